@@ -0,0 +1,624 @@
+package claudetool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"shelley.exe.dev/claudetool/sandbox"
+	"shelley.exe.dev/llm"
+)
+
+const (
+	// DefaultBackgroundLogSize bounds the log kept for each background process.
+	DefaultBackgroundLogSize = 1 << 20
+
+	backgroundStopGrace    = 5 * time.Second
+	backgroundTailLines    = 50
+	backgroundTailMaxBytes = 16 * 1024
+	backgroundWaitTimeout  = 30 * time.Second
+)
+
+// BackgroundProcesses runs long-lived commands, such as servers and watch
+// modes, for one conversation. Their output goes to log files that keep
+// only the most recent output.
+type BackgroundProcesses struct {
+	// ConversationID is exposed to processes via SHELLEY_CONVERSATION_ID.
+	ConversationID string
+	// LogDir is where output is logged. Defaults to a temporary directory.
+	LogDir string
+	// MaxLogSize bounds each process's log. Defaults to DefaultBackgroundLogSize.
+	MaxLogSize int64
+	// OnExit, if set, is called when a process exits by itself, rather than
+	// being stopped.
+	OnExit func(BackgroundProcessInfo)
+
+	mu     sync.Mutex
+	procs  []*BackgroundProcess
+	nextID int
+}
+
+// BackgroundProcess is a command started by BackgroundProcesses.
+type BackgroundProcess struct {
+	ID         string
+	Command    string
+	WorkingDir string
+	PID        int
+	StartedAt  time.Time
+	LogPath    string
+
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	log      *ringLog
+	done     chan struct{}
+	stopOnce sync.Once
+
+	mu       sync.Mutex
+	exitedAt time.Time
+	exitCode int
+	stopped  bool
+}
+
+// BackgroundProcessInfo describes a background process for the UI.
+type BackgroundProcessInfo struct {
+	ID         string     `json:"id"`
+	Command    string     `json:"command"`
+	WorkingDir string     `json:"working_dir"`
+	PID        int        `json:"pid"`
+	StartedAt  time.Time  `json:"started_at"`
+	Running    bool       `json:"running"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	ExitedAt   *time.Time `json:"exited_at,omitempty"`
+	LogPath    string     `json:"log_path"`
+}
+
+// Running reports whether the process has not exited yet.
+func (p *BackgroundProcess) Running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Info returns a snapshot of the process's state.
+func (p *BackgroundProcess) Info() BackgroundProcessInfo {
+	info := BackgroundProcessInfo{
+		ID:         p.ID,
+		Command:    p.Command,
+		WorkingDir: p.WorkingDir,
+		PID:        p.PID,
+		StartedAt:  p.StartedAt,
+		Running:    p.Running(),
+		LogPath:    p.LogPath,
+	}
+	if !info.Running {
+		p.mu.Lock()
+		code, at := p.exitCode, p.exitedAt
+		p.mu.Unlock()
+		info.ExitCode = &code
+		info.ExitedAt = &at
+	}
+	return info
+}
+
+// status describes the process in a line for the agent.
+func (p *BackgroundProcess) status() string {
+	info := p.Info()
+	if info.Running {
+		return fmt.Sprintf("process %s (pid %d) is running: %s", p.ID, p.PID, p.Command)
+	}
+	return fmt.Sprintf("process %s (pid %d) exited with code %d: %s", p.ID, p.PID, *info.ExitCode, p.Command)
+}
+
+// Start runs command with bash in workingDir, using executor, or directly on the host if it is nil.
+func (bp *BackgroundProcesses) Start(command, workingDir string, executor sandbox.Executor) (*BackgroundProcess, error) {
+	bp.mu.Lock()
+	bp.nextID++
+	id := strconv.Itoa(bp.nextID)
+	logDir := bp.LogDir
+	maxLogSize := bp.MaxLogSize
+	onExit := bp.OnExit
+	bp.mu.Unlock()
+
+	if logDir == "" {
+		logDir = filepath.Join(os.TempDir(), "shelley-background", bp.ConversationID)
+	}
+	if maxLogSize <= 0 {
+		maxLogSize = DefaultBackgroundLogSize
+	}
+	if err := os.MkdirAll(logDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	logPath := filepath.Join(logDir, id+".log")
+	log, err := newRingLog(logPath, maxLogSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if executor == nil {
+		executor = sandbox.Host{}
+	}
+	cmd := executor.Command(context.Background(), command, workingDir, bashEnv(bp.ConversationID))
+	cmd.Stdout = log
+	cmd.Stderr = log
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} // set up for stopping the process group
+	// Don't wait for children that keep the output open after bash exits.
+	cmd.WaitDelay = time.Second
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		log.Close()
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+
+	p := &BackgroundProcess{
+		ID:         id,
+		Command:    command,
+		WorkingDir: workingDir,
+		PID:        cmd.Process.Pid,
+		StartedAt:  time.Now(),
+		LogPath:    logPath,
+		cmd:        cmd,
+		stdin:      stdin,
+		log:        log,
+		done:       make(chan struct{}),
+	}
+	go func() {
+		cmd.Wait() // the exit code is in ProcessState
+		p.mu.Lock()
+		p.exitedAt = time.Now()
+		p.exitCode = cmd.ProcessState.ExitCode()
+		stopped := p.stopped
+		p.mu.Unlock()
+		close(p.done)
+		if onExit != nil && !stopped {
+			onExit(p.Info())
+		}
+	}()
+
+	bp.mu.Lock()
+	bp.procs = append(bp.procs, p)
+	bp.mu.Unlock()
+	return p, nil
+}
+
+// Get returns the process with the given ID.
+func (bp *BackgroundProcesses) Get(id string) (*BackgroundProcess, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	for _, p := range bp.procs {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("no background process with id %q", id)
+}
+
+// List describes all processes, including those that have exited, in the order they started.
+func (bp *BackgroundProcesses) List() []BackgroundProcessInfo {
+	bp.mu.Lock()
+	procs := slices.Clone(bp.procs)
+	bp.mu.Unlock()
+	infos := make([]BackgroundProcessInfo, 0, len(procs))
+	for _, p := range procs {
+		infos = append(infos, p.Info())
+	}
+	return infos
+}
+
+// HasRunning reports whether any process is still running.
+func (bp *BackgroundProcesses) HasRunning() bool {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return slices.ContainsFunc(bp.procs, (*BackgroundProcess).Running)
+}
+
+// Stop stops a process and anything it started, first with SIGTERM and then,
+// if they have not exited after a grace period, with SIGKILL.
+func (p *BackgroundProcess) Stop() {
+	p.stopOnce.Do(func() {
+		p.mu.Lock()
+		p.stopped = true
+		p.mu.Unlock()
+		// Signal the group even if bash has exited, to stop children it left behind.
+		syscall.Kill(-p.PID, syscall.SIGTERM)
+		select {
+		case <-p.done:
+		case <-time.After(backgroundStopGrace):
+			syscall.Kill(-p.PID, syscall.SIGKILL)
+			<-p.done
+		}
+		p.stdin.Close()
+	})
+}
+
+// StopAll stops every process and removes their logs.
+func (bp *BackgroundProcesses) StopAll() {
+	bp.mu.Lock()
+	procs := bp.procs
+	bp.procs = nil
+	bp.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, p := range procs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Stop()
+			p.log.Close()
+			os.Remove(p.LogPath)
+		}()
+	}
+	wg.Wait()
+}
+
+// Send writes input to the process's stdin.
+func (p *BackgroundProcess) Send(input string) error {
+	if !p.Running() {
+		return errors.New("process has exited")
+	}
+	_, err := io.WriteString(p.stdin, input)
+	return err
+}
+
+// Tail returns up to the last n lines of the process's output.
+func (p *BackgroundProcess) Tail(n int) (string, error) {
+	data, dropped, err := p.log.Contents()
+	if err != nil {
+		return "", err
+	}
+	out := string(data)
+	lines := strings.SplitAfter(out, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+		dropped = 1
+	}
+	out = strings.Join(lines, "")
+	if len(out) > backgroundTailMaxBytes {
+		out = out[len(out)-backgroundTailMaxBytes:]
+		dropped = 1
+	}
+	if dropped > 0 {
+		out = "[earlier output omitted, see " + p.LogPath + "]\n" + out
+	}
+	return out, nil
+}
+
+// WaitFor waits until the process's output matches re, the process exits, or timeout passes.
+// It reports whether the output matched.
+func (p *BackgroundProcess) WaitFor(ctx context.Context, re *regexp.Regexp, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if data, _, err := p.log.Contents(); err == nil && re.Match(data) {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-p.done:
+			data, _, err := p.log.Contents()
+			return err == nil && re.Match(data)
+		case <-ticker.C:
+		}
+	}
+}
+
+// ringLog is an io.Writer that logs to a file, discarding the oldest output
+// to keep the file under max bytes.
+type ringLog struct {
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+	max     int64
+	dropped int64 // bytes discarded so far
+}
+
+func newRingLog(path string, max int64) (*ringLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log file: %w", err)
+	}
+	return &ringLog{f: f, max: max}, nil
+}
+
+func (r *ringLog) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	written := len(p)
+	if r.f == nil {
+		return written, nil
+	}
+	if int64(len(p)) > r.max/2 {
+		// Only the end of a very large write would survive trimming anyway.
+		r.dropped += int64(len(p)) - r.max/2
+		p = p[int64(len(p))-r.max/2:]
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+	if r.size > r.max {
+		if err := r.trim(); err != nil {
+			return n, err
+		}
+	}
+	return written, nil
+}
+
+// trim keeps the most recent half of the log, starting at a line boundary.
+func (r *ringLog) trim() error {
+	// Read one byte more to tell whether the kept half starts a line.
+	buf := make([]byte, r.max/2+1)
+	if _, err := r.f.ReadAt(buf, r.size-int64(len(buf))); err != nil {
+		return err
+	}
+	keep := buf[1:]
+	if buf[0] != '\n' {
+		if i := bytes.IndexByte(keep, '\n'); i >= 0 && i < len(keep)-1 {
+			keep = keep[i+1:]
+		}
+	}
+	if err := r.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := r.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := r.f.Write(keep); err != nil {
+		return err
+	}
+	r.dropped += r.size - int64(len(keep))
+	r.size = int64(len(keep))
+	return nil
+}
+
+// Contents returns the logged output and how many earlier bytes were discarded.
+func (r *ringLog) Contents() ([]byte, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil, r.dropped, errors.New("log is closed")
+	}
+	data := make([]byte, r.size)
+	if _, err := r.f.ReadAt(data, 0); err != nil && !errors.Is(err, io.EOF) {
+		return nil, r.dropped, err
+	}
+	return data, r.dropped, nil
+}
+
+// Close closes the log file. Output written afterwards is discarded.
+func (r *ringLog) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// BackgroundTool manages background processes for the agent.
+type BackgroundTool struct {
+	Processes *BackgroundProcesses
+	// WorkingDir is the shared mutable working directory.
+	WorkingDir *MutableWorkingDir
+	// Sandbox runs commands. If nil, they run directly on the host.
+	Sandbox sandbox.Executor
+}
+
+const (
+	backgroundName        = "background"
+	backgroundDescription = `Runs and manages long-running background processes, such as dev servers and watch modes.
+Processes keep running across tool calls until stopped, and are stopped when the conversation ends.
+Output (stdout and stderr) is logged; only the most recent output is kept.
+
+Use the "action" field to select an operation:
+
+- action: "start"
+  Start a command with bash in the current working directory and return its id.
+  Parameters: command (string, required), wait_for (regex, optional), timeout (string, optional)
+  With wait_for, waits until the output matches (e.g. "listening on") before returning.
+
+- action: "list"
+  List processes with their ids, commands and whether they are running.
+
+- action: "tail"
+  Show the most recent output of a process.
+  Parameters: id (string, required), lines (integer, default 50), wait_for (regex, optional), timeout (string, optional)
+
+- action: "send"
+  Write to a process's stdin. Include "\n" to send a newline.
+  Parameters: id (string, required), input (string, required)
+
+- action: "stop"
+  Stop a process and any processes it started.
+  Parameters: id (string, required)
+`
+	backgroundInputSchema = `{
+  "type": "object",
+  "required": ["action"],
+  "properties": {
+    "action": {
+      "type": "string",
+      "enum": ["start", "list", "tail", "send", "stop"],
+      "description": "The operation to perform"
+    },
+    "command": {
+      "type": "string",
+      "description": "Shell command to run (start action)"
+    },
+    "id": {
+      "type": "string",
+      "description": "Process id (tail, send and stop actions)"
+    },
+    "lines": {
+      "type": "integer",
+      "description": "Number of output lines to show (tail action, default 50)"
+    },
+    "input": {
+      "type": "string",
+      "description": "Text to write to stdin (send action)"
+    },
+    "wait_for": {
+      "type": "string",
+      "description": "Regular expression to wait for in the output (start and tail actions)"
+    },
+    "timeout": {
+      "type": "string",
+      "description": "How long to wait for wait_for, as a Go duration (default 30s)"
+    }
+  }
+}`
+)
+
+type backgroundInput struct {
+	Action  string `json:"action"`
+	Command string `json:"command"`
+	ID      string `json:"id"`
+	Lines   int    `json:"lines"`
+	Input   string `json:"input"`
+	WaitFor string `json:"wait_for"`
+	Timeout string `json:"timeout"`
+}
+
+// Tool returns an llm.Tool for managing background processes.
+func (b *BackgroundTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        backgroundName,
+		Description: backgroundDescription,
+		InputSchema: llm.MustSchema(backgroundInputSchema),
+		Sequential:  true,
+		Run:         b.Run,
+	}
+}
+
+// Run executes the background tool.
+func (b *BackgroundTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req backgroundInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse background input: %w", err)
+	}
+
+	var waitFor *regexp.Regexp
+	if req.WaitFor != "" {
+		var err error
+		if waitFor, err = regexp.Compile(req.WaitFor); err != nil {
+			return llm.ErrorfToolOut("invalid wait_for pattern: %w", err)
+		}
+	}
+	timeout := backgroundWaitTimeout
+	if req.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(req.Timeout); err != nil {
+			return llm.ErrorfToolOut("invalid timeout: %w", err)
+		}
+	}
+
+	switch req.Action {
+	case "start":
+		if req.Command == "" {
+			return llm.ErrorfToolOut("command is required")
+		}
+		wd := b.WorkingDir.Get()
+		if _, err := os.Stat(wd); err != nil {
+			return llm.ErrorfToolOut("cannot access working directory %s: %w", wd, err)
+		}
+		p, err := b.Processes.Start(req.Command, wd, b.Sandbox)
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		if waitFor == nil {
+			return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("started %s", p.status()))}
+		}
+		return b.waitAndTail(ctx, p, waitFor, timeout, backgroundTailLines)
+
+	case "list":
+		infos := b.Processes.List()
+		if len(infos) == 0 {
+			return llm.ToolOut{LLMContent: llm.TextContent("no background processes")}
+		}
+		var sb strings.Builder
+		for _, info := range infos {
+			p, err := b.Processes.Get(info.ID)
+			if err != nil {
+				continue
+			}
+			sb.WriteString(p.status())
+			sb.WriteString("\n")
+		}
+		return llm.ToolOut{LLMContent: llm.TextContent(sb.String())}
+
+	case "tail":
+		p, err := b.Processes.Get(req.ID)
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		lines := req.Lines
+		if lines <= 0 {
+			lines = backgroundTailLines
+		}
+		return b.waitAndTail(ctx, p, waitFor, timeout, lines)
+
+	case "send":
+		p, err := b.Processes.Get(req.ID)
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		if err := p.Send(req.Input); err != nil {
+			return llm.ErrorfToolOut("failed to write to process %s: %w", p.ID, err)
+		}
+		return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("sent %d bytes to process %s", len(req.Input), p.ID))}
+
+	case "stop":
+		p, err := b.Processes.Get(req.ID)
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		p.Stop()
+		return llm.ToolOut{LLMContent: llm.TextContent(p.status())}
+	}
+	return llm.ErrorfToolOut("unknown action %q", req.Action)
+}
+
+// waitAndTail optionally waits for output matching waitFor, then shows the process's recent output.
+func (b *BackgroundTool) waitAndTail(ctx context.Context, p *BackgroundProcess, waitFor *regexp.Regexp, timeout time.Duration, lines int) llm.ToolOut {
+	var note string
+	if waitFor != nil && !p.WaitFor(ctx, waitFor, timeout) {
+		note = fmt.Sprintf("[output did not match %q", waitFor)
+		if p.Running() {
+			note += fmt.Sprintf(" within %s", timeout)
+		}
+		note += "]\n"
+	}
+	out, err := p.Tail(lines)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to read output of process %s: %w", p.ID, err)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(p.status() + "\n" + note + out)}
+}
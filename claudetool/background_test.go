@@ -0,0 +1,197 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/llm"
+)
+
+func runBackground(t *testing.T, tool *llm.Tool, input string) string {
+	t.Helper()
+	out := tool.Run(context.Background(), json.RawMessage(input))
+	if out.Error != nil {
+		t.Fatalf("background %s: %v", input, out.Error)
+	}
+	return out.LLMContent[0].Text
+}
+
+func newBackgroundTool(t *testing.T) (*llm.Tool, *BackgroundProcesses) {
+	t.Helper()
+	procs := &BackgroundProcesses{LogDir: t.TempDir()}
+	t.Cleanup(procs.StopAll)
+	tool := &BackgroundTool{Processes: procs, WorkingDir: NewMutableWorkingDir(t.TempDir())}
+	return tool.Tool(), procs
+}
+
+func TestBackgroundTool(t *testing.T) {
+	tool, procs := newBackgroundTool(t)
+
+	out := runBackground(t, tool, `{"action":"start","command":"echo ready; cat","wait_for":"ready"}`)
+	if !strings.Contains(out, "process 1") || !strings.Contains(out, "is running") || !strings.Contains(out, "ready") {
+		t.Fatalf("unexpected start output: %q", out)
+	}
+
+	runBackground(t, tool, `{"action":"send","id":"1","input":"hello from stdin\n"}`)
+	out = runBackground(t, tool, `{"action":"tail","id":"1","wait_for":"hello from stdin","timeout":"5s"}`)
+	if !strings.Contains(out, "hello from stdin") || strings.Contains(out, "did not match") {
+		t.Fatalf("expected the echoed input, got %q", out)
+	}
+
+	out = runBackground(t, tool, `{"action":"list"}`)
+	if !strings.Contains(out, "process 1 (pid") || !strings.Contains(out, "echo ready; cat") {
+		t.Fatalf("unexpected list output: %q", out)
+	}
+
+	out = runBackground(t, tool, `{"action":"stop","id":"1"}`)
+	if !strings.Contains(out, "exited") {
+		t.Fatalf("expected the process to have exited, got %q", out)
+	}
+	infos := procs.List()
+	if len(infos) != 1 || infos[0].Running || infos[0].ExitCode == nil {
+		t.Fatalf("unexpected process info after stop: %+v", infos)
+	}
+
+	if out := tool.Run(context.Background(), json.RawMessage(`{"action":"tail","id":"7"}`)); out.Error == nil {
+		t.Fatal("expected an error for an unknown process")
+	}
+}
+
+func TestBackgroundOnExit(t *testing.T) {
+	exits := make(chan BackgroundProcessInfo, 2)
+	procs := &BackgroundProcesses{LogDir: t.TempDir(), OnExit: func(info BackgroundProcessInfo) { exits <- info }}
+	t.Cleanup(procs.StopAll)
+
+	if _, err := procs.Start("exit 3", t.TempDir(), nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case info := <-exits:
+		if info.Command != "exit 3" || info.ExitCode == nil || *info.ExitCode != 3 {
+			t.Errorf("unexpected exit info: %+v", info)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnExit was not called")
+	}
+
+	// Stopped processes don't count.
+	p, err := procs.Start("sleep 300", t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Stop()
+	select {
+	case info := <-exits:
+		t.Errorf("OnExit called for a stopped process: %+v", info)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestBackgroundStopKillsChildren(t *testing.T) {
+	tool, procs := newBackgroundTool(t)
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	runBackground(t, tool, `{"action":"start","command":"sleep 300 & echo $! > `+pidFile+`; wait"}`)
+
+	var childPID int
+	deadline := time.Now().Add(5 * time.Second)
+	for childPID == 0 && time.Now().Before(deadline) {
+		if data, err := os.ReadFile(pidFile); err == nil && strings.HasSuffix(string(data), "\n") {
+			json.Unmarshal(data, &childPID)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if childPID == 0 {
+		t.Fatal("child did not start")
+	}
+
+	procs.StopAll()
+	if len(procs.List()) != 0 {
+		t.Fatal("expected StopAll to forget the processes")
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for syscall.Kill(childPID, 0) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("child %d still running after StopAll", childPID)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRingLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	log, err := newRingLog(path, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	// Output may include secrets, so only the owner may read it.
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode().Perm() != 0o600 {
+		t.Fatalf("expected a log file with mode 0600, got %v", fi.Mode().Perm())
+	}
+
+	for i := range 30 {
+		if _, err := log.Write([]byte(strings.Repeat(string(rune('a'+i%26)), 9) + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, dropped, err := log.Contents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > 100 || dropped == 0 {
+		t.Fatalf("expected the log to be trimmed, got %d bytes with %d dropped", len(data), dropped)
+	}
+	if !strings.HasSuffix(string(data), "ccccccccc\nddddddddd\n") {
+		t.Fatalf("expected the most recent output to be kept, got %q", data)
+	}
+	if data[0] == '\n' || strings.Count(string(data), "\n") != len(data)/10 {
+		t.Fatalf("expected the log to start at a line boundary, got %q", data)
+	}
+	onDisk, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(onDisk) != string(data) {
+		t.Fatalf("file contents %q differ from %q", onDisk, data)
+	}
+
+	// A single write larger than the log keeps only its end.
+	log.Write([]byte(strings.Repeat("x", 500) + "end\n"))
+	data, _, _ = log.Contents()
+	if len(data) > 100 || !strings.HasSuffix(string(data), "end\n") {
+		t.Fatalf("unexpected contents after a large write: %q", data)
+	}
+}
+
+func TestToolSetCleanupStopsBackgroundProcesses(t *testing.T) {
+	ts := NewToolSet(context.Background(), ToolSetConfig{WorkingDir: t.TempDir()})
+	p, err := ts.Background().Start("sleep 300", t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Cleanup()
+	if p.Running() {
+		t.Fatal("expected Cleanup to stop the process")
+	}
+
+	// A tool set given processes leaves them running.
+	shared := &BackgroundProcesses{LogDir: t.TempDir()}
+	defer shared.StopAll()
+	ts = NewToolSet(context.Background(), ToolSetConfig{WorkingDir: t.TempDir(), Background: shared})
+	p, err = ts.Background().Start("sleep 300", t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Cleanup()
+	if !p.Running() {
+		t.Fatal("expected the shared process to keep running")
+	}
+}
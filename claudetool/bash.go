@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"shelley.exe.dev/claudetool/bashkit"
+	"shelley.exe.dev/claudetool/sandbox"
 	"shelley.exe.dev/llm"
 )
 
@@ -39,6 +40,8 @@ type BashTool struct {
 	// ConversationID is the ID of the conversation this tool belongs to.
 	// It is exposed to invoked commands via SHELLEY_CONVERSATION_ID.
 	ConversationID string
+	// Sandbox runs commands. If nil, they run directly on the host.
+	Sandbox sandbox.Executor
 }
 
 const (
@@ -77,7 +80,15 @@ func (b *BashTool) Tool() *llm.Tool {
 		Name:        bashName,
 		Description: strings.TrimSpace(bashDescription),
 		InputSchema: llm.MustSchema(bashInputSchema),
-		Run:         b.Run,
+		// Commands that only read can run alongside other calls.
+		SequentialCall: func(input json.RawMessage) bool {
+			var req bashInput
+			if err := json.Unmarshal(input, &req); err != nil {
+				return true
+			}
+			return !bashkit.ReadOnly(req.Command)
+		},
+		Run: b.Run,
 	}
 }
 
@@ -100,7 +111,7 @@ const (
 	bashDescription = `Executes shell commands via bash --login -c, returning combined stdout/stderr.
 Bash state changes (working dir, variables, aliases) don't persist between calls.
 
-For long-running processes (servers, watch modes), use tmux instead.
+For long-running processes (servers, watch modes), use the background tool instead.
 Do NOT use &, nohup, or disown — the bash tool kills its process group on exit.
 
 MUST set slow_ok=true for potentially slow commands: builds, downloads,
@@ -211,9 +222,11 @@ const (
 )
 
 func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.Writer) *exec.Cmd {
-	cmd := exec.CommandContext(ctx, "bash", "--login", "-c", command)
-	// Use shared WorkingDir if available, then context, then Pwd fallback
-	cmd.Dir = b.getWorkingDir()
+	executor := b.Sandbox
+	if executor == nil {
+		executor = sandbox.Host{}
+	}
+	cmd := executor.Command(ctx, command, b.getWorkingDir(), bashEnv(b.ConversationID))
 	cmd.Stdin = nil
 	cmd.Stdout = out
 	cmd.Stderr = out
@@ -228,17 +241,21 @@ func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.W
 		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) // kill entire process group
 	}
 	cmd.WaitDelay = 15 * time.Second // prevent indefinite hangs when child processes keep pipes open
+	return cmd
+}
+
+// bashEnv returns the environment for commands run on behalf of a conversation.
+func bashEnv(conversationID string) []string {
 	// Remove SHELLEY_CONVERSATION_ID so we control it explicitly below.
 	env := slices.DeleteFunc(os.Environ(), func(s string) bool {
 		return strings.HasPrefix(s, "SHELLEY_CONVERSATION_ID=")
 	})
 	env = append(env, "SKETCH=1")          // signal that this has been run by Sketch, sometimes useful for scripts
 	env = append(env, "EDITOR=/bin/false") // interactive editors won't work
-	if b.ConversationID != "" {
-		env = append(env, "SHELLEY_CONVERSATION_ID="+b.ConversationID)
+	if conversationID != "" {
+		env = append(env, "SHELLEY_CONVERSATION_ID="+conversationID)
 	}
-	cmd.Env = env
-	return cmd
+	return env
 }
 
 func cmdWait(cmd *exec.Cmd) error {
@@ -254,14 +271,21 @@ func (b *BashTool) executeBash(ctx context.Context, req bashInput, timeout time.
 	execCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	output := new(bytes.Buffer)
+	output := new(syncBuffer)
 	cmd := b.makeBashCommand(execCtx, req.Command, output)
 	cmd.Env = append(cmd.Env, `GIT_SEQUENCE_EDITOR=echo "To do an interactive rebase, run it in a tmux session." && exit 1`)
 	if err := cmd.Start(); err != nil {
 		return "", fmt.Errorf("command failed: %w", err)
 	}
 
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	if HasProgress(ctx) {
+		wg.Go(func() { reportBashProgress(ctx, output, done) })
+	}
 	err := cmdWait(cmd)
+	close(done)
+	wg.Wait()
 
 	out, formatErr := formatForegroundBashOutput(output.String())
 	if formatErr != nil {
@@ -278,6 +302,67 @@ func (b *BashTool) executeBash(ctx context.Context, req bashInput, timeout time.
 	return out, nil
 }
 
+const (
+	// bashProgressInterval is how often the output of a running command is reported.
+	bashProgressInterval = 250 * time.Millisecond
+	// bashProgressMaxBytes limits the output sent with each progress report.
+	bashProgressMaxBytes = 8 * 1024
+)
+
+// reportBashProgress reports the end of a running command's output whenever it grows, until done is closed.
+func reportBashProgress(ctx context.Context, output *syncBuffer, done <-chan struct{}) {
+	ticker := time.NewTicker(bashProgressInterval)
+	defer ticker.Stop()
+	reported := 0
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		n, tail := output.tail(bashProgressMaxBytes)
+		if n != reported {
+			reported = n
+			ReportProgress(ctx, tail)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be read while a command writes to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// tail returns the buffer's length and up to its last limit bytes,
+// starting at a line boundary if the output was cut.
+func (b *syncBuffer) tail(limit int) (int, string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data := b.buf.Bytes()
+	n := len(data)
+	if n <= limit {
+		return n, string(data)
+	}
+	data = data[n-limit:]
+	if i := bytes.IndexByte(data, '\n'); i >= 0 && i < len(data)-1 {
+		data = data[i+1:]
+	}
+	return n, strings.ToValidUTF8(string(data), "")
+}
+
 // formatForegroundBashOutput formats the output of a foreground bash command for display to the agent.
 // If output exceeds largeOutputThreshold, it saves to a file and returns a summary.
 func formatForegroundBashOutput(out string) (string, error) {
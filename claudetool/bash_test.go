@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"os/exec"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -274,6 +276,24 @@ func TestExecuteBash(t *testing.T) {
 	})
 }
 
+func TestBashRunsSequentially(t *testing.T) {
+	tool := (&BashTool{WorkingDir: NewMutableWorkingDir("/")}).Tool()
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{`{"command":"git status && grep -rn foo ."}`, false},
+		{`{"command":"go test ./..."}`, true},
+		{`{"command":"ls > out"}`, true},
+		{`not json`, true},
+	}
+	for _, tt := range tests {
+		if got := tool.RunsSequentially(json.RawMessage(tt.input)); got != tt.want {
+			t.Errorf("RunsSequentially(%s) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
 func TestBashTimeout(t *testing.T) {
 	// Test default timeout values
 	t.Run("Default Timeout Values", func(t *testing.T) {
@@ -456,3 +476,51 @@ func TestIsNoTrailerSet(t *testing.T) {
 		}
 	})
 }
+
+func TestBashProgress(t *testing.T) {
+	var mu sync.Mutex
+	var reports []string
+	ctx := WithProgress(context.Background(), func(output string) {
+		mu.Lock()
+		reports = append(reports, output)
+		mu.Unlock()
+	})
+
+	bashTool := &BashTool{WorkingDir: NewMutableWorkingDir(t.TempDir())}
+	out, err := bashTool.executeBash(ctx, bashInput{Command: "echo first; sleep 1; echo second"}, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "first\nsecond\n") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	partial := slices.IndexFunc(reports, func(r string) bool {
+		return strings.Contains(r, "first") && !strings.Contains(r, "second")
+	})
+	if partial < 0 {
+		t.Fatalf("expected progress with the output so far while the command ran, got %q", reports)
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i] == reports[i-1] {
+			t.Fatalf("expected progress only when the output changes, got %q twice", reports[i])
+		}
+	}
+}
+
+func TestSyncBufferTail(t *testing.T) {
+	var b syncBuffer
+	b.Write([]byte("one\ntwo\nthree\n"))
+	if n, tail := b.tail(100); n != 14 || tail != "one\ntwo\nthree\n" {
+		t.Fatalf("tail(100) = %d, %q", n, tail)
+	}
+	if _, tail := b.tail(9); tail != "three\n" {
+		t.Fatalf("expected the tail to start at a line boundary, got %q", tail)
+	}
+	b.Write([]byte(strings.Repeat("é", 10)))
+	if _, tail := b.tail(5); tail != "éé" {
+		t.Fatalf("expected the tail to drop a partial rune, got %q", tail)
+	}
+}
@@ -65,3 +65,187 @@ func ExtractCommands(command string) ([]string, error) {
 
 	return commands, nil
 }
+
+// SimpleCommands parses a bash script and returns the words of each simple
+// command in it, in the order they appear. Commands inside pipelines, lists,
+// control flow and command substitutions are all included. Quotes around
+// literal text are removed; words containing expansions are kept as written.
+//
+// Examples:
+//
+//	"cd src && go test ./... | tee out" → [["cd" "src"] ["go" "test" "./..."] ["tee" "out"]]
+//	"git commit -m 'fix: x'" → [["git" "commit" "-m" "fix: x"]]
+//	"echo $(rm -rf /tmp/x)" → [["echo" "$(rm -rf /tmp/x)"] ["rm" "-rf" "/tmp/x"]]
+func SimpleCommands(script string) ([][]string, error) {
+	file, err := syntax.NewParser().Parse(strings.NewReader(script), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse bash command: %w", err)
+	}
+
+	printer := syntax.NewPrinter()
+	var commands [][]string
+	syntax.Walk(file, func(node syntax.Node) bool {
+		callExpr, ok := node.(*syntax.CallExpr)
+		if !ok || len(callExpr.Args) == 0 {
+			return true
+		}
+		words := make([]string, len(callExpr.Args))
+		for i, arg := range callExpr.Args {
+			words[i] = wordText(printer, arg)
+		}
+		commands = append(commands, words)
+		return true
+	})
+	return commands, nil
+}
+
+// wordText returns the value of word with quoting removed from its literal
+// parts. Other parts, such as parameter expansions, are printed as written.
+func wordText(printer *syntax.Printer, word *syntax.Word) string {
+	var sb strings.Builder
+	for _, part := range word.Parts {
+		switch part := part.(type) {
+		case *syntax.Lit:
+			sb.WriteString(part.Value)
+		case *syntax.SglQuoted:
+			sb.WriteString(part.Value)
+		case *syntax.DblQuoted:
+			for _, inner := range part.Parts {
+				if lit, ok := inner.(*syntax.Lit); ok {
+					sb.WriteString(lit.Value)
+				} else {
+					printer.Print(&sb, inner)
+				}
+			}
+		default:
+			printer.Print(&sb, part)
+		}
+	}
+	return sb.String()
+}
+
+// readOnlyCommands are the commands ReadOnly accepts, mapped to the options
+// that make them write or run other programs. Options are matched by prefix.
+var readOnlyCommands = map[string][]string{
+	"[": nil, "basename": nil, "cat": nil, "cmp": nil, "cut": nil, "df": nil,
+	"diff": nil, "dirname": nil, "du": nil, "echo": nil, "egrep": nil,
+	"false": nil, "fgrep": nil, "grep": nil, "head": nil, "id": nil,
+	"jq": nil, "ls": nil, "md5sum": nil, "nl": nil, "printf": nil, "pwd": nil,
+	"readlink": nil, "realpath": nil, "sha1sum": nil, "sha256sum": nil,
+	"stat": nil, "tail": nil, "test": nil, "tr": nil, "true": nil, "type": nil,
+	"uname": nil, "wc": nil, "which": nil, "whoami": nil,
+	"find": {"-delete", "-exec", "-ok", "-fprint", "-fls"},
+	"rg":   {"--pre"},
+	"git":  {"--output", "-O", "--open-files-in-pager"},
+}
+
+// readOnlyGitCommands are the git subcommands ReadOnly accepts.
+var readOnlyGitCommands = map[string]bool{
+	"blame": true, "cat-file": true, "describe": true, "diff": true,
+	"grep": true, "log": true, "ls-files": true, "ls-tree": true,
+	"merge-base": true, "rev-list": true, "rev-parse": true, "shortlog": true,
+	"show": true, "status": true,
+}
+
+// ReadOnly reports whether a bash script only reads files: every command in
+// it is one known not to write, it sets no variables, and it redirects
+// output only to /dev/null.
+// Scripts it can't tell about, including ones that fail to parse, are not
+// read-only.
+//
+// Examples:
+//
+//	"grep -rn foo . | head" → true
+//	"git log --oneline && cat go.mod" → true
+//	"ls > files.txt" → false
+//	"find . -name '*.tmp' -delete" → false
+func ReadOnly(script string) bool {
+	file, err := syntax.NewParser().Parse(strings.NewReader(script), "")
+	if err != nil {
+		return false
+	}
+
+	printer := syntax.NewPrinter()
+	readOnly := true
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.Redirect:
+			readOnly = readOnly && !redirectWrites(node)
+		case *syntax.DeclClause:
+			// Variables like PATH or GIT_EXTERNAL_DIFF change what later commands run.
+			readOnly = false
+		case *syntax.CallExpr:
+			if len(node.Assigns) > 0 {
+				readOnly = false
+			} else if len(node.Args) > 0 {
+				words := make([]string, len(node.Args))
+				for i, arg := range node.Args {
+					words[i] = wordText(printer, arg)
+				}
+				readOnly = readOnly && readOnlyCommand(words, node.Args)
+			}
+		}
+		return readOnly
+	})
+	return readOnly
+}
+
+// redirectWrites reports whether r writes to a file other than /dev/null.
+func redirectWrites(r *syntax.Redirect) bool {
+	switch r.Op {
+	case syntax.RdrIn, syntax.DplIn, syntax.Hdoc, syntax.DashHdoc, syntax.WordHdoc:
+		return false
+	case syntax.DplOut:
+		// 2>&1 and >&- duplicate or close descriptors; >&file writes to file.
+		if target := r.Word.Lit(); target == "-" || (target != "" && strings.Trim(target, "0123456789") == "") {
+			return false
+		}
+	}
+	return r.Word == nil || r.Word.Lit() != "/dev/null"
+}
+
+// readOnlyCommand reports whether the simple command words, parsed from
+// args, is in readOnlyCommands and uses none of its writing options.
+func readOnlyCommand(words []string, args []*syntax.Word) bool {
+	name := words[0]
+	if args[0].Lit() != name {
+		return false
+	}
+	banned, ok := readOnlyCommands[name]
+	if !ok {
+		return false
+	}
+	if name == "git" && (len(words) < 2 || !readOnlyGitCommands[words[1]]) {
+		return false
+	}
+	for i, word := range words[1:] {
+		if len(banned) > 0 && !literal(args[i+1]) {
+			// An expansion could supply a banned option.
+			return false
+		}
+		for _, option := range banned {
+			if strings.HasPrefix(word, option) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// literal reports whether word has no expansions.
+func literal(word *syntax.Word) bool {
+	for _, part := range word.Parts {
+		switch part := part.(type) {
+		case *syntax.Lit, *syntax.SglQuoted:
+		case *syntax.DblQuoted:
+			for _, inner := range part.Parts {
+				if _, ok := inner.(*syntax.Lit); !ok {
+					return false
+				}
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -203,3 +203,77 @@ func TestExtractCommandsEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestSimpleCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected [][]string
+	}{
+		{"ls -la", [][]string{{"ls", "-la"}}},
+		{"cd src && go test ./... | tee out", [][]string{{"cd", "src"}, {"go", "test", "./..."}, {"tee", "out"}}},
+		{`git commit -m 'fix: x' -m "more text"`, [][]string{{"git", "commit", "-m", "fix: x", "-m", "more text"}}},
+		{"echo $(rm -rf /tmp/x)", [][]string{{"echo", "$(rm -rf /tmp/x)"}, {"rm", "-rf", "/tmp/x"}}},
+		{`FOO=bar make "$TARGET"`, [][]string{{"make", "$TARGET"}}},
+		{"if true; then curl example.com; fi", [][]string{{"true"}, {"curl", "example.com"}}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := SimpleCommands(tt.input)
+			if err != nil {
+				t.Fatalf("SimpleCommands(%q) error: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("SimpleCommands(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := SimpleCommands("echo 'unterminated"); err == nil {
+		t.Error("expected an error for invalid syntax")
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"ls -la", true},
+		{"grep -rn foo . | head -20", true},
+		{"cat go.mod && git log --oneline -5", true},
+		{"git status; git diff HEAD~1 -- main.go", true},
+		{"find . -name '*.go' 2>/dev/null | wc -l", true},
+		{"grep -n TODO $(git ls-files)", true},
+		{"rg TODO $(git ls-files)", false},
+		{`if [ -f go.mod ]; then cat go.mod; fi`, true},
+		{"grep foo < input.txt 2>&1", true},
+		{"ls > files.txt", false},
+		{"cat a >> b", false},
+		{"ls &> out.log", false},
+		{"ls >&out.log", false},
+		{"rm -rf build", false},
+		{"ls && go build ./...", false},
+		{"find . -name '*.tmp' -delete", false},
+		{"find . -exec rm {} +", false},
+		{"find . $FLAGS", false},
+		{"git commit -m x", false},
+		{"git -C sub status", false},
+		{"git", false},
+		{"git diff --output=patch", false},
+		{"cat $(touch x)", false},
+		{"GIT_EXTERNAL_DIFF=./x git diff", false},
+		{"PATH=.; ls", false},
+		{"export PATH=.", false},
+		{"$CMD", false},
+		{"./ls", false},
+		{"echo 'unterminated", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := ReadOnly(tt.input); got != tt.want {
+				t.Errorf("ReadOnly(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
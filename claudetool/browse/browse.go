@@ -564,6 +564,7 @@ func (b *BrowseTools) CombinedTool() *llm.Tool {
 		Name:        "browser",
 		Description: description,
 		InputSchema: json.RawMessage(schema),
+		Sequential:  true, // all actions share one browser page
 		Run:         b.combinedRun(),
 	}
 }
@@ -61,6 +61,7 @@ func (c *ChangeDirTool) Tool() *llm.Tool {
 		Name:        changeDirName,
 		Description: changeDirDescription,
 		InputSchema: llm.MustSchema(changeDirInputSchema),
+		Sequential:  true,
 		Run:         c.Run,
 	}
 }
@@ -0,0 +1,326 @@
+// Package mcp is a client for Model Context Protocol servers, which provide
+// tools, resources, and prompts to agents.
+//
+// It speaks JSON-RPC over the stdio and streamable HTTP transports, and adapts
+// the servers' tools into llm.Tools; see Servers. Handler serves tools the
+// other way, to MCP clients over HTTP.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"shelley.exe.dev/version"
+)
+
+// ProtocolVersion is the MCP revision the client speaks.
+const ProtocolVersion = "2025-06-18"
+
+// ServerConfig says how to reach an MCP server. Exactly one of Command
+// (for a server over stdio) and URL (for one over streamable HTTP) is set.
+type ServerConfig struct {
+	// Name identifies the server. It prefixes the names of its tools.
+	Name string `json:"name"`
+	// Command and Args start a server that speaks over stdin and stdout. It
+	// runs on the host, even for conversations whose commands are sandboxed.
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	// Env adds variables to the environment of a stdio server, which otherwise
+	// gets only basics such as PATH and HOME from Shelley's.
+	Env map[string]string `json:"env,omitempty"`
+	// URL is the endpoint of a streamable HTTP server.
+	URL string `json:"url,omitempty"`
+	// Headers are sent with every request to an HTTP server, e.g. for authorization.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Transport returns "stdio" or "http".
+func (c ServerConfig) Transport() string {
+	if c.URL != "" {
+		return "http"
+	}
+	return "stdio"
+}
+
+// Validate reports whether c is well-formed.
+func (c ServerConfig) Validate() error {
+	if c.Name == "" {
+		return errors.New("MCP server name is required")
+	}
+	if (c.Command == "") == (c.URL == "") {
+		return fmt.Errorf("MCP server %q needs exactly one of command and url", c.Name)
+	}
+	return nil
+}
+
+// Redacted returns c with the values of its environment variables and
+// headers hidden, since they often hold credentials.
+func (c ServerConfig) Redacted() ServerConfig {
+	redact := func(m map[string]string) map[string]string {
+		if m == nil {
+			return nil
+		}
+		out := make(map[string]string, len(m))
+		for k := range m {
+			out[k] = "***"
+		}
+		return out
+	}
+	c.Args = slices.Clone(c.Args)
+	c.Env = redact(c.Env)
+	c.Headers = redact(c.Headers)
+	return c
+}
+
+// Tool is a tool offered by a server.
+type Tool struct {
+	Name        string          `json:"name"`
+	Title       string          `json:"title,omitempty"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+	Annotations struct {
+		ReadOnlyHint bool `json:"readOnlyHint,omitempty"`
+	} `json:"annotations"`
+}
+
+// Content is an item of a tool result or prompt message.
+type Content struct {
+	// Type is "text", "image", "audio", "resource_link", or "resource".
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"` // base64, for images and audio
+	MimeType string `json:"mimeType,omitempty"`
+	// URI and Name describe a resource link.
+	URI  string `json:"uri,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Resource is an embedded resource.
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+// CallToolResult is the result of calling a tool.
+type CallToolResult struct {
+	Content           []Content       `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
+}
+
+// Resource is a piece of context offered by a server, such as a file.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContents is the contents of a resource: Text, or base64 Blob.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// Prompt is a message template offered by a server.
+type Prompt struct {
+	Name        string           `json:"name"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptArgument is an argument of a prompt.
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptMessage is a message of an expanded prompt.
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// GetPromptResult is an expanded prompt.
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// ServerInfo describes a connected server, from its initialize response.
+type ServerInfo struct {
+	Name         string `json:"name"`
+	Version      string `json:"version"`
+	Instructions string `json:"instructions,omitempty"`
+	// Tools, Resources, and Prompts report whether the server offers them.
+	Tools     bool `json:"tools"`
+	Resources bool `json:"resources"`
+	Prompts   bool `json:"prompts"`
+}
+
+// Client is a connection to an MCP server.
+type Client struct {
+	config    ServerConfig
+	transport transport
+	info      ServerInfo
+}
+
+// Connect starts or reaches the server described by cfg and initializes the
+// session. Stdio servers run in dir. ctx bounds the handshake; the connection
+// lasts until Close.
+func Connect(ctx context.Context, cfg ServerConfig, dir string) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	var t transport
+	var err error
+	if cfg.URL != "" {
+		t = newHTTPTransport(cfg)
+	} else if t, err = newStdioTransport(cfg, dir); err != nil {
+		return nil, err
+	}
+	c := &Client{config: cfg, transport: t}
+	if err := c.initialize(ctx); err != nil {
+		t.close()
+		return nil, fmt.Errorf("initializing MCP server %q: %w", cfg.Name, err)
+	}
+	return c, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	params := map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]string{"name": "shelley", "version": version.Version},
+	}
+	var result struct {
+		ProtocolVersion string                     `json:"protocolVersion"`
+		Capabilities    map[string]json.RawMessage `json:"capabilities"`
+		ServerInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+		Instructions string `json:"instructions"`
+	}
+	if err := c.transport.call(ctx, "initialize", params, &result); err != nil {
+		return err
+	}
+	c.info = ServerInfo{
+		Name:         result.ServerInfo.Name,
+		Version:      result.ServerInfo.Version,
+		Instructions: result.Instructions,
+		Tools:        result.Capabilities["tools"] != nil,
+		Resources:    result.Capabilities["resources"] != nil,
+		Prompts:      result.Capabilities["prompts"] != nil,
+	}
+	c.transport.setProtocolVersion(result.ProtocolVersion)
+	return c.transport.notify(ctx, "notifications/initialized", nil)
+}
+
+// Config returns the configuration the client connected with.
+func (c *Client) Config() ServerConfig {
+	return c.config
+}
+
+// Info describes the server.
+func (c *Client) Info() ServerInfo {
+	return c.info
+}
+
+// Err returns why the connection broke, or nil while it works.
+func (c *Client) Err() error {
+	return c.transport.err()
+}
+
+// Close ends the session, stopping a stdio server.
+func (c *Client) Close() error {
+	return c.transport.close()
+}
+
+// Ping checks that the server responds.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.transport.call(ctx, "ping", nil, nil)
+}
+
+// ListTools returns the server's tools.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	return listAll[Tool](ctx, c, "tools/list", "tools")
+}
+
+// CallTool calls the named tool with args, a JSON object.
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, error) {
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	var result CallToolResult
+	params := map[string]any{"name": name, "arguments": args}
+	if err := c.transport.call(ctx, "tools/call", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListResources returns the server's resources.
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	return listAll[Resource](ctx, c, "resources/list", "resources")
+}
+
+// ReadResource returns the contents of the resource at uri.
+func (c *Client) ReadResource(ctx context.Context, uri string) ([]ResourceContents, error) {
+	var result struct {
+		Contents []ResourceContents `json:"contents"`
+	}
+	if err := c.transport.call(ctx, "resources/read", map[string]string{"uri": uri}, &result); err != nil {
+		return nil, err
+	}
+	return result.Contents, nil
+}
+
+// ListPrompts returns the server's prompts.
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	return listAll[Prompt](ctx, c, "prompts/list", "prompts")
+}
+
+// GetPrompt expands the named prompt with args.
+func (c *Client) GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error) {
+	var result GetPromptResult
+	params := map[string]any{"name": name, "arguments": args}
+	if err := c.transport.call(ctx, "prompts/get", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// listAll calls a paginated list method, collecting the items under key from every page.
+func listAll[T any](ctx context.Context, c *Client, method, key string) ([]T, error) {
+	var items []T
+	cursor := ""
+	for {
+		var params any
+		if cursor != "" {
+			params = map[string]string{"cursor": cursor}
+		}
+		var page map[string]json.RawMessage
+		if err := c.transport.call(ctx, method, params, &page); err != nil {
+			return nil, err
+		}
+		var pageItems []T
+		if raw := page[key]; raw != nil {
+			if err := json.Unmarshal(raw, &pageItems); err != nil {
+				return nil, fmt.Errorf("decoding %s: %w", method, err)
+			}
+		}
+		items = append(items, pageItems...)
+		cursor = ""
+		if raw := page["nextCursor"]; raw != nil {
+			json.Unmarshal(raw, &cursor)
+		}
+		if cursor == "" {
+			return items, nil
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// The test binary doubles as a stdio MCP server.
+	if os.Getenv("MCP_FAKE_SERVER") == "1" {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if resp := fakeServer(scanner.Bytes()); resp != nil {
+				os.Stdout.Write(append(resp, '\n'))
+			}
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeServer handles a JSON-RPC message, returning the response, if any.
+func fakeServer(data []byte) []byte {
+	var req struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+			Cursor    string          `json:"cursor"`
+			URI       string          `json:"uri"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil || req.ID == nil {
+		return nil
+	}
+	var result any
+	switch req.Method {
+	case "initialize":
+		result = map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}, "resources": map[string]any{}, "prompts": map[string]any{}},
+			"serverInfo":      map[string]string{"name": "fake", "version": "1.0"},
+		}
+	case "ping":
+		result = map[string]any{}
+	case "tools/list":
+		// Two pages, to exercise pagination.
+		if req.Params.Cursor == "" {
+			result = map[string]any{
+				"tools":      []map[string]any{{"name": "echo", "description": "Echoes text", "inputSchema": map[string]any{"type": "object", "properties": map[string]any{"text": map[string]string{"type": "string"}}}, "annotations": map[string]bool{"readOnlyHint": true}}},
+				"nextCursor": "2",
+			}
+		} else {
+			result = map[string]any{"tools": []map[string]any{{"name": "fail.hard", "inputSchema": map[string]any{"type": "object"}}}}
+		}
+	case "tools/call":
+		if req.Params.Name == "echo" {
+			var args struct{ Text string }
+			json.Unmarshal(req.Params.Arguments, &args)
+			result = map[string]any{"content": []map[string]string{{"type": "text", "text": "echo: " + args.Text}}}
+		} else {
+			result = map[string]any{"content": []map[string]string{{"type": "text", "text": "it broke"}}, "isError": true}
+		}
+	case "resources/list":
+		result = map[string]any{"resources": []map[string]string{{"uri": "file:///notes.txt", "name": "notes"}}}
+	case "resources/read":
+		result = map[string]any{"contents": []map[string]string{{"uri": req.Params.URI, "text": "remember the milk"}}}
+	case "prompts/list":
+		result = map[string]any{"prompts": []map[string]any{{"name": "review", "arguments": []map[string]any{{"name": "file", "required": true}}}}}
+	case "prompts/get":
+		result = map[string]any{"messages": []map[string]any{{"role": "user", "content": map[string]string{"type": "text", "text": "Review " + string(req.Params.Arguments)}}}}
+	default:
+		resp, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": req.ID, "error": map[string]any{"code": methodNotFound, "message": "no"}})
+		return resp
+	}
+	resp, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	return resp
+}
+
+func fakeHTTPServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		resp := fakeServer(body)
+		if resp == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Mcp-Session-Id", "session-1")
+		if strings.Contains(string(body), `"tools/call"`) {
+			// Answer tool calls as a stream, after a notification.
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", resp)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestServers(t *testing.T) {
+	httpSrv := fakeHTTPServer(t)
+	configs := []ServerConfig{
+		{Name: "local", Command: os.Args[0], Env: map[string]string{"MCP_FAKE_SERVER": "1"}},
+		{Name: "remote", URL: httpSrv.URL, Headers: map[string]string{"Authorization": "Bearer secret"}},
+		{Name: "broken", Command: "/nonexistent/mcp-server"},
+	}
+	ctx := context.Background()
+	servers := ConnectAll(ctx, configs, t.TempDir())
+	defer servers.Close()
+
+	tools := servers.Tools()
+	byName := make(map[string]func(string) (string, error))
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+		run := tool.Run
+		byName[tool.Name] = func(input string) (string, error) {
+			out := run(ctx, json.RawMessage(input))
+			if out.Error != nil {
+				return "", out.Error
+			}
+			var texts []string
+			for _, c := range out.LLMContent {
+				texts = append(texts, c.Text)
+			}
+			return strings.Join(texts, ""), nil
+		}
+	}
+	want := []string{"mcp__local__echo", "mcp__local__fail_hard", "mcp__remote__echo", "mcp__remote__fail_hard", "mcp_resource", "mcp_prompt"}
+	if !slices.Equal(names, want) {
+		t.Fatalf("tools = %q, want %q", names, want)
+	}
+
+	for _, name := range []string{"mcp__local__echo", "mcp__remote__echo"} {
+		if out, err := byName[name](`{"text":"hi"}`); err != nil || out != "echo: hi" {
+			t.Errorf("%s = %q, %v", name, out, err)
+		}
+	}
+	if _, err := byName["mcp__remote__fail_hard"](`{}`); err == nil || err.Error() != "it broke" {
+		t.Errorf("expected the tool's error, got %v", err)
+	}
+	if out, err := byName["mcp_resource"](`{"server":"local"}`); err != nil || !strings.Contains(out, "file:///notes.txt") {
+		t.Errorf("listing resources = %q, %v", out, err)
+	}
+	if out, err := byName["mcp_resource"](`{"server":"remote","uri":"file:///notes.txt"}`); err != nil || out != "remember the milk" {
+		t.Errorf("reading a resource = %q, %v", out, err)
+	}
+	if _, err := byName["mcp_resource"](`{"server":"broken"}`); err == nil {
+		t.Error("expected an error for a server that didn't connect")
+	}
+	if out, err := byName["mcp_prompt"](`{"server":"local"}`); err != nil || !strings.Contains(out, "file (required)") {
+		t.Errorf("listing prompts = %q, %v", out, err)
+	}
+	if out, err := byName["mcp_prompt"](`{"server":"local","name":"review","arguments":{"file":"a.go"}}`); err != nil || !strings.Contains(out, "a.go") {
+		t.Errorf("getting a prompt = %q, %v", out, err)
+	}
+
+	statuses := servers.Status(ctx)
+	if len(statuses) != 3 {
+		t.Fatalf("expected 3 statuses, got %+v", statuses)
+	}
+	for _, st := range statuses[:2] {
+		if !st.Connected || !st.Healthy || st.Server == nil || st.Server.Name != "fake" || len(st.Tools) != 2 {
+			t.Errorf("expected %s to be healthy, got %+v", st.Name, st)
+		}
+	}
+	if st := statuses[2]; st.Connected || st.Healthy || st.Error == "" {
+		t.Errorf("expected broken to have failed, got %+v", st)
+	}
+
+	// A stdio server that exits is reported unhealthy, with why.
+	local := servers.servers[0].client
+	local.Close()
+	if st := servers.Status(ctx)[0]; st.Healthy || !strings.Contains(st.Error, "server exited") {
+		t.Errorf("expected the closed server to be unhealthy, got %+v", st)
+	}
+}
+
+func TestPool(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	configs := []ServerConfig{{Name: "local", Command: os.Args[0], Env: map[string]string{"MCP_FAKE_SERVER": "1"}}}
+	pool := &Pool{Idle: time.Hour}
+	defer pool.Close()
+
+	a := pool.Acquire(ctx, configs, dir)
+	b := pool.Acquire(ctx, configs, dir)
+	client := a.servers[0].client
+	if client == nil || b.servers[0].client != client {
+		t.Fatal("expected conversations to share a connection")
+	}
+	if other := pool.Acquire(ctx, configs, t.TempDir()); other.servers[0].client == client {
+		t.Error("expected a stdio server for each directory")
+	} else {
+		other.Close()
+	}
+
+	// A connection outlives its users until it has been idle for a while,
+	// so that a loop restarting doesn't restart the server.
+	a.Close()
+	b.Close()
+	c := pool.Acquire(ctx, configs, dir)
+	if c.servers[0].client != client || client.Err() != nil {
+		t.Error("expected the idle connection to be reused")
+	}
+
+	// A broken connection is replaced.
+	client.Close()
+	d := pool.Acquire(ctx, configs, dir)
+	if d.servers[0].client == client || d.servers[0].client == nil {
+		t.Error("expected a new connection in place of the broken one")
+	}
+	c.Close()
+	d.Close()
+
+	// Without Idle, a connection closes with its last user.
+	e := new(Pool).Acquire(ctx, configs, dir)
+	e.Close()
+	if e.servers[0].client.Err() == nil {
+		t.Error("expected the unused connection to be closed")
+	}
+}
+
+func TestConnectUnauthorized(t *testing.T) {
+	httpSrv := fakeHTTPServer(t)
+	_, err := Connect(context.Background(), ServerConfig{Name: "remote", URL: httpSrv.URL}, "")
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected a 401 error, got %v", err)
+	}
+}
+
+func TestStdioEnv(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-secret")
+	env := stdioEnv(ServerConfig{Name: "local", Command: "x", Env: map[string]string{"TOKEN": "t"}})
+	if !slices.Contains(env, "PATH=/usr/bin") || !slices.Contains(env, "TOKEN=t") {
+		t.Errorf("expected PATH and the configured variables, got %q", env)
+	}
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "ANTHROPIC_API_KEY=") {
+			t.Errorf("expected Shelley's secrets to be left out, got %q", env)
+		}
+	}
+}
+
+func TestToolName(t *testing.T) {
+	if got := toolName("my server", "do.it"); got != "mcp__my_server__do_it" {
+		t.Errorf("toolName = %q", got)
+	}
+	if got := toolName(strings.Repeat("s", 50), strings.Repeat("t", 50)); len(got) != 64 {
+		t.Errorf("expected names to be truncated to 64 characters, got %d", len(got))
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	for _, c := range []ServerConfig{{Command: "x"}, {Name: "a"}, {Name: "a", Command: "x", URL: "http://x"}} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", c)
+		}
+	}
+	c := ServerConfig{Name: "a", URL: "http://x", Headers: map[string]string{"Authorization": "Bearer secret"}}
+	if r := c.Redacted(); r.Headers["Authorization"] != "***" || c.Headers["Authorization"] != "Bearer secret" {
+		t.Errorf("Redacted = %+v, original %+v", r, c)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	h := &Handler{Name: "test", Version: "1.0", Tools: []ServerTool{{
+		Tool: Tool{Name: "shout", InputSchema: json.RawMessage(`{"type":"object"}`)},
+		Handle: func(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+			var in struct{ Text string }
+			if err := json.Unmarshal(args, &in); err != nil {
+				return nil, err
+			}
+			if in.Text == "" {
+				return nil, fmt.Errorf("text is required")
+			}
+			return TextResult(strings.ToUpper(in.Text)), nil
+		},
+	}}}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c, err := Connect(ctx, ServerConfig{Name: "test", URL: srv.URL}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if info := c.Info(); info.Name != "test" || !info.Tools || info.Prompts {
+		t.Errorf("unexpected server info %+v", info)
+	}
+	if err := c.Ping(ctx); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+	tools, err := c.ListTools(ctx)
+	if err != nil || len(tools) != 1 || tools[0].Name != "shout" {
+		t.Fatalf("ListTools = %+v, %v", tools, err)
+	}
+	if res, err := c.CallTool(ctx, "shout", json.RawMessage(`{"text":"hi"}`)); err != nil || res.IsError || res.Content[0].Text != "HI" {
+		t.Errorf("CallTool = %+v, %v", res, err)
+	}
+	if res, err := c.CallTool(ctx, "shout", nil); err != nil || !res.IsError || res.Content[0].Text != "text is required" {
+		t.Errorf("expected a failed tool call, got %+v, %v", res, err)
+	}
+	var rpcErr *RPCError
+	if _, err := c.CallTool(ctx, "whisper", nil); !errors.As(err, &rpcErr) || rpcErr.Code != invalidParams {
+		t.Errorf("expected an invalid params error, got %v", err)
+	}
+	if _, err := c.ListPrompts(ctx); !errors.As(err, &rpcErr) || rpcErr.Code != methodNotFound {
+		t.Errorf("expected a method not found error, got %v", err)
+	}
+}
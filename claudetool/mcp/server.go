@@ -0,0 +1,124 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+)
+
+// ServerTool is a tool offered by a Handler.
+type ServerTool struct {
+	Tool
+	// Handle runs the tool with args, a JSON object. An error is reported to
+	// the client as a failed tool call rather than a protocol error.
+	Handle func(ctx context.Context, args json.RawMessage) (*CallToolResult, error)
+}
+
+// TextResult returns a tool result holding text.
+func TextResult(text string) *CallToolResult {
+	return &CallToolResult{Content: []Content{{Type: "text", Text: text}}}
+}
+
+// Handler serves tools to MCP clients over streamable HTTP.
+//
+// It is stateless: it issues no session IDs, offers no stream for messages of
+// its own, and answers each request with a single JSON response.
+type Handler struct {
+	Name         string
+	Version      string
+	Instructions string
+	Tools        []ServerTool
+}
+
+// maxRequestSize bounds the body of a request to a Handler.
+const maxRequestSize = 4 << 20
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		writeResponse(w, http.StatusBadRequest, response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &RPCError{Code: parseError, Message: err.Error()}})
+		return
+	}
+	if msg.ID == nil || msg.Method == "" {
+		// Notifications and responses need no answer.
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	writeResponse(w, http.StatusOK, h.handle(r.Context(), &msg))
+}
+
+func writeResponse(w http.ResponseWriter, code int, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handle answers a request.
+func (h *Handler) handle(ctx context.Context, m *message) response {
+	result, err := h.dispatch(ctx, m)
+	if err != nil {
+		rpcErr, ok := err.(*RPCError)
+		if !ok {
+			rpcErr = &RPCError{Code: invalidParams, Message: err.Error()}
+		}
+		return response{JSONRPC: "2.0", ID: m.ID, Error: rpcErr}
+	}
+	return response{JSONRPC: "2.0", ID: m.ID, Result: result}
+}
+
+func (h *Handler) dispatch(ctx context.Context, m *message) (any, error) {
+	switch m.Method {
+	case "initialize":
+		// The handler speaks one revision; clients that can't speak it disconnect.
+		return map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": h.Name, "version": h.Version},
+			"instructions":    h.Instructions,
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		tools := make([]Tool, len(h.Tools))
+		for i, t := range h.Tools {
+			tools[i] = t.Tool
+		}
+		return map[string]any{"tools": tools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(m.Params, &params); err != nil {
+			return nil, err
+		}
+		i := slices.IndexFunc(h.Tools, func(t ServerTool) bool { return t.Name == params.Name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown tool %q", params.Name)
+		}
+		if len(params.Arguments) == 0 {
+			params.Arguments = json.RawMessage("{}")
+		}
+		result, err := h.Tools[i].Handle(ctx, params.Arguments)
+		if err != nil {
+			result = TextResult(err.Error())
+			result.IsError = true
+		}
+		return result, nil
+	default:
+		return nil, &RPCError{Code: methodNotFound, Message: "method not found: " + m.Method}
+	}
+}
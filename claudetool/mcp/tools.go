@@ -0,0 +1,539 @@
+package mcp
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/llm"
+)
+
+// ConnectTimeout bounds connecting to and listing the tools of each server.
+const ConnectTimeout = 30 * time.Second
+
+// retryFailedAfter is how long a pool remembers that connecting to a server
+// failed, so that conversations starting meanwhile don't each wait on it.
+const retryFailedAfter = time.Minute
+
+// Servers is a set of connections to MCP servers, made for one conversation.
+type Servers struct {
+	servers   []*server
+	pool      *Pool
+	conns     []*conn
+	closeOnce sync.Once
+}
+
+type server struct {
+	config ServerConfig
+	client *Client // nil if connecting failed
+	err    error   // why connecting failed
+	tools  []Tool
+}
+
+// ConnectAll connects to the servers described by configs concurrently.
+// Stdio servers run in dir. Servers that fail to connect are left out of
+// Tools and reported by Status. Close disconnects them.
+func ConnectAll(ctx context.Context, configs []ServerConfig, dir string) *Servers {
+	return new(Pool).Acquire(ctx, configs, dir)
+}
+
+// Pool shares connections to MCP servers between conversations, so that a
+// server starts once for everyone using the same configuration rather than
+// once for each conversation and each restart of its loop. Stdio servers
+// are shared only between conversations in the same directory.
+type Pool struct {
+	// Idle is how long a connection stays open once no Servers uses it.
+	Idle time.Duration
+
+	mu    sync.Mutex
+	conns map[string]*conn
+}
+
+// conn is a connection of a pool.
+type conn struct {
+	key   string
+	srv   *server
+	ready chan struct{} // closed once connecting finishes
+	done  time.Time     // when connecting finished; set before ready closes
+	refs  int
+	idle  *time.Timer // closes the connection once it has been unused for Pool.Idle
+}
+
+// stale reports whether c should be replaced by a new connection: it broke,
+// or connecting failed a while ago. The caller holds the pool's lock.
+func (c *conn) stale() bool {
+	select {
+	case <-c.ready:
+	default:
+		return false
+	}
+	if c.srv.client == nil {
+		return time.Since(c.done) >= retryFailedAfter
+	}
+	return c.srv.client.Err() != nil
+}
+
+func poolKey(cfg ServerConfig, dir string) string {
+	key, _ := json.Marshal(cfg)
+	if cfg.Transport() == "stdio" {
+		return string(key) + "\x00" + dir
+	}
+	return string(key)
+}
+
+// Acquire returns connections to the servers described by configs, making
+// those the pool lacks concurrently. Stdio servers run in dir. Servers that
+// fail to connect are left out of Tools and reported by Status. Close
+// releases the connections.
+func (p *Pool) Acquire(ctx context.Context, configs []ServerConfig, dir string) *Servers {
+	s := &Servers{pool: p}
+	p.mu.Lock()
+	if p.conns == nil {
+		p.conns = make(map[string]*conn)
+	}
+	for _, cfg := range configs {
+		key := poolKey(cfg, dir)
+		c := p.conns[key]
+		if c == nil || c.stale() {
+			c = &conn{key: key, srv: &server{config: cfg}, ready: make(chan struct{})}
+			p.conns[key] = c
+			go c.connect(dir)
+		}
+		if c.idle != nil {
+			c.idle.Stop()
+			c.idle = nil
+		}
+		c.refs++
+		s.conns = append(s.conns, c)
+	}
+	p.mu.Unlock()
+	for _, c := range s.conns {
+		<-c.ready
+		s.servers = append(s.servers, c.srv)
+	}
+	return s
+}
+
+// connect connects to the server. It doesn't use the context of whoever
+// asked first, since the connection outlives them.
+func (c *conn) connect(dir string) {
+	defer close(c.ready)
+	srv := c.srv
+	ctx, cancel := context.WithTimeout(context.Background(), ConnectTimeout)
+	defer cancel()
+	client, err := Connect(ctx, srv.config, dir)
+	if err == nil && client.Info().Tools {
+		srv.tools, err = client.ListTools(ctx)
+		if err != nil {
+			client.Close()
+			err = fmt.Errorf("listing tools of MCP server %q: %w", srv.config.Name, err)
+		}
+	}
+	c.done = time.Now()
+	if err != nil {
+		slog.WarnContext(ctx, "failed to connect to MCP server", "name", srv.config.Name, "error", err)
+		srv.err = err
+		return
+	}
+	srv.client = client
+}
+
+// release drops a use of c. Once nothing uses it, it is closed after p.Idle.
+func (p *Pool) release(c *conn) {
+	p.mu.Lock()
+	c.refs--
+	if c.refs > 0 {
+		p.mu.Unlock()
+		return
+	}
+	if p.conns[c.key] != c || p.Idle <= 0 {
+		p.remove(c)
+		p.mu.Unlock()
+		c.close()
+		return
+	}
+	var idle *time.Timer
+	idle = time.AfterFunc(p.Idle, func() {
+		p.mu.Lock()
+		expired := c.idle == idle
+		if expired {
+			p.remove(c)
+		}
+		p.mu.Unlock()
+		if expired {
+			c.close()
+		}
+	})
+	c.idle = idle
+	p.mu.Unlock()
+}
+
+// remove removes c from the pool. The caller holds p.mu.
+func (p *Pool) remove(c *conn) {
+	if p.conns[c.key] == c {
+		delete(p.conns, c.key)
+	}
+	c.idle = nil
+}
+
+// close closes the connection once connecting finishes.
+func (c *conn) close() {
+	<-c.ready
+	if c.srv.client != nil {
+		c.srv.client.Close()
+	}
+}
+
+// Close closes every connection of the pool, in use or not.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = nil
+	for _, c := range conns {
+		if c.idle != nil {
+			c.idle.Stop()
+			c.idle = nil
+		}
+	}
+	p.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		wg.Go(c.close)
+	}
+	wg.Wait()
+}
+
+// Close releases the connections. Those no other Servers of the pool uses
+// are closed.
+func (s *Servers) Close() {
+	s.closeOnce.Do(func() {
+		for _, c := range s.conns {
+			s.pool.release(c)
+		}
+	})
+}
+
+// Status describes a server of a conversation.
+type Status struct {
+	Name      string `json:"name"`
+	Transport string `json:"transport"`
+	// Connected reports whether connecting succeeded.
+	Connected bool `json:"connected"`
+	// Healthy reports whether the server still responds.
+	Healthy bool        `json:"healthy"`
+	Error   string      `json:"error,omitempty"`
+	Server  *ServerInfo `json:"server,omitempty"`
+	// Tools are the names the model calls the server's tools by.
+	Tools []string `json:"tools"`
+}
+
+// Status reports on each server, pinging the connected ones.
+func (s *Servers) Status(ctx context.Context) []Status {
+	statuses := make([]Status, len(s.servers))
+	var wg sync.WaitGroup
+	for i, srv := range s.servers {
+		st := &statuses[i]
+		*st = Status{Name: srv.config.Name, Transport: srv.config.Transport(), Tools: []string{}}
+		if srv.client == nil {
+			st.Error = srv.err.Error()
+			continue
+		}
+		st.Connected = true
+		info := srv.client.Info()
+		st.Server = &info
+		for _, t := range srv.tools {
+			st.Tools = append(st.Tools, toolName(srv.config.Name, t.Name))
+		}
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			err := srv.client.Err()
+			if err == nil {
+				err = srv.client.Ping(ctx)
+			}
+			if err != nil {
+				st.Error = err.Error()
+				return
+			}
+			st.Healthy = true
+		})
+	}
+	wg.Wait()
+	return statuses
+}
+
+// Tools returns the servers' tools, adapted to run through the connections,
+// and tools for reading their resources and prompts.
+func (s *Servers) Tools() []*llm.Tool {
+	var tools []*llm.Tool
+	seen := make(map[string]bool)
+	var withResources, withPrompts []*server
+	for _, srv := range s.servers {
+		if srv.client == nil {
+			continue
+		}
+		for _, t := range srv.tools {
+			tool := srv.llmTool(t)
+			if seen[tool.Name] {
+				slog.Warn("skipping MCP tool with duplicate name", "server", srv.config.Name, "tool", t.Name, "name", tool.Name)
+				continue
+			}
+			seen[tool.Name] = true
+			tools = append(tools, tool)
+		}
+		if srv.client.Info().Resources {
+			withResources = append(withResources, srv)
+		}
+		if srv.client.Info().Prompts {
+			withPrompts = append(withPrompts, srv)
+		}
+	}
+	if len(withResources) > 0 {
+		tools = append(tools, resourceTool(withResources))
+	}
+	if len(withPrompts) > 0 {
+		tools = append(tools, promptTool(withPrompts))
+	}
+	return tools
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// toolName returns the name the model calls a server's tool by. Tool names
+// are limited to 64 letters, digits, underscores, and hyphens.
+func toolName(serverName, tool string) string {
+	name := "mcp__" + invalidNameChars.ReplaceAllString(serverName, "_") + "__" + invalidNameChars.ReplaceAllString(tool, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+func (srv *server) llmTool(t Tool) *llm.Tool {
+	schema := t.InputSchema
+	var probe struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(schema, &probe) != nil || probe.Type != "object" {
+		schema = json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	desc := cmp.Or(t.Description, t.Title, t.Name)
+	client := srv.client
+	return &llm.Tool{
+		Name:        toolName(srv.config.Name, t.Name),
+		Description: fmt.Sprintf("%s\n\n(Tool %q of MCP server %q.)", desc, t.Name, srv.config.Name),
+		InputSchema: schema,
+		// Tools that don't declare themselves read-only may change shared state.
+		Sequential: !t.Annotations.ReadOnlyHint,
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			result, err := client.CallTool(ctx, t.Name, input)
+			if err != nil {
+				return llm.ErrorfToolOut("MCP server %q: %w", srv.config.Name, err)
+			}
+			contents := toLLMContents(result.Content)
+			if result.IsError {
+				var texts []string
+				for _, c := range contents {
+					texts = append(texts, c.Text)
+				}
+				return llm.ErrorToolOut(errors.New(cmp.Or(strings.Join(texts, "\n"), "tool failed")))
+			}
+			if len(contents) == 0 {
+				text := "(no output)"
+				if len(result.StructuredContent) > 0 {
+					text = string(result.StructuredContent)
+				}
+				contents = llm.TextContent(text)
+			}
+			return llm.ToolOut{LLMContent: contents}
+		},
+	}
+}
+
+// toLLMContents converts tool result content for the model. Images are kept;
+// other binary content is described rather than sent.
+func toLLMContents(contents []Content) []llm.Content {
+	var out []llm.Content
+	for _, c := range contents {
+		switch c.Type {
+		case "text":
+			out = append(out, llm.StringContent(c.Text))
+		case "image":
+			out = append(out, llm.Content{Type: llm.ContentTypeText, MediaType: c.MimeType, Data: c.Data})
+		case "resource_link":
+			out = append(out, llm.StringContent(fmt.Sprintf("Resource %s: %s", cmp.Or(c.Name, c.URI), c.URI)))
+		case "resource":
+			if c.Resource != nil {
+				out = append(out, resourceContent(*c.Resource))
+			}
+		default:
+			out = append(out, llm.StringContent(fmt.Sprintf("(%s content of type %s omitted)", c.Type, c.MimeType)))
+		}
+	}
+	return out
+}
+
+func resourceContent(rc ResourceContents) llm.Content {
+	switch {
+	case rc.Blob == "":
+		return llm.StringContent(rc.Text)
+	case strings.HasPrefix(rc.MimeType, "image/"):
+		return llm.Content{Type: llm.ContentTypeText, MediaType: rc.MimeType, Data: rc.Blob}
+	default:
+		return llm.StringContent(fmt.Sprintf("(binary resource %s of type %s omitted)", rc.URI, rc.MimeType))
+	}
+}
+
+func serverNames(servers []*server) []string {
+	var names []string
+	for _, srv := range servers {
+		names = append(names, srv.config.Name)
+	}
+	return names
+}
+
+func findServer(servers []*server, name string) (*server, error) {
+	i := slices.IndexFunc(servers, func(srv *server) bool { return srv.config.Name == name })
+	if i < 0 {
+		return nil, fmt.Errorf("unknown server %q; choose one of %s", name, strings.Join(serverNames(servers), ", "))
+	}
+	return servers[i], nil
+}
+
+// resourceTool returns a tool that lists and reads the resources of servers.
+func resourceTool(servers []*server) *llm.Tool {
+	return &llm.Tool{
+		Name: "mcp_resource",
+		Description: fmt.Sprintf(`Lists or reads resources, such as files or records, offered by MCP servers.
+Servers with resources: %s.
+Without a uri, lists the server's resources; with one, reads that resource.`, strings.Join(serverNames(servers), ", ")),
+		InputSchema: llm.MustSchema(`{
+  "type": "object",
+  "required": ["server"],
+  "properties": {
+    "server": {"type": "string", "description": "Name of the MCP server"},
+    "uri": {"type": "string", "description": "URI of the resource to read; omit to list resources"}
+  }
+}`),
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			var req struct {
+				Server string `json:"server"`
+				URI    string `json:"uri"`
+			}
+			if err := json.Unmarshal(input, &req); err != nil {
+				return llm.ErrorfToolOut("invalid input: %w", err)
+			}
+			srv, err := findServer(servers, req.Server)
+			if err != nil {
+				return llm.ErrorToolOut(err)
+			}
+			if req.URI == "" {
+				resources, err := srv.client.ListResources(ctx)
+				if err != nil {
+					return llm.ErrorToolOut(err)
+				}
+				if len(resources) == 0 {
+					return llm.ToolOut{LLMContent: llm.TextContent("No resources.")}
+				}
+				var b strings.Builder
+				for _, r := range resources {
+					fmt.Fprintf(&b, "%s\t%s", r.URI, cmp.Or(r.Title, r.Name))
+					if r.Description != "" {
+						fmt.Fprintf(&b, "\t%s", r.Description)
+					}
+					b.WriteByte('\n')
+				}
+				return llm.ToolOut{LLMContent: llm.TextContent(b.String())}
+			}
+			contents, err := srv.client.ReadResource(ctx, req.URI)
+			if err != nil {
+				return llm.ErrorToolOut(err)
+			}
+			var out []llm.Content
+			for _, rc := range contents {
+				out = append(out, resourceContent(rc))
+			}
+			if len(out) == 0 {
+				out = llm.TextContent("(empty resource)")
+			}
+			return llm.ToolOut{LLMContent: out}
+		},
+	}
+}
+
+// promptTool returns a tool that lists and expands the prompts of servers.
+func promptTool(servers []*server) *llm.Tool {
+	return &llm.Tool{
+		Name: "mcp_prompt",
+		Description: fmt.Sprintf(`Lists or expands prompt templates offered by MCP servers.
+Servers with prompts: %s.
+Without a name, lists the server's prompts and their arguments; with one, returns that prompt's messages.`, strings.Join(serverNames(servers), ", ")),
+		InputSchema: llm.MustSchema(`{
+  "type": "object",
+  "required": ["server"],
+  "properties": {
+    "server": {"type": "string", "description": "Name of the MCP server"},
+    "name": {"type": "string", "description": "Name of the prompt to expand; omit to list prompts"},
+    "arguments": {"type": "object", "additionalProperties": {"type": "string"}, "description": "Arguments of the prompt"}
+  }
+}`),
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			var req struct {
+				Server    string            `json:"server"`
+				Name      string            `json:"name"`
+				Arguments map[string]string `json:"arguments"`
+			}
+			if err := json.Unmarshal(input, &req); err != nil {
+				return llm.ErrorfToolOut("invalid input: %w", err)
+			}
+			srv, err := findServer(servers, req.Server)
+			if err != nil {
+				return llm.ErrorToolOut(err)
+			}
+			var b strings.Builder
+			if req.Name == "" {
+				prompts, err := srv.client.ListPrompts(ctx)
+				if err != nil {
+					return llm.ErrorToolOut(err)
+				}
+				for _, p := range prompts {
+					fmt.Fprintf(&b, "%s", p.Name)
+					if d := cmp.Or(p.Description, p.Title); d != "" {
+						fmt.Fprintf(&b, ": %s", d)
+					}
+					b.WriteByte('\n')
+					for _, a := range p.Arguments {
+						required := ""
+						if a.Required {
+							required = " (required)"
+						}
+						fmt.Fprintf(&b, "  %s%s: %s\n", a.Name, required, a.Description)
+					}
+				}
+				return llm.ToolOut{LLMContent: llm.TextContent(cmp.Or(b.String(), "No prompts."))}
+			}
+			result, err := srv.client.GetPrompt(ctx, req.Name, req.Arguments)
+			if err != nil {
+				return llm.ErrorToolOut(err)
+			}
+			for _, m := range result.Messages {
+				text := m.Content.Text
+				if m.Content.Type == "resource" && m.Content.Resource != nil {
+					text = m.Content.Resource.Text
+				} else if m.Content.Type != "text" {
+					text = fmt.Sprintf("(%s content omitted)", m.Content.Type)
+				}
+				fmt.Fprintf(&b, "[%s]\n%s\n\n", m.Role, text)
+			}
+			return llm.ToolOut{LLMContent: llm.TextContent(b.String())}
+		},
+	}
+}
@@ -0,0 +1,507 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// transport carries JSON-RPC messages to and from a server.
+type transport interface {
+	// call sends a request and decodes the response's result into result, if non-nil.
+	call(ctx context.Context, method string, params, result any) error
+	// notify sends a notification, which has no response.
+	notify(ctx context.Context, method string, params any) error
+	// setProtocolVersion records the version negotiated during initialization.
+	setProtocolVersion(v string)
+	// err returns why the transport broke, or nil while it works.
+	err() error
+	close() error
+}
+
+type request struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      *int64 `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// message is a message from the other side: a response, or a request or
+// notification of its own.
+type message struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError is an error response from a server.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("MCP error %d: %s", e.Code, e.Message)
+}
+
+// JSON-RPC error codes.
+const (
+	parseError     = -32700
+	methodNotFound = -32601
+	invalidParams  = -32602
+)
+
+func (m *message) decode(result any) error {
+	if m.Error != nil {
+		return m.Error
+	}
+	if result == nil || len(m.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(m.Result, result)
+}
+
+// response is a response to a request from the other side.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// reply returns the client's response to a request from the server. The
+// client answers pings and declines everything else, since it offers no
+// capabilities such as sampling or roots.
+func reply(m *message) response {
+	if m.Method == "ping" {
+		return response{JSONRPC: "2.0", ID: m.ID, Result: struct{}{}}
+	}
+	return response{JSONRPC: "2.0", ID: m.ID, Error: &RPCError{Code: methodNotFound, Message: "method not found: " + m.Method}}
+}
+
+// stdioTransport talks to a server process over its stdin and stdout, one
+// JSON message per line.
+type stdioTransport struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *tailBuffer
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan *message
+
+	done      chan struct{} // closed when the server's stdout closes
+	readErr   error         // why reading stopped; set before done closes
+	closeOnce sync.Once
+}
+
+func newStdioTransport(cfg ServerConfig, dir string) (*stdioTransport, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = dir
+	cmd.Env = stdioEnv(cfg)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} // set up for stopping the process group
+	cmd.WaitDelay = 2 * time.Second
+	t := &stdioTransport{
+		cmd:     cmd,
+		stderr:  &tailBuffer{limit: 4096},
+		pending: make(map[int64]chan *message),
+		done:    make(chan struct{}),
+	}
+	cmd.Stderr = t.stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting MCP server %q: %w", cfg.Name, err)
+	}
+	t.stdin = stdin
+	go t.read(stdout)
+	return t, nil
+}
+
+// inheritedEnv are the variables of Shelley's environment a stdio server
+// gets. The rest, such as API keys, stay out of reach of third-party code.
+var inheritedEnv = []string{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "LANG", "LC_ALL", "LC_CTYPE", "TERM", "TMPDIR", "TZ"}
+
+// stdioEnv returns the environment of a stdio server: the variables of
+// inheritedEnv that are set, and then those of cfg.Env.
+func stdioEnv(cfg ServerConfig) []string {
+	var env []string
+	for _, k := range inheritedEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(cfg.Env)) {
+		env = append(env, k+"="+cfg.Env[k])
+	}
+	return env
+}
+
+func (t *stdioTransport) read(stdout io.Reader) {
+	r := bufio.NewReader(stdout)
+	var readErr error
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			t.handle(line)
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+	waitErr := t.cmd.Wait()
+	msg := "server exited"
+	if waitErr != nil {
+		msg += ": " + waitErr.Error()
+	} else if !errors.Is(readErr, io.EOF) {
+		msg += ": " + readErr.Error()
+	}
+	if stderr := strings.TrimSpace(t.stderr.String()); stderr != "" {
+		msg += "\n" + stderr
+	}
+	t.readErr = errors.New(msg)
+	close(t.done)
+}
+
+func (t *stdioTransport) handle(line []byte) {
+	var m message
+	if err := json.Unmarshal(line, &m); err != nil {
+		return // servers may log to stdout; skip what isn't JSON-RPC
+	}
+	if m.Method != "" {
+		if len(m.ID) > 0 {
+			t.write(reply(&m))
+		}
+		return // notifications need no action
+	}
+	var id int64
+	if err := json.Unmarshal(m.ID, &id); err != nil {
+		return
+	}
+	t.mu.Lock()
+	ch := t.pending[id]
+	delete(t.pending, id)
+	t.mu.Unlock()
+	if ch != nil {
+		ch <- &m
+	}
+}
+
+func (t *stdioTransport) write(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) call(ctx context.Context, method string, params, result any) error {
+	t.mu.Lock()
+	t.nextID++
+	id := t.nextID
+	ch := make(chan *message, 1)
+	t.pending[id] = ch
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+	}()
+
+	if err := t.write(request{JSONRPC: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		// Writing fails once the server exits; report why it did.
+		select {
+		case <-t.done:
+			return t.readErr
+		case <-ctx.Done():
+			return err
+		}
+	}
+	select {
+	case m := <-ch:
+		return m.decode(result)
+	case <-t.done:
+		select {
+		case m := <-ch:
+			return m.decode(result)
+		default:
+			return t.readErr
+		}
+	case <-ctx.Done():
+		t.write(request{JSONRPC: "2.0", Method: "notifications/cancelled", Params: map[string]any{"requestId": id}})
+		return ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(ctx context.Context, method string, params any) error {
+	return t.write(request{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (t *stdioTransport) setProtocolVersion(string) {}
+
+func (t *stdioTransport) err() error {
+	select {
+	case <-t.done:
+		return t.readErr
+	default:
+		return nil
+	}
+}
+
+// close closes the server's stdin, which asks it to exit, and stops its
+// process group if it doesn't.
+func (t *stdioTransport) close() error {
+	t.closeOnce.Do(func() {
+		t.stdin.Close()
+		pid := t.cmd.Process.Pid
+		for _, sig := range []syscall.Signal{0, syscall.SIGTERM, syscall.SIGKILL} {
+			if sig != 0 {
+				syscall.Kill(-pid, sig)
+			}
+			select {
+			case <-t.done:
+				return
+			case <-time.After(2 * time.Second):
+			}
+		}
+	})
+	return nil
+}
+
+// tailBuffer keeps the last limit bytes written to it.
+type tailBuffer struct {
+	mu    sync.Mutex
+	buf   []byte
+	limit int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.limit; over > 0 {
+		b.buf = b.buf[over:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+// httpTransport talks to a server over streamable HTTP: each message is
+// POSTed, and responses come back as JSON or as a stream of server-sent events.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu              sync.Mutex
+	nextID          int64
+	sessionID       string
+	protocolVersion string
+}
+
+func newHTTPTransport(cfg ServerConfig) *httpTransport {
+	return &httpTransport{url: cfg.URL, headers: cfg.Headers, client: &http.Client{}}
+}
+
+func (t *httpTransport) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	if t.protocolVersion != "" {
+		req.Header.Set("MCP-Protocol-Version", t.protocolVersion)
+	}
+	t.mu.Unlock()
+	return req, nil
+}
+
+// post sends msg and returns the response, which the caller closes.
+func (t *httpTransport) post(ctx context.Context, msg any) (*http.Response, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := t.newRequest(ctx, http.MethodPost, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("MCP server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) call(ctx context.Context, method string, params, result any) error {
+	t.mu.Lock()
+	t.nextID++
+	id := t.nextID
+	t.mu.Unlock()
+	resp, err := t.post(ctx, request{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		var m message
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			return fmt.Errorf("decoding MCP response: %w", err)
+		}
+		return m.decode(result)
+	}
+
+	// Read events until the response to this request; the server may send
+	// its own requests and notifications first.
+	want := fmt.Sprint(id)
+	var m *message
+	err = readEvents(resp.Body, func(data []byte) bool {
+		var ev message
+		if json.Unmarshal(data, &ev) != nil {
+			return true
+		}
+		if ev.Method != "" {
+			if len(ev.ID) > 0 {
+				if resp, err := t.post(ctx, reply(&ev)); err == nil {
+					resp.Body.Close()
+				}
+			}
+			return true
+		}
+		if string(ev.ID) == want {
+			m = &ev
+			return false
+		}
+		return true
+	})
+	if m == nil {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("reading MCP response: %w", err)
+	}
+	return m.decode(result)
+}
+
+// readEvents calls fn with the data of each server-sent event in r until fn
+// returns false or r ends.
+func readEvents(r io.Reader, fn func(data []byte) bool) error {
+	br := bufio.NewReader(r)
+	var data []byte
+	for {
+		line, err := br.ReadBytes('\n')
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) == 0 && len(data) > 0 {
+			if !fn(data) {
+				return nil
+			}
+			data = nil
+		} else if rest, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, bytes.TrimPrefix(rest, []byte(" "))...)
+		}
+		if err != nil {
+			if len(data) > 0 {
+				fn(data)
+			}
+			return err
+		}
+	}
+}
+
+func (t *httpTransport) notify(ctx context.Context, method string, params any) error {
+	resp, err := t.post(ctx, request{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+func (t *httpTransport) setProtocolVersion(v string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.protocolVersion = v
+}
+
+// err is always nil: each request reaches the server afresh, so a failure
+// shows up as that request's error.
+func (t *httpTransport) err() error {
+	return nil
+}
+
+// close ends the session, if the server gave one.
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := t.newRequest(ctx, http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -14,6 +14,7 @@ import (
 	"strings"
 
 	"github.com/pkg/diff"
+	"shelley.exe.dev/claudetool/sandbox"
 	"shelley.exe.dev/llm"
 	"sketch.dev/claudetool/editbuf"
 	"sketch.dev/claudetool/patchkit"
@@ -39,6 +40,12 @@ type PatchTool struct {
 	// NB: The actual implementation of the patch tool is unchanged,
 	// this flag merely extends the description and input schema to include the clipboard operations.
 	ClipboardEnabled bool
+	// BeforeWrite, if set, is called with a file's current contents just
+	// before the tool overwrites or creates it. existed is false for new files.
+	BeforeWrite func(path string, orig []byte, existed bool)
+	// Sandbox, if set, restricts which files the tool may write to those
+	// commands run in the sandbox may write.
+	Sandbox sandbox.Executor
 	// clipboards stores clipboard name -> text
 	clipboards map[string]string
 }
@@ -63,6 +70,7 @@ func (p *PatchTool) Tool() *llm.Tool {
 		Name:        PatchName,
 		Description: strings.TrimSpace(description),
 		InputSchema: llm.MustSchema(schema),
+		Sequential:  true,
 		Run:         p.Run,
 	}
 }
@@ -360,12 +368,18 @@ func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut
 		path = filepath.Join(pwd, input.Path)
 	}
 	input.Path = path
+	if p.Sandbox != nil {
+		if err := p.Sandbox.CheckWrite(input.Path, p.getWorkingDir()); err != nil {
+			return llm.ErrorToolOut(err)
+		}
+	}
 	if len(input.Patches) == 0 {
 		return llm.ErrorToolOut(fmt.Errorf("no patches provided"))
 	}
 	// TODO: check whether the file is autogenerated, and if so, require a "force" flag to modify it.
 
 	orig, err := os.ReadFile(input.Path)
+	existed := err == nil
 	// If the file doesn't exist, we can still apply patches
 	// that don't require finding existing text.
 	switch {
@@ -535,6 +549,9 @@ func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut
 	if err := os.MkdirAll(filepath.Dir(input.Path), 0o700); err != nil {
 		return llm.ErrorfToolOut("failed to create directory %q: %w", filepath.Dir(input.Path), err)
 	}
+	if p.BeforeWrite != nil {
+		p.BeforeWrite(input.Path, orig, existed)
+	}
 	if err := os.WriteFile(input.Path, patched, 0o600); err != nil {
 		return llm.ErrorfToolOut("failed to write patched contents to file %q: %w", input.Path, err)
 	}
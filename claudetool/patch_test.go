@@ -640,3 +640,49 @@ func TestPatchTool_CallbackFunction(t *testing.T) {
 		t.Errorf("callback received error: %v", capturedOutput.Error)
 	}
 }
+
+func TestPatchTool_BeforeWrite(t *testing.T) {
+	tempDir := t.TempDir()
+	type write struct {
+		path    string
+		orig    string
+		existed bool
+	}
+	var writes []write
+	patch := &PatchTool{
+		WorkingDir: NewMutableWorkingDir(tempDir),
+		BeforeWrite: func(path string, orig []byte, existed bool) {
+			writes = append(writes, write{path, string(orig), existed})
+		},
+	}
+	ctx := context.Background()
+	testFile := filepath.Join(tempDir, "hook.txt")
+
+	run := func(patches ...PatchRequest) llm.ToolOut {
+		msg, _ := json.Marshal(PatchInput{Path: testFile, Patches: patches})
+		return patch.Run(ctx, msg)
+	}
+	if result := run(PatchRequest{Operation: "overwrite", NewText: "one\n"}); result.Error != nil {
+		t.Fatalf("overwrite failed: %v", result.Error)
+	}
+	if result := run(PatchRequest{Operation: "replace", OldText: "one", NewText: "two"}); result.Error != nil {
+		t.Fatalf("replace failed: %v", result.Error)
+	}
+	// A failed patch must not report a write.
+	if result := run(PatchRequest{Operation: "replace", OldText: "missing", NewText: "x"}); result.Error == nil {
+		t.Fatal("expected replace of missing text to fail")
+	}
+
+	want := []write{
+		{testFile, "", false},
+		{testFile, "one\n", true},
+	}
+	if len(writes) != len(want) {
+		t.Fatalf("got %d writes, want %d: %+v", len(writes), len(want), writes)
+	}
+	for i := range want {
+		if writes[i] != want[i] {
+			t.Errorf("write %d = %+v, want %+v", i, writes[i], want[i])
+		}
+	}
+}
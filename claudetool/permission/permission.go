@@ -0,0 +1,392 @@
+// Package permission decides whether tool calls may run, according to
+// policies of allow, deny and ask rules.
+//
+// A rule matches on the tool name and, depending on the tool, on a prefix of
+// a bash command, a glob of the file path the tool touches, or the host of
+// the URL it visits. When several rules match a call, the strictest wins:
+// deny over ask over allow. Calls no rule matches get the policy's default,
+// which is allow unless configured otherwise.
+//
+// A project's policy comes from files in the repository the agent works on,
+// so it can only add restrictions: its allow rules are ignored, and merged
+// policies get the strictest of their defaults.
+package permission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"shelley.exe.dev/claudetool/bashkit"
+)
+
+// Action is what a policy decides for a tool call.
+type Action string
+
+const (
+	Allow Action = "allow"
+	Ask   Action = "ask"
+	Deny  Action = "deny"
+)
+
+// strictness orders actions so that the strictest decision wins.
+func (a Action) strictness() int {
+	switch a {
+	case Deny:
+		return 2
+	case Ask:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (a Action) valid() bool {
+	return a == Allow || a == Ask || a == Deny
+}
+
+// Rule applies Action to the tool calls it matches. Every field that is set
+// must match. A rule with only Action set matches every call.
+type Rule struct {
+	Action Action `json:"action"`
+	// Tool is a glob matched against the tool name, such as "bash" or "browser*".
+	Tool string `json:"tool,omitempty"`
+	// Command matches bash commands whose leading words are these words,
+	// so "git push" matches "git push origin main" but not "git pull".
+	// Every command in a script is checked separately.
+	Command string `json:"command,omitempty"`
+	// Path is a glob matched against the path of the file a tool touches.
+	// "*" matches within a path component and "**" across components.
+	// Relative globs are matched against the path relative to the working directory.
+	Path string `json:"path,omitempty"`
+	// Host matches the host of the URL a tool visits. "*.example.com" matches
+	// example.com and its subdomains.
+	Host string `json:"host,omitempty"`
+	// Reason is shown when the rule denies a call or asks about it.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Policy is a list of rules and the action for calls none of them match.
+type Policy struct {
+	Default Action `json:"default,omitempty"`
+	Rules   []Rule `json:"rules,omitempty"`
+}
+
+// IsZero reports whether the policy has no rules and no default.
+func (p Policy) IsZero() bool {
+	return p.Default == "" && len(p.Rules) == 0
+}
+
+// Validate reports the first malformed rule in the policy, if any.
+func (p Policy) Validate() error {
+	if p.Default != "" && !p.Default.valid() {
+		return fmt.Errorf("invalid default action %q", p.Default)
+	}
+	for i, r := range p.Rules {
+		if !r.Action.valid() {
+			return fmt.Errorf("rule %d: invalid action %q", i, r.Action)
+		}
+		if r.Tool != "" {
+			if _, err := filepath.Match(r.Tool, ""); err != nil {
+				return fmt.Errorf("rule %d: invalid tool glob %q", i, r.Tool)
+			}
+		}
+		if r.Path != "" {
+			if _, err := globRegexp(r.Path); err != nil {
+				return fmt.Errorf("rule %d: invalid path glob %q", i, r.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// Merge combines policies into one that has all of their rules and the
+// strictest of their defaults.
+func Merge(policies ...Policy) Policy {
+	var merged Policy
+	for _, p := range policies {
+		if merged.Default == "" || p.Default.strictness() > merged.Default.strictness() {
+			merged.Default = p.Default
+		}
+		merged.Rules = append(merged.Rules, p.Rules...)
+	}
+	return merged
+}
+
+// Restrictions returns the policy without its allow rules, so that merging
+// it into another policy can deny or ask about more calls but never allow
+// more. Use it for policies that come from untrusted places, like a project.
+func (p Policy) Restrictions() Policy {
+	restricted := Policy{Default: p.Default}
+	for _, r := range p.Rules {
+		if r.Action != Allow {
+			restricted.Rules = append(restricted.Rules, r)
+		}
+	}
+	return restricted
+}
+
+// Call describes a tool call in the terms rules match on.
+type Call struct {
+	Tool string `json:"tool"`
+	// Command is the bash script, for the bash and background tools.
+	Command string `json:"command,omitempty"`
+	// Path is the absolute path of the file the tool touches, if any.
+	Path string `json:"path,omitempty"`
+	// URL is the URL the tool visits, if any.
+	URL string `json:"url,omitempty"`
+	// WorkingDir is the tool's working directory.
+	WorkingDir string `json:"working_dir,omitempty"`
+}
+
+// Describe extracts the command, file path and URL from a tool's input.
+// Relative paths are resolved against workingDir.
+func Describe(tool string, input json.RawMessage, workingDir string) Call {
+	var fields struct {
+		Command string `json:"command"`
+		Path    string `json:"path"`
+		URL     string `json:"url"`
+	}
+	// Inputs without these fields, or that fail to parse, are matched on tool name alone.
+	_ = json.Unmarshal(input, &fields)
+
+	call := Call{Tool: tool, URL: fields.URL, WorkingDir: workingDir}
+	if tool == "bash" || tool == "background" {
+		call.Command = fields.Command
+	}
+	if fields.Path != "" {
+		call.Path = fields.Path
+		if !filepath.IsAbs(call.Path) && workingDir != "" {
+			call.Path = filepath.Join(workingDir, call.Path)
+		}
+	}
+	return call
+}
+
+// Summary is a one-line description of the call for showing to the user.
+func (c Call) Summary() string {
+	switch {
+	case c.Command != "":
+		return c.Command
+	case c.Path != "":
+		return c.Path
+	case c.URL != "":
+		return c.URL
+	}
+	return c.Tool
+}
+
+// Key identifies calls that are the same for the purpose of remembering a decision.
+func (c Call) Key() string {
+	return strings.Join([]string{c.Tool, c.Command, c.Path, c.URL}, "\x00")
+}
+
+// host returns the host of the call's URL, or "" if it has none.
+func (c Call) host() string {
+	if c.URL == "" {
+		return ""
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// Decision is the outcome of evaluating a call against a policy.
+type Decision struct {
+	Action Action `json:"action"`
+	// Rule is the rule that decided, or nil if the policy default applied.
+	Rule *Rule `json:"rule,omitempty"`
+}
+
+// Reason explains the decision for the user or the model.
+func (d Decision) Reason() string {
+	if d.Rule == nil {
+		return fmt.Sprintf("default policy is %s", d.Action)
+	}
+	if d.Rule.Reason != "" {
+		return d.Rule.Reason
+	}
+	var parts []string
+	for _, p := range []struct{ name, value string }{
+		{"tool", d.Rule.Tool}, {"command", d.Rule.Command}, {"path", d.Rule.Path}, {"host", d.Rule.Host},
+	} {
+		if p.value != "" {
+			parts = append(parts, fmt.Sprintf("%s %q", p.name, p.value))
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("a rule says %s for all tools", d.Action)
+	}
+	return fmt.Sprintf("a rule says %s for %s", d.Action, strings.Join(parts, ", "))
+}
+
+// Evaluate decides what to do with a call. For bash scripts that run several
+// commands, each command is evaluated on its own and the strictest decision wins.
+func (p Policy) Evaluate(call Call) Decision {
+	if call.Command == "" {
+		return p.evaluate(call, nil)
+	}
+	commands, err := bashkit.SimpleCommands(call.Command)
+	if err != nil || len(commands) == 0 {
+		// Match the script as a whole; bash will report the syntax error.
+		commands = [][]string{strings.Fields(call.Command)}
+	}
+	var decision Decision
+	for i, words := range commands {
+		d := p.evaluate(call, words)
+		if i == 0 || d.Action.strictness() > decision.Action.strictness() {
+			decision = d
+		}
+	}
+	return decision
+}
+
+// evaluate decides for a call running the single command words, if any.
+func (p Policy) evaluate(call Call, words []string) Decision {
+	var decision *Decision
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !rule.matches(call, words) {
+			continue
+		}
+		if decision == nil || rule.Action.strictness() > decision.Action.strictness() {
+			decision = &Decision{Action: rule.Action, Rule: rule}
+		}
+	}
+	if decision != nil {
+		return *decision
+	}
+	if p.Default != "" {
+		return Decision{Action: p.Default}
+	}
+	return Decision{Action: Allow}
+}
+
+func (r *Rule) matches(call Call, words []string) bool {
+	if r.Tool != "" {
+		if ok, _ := filepath.Match(r.Tool, call.Tool); !ok {
+			return false
+		}
+	}
+	if r.Command != "" && !hasWordPrefix(words, strings.Fields(r.Command)) {
+		return false
+	}
+	if r.Path != "" && !matchPath(r.Path, call.Path, call.WorkingDir) {
+		return false
+	}
+	if r.Host != "" && !matchHost(r.Host, call.host()) {
+		return false
+	}
+	return true
+}
+
+func hasWordPrefix(words, prefix []string) bool {
+	if len(prefix) == 0 || len(words) < len(prefix) {
+		return false
+	}
+	for i, w := range prefix {
+		if words[i] != w {
+			return false
+		}
+	}
+	return true
+}
+
+func matchPath(glob, path, workingDir string) bool {
+	if path == "" {
+		return false
+	}
+	re, err := globRegexp(glob)
+	if err != nil {
+		return false
+	}
+	if filepath.IsAbs(glob) {
+		return re.MatchString(filepath.Clean(path))
+	}
+	if workingDir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(workingDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return false
+	}
+	return re.MatchString(rel)
+}
+
+// globRegexp compiles a path glob in which "**" matches across path separators.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// "**/" also matches no directories at all.
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+func matchHost(pattern, host string) bool {
+	if host == "" {
+		return false
+	}
+	pattern = strings.ToLower(pattern)
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return host == suffix || strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
+
+// ProjectFile is where a project's policy lives, relative to its root.
+const ProjectFile = ".shelley/permissions.json"
+
+// LoadProject finds the nearest ProjectFile in dir or its parents and
+// returns its policy and path. It returns a zero policy if there is none.
+func LoadProject(dir string) (Policy, string, error) {
+	for dir != "" {
+		path := filepath.Join(dir, ProjectFile)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			var p Policy
+			if err := json.Unmarshal(data, &p); err != nil {
+				return Policy{}, path, fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			if err := p.Validate(); err != nil {
+				return Policy{}, path, fmt.Errorf("invalid policy in %s: %w", path, err)
+			}
+			return p, path, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return Policy{}, path, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return Policy{}, "", nil
+}
@@ -0,0 +1,168 @@
+package permission
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	policy := Policy{
+		Rules: []Rule{
+			{Action: Deny, Command: "rm -rf"},
+			{Action: Ask, Command: "git push"},
+			{Action: Allow, Command: "git"},
+			{Action: Deny, Tool: "patch", Path: "**/.env"},
+			{Action: Ask, Tool: "patch", Path: "/etc/**"},
+			{Action: Ask, Host: "*.internal.example.com"},
+			{Action: Deny, Tool: "browser_*", Host: "evil.example"},
+		},
+	}
+	wd := "/home/user/project"
+
+	tests := []struct {
+		name  string
+		tool  string
+		input string
+		want  Action
+	}{
+		{"unmatched bash", "bash", `{"command":"ls -la"}`, Allow},
+		{"allowed prefix", "bash", `{"command":"git status"}`, Allow},
+		{"ask prefix", "bash", `{"command":"git push origin main"}`, Ask},
+		{"prefix is whole words", "bash", `{"command":"git pushy"}`, Allow},
+		{"strictest command in script", "bash", `{"command":"git status && git push"}`, Ask},
+		{"deny inside subshell", "bash", `{"command":"echo hi; (cd /tmp && rm -rf foo)"}`, Deny},
+		{"quoted words", "bash", `{"command":"'git' \"push\""}`, Ask},
+		{"background command", "background", `{"action":"start","command":"rm -rf build && make watch"}`, Deny},
+		{"relative path glob", "patch", `{"path":"config/.env"}`, Deny},
+		{"relative path glob at root", "patch", `{"path":".env"}`, Deny},
+		{"absolute path glob", "patch", `{"path":"/etc/hosts"}`, Ask},
+		{"unmatched path", "patch", `{"path":"main.go"}`, Allow},
+		{"path outside working dir", "patch", `{"path":"/tmp/.env"}`, Allow},
+		{"host wildcard", "browser_navigate", `{"url":"https://api.internal.example.com/x"}`, Ask},
+		{"host wildcard apex", "browser_navigate", `{"url":"https://internal.example.com"}`, Ask},
+		{"host exact", "browser_navigate", `{"url":"http://evil.example:8080/"}`, Deny},
+		{"host unmatched", "browser_navigate", `{"url":"https://example.com"}`, Allow},
+		{"tool without fields", "think", `{"thoughts":"hmm"}`, Allow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call := Describe(tt.tool, json.RawMessage(tt.input), wd)
+			got := policy.Evaluate(call)
+			if got.Action != tt.want {
+				t.Errorf("Evaluate(%s %s) = %s (%s), want %s", tt.tool, tt.input, got.Action, got.Reason(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateDefault(t *testing.T) {
+	call := Describe("bash", json.RawMessage(`{"command":"ls"}`), "/")
+	if got := (Policy{}).Evaluate(call); got.Action != Allow || got.Rule != nil {
+		t.Errorf("empty policy: got %+v, want allow by default", got)
+	}
+	p := Policy{Default: Ask, Rules: []Rule{{Action: Allow, Command: "ls"}}}
+	if got := p.Evaluate(call); got.Action != Allow {
+		t.Errorf("matched rule: got %s, want allow", got.Action)
+	}
+	call = Describe("bash", json.RawMessage(`{"command":"ls; make"}`), "/")
+	if got := p.Evaluate(call); got.Action != Ask || got.Rule != nil {
+		t.Errorf("unmatched command: got %+v, want ask by default", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	conversation := Policy{Rules: []Rule{{Action: Allow, Tool: "bash"}}}
+	project := Policy{Default: Deny, Rules: []Rule{{Action: Ask, Command: "make deploy"}}}
+	server := Policy{Default: Allow}
+
+	p := Merge(conversation, project, server)
+	if p.Default != Deny {
+		t.Errorf("Default = %s, want the project's deny", p.Default)
+	}
+	if len(p.Rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(p.Rules))
+	}
+	call := Describe("bash", json.RawMessage(`{"command":"make deploy"}`), "/")
+	if got := p.Evaluate(call); got.Action != Ask {
+		t.Errorf("make deploy: got %s, want ask", got.Action)
+	}
+	call = Describe("patch", json.RawMessage(`{"path":"x"}`), "/")
+	if got := p.Evaluate(call); got.Action != Deny {
+		t.Errorf("patch: got %s, want deny", got.Action)
+	}
+}
+
+func TestMergeProjectCannotLoosen(t *testing.T) {
+	project := Policy{Default: Allow, Rules: []Rule{
+		{Action: Allow, Tool: "bash"},
+		{Action: Deny, Command: "rm"},
+	}}
+	server := Policy{Default: Ask}
+
+	p := Merge(project.Restrictions(), server)
+	if p.Default != Ask {
+		t.Errorf("Default = %s, want the server's ask", p.Default)
+	}
+	call := Describe("bash", json.RawMessage(`{"command":"ls"}`), "/")
+	if got := p.Evaluate(call); got.Action != Ask {
+		t.Errorf("ls: got %s, want ask despite the project's allow rule", got.Action)
+	}
+	call = Describe("bash", json.RawMessage(`{"command":"rm -rf x"}`), "/")
+	if got := p.Evaluate(call); got.Action != Deny {
+		t.Errorf("rm: got %s, want the project's deny", got.Action)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := []Policy{
+		{Default: "maybe"},
+		{Rules: []Rule{{Action: "sometimes"}}},
+		{Rules: []Rule{{Action: Allow, Tool: "["}}},
+	}
+	for _, p := range bad {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", p)
+		}
+	}
+	good := Policy{Default: Ask, Rules: []Rule{{Action: Deny, Tool: "bash", Command: "sudo", Path: "**/*.go"}}}
+	if err := good.Validate(); err != nil {
+		t.Errorf("Validate(%+v) = %v", good, err)
+	}
+}
+
+func TestLoadProject(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(filepath.Join(root, ".shelley"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	p, path, err := LoadProject(sub)
+	if err != nil || path != "" || !p.IsZero() {
+		t.Fatalf("without a policy file: got %+v, %q, %v", p, path, err)
+	}
+
+	file := filepath.Join(root, ProjectFile)
+	if err := os.WriteFile(file, []byte(`{"default":"ask","rules":[{"action":"deny","command":"rm"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, path, err = LoadProject(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != file || p.Default != Ask || len(p.Rules) != 1 {
+		t.Errorf("got %+v from %q", p, path)
+	}
+
+	if err := os.WriteFile(file, []byte(`{"rules":[{"action":"nope"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadProject(sub); err == nil {
+		t.Error("invalid policy file: want error")
+	}
+}
@@ -0,0 +1,358 @@
+// Package sandbox runs the commands of tools such as bash, either directly on
+// the host or isolated in Linux namespaces.
+//
+// In namespace mode, commands run in new user, mount, PID, and network
+// namespaces created with util-linux's unshare. Every mount is read-only except the
+// working directory's git worktree (or the directory itself outside a
+// repository), the temporary directory, and configured extra paths. The home
+// directory stays read-only even when it is the working directory, and so do
+// the repository's config and hooks, which git outside the sandbox acts on.
+// The network namespace has only a loopback interface, so that commands can't
+// reach the server's API unless the network is shared, and only allowlisted
+// environment variables are passed.
+// Inside the namespaces, commands run as a root user mapped to the server's
+// user, but with no capabilities and no way to gain any, so they can't
+// undo the mounts.
+//
+// Only the commands tools run are sandboxed. MCP servers started over stdio
+// run on the host, as the server's user.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Mode selects how commands run.
+type Mode string
+
+const (
+	// ModeHost runs commands directly as the server's user, with its environment.
+	ModeHost Mode = "host"
+	// ModeNamespace runs commands in Linux namespaces with a restricted view of the filesystem.
+	ModeNamespace Mode = "namespace"
+)
+
+// Config configures how commands run. The zero Config runs them on the host.
+type Config struct {
+	Mode Mode `json:"mode,omitempty"`
+	// ShareNetwork lets commands in namespace mode use the host's network
+	// rather than a network namespace with only a loopback interface. They
+	// can then reach the server's API, and through it leave the sandbox, so
+	// share the network only if the API requires authentication.
+	ShareNetwork bool `json:"share_network,omitempty"`
+	// ReadWrite lists extra paths that are writable in namespace mode, such
+	// as "~/.cache". A leading ~ is the home directory.
+	ReadWrite []string `json:"read_write,omitempty"`
+	// Env lists environment variables passed to commands in namespace mode,
+	// in addition to DefaultEnv. A trailing * matches any suffix.
+	Env []string `json:"env,omitempty"`
+	// Hide lists files that commands in namespace mode see as empty, such as
+	// the server's Unix socket. The server sets it.
+	Hide []string `json:"-"`
+}
+
+// DefaultEnv lists the environment variables passed to commands in namespace mode.
+var DefaultEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "TZ", "TMPDIR",
+	"LANG", "LANGUAGE", "LC_*",
+	"EDITOR", "SKETCH", "SHELLEY_CONVERSATION_ID", "GIT_SEQUENCE_EDITOR",
+}
+
+// Validate reports whether c is well-formed.
+func (c Config) Validate() error {
+	switch c.Mode {
+	case "", ModeHost, ModeNamespace:
+	default:
+		return fmt.Errorf("unknown sandbox mode %q", c.Mode)
+	}
+	for _, p := range c.ReadWrite {
+		if !filepath.IsAbs(expandHome(p)) {
+			return fmt.Errorf("sandbox read_write path %q must be absolute", p)
+		}
+	}
+	return nil
+}
+
+// Executor builds the commands tools run and decides which files tools may write.
+type Executor interface {
+	// Command returns a command that runs script with a bash login shell in dir.
+	Command(ctx context.Context, script, dir string, env []string) *exec.Cmd
+	// CheckWrite returns an error if tools working in dir may not write path.
+	CheckWrite(path, dir string) error
+}
+
+// New returns the executor for c.
+func New(c Config) Executor {
+	if c.Mode == ModeNamespace {
+		return &Namespace{Config: c}
+	}
+	return Host{}
+}
+
+// Host runs commands directly on the host.
+type Host struct{}
+
+func (Host) Command(ctx context.Context, script, dir string, env []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "bash", "--login", "-c", script)
+	cmd.Dir = dir
+	cmd.Env = env
+	return cmd
+}
+
+func (Host) CheckWrite(path, dir string) error {
+	return nil
+}
+
+// Namespace runs commands in Linux namespaces; see the package documentation.
+type Namespace struct {
+	Config Config
+}
+
+// namespaceSetup runs as root in the new namespaces before the command. Its
+// arguments are the command's script, the paths to make writable, "--", the
+// paths within them to keep read-only, "--", and the files to hide.
+const namespaceSetup = `set -e
+script=$1
+shift
+mount --make-rprivate /
+while read -r _ _ _ _ mnt _; do
+	mount -o remount,bind,ro "$(printf %b "$mnt")" 2>/dev/null || true
+done < /proc/self/mountinfo
+while [ "$1" != -- ]; do
+	mount --bind "$1" "$1"
+	mount -o remount,bind,rw "$1"
+	shift
+done
+shift
+while [ "$1" != -- ]; do
+	# Create a missing hooks directory, so that the script can't.
+	if [ -e "$1" ] || mkdir -p "$1" 2>/dev/null; then
+		mount --bind "$1" "$1"
+		mount -o remount,bind,ro "$1"
+	fi
+	shift
+done
+shift
+for p in "$@"; do
+	if [ -e "$p" ]; then
+		mount --bind /dev/null "$p"
+	fi
+done
+ip link set lo up 2>/dev/null || true
+# Enter the working directory again, through the new mounts.
+cd "$PWD"
+# Drop the capabilities root has in the namespaces, such as CAP_SYS_ADMIN,
+# which would let the script remount paths read-write.
+exec setpriv --no-new-privs --inh-caps=-all --ambient-caps=-all --bounding-set=-all -- bash --login -c "$script"
+`
+
+func (n *Namespace) Command(ctx context.Context, script, dir string, env []string) *exec.Cmd {
+	args := []string{"--user", "--map-root-user", "--mount", "--pid", "--fork", "--kill-child", "--mount-proc"}
+	if !n.Config.ShareNetwork {
+		args = append(args, "--net")
+	}
+	writable, readOnly := n.paths(dir)
+	args = append(args, "--", "sh", "-c", namespaceSetup, "shelley-sandbox", script)
+	args = append(args, writable...)
+	args = append(args, "--")
+	args = append(args, readOnly...)
+	args = append(args, "--")
+	args = append(args, n.Config.Hide...)
+	cmd := exec.CommandContext(ctx, "unshare", args...)
+	cmd.Dir = dir
+	cmd.Env = FilterEnv(env, slices.Concat(DefaultEnv, n.Config.Env))
+	return cmd
+}
+
+func (n *Namespace) CheckWrite(path, dir string) error {
+	resolved := resolveExisting(path)
+	writable, readOnly := n.paths(dir)
+	for _, p := range readOnly {
+		if within(resolved, p) {
+			return fmt.Errorf("%s is not writable in the sandbox, since git outside it runs what it configures", path)
+		}
+	}
+	for _, root := range writable {
+		if within(resolved, root) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not writable in the sandbox; writable paths are %s", path, strings.Join(writable, ", "))
+}
+
+// paths returns the existing paths commands working in dir may write, and
+// the paths within them that stay read-only.
+func (n *Namespace) paths(dir string) (writable, readOnly []string) {
+	home, _ := os.UserHomeDir()
+	if home != "" {
+		home = resolveExisting(home)
+	}
+	add := func(p string) {
+		if p == "" {
+			return
+		}
+		if _, err := os.Stat(p); err != nil {
+			return
+		}
+		p = resolveExisting(p)
+		// The working directory never opens up the home directory or the whole filesystem.
+		if home != "" && within(home, p) {
+			return
+		}
+		if !slices.Contains(writable, p) {
+			writable = append(writable, p)
+		}
+	}
+	add(os.TempDir())
+	repo, protected := repository(dir)
+	for _, p := range repo {
+		add(p)
+	}
+	for _, p := range n.Config.ReadWrite {
+		p = expandHome(p)
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		p = resolveExisting(p)
+		if !slices.Contains(writable, p) {
+			writable = append(writable, p)
+		}
+	}
+	for _, p := range protected {
+		readOnly = append(readOnly, resolveExisting(p))
+	}
+	return writable, readOnly
+}
+
+// repository returns the root of dir's git worktree and the repository's git
+// directory, which commits in a linked worktree write to, or just dir
+// outside a repository.
+//
+// It also returns the paths within them that must stay read-only, because
+// git outside the sandbox, such as the server taking checkpoints, runs what
+// they configure: the repository's config and hooks, and the files that lead
+// git from a linked worktree to its repository. The hooks directory is
+// returned even if it doesn't exist yet.
+func repository(dir string) (paths, readOnly []string) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--path-format=absolute", "--show-toplevel", "--git-dir", "--git-common-dir", "--git-path", "hooks").Output()
+	if err != nil {
+		return []string{dir}, nil
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 4 {
+		return []string{dir}, nil
+	}
+	top, gitDir, commonDir, hooks := lines[0], lines[1], lines[2], lines[3]
+	readOnly = []string{filepath.Join(commonDir, "config"), hooks}
+	for _, p := range []string{filepath.Join(top, ".git"), filepath.Join(gitDir, "commondir"), filepath.Join(gitDir, "config.worktree")} {
+		if fi, err := os.Stat(p); err == nil && fi.Mode().IsRegular() {
+			readOnly = append(readOnly, p)
+		}
+	}
+	return []string{top, commonDir}, readOnly
+}
+
+// FilterEnv returns the variables in env whose names match allow.
+// A name in allow ending in * matches any name with that prefix.
+func FilterEnv(env, allow []string) []string {
+	var out []string
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if slices.ContainsFunc(allow, func(pattern string) bool {
+			if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+				return strings.HasPrefix(name, prefix)
+			}
+			return name == pattern
+		}) {
+			out = append(out, kv)
+		}
+	}
+	return out
+}
+
+// Failed returns an executor that runs no commands and allows no writes,
+// reporting err instead, for when which executor to use can't be determined.
+func Failed(err error) Executor {
+	return failed{err}
+}
+
+type failed struct{ err error }
+
+func (f failed) Command(ctx context.Context, script, dir string, env []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "bash", "--login", "-c", script)
+	cmd.Err = f.err // Start reports it without running anything
+	return cmd
+}
+
+func (f failed) CheckWrite(path, dir string) error {
+	return f.err
+}
+
+// Dynamic returns an executor that delegates to whatever get returns at each
+// call, so that long-lived tools follow changes to a conversation's sandbox.
+func Dynamic(get func() Executor) Executor {
+	return dynamic(get)
+}
+
+type dynamic func() Executor
+
+func (d dynamic) Command(ctx context.Context, script, dir string, env []string) *exec.Cmd {
+	return d().Command(ctx, script, dir, env)
+}
+
+func (d dynamic) CheckWrite(path, dir string) error {
+	return d().CheckWrite(path, dir)
+}
+
+// ErrUnavailable is returned by Available when namespace mode cannot work on this system.
+var ErrUnavailable = errors.New("namespace sandbox unavailable")
+
+// Available reports whether commands can run in namespace mode, by running a trivial one.
+func Available(ctx context.Context) error {
+	n := &Namespace{}
+	cmd := n.Command(ctx, "true", os.TempDir(), os.Environ())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %v: %s", ErrUnavailable, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
+
+// resolveExisting resolves symlinks in the longest existing prefix of path,
+// so that paths of files yet to be created can be compared with writable roots.
+func resolveExisting(path string) string {
+	path = filepath.Clean(path)
+	var rest []string
+	for {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(append([]string{path}, rest...)...)
+		}
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = parent
+	}
+}
+
+// within reports whether path is root or inside it.
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
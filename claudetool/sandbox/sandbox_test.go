@@ -0,0 +1,173 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFilterEnv(t *testing.T) {
+	env := []string{"PATH=/bin", "LC_ALL=C", "AWS_SECRET_ACCESS_KEY=x", "PATHOLOGICAL=1", "GOFLAGS=-mod=mod"}
+	got := FilterEnv(env, []string{"PATH", "LC_*", "GO*"})
+	want := []string{"PATH=/bin", "LC_ALL=C", "GOFLAGS=-mod=mod"}
+	if !slices.Equal(got, want) {
+		t.Errorf("FilterEnv = %q, want %q", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, c := range []Config{{Mode: "jail"}, {Mode: ModeNamespace, ReadWrite: []string{"relative"}}} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", c)
+		}
+	}
+	for _, c := range []Config{{}, {Mode: ModeHost}, {Mode: ModeNamespace, ReadWrite: []string{"~/.cache", "/opt"}}} {
+		if err := c.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v", c, err)
+		}
+	}
+}
+
+func gitInit(t *testing.T, dir string) {
+	t.Helper()
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+}
+
+func TestNamespaceCheckWrite(t *testing.T) {
+	repo := t.TempDir()
+	gitInit(t, repo)
+	sub := filepath.Join(repo, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	extra := t.TempDir()
+	n := &Namespace{Config: Config{Mode: ModeNamespace, ReadWrite: []string{extra}}}
+
+	for _, path := range []string{
+		filepath.Join(repo, "main.go"),
+		filepath.Join(repo, "new", "dir", "file.txt"),
+		filepath.Join(repo, ".git", "refs", "heads", "main"),
+		filepath.Join(extra, "x"),
+		filepath.Join(os.TempDir(), "scratch.txt"),
+	} {
+		if err := n.CheckWrite(path, sub); err != nil {
+			t.Errorf("CheckWrite(%s) = %v, want nil", path, err)
+		}
+	}
+
+	// Git outside the sandbox runs what the repository's config and hooks say.
+	for _, path := range []string{filepath.Join(repo, ".git", "config"), filepath.Join(repo, ".git", "hooks", "pre-commit")} {
+		if err := n.CheckWrite(path, sub); err == nil {
+			t.Errorf("CheckWrite(%s) = nil, want error", path)
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	for _, path := range []string{filepath.Join(home, ".bashrc"), "/etc/passwd"} {
+		if err := n.CheckWrite(path, sub); err == nil {
+			t.Errorf("CheckWrite(%s) = nil, want error", path)
+		}
+	}
+	// Working in the home directory does not make it writable.
+	if err := n.CheckWrite(filepath.Join(home, ".bashrc"), home); err == nil {
+		t.Error("CheckWrite in the home directory = nil, want error")
+	}
+	if err := n.CheckWrite("/etc/passwd", "/"); err == nil {
+		t.Error("CheckWrite in / = nil, want error")
+	}
+
+	if err := (Host{}).CheckWrite("/etc/passwd", "/"); err != nil {
+		t.Errorf("host CheckWrite = %v, want nil", err)
+	}
+}
+
+func TestNamespaceCommand(t *testing.T) {
+	ctx := context.Background()
+	if err := Available(ctx); err != nil {
+		t.Skip(err)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	repo := t.TempDir()
+	gitInit(t, repo)
+	// A missing hooks directory can't be created either.
+	if err := os.RemoveAll(filepath.Join(repo, ".git", "hooks")); err != nil {
+		t.Fatal(err)
+	}
+	hidden := filepath.Join(t.TempDir(), "server.sock")
+	if err := os.WriteFile(hidden, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	n := &Namespace{Config: Config{Mode: ModeNamespace, Hide: []string{hidden, "/nonexistent/server.sock"}}}
+
+	run := func(script string, env ...string) (string, error) {
+		cmd := n.Command(ctx, script, repo, append([]string{"PATH=" + os.Getenv("PATH"), "HOME=" + home}, env...))
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	if out, err := run("echo hello > file.txt && cat file.txt"); err != nil || !strings.Contains(out, "hello") {
+		t.Fatalf("writing in the worktree: %v: %s", err, out)
+	}
+	if data, err := os.ReadFile(filepath.Join(repo, "file.txt")); err != nil || string(data) != "hello\n" {
+		t.Fatalf("expected the file outside the sandbox, got %q, %v", data, err)
+	}
+
+	if out, err := run("git -c user.name=a -c user.email=a@localhost commit -q --allow-empty -m x"); err != nil {
+		t.Fatalf("committing: %v: %s", err, out)
+	}
+	for _, script := range []string{
+		"echo '[core] fsmonitor = evil' >> .git/config",
+		"mv .git/config .git/config.old",
+		"mkdir -p .git/hooks && echo evil > .git/hooks/post-checkout",
+		"mv .git .git.old",
+	} {
+		if out, err := run(script); err == nil {
+			t.Errorf("expected %q to fail, got %s", script, out)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(repo, ".git", "config")); err != nil || strings.Contains(string(data), "evil") {
+		t.Errorf("expected the repository's config to be untouched, got %q, %v", data, err)
+	}
+
+	probe := filepath.Join(home, ".shelley-sandbox-test")
+	defer os.Remove(probe)
+	if out, err := run("touch " + probe); err == nil {
+		t.Fatalf("expected writing to the home directory to fail, got %s", out)
+	}
+
+	// Root in the namespaces has no capabilities to undo the read-only mounts with.
+	if out, err := run("mount -o remount,bind,rw / || mount -o remount,bind,rw " + home + "; touch " + probe); err == nil {
+		t.Fatalf("expected remounting to fail, got %s", out)
+	}
+	if _, err := os.Stat(probe); err == nil {
+		t.Fatal("expected the home directory to stay read-only")
+	}
+
+	if out, err := run("echo \"hidden=$(cat " + hidden + ")\""); err != nil || !strings.Contains(out, "hidden=\n") {
+		t.Errorf("expected the hidden file to be empty, got %q, %v", out, err)
+	}
+
+	if out, _ := run("echo \"secret=$SECRET_TOKEN\"", "SECRET_TOKEN=hunter2"); !strings.Contains(out, "secret=\n") {
+		t.Errorf("expected SECRET_TOKEN to be scrubbed, got %q", out)
+	}
+
+	out, err := run("grep -c : /proc/net/dev")
+	if err != nil {
+		t.Fatalf("reading network devices: %v: %s", err, out)
+	}
+	if lines := strings.Fields(out); len(lines) == 0 || lines[len(lines)-1] != "1" {
+		t.Errorf("expected only a loopback interface, got %q", out)
+	}
+}
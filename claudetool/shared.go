@@ -36,3 +36,29 @@ func SessionID(ctx context.Context) string {
 	sessionID, _ := ctx.Value(sessionIDCtxKey).(string)
 	return sessionID
 }
+
+type progressCtxKeyType string
+
+const progressCtxKey progressCtxKeyType = "progress"
+
+// ProgressFunc receives the output of a tool call that is still running.
+// Each call supersedes the previous one, so output may be only the most recent part.
+type ProgressFunc func(output string)
+
+// WithProgress returns a context whose tool calls report progress to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressCtxKey, fn)
+}
+
+// ReportProgress reports the output of a running tool call, if anyone is listening.
+func ReportProgress(ctx context.Context, output string) {
+	if fn, _ := ctx.Value(progressCtxKey).(ProgressFunc); fn != nil {
+		fn(output)
+	}
+}
+
+// HasProgress reports whether progress reported with ctx goes anywhere.
+func HasProgress(ctx context.Context) bool {
+	fn, _ := ctx.Value(progressCtxKey).(ProgressFunc)
+	return fn != nil
+}
@@ -131,6 +131,7 @@ func (s *SubagentTool) Tool() *llm.Tool {
 		Name:        subagentName,
 		Description: s.subagentDescription(),
 		InputSchema: llm.MustSchema(s.subagentInputSchema()),
+		Sequential:  true,
 		Run:         s.Run,
 	}
 }
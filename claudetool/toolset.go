@@ -2,11 +2,14 @@ package claudetool
 
 import (
 	"context"
+	"encoding/json"
 	"os"
 	"strings"
 	"sync"
 
 	"shelley.exe.dev/claudetool/browse"
+	"shelley.exe.dev/claudetool/mcp"
+	"shelley.exe.dev/claudetool/sandbox"
 	"shelley.exe.dev/llm"
 )
 
@@ -72,14 +75,43 @@ type ToolSetConfig struct {
 	// AvailableModels is the list of models the subagent can choose from.
 	// If nil, the list is built from LLMProvider.GetAvailableModels().
 	AvailableModels []AvailableModel
+	// BeforeFileWrite, if set, is called by the patch tool with a file's
+	// contents just before it is changed. See PatchTool.BeforeWrite.
+	BeforeFileWrite func(path string, orig []byte, existed bool)
+	// CheckToolCall, if set, is called before every tool runs, with the
+	// tool's input and the current working directory. If it returns an
+	// error, the tool does not run and the error is its result.
+	// It may block, for example while waiting for the user to approve the call.
+	CheckToolCall func(ctx context.Context, tool string, input json.RawMessage, workingDir string) error
+	// Background holds the conversation's background processes. If nil, the
+	// tool set creates its own, and stops its processes in Cleanup.
+	// Set it to keep processes running across tool sets, such as when a
+	// conversation's loop restarts.
+	Background *BackgroundProcesses
+	// Sandbox runs the commands of the bash and background tools, and
+	// restricts the files the patch tool writes. If nil, commands run
+	// directly on the host and writes are unrestricted.
+	Sandbox sandbox.Executor
+	// MCPServers are the MCP servers whose tools, resources, and prompts the
+	// tool set offers. The tool set connects to them when created and
+	// disconnects in Cleanup. Stdio servers run on the host, whatever Sandbox is.
+	MCPServers []mcp.ServerConfig
+	// MCPPool, if set, shares the connections to MCPServers with other tool
+	// sets, such as those of other conversations.
+	MCPPool *mcp.Pool
+	// ServerTools names tools the model's provider runs itself, such as
+	// "web_search". Tools the model's service doesn't support are skipped.
+	ServerTools []string
 }
 
 // ToolSet holds a set of tools for a single conversation.
 // Each conversation should have its own ToolSet.
 type ToolSet struct {
-	tools   []*llm.Tool
-	cleanup func()
-	wd      *MutableWorkingDir
+	tools      []*llm.Tool
+	cleanup    func()
+	wd         *MutableWorkingDir
+	background *BackgroundProcesses
+	mcp        *mcp.Servers
 }
 
 // Tools returns the tools in this set.
@@ -87,13 +119,23 @@ func (ts *ToolSet) Tools() []*llm.Tool {
 	return ts.tools
 }
 
-// Cleanup releases resources held by the tools (e.g., browser).
+// Cleanup releases resources held by the tools (e.g., browser, background processes).
 func (ts *ToolSet) Cleanup() {
 	if ts.cleanup != nil {
 		ts.cleanup()
 	}
 }
 
+// Background returns the background processes started by the tools.
+func (ts *ToolSet) Background() *BackgroundProcesses {
+	return ts.background
+}
+
+// MCP returns the tool set's connections to MCP servers, or nil if it has none.
+func (ts *ToolSet) MCP() *mcp.Servers {
+	return ts.mcp
+}
+
 // WorkingDir returns the shared working directory.
 func (ts *ToolSet) WorkingDir() *MutableWorkingDir {
 	return ts.wd
@@ -122,6 +164,7 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		LLMProvider:      cfg.LLMProvider,
 		EnableJITInstall: cfg.EnableJITInstall,
 		ConversationID:   cfg.ConversationID,
+		Sandbox:          cfg.Sandbox,
 	}
 
 	// Use simplified patch schema for weaker models, full schema for sonnet/opus
@@ -130,6 +173,8 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		Simplified:       simplified,
 		WorkingDir:       wd,
 		ClipboardEnabled: true,
+		BeforeWrite:      cfg.BeforeFileWrite,
+		Sandbox:          cfg.Sandbox,
 	}
 
 	keywordTool := NewKeywordToolWithWorkingDir(cfg.LLMProvider, wd)
@@ -141,12 +186,20 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 
 	outputIframeTool := &OutputIframeTool{WorkingDir: wd}
 
+	background := cfg.Background
+	ownsBackground := background == nil
+	if ownsBackground {
+		background = &BackgroundProcesses{ConversationID: cfg.ConversationID}
+	}
+	backgroundTool := &BackgroundTool{Processes: background, WorkingDir: wd, Sandbox: cfg.Sandbox}
+
 	tools := []*llm.Tool{
 		bashTool.Tool(),
 		patchTool.Tool(),
 		keywordTool.Tool(),
 		changeDirTool.Tool(),
 		outputIframeTool.Tool(),
+		backgroundTool.Tool(),
 	}
 
 	// Build the available models list (shared by subagent and llm_one_shot tools).
@@ -183,7 +236,20 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		tools = append(tools, llmOneShotTool.Tool())
 	}
 
-	var cleanup func()
+	var cleanups []func()
+	if ownsBackground {
+		cleanups = append(cleanups, background.StopAll)
+	}
+	var mcpServers *mcp.Servers
+	if len(cfg.MCPServers) > 0 {
+		if cfg.MCPPool != nil {
+			mcpServers = cfg.MCPPool.Acquire(ctx, cfg.MCPServers, workingDir)
+		} else {
+			mcpServers = mcp.ConnectAll(ctx, cfg.MCPServers, workingDir)
+		}
+		tools = append(tools, mcpServers.Tools()...)
+		cleanups = append(cleanups, mcpServers.Close)
+	}
 	if cfg.EnableBrowser {
 		// Get max image dimension from the LLM service
 		maxImageDimension := 0
@@ -196,12 +262,44 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		if len(browserTools) > 0 {
 			tools = append(tools, browserTools...)
 		}
-		cleanup = browserCleanup
+		cleanups = append(cleanups, browserCleanup)
+	}
+
+	if cfg.CheckToolCall != nil {
+		for _, tool := range tools {
+			guardTool(tool, wd, cfg.CheckToolCall)
+		}
+	}
+
+	// Server tools run at the provider, so there is nothing to guard.
+	if len(cfg.ServerTools) > 0 && cfg.LLMProvider != nil && cfg.ModelID != "" {
+		if svc, err := cfg.LLMProvider.GetService(cfg.ModelID); err == nil {
+			if sts, ok := svc.(llm.ServerToolService); ok {
+				tools = append(tools, sts.ServerTools(cfg.ServerTools)...)
+			}
+		}
 	}
 
 	return &ToolSet{
-		tools:   tools,
-		cleanup: cleanup,
-		wd:      wd,
+		tools: tools,
+		cleanup: func() {
+			for _, cleanup := range cleanups {
+				cleanup()
+			}
+		},
+		wd:         wd,
+		background: background,
+		mcp:        mcpServers,
+	}
+}
+
+// guardTool makes tool call check before running.
+func guardTool(tool *llm.Tool, wd *MutableWorkingDir, check func(ctx context.Context, tool string, input json.RawMessage, workingDir string) error) {
+	run := tool.Run
+	tool.Run = func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+		if err := check(ctx, tool.Name, input, wd.Get()); err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		return run(ctx, input)
 	}
 }
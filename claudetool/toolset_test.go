@@ -2,8 +2,13 @@ package claudetool
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
+
+	"shelley.exe.dev/llm"
 )
 
 func TestIsStrongModel(t *testing.T) {
@@ -137,6 +142,45 @@ func TestNewToolSet_DefaultWorkingDir(t *testing.T) {
 	}
 }
 
+func TestNewToolSet_CheckToolCall(t *testing.T) {
+	dir := t.TempDir()
+	var checked []string
+	cfg := ToolSetConfig{
+		LLMProvider: &mockLLMProvider{},
+		ModelID:     "test-model",
+		WorkingDir:  dir,
+		CheckToolCall: func(ctx context.Context, tool string, input json.RawMessage, workingDir string) error {
+			checked = append(checked, tool+" "+workingDir)
+			if tool == "patch" {
+				return errors.New("not allowed")
+			}
+			return nil
+		},
+	}
+	ts := NewToolSet(context.Background(), cfg)
+
+	var patch *llm.Tool
+	for _, tool := range ts.Tools() {
+		if tool.Name == "patch" {
+			patch = tool
+		}
+	}
+	if patch == nil {
+		t.Fatal("patch tool not found")
+	}
+	input := json.RawMessage(`{"path":"new.txt","patches":[{"operation":"overwrite","newText":"hi"}]}`)
+	out := patch.Run(context.Background(), input)
+	if out.Error == nil || out.Error.Error() != "not allowed" {
+		t.Errorf("expected the check's error, got %v", out.Error)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("patch ran despite the check failing: %v", err)
+	}
+	if len(checked) != 1 || checked[0] != "patch "+dir {
+		t.Errorf("checked = %v", checked)
+	}
+}
+
 func TestNewToolSet_WithBrowser(t *testing.T) {
 	provider := &mockLLMProvider{}
 
@@ -360,3 +404,47 @@ func TestToolDescriptions(t *testing.T) {
 		}
 	}
 }
+
+// serverToolService offers web_search as a server tool.
+type serverToolService struct{ mockService }
+
+func (s *serverToolService) ServerTools(names []string) []*llm.Tool {
+	var tools []*llm.Tool
+	for _, name := range names {
+		if name == "web_search" {
+			tools = append(tools, &llm.Tool{Name: name, Type: "web_search_20250305", Server: true})
+		}
+	}
+	return tools
+}
+
+func TestNewToolSet_ServerTools(t *testing.T) {
+	provider := &oneShotMockProvider{services: map[string]llm.Service{
+		"claude": &serverToolService{},
+		"other":  &mockService{},
+	}}
+	serverTools := func(modelID string) []string {
+		ts := NewToolSet(context.Background(), ToolSetConfig{
+			LLMProvider:   provider,
+			ModelID:       modelID,
+			WorkingDir:    t.TempDir(),
+			ServerTools:   []string{"web_search", "code_execution"},
+			CheckToolCall: func(ctx context.Context, tool string, input json.RawMessage, workingDir string) error { return nil },
+		})
+		defer ts.Cleanup()
+		var names []string
+		for _, tool := range ts.Tools() {
+			if tool.Server {
+				names = append(names, tool.Name)
+			}
+		}
+		return names
+	}
+
+	if got := serverTools("claude"); len(got) != 1 || got[0] != "web_search" {
+		t.Errorf("expected web_search for a service with server tools, got %v", got)
+	}
+	if got := serverTools("other"); len(got) != 0 {
+		t.Errorf("expected no server tools for a service without them, got %v", got)
+	}
+}
@@ -56,9 +56,23 @@ func (f *multiFlag) Set(value string) error {
 	return nil
 }
 
+// parseHeaders parses -H flags of the form "Name: Value".
+func parseHeaders(flags []string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, h := range flags {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid header %q (expected \"Name: Value\")", h)
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return headers, nil
+}
+
 type clientConfig struct {
 	serverURL string
 	headers   map[string]string
+	token     string // API token for servers run with --auth
 }
 
 func (cc *clientConfig) newHTTPClient() (*http.Client, string, error) {
@@ -96,6 +110,9 @@ func (cc *clientConfig) newRequest(method, url string, body *strings.Reader) (*h
 	if method == http.MethodPost {
 		req.Header.Set("X-Shelley-Request", "1")
 	}
+	if cc.token != "" {
+		req.Header.Set("Authorization", "Bearer "+cc.token)
+	}
 	for k, v := range cc.headers {
 		req.Header.Set(k, v)
 	}
@@ -108,6 +125,7 @@ func Run(args []string) {
 	urlFlag := fs.String("url", defaultClientURL(), "Server URL (unix:///path, http://host:port, https://host:port)")
 	var headerFlags multiFlag
 	fs.Var(&headerFlags, "H", `Extra HTTP header ("Name: Value", can be repeated)`)
+	tokenFlag := fs.String("token", os.Getenv("SHELLEY_TOKEN"), "API token for servers run with --auth (default $SHELLEY_TOKEN)")
 	fs.Usage = func() {
 		fmt.Fprintf(fs.Output(), "EXPERIMENTAL: Shelley CLI client\n\n")
 		fmt.Fprintf(fs.Output(), "Usage: shelley client [flags] <subcommand> [args...]\n\n")
@@ -122,17 +140,13 @@ func Run(args []string) {
 	}
 	fs.Parse(args)
 
-	headers := make(map[string]string)
-	for _, h := range headerFlags {
-		parts := strings.SplitN(h, ":", 2)
-		if len(parts) != 2 {
-			fmt.Fprintf(os.Stderr, "Error: invalid header %q (expected \"Name: Value\")\n", h)
-			os.Exit(1)
-		}
-		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	headers, err := parseHeaders(headerFlags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	cc := &clientConfig{serverURL: *urlFlag, headers: headers}
+	cc := &clientConfig{serverURL: *urlFlag, headers: headers, token: *tokenFlag}
 
 	subArgs := fs.Args()
 	if len(subArgs) == 0 {
@@ -546,6 +560,7 @@ Usage:
 Flags:
   -url URL     Server URL (default: unix://%s)
   -H HEADER    Extra HTTP header "Name: Value" (can be repeated)
+  -token TOKEN API token for servers run with --auth (default: $SHELLEY_TOKEN)
 
 Subcommands:
   chat -p PROMPT [-c CONVERSATION_ID] [-model MODEL] [-cwd DIR]
@@ -568,6 +583,9 @@ Subcommands:
 Connecting over HTTP with auth headers:
   shelley client -url http://localhost:9999 -H "X-Exedev-Userid: user" list
 
+Connecting over HTTP to a server run with --auth (the Unix socket needs no token):
+  SHELLEY_TOKEN=shelley_... shelley client -url http://localhost:9999 list
+
 Examples:
   # Start a conversation and wait for the agent
   ID=$(shelley client chat -p "list files" | jq -r .conversation_id)
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// RunMCP is the entry point for "shelley mcp [flags]". It serves the
+// server's MCP endpoint over stdio, so MCP clients can start it as a local
+// server: each JSON-RPC message read from stdin is posted to /api/mcp, and
+// each response is written to stdout, one per line.
+func RunMCP(args []string) {
+	fs := flag.NewFlagSet("mcp", flag.ExitOnError)
+	urlFlag := fs.String("url", defaultClientURL(), "Server URL (unix:///path, http://host:port, https://host:port)")
+	var headerFlags multiFlag
+	fs.Var(&headerFlags, "H", `Extra HTTP header ("Name: Value", can be repeated)`)
+	tokenFlag := fs.String("token", os.Getenv("SHELLEY_TOKEN"), "API token for servers run with --auth (default $SHELLEY_TOKEN)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "EXPERIMENTAL: Serve Shelley's conversations as an MCP server over stdio\n\n")
+		fmt.Fprintf(fs.Output(), "Usage: shelley mcp [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	headers, err := parseHeaders(headerFlags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	cc := &clientConfig{serverURL: *urlFlag, headers: headers, token: *tokenFlag}
+	client, baseURL, err := cc.newHTTPClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	b := &mcpBridge{cc: cc, client: client, endpoint: baseURL + "/api/mcp", out: os.Stdout}
+	if err := b.serve(os.Stdin); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// mcpBridge relays JSON-RPC messages between stdio and the server.
+type mcpBridge struct {
+	cc       *clientConfig
+	client   *http.Client
+	endpoint string
+
+	mu  sync.Mutex // guards out
+	out io.Writer
+}
+
+// serve relays messages until in closes. Requests are relayed concurrently,
+// since tools that wait for the agent can take minutes to answer.
+func (b *mcpBridge) serve(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	var wg sync.WaitGroup
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		msg := string(line)
+		wg.Go(func() { b.relay(msg) })
+	}
+	wg.Wait()
+	return scanner.Err()
+}
+
+func (b *mcpBridge) relay(msg string) {
+	var id struct {
+		ID json.RawMessage `json:"id"`
+	}
+	json.Unmarshal([]byte(msg), &id)
+
+	resp, err := b.post(msg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "shelley mcp: %v\n", err)
+		if id.ID != nil {
+			// Answer the request, so the client doesn't wait forever.
+			b.write(map[string]any{
+				"jsonrpc": "2.0",
+				"id":      id.ID,
+				"error":   map[string]any{"code": -32603, "message": err.Error()},
+			})
+		}
+		return
+	}
+	if len(resp) > 0 {
+		b.write(json.RawMessage(resp))
+	}
+}
+
+// post sends a message to the server, returning its response, if any.
+func (b *mcpBridge) post(msg string) ([]byte, error) {
+	req, err := b.cc.newRequest(http.MethodPost, b.endpoint, strings.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+		return nil, nil
+	case http.StatusOK, http.StatusBadRequest:
+		// The server reports JSON-RPC errors in the body.
+		if json.Valid(body) {
+			return bytes.TrimSpace(body), nil
+		}
+	}
+	return nil, fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+func (b *mcpBridge) write(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.out.Write(append(data, '\n'))
+}
@@ -69,6 +69,10 @@ func TS() *go2ts.Go2TS {
 		streamResponseForTS{},
 		conversationWithStateForTS{},
 		notificationEventForTS{},
+		streamDeltaForTS{},
+		streamResyncForTS{},
+		approvalRequestForTS{},
+		toolProgressForTS{},
 	)
 
 	// Generate clean nominal types
@@ -91,26 +95,44 @@ type apiMessageForTS struct {
 }
 
 type conversationStateForTS struct {
-	ConversationID string `json:"conversation_id"`
-	Working        bool   `json:"working"`
-	Model          string `json:"model,omitempty"`
+	ConversationID   string                 `json:"conversation_id"`
+	Working          bool                   `json:"working"`
+	Model            string                 `json:"model,omitempty"`
+	PendingApprovals []approvalRequestForTS `json:"pending_approvals,omitempty"`
+}
+
+type approvalRequestForTS struct {
+	ID                   string `json:"id"`
+	ConversationID       string `json:"conversation_id"`
+	ParentConversationID string `json:"parent_conversation_id,omitempty"`
+	Tool                 string `json:"tool"`
+	Input                string `json:"input"`
+	Summary              string `json:"summary"`
+	Reason               string `json:"reason"`
+	CreatedAt            string `json:"created_at"`
 }
 
 type conversationWithStateForTS struct {
-	ConversationID       string  `json:"conversation_id"`
-	Slug                 *string `json:"slug"`
-	UserInitiated        bool    `json:"user_initiated"`
-	CreatedAt            string  `json:"created_at"`
-	UpdatedAt            string  `json:"updated_at"`
-	Cwd                  *string `json:"cwd"`
-	Archived             bool    `json:"archived"`
-	ParentConversationID *string `json:"parent_conversation_id"`
-	Model                *string `json:"model"`
-	Working              bool    `json:"working"`
-	GitRepoRoot          string  `json:"git_repo_root,omitempty"`
-	GitWorktreeRoot      string  `json:"git_worktree_root,omitempty"`
-	GitCommit            string  `json:"git_commit,omitempty"`
-	GitSubject           string  `json:"git_subject,omitempty"`
+	ConversationID           string  `json:"conversation_id"`
+	Slug                     *string `json:"slug"`
+	UserInitiated            bool    `json:"user_initiated"`
+	CreatedAt                string  `json:"created_at"`
+	UpdatedAt                string  `json:"updated_at"`
+	Cwd                      *string `json:"cwd"`
+	Archived                 bool    `json:"archived"`
+	ParentConversationID     *string `json:"parent_conversation_id"`
+	Model                    *string `json:"model"`
+	ForkedFromConversationID *string `json:"forked_from_conversation_id"`
+	ForkedFromMessageID      *string `json:"forked_from_message_id"`
+	ScheduledTaskID          *string `json:"scheduled_task_id"`
+	ThinkingLevel            *string `json:"thinking_level"`
+	Working                  bool    `json:"working"`
+	GitRepoRoot              string  `json:"git_repo_root,omitempty"`
+	GitWorktreeRoot          string  `json:"git_worktree_root,omitempty"`
+	GitCommit                string  `json:"git_commit,omitempty"`
+	GitSubject               string  `json:"git_subject,omitempty"`
+	MatchSequenceID          *int64  `json:"match_sequence_id,omitempty"`
+	MatchSnippet             string  `json:"match_snippet,omitempty"`
 }
 
 type streamResponseForTS struct {
@@ -119,6 +141,33 @@ type streamResponseForTS struct {
 	ConversationState *conversationStateForTS `json:"conversation_state,omitempty"`
 	Heartbeat         bool                    `json:"heartbeat,omitempty"`
 	NotificationEvent *notificationEventForTS `json:"notification_event,omitempty"`
+	StreamDelta       *streamDeltaForTS       `json:"stream_delta,omitempty"`
+	StreamResync      *streamResyncForTS      `json:"stream_resync,omitempty"`
+	ApprovalRequest   *approvalRequestForTS   `json:"approval_request,omitempty"`
+	ToolProgress      *toolProgressForTS      `json:"tool_progress,omitempty"`
+}
+
+type toolProgressForTS struct {
+	ToolUseID string `json:"tool_use_id"`
+	Output    string `json:"output"`
+}
+
+type streamDeltaForTS struct {
+	Response  int64  `json:"response"`
+	Seq       int    `json:"seq"`
+	Index     int    `json:"index"`
+	Type      string `json:"type"`
+	Text      string `json:"text,omitempty"`
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	ToolName  string `json:"tool_name,omitempty"`
+	ToolInput string `json:"tool_input,omitempty"`
+	Reset     bool   `json:"reset,omitempty"`
+}
+
+type streamResyncForTS struct {
+	Response int64              `json:"response"`
+	Seq      int                `json:"seq"`
+	Blocks   []streamDeltaForTS `json:"blocks"`
 }
 
 type notificationEventForTS struct {
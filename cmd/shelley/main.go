@@ -6,12 +6,17 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 
 	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/claudetool/mcp"
+	"shelley.exe.dev/claudetool/permission"
+	"shelley.exe.dev/claudetool/sandbox"
 	"shelley.exe.dev/client"
 	"shelley.exe.dev/db"
 	"shelley.exe.dev/models"
@@ -49,7 +54,9 @@ func main() {
 		flag.PrintDefaults()
 		fmt.Fprintf(flag.CommandLine.Output(), "\nCommands:\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  serve [flags]                 Start the web server\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  run [flags] [prompt...]       Run the agent on one prompt without a server\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  client [flags] <subcommand>   CLI client (chat, read, list, archive) (experimental)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  mcp [flags]                   Serve conversations to MCP clients over stdio (experimental)\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  unpack-template <name> <dir>  Unpack a project template to a directory\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  version                       Print version information as JSON\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "\nUse '%s <command> -h' for command-specific help\n", os.Args[0])
@@ -68,8 +75,12 @@ func main() {
 	switch command {
 	case "serve":
 		runServe(global, args[1:])
+	case "run":
+		runRun(global, args[1:])
 	case "client":
 		client.Run(args[1:])
+	case "mcp":
+		client.RunMCP(args[1:])
 	case "unpack-template":
 		runUnpackTemplate(args[1:])
 	case "version":
@@ -87,6 +98,7 @@ func runServe(global GlobalConfig, args []string) {
 	portFile := fs.String("port-file", "", "Write the actual listening port to this file (useful with --port 0)")
 	systemdActivation := fs.Bool("systemd-activation", false, "Use systemd socket activation (listen on fd from systemd)")
 	requireHeader := fs.String("require-header", "", "Require this header on all API requests (e.g., X-Exedev-Userid)")
+	auth := fs.Bool("auth", false, "Require signing in with a password or API token (the password is chosen on first run)")
 	socketPath := fs.String("socket", client.DefaultSocketPath(), "Path to Unix socket for local CLI client access (set to 'none' to disable)")
 	fs.Parse(args)
 
@@ -109,14 +121,31 @@ func runServe(global GlobalConfig, args []string) {
 	logger.Info("Available models", "models", strings.Join(availableModels, ", "))
 
 	toolSetConfig := setupToolSetConfig(llmManager, llmManager)
+	toolSetConfig.ServerTools = llmConfig.ServerTools
 
 	// Create server
 	svr := server.NewServer(database, llmManager, toolSetConfig, logger, global.PredictableOnly, llmConfig.TerminalURL, llmConfig.DefaultModel, *requireHeader, llmConfig.Links)
 
+	if *auth {
+		if err := svr.EnableAuth(context.Background()); err != nil {
+			logger.Error("Failed to enable authentication", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	// Seed notification channels from config file if DB is empty (one-time migration)
 	svr.SeedNotificationChannelsFromConfig(llmConfig.NotificationChannels)
 	// Load notification channels from DB
 	svr.ReloadNotificationChannels()
+	svr.SetBudgets(llmConfig.Budgets)
+	svr.SetPermissions(llmConfig.Permissions)
+	svr.SetSandbox(llmConfig.Sandbox)
+	svr.SetMCPServers(llmConfig.MCPServers)
+	if llmConfig.Sandbox.Mode == sandbox.ModeNamespace {
+		if err := sandbox.Available(context.Background()); err != nil {
+			logger.Warn("Sandbox configured but unavailable, commands will fail", "error", err)
+		}
+	}
 
 	// Resolve socket path: "none" disables the Unix socket listener
 	effectiveSocket := *socketPath
@@ -295,6 +324,14 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 		Logger:          logger,
 	}
 
+	// SHELLEY_LOCAL_MODELS is a comma-separated list of local server URLs,
+	// such as http://localhost:11434/v1 for Ollama.
+	for endpoint := range strings.SplitSeq(os.Getenv("SHELLEY_LOCAL_MODELS"), ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			llmCfg.LocalModels = append(llmCfg.LocalModels, strings.TrimSuffix(endpoint, "/"))
+		}
+	}
+
 	if configPath != "" {
 		data, err := os.ReadFile(configPath)
 		if err != nil {
@@ -305,11 +342,18 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 		}
 
 		var cfg struct {
-			LLMGateway           string           `json:"llm_gateway"`
-			TerminalURL          string           `json:"terminal_url"`
-			DefaultModel         string           `json:"default_model"`
-			Links                []server.Link    `json:"links"`
-			NotificationChannels []map[string]any `json:"notification_channels"`
+			LLMGateway           string                      `json:"llm_gateway"`
+			TerminalURL          string                      `json:"terminal_url"`
+			DefaultModel         string                      `json:"default_model"`
+			Links                []server.Link               `json:"links"`
+			NotificationChannels []map[string]any            `json:"notification_channels"`
+			Budgets              server.BudgetConfig         `json:"budgets"`
+			ModelFallbacks       map[string][]string         `json:"model_fallbacks"`
+			LocalModels          []string                    `json:"local_models"`
+			ServerTools          []string                    `json:"server_tools"`
+			Permissions          permission.Policy           `json:"permissions"`
+			Sandbox              sandbox.Config              `json:"sandbox"`
+			MCPServers           map[string]mcp.ServerConfig `json:"mcp_servers"`
 		}
 		if err := json.Unmarshal(data, &cfg); err != nil {
 			logger.Warn("Failed to parse config file", "path", configPath, "error", err)
@@ -358,6 +402,62 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 			llmCfg.NotificationChannels = cfg.NotificationChannels
 			logger.Info("Notification channels configured", "count", len(cfg.NotificationChannels))
 		}
+
+		if len(cfg.ModelFallbacks) > 0 {
+			llmCfg.ModelFallbacks = cfg.ModelFallbacks
+			logger.Info("Model fallbacks configured", "models", len(cfg.ModelFallbacks))
+		}
+
+		for _, endpoint := range cfg.LocalModels {
+			llmCfg.LocalModels = append(llmCfg.LocalModels, strings.TrimSuffix(endpoint, "/"))
+		}
+		if len(cfg.LocalModels) > 0 {
+			logger.Info("Local model servers configured", "endpoints", llmCfg.LocalModels)
+		}
+
+		if len(cfg.ServerTools) > 0 {
+			llmCfg.ServerTools = cfg.ServerTools
+			logger.Info("Server tools configured", "tools", cfg.ServerTools)
+		}
+
+		llmCfg.Budgets = cfg.Budgets
+		if !cfg.Budgets.Conversation.IsZero() || !cfg.Budgets.Daily.IsZero() {
+			logger.Info("Spending budgets configured", "conversation", cfg.Budgets.Conversation, "daily", cfg.Budgets.Daily)
+		}
+
+		if err := cfg.Permissions.Validate(); err != nil {
+			// Ask about every tool call rather than drop rules that may deny some.
+			logger.Warn("Invalid permissions in config file, asking before every tool call", "path", configPath, "error", err)
+			llmCfg.Permissions = permission.Policy{Default: permission.Ask}
+		} else if !cfg.Permissions.IsZero() {
+			llmCfg.Permissions = cfg.Permissions
+			logger.Info("Tool permission policy configured", "rules", len(cfg.Permissions.Rules), "default", cfg.Permissions.Default)
+		}
+
+		if err := cfg.Sandbox.Validate(); err != nil {
+			// Sandbox commands with the defaults rather than run them on the host.
+			logger.Warn("Invalid sandbox in config file, using the default namespace sandbox", "path", configPath, "error", err)
+			llmCfg.Sandbox = sandbox.Config{Mode: sandbox.ModeNamespace}
+		} else if cfg.Sandbox.Mode != "" {
+			llmCfg.Sandbox = cfg.Sandbox
+			logger.Info("Sandbox configured", "mode", cfg.Sandbox.Mode, "share_network", cfg.Sandbox.ShareNetwork)
+			if cfg.Sandbox.ShareNetwork {
+				logger.Warn("Sandboxed commands share the network, so they can reach the server's API")
+			}
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(cfg.MCPServers)) {
+			server := cfg.MCPServers[name]
+			server.Name = name
+			if err := server.Validate(); err != nil {
+				logger.Warn("Invalid MCP server in config file, skipping", "path", configPath, "error", err)
+				continue
+			}
+			llmCfg.MCPServers = append(llmCfg.MCPServers, server)
+		}
+		if len(llmCfg.MCPServers) > 0 {
+			logger.Info("MCP servers configured", "count", len(llmCfg.MCPServers))
+		}
 	}
 
 	return llmCfg
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/claudetool/permission"
+	"shelley.exe.dev/claudetool/sandbox"
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+	"shelley.exe.dev/server"
+	"shelley.exe.dev/slug"
+)
+
+// Exit codes of "shelley run", besides 0 for success and 2 for bad usage.
+const (
+	exitRunFailed = 1 // the LLM or the run itself failed
+	exitRunLimit  = 3 // a budget or turn limit stopped the agent
+)
+
+// errTurnLimit is returned by a run stopped by its turn limit.
+var errTurnLimit = errors.New("turn limit reached")
+
+// runOptions configures a headless run of the agent.
+type runOptions struct {
+	Prompt     string
+	Cwd        string
+	Model      string
+	MaxTurns   int64   // LLM requests; 0 is unlimited
+	MaxDollars float64 // 0 is unlimited
+	// Save records the conversation in the database, for the UI to show later.
+	Save bool
+}
+
+// runTranscript is the outcome of a run, printed by "shelley run -json".
+type runTranscript struct {
+	ConversationID string `json:"conversation_id,omitempty"`
+	Model          string `json:"model"`
+	// Result is the text of the agent's last message.
+	Result   string        `json:"result"`
+	Error    string        `json:"error,omitempty"`
+	Usage    llm.Usage     `json:"usage"`
+	Messages []llm.Message `json:"messages"`
+}
+
+func runRun(global GlobalConfig, args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	cwd := fs.String("cwd", "", "Working directory for the agent (default: the current directory)")
+	model := fs.String("model", global.Model, "Model to use")
+	jsonOutput := fs.Bool("json", false, "Print a JSON transcript instead of the agent's final message")
+	save := fs.Bool("save", false, "Record the conversation in the database given by -db")
+	maxTurns := fs.Int64("max-turns", 0, "Stop after this many LLM requests (default: budgets.conversation.max_turns from shelley.json)")
+	maxDollars := fs.Float64("max-dollars", 0, "Stop once the run costs this much (default: budgets.conversation.max_dollars from shelley.json)")
+	timeout := fs.Duration("timeout", 0, "Stop the run after this long (default: no limit)")
+	quiet := fs.Bool("q", false, "Don't report progress on stderr")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: shelley [global-flags] run [flags] [prompt...]\n\n")
+		fmt.Fprintf(fs.Output(), "Runs the agent on one prompt without a server, reporting progress on stderr\n")
+		fmt.Fprintf(fs.Output(), "and printing its final message on stdout. The prompt is read from stdin if\n")
+		fmt.Fprintf(fs.Output(), "it isn't given, or is \"-\".\n\n")
+		fmt.Fprintf(fs.Output(), "Exits with status 1 if the run fails and 3 if a budget or turn limit stops it.\n\n")
+		fmt.Fprintf(fs.Output(), "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	prompt := strings.Join(fs.Args(), " ")
+	if prompt == "" || prompt == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: reading prompt: %v\n", err)
+			os.Exit(2)
+		}
+		prompt = string(data)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		fmt.Fprintf(os.Stderr, "Error: a prompt is required\n")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// Logs go to stderr, and only warnings unless -debug, so stdout holds just the result.
+	logLevel := slog.LevelWarn
+	if global.Debug {
+		logLevel = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+	slog.SetDefault(logger)
+
+	var database *db.DB
+	if *save {
+		database = setupDatabase(global.DBPath, logger)
+		defer database.Close()
+		server.DBPath = global.DBPath
+	}
+
+	llmConfig := buildLLMConfig(logger, global.ConfigPath, global.TerminalURL, global.DefaultModel, database)
+	llmManager := server.NewLLMServiceManager(llmConfig)
+	toolSetConfig := setupToolSetConfig(llmManager, llmManager)
+	if llmConfig.Sandbox.Mode != "" {
+		toolSetConfig.Sandbox = sandbox.New(llmConfig.Sandbox)
+	}
+	toolSetConfig.MCPServers = llmConfig.MCPServers
+	toolSetConfig.ServerTools = llmConfig.ServerTools
+
+	opts := runOptions{
+		Prompt:     prompt,
+		Cwd:        *cwd,
+		Model:      *model,
+		MaxTurns:   *maxTurns,
+		MaxDollars: *maxDollars,
+		Save:       *save,
+	}
+	if opts.MaxTurns == 0 {
+		opts.MaxTurns = llmConfig.Budgets.Conversation.MaxTurns
+	}
+	if opts.MaxDollars == 0 {
+		opts.MaxDollars = llmConfig.Budgets.Conversation.MaxDollars
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	progress := io.Writer(os.Stderr)
+	if *quiet {
+		progress = io.Discard
+	}
+	transcript, err := runAgent(ctx, opts, llmManager, database, toolSetConfig, llmConfig.Permissions, logger, progress)
+	if transcript != nil {
+		if *jsonOutput {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(transcript)
+		} else if transcript.Result != "" {
+			fmt.Println(transcript.Result)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, loop.ErrBudgetExceeded) {
+			os.Exit(exitRunLimit)
+		}
+		os.Exit(exitRunFailed)
+	}
+}
+
+// runAgent runs the agent on opts.Prompt until it ends its turn, writing
+// progress to progress. It returns the transcript so far even if the run
+// fails, but only sets its Result if the run succeeds.
+func runAgent(ctx context.Context, opts runOptions, llmManager server.LLMProvider, database *db.DB, toolSetConfig claudetool.ToolSetConfig, policy permission.Policy, logger *slog.Logger, progress io.Writer) (*runTranscript, error) {
+	cwd := opts.Cwd
+	if cwd == "" {
+		cwd = toolSetConfig.WorkingDir
+	}
+	cwd, err := filepath.Abs(cwd)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(cwd); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("working directory %q is not a directory", cwd)
+	}
+	service, err := llmManager.GetService(opts.Model)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported model %q: %w", opts.Model, err)
+	}
+	systemPrompt, err := server.GenerateSystemPrompt(cwd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate system prompt: %w", err)
+	}
+
+	transcript := &runTranscript{Model: opts.Model, Messages: []llm.Message{}}
+	var rec *runRecorder
+	if opts.Save {
+		if rec, err = newRunRecorder(ctx, database, cwd, opts.Model, systemPrompt); err != nil {
+			return nil, err
+		}
+		transcript.ConversationID = rec.conversationID
+		fmt.Fprintf(progress, "Conversation %s\n", rec.conversationID)
+
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		wg.Go(func() {
+			slugCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 15*time.Second)
+			defer cancel()
+			if _, err := slug.GenerateSlug(slugCtx, llmManager, database, logger, rec.conversationID, opts.Prompt, opts.Model); err != nil {
+				logger.Warn("Failed to generate slug for conversation", "conversationID", rec.conversationID, "error", err)
+			}
+		})
+	}
+
+	var mu sync.Mutex // guards transcript.Messages
+	recordMessage := func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+		mu.Lock()
+		transcript.Messages = append(transcript.Messages, message)
+		mu.Unlock()
+		reportProgress(progress, message, cwd)
+		if rec != nil {
+			return rec.record(ctx, message, usage)
+		}
+		return nil
+	}
+
+	toolSetConfig.WorkingDir = cwd
+	toolSetConfig.ModelID = opts.Model
+	toolSetConfig.ConversationID = transcript.ConversationID
+	toolSetConfig.CheckToolCall = func(ctx context.Context, tool string, input json.RawMessage, workingDir string) error {
+		project, _, err := permission.LoadProject(workingDir)
+		if err != nil {
+			return fmt.Errorf("failed to load permission policy: %w", err)
+		}
+		decision := permission.Merge(project.Restrictions(), policy).Evaluate(permission.Describe(tool, input, workingDir))
+		switch decision.Action {
+		case permission.Deny:
+			return fmt.Errorf("permission denied: %s", decision.Reason())
+		case permission.Ask:
+			// Nobody is around to approve calls in a headless run.
+			return fmt.Errorf("permission denied: %s, and no one can approve it in a headless run", decision.Reason())
+		}
+		return nil
+	}
+	toolSet := claudetool.NewToolSet(ctx, toolSetConfig)
+	defer toolSet.Cleanup()
+
+	var agent *loop.Loop
+	var turns int64
+	agent = loop.NewLoop(loop.Config{
+		LLM:           service,
+		ModelID:       opts.Model,
+		Tools:         toolSet.Tools(),
+		RecordMessage: recordMessage,
+		Logger:        logger,
+		System:        []llm.SystemContent{{Type: "text", Text: systemPrompt}},
+		WorkingDir:    cwd,
+		GetWorkingDir: toolSet.WorkingDir().Get,
+		CheckBudget: func(ctx context.Context) error {
+			turns++
+			if opts.MaxTurns > 0 && turns > opts.MaxTurns {
+				return fmt.Errorf("%w: %d turns", errTurnLimit, opts.MaxTurns)
+			}
+			if usage := agent.GetUsage(); opts.MaxDollars > 0 && usage.CostUSD >= opts.MaxDollars {
+				return fmt.Errorf("budget of $%.2f reached: spent $%.2f", opts.MaxDollars, usage.CostUSD)
+			}
+			return nil
+		},
+	})
+
+	userMessage := llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: opts.Prompt}},
+	}
+	if err := recordMessage(ctx, userMessage, llm.Usage{}); err != nil {
+		return transcript, err
+	}
+	agent.QueueUserMessage(userMessage)
+	err = agent.ProcessOneTurn(ctx)
+
+	transcript.Usage = agent.GetUsage()
+	mu.Lock()
+	defer mu.Unlock()
+	if err != nil {
+		transcript.Error = err.Error()
+		return transcript, err
+	}
+	if n := len(transcript.Messages); n > 0 && transcript.Messages[n-1].Role == llm.MessageRoleAssistant {
+		transcript.Result = messageText(transcript.Messages[n-1])
+	}
+	return transcript, nil
+}
+
+// messageText returns the text of a message's text content.
+func messageText(msg llm.Message) string {
+	var texts []string
+	for _, c := range msg.Content {
+		if c.Type == llm.ContentTypeText && c.Text != "" {
+			texts = append(texts, c.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// reportProgress writes a line or two about a message as it is recorded.
+func reportProgress(w io.Writer, msg llm.Message, workingDir string) {
+	for _, c := range msg.Content {
+		switch c.Type {
+		case llm.ContentTypeText:
+			if msg.Role == llm.MessageRoleAssistant && c.Text != "" {
+				fmt.Fprintf(w, "%s\n", c.Text)
+			}
+		case llm.ContentTypeToolUse:
+			summary := permission.Describe(c.ToolName, c.ToolInput, workingDir).Summary()
+			fmt.Fprintf(w, "> %s %s\n", c.ToolName, summary)
+		case llm.ContentTypeToolResult:
+			if c.ToolError {
+				for _, r := range c.ToolResult {
+					fmt.Fprintf(w, "  tool failed: %s\n", strings.TrimSpace(r.Text))
+				}
+			}
+		}
+	}
+}
+
+// runRecorder records a run as a conversation in the database.
+type runRecorder struct {
+	db             *db.DB
+	conversationID string
+}
+
+func newRunRecorder(ctx context.Context, database *db.DB, cwd, modelID, systemPrompt string) (*runRecorder, error) {
+	conversation, err := database.CreateConversation(ctx, nil, true, &cwd, &modelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conversation: %w", err)
+	}
+	rec := &runRecorder{db: database, conversationID: conversation.ConversationID}
+	if _, err := database.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID: rec.conversationID,
+		Type:           db.MessageTypeSystem,
+		LLMData: llm.Message{
+			Role:    llm.MessageRoleUser,
+			Content: []llm.Content{{Type: llm.ContentTypeText, Text: systemPrompt}},
+		},
+		UsageData: llm.Usage{},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store system prompt: %w", err)
+	}
+	return rec, nil
+}
+
+func (r *runRecorder) record(ctx context.Context, message llm.Message, usage llm.Usage) error {
+	messageType := db.MessageTypeAgent
+	switch {
+	case message.ErrorType != llm.ErrorTypeNone:
+		messageType = db.MessageTypeError
+	case message.Role == llm.MessageRoleUser:
+		messageType = db.MessageTypeUser
+	}
+	if _, err := r.db.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID:      r.conversationID,
+		Type:                messageType,
+		LLMData:             message,
+		UsageData:           usage,
+		DisplayData:         server.ExtractDisplayData(message),
+		ExcludedFromContext: message.ExcludedFromContext,
+	}); err != nil {
+		return fmt.Errorf("failed to create message: %w", err)
+	}
+	return r.db.QueriesTx(ctx, func(q *generated.Queries) error {
+		return q.UpdateConversationTimestamp(ctx, r.conversationID)
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/claudetool/permission"
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+	"shelley.exe.dev/server"
+)
+
+func TestRunAgent(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	llmManager := server.NewLLMServiceManager(&server.LLMConfig{Logger: logger})
+	run := func(opts runOptions, database *db.DB) (*runTranscript, error) {
+		t.Helper()
+		opts.Model = "predictable"
+		if opts.Cwd == "" {
+			opts.Cwd = t.TempDir()
+		}
+		return runAgent(context.Background(), opts, llmManager, database, claudetool.ToolSetConfig{}, permission.Policy{}, logger, io.Discard)
+	}
+
+	transcript, err := run(runOptions{Prompt: "echo: hello"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transcript.Result != "hello" || len(transcript.Messages) != 2 || transcript.ConversationID != "" {
+		t.Errorf("unexpected transcript %+v", transcript)
+	}
+
+	// A turn that calls a tool takes two LLM requests.
+	transcript, err = run(runOptions{Prompt: "bash: echo hi", MaxTurns: 1}, nil)
+	if !errors.Is(err, loop.ErrBudgetExceeded) || !errors.Is(err, errTurnLimit) {
+		t.Fatalf("expected the turn limit to stop the run, got %v", err)
+	}
+	if transcript.Result != "" || transcript.Error == "" {
+		t.Errorf("expected a failed transcript, got %+v", transcript)
+	}
+
+	database, err := db.New(db.Config{DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if err := database.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	transcript, err = run(runOptions{Prompt: "bash: echo saved", Save: true}, database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := database.ListMessages(context.Background(), transcript.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The system prompt, then the user's message, the tool call, its result, and the reply.
+	if len(messages) != 5 || messages[0].Type != string(db.MessageTypeSystem) || messages[1].Type != string(db.MessageTypeUser) {
+		t.Errorf("unexpected messages %+v", messages)
+	}
+}
+
+func TestRunAgentProjectPolicyCannotAllow(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	llmManager := server.NewLLMServiceManager(&server.LLMConfig{Logger: logger})
+	cwd := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(cwd, ".shelley"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	project := `{"default":"allow","rules":[{"action":"allow","tool":"bash"}]}`
+	if err := os.WriteFile(filepath.Join(cwd, permission.ProjectFile), []byte(project), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := runOptions{Prompt: "bash: echo hi", Model: "predictable", Cwd: cwd}
+	transcript, err := runAgent(context.Background(), opts, llmManager, nil, claudetool.ToolSetConfig{}, permission.Policy{Default: permission.Ask}, logger, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result *llm.Content
+	for _, msg := range transcript.Messages {
+		for i, c := range msg.Content {
+			if c.Type == llm.ContentTypeToolResult {
+				result = &msg.Content[i]
+			}
+		}
+	}
+	if result == nil || !result.ToolError || len(result.ToolResult) == 0 || !strings.Contains(result.ToolResult[0].Text, "permission denied") {
+		t.Errorf("expected the bash call to be denied, got %+v", result)
+	}
+}
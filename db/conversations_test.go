@@ -2,11 +2,14 @@ package db
 
 import (
 	"context"
+	"encoding/json"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
 	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
 )
 
 func TestConversationService_Create(t *testing.T) {
@@ -827,3 +830,277 @@ func TestUnarchivePreservesSortOrder(t *testing.T) {
 		}
 	}
 }
+
+func TestForkConversation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	source, err := db.CreateConversation(ctx, stringPtr("fork-source"), true, stringPtr("/tmp"), stringPtr("predictable"))
+	if err != nil {
+		t.Fatalf("Failed to create source conversation: %v", err)
+	}
+	var messages []*generated.Message
+	for i, typ := range []MessageType{MessageTypeUser, MessageTypeAgent, MessageTypeUser, MessageTypeAgent} {
+		msg, err := db.CreateMessage(ctx, CreateMessageParams{
+			ConversationID: source.ConversationID,
+			Type:           typ,
+			LLMData:        map[string]int{"n": i},
+			UsageData:      map[string]float64{"cost_usd": 0.5},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	var forks []*generated.Conversation
+	tests := []struct {
+		name           string
+		includeMessage bool
+		wantMessages   int
+	}{
+		{"including the message", true, 3},
+		{"before the message", false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fork, err := db.ForkConversation(ctx, ForkConversationParams{
+				SourceConversationID: source.ConversationID,
+				MessageID:            messages[2].MessageID,
+				IncludeMessage:       tt.includeMessage,
+				Cwd:                  source.Cwd,
+				Model:                source.Model,
+			})
+			if err != nil {
+				t.Fatalf("ForkConversation() error = %v", err)
+			}
+			forks = append(forks, fork)
+			if fork.ForkedFromConversationID == nil || *fork.ForkedFromConversationID != source.ConversationID {
+				t.Errorf("ForkedFromConversationID = %v, want %s", fork.ForkedFromConversationID, source.ConversationID)
+			}
+			if fork.ForkedFromMessageID == nil || *fork.ForkedFromMessageID != messages[2].MessageID {
+				t.Errorf("ForkedFromMessageID = %v, want %s", fork.ForkedFromMessageID, messages[2].MessageID)
+			}
+			if fork.ParentConversationID != nil || fork.Slug != nil || *fork.Model != "predictable" || *fork.Cwd != "/tmp" {
+				t.Errorf("unexpected fork: %+v", fork)
+			}
+
+			copied, err := db.ListMessages(ctx, fork.ConversationID)
+			if err != nil {
+				t.Fatalf("ListMessages() error = %v", err)
+			}
+			if len(copied) != tt.wantMessages {
+				t.Fatalf("expected %d copied messages, got %d", tt.wantMessages, len(copied))
+			}
+			for i, msg := range copied {
+				if msg.SequenceID != messages[i].SequenceID || *msg.LlmData != *messages[i].LlmData {
+					t.Errorf("message %d was not copied faithfully: %+v", i, msg)
+				}
+				if msg.MessageID == messages[i].MessageID {
+					t.Errorf("message %d kept its source ID", i)
+				}
+				if msg.UsageData != nil {
+					t.Errorf("message %d kept its usage data", i)
+				}
+			}
+		})
+	}
+
+	// A message from another conversation can't be a fork point.
+	other, err := db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	if _, err := db.ForkConversation(ctx, ForkConversationParams{
+		SourceConversationID: other.ConversationID,
+		MessageID:            messages[0].MessageID,
+	}); err == nil {
+		t.Error("expected error forking at a message from another conversation")
+	}
+
+	// Forks outlive their source.
+	if err := db.DeleteConversation(ctx, source.ConversationID); err != nil {
+		t.Fatalf("DeleteConversation() error = %v", err)
+	}
+	if _, err := db.GetConversationByID(ctx, forks[0].ConversationID); err != nil {
+		t.Errorf("fork was lost with its source: %v", err)
+	}
+
+	// A fork keeps the settings of its source, and a fork of a subagent
+	// keeps the sandbox and policy the subagent inherits from its parent.
+	parent, err := db.CreateConversation(ctx, nil, true, stringPtr("/tmp"), nil)
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	if err := db.SetConversationSandbox(ctx, parent.ConversationID, "none"); err != nil {
+		t.Fatalf("SetConversationSandbox() error = %v", err)
+	}
+	if err := db.SetConversationPermissions(ctx, parent.ConversationID, `{"default":"ask"}`); err != nil {
+		t.Fatalf("SetConversationPermissions() error = %v", err)
+	}
+	subagent, err := db.CreateSubagentConversation(ctx, "fork-subagent", parent.ConversationID, parent.Cwd)
+	if err != nil {
+		t.Fatalf("CreateSubagentConversation() error = %v", err)
+	}
+	if err := db.SetConversationThinkingLevel(ctx, subagent.ConversationID, "high"); err != nil {
+		t.Fatalf("SetConversationThinkingLevel() error = %v", err)
+	}
+	subagentMessage, err := db.CreateMessage(ctx, CreateMessageParams{
+		ConversationID: subagent.ConversationID,
+		Type:           MessageTypeUser,
+		LLMData:        map[string]int{"n": 0},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	fork, err := db.ForkConversation(ctx, ForkConversationParams{
+		SourceConversationID: subagent.ConversationID,
+		MessageID:            subagentMessage.MessageID,
+		IncludeMessage:       true,
+	})
+	if err != nil {
+		t.Fatalf("ForkConversation() error = %v", err)
+	}
+	if fork.ThinkingLevel == nil || *fork.ThinkingLevel != "high" {
+		t.Errorf("ThinkingLevel = %v, want high", fork.ThinkingLevel)
+	}
+	if stored, err := db.GetConversationByID(ctx, fork.ConversationID); err != nil || stored.ThinkingLevel == nil || *stored.ThinkingLevel != "high" {
+		t.Errorf("stored fork = %+v, %v, want thinking level high", stored, err)
+	}
+	if sandbox, err := db.GetConversationSandbox(ctx, fork.ConversationID); err != nil || sandbox == nil || sandbox.Mode != "none" {
+		t.Errorf("GetConversationSandbox() = %+v, %v, want none", sandbox, err)
+	}
+	if perms, err := db.GetConversationPermissions(ctx, fork.ConversationID); err != nil || perms == nil || perms.Policy != `{"default":"ask"}` {
+		t.Errorf("GetConversationPermissions() = %+v, %v, want the source's policy", perms, err)
+	}
+}
+
+// TestSearchIndexContentTypes ties the content types the search index SQL
+// matches by value (IndexMessageForSearch in query/messages.sql and the
+// backfill in schema/021-message-search.sql) to the llm constants. If it
+// fails, update the SQL along with a migration that rebuilds the index.
+func TestSearchIndexContentTypes(t *testing.T) {
+	for _, tt := range []struct {
+		typ  llm.ContentType
+		want int
+	}{
+		{llm.ContentTypeText, 2},
+		{llm.ContentTypeToolUse, 5},
+		{llm.ContentTypeToolResult, 6},
+	} {
+		if int(tt.typ) != tt.want {
+			t.Errorf("%v = %d, but the search index SQL uses %d", tt.typ, tt.typ, tt.want)
+		}
+	}
+}
+
+func TestSearchConversationsWithMessages(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	newConversation := func(slug string, messages ...llm.Message) string {
+		t.Helper()
+		conv, err := db.CreateConversation(ctx, stringPtr(slug), true, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create conversation: %v", err)
+		}
+		for _, msg := range messages {
+			typ := MessageTypeUser
+			if msg.Role == llm.MessageRoleAssistant {
+				typ = MessageTypeAgent
+			}
+			if _, err := db.CreateMessage(ctx, CreateMessageParams{ConversationID: conv.ConversationID, Type: typ, LLMData: msg}); err != nil {
+				t.Fatalf("Failed to create message: %v", err)
+			}
+		}
+		return conv.ConversationID
+	}
+	text := func(role llm.MessageRole, s string) llm.Message {
+		return llm.Message{Role: role, Content: []llm.Content{llm.StringContent(s)}}
+	}
+
+	newConversation("chat",
+		text(llm.MessageRoleUser, "please refactor the frobnicator"),
+		text(llm.MessageRoleAssistant, "The frobnicator is refactored."),
+	)
+	tools := newConversation("tools",
+		text(llm.MessageRoleUser, "list the files"),
+		llm.Message{Role: llm.MessageRoleAssistant, Content: []llm.Content{{
+			Type: llm.ContentTypeToolUse, ID: "t1", ToolName: "bash", ToolInput: json.RawMessage(`{"command":"ls /srv/quuxdir"}`),
+		}}},
+		llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{
+			Type: llm.ContentTypeToolResult, ToolUseID: "t1", ToolResult: []llm.Content{llm.StringContent("zorkfile.txt")},
+		}}},
+	)
+	// System prompts are not searchable.
+	system, err := db.CreateConversation(ctx, stringPtr("system"), true, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	if _, err := db.CreateMessage(ctx, CreateMessageParams{ConversationID: system.ConversationID, Type: MessageTypeSystem, LLMData: text(llm.MessageRoleUser, "frobnicator quuxdir")}); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	newConversation("frobnicator-notes")
+
+	tests := []struct {
+		query   string
+		want    []string // slugs, in order
+		wantSeq int64    // of the first result's match
+	}{
+		{"frobnicator", []string{"frobnicator-notes", "chat"}, 0},
+		{"refactor frobni", []string{"chat"}, 1},
+		{"quuxdir", []string{"tools"}, 2},
+		{"bash", []string{"tools"}, 2},
+		{"zorkfile", []string{"tools"}, 3},
+		{`"ls /srv`, []string{"tools"}, 2},
+		{"missing", nil, 0},
+		{"   ", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := db.SearchConversationsWithMessages(ctx, tt.query, 10, 0)
+			if err != nil {
+				t.Fatalf("SearchConversationsWithMessages() error = %v", err)
+			}
+			var slugs []string
+			for _, r := range results {
+				slugs = append(slugs, *r.Conversation.Slug)
+			}
+			if !slices.Equal(slugs, tt.want) {
+				t.Fatalf("got %v, want %v", slugs, tt.want)
+			}
+			if tt.wantSeq == 0 {
+				return
+			}
+			first := results[0]
+			if first.SequenceID == nil || *first.SequenceID != tt.wantSeq {
+				t.Errorf("match sequence ID = %v, want %d", first.SequenceID, tt.wantSeq)
+			}
+			if first.Snippet == nil || !strings.Contains(*first.Snippet, SearchSnippetStart) {
+				t.Errorf("snippet %v has no highlighted match", first.Snippet)
+			}
+		})
+	}
+
+	t.Run("deleted conversations leave the index", func(t *testing.T) {
+		if err := db.DeleteConversation(ctx, tools); err != nil {
+			t.Fatalf("DeleteConversation() error = %v", err)
+		}
+		var n int64
+		err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+			return rx.Conn().QueryRowContext(ctx, "SELECT count(*) FROM message_search WHERE conversation_id = ?", tools).Scan(&n)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("%d index rows left for deleted conversation", n)
+		}
+	})
+}
@@ -18,6 +18,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"shelley.exe.dev/db/generated"
@@ -317,23 +318,48 @@ func (db *DB) SearchConversations(ctx context.Context, query string, limit, offs
 	return conversations, err
 }
 
-// SearchConversationsWithMessages searches for conversations containing the query in slug or message content
-func (db *DB) SearchConversationsWithMessages(ctx context.Context, query string, limit, offset int64) ([]generated.Conversation, error) {
-	queryPtr := &query
-	var conversations []generated.Conversation
+// SearchConversationsWithMessages searches for conversations containing the query in slug or message content.
+// Message content is matched with the full-text index, so each query word must
+// appear in a message (the last one as a prefix). Each result carries the best
+// matching message's sequence ID and snippet, if any; see SearchSnippetStart.
+func (db *DB) SearchConversationsWithMessages(ctx context.Context, query string, limit, offset int64) ([]generated.SearchConversationsWithMessagesRow, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return []generated.SearchConversationsWithMessagesRow{}, nil
+	}
+	var results []generated.SearchConversationsWithMessagesRow
 	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
 		var err error
-		conversations, err = q.SearchConversationsWithMessages(ctx, generated.SearchConversationsWithMessagesParams{
-			Column1: queryPtr,
-			Column2: queryPtr,
-			Column3: queryPtr,
-			Limit:   limit,
-			Offset:  offset,
+		results, err = q.SearchConversationsWithMessages(ctx, generated.SearchConversationsWithMessagesParams{
+			Match:  match,
+			Query:  query,
+			Limit:  limit,
+			Offset: offset,
 		})
 		return err
 	})
-	return conversations, err
+	return results, err
+}
+
+// Search snippets wrap each match in SearchSnippetStart and SearchSnippetEnd.
+const (
+	SearchSnippetStart = "\x02"
+	SearchSnippetEnd   = "\x03"
+)
+
+// ftsQuery turns user input into an FTS5 query matching messages that
+// contain every word, treating the last word as a prefix. Quoting each word
+// keeps FTS5 operators and punctuation in the input from being interpreted.
+func ftsQuery(query string) string {
+	words := strings.Fields(query)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	if len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	return strings.Join(words, " ")
 }
 
 // UpdateConversationSlug updates the slug of a conversation
@@ -375,6 +401,22 @@ func (db *DB) UpdateConversationModel(ctx context.Context, conversationID, model
 	})
 }
 
+// SetConversationThinkingLevel sets a conversation's thinking level.
+// An empty level clears it, so the conversation uses its model's default.
+func (db *DB) SetConversationThinkingLevel(ctx context.Context, conversationID, level string) error {
+	var levelPtr *string
+	if level != "" {
+		levelPtr = &level
+	}
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SetConversationThinkingLevel(ctx, generated.SetConversationThinkingLevelParams{
+			ThinkingLevel:  levelPtr,
+			ConversationID: conversationID,
+		})
+	})
+}
+
 // Message methods (moved from MessageService)
 
 // MessageType represents the type of message
@@ -464,7 +506,10 @@ func (db *DB) CreateMessage(ctx context.Context, params CreateMessageParams) (*g
 			DisplayData:         displayDataJSON,
 			ExcludedFromContext: params.ExcludedFromContext,
 		})
-		return err
+		if err != nil {
+			return err
+		}
+		return q.IndexMessageForSearch(ctx, messageID)
 	})
 	return &message, err
 }
@@ -580,6 +625,279 @@ func (db *DB) UpdateMessageUserData(ctx context.Context, messageID string, userD
 	})
 }
 
+// ExcludeMessagesFromContextBefore marks the user, agent, and tool messages before
+// sequenceID as excluded from the LLM context. The messages remain visible in the UI.
+func (db *DB) ExcludeMessagesFromContextBefore(ctx context.Context, conversationID string, sequenceID int64) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.ExcludeMessagesFromContextBefore(ctx, generated.ExcludeMessagesFromContextBeforeParams{
+			ConversationID: conversationID,
+			SequenceID:     sequenceID,
+		})
+	})
+}
+
+// GetConversationCosts returns the cost of each conversation with usage on or
+// after since (a YYYY-MM-DD date), most expensive first.
+func (db *DB) GetConversationCosts(ctx context.Context, since string, limit int64) ([]generated.GetConversationCostsRow, error) {
+	var rows []generated.GetConversationCostsRow
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		rows, err = q.GetConversationCosts(ctx, generated.GetConversationCostsParams{
+			Since: since,
+			Limit: limit,
+		})
+		return err
+	})
+	return rows, err
+}
+
+// GetDailyCosts returns the total cost per UTC day on or after since (a YYYY-MM-DD date).
+func (db *DB) GetDailyCosts(ctx context.Context, since string) ([]generated.GetDailyCostsRow, error) {
+	var rows []generated.GetDailyCostsRow
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		rows, err = q.GetDailyCosts(ctx, since)
+		return err
+	})
+	return rows, err
+}
+
+// GetConversationBudget returns the budget override for a conversation, or nil if it has none.
+func (db *DB) GetConversationBudget(ctx context.Context, conversationID string) (*generated.ConversationBudget, error) {
+	var budget *generated.ConversationBudget
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		b, err := q.GetConversationBudget(ctx, conversationID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		budget = &b
+		return nil
+	})
+	return budget, err
+}
+
+// SetConversationBudget sets the budget override for a conversation.
+func (db *DB) SetConversationBudget(ctx context.Context, params generated.SetConversationBudgetParams) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SetConversationBudget(ctx, params)
+	})
+}
+
+// GetConversationPermissions returns the permission policy set for a conversation, or nil if it has none.
+func (db *DB) GetConversationPermissions(ctx context.Context, conversationID string) (*generated.ConversationPermission, error) {
+	var perms *generated.ConversationPermission
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		p, err := q.GetConversationPermissions(ctx, conversationID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		perms = &p
+		return nil
+	})
+	return perms, err
+}
+
+// SetConversationPermissions sets the permission policy for a conversation.
+func (db *DB) SetConversationPermissions(ctx context.Context, conversationID, policy string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SetConversationPermissions(ctx, generated.SetConversationPermissionsParams{
+			ConversationID: conversationID,
+			Policy:         policy,
+		})
+	})
+}
+
+// GetConversationSandbox returns the sandbox mode set for a conversation, or nil if it has none.
+func (db *DB) GetConversationSandbox(ctx context.Context, conversationID string) (*generated.ConversationSandbox, error) {
+	var sandbox *generated.ConversationSandbox
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		s, err := q.GetConversationSandbox(ctx, conversationID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		sandbox = &s
+		return nil
+	})
+	return sandbox, err
+}
+
+// SetConversationSandbox sets the sandbox mode for a conversation.
+func (db *DB) SetConversationSandbox(ctx context.Context, conversationID, mode string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SetConversationSandbox(ctx, generated.SetConversationSandboxParams{
+			ConversationID: conversationID,
+			Mode:           mode,
+		})
+	})
+}
+
+// GetConversationSpend returns the total cost, tokens and LLM turns used by a
+// conversation and its subagents.
+func (db *DB) GetConversationSpend(ctx context.Context, conversationID string) (generated.GetConversationSpendRow, error) {
+	var spend generated.GetConversationSpendRow
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		spend, err = q.GetConversationSpend(ctx, conversationID)
+		return err
+	})
+	return spend, err
+}
+
+// GetSpendSince returns the total cost, tokens and LLM turns used across all
+// conversations on or after since (a YYYY-MM-DD date).
+func (db *DB) GetSpendSince(ctx context.Context, since string) (generated.GetSpendSinceRow, error) {
+	var spend generated.GetSpendSinceRow
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		spend, err = q.GetSpendSince(ctx, since)
+		return err
+	})
+	return spend, err
+}
+
+// CreateCheckpoint records a checkpoint for a conversation. Messages with a
+// sequence ID below the checkpoint's predate it.
+func (db *DB) CreateCheckpoint(ctx context.Context, params generated.CreateCheckpointParams) (*generated.Checkpoint, error) {
+	var checkpoint generated.Checkpoint
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		checkpoint, err = q.CreateCheckpoint(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// GetCheckpoint returns a checkpoint by ID.
+func (db *DB) GetCheckpoint(ctx context.Context, checkpointID int64) (*generated.Checkpoint, error) {
+	var checkpoint generated.Checkpoint
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		checkpoint, err = q.GetCheckpoint(ctx, checkpointID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("checkpoint not found: %d", checkpointID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// GetLatestCheckpoint returns a conversation's most recent checkpoint, or nil if it has none.
+func (db *DB) GetLatestCheckpoint(ctx context.Context, conversationID string) (*generated.Checkpoint, error) {
+	var checkpoint *generated.Checkpoint
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		c, err := q.GetLatestCheckpoint(ctx, conversationID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		checkpoint = &c
+		return nil
+	})
+	return checkpoint, err
+}
+
+// GetFirstCheckpointAfter returns the earliest checkpoint taken after the
+// message with the given sequence ID, or nil if there is none.
+func (db *DB) GetFirstCheckpointAfter(ctx context.Context, conversationID string, sequenceID int64) (*generated.Checkpoint, error) {
+	var checkpoint *generated.Checkpoint
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		c, err := q.GetFirstCheckpointAfter(ctx, generated.GetFirstCheckpointAfterParams{
+			ConversationID: conversationID,
+			SequenceID:     sequenceID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		checkpoint = &c
+		return nil
+	})
+	return checkpoint, err
+}
+
+// ListCheckpoints returns a conversation's checkpoints, oldest first.
+func (db *DB) ListCheckpoints(ctx context.Context, conversationID string) ([]generated.Checkpoint, error) {
+	var checkpoints []generated.Checkpoint
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		checkpoints, err = q.ListCheckpoints(ctx, conversationID)
+		return err
+	})
+	return checkpoints, err
+}
+
+// AddCheckpointFile saves a file's prior contents to a checkpoint. Only the
+// first save of a path per checkpoint is kept.
+func (db *DB) AddCheckpointFile(ctx context.Context, params generated.AddCheckpointFileParams) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.AddCheckpointFile(ctx, params)
+	})
+}
+
+// ListCheckpointFilePaths returns the paths saved by each of a conversation's checkpoints.
+func (db *DB) ListCheckpointFilePaths(ctx context.Context, conversationID string) ([]generated.ListCheckpointFilePathsRow, error) {
+	var rows []generated.ListCheckpointFilePathsRow
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		rows, err = q.ListCheckpointFilePaths(ctx, conversationID)
+		return err
+	})
+	return rows, err
+}
+
+// ListCheckpointFilesFrom returns the files saved by a checkpoint and all
+// later checkpoints of the same conversation, oldest checkpoint first.
+func (db *DB) ListCheckpointFilesFrom(ctx context.Context, conversationID string, checkpointID int64) ([]generated.CheckpointFile, error) {
+	var files []generated.CheckpointFile
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		files, err = q.ListCheckpointFilesFrom(ctx, generated.ListCheckpointFilesFromParams{
+			ConversationID: conversationID,
+			CheckpointID:   checkpointID,
+		})
+		return err
+	})
+	return files, err
+}
+
 // Queries provides read-only access to generated queries within a read transaction
 func (db *DB) Queries(ctx context.Context, fn func(*generated.Queries) error) error {
 	return db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
@@ -696,6 +1014,130 @@ func (db *DB) GetSubagents(ctx context.Context, parentID string) ([]generated.Co
 	return conversations, err
 }
 
+// ForkConversationParams contains parameters for forking a conversation
+type ForkConversationParams struct {
+	SourceConversationID string
+	MessageID            string  // message the fork branches off at
+	IncludeMessage       bool    // if false, history stops just before MessageID
+	Cwd                  *string // working directory of the fork
+	Model                *string // model of the fork
+}
+
+// ForkConversation creates a new top-level conversation whose history is a
+// copy of the source conversation up to params.MessageID. Copied messages keep
+// their sequence IDs but not their usage data, so the source's spend isn't
+// counted twice.
+func (db *DB) ForkConversation(ctx context.Context, params ForkConversationParams) (*generated.Conversation, error) {
+	conversationID, err := generateConversationID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate conversation ID: %w", err)
+	}
+	var conversation generated.Conversation
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		at, err := q.GetMessage(ctx, params.MessageID)
+		if err != nil {
+			return fmt.Errorf("failed to get message: %w", err)
+		}
+		if at.ConversationID != params.SourceConversationID {
+			return fmt.Errorf("message %s is not in conversation %s", params.MessageID, params.SourceConversationID)
+		}
+		messages, err := q.ListMessages(ctx, params.SourceConversationID)
+		if err != nil {
+			return fmt.Errorf("failed to list messages: %w", err)
+		}
+
+		conversation, err = q.CreateForkedConversation(ctx, generated.CreateForkedConversationParams{
+			ConversationID:           conversationID,
+			Cwd:                      params.Cwd,
+			Model:                    params.Model,
+			ForkedFromConversationID: &params.SourceConversationID,
+			ForkedFromMessageID:      &params.MessageID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create conversation: %w", err)
+		}
+		source, err := q.GetConversation(ctx, params.SourceConversationID)
+		if err != nil {
+			return fmt.Errorf("failed to get conversation: %w", err)
+		}
+		if err := copyConversationSettings(ctx, q, source, conversationID); err != nil {
+			return err
+		}
+		conversation.ThinkingLevel = source.ThinkingLevel
+
+		for _, msg := range messages {
+			if msg.SequenceID > at.SequenceID || (msg.SequenceID == at.SequenceID && !params.IncludeMessage) {
+				break
+			}
+			copied, err := q.CreateMessage(ctx, generated.CreateMessageParams{
+				MessageID:           uuid.New().String(),
+				ConversationID:      conversationID,
+				SequenceID:          msg.SequenceID,
+				Type:                msg.Type,
+				LlmData:             msg.LlmData,
+				UserData:            msg.UserData,
+				DisplayData:         msg.DisplayData,
+				ExcludedFromContext: msg.ExcludedFromContext,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to copy message: %w", err)
+			}
+			if err := q.IndexMessageForSearch(ctx, copied.MessageID); err != nil {
+				return fmt.Errorf("failed to index message: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// copyConversationSettings gives a fork the sandbox mode, permission policy,
+// and thinking level of the conversation it was forked from. A fork has no
+// parent, so it also takes the sandbox mode and policy a subagent inherits.
+func copyConversationSettings(ctx context.Context, q *generated.Queries, source generated.Conversation, to string) error {
+	if err := q.SetConversationThinkingLevel(ctx, generated.SetConversationThinkingLevelParams{
+		ThinkingLevel:  source.ThinkingLevel,
+		ConversationID: to,
+	}); err != nil {
+		return fmt.Errorf("failed to copy thinking level: %w", err)
+	}
+	owners := []string{source.ConversationID}
+	if source.ParentConversationID != nil {
+		owners = append(owners, *source.ParentConversationID)
+	}
+	for _, owner := range owners {
+		sandbox, err := q.GetConversationSandbox(ctx, owner)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get sandbox: %w", err)
+		}
+		if err := q.SetConversationSandbox(ctx, generated.SetConversationSandboxParams{ConversationID: to, Mode: sandbox.Mode}); err != nil {
+			return fmt.Errorf("failed to copy sandbox: %w", err)
+		}
+		break
+	}
+	for _, owner := range owners {
+		permissions, err := q.GetConversationPermissions(ctx, owner)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get permissions: %w", err)
+		}
+		if err := q.SetConversationPermissions(ctx, generated.SetConversationPermissionsParams{ConversationID: to, Policy: permissions.Policy}); err != nil {
+			return fmt.Errorf("failed to copy permissions: %w", err)
+		}
+		break
+	}
+	return nil
+}
+
 // GetConversationBySlugAndParent retrieves a subagent conversation by slug and parent ID
 func (db *DB) GetConversationBySlugAndParent(ctx context.Context, slug, parentID string) (*generated.Conversation, error) {
 	var conversation generated.Conversation
@@ -1049,10 +1491,100 @@ func (db *DB) UpdateNotificationChannel(ctx context.Context, params generated.Up
 func (db *DB) DeleteNotificationChannel(ctx context.Context, channelID string) error {
 	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
 		q := generated.New(tx.Conn())
+		if err := q.DeleteNotificationDeliveriesForChannel(ctx, channelID); err != nil {
+			return err
+		}
 		return q.DeleteNotificationChannel(ctx, channelID)
 	})
 }
 
+func (db *DB) CreateNotificationDelivery(ctx context.Context, params generated.CreateNotificationDeliveryParams) (*generated.NotificationDelivery, error) {
+	var d generated.NotificationDelivery
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		d, err = q.CreateNotificationDelivery(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (db *DB) UpdateNotificationDelivery(ctx context.Context, params generated.UpdateNotificationDeliveryParams) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateNotificationDelivery(ctx, params)
+	})
+}
+
+func (db *DB) ListNotificationDeliveries(ctx context.Context, channelID string, limit int64) ([]generated.NotificationDelivery, error) {
+	var deliveries []generated.NotificationDelivery
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		deliveries, err = q.ListNotificationDeliveries(ctx, generated.ListNotificationDeliveriesParams{
+			ChannelID: channelID,
+			Limit:     limit,
+		})
+		return err
+	})
+	return deliveries, err
+}
+
+// DeleteOldNotificationDeliveries prunes deliveries older than 30 days.
+func (db *DB) DeleteOldNotificationDeliveries(ctx context.Context) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteOldNotificationDeliveries(ctx)
+	})
+}
+
+// FailPendingNotificationDeliveries marks deliveries left pending by an
+// earlier run of the server as failed.
+func (db *DB) FailPendingNotificationDeliveries(ctx context.Context) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.FailPendingNotificationDeliveries(ctx)
+	})
+}
+
+// GetMCPServers returns the MCP servers added through the API.
+func (db *DB) GetMCPServers(ctx context.Context) ([]generated.McpServer, error) {
+	var servers []generated.McpServer
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		servers, err = q.GetMCPServers(ctx)
+		return err
+	})
+	return servers, err
+}
+
+// UpsertMCPServer adds an MCP server or replaces the one with the same name.
+func (db *DB) UpsertMCPServer(ctx context.Context, params generated.UpsertMCPServerParams) (*generated.McpServer, error) {
+	var server generated.McpServer
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		server, err = q.UpsertMCPServer(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+// DeleteMCPServer removes an MCP server added through the API.
+func (db *DB) DeleteMCPServer(ctx context.Context, name string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteMCPServer(ctx, name)
+	})
+}
+
 // GetSetting retrieves a setting value by key
 // Returns empty string and nil error if the setting doesn't exist
 func (db *DB) GetSetting(ctx context.Context, key string) (string, error) {
@@ -1099,3 +1631,235 @@ func (db *DB) GetAllSettings(ctx context.Context) (map[string]string, error) {
 	}
 	return settings, nil
 }
+
+// ListScheduledTasks returns all scheduled tasks, oldest first.
+func (db *DB) ListScheduledTasks(ctx context.Context) ([]generated.ScheduledTask, error) {
+	var tasks []generated.ScheduledTask
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		tasks, err = q.ListScheduledTasks(ctx)
+		return err
+	})
+	return tasks, err
+}
+
+// ListDueScheduledTasks returns the enabled tasks due to run at or before now.
+func (db *DB) ListDueScheduledTasks(ctx context.Context, now time.Time) ([]generated.ScheduledTask, error) {
+	var tasks []generated.ScheduledTask
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		tasks, err = q.ListDueScheduledTasks(ctx, &now)
+		return err
+	})
+	return tasks, err
+}
+
+func (db *DB) GetScheduledTask(ctx context.Context, taskID string) (*generated.ScheduledTask, error) {
+	var task generated.ScheduledTask
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		task, err = q.GetScheduledTask(ctx, taskID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (db *DB) CreateScheduledTask(ctx context.Context, params generated.CreateScheduledTaskParams) (*generated.ScheduledTask, error) {
+	var task generated.ScheduledTask
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		task, err = q.CreateScheduledTask(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (db *DB) UpdateScheduledTask(ctx context.Context, params generated.UpdateScheduledTaskParams) (*generated.ScheduledTask, error) {
+	var task generated.ScheduledTask
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		task, err = q.UpdateScheduledTask(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// SetScheduledTaskRun records that a task ran at lastRunAt and is next due at nextRunAt.
+func (db *DB) SetScheduledTaskRun(ctx context.Context, taskID string, lastRunAt time.Time, nextRunAt *time.Time) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SetScheduledTaskRun(ctx, generated.SetScheduledTaskRunParams{
+			LastRunAt: &lastRunAt,
+			NextRunAt: nextRunAt,
+			TaskID:    taskID,
+		})
+	})
+}
+
+func (db *DB) DeleteScheduledTask(ctx context.Context, taskID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteScheduledTask(ctx, taskID)
+	})
+}
+
+// SetConversationScheduledTask links a conversation to the scheduled task that started it.
+func (db *DB) SetConversationScheduledTask(ctx context.Context, conversationID, taskID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SetConversationScheduledTask(ctx, generated.SetConversationScheduledTaskParams{
+			ScheduledTaskID: &taskID,
+			ConversationID:  conversationID,
+		})
+	})
+}
+
+// ListScheduledTaskConversations returns the conversations started by a
+// scheduled task, newest first.
+func (db *DB) ListScheduledTaskConversations(ctx context.Context, taskID string, limit int64) ([]generated.Conversation, error) {
+	var conversations []generated.Conversation
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		conversations, err = q.ListScheduledTaskConversations(ctx, generated.ListScheduledTaskConversationsParams{
+			ScheduledTaskID: &taskID,
+			Limit:           limit,
+		})
+		return err
+	})
+	return conversations, err
+}
+
+// GetAuthConfig returns the built-in authentication settings, or nil if no
+// password has been set.
+func (db *DB) GetAuthConfig(ctx context.Context) (*generated.AuthConfig, error) {
+	var config generated.AuthConfig
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		config, err = q.GetAuthConfig(ctx)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// SetAuthConfig stores the password hash and session signing secret.
+func (db *DB) SetAuthConfig(ctx context.Context, passwordHash, sessionSecret string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SetAuthConfig(ctx, generated.SetAuthConfigParams{
+			PasswordHash:  passwordHash,
+			SessionSecret: sessionSecret,
+		})
+	})
+}
+
+// CreateAuthSession records a new browser session or API token.
+func (db *DB) CreateAuthSession(ctx context.Context, params generated.CreateAuthSessionParams) (*generated.AuthSession, error) {
+	var session generated.AuthSession
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		session, err = q.CreateAuthSession(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetAuthSession returns a session or token by its ID.
+func (db *DB) GetAuthSession(ctx context.Context, sessionID string) (*generated.AuthSession, error) {
+	var session generated.AuthSession
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		session, err = q.GetAuthSession(ctx, sessionID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetAuthSessionByTokenHash returns the API token with the given hash.
+func (db *DB) GetAuthSessionByTokenHash(ctx context.Context, tokenHash string) (*generated.AuthSession, error) {
+	var session generated.AuthSession
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		session, err = q.GetAuthSessionByTokenHash(ctx, &tokenHash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListAuthSessions returns all sessions and API tokens, newest first.
+func (db *DB) ListAuthSessions(ctx context.Context) ([]generated.AuthSession, error) {
+	var sessions []generated.AuthSession
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		sessions, err = q.ListAuthSessions(ctx)
+		return err
+	})
+	return sessions, err
+}
+
+// TouchAuthSession records that a session or token was just used.
+func (db *DB) TouchAuthSession(ctx context.Context, sessionID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.TouchAuthSession(ctx, sessionID)
+	})
+}
+
+// DeleteAuthSession revokes a session or API token.
+func (db *DB) DeleteAuthSession(ctx context.Context, sessionID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteAuthSession(ctx, sessionID)
+	})
+}
+
+// DeleteOtherAuthSessions revokes every browser session except one. API
+// tokens are kept.
+func (db *DB) DeleteOtherAuthSessions(ctx context.Context, keepSessionID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteOtherAuthSessions(ctx, keepSessionID)
+	})
+}
+
+// DeleteExpiredAuthSessions removes sessions and tokens that expired before now.
+func (db *DB) DeleteExpiredAuthSessions(ctx context.Context, now time.Time) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteExpiredAuthSessions(ctx, &now)
+	})
+}
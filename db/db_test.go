@@ -631,3 +631,36 @@ func TestLLMRequestOpenAIStyle(t *testing.T) {
 		req2FullLen-req2StoredLen,
 		100.0*float64(req2FullLen-req2StoredLen)/float64(req2FullLen))
 }
+
+func TestFailPendingNotificationDeliveries(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	pending, err := db.CreateNotificationDelivery(ctx, generated.CreateNotificationDeliveryParams{ChannelID: "c", EventType: "agent_done"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivered, err := db.CreateNotificationDelivery(ctx, generated.CreateNotificationDeliveryParams{ChannelID: "c", EventType: "agent_done"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateNotificationDelivery(ctx, generated.UpdateNotificationDeliveryParams{DeliveryID: delivered.DeliveryID, Status: "delivered", Attempts: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.FailPendingNotificationDeliveries(ctx); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := db.ListNotificationDeliveries(ctx, "c", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := map[int64]string{}
+	for _, d := range deliveries {
+		status[d.DeliveryID] = d.Status
+	}
+	if status[pending.DeliveryID] != "failed" || status[delivered.DeliveryID] != "delivered" {
+		t.Errorf("expected only the pending delivery to fail, got %v", status)
+	}
+}
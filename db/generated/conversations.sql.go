@@ -13,7 +13,7 @@ const archiveConversation = `-- name: ArchiveConversation :one
 UPDATE conversations
 SET archived = TRUE
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level
 `
 
 func (q *Queries) ArchiveConversation(ctx context.Context, conversationID string) (Conversation, error) {
@@ -29,6 +29,10 @@ func (q *Queries) ArchiveConversation(ctx context.Context, conversationID string
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromMessageID,
+		&i.ScheduledTaskID,
+		&i.ThinkingLevel,
 	)
 	return i, err
 }
@@ -58,7 +62,7 @@ func (q *Queries) CountConversations(ctx context.Context) (int64, error) {
 const createConversation = `-- name: CreateConversation :one
 INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, model)
 VALUES (?, ?, ?, ?, ?)
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level
 `
 
 type CreateConversationParams struct {
@@ -88,6 +92,51 @@ func (q *Queries) CreateConversation(ctx context.Context, arg CreateConversation
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromMessageID,
+		&i.ScheduledTaskID,
+		&i.ThinkingLevel,
+	)
+	return i, err
+}
+
+const createForkedConversation = `-- name: CreateForkedConversation :one
+INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, model, forked_from_conversation_id, forked_from_message_id)
+VALUES (?, NULL, TRUE, ?, ?, ?, ?)
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level
+`
+
+type CreateForkedConversationParams struct {
+	ConversationID           string  `json:"conversation_id"`
+	Cwd                      *string `json:"cwd"`
+	Model                    *string `json:"model"`
+	ForkedFromConversationID *string `json:"forked_from_conversation_id"`
+	ForkedFromMessageID      *string `json:"forked_from_message_id"`
+}
+
+func (q *Queries) CreateForkedConversation(ctx context.Context, arg CreateForkedConversationParams) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, createForkedConversation,
+		arg.ConversationID,
+		arg.Cwd,
+		arg.Model,
+		arg.ForkedFromConversationID,
+		arg.ForkedFromMessageID,
+	)
+	var i Conversation
+	err := row.Scan(
+		&i.ConversationID,
+		&i.Slug,
+		&i.UserInitiated,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Cwd,
+		&i.Archived,
+		&i.ParentConversationID,
+		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromMessageID,
+		&i.ScheduledTaskID,
+		&i.ThinkingLevel,
 	)
 	return i, err
 }
@@ -95,7 +144,7 @@ func (q *Queries) CreateConversation(ctx context.Context, arg CreateConversation
 const createSubagentConversation = `-- name: CreateSubagentConversation :one
 INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, parent_conversation_id)
 VALUES (?, ?, FALSE, ?, ?)
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level
 `
 
 type CreateSubagentConversationParams struct {
@@ -123,6 +172,10 @@ func (q *Queries) CreateSubagentConversation(ctx context.Context, arg CreateSuba
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromMessageID,
+		&i.ScheduledTaskID,
+		&i.ThinkingLevel,
 	)
 	return i, err
 }
@@ -138,7 +191,7 @@ func (q *Queries) DeleteConversation(ctx context.Context, conversationID string)
 }
 
 const getConversation = `-- name: GetConversation :one
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level FROM conversations
 WHERE conversation_id = ?
 `
 
@@ -155,12 +208,16 @@ func (q *Queries) GetConversation(ctx context.Context, conversationID string) (C
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromMessageID,
+		&i.ScheduledTaskID,
+		&i.ThinkingLevel,
 	)
 	return i, err
 }
 
 const getConversationBySlug = `-- name: GetConversationBySlug :one
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level FROM conversations
 WHERE slug = ?
 `
 
@@ -177,12 +234,16 @@ func (q *Queries) GetConversationBySlug(ctx context.Context, slug *string) (Conv
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromMessageID,
+		&i.ScheduledTaskID,
+		&i.ThinkingLevel,
 	)
 	return i, err
 }
 
 const getConversationBySlugAndParent = `-- name: GetConversationBySlugAndParent :one
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level FROM conversations
 WHERE slug = ? AND parent_conversation_id = ?
 `
 
@@ -204,12 +265,16 @@ func (q *Queries) GetConversationBySlugAndParent(ctx context.Context, arg GetCon
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromMessageID,
+		&i.ScheduledTaskID,
+		&i.ThinkingLevel,
 	)
 	return i, err
 }
 
 const getSubagents = `-- name: GetSubagents :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level FROM conversations
 WHERE parent_conversation_id = ?
 ORDER BY created_at ASC
 `
@@ -233,6 +298,10 @@ func (q *Queries) GetSubagents(ctx context.Context, parentConversationID *string
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ForkedFromConversationID,
+			&i.ForkedFromMessageID,
+			&i.ScheduledTaskID,
+			&i.ThinkingLevel,
 		); err != nil {
 			return nil, err
 		}
@@ -248,7 +317,7 @@ func (q *Queries) GetSubagents(ctx context.Context, parentConversationID *string
 }
 
 const listArchivedConversations = `-- name: ListArchivedConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level FROM conversations
 WHERE archived = TRUE
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?
@@ -278,6 +347,10 @@ func (q *Queries) ListArchivedConversations(ctx context.Context, arg ListArchive
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ForkedFromConversationID,
+			&i.ForkedFromMessageID,
+			&i.ScheduledTaskID,
+			&i.ThinkingLevel,
 		); err != nil {
 			return nil, err
 		}
@@ -293,7 +366,7 @@ func (q *Queries) ListArchivedConversations(ctx context.Context, arg ListArchive
 }
 
 const listConversations = `-- name: ListConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level FROM conversations
 WHERE archived = FALSE AND parent_conversation_id IS NULL
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?
@@ -323,6 +396,59 @@ func (q *Queries) ListConversations(ctx context.Context, arg ListConversationsPa
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ForkedFromConversationID,
+			&i.ForkedFromMessageID,
+			&i.ScheduledTaskID,
+			&i.ThinkingLevel,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScheduledTaskConversations = `-- name: ListScheduledTaskConversations :many
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level FROM conversations
+WHERE scheduled_task_id = ?
+ORDER BY created_at DESC
+LIMIT ?
+`
+
+type ListScheduledTaskConversationsParams struct {
+	ScheduledTaskID *string `json:"scheduled_task_id"`
+	Limit           int64   `json:"limit"`
+}
+
+func (q *Queries) ListScheduledTaskConversations(ctx context.Context, arg ListScheduledTaskConversationsParams) ([]Conversation, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledTaskConversations, arg.ScheduledTaskID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Conversation{}
+	for rows.Next() {
+		var i Conversation
+		if err := rows.Scan(
+			&i.ConversationID,
+			&i.Slug,
+			&i.UserInitiated,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Cwd,
+			&i.Archived,
+			&i.ParentConversationID,
+			&i.Model,
+			&i.ForkedFromConversationID,
+			&i.ForkedFromMessageID,
+			&i.ScheduledTaskID,
+			&i.ThinkingLevel,
 		); err != nil {
 			return nil, err
 		}
@@ -338,7 +464,7 @@ func (q *Queries) ListConversations(ctx context.Context, arg ListConversationsPa
 }
 
 const searchArchivedConversations = `-- name: SearchArchivedConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level FROM conversations
 WHERE slug LIKE '%' || ? || '%' AND archived = TRUE
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?
@@ -369,6 +495,10 @@ func (q *Queries) SearchArchivedConversations(ctx context.Context, arg SearchArc
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ForkedFromConversationID,
+			&i.ForkedFromMessageID,
+			&i.ScheduledTaskID,
+			&i.ThinkingLevel,
 		); err != nil {
 			return nil, err
 		}
@@ -384,7 +514,7 @@ func (q *Queries) SearchArchivedConversations(ctx context.Context, arg SearchArc
 }
 
 const searchConversations = `-- name: SearchConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level FROM conversations
 WHERE slug LIKE '%' || ? || '%' AND archived = FALSE AND parent_conversation_id IS NULL
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?
@@ -415,6 +545,10 @@ func (q *Queries) SearchConversations(ctx context.Context, arg SearchConversatio
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ForkedFromConversationID,
+			&i.ForkedFromMessageID,
+			&i.ScheduledTaskID,
+			&i.ThinkingLevel,
 		); err != nil {
 			return nil, err
 		}
@@ -430,33 +564,48 @@ func (q *Queries) SearchConversations(ctx context.Context, arg SearchConversatio
 }
 
 const searchConversationsWithMessages = `-- name: SearchConversationsWithMessages :many
-SELECT DISTINCT c.conversation_id, c.slug, c.user_initiated, c.created_at, c.updated_at, c.cwd, c.archived, c.parent_conversation_id, c.model FROM conversations c
-LEFT JOIN messages m ON c.conversation_id = m.conversation_id AND m.type IN ('user', 'agent')
+WITH hits AS MATERIALIZED (
+    SELECT conversation_id, sequence_id, rank,
+        snippet(message_search, 0, char(2), char(3), '…', 16) AS snippet
+    FROM message_search
+    WHERE message_search MATCH CAST(?1 AS TEXT)
+),
+best AS (
+    SELECT conversation_id, sequence_id, snippet, rank FROM (
+        SELECT *, row_number() OVER (PARTITION BY conversation_id ORDER BY rank) AS n
+        FROM hits
+    )
+    WHERE n = 1
+)
+SELECT c.conversation_id, c.slug, c.user_initiated, c.created_at, c.updated_at, c.cwd, c.archived, c.parent_conversation_id, c.model, c.forked_from_conversation_id, c.forked_from_message_id, c.scheduled_task_id, c.thinking_level, b.sequence_id, b.snippet FROM conversations c
+LEFT JOIN best b ON b.conversation_id = c.conversation_id
 WHERE c.archived = FALSE
-  AND (
-    c.slug LIKE '%' || ? || '%'
-    OR json_extract(m.user_data, '$.text') LIKE '%' || ? || '%'
-    OR m.llm_data LIKE '%' || ? || '%'
-  )
-ORDER BY c.updated_at DESC
-LIMIT ? OFFSET ?
+  AND (b.conversation_id IS NOT NULL OR c.slug LIKE '%' || CAST(?2 AS TEXT) || '%')
+ORDER BY c.slug LIKE '%' || CAST(?2 AS TEXT) || '%' DESC, COALESCE(b.rank, 0), c.updated_at DESC
+LIMIT ?3 OFFSET ?4
 `
 
 type SearchConversationsWithMessagesParams struct {
-	Column1 *string `json:"column_1"`
-	Column2 *string `json:"column_2"`
-	Column3 *string `json:"column_3"`
-	Limit   int64   `json:"limit"`
-	Offset  int64   `json:"offset"`
+	Match  string `json:"match"`
+	Query  string `json:"query"`
+	Limit  int64  `json:"limit"`
+	Offset int64  `json:"offset"`
 }
 
-// Search conversations by slug OR message content (user messages and agent responses, not system prompts)
+type SearchConversationsWithMessagesRow struct {
+	Conversation Conversation `json:"conversation"`
+	SequenceID   *int64       `json:"sequence_id"`
+	Snippet      *string      `json:"snippet"`
+}
+
+// Search conversations by slug OR message content (text, tool inputs and tool results, not system prompts)
 // Includes both top-level conversations and subagent conversations
-func (q *Queries) SearchConversationsWithMessages(ctx context.Context, arg SearchConversationsWithMessagesParams) ([]Conversation, error) {
+// Slug matches come first, then conversations by the rank of their best matching message,
+// which is returned with a snippet whose matches are wrapped in char(2) and char(3).
+func (q *Queries) SearchConversationsWithMessages(ctx context.Context, arg SearchConversationsWithMessagesParams) ([]SearchConversationsWithMessagesRow, error) {
 	rows, err := q.db.QueryContext(ctx, searchConversationsWithMessages,
-		arg.Column1,
-		arg.Column2,
-		arg.Column3,
+		arg.Match,
+		arg.Query,
 		arg.Limit,
 		arg.Offset,
 	)
@@ -464,19 +613,25 @@ func (q *Queries) SearchConversationsWithMessages(ctx context.Context, arg Searc
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Conversation{}
+	items := []SearchConversationsWithMessagesRow{}
 	for rows.Next() {
-		var i Conversation
+		var i SearchConversationsWithMessagesRow
 		if err := rows.Scan(
-			&i.ConversationID,
-			&i.Slug,
-			&i.UserInitiated,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.Cwd,
-			&i.Archived,
-			&i.ParentConversationID,
-			&i.Model,
+			&i.Conversation.ConversationID,
+			&i.Conversation.Slug,
+			&i.Conversation.UserInitiated,
+			&i.Conversation.CreatedAt,
+			&i.Conversation.UpdatedAt,
+			&i.Conversation.Cwd,
+			&i.Conversation.Archived,
+			&i.Conversation.ParentConversationID,
+			&i.Conversation.Model,
+			&i.Conversation.ForkedFromConversationID,
+			&i.Conversation.ForkedFromMessageID,
+			&i.Conversation.ScheduledTaskID,
+			&i.Conversation.ThinkingLevel,
+			&i.SequenceID,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
@@ -491,11 +646,43 @@ func (q *Queries) SearchConversationsWithMessages(ctx context.Context, arg Searc
 	return items, nil
 }
 
+const setConversationScheduledTask = `-- name: SetConversationScheduledTask :exec
+UPDATE conversations
+SET scheduled_task_id = ?
+WHERE conversation_id = ?
+`
+
+type SetConversationScheduledTaskParams struct {
+	ScheduledTaskID *string `json:"scheduled_task_id"`
+	ConversationID  string  `json:"conversation_id"`
+}
+
+func (q *Queries) SetConversationScheduledTask(ctx context.Context, arg SetConversationScheduledTaskParams) error {
+	_, err := q.db.ExecContext(ctx, setConversationScheduledTask, arg.ScheduledTaskID, arg.ConversationID)
+	return err
+}
+
+const setConversationThinkingLevel = `-- name: SetConversationThinkingLevel :exec
+UPDATE conversations
+SET thinking_level = ?
+WHERE conversation_id = ?
+`
+
+type SetConversationThinkingLevelParams struct {
+	ThinkingLevel  *string `json:"thinking_level"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) SetConversationThinkingLevel(ctx context.Context, arg SetConversationThinkingLevelParams) error {
+	_, err := q.db.ExecContext(ctx, setConversationThinkingLevel, arg.ThinkingLevel, arg.ConversationID)
+	return err
+}
+
 const unarchiveConversation = `-- name: UnarchiveConversation :one
 UPDATE conversations
 SET archived = FALSE
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level
 `
 
 func (q *Queries) UnarchiveConversation(ctx context.Context, conversationID string) (Conversation, error) {
@@ -511,6 +698,10 @@ func (q *Queries) UnarchiveConversation(ctx context.Context, conversationID stri
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromMessageID,
+		&i.ScheduledTaskID,
+		&i.ThinkingLevel,
 	)
 	return i, err
 }
@@ -519,7 +710,7 @@ const updateConversationCwd = `-- name: UpdateConversationCwd :one
 UPDATE conversations
 SET cwd = ?, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level
 `
 
 type UpdateConversationCwdParams struct {
@@ -540,6 +731,10 @@ func (q *Queries) UpdateConversationCwd(ctx context.Context, arg UpdateConversat
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromMessageID,
+		&i.ScheduledTaskID,
+		&i.ThinkingLevel,
 	)
 	return i, err
 }
@@ -564,7 +759,7 @@ const updateConversationSlug = `-- name: UpdateConversationSlug :one
 UPDATE conversations
 SET slug = ?, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_message_id, scheduled_task_id, thinking_level
 `
 
 type UpdateConversationSlugParams struct {
@@ -585,6 +780,10 @@ func (q *Queries) UpdateConversationSlug(ctx context.Context, arg UpdateConversa
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromMessageID,
+		&i.ScheduledTaskID,
+		&i.ThinkingLevel,
 	)
 	return i, err
 }
@@ -104,6 +104,124 @@ func (q *Queries) DeleteMessage(ctx context.Context, messageID string) error {
 	return err
 }
 
+const excludeMessagesFromContextBefore = `-- name: ExcludeMessagesFromContextBefore :exec
+UPDATE messages SET excluded_from_context = TRUE
+WHERE conversation_id = ? AND sequence_id < ? AND type IN ('user', 'agent', 'tool')
+`
+
+type ExcludeMessagesFromContextBeforeParams struct {
+	ConversationID string `json:"conversation_id"`
+	SequenceID     int64  `json:"sequence_id"`
+}
+
+func (q *Queries) ExcludeMessagesFromContextBefore(ctx context.Context, arg ExcludeMessagesFromContextBeforeParams) error {
+	_, err := q.db.ExecContext(ctx, excludeMessagesFromContextBefore, arg.ConversationID, arg.SequenceID)
+	return err
+}
+
+const getConversationCosts = `-- name: GetConversationCosts :many
+SELECT m.conversation_id, c.slug, c.model,
+    CAST(COALESCE(SUM(json_extract(m.usage_data, '$.cost_usd')), 0) AS REAL) AS cost_usd,
+    CAST(COALESCE(SUM(json_extract(m.usage_data, '$.input_tokens') + json_extract(m.usage_data, '$.cache_creation_input_tokens') + json_extract(m.usage_data, '$.cache_read_input_tokens')), 0) AS INTEGER) AS input_tokens,
+    CAST(COALESCE(SUM(json_extract(m.usage_data, '$.output_tokens')), 0) AS INTEGER) AS output_tokens
+FROM messages m
+JOIN conversations c ON c.conversation_id = m.conversation_id
+WHERE m.usage_data IS NOT NULL AND date(m.created_at) >= CAST(?1 AS TEXT)
+GROUP BY m.conversation_id
+ORDER BY cost_usd DESC
+LIMIT ?2
+`
+
+type GetConversationCostsParams struct {
+	Since string `json:"since"`
+	Limit int64  `json:"limit"`
+}
+
+type GetConversationCostsRow struct {
+	ConversationID string  `json:"conversation_id"`
+	Slug           *string `json:"slug"`
+	Model          *string `json:"model"`
+	CostUsd        float64 `json:"cost_usd"`
+	InputTokens    int64   `json:"input_tokens"`
+	OutputTokens   int64   `json:"output_tokens"`
+}
+
+func (q *Queries) GetConversationCosts(ctx context.Context, arg GetConversationCostsParams) ([]GetConversationCostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getConversationCosts, arg.Since, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetConversationCostsRow{}
+	for rows.Next() {
+		var i GetConversationCostsRow
+		if err := rows.Scan(
+			&i.ConversationID,
+			&i.Slug,
+			&i.Model,
+			&i.CostUsd,
+			&i.InputTokens,
+			&i.OutputTokens,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDailyCosts = `-- name: GetDailyCosts :many
+SELECT CAST(date(created_at) AS TEXT) AS day,
+    CAST(COALESCE(SUM(json_extract(usage_data, '$.cost_usd')), 0) AS REAL) AS cost_usd,
+    CAST(COALESCE(SUM(json_extract(usage_data, '$.input_tokens') + json_extract(usage_data, '$.cache_creation_input_tokens') + json_extract(usage_data, '$.cache_read_input_tokens')), 0) AS INTEGER) AS input_tokens,
+    CAST(COALESCE(SUM(json_extract(usage_data, '$.output_tokens')), 0) AS INTEGER) AS output_tokens
+FROM messages
+WHERE usage_data IS NOT NULL AND date(created_at) >= CAST(?1 AS TEXT)
+GROUP BY day
+ORDER BY day ASC
+`
+
+type GetDailyCostsRow struct {
+	Day          string  `json:"day"`
+	CostUsd      float64 `json:"cost_usd"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+}
+
+func (q *Queries) GetDailyCosts(ctx context.Context, since string) ([]GetDailyCostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDailyCosts, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetDailyCostsRow{}
+	for rows.Next() {
+		var i GetDailyCostsRow
+		if err := rows.Scan(
+			&i.Day,
+			&i.CostUsd,
+			&i.InputTokens,
+			&i.OutputTokens,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getLatestMessage = `-- name: GetLatestMessage :one
 SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context FROM messages
 WHERE conversation_id = ?
@@ -165,6 +283,35 @@ func (q *Queries) GetNextSequenceID(ctx context.Context, conversationID string)
 	return column_1, err
 }
 
+const indexMessageForSearch = `-- name: IndexMessageForSearch :exec
+INSERT INTO message_search (rowid, body, conversation_id, sequence_id)
+SELECT message_rowid, body, conversation_id, sequence_id FROM (
+    SELECT m.rowid AS message_rowid, m.conversation_id, m.sequence_id, (
+        SELECT group_concat(CASE json_extract(c.value, '$.Type')
+            WHEN 2 THEN json_extract(c.value, '$.Text')
+            WHEN 5 THEN json_extract(c.value, '$.ToolName') || ' ' || json_extract(c.value, '$.ToolInput')
+            WHEN 6 THEN (
+                SELECT group_concat(json_extract(r.value, '$.Text'), char(10))
+                FROM json_each(c.value, '$.ToolResult') r
+                WHERE json_extract(r.value, '$.Type') = 2
+            )
+        END, char(10))
+        FROM json_each(m.llm_data, '$.Content') c
+    ) AS body
+    FROM messages m
+    WHERE m.message_id = ? AND m.type IN ('user', 'agent')
+)
+WHERE body != ''
+`
+
+// Add a message's text, tool inputs and tool results to message_search.
+// The numbers are llm.ContentType values (2 = text, 5 = tool_use, 6 = tool_result);
+// TestSearchIndexContentTypes checks that they still match.
+func (q *Queries) IndexMessageForSearch(ctx context.Context, messageID string) error {
+	_, err := q.db.ExecContext(ctx, indexMessageForSearch, messageID)
+	return err
+}
+
 const listMessages = `-- name: ListMessages :many
 SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context FROM messages
 WHERE conversation_id = ?
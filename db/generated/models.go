@@ -8,16 +8,74 @@ import (
 	"time"
 )
 
+type AuthConfig struct {
+	ID            int64     `json:"id"`
+	PasswordHash  string    `json:"password_hash"`
+	SessionSecret string    `json:"session_secret"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type AuthSession struct {
+	SessionID  string     `json:"session_id"`
+	Kind       string     `json:"kind"`
+	Name       string     `json:"name"`
+	TokenHash  *string    `json:"token_hash"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+type Checkpoint struct {
+	CheckpointID   int64     `json:"checkpoint_id"`
+	ConversationID string    `json:"conversation_id"`
+	SequenceID     int64     `json:"sequence_id"`
+	GitWorktree    *string   `json:"git_worktree"`
+	GitCommit      *string   `json:"git_commit"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type CheckpointFile struct {
+	CheckpointID int64  `json:"checkpoint_id"`
+	Path         string `json:"path"`
+	Existed      bool   `json:"existed"`
+	Content      []byte `json:"content"`
+	Mode         *int64 `json:"mode"`
+}
+
 type Conversation struct {
-	ConversationID       string    `json:"conversation_id"`
-	Slug                 *string   `json:"slug"`
-	UserInitiated        bool      `json:"user_initiated"`
-	CreatedAt            time.Time `json:"created_at"`
-	UpdatedAt            time.Time `json:"updated_at"`
-	Cwd                  *string   `json:"cwd"`
-	Archived             bool      `json:"archived"`
-	ParentConversationID *string   `json:"parent_conversation_id"`
-	Model                *string   `json:"model"`
+	ConversationID           string    `json:"conversation_id"`
+	Slug                     *string   `json:"slug"`
+	UserInitiated            bool      `json:"user_initiated"`
+	CreatedAt                time.Time `json:"created_at"`
+	UpdatedAt                time.Time `json:"updated_at"`
+	Cwd                      *string   `json:"cwd"`
+	Archived                 bool      `json:"archived"`
+	ParentConversationID     *string   `json:"parent_conversation_id"`
+	Model                    *string   `json:"model"`
+	ForkedFromConversationID *string   `json:"forked_from_conversation_id"`
+	ForkedFromMessageID      *string   `json:"forked_from_message_id"`
+	ScheduledTaskID          *string   `json:"scheduled_task_id"`
+	ThinkingLevel            *string   `json:"thinking_level"`
+}
+
+type ConversationBudget struct {
+	ConversationID string    `json:"conversation_id"`
+	MaxDollars     float64   `json:"max_dollars"`
+	MaxTokens      int64     `json:"max_tokens"`
+	MaxTurns       int64     `json:"max_turns"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type ConversationPermission struct {
+	ConversationID string    `json:"conversation_id"`
+	Policy         string    `json:"policy"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type ConversationSandbox struct {
+	ConversationID string    `json:"conversation_id"`
+	Mode           string    `json:"mode"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 type LlmRequest struct {
@@ -36,6 +94,14 @@ type LlmRequest struct {
 	PrefixLength    *int64    `json:"prefix_length"`
 }
 
+type McpServer struct {
+	Name      string    `json:"name"`
+	Enabled   int64     `json:"enabled"`
+	Config    string    `json:"config"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 type Message struct {
 	MessageID           string    `json:"message_id"`
 	ConversationID      string    `json:"conversation_id"`
@@ -56,16 +122,20 @@ type Migration struct {
 }
 
 type Model struct {
-	ModelID      string    `json:"model_id"`
-	DisplayName  string    `json:"display_name"`
-	ProviderType string    `json:"provider_type"`
-	Endpoint     string    `json:"endpoint"`
-	ApiKey       string    `json:"api_key"`
-	ModelName    string    `json:"model_name"`
-	MaxTokens    int64     `json:"max_tokens"`
-	Tags         string    `json:"tags"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ModelID                string    `json:"model_id"`
+	DisplayName            string    `json:"display_name"`
+	ProviderType           string    `json:"provider_type"`
+	Endpoint               string    `json:"endpoint"`
+	ApiKey                 string    `json:"api_key"`
+	ModelName              string    `json:"model_name"`
+	MaxTokens              int64     `json:"max_tokens"`
+	Tags                   string    `json:"tags"`
+	CreatedAt              time.Time `json:"created_at"`
+	UpdatedAt              time.Time `json:"updated_at"`
+	InputPricePerMtok      float64   `json:"input_price_per_mtok"`
+	OutputPricePerMtok     float64   `json:"output_price_per_mtok"`
+	CacheReadPricePerMtok  float64   `json:"cache_read_price_per_mtok"`
+	CacheWritePricePerMtok float64   `json:"cache_write_price_per_mtok"`
 }
 
 type NotificationChannel struct {
@@ -78,6 +148,33 @@ type NotificationChannel struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+type NotificationDelivery struct {
+	DeliveryID     int64     `json:"delivery_id"`
+	ChannelID      string    `json:"channel_id"`
+	EventType      string    `json:"event_type"`
+	ConversationID *string   `json:"conversation_id"`
+	Status         string    `json:"status"`
+	Attempts       int64     `json:"attempts"`
+	LastError      *string   `json:"last_error"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type ScheduledTask struct {
+	TaskID                string     `json:"task_id"`
+	Name                  string     `json:"name"`
+	Cron                  string     `json:"cron"`
+	Prompt                string     `json:"prompt"`
+	Model                 *string    `json:"model"`
+	Cwd                   *string    `json:"cwd"`
+	NotificationChannelID *string    `json:"notification_channel_id"`
+	Enabled               int64      `json:"enabled"`
+	LastRunAt             *time.Time `json:"last_run_at"`
+	NextRunAt             *time.Time `json:"next_run_at"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
 type Setting struct {
 	Key       string    `json:"key"`
 	Value     string    `json:"value"`
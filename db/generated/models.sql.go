@@ -10,20 +10,24 @@ import (
 )
 
 const createModel = `-- name: CreateModel :one
-INSERT INTO models (model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-RETURNING model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at
+INSERT INTO models (model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, input_price_per_mtok, output_price_per_mtok, cache_read_price_per_mtok, cache_write_price_per_mtok)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at, input_price_per_mtok, output_price_per_mtok, cache_read_price_per_mtok, cache_write_price_per_mtok
 `
 
 type CreateModelParams struct {
-	ModelID      string `json:"model_id"`
-	DisplayName  string `json:"display_name"`
-	ProviderType string `json:"provider_type"`
-	Endpoint     string `json:"endpoint"`
-	ApiKey       string `json:"api_key"`
-	ModelName    string `json:"model_name"`
-	MaxTokens    int64  `json:"max_tokens"`
-	Tags         string `json:"tags"`
+	ModelID                string  `json:"model_id"`
+	DisplayName            string  `json:"display_name"`
+	ProviderType           string  `json:"provider_type"`
+	Endpoint               string  `json:"endpoint"`
+	ApiKey                 string  `json:"api_key"`
+	ModelName              string  `json:"model_name"`
+	MaxTokens              int64   `json:"max_tokens"`
+	Tags                   string  `json:"tags"`
+	InputPricePerMtok      float64 `json:"input_price_per_mtok"`
+	OutputPricePerMtok     float64 `json:"output_price_per_mtok"`
+	CacheReadPricePerMtok  float64 `json:"cache_read_price_per_mtok"`
+	CacheWritePricePerMtok float64 `json:"cache_write_price_per_mtok"`
 }
 
 func (q *Queries) CreateModel(ctx context.Context, arg CreateModelParams) (Model, error) {
@@ -36,6 +40,10 @@ func (q *Queries) CreateModel(ctx context.Context, arg CreateModelParams) (Model
 		arg.ModelName,
 		arg.MaxTokens,
 		arg.Tags,
+		arg.InputPricePerMtok,
+		arg.OutputPricePerMtok,
+		arg.CacheReadPricePerMtok,
+		arg.CacheWritePricePerMtok,
 	)
 	var i Model
 	err := row.Scan(
@@ -49,6 +57,10 @@ func (q *Queries) CreateModel(ctx context.Context, arg CreateModelParams) (Model
 		&i.Tags,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.InputPricePerMtok,
+		&i.OutputPricePerMtok,
+		&i.CacheReadPricePerMtok,
+		&i.CacheWritePricePerMtok,
 	)
 	return i, err
 }
@@ -63,7 +75,7 @@ func (q *Queries) DeleteModel(ctx context.Context, modelID string) error {
 }
 
 const getModel = `-- name: GetModel :one
-SELECT model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at FROM models WHERE model_id = ?
+SELECT model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at, input_price_per_mtok, output_price_per_mtok, cache_read_price_per_mtok, cache_write_price_per_mtok FROM models WHERE model_id = ?
 `
 
 func (q *Queries) GetModel(ctx context.Context, modelID string) (Model, error) {
@@ -80,12 +92,16 @@ func (q *Queries) GetModel(ctx context.Context, modelID string) (Model, error) {
 		&i.Tags,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.InputPricePerMtok,
+		&i.OutputPricePerMtok,
+		&i.CacheReadPricePerMtok,
+		&i.CacheWritePricePerMtok,
 	)
 	return i, err
 }
 
 const getModels = `-- name: GetModels :many
-SELECT model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at FROM models ORDER BY created_at ASC
+SELECT model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at, input_price_per_mtok, output_price_per_mtok, cache_read_price_per_mtok, cache_write_price_per_mtok FROM models ORDER BY created_at ASC
 `
 
 func (q *Queries) GetModels(ctx context.Context) ([]Model, error) {
@@ -108,6 +124,10 @@ func (q *Queries) GetModels(ctx context.Context) ([]Model, error) {
 			&i.Tags,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.InputPricePerMtok,
+			&i.OutputPricePerMtok,
+			&i.CacheReadPricePerMtok,
+			&i.CacheWritePricePerMtok,
 		); err != nil {
 			return nil, err
 		}
@@ -131,20 +151,28 @@ SET display_name = ?,
     model_name = ?,
     max_tokens = ?,
     tags = ?,
+    input_price_per_mtok = ?,
+    output_price_per_mtok = ?,
+    cache_read_price_per_mtok = ?,
+    cache_write_price_per_mtok = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE model_id = ?
-RETURNING model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at
+RETURNING model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, created_at, updated_at, input_price_per_mtok, output_price_per_mtok, cache_read_price_per_mtok, cache_write_price_per_mtok
 `
 
 type UpdateModelParams struct {
-	DisplayName  string `json:"display_name"`
-	ProviderType string `json:"provider_type"`
-	Endpoint     string `json:"endpoint"`
-	ApiKey       string `json:"api_key"`
-	ModelName    string `json:"model_name"`
-	MaxTokens    int64  `json:"max_tokens"`
-	Tags         string `json:"tags"`
-	ModelID      string `json:"model_id"`
+	DisplayName            string  `json:"display_name"`
+	ProviderType           string  `json:"provider_type"`
+	Endpoint               string  `json:"endpoint"`
+	ApiKey                 string  `json:"api_key"`
+	ModelName              string  `json:"model_name"`
+	MaxTokens              int64   `json:"max_tokens"`
+	Tags                   string  `json:"tags"`
+	InputPricePerMtok      float64 `json:"input_price_per_mtok"`
+	OutputPricePerMtok     float64 `json:"output_price_per_mtok"`
+	CacheReadPricePerMtok  float64 `json:"cache_read_price_per_mtok"`
+	CacheWritePricePerMtok float64 `json:"cache_write_price_per_mtok"`
+	ModelID                string  `json:"model_id"`
 }
 
 func (q *Queries) UpdateModel(ctx context.Context, arg UpdateModelParams) (Model, error) {
@@ -156,6 +184,10 @@ func (q *Queries) UpdateModel(ctx context.Context, arg UpdateModelParams) (Model
 		arg.ModelName,
 		arg.MaxTokens,
 		arg.Tags,
+		arg.InputPricePerMtok,
+		arg.OutputPricePerMtok,
+		arg.CacheReadPricePerMtok,
+		arg.CacheWritePricePerMtok,
 		arg.ModelID,
 	)
 	var i Model
@@ -170,6 +202,10 @@ func (q *Queries) UpdateModel(ctx context.Context, arg UpdateModelParams) (Model
 		&i.Tags,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.InputPricePerMtok,
+		&i.OutputPricePerMtok,
+		&i.CacheReadPricePerMtok,
+		&i.CacheWritePricePerMtok,
 	)
 	return i, err
 }
@@ -0,0 +1,36 @@
+-- name: GetAuthConfig :one
+SELECT * FROM auth_config WHERE id = 1;
+
+-- name: SetAuthConfig :exec
+INSERT INTO auth_config (id, password_hash, session_secret)
+VALUES (1, ?, ?)
+ON CONFLICT(id) DO UPDATE SET
+    password_hash = excluded.password_hash,
+    session_secret = excluded.session_secret,
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: CreateAuthSession :one
+INSERT INTO auth_sessions (session_id, kind, name, token_hash, expires_at)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetAuthSession :one
+SELECT * FROM auth_sessions WHERE session_id = ?;
+
+-- name: GetAuthSessionByTokenHash :one
+SELECT * FROM auth_sessions WHERE token_hash = ?;
+
+-- name: ListAuthSessions :many
+SELECT * FROM auth_sessions ORDER BY created_at DESC;
+
+-- name: TouchAuthSession :exec
+UPDATE auth_sessions SET last_used_at = CURRENT_TIMESTAMP WHERE session_id = ?;
+
+-- name: DeleteAuthSession :exec
+DELETE FROM auth_sessions WHERE session_id = ?;
+
+-- name: DeleteOtherAuthSessions :exec
+DELETE FROM auth_sessions WHERE kind = 'session' AND session_id != ?;
+
+-- name: DeleteExpiredAuthSessions :exec
+DELETE FROM auth_sessions WHERE expires_at IS NOT NULL AND expires_at < ?;
@@ -0,0 +1,32 @@
+-- name: GetConversationBudget :one
+SELECT * FROM conversation_budgets
+WHERE conversation_id = ?;
+
+-- name: SetConversationBudget :exec
+INSERT INTO conversation_budgets (conversation_id, max_dollars, max_tokens, max_turns, updated_at)
+VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT(conversation_id) DO UPDATE SET
+    max_dollars = excluded.max_dollars,
+    max_tokens = excluded.max_tokens,
+    max_turns = excluded.max_turns,
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: GetConversationSpend :one
+WITH RECURSIVE tree(conversation_id) AS (
+    SELECT CAST(sqlc.arg(conversation_id) AS TEXT)
+    UNION ALL
+    SELECT c.conversation_id FROM conversations c
+    JOIN tree ON c.parent_conversation_id = tree.conversation_id
+)
+SELECT CAST(COALESCE(SUM(json_extract(usage_data, '$.cost_usd')), 0) AS REAL) AS cost_usd,
+    CAST(COALESCE(SUM(json_extract(usage_data, '$.input_tokens') + json_extract(usage_data, '$.cache_creation_input_tokens') + json_extract(usage_data, '$.cache_read_input_tokens') + json_extract(usage_data, '$.output_tokens')), 0) AS INTEGER) AS tokens,
+    CAST(COUNT(CASE WHEN json_extract(usage_data, '$.output_tokens') > 0 THEN 1 END) AS INTEGER) AS turns
+FROM messages
+WHERE conversation_id IN (SELECT conversation_id FROM tree) AND usage_data IS NOT NULL;
+
+-- name: GetSpendSince :one
+SELECT CAST(COALESCE(SUM(json_extract(usage_data, '$.cost_usd')), 0) AS REAL) AS cost_usd,
+    CAST(COALESCE(SUM(json_extract(usage_data, '$.input_tokens') + json_extract(usage_data, '$.cache_creation_input_tokens') + json_extract(usage_data, '$.cache_read_input_tokens') + json_extract(usage_data, '$.output_tokens')), 0) AS INTEGER) AS tokens,
+    CAST(COUNT(CASE WHEN json_extract(usage_data, '$.output_tokens') > 0 THEN 1 END) AS INTEGER) AS turns
+FROM messages
+WHERE usage_data IS NOT NULL AND date(created_at) >= CAST(sqlc.arg(since) AS TEXT);
@@ -0,0 +1,43 @@
+-- name: CreateCheckpoint :one
+INSERT INTO checkpoints (conversation_id, sequence_id, git_worktree, git_commit)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetCheckpoint :one
+SELECT * FROM checkpoints
+WHERE checkpoint_id = ?;
+
+-- name: GetLatestCheckpoint :one
+SELECT * FROM checkpoints
+WHERE conversation_id = ?
+ORDER BY checkpoint_id DESC
+LIMIT 1;
+
+-- name: GetFirstCheckpointAfter :one
+SELECT * FROM checkpoints
+WHERE conversation_id = ? AND sequence_id > ?
+ORDER BY checkpoint_id ASC
+LIMIT 1;
+
+-- name: ListCheckpoints :many
+SELECT * FROM checkpoints
+WHERE conversation_id = ?
+ORDER BY checkpoint_id ASC;
+
+-- name: AddCheckpointFile :exec
+INSERT INTO checkpoint_files (checkpoint_id, path, existed, content, mode)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(checkpoint_id, path) DO NOTHING;
+
+-- name: ListCheckpointFilePaths :many
+SELECT f.checkpoint_id, f.path FROM checkpoint_files f
+JOIN checkpoints c ON c.checkpoint_id = f.checkpoint_id
+WHERE c.conversation_id = ?
+ORDER BY f.checkpoint_id ASC, f.path ASC;
+
+-- name: ListCheckpointFilesFrom :many
+-- Files saved by a checkpoint and every later checkpoint of the same conversation.
+SELECT f.checkpoint_id, f.path, f.existed, f.content, f.mode FROM checkpoint_files f
+JOIN checkpoints c ON c.checkpoint_id = f.checkpoint_id
+WHERE c.conversation_id = ? AND f.checkpoint_id >= ?
+ORDER BY f.checkpoint_id ASC;
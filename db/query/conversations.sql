@@ -30,18 +30,29 @@ ORDER BY updated_at DESC
 LIMIT ? OFFSET ?;
 
 -- name: SearchConversationsWithMessages :many
--- Search conversations by slug OR message content (user messages and agent responses, not system prompts)
+-- Search conversations by slug OR message content (text, tool inputs and tool results, not system prompts)
 -- Includes both top-level conversations and subagent conversations
-SELECT DISTINCT c.* FROM conversations c
-LEFT JOIN messages m ON c.conversation_id = m.conversation_id AND m.type IN ('user', 'agent')
+-- Slug matches come first, then conversations by the rank of their best matching message,
+-- which is returned with a snippet whose matches are wrapped in char(2) and char(3).
+WITH hits AS MATERIALIZED (
+    SELECT conversation_id, sequence_id, rank,
+        snippet(message_search, 0, char(2), char(3), '…', 16) AS snippet
+    FROM message_search
+    WHERE message_search MATCH CAST(sqlc.arg(match) AS TEXT)
+),
+best AS (
+    SELECT conversation_id, sequence_id, snippet, rank FROM (
+        SELECT *, row_number() OVER (PARTITION BY conversation_id ORDER BY rank) AS n
+        FROM hits
+    )
+    WHERE n = 1
+)
+SELECT sqlc.embed(c), b.sequence_id, b.snippet FROM conversations c
+LEFT JOIN best b ON b.conversation_id = c.conversation_id
 WHERE c.archived = FALSE
-  AND (
-    c.slug LIKE '%' || ? || '%'
-    OR json_extract(m.user_data, '$.text') LIKE '%' || ? || '%'
-    OR m.llm_data LIKE '%' || ? || '%'
-  )
-ORDER BY c.updated_at DESC
-LIMIT ? OFFSET ?;
+  AND (b.conversation_id IS NOT NULL OR c.slug LIKE '%' || CAST(sqlc.arg(query) AS TEXT) || '%')
+ORDER BY c.slug LIKE '%' || CAST(sqlc.arg(query) AS TEXT) || '%' DESC, COALESCE(b.rank, 0), c.updated_at DESC
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
 
 -- name: SearchArchivedConversations :many
 SELECT * FROM conversations
@@ -107,3 +118,24 @@ WHERE slug = ? AND parent_conversation_id = ?;
 UPDATE conversations
 SET model = ?
 WHERE conversation_id = ? AND model IS NULL;
+
+-- name: CreateForkedConversation :one
+INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, model, forked_from_conversation_id, forked_from_message_id)
+VALUES (?, NULL, TRUE, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: SetConversationScheduledTask :exec
+UPDATE conversations
+SET scheduled_task_id = ?
+WHERE conversation_id = ?;
+
+-- name: ListScheduledTaskConversations :many
+SELECT * FROM conversations
+WHERE scheduled_task_id = ?
+ORDER BY created_at DESC
+LIMIT ?;
+
+-- name: SetConversationThinkingLevel :exec
+UPDATE conversations
+SET thinking_level = ?
+WHERE conversation_id = ?;
@@ -0,0 +1,14 @@
+-- name: GetMCPServers :many
+SELECT * FROM mcp_servers ORDER BY name ASC;
+
+-- name: UpsertMCPServer :one
+INSERT INTO mcp_servers (name, enabled, config)
+VALUES (?, ?, ?)
+ON CONFLICT(name) DO UPDATE SET
+    enabled = excluded.enabled,
+    config = excluded.config,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: DeleteMCPServer :exec
+DELETE FROM mcp_servers WHERE name = ?;
@@ -3,6 +3,29 @@ INSERT INTO messages (message_id, conversation_id, sequence_id, type, llm_data,
 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING *;
 
+-- name: IndexMessageForSearch :exec
+-- Add a message's text, tool inputs and tool results to message_search.
+-- The numbers are llm.ContentType values (2 = text, 5 = tool_use, 6 = tool_result);
+-- TestSearchIndexContentTypes checks that they still match.
+INSERT INTO message_search (rowid, body, conversation_id, sequence_id)
+SELECT message_rowid, body, conversation_id, sequence_id FROM (
+    SELECT m.rowid AS message_rowid, m.conversation_id, m.sequence_id, (
+        SELECT group_concat(CASE json_extract(c.value, '$.Type')
+            WHEN 2 THEN json_extract(c.value, '$.Text')
+            WHEN 5 THEN json_extract(c.value, '$.ToolName') || ' ' || json_extract(c.value, '$.ToolInput')
+            WHEN 6 THEN (
+                SELECT group_concat(json_extract(r.value, '$.Text'), char(10))
+                FROM json_each(c.value, '$.ToolResult') r
+                WHERE json_extract(r.value, '$.Type') = 2
+            )
+        END, char(10))
+        FROM json_each(m.llm_data, '$.Content') c
+    ) AS body
+    FROM messages m
+    WHERE m.message_id = ? AND m.type IN ('user', 'agent')
+)
+WHERE body != '';
+
 -- name: GetNextSequenceID :one
 SELECT COALESCE(MAX(sequence_id), 0) + 1 
 FROM messages 
@@ -62,3 +85,29 @@ ORDER BY sequence_id ASC;
 
 -- name: UpdateMessageUserData :exec
 UPDATE messages SET user_data = ? WHERE message_id = ?;
+
+-- name: ExcludeMessagesFromContextBefore :exec
+UPDATE messages SET excluded_from_context = TRUE
+WHERE conversation_id = ? AND sequence_id < ? AND type IN ('user', 'agent', 'tool');
+
+-- name: GetConversationCosts :many
+SELECT m.conversation_id, c.slug, c.model,
+    CAST(COALESCE(SUM(json_extract(m.usage_data, '$.cost_usd')), 0) AS REAL) AS cost_usd,
+    CAST(COALESCE(SUM(json_extract(m.usage_data, '$.input_tokens') + json_extract(m.usage_data, '$.cache_creation_input_tokens') + json_extract(m.usage_data, '$.cache_read_input_tokens')), 0) AS INTEGER) AS input_tokens,
+    CAST(COALESCE(SUM(json_extract(m.usage_data, '$.output_tokens')), 0) AS INTEGER) AS output_tokens
+FROM messages m
+JOIN conversations c ON c.conversation_id = m.conversation_id
+WHERE m.usage_data IS NOT NULL AND date(m.created_at) >= CAST(sqlc.arg(since) AS TEXT)
+GROUP BY m.conversation_id
+ORDER BY cost_usd DESC
+LIMIT sqlc.arg(limit);
+
+-- name: GetDailyCosts :many
+SELECT CAST(date(created_at) AS TEXT) AS day,
+    CAST(COALESCE(SUM(json_extract(usage_data, '$.cost_usd')), 0) AS REAL) AS cost_usd,
+    CAST(COALESCE(SUM(json_extract(usage_data, '$.input_tokens') + json_extract(usage_data, '$.cache_creation_input_tokens') + json_extract(usage_data, '$.cache_read_input_tokens')), 0) AS INTEGER) AS input_tokens,
+    CAST(COALESCE(SUM(json_extract(usage_data, '$.output_tokens')), 0) AS INTEGER) AS output_tokens
+FROM messages
+WHERE usage_data IS NOT NULL AND date(created_at) >= CAST(sqlc.arg(since) AS TEXT)
+GROUP BY day
+ORDER BY day ASC;
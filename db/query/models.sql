@@ -5,8 +5,8 @@ SELECT * FROM models ORDER BY created_at ASC;
 SELECT * FROM models WHERE model_id = ?;
 
 -- name: CreateModel :one
-INSERT INTO models (model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+INSERT INTO models (model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, input_price_per_mtok, output_price_per_mtok, cache_read_price_per_mtok, cache_write_price_per_mtok)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: UpdateModel :one
@@ -18,6 +18,10 @@ SET display_name = ?,
     model_name = ?,
     max_tokens = ?,
     tags = ?,
+    input_price_per_mtok = ?,
+    output_price_per_mtok = ?,
+    cache_read_price_per_mtok = ?,
+    cache_write_price_per_mtok = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE model_id = ?
 RETURNING *;
@@ -0,0 +1,33 @@
+-- name: CreateNotificationDelivery :one
+INSERT INTO notification_deliveries (channel_id, event_type, conversation_id)
+VALUES (?, ?, ?)
+RETURNING *;
+
+-- name: UpdateNotificationDelivery :exec
+UPDATE notification_deliveries
+SET status = ?,
+    attempts = ?,
+    last_error = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE delivery_id = ?;
+
+-- name: ListNotificationDeliveries :many
+SELECT * FROM notification_deliveries
+WHERE channel_id = ?
+ORDER BY delivery_id DESC
+LIMIT ?;
+
+-- name: DeleteNotificationDeliveriesForChannel :exec
+DELETE FROM notification_deliveries WHERE channel_id = ?;
+
+-- name: DeleteOldNotificationDeliveries :exec
+DELETE FROM notification_deliveries WHERE created_at < datetime('now', '-30 days');
+
+-- name: FailPendingNotificationDeliveries :exec
+-- Deliveries are retried in memory, so those still pending when the server
+-- stopped will never be sent.
+UPDATE notification_deliveries
+SET status = 'failed',
+    last_error = 'abandoned: server stopped before delivery',
+    updated_at = CURRENT_TIMESTAMP
+WHERE status = 'pending';
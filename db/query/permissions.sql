@@ -0,0 +1,10 @@
+-- name: GetConversationPermissions :one
+SELECT * FROM conversation_permissions
+WHERE conversation_id = ?;
+
+-- name: SetConversationPermissions :exec
+INSERT INTO conversation_permissions (conversation_id, policy, updated_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT(conversation_id) DO UPDATE SET
+    policy = excluded.policy,
+    updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,10 @@
+-- name: GetConversationSandbox :one
+SELECT * FROM conversation_sandboxes
+WHERE conversation_id = ?;
+
+-- name: SetConversationSandbox :exec
+INSERT INTO conversation_sandboxes (conversation_id, mode, updated_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT(conversation_id) DO UPDATE SET
+    mode = excluded.mode,
+    updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,38 @@
+-- name: CreateScheduledTask :one
+INSERT INTO scheduled_tasks (task_id, name, cron, prompt, model, cwd, notification_channel_id, enabled, next_run_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetScheduledTask :one
+SELECT * FROM scheduled_tasks WHERE task_id = ?;
+
+-- name: ListScheduledTasks :many
+SELECT * FROM scheduled_tasks ORDER BY created_at ASC;
+
+-- name: ListDueScheduledTasks :many
+SELECT * FROM scheduled_tasks
+WHERE enabled = 1 AND next_run_at IS NOT NULL AND next_run_at <= ?
+ORDER BY next_run_at ASC;
+
+-- name: UpdateScheduledTask :one
+UPDATE scheduled_tasks
+SET name = ?,
+    cron = ?,
+    prompt = ?,
+    model = ?,
+    cwd = ?,
+    notification_channel_id = ?,
+    enabled = ?,
+    next_run_at = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE task_id = ?
+RETURNING *;
+
+-- name: SetScheduledTaskRun :exec
+UPDATE scheduled_tasks
+SET last_run_at = ?,
+    next_run_at = ?
+WHERE task_id = ?;
+
+-- name: DeleteScheduledTask :exec
+DELETE FROM scheduled_tasks WHERE task_id = ?;
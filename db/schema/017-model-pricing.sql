@@ -0,0 +1,8 @@
+-- Add per-million-token prices to custom models.
+-- These are used to compute request cost when the provider (or gateway)
+-- doesn't report it. Zero means the price is unknown.
+
+ALTER TABLE models ADD COLUMN input_price_per_mtok REAL NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN output_price_per_mtok REAL NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN cache_read_price_per_mtok REAL NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN cache_write_price_per_mtok REAL NOT NULL DEFAULT 0;
@@ -0,0 +1,11 @@
+-- Per-conversation spending budgets.
+-- A row overrides the server's default conversation budget. Zero means unlimited.
+
+CREATE TABLE conversation_budgets (
+    conversation_id TEXT PRIMARY KEY,
+    max_dollars REAL NOT NULL DEFAULT 0,
+    max_tokens INTEGER NOT NULL DEFAULT 0,
+    max_turns INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
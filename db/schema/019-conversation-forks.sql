@@ -0,0 +1,9 @@
+-- Record where a forked conversation branched off.
+-- Forks are top-level conversations, unlike subagents (parent_conversation_id).
+-- These columns are not foreign keys so that deleting the source conversation
+-- leaves its forks intact.
+
+ALTER TABLE conversations ADD COLUMN forked_from_conversation_id TEXT;
+ALTER TABLE conversations ADD COLUMN forked_from_message_id TEXT;
+
+CREATE INDEX idx_conversations_forked_from ON conversations(forked_from_conversation_id) WHERE forked_from_conversation_id IS NOT NULL;
@@ -0,0 +1,28 @@
+-- Filesystem checkpoints taken at the start of each user turn.
+-- Messages with a sequence_id lower than a checkpoint's predate it.
+-- git_worktree and git_commit are set when the whole worktree was snapshotted
+-- (the commit is kept alive by a ref under refs/shelley/checkpoints/).
+
+CREATE TABLE checkpoints (
+    checkpoint_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation_id TEXT NOT NULL,
+    sequence_id INTEGER NOT NULL,
+    git_worktree TEXT,
+    git_commit TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_checkpoints_conversation ON checkpoints(conversation_id, checkpoint_id);
+
+-- The contents of files as they were before the agent first changed them
+-- during a checkpoint's turn. existed is FALSE for files the agent created.
+
+CREATE TABLE checkpoint_files (
+    checkpoint_id INTEGER NOT NULL,
+    path TEXT NOT NULL,
+    existed BOOLEAN NOT NULL,
+    content BLOB,
+    PRIMARY KEY (checkpoint_id, path),
+    FOREIGN KEY (checkpoint_id) REFERENCES checkpoints(checkpoint_id) ON DELETE CASCADE
+);
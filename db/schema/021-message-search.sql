@@ -0,0 +1,36 @@
+-- Full-text index over message content: user and agent text, tool inputs and
+-- tool results. Each row's rowid is the rowid of its message in messages.
+-- Rows are added by db.CreateMessage and removed along with their message.
+
+CREATE VIRTUAL TABLE message_search USING fts5(
+    body,
+    conversation_id UNINDEXED,
+    sequence_id UNINDEXED,
+    tokenize = 'unicode61'
+);
+
+CREATE TRIGGER messages_search_delete AFTER DELETE ON messages BEGIN
+    DELETE FROM message_search WHERE rowid = old.rowid;
+END;
+
+-- Index existing messages. This matches IndexMessageForSearch in
+-- db/query/messages.sql; the numbers are llm.ContentType values
+-- (2 = text, 5 = tool_use, 6 = tool_result).
+INSERT INTO message_search (rowid, body, conversation_id, sequence_id)
+SELECT message_rowid, body, conversation_id, sequence_id FROM (
+    SELECT m.rowid AS message_rowid, m.conversation_id, m.sequence_id, (
+        SELECT group_concat(CASE json_extract(c.value, '$.Type')
+            WHEN 2 THEN json_extract(c.value, '$.Text')
+            WHEN 5 THEN json_extract(c.value, '$.ToolName') || ' ' || json_extract(c.value, '$.ToolInput')
+            WHEN 6 THEN (
+                SELECT group_concat(json_extract(r.value, '$.Text'), char(10))
+                FROM json_each(c.value, '$.ToolResult') r
+                WHERE json_extract(r.value, '$.Type') = 2
+            )
+        END, char(10))
+        FROM json_each(m.llm_data, '$.Content') c
+    ) AS body
+    FROM messages m
+    WHERE m.type IN ('user', 'agent')
+)
+WHERE body != '';
@@ -0,0 +1,10 @@
+-- Per-conversation tool permission policies.
+-- policy is a JSON-encoded permission.Policy; its rules are combined with
+-- the project's and the server's.
+
+CREATE TABLE conversation_permissions (
+    conversation_id TEXT PRIMARY KEY,
+    policy TEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
@@ -0,0 +1,10 @@
+-- Per-conversation sandbox modes for the commands tools run.
+-- mode is a sandbox.Mode ("host" or "namespace"); it overrides the mode in
+-- the server's configuration, whose other sandbox settings still apply.
+
+CREATE TABLE conversation_sandboxes (
+    conversation_id TEXT PRIMARY KEY,
+    mode TEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
@@ -0,0 +1,11 @@
+-- MCP servers added through the API, in addition to those in shelley.json.
+-- config is a JSON-encoded mcp.ServerConfig without its name. A server here
+-- replaces one of the same name from shelley.json; a disabled one hides it.
+
+CREATE TABLE mcp_servers (
+    name TEXT PRIMARY KEY,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    config TEXT NOT NULL DEFAULT '{}',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
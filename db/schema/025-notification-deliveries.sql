@@ -0,0 +1,17 @@
+-- Delivery log for notifications. Each row is one event sent to one channel,
+-- updated as delivery is attempted: status is "pending", "delivered" or
+-- "failed" (after the last retry).
+
+CREATE TABLE notification_deliveries (
+    delivery_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    conversation_id TEXT,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_notification_deliveries_channel ON notification_deliveries(channel_id, delivery_id);
@@ -0,0 +1,25 @@
+-- Scheduled tasks run a prompt in a fresh conversation on a cron schedule.
+-- next_run_at is persisted, and advanced before each run starts, so that runs
+-- missed while the server was down happen once when it comes back, and a
+-- restart mid-run doesn't repeat it. Each run's conversation records its task
+-- in conversations.scheduled_task_id, which is not a foreign key so that
+-- deleting a task keeps its run history.
+
+CREATE TABLE scheduled_tasks (
+    task_id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    cron TEXT NOT NULL,
+    prompt TEXT NOT NULL,
+    model TEXT,
+    cwd TEXT,
+    notification_channel_id TEXT,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    last_run_at DATETIME,
+    next_run_at DATETIME,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE conversations ADD COLUMN scheduled_task_id TEXT;
+
+CREATE INDEX idx_conversations_scheduled_task ON conversations(scheduled_task_id) WHERE scheduled_task_id IS NOT NULL;
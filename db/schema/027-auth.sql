@@ -0,0 +1,22 @@
+-- Built-in authentication, used when the server runs with --auth.
+-- auth_config holds the single password hash and the secret that signs
+-- session cookies; it is kept out of the settings table, which the UI reads.
+-- auth_sessions holds browser sessions and API tokens, so either can be
+-- listed and revoked. Tokens are stored only as a SHA-256 hash.
+
+CREATE TABLE auth_config (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    password_hash TEXT NOT NULL,
+    session_secret TEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE auth_sessions (
+    session_id TEXT PRIMARY KEY,
+    kind TEXT NOT NULL CHECK (kind IN ('session', 'token')),
+    name TEXT NOT NULL DEFAULT '',
+    token_hash TEXT UNIQUE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME,
+    expires_at DATETIME
+);
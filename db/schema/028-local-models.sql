@@ -0,0 +1,28 @@
+-- Add the 'local' provider type for self-hosted OpenAI-compatible servers
+-- (Ollama, llama.cpp, vLLM), whose context window and tool support are
+-- probed. For these, max_tokens = 0 means use the probed context window.
+-- SQLite doesn't support ALTER TABLE to modify CHECK constraints, so the
+-- table is recreated.
+
+CREATE TABLE models_new (
+    model_id TEXT PRIMARY KEY,
+    display_name TEXT NOT NULL,
+    provider_type TEXT NOT NULL CHECK (provider_type IN ('anthropic', 'openai', 'openai-responses', 'gemini', 'local')),
+    endpoint TEXT NOT NULL,
+    api_key TEXT NOT NULL,
+    model_name TEXT NOT NULL,  -- The actual model name sent to the API (e.g., "claude-sonnet-4-5-20250514")
+    max_tokens INTEGER NOT NULL DEFAULT 200000,
+    tags TEXT NOT NULL DEFAULT '',  -- Comma-separated tags (e.g., "slug" for slug generation)
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    input_price_per_mtok REAL NOT NULL DEFAULT 0,
+    output_price_per_mtok REAL NOT NULL DEFAULT 0,
+    cache_read_price_per_mtok REAL NOT NULL DEFAULT 0,
+    cache_write_price_per_mtok REAL NOT NULL DEFAULT 0
+);
+
+INSERT INTO models_new SELECT * FROM models;
+
+DROP TABLE models;
+
+ALTER TABLE models_new RENAME TO models;
@@ -0,0 +1,4 @@
+-- Per-conversation thinking levels: "off", "minimal", "low", "medium" or
+-- "high". NULL uses the model's default.
+
+ALTER TABLE conversations ADD COLUMN thinking_level TEXT;
@@ -0,0 +1,4 @@
+-- The permission bits of files saved by checkpoints, so restoring them keeps
+-- executables executable. NULL for files saved before this was recorded.
+
+ALTER TABLE checkpoint_files ADD COLUMN mode INTEGER;
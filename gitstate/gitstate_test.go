@@ -250,3 +250,88 @@ func runGitOutput(t *testing.T, dir string, args ...string) string {
 	}
 	return string(output)
 }
+
+func TestSnapshotAndRestore(t *testing.T) {
+	tmpDir := t.TempDir()
+	runGit(t, tmpDir, "init")
+	runGit(t, tmpDir, "config", "user.email", "test@test.com")
+	runGit(t, tmpDir, "config", "user.name", "Test")
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	write(".gitignore", "ignored.txt\n")
+	write("committed.txt", "v1")
+	runGit(t, tmpDir, "add", ".")
+	runGit(t, tmpDir, "commit", "-m", "initial")
+
+	// Uncommitted and untracked changes are part of the snapshot.
+	write("committed.txt", "v2")
+	write("untracked.txt", "draft")
+	worktree, commit, err := Snapshot(tmpDir, "refs/shelley/test")
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	if worktree != tmpDir {
+		t.Errorf("worktree = %q, want %q", worktree, tmpDir)
+	}
+	if got := strings.TrimSpace(runGitOutput(t, tmpDir, "rev-parse", "refs/shelley/test")); got != commit {
+		t.Errorf("ref points at %q, want %q", got, commit)
+	}
+	if status := runGitOutput(t, tmpDir, "status", "--porcelain"); !strings.Contains(status, " M committed.txt") || !strings.Contains(status, "?? untracked.txt") {
+		t.Errorf("snapshot changed the index or worktree:\n%s", status)
+	}
+
+	write("committed.txt", "v3")
+	write("untracked.txt", "rewritten")
+	write("new.txt", "new")
+	write("ignored.txt", "keep me")
+	if err := os.Remove(filepath.Join(tmpDir, ".gitignore")); err != nil {
+		t.Fatal(err)
+	}
+	write(".gitignore", "ignored.txt\n")
+
+	changed, err := RestoreSnapshot(worktree, commit)
+	if err != nil {
+		t.Fatalf("RestoreSnapshot() error = %v", err)
+	}
+	if got := strings.Join(changed, ","); got != "committed.txt,new.txt,untracked.txt" {
+		t.Errorf("changed = %q", got)
+	}
+	if got := read("committed.txt"); got != "v2" {
+		t.Errorf("committed.txt = %q, want v2", got)
+	}
+	if got := read("untracked.txt"); got != "draft" {
+		t.Errorf("untracked.txt = %q, want draft", got)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected new.txt to be removed, got %v", err)
+	}
+	if got := read("ignored.txt"); got != "keep me" {
+		t.Errorf("ignored.txt = %q, want it left alone", got)
+	}
+}
+
+func TestSnapshotEmptyRepo(t *testing.T) {
+	tmpDir := t.TempDir()
+	runGit(t, tmpDir, "init")
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Snapshot(tmpDir, "refs/shelley/test"); err != nil {
+		t.Fatalf("Snapshot() of a repo without commits failed: %v", err)
+	}
+	if _, _, err := Snapshot(t.TempDir(), "refs/shelley/test"); err == nil {
+		t.Error("expected Snapshot() outside a repository to fail")
+	}
+}
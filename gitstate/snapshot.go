@@ -0,0 +1,146 @@
+package gitstate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Snapshot records the full contents of the worktree containing dir,
+// including uncommitted changes and untracked (but not ignored) files,
+// as a commit stored under ref. It uses a temporary index, so the
+// repository's own index, HEAD, and worktree are left untouched.
+// It returns the worktree root and the snapshot commit hash.
+func Snapshot(dir, ref string) (worktree, commit string, err error) {
+	worktree, err = worktreeRoot(dir)
+	if err != nil {
+		return "", "", err
+	}
+	tree, err := writeWorktreeTree(worktree)
+	if err != nil {
+		return "", "", err
+	}
+	args := []string{"commit-tree", tree, "-m", "shelley checkpoint"}
+	if head, err := gitOutput(worktree, nil, "rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		args = append(args, "-p", head)
+	}
+	// commit-tree needs an identity, which the repository may not configure.
+	env := []string{
+		"GIT_AUTHOR_NAME=Shelley", "GIT_AUTHOR_EMAIL=shelley@localhost",
+		"GIT_COMMITTER_NAME=Shelley", "GIT_COMMITTER_EMAIL=shelley@localhost",
+	}
+	commit, err = gitOutput(worktree, env, args...)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := gitOutput(worktree, nil, "update-ref", ref, commit); err != nil {
+		return "", "", err
+	}
+	return worktree, commit, nil
+}
+
+// RestoreSnapshot makes the worktree's files match a commit made by Snapshot:
+// files that differ are rewritten and files created since are removed.
+// Ignored files are left alone, as are HEAD and the index.
+// It returns the paths it changed, relative to the worktree root.
+func RestoreSnapshot(worktree, commit string) ([]string, error) {
+	current, err := writeWorktreeTree(worktree)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := gitOutput(worktree, nil, "diff-tree", "-r", "--name-only", "--no-renames", "-z", commit, current)
+	if err != nil {
+		return nil, err
+	}
+	added, err := gitOutput(worktree, nil, "diff-tree", "-r", "--name-only", "--no-renames", "-z", "--diff-filter=A", commit, current)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range splitNul(added) {
+		if err := os.Remove(filepath.Join(worktree, path)); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	index, cleanup, err := tempIndex()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	env := []string{"GIT_INDEX_FILE=" + index}
+	if _, err := gitOutput(worktree, env, "read-tree", commit); err != nil {
+		return nil, err
+	}
+	if _, err := gitOutput(worktree, env, "checkout-index", "--all", "--force"); err != nil {
+		return nil, err
+	}
+	return splitNul(changed), nil
+}
+
+// DeleteSnapshot removes a ref created by Snapshot, letting git collect the commit.
+func DeleteSnapshot(worktree, ref string) error {
+	_, err := gitOutput(worktree, nil, "update-ref", "-d", ref)
+	return err
+}
+
+// worktreeRoot returns the root of the worktree containing dir.
+func worktreeRoot(dir string) (string, error) {
+	return gitOutput(dir, nil, "rev-parse", "--show-toplevel")
+}
+
+// writeWorktreeTree writes a tree object for the worktree's current contents
+// using a temporary index, and returns its hash.
+func writeWorktreeTree(worktree string) (string, error) {
+	index, cleanup, err := tempIndex()
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	env := []string{"GIT_INDEX_FILE=" + index}
+	// Starting from HEAD lets git reuse cached stat information for unchanged files.
+	if _, err := gitOutput(worktree, nil, "rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		if _, err := gitOutput(worktree, env, "read-tree", "HEAD"); err != nil {
+			return "", err
+		}
+	}
+	if _, err := gitOutput(worktree, env, "add", "--all", "."); err != nil {
+		return "", err
+	}
+	return gitOutput(worktree, env, "write-tree")
+}
+
+// tempIndex returns the path of a fresh git index file and a function that removes it.
+func tempIndex() (string, func(), error) {
+	dir, err := os.MkdirTemp("", "shelley-index-")
+	if err != nil {
+		return "", nil, err
+	}
+	return filepath.Join(dir, "index"), func() { os.RemoveAll(dir) }, nil
+}
+
+// gitOutput runs git in dir with extra environment variables and returns its trimmed output.
+func gitOutput(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func splitNul(s string) []string {
+	var parts []string
+	for _, part := range strings.Split(s, "\x00") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
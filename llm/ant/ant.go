@@ -74,9 +74,12 @@ func ClaudeModelName(userName string) string {
 	}
 }
 
+// defaultContextWindow is the context window without the long context beta.
+const defaultContextWindow = 200000
+
 // TokenContextWindow returns the maximum token context window size for this service
 func (s *Service) TokenContextWindow() int {
-	return 200000
+	return cmp.Or(s.ContextWindow, defaultContextWindow)
 }
 
 // maxOutputTokens returns the maximum allowed output tokens for the configured model.
@@ -103,6 +106,11 @@ func (s *Service) MaxImageDimension() int {
 	return 2000
 }
 
+// MaxRequestBytes returns the Messages API's 32 MB request size limit.
+func (s *Service) MaxRequestBytes() int {
+	return 32 * 1024 * 1024
+}
+
 // Service provides Claude completions.
 // Fields should not be altered concurrently with calling any method on Service.
 type Service struct {
@@ -113,9 +121,15 @@ type Service struct {
 	MaxTokens     int               // 0 means use model-specific limit from modelMaxOutputTokens
 	ThinkingLevel llm.ThinkingLevel // thinking level (ThinkingLevelOff disables, default is ThinkingLevelMedium)
 	Backoff       []time.Duration   // retry backoff durations; defaults to {15s, 30s, 60s} if nil
+	Pricing       llm.Pricing       // used to compute cost when the gateway doesn't report it
+	ContextWindow int               // 0 means 200k; larger windows enable the long context beta
 }
 
-var _ llm.Service = (*Service)(nil)
+var (
+	_ llm.Service           = (*Service)(nil)
+	_ llm.StreamingService  = (*Service)(nil)
+	_ llm.ServerToolService = (*Service)(nil)
+)
 
 type content struct {
 	// https://docs.anthropic.com/en/api/messages
@@ -133,7 +147,11 @@ type content struct {
 	// is somewhat acceptable but hard to read.
 	Text      *string         `json:"text,omitempty"`
 	MediaType string          `json:"media_type,omitempty"` // for image
-	Source    json.RawMessage `json:"source,omitempty"`     // for image
+	Source    json.RawMessage `json:"source,omitempty"`     // for image and document
+	Title     string          `json:"title,omitempty"`      // for document
+	// Citations is {"enabled":true} on a document, and the list of
+	// sources cited on a text block.
+	Citations json.RawMessage `json:"citations,omitempty"`
 
 	// for thinking
 	Thinking  *string `json:"thinking,omitempty"`
@@ -175,6 +193,41 @@ type content struct {
 	EndTime   *time.Time `json:"-"`
 
 	CacheControl json.RawMessage `json:"cache_control,omitempty"`
+
+	// raw is the whole block, for server tool results, which are
+	// stored and sent back as they came.
+	raw json.RawMessage
+}
+
+// isServerToolResult reports whether typ is the type of a server tool's
+// result block, such as web_search_tool_result.
+func isServerToolResult(typ string) bool {
+	return strings.HasSuffix(typ, "_tool_result") && typ != "tool_result"
+}
+
+func (c content) MarshalJSON() ([]byte, error) {
+	if c.raw != nil {
+		return c.raw, nil
+	}
+	type plain content
+	return json.Marshal(plain(c))
+}
+
+func (c *content) UnmarshalJSON(data []byte) error {
+	var head struct {
+		Type      string `json:"type"`
+		ToolUseID string `json:"tool_use_id"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return err
+	}
+	if isServerToolResult(head.Type) {
+		// Their content doesn't fit our content; keep the block instead.
+		*c = content{Type: head.Type, ToolUseID: head.ToolUseID, raw: bytes.Clone(data)}
+		return nil
+	}
+	type plain content
+	return json.Unmarshal(data, (*plain)(c))
 }
 
 // message represents a message in the conversation.
@@ -198,6 +251,7 @@ type tool struct {
 	Type         string          `json:"type,omitempty"`
 	Description  string          `json:"description,omitempty"`
 	InputSchema  json.RawMessage `json:"input_schema,omitempty"`
+	MaxUses      int             `json:"max_uses,omitempty"` // for server tools
 	CacheControl json.RawMessage `json:"cache_control,omitempty"`
 }
 
@@ -299,6 +353,7 @@ var (
 		llm.ContentTypeRedactedThinking: "redacted_thinking",
 		llm.ContentTypeToolUse:          "tool_use",
 		llm.ContentTypeToolResult:       "tool_result",
+		llm.ContentTypeServerToolUse:    "server_tool_use",
 	}
 	toLLMContentType = inverted(fromLLMContentType)
 
@@ -315,9 +370,53 @@ var (
 		"end_turn":      llm.StopReasonEndTurn,
 		"tool_use":      llm.StopReasonToolUse,
 		"refusal":       llm.StopReasonRefusal,
+		"pause_turn":    llm.StopReasonPauseTurn,
 	}
 )
 
+// serverTools are the server tools Claude supports, by the names used in
+// configuration. See https://docs.anthropic.com/en/docs/agents-and-tools/tool-use/overview
+var serverTools = map[string]llm.Tool{
+	"web_search":     {Name: "web_search", Type: "web_search_20250305", MaxUses: 5},
+	"web_fetch":      {Name: "web_fetch", Type: "web_fetch_20250910", MaxUses: 5},
+	"code_execution": {Name: "code_execution", Type: "code_execution_20250825"},
+}
+
+// betaHeaders are the anthropic-beta headers the server tools need.
+var betaHeaders = map[string]string{
+	"web_fetch_20250910":      "web-fetch-2025-09-10",
+	"code_execution_20250825": "code-execution-2025-08-25",
+}
+
+// longContextBeta enables context windows over 200k tokens on models that support them.
+const longContextBeta = "context-1m-2025-08-07"
+
+// ServerTools returns the server tools among names, such as "web_search".
+func (s *Service) ServerTools(names []string) []*llm.Tool {
+	var tools []*llm.Tool
+	for _, name := range names {
+		if t, ok := serverTools[name]; ok {
+			t.Server = true
+			tools = append(tools, &t)
+		}
+	}
+	return tools
+}
+
+// betas returns the anthropic-beta header value for r.
+func (s *Service) betas(r *request) string {
+	var betas []string
+	if s.ContextWindow > defaultContextWindow {
+		betas = append(betas, longContextBeta)
+	}
+	for _, t := range r.Tools {
+		if b := betaHeaders[t.Type]; b != "" {
+			betas = append(betas, b)
+		}
+	}
+	return strings.Join(betas, ",")
+}
+
 func fromLLMCache(c bool) json.RawMessage {
 	if !c {
 		return nil
@@ -325,6 +424,19 @@ func fromLLMCache(c bool) json.RawMessage {
 	return json.RawMessage(`{"type":"ephemeral"}`)
 }
 
+// documentSource returns the source of a document block: the PDF itself,
+// or the text of other documents.
+func documentSource(c llm.Content) json.RawMessage {
+	var source map[string]string
+	if c.MediaType == "application/pdf" && c.Data != "" {
+		source = map[string]string{"type": "base64", "media_type": c.MediaType, "data": c.Data}
+	} else {
+		source = map[string]string{"type": "text", "media_type": "text/plain", "data": c.Text}
+	}
+	data, _ := json.Marshal(source)
+	return data
+}
+
 func fromLLMContent(c llm.Content) content {
 	var toolResult []content
 	if len(c.ToolResult) > 0 {
@@ -352,13 +464,19 @@ func fromLLMContent(c llm.Content) content {
 	// Set fields based on content type to avoid sending invalid fields
 	switch c.Type {
 	case llm.ContentTypeText:
-		// Images are represented as text with MediaType and Data
-		if c.MediaType != "" {
+		// Images and documents are represented as text with MediaType and Data
+		if c.IsDocument() {
+			d.Type = "document"
+			d.Title = c.Filename
+			d.Source = documentSource(c)
+			d.Citations = json.RawMessage(`{"enabled":true}`)
+		} else if c.MediaType != "" {
 			d.Type = "image"
 			d.Source = json.RawMessage(fmt.Sprintf(`{"type":"base64","media_type":"%s","data":"%s"}`,
 				c.MediaType, c.Data))
 		} else {
 			d.Text = &c.Text
+			d.Citations = c.Citations
 		}
 	case llm.ContentTypeThinking:
 		d.Thinking = &c.Thinking
@@ -366,7 +484,7 @@ func fromLLMContent(c llm.Content) content {
 	case llm.ContentTypeRedactedThinking:
 		d.Data = c.Data
 		d.Signature = c.Signature
-	case llm.ContentTypeToolUse:
+	case llm.ContentTypeToolUse, llm.ContentTypeServerToolUse:
 		d.ID = c.ID
 		d.ToolName = c.ToolName
 		d.ToolInput = c.ToolInput
@@ -378,6 +496,8 @@ func fromLLMContent(c llm.Content) content {
 		d.ToolUseID = c.ToolUseID
 		d.ToolError = c.ToolError
 		d.ToolResult = toolResult
+	case llm.ContentTypeServerToolResult:
+		d.raw = c.ServerToolResult
 	}
 
 	return d
@@ -421,6 +541,14 @@ func fromLLMToolChoice(tc *llm.ToolChoice) *toolChoice {
 }
 
 func fromLLMTool(t *llm.Tool) *tool {
+	if t.Server {
+		return &tool{
+			Name:         t.Name,
+			Type:         t.Type,
+			MaxUses:      t.MaxUses,
+			CacheControl: fromLLMCache(t.Cache),
+		}
+	}
 	return &tool{
 		Name:         t.Name,
 		Type:         t.Type,
@@ -459,8 +587,8 @@ func (s *Service) fromLLMRequest(r *llm.Request) *request {
 	}
 
 	// Enable extended thinking if a thinking level is set
-	if s.ThinkingLevel != llm.ThinkingLevelOff {
-		budget := s.ThinkingLevel.ThinkingBudgetTokens()
+	if level := r.ThinkingLevelOr(s.ThinkingLevel); level != llm.ThinkingLevelOff {
+		budget := level.ThinkingBudgetTokens()
 		// Ensure max_tokens > budget_tokens as required by Anthropic API
 		if maxTokens <= budget {
 			req.MaxTokens = budget + 1024
@@ -511,6 +639,13 @@ func toLLMContent(c content) llm.Content {
 		ToolError:  c.ToolError,
 		ToolResult: toolResultContents,
 	}
+	if hasCitations(c.Citations) {
+		ret.Citations = c.Citations
+	}
+	if isServerToolResult(c.Type) {
+		ret.Type = llm.ContentTypeServerToolResult
+		ret.ServerToolResult = c.raw
+	}
 	if c.Text != nil {
 		ret.Text = *c.Text
 	}
@@ -520,6 +655,9 @@ func toLLMContent(c content) llm.Content {
 	return ret
 }
 
+// toLLMResponse converts a response. Usage.ThinkingTokens is left unset:
+// Anthropic doesn't count thinking tokens separately, and the summarized
+// thinking text Claude 4 models return is much shorter than what is billed.
 func toLLMResponse(r *response) *llm.Response {
 	return &llm.Response{
 		ID:           r.ID,
@@ -567,13 +705,17 @@ type streamDelta struct {
 	// signature_delta
 	Signature string `json:"signature,omitempty"`
 
+	// citations_delta
+	Citation json.RawMessage `json:"citation,omitempty"`
+
 	// message_delta
 	StopReason   string  `json:"stop_reason,omitempty"`
 	StopSequence *string `json:"stop_sequence,omitempty"`
 }
 
 // parseSSEStream reads an SSE stream and assembles the complete response.
-func parseSSEStream(r io.Reader) (*response, error) {
+// If onDelta is non-nil, it is called with each text, thinking, and tool input delta as it arrives.
+func parseSSEStream(r io.Reader, onDelta func(llm.StreamDelta)) (*response, error) {
 	var (
 		resp        *response
 		contents    []content // indexed by content block index
@@ -619,8 +761,16 @@ func parseSSEStream(r io.Reader) (*response, error) {
 			block := *event.ContentBlock
 			// For tool_use blocks, the initial input is always empty {};
 			// clear it so delta accumulation starts fresh.
-			if block.Type == "tool_use" {
+			if block.Type == "tool_use" || block.Type == "server_tool_use" {
 				block.ToolInput = nil
+				if onDelta != nil {
+					onDelta(llm.StreamDelta{
+						Index:     event.Index,
+						Type:      toLLMContentType[block.Type],
+						ToolUseID: block.ID,
+						ToolName:  block.ToolName,
+					})
+				}
 			}
 			contents[event.Index] = block
 
@@ -639,16 +789,27 @@ func parseSSEStream(r io.Reader) (*response, error) {
 					c.Text = new(string)
 				}
 				*c.Text += delta.Text
+				if onDelta != nil {
+					onDelta(llm.StreamDelta{Index: event.Index, Type: llm.ContentTypeText, Text: delta.Text})
+				}
 			case "thinking_delta":
 				if c.Thinking == nil {
 					c.Thinking = new(string)
 				}
 				*c.Thinking += delta.Thinking
+				if onDelta != nil {
+					onDelta(llm.StreamDelta{Index: event.Index, Type: llm.ContentTypeThinking, Text: delta.Thinking})
+				}
 			case "input_json_delta":
 				// Accumulate raw JSON for tool_use input
 				c.ToolInput = append(c.ToolInput, []byte(delta.PartialJSON)...)
+				if onDelta != nil && delta.PartialJSON != "" {
+					onDelta(llm.StreamDelta{Index: event.Index, Type: toLLMContentType[c.Type], ToolInput: delta.PartialJSON})
+				}
 			case "signature_delta":
 				c.Signature += delta.Signature
+			case "citations_delta":
+				c.Citations = appendCitation(c.Citations, delta.Citation)
 			}
 
 		case "content_block_stop":
@@ -697,7 +858,7 @@ func parseSSEStream(r io.Reader) (*response, error) {
 	// Anthropic requires the "input" field on tool_use blocks, and
 	// json:"input,omitempty" omits nil, causing a 400 error.
 	for i := range contents {
-		if contents[i].Type == "tool_use" && contents[i].ToolInput == nil {
+		if (contents[i].Type == "tool_use" || contents[i].Type == "server_tool_use") && contents[i].ToolInput == nil {
 			contents[i].ToolInput = json.RawMessage("{}")
 		}
 	}
@@ -706,8 +867,33 @@ func parseSSEStream(r io.Reader) (*response, error) {
 	return resp, nil
 }
 
+// hasCitations reports whether citations is a non-empty list.
+// Streamed text blocks start with "citations": null.
+func hasCitations(citations json.RawMessage) bool {
+	var list []json.RawMessage
+	return json.Unmarshal(citations, &list) == nil && len(list) > 0
+}
+
+// appendCitation appends citation to the JSON array citations.
+func appendCitation(citations, citation json.RawMessage) json.RawMessage {
+	var list []json.RawMessage
+	json.Unmarshal(citations, &list)
+	list = append(list, citation)
+	data, _ := json.Marshal(list)
+	return data
+}
+
 // Do sends a streaming request to Anthropic and collects the full response.
 func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
+	return s.do(ctx, ir, nil)
+}
+
+// DoStream is like Do, but reports partial output to onDelta as it arrives.
+func (s *Service) DoStream(ctx context.Context, ir *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
+	return s.do(ctx, ir, onDelta)
+}
+
+func (s *Service) do(ctx context.Context, ir *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
 	startTime := time.Now()
 	request := s.fromLLMRequest(ir)
 	request.Stream = true
@@ -726,13 +912,14 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 	httpc := cmp.Or(s.HTTPC, http.DefaultClient)
 
 	// retry loop
-	var errs error // accumulated errors across all attempts
+	var errs error               // accumulated errors across all attempts
+	var retryAfter time.Duration // requested by the last error response
 	for attempts := 0; ; attempts++ {
 		if attempts > 10 {
-			return nil, fmt.Errorf("anthropic request failed after %d attempts: %w", attempts, errs)
+			return nil, &llm.Error{Kind: llm.ErrorKindTransient, Err: fmt.Errorf("anthropic request failed after %d attempts: %w", attempts, errs)}
 		}
 		if attempts > 0 {
-			sleep := backoff[min(attempts-1, len(backoff)-1)] + time.Duration(rand.Int64N(int64(time.Second)))
+			sleep := max(backoff[min(attempts-1, len(backoff)-1)]+time.Duration(rand.Int64N(int64(time.Second))), retryAfter)
 			slog.WarnContext(ctx, "anthropic request sleep before retry", "sleep", sleep, "attempts", attempts)
 			time.Sleep(sleep)
 		}
@@ -744,6 +931,9 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 		req.Header.Set("Content-Type", "application/json")
 		req.Header.Set("X-API-Key", s.APIKey)
 		req.Header.Set("Anthropic-Version", "2023-06-01")
+		if betas := s.betas(request); betas != "" {
+			req.Header.Set("Anthropic-Beta", betas)
+		}
 
 		resp, err := httpc.Do(req)
 		if err != nil {
@@ -757,15 +947,18 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 
 		switch {
 		case resp.StatusCode == http.StatusOK:
-			response, err := parseSSEStream(resp.Body)
+			response, err := parseSSEStream(resp.Body, onDelta)
 			resp.Body.Close()
 			if err != nil {
 				// Stream parse errors might be transient (connection reset, etc.)
 				errs = errors.Join(errs, fmt.Errorf("attempt %d at %s: %w", attempts+1, time.Now().Format(time.DateTime), err))
+				if onDelta != nil {
+					onDelta(llm.StreamDelta{Reset: true})
+				}
 				continue
 			}
 			// Calculate and set the cost_usd field
-			response.Usage.CostUSD = llm.CostUSDFromResponse(resp.Header)
+			response.Usage.CostUSD = s.Pricing.CostUSD(resp.Header, toLLMUsage(response.Usage))
 
 			endTime := time.Now()
 			result := toLLMResponse(response)
@@ -776,31 +969,28 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 			buf, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
 
-			switch {
-			case resp.StatusCode >= 500 && resp.StatusCode < 600:
-				// server error, retry
-				slog.WarnContext(ctx, "anthropic_request_failed", "response", string(buf), "status_code", resp.StatusCode, "url", url, "model", s.Model)
-				errs = errors.Join(errs, fmt.Errorf("attempt %d at %s: status %v (url=%s, model=%s): %s", attempts+1, time.Now().Format(time.DateTime), resp.Status, url, cmp.Or(s.Model, DefaultModel), buf))
-				continue
-			case resp.StatusCode == 429:
-				// rate limited, retry
-				slog.WarnContext(ctx, "anthropic_request_rate_limited", "response", string(buf), "url", url, "model", s.Model)
-				errs = errors.Join(errs, fmt.Errorf("attempt %d at %s: status %v (url=%s, model=%s): %s", attempts+1, time.Now().Format(time.DateTime), resp.Status, url, cmp.Or(s.Model, DefaultModel), buf))
-				continue
-			case resp.StatusCode >= 400 && resp.StatusCode < 500:
-				// some other 400, probably unrecoverable
-				slog.WarnContext(ctx, "anthropic_request_failed", "response", string(buf), "status_code", resp.StatusCode, "url", url, "model", s.Model)
-				return nil, errors.Join(errs, fmt.Errorf("attempt %d at %s: status %v (url=%s, model=%s): %s", attempts+1, time.Now().Format(time.DateTime), resp.Status, url, cmp.Or(s.Model, DefaultModel), buf))
-			default:
-				// ...retry, I guess?
-				slog.WarnContext(ctx, "anthropic_request_failed", "response", string(buf), "status_code", resp.StatusCode, "url", url, "model", s.Model)
-				errs = errors.Join(errs, fmt.Errorf("attempt %d at %s: status %v (url=%s, model=%s): %s", attempts+1, time.Now().Format(time.DateTime), resp.Status, url, cmp.Or(s.Model, DefaultModel), buf))
-				continue
+			// Errors the API responds with are classified. Retryable ones are
+			// retried (honoring Retry-After) unless the caller retries on its
+			// own; see llm.WithoutRetries.
+			slog.WarnContext(ctx, "anthropic_request_failed", "response", string(buf), "status_code", resp.StatusCode, "url", url, "model", s.Model)
+			err := fmt.Errorf("attempt %d at %s: status %v (url=%s, model=%s): %s", attempts+1, time.Now().Format(time.DateTime), resp.Status, url, cmp.Or(s.Model, DefaultModel), buf)
+			httpErr := llm.NewHTTPError(resp.StatusCode, resp.Header, isPromptTooLong(resp.StatusCode, buf), errors.Join(errs, err))
+			if !llm.ShouldRetry(ctx, httpErr) {
+				return nil, httpErr
 			}
+			errs = errors.Join(errs, err)
+			retryAfter = httpErr.RetryAfter
 		}
 	}
 }
 
+// isPromptTooLong reports whether an error response says the request exceeds
+// the model's context window, e.g.
+// {"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 208000 tokens > 200000 maximum"}}
+func isPromptTooLong(statusCode int, body []byte) bool {
+	return statusCode == http.StatusBadRequest && bytes.Contains(body, []byte("prompt is too long"))
+}
+
 // For debugging only, Claude can definitely handle the full patch tool.
 // func (s *Service) UseSimplifiedPatch() bool {
 // 	return true
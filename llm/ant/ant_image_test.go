@@ -76,3 +76,32 @@ func TestAnthropicImageToolResult(t *testing.T) {
 		t.Errorf("Expected data to be '/9j/4AAQSkZJRg...', got '%s'", source["data"])
 	}
 }
+
+func TestAnthropicDocuments(t *testing.T) {
+	pdf := fromLLMContent(llm.Content{
+		Type:      llm.ContentTypeText,
+		MediaType: "application/pdf",
+		Data:      "JVBERi0xLjQ=",
+		Text:      "extracted",
+		Filename:  "report.pdf",
+	})
+	if pdf.Type != "document" || pdf.Title != "report.pdf" || pdf.Text != nil {
+		t.Fatalf("unexpected PDF block: %+v", pdf)
+	}
+	var source map[string]string
+	json.Unmarshal(pdf.Source, &source)
+	if source["type"] != "base64" || source["media_type"] != "application/pdf" || source["data"] != "JVBERi0xLjQ=" {
+		t.Errorf("unexpected PDF source: %s", pdf.Source)
+	}
+
+	text := fromLLMContent(llm.Content{
+		Type:      llm.ContentTypeText,
+		MediaType: "text/plain",
+		Text:      "some \"notes\"",
+		Filename:  "notes.txt",
+	})
+	json.Unmarshal(text.Source, &source)
+	if text.Type != "document" || source["type"] != "text" || source["data"] != `some "notes"` {
+		t.Errorf("unexpected text block: %+v, source %s", text, text.Source)
+	}
+}
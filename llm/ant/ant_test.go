@@ -1224,7 +1224,7 @@ func TestToLLMContentWithNestedToolResults(t *testing.T) {
 
 func TestParseSSEStreamText(t *testing.T) {
 	stream := mockSSEResponse("msg_abc", Claude45Sonnet, "Hello!", 10, 5)
-	resp, err := parseSSEStream(strings.NewReader(stream))
+	resp, err := parseSSEStream(strings.NewReader(stream), nil)
 	if err != nil {
 		t.Fatalf("parseSSEStream() error = %v", err)
 	}
@@ -1265,7 +1265,7 @@ func TestParseSSEStreamMultipleDeltas(t *testing.T) {
 	b.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":3}}\n\n")
 	b.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
 
-	resp, err := parseSSEStream(strings.NewReader(b.String()))
+	resp, err := parseSSEStream(strings.NewReader(b.String()), nil)
 	if err != nil {
 		t.Fatalf("parseSSEStream() error = %v", err)
 	}
@@ -1292,7 +1292,7 @@ func TestParseSSEStreamToolUse(t *testing.T) {
 	b.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":25}}\n\n")
 	b.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
 
-	resp, err := parseSSEStream(strings.NewReader(b.String()))
+	resp, err := parseSSEStream(strings.NewReader(b.String()), nil)
 	if err != nil {
 		t.Fatalf("parseSSEStream() error = %v", err)
 	}
@@ -1329,6 +1329,44 @@ func TestParseSSEStreamToolUse(t *testing.T) {
 	}
 }
 
+func TestParseSSEStreamDeltas(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_d\",\"type\":\"message\",\"role\":\"assistant\",\"model\":\"test\",\"content\":[],\"stop_reason\":null,\"usage\":{\"input_tokens\":1,\"output_tokens\":0}}}\n\n")
+	b.WriteString("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"thinking\",\"thinking\":\"\"}}\n\n")
+	b.WriteString("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"hmm\"}}\n\n")
+	b.WriteString("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n")
+	b.WriteString("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n")
+	b.WriteString("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n")
+	b.WriteString("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":1}\n\n")
+	b.WriteString(`event: content_block_start` + "\n" + `data: {"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"bash","input":{}}}` + "\n\n")
+	b.WriteString(`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{}"}}` + "\n\n")
+	b.WriteString("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":2}\n\n")
+	b.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":3}}\n\n")
+	b.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+
+	var deltas []llm.StreamDelta
+	if _, err := parseSSEStream(strings.NewReader(b.String()), func(d llm.StreamDelta) {
+		deltas = append(deltas, d)
+	}); err != nil {
+		t.Fatalf("parseSSEStream() error = %v", err)
+	}
+
+	want := []llm.StreamDelta{
+		{Index: 0, Type: llm.ContentTypeThinking, Text: "hmm"},
+		{Index: 1, Type: llm.ContentTypeText, Text: "Hi"},
+		{Index: 2, Type: llm.ContentTypeToolUse, ToolUseID: "toolu_1", ToolName: "bash"},
+		{Index: 2, Type: llm.ContentTypeToolUse, ToolInput: "{}"},
+	}
+	if len(deltas) != len(want) {
+		t.Fatalf("got %d deltas, want %d: %+v", len(deltas), len(want), deltas)
+	}
+	for i := range want {
+		if deltas[i] != want[i] {
+			t.Errorf("delta[%d] = %+v, want %+v", i, deltas[i], want[i])
+		}
+	}
+}
+
 func TestParseSSEStreamToolUseEmptyInput(t *testing.T) {
 	// Reproduces a bug where tool_use with empty input {} gets ToolInput=nil
 	// after SSE parsing. Anthropic sends input_json_delta with partial_json:""
@@ -1343,7 +1381,7 @@ func TestParseSSEStreamToolUseEmptyInput(t *testing.T) {
 	b.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":10}}\n\n")
 	b.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
 
-	resp, err := parseSSEStream(strings.NewReader(b.String()))
+	resp, err := parseSSEStream(strings.NewReader(b.String()), nil)
 	if err != nil {
 		t.Fatalf("parseSSEStream() error = %v", err)
 	}
@@ -1381,7 +1419,7 @@ func TestParseSSEStreamThinking(t *testing.T) {
 	b.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":15}}\n\n")
 	b.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
 
-	resp, err := parseSSEStream(strings.NewReader(b.String()))
+	resp, err := parseSSEStream(strings.NewReader(b.String()), nil)
 	if err != nil {
 		t.Fatalf("parseSSEStream() error = %v", err)
 	}
@@ -1416,7 +1454,7 @@ func TestParseSSEStreamPing(t *testing.T) {
 	b.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":1}}\n\n")
 	b.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
 
-	resp, err := parseSSEStream(strings.NewReader(b.String()))
+	resp, err := parseSSEStream(strings.NewReader(b.String()), nil)
 	if err != nil {
 		t.Fatalf("parseSSEStream() error = %v", err)
 	}
@@ -1427,7 +1465,7 @@ func TestParseSSEStreamPing(t *testing.T) {
 
 func TestParseSSEStreamNoMessageStart(t *testing.T) {
 	stream := "event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n"
-	_, err := parseSSEStream(strings.NewReader(stream))
+	_, err := parseSSEStream(strings.NewReader(stream), nil)
 	if err == nil {
 		t.Fatal("expected error for missing message_start")
 	}
@@ -1443,7 +1481,7 @@ func TestParseSSEStreamIncomplete(t *testing.T) {
 	b.WriteString("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"thinking\",\"thinking\":\"\",\"signature\":\"\"}}\n\n")
 	b.WriteString("event: ping\ndata: {\"type\":\"ping\"}\n\n")
 
-	_, err := parseSSEStream(strings.NewReader(b.String()))
+	_, err := parseSSEStream(strings.NewReader(b.String()), nil)
 	if err == nil {
 		t.Fatal("expected error for incomplete stream (no message_stop)")
 	}
@@ -1457,7 +1495,7 @@ func TestParseSSEStreamError(t *testing.T) {
 	b.WriteString("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_err\",\"type\":\"message\",\"role\":\"assistant\",\"model\":\"test\",\"content\":[],\"stop_reason\":null,\"usage\":{\"input_tokens\":1,\"output_tokens\":0}}}\n\n")
 	b.WriteString(`event: error` + "\n" + `data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}` + "\n\n")
 
-	_, err := parseSSEStream(strings.NewReader(b.String()))
+	_, err := parseSSEStream(strings.NewReader(b.String()), nil)
 	if err == nil {
 		t.Fatal("expected error for stream error event")
 	}
@@ -1506,6 +1544,91 @@ func TestDoClientError(t *testing.T) {
 	}
 }
 
+// headerTransport returns a fixed error response with the given headers.
+type headerTransport struct {
+	statusCode int
+	body       string
+	header     http.Header
+	calls      int
+}
+
+func (m *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.calls++
+	return &http.Response{
+		StatusCode: m.statusCode,
+		Body:       io.NopCloser(strings.NewReader(m.body)),
+		Header:     m.header,
+	}, nil
+}
+
+func TestDoClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		header     http.Header
+		wantKind   llm.ErrorKind
+		wantRetry  time.Duration
+	}{
+		{
+			name:       "rate limit",
+			statusCode: 429,
+			body:       `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`,
+			header:     http.Header{"Retry-After": {"12"}},
+			wantKind:   llm.ErrorKindRateLimit,
+			wantRetry:  12 * time.Second,
+		},
+		{
+			name:       "overloaded",
+			statusCode: 529,
+			body:       `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			wantKind:   llm.ErrorKindOverloaded,
+		},
+		{
+			name:       "prompt too long",
+			statusCode: 400,
+			body:       `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`,
+			wantKind:   llm.ErrorKindContextTooLong,
+		},
+		{
+			name:       "auth",
+			statusCode: 401,
+			body:       `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`,
+			wantKind:   llm.ErrorKindAuth,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header
+			if header == nil {
+				header = http.Header{}
+			}
+			transport := &headerTransport{statusCode: tt.statusCode, body: tt.body, header: header}
+			s := &Service{APIKey: "test-key", HTTPC: &http.Client{Transport: transport}}
+			req := &llm.Request{
+				Messages: []llm.Message{{
+					Role:    llm.MessageRoleUser,
+					Content: []llm.Content{{Type: llm.ContentTypeText, Text: "Hello"}},
+				}},
+			}
+			_, err := s.Do(llm.WithoutRetries(context.Background()), req)
+			if err == nil {
+				t.Fatal("Do() error = nil, want error")
+			}
+			if kind := llm.ErrorKindOf(err); kind != tt.wantKind {
+				t.Errorf("ErrorKindOf() = %q, want %q (err: %v)", kind, tt.wantKind, err)
+			}
+			if d := llm.RetryAfterOf(err); d != tt.wantRetry {
+				t.Errorf("RetryAfterOf() = %v, want %v", d, tt.wantRetry)
+			}
+			// The caller opted out of retries.
+			if transport.calls != 1 {
+				t.Errorf("expected 1 attempt, got %d", transport.calls)
+			}
+		})
+	}
+}
+
 func TestServiceConfigDetails(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -1714,7 +1837,7 @@ data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":
 event: message_stop
 data: {"type":"message_stop"}
 `
-	resp, err := parseSSEStream(strings.NewReader(recorded))
+	resp, err := parseSSEStream(strings.NewReader(recorded), nil)
 	if err != nil {
 		t.Fatalf("parseSSEStream() error = %v", err)
 	}
@@ -1743,7 +1866,7 @@ func TestParseSSEStreamConnectionReset(t *testing.T) {
 		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n"
 
 	r := &errorAfterReader{data: []byte(partial), err: fmt.Errorf("connection reset by peer")}
-	_, err := parseSSEStream(r)
+	_, err := parseSSEStream(r, nil)
 	if err == nil {
 		t.Fatal("expected error for connection reset")
 	}
@@ -1785,7 +1908,7 @@ func mockTruncatedSSEResponse(id, model, text string, inputTokens uint64) string
 func TestParseSSEStreamTruncated(t *testing.T) {
 	// A stream that cuts off before message_delta (no stop_reason) should be an error.
 	stream := mockTruncatedSSEResponse("msg_trunc", Claude45Sonnet, "partial response", 100)
-	_, err := parseSSEStream(strings.NewReader(stream))
+	_, err := parseSSEStream(strings.NewReader(stream), nil)
 	if err == nil {
 		t.Fatal("expected error for truncated stream, got nil")
 	}
@@ -1802,7 +1925,7 @@ func TestParseSSEStreamTruncatedMidContentBlock(t *testing.T) {
 	b.WriteString("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n")
 	// Cut off here — no content_block_stop, no message_delta, no message_stop
 
-	_, err := parseSSEStream(strings.NewReader(b.String()))
+	_, err := parseSSEStream(strings.NewReader(b.String()), nil)
 	if err == nil {
 		t.Fatal("expected error for truncated stream, got nil")
 	}
@@ -1906,3 +2029,91 @@ func TestDoFailsAfterMaxRetriesOnTruncatedStream(t *testing.T) {
 		t.Errorf("expected 11 attempts, got %d", transport.calls)
 	}
 }
+
+func TestRequestThinkingLevel(t *testing.T) {
+	s := &Service{Model: Claude45Sonnet, ThinkingLevel: llm.ThinkingLevelMedium}
+	off, high := llm.ThinkingLevelOff, llm.ThinkingLevelHigh
+	msgs := []llm.Message{{Role: llm.MessageRoleUser, Content: []llm.Content{llm.StringContent("Hello")}}}
+
+	if got := s.fromLLMRequest(&llm.Request{Messages: msgs}); got.Thinking == nil || got.Thinking.BudgetTokens != 8192 {
+		t.Errorf("expected the service's medium budget, got %+v", got.Thinking)
+	}
+	if got := s.fromLLMRequest(&llm.Request{Messages: msgs, ThinkingLevel: &high}); got.Thinking == nil || got.Thinking.BudgetTokens != 16384 {
+		t.Errorf("expected the request's high budget, got %+v", got.Thinking)
+	}
+	if got := s.fromLLMRequest(&llm.Request{Messages: msgs, ThinkingLevel: &off}); got.Thinking != nil {
+		t.Errorf("expected thinking off, got %+v", got.Thinking)
+	}
+
+	resp := toLLMResponse(&response{
+		Content: []content{{Type: "thinking", Thinking: strp(strings.Repeat("hmm ", 100))}, {Type: "text", Text: strp("hi")}},
+		Usage:   usage{OutputTokens: 300},
+	})
+	if resp.Usage.ThinkingTokens != 0 {
+		t.Errorf("ThinkingTokens = %d, want 0 since Anthropic doesn't report it", resp.Usage.ThinkingTokens)
+	}
+}
+
+// overloadedTransport returns 529 Overloaded for the first N attempts, then
+// a complete response.
+type overloadedTransport struct {
+	overloadedCount int
+	completeBody    string
+	calls           int
+}
+
+func (m *overloadedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.calls++
+	if m.calls <= m.overloadedCount {
+		return &http.Response{
+			StatusCode: 529,
+			Body:       io.NopCloser(strings.NewReader(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)),
+			Header:     http.Header{},
+		}, nil
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(strings.NewReader(m.completeBody)),
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+	}, nil
+}
+
+func TestDoRetriesOverloaded(t *testing.T) {
+	req := &llm.Request{
+		Messages: []llm.Message{{
+			Role:    llm.MessageRoleUser,
+			Content: []llm.Content{{Type: llm.ContentTypeText, Text: "Hello"}},
+		}},
+	}
+
+	transport := &overloadedTransport{
+		overloadedCount: 2,
+		completeBody:    mockSSEResponse("msg_ok", Claude45Sonnet, "Hello, world!", 100, 50),
+	}
+	s := &Service{
+		APIKey:  "test-key",
+		HTTPC:   &http.Client{Transport: transport},
+		Backoff: []time.Duration{time.Millisecond},
+	}
+	resp, err := s.Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Do() error = %v, want nil (expected retry to succeed)", err)
+	}
+	if resp.Content[0].Text != "Hello, world!" {
+		t.Errorf("resp text = %q, want %q", resp.Content[0].Text, "Hello, world!")
+	}
+	if transport.calls != 3 {
+		t.Errorf("expected 3 attempts (2 overloaded + 1 success), got %d", transport.calls)
+	}
+
+	// Callers that retry on their own get the error right away.
+	transport = &overloadedTransport{overloadedCount: 1}
+	s.HTTPC = &http.Client{Transport: transport}
+	_, err = s.Do(llm.WithoutRetries(context.Background()), req)
+	if kind := llm.ErrorKindOf(err); kind != llm.ErrorKindOverloaded {
+		t.Errorf("ErrorKindOf() = %q, want %q (err: %v)", kind, llm.ErrorKindOverloaded, err)
+	}
+	if transport.calls != 1 {
+		t.Errorf("expected 1 attempt, got %d", transport.calls)
+	}
+}
@@ -0,0 +1,167 @@
+package ant
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestServerToolsRequest(t *testing.T) {
+	s := &Service{ContextWindow: 1000000}
+	tools := s.ServerTools([]string{"web_search", "code_execution", "no_such_tool"})
+	if len(tools) != 2 || !tools[0].Server || tools[0].Type != "web_search_20250305" || tools[0].MaxUses != 5 {
+		t.Fatalf("unexpected server tools: %+v", tools)
+	}
+	if s.TokenContextWindow() != 1000000 {
+		t.Errorf("TokenContextWindow() = %d, want 1000000", s.TokenContextWindow())
+	}
+
+	bash := &llm.Tool{Name: "bash", Description: "Runs a command.", InputSchema: json.RawMessage(`{"type":"object"}`)}
+	req := s.fromLLMRequest(&llm.Request{Tools: append([]*llm.Tool{bash}, tools...)})
+	data, err := json.Marshal(req.Tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"name":"bash","description":"Runs a command.","input_schema":{"type":"object"}},` +
+		`{"name":"web_search","type":"web_search_20250305","max_uses":5},` +
+		`{"name":"code_execution","type":"code_execution_20250825"}]`
+	if string(data) != want {
+		t.Errorf("tools = %s\nwant %s", data, want)
+	}
+	if got := s.betas(req); got != "context-1m-2025-08-07,code-execution-2025-08-25" {
+		t.Errorf("betas() = %q", got)
+	}
+	if got := (&Service{}).betas(&request{}); got != "" {
+		t.Errorf("betas() without long context or server tools = %q, want none", got)
+	}
+}
+
+func TestDocumentCitations(t *testing.T) {
+	doc := fromLLMContent(llm.Content{Type: llm.ContentTypeText, MediaType: "text/plain", Text: "notes", Filename: "notes.txt"})
+	if string(doc.Citations) != `{"enabled":true}` {
+		t.Errorf("document citations = %s, want enabled", doc.Citations)
+	}
+}
+
+// serverToolStream is a response that searches the web, cites a result, and
+// is paused by the server.
+const serverToolStream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_web","type":"message","role":"assistant","model":"test","content":[],"stop_reason":null,"usage":{"input_tokens":10,"output_tokens":0}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"server_tool_use","id":"srvtoolu_1","name":"web_search","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"query\":\"go 1.25\"}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"web_search_tool_result","tool_use_id":"srvtoolu_1","content":[{"type":"web_search_result","url":"https://go.dev/doc/go1.25","title":"Go 1.25 Release Notes","encrypted_content":"abc","page_age":"August 12, 2025"}]}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: content_block_start
+data: {"type":"content_block_start","index":2,"content_block":{"type":"text","text":"","citations":null}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":2,"delta":{"type":"citations_delta","citation":{"type":"web_search_result_location","url":"https://go.dev/doc/go1.25","title":"Go 1.25 Release Notes","encrypted_index":"xyz","cited_text":"Go 1.25 is released."}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"Go 1.25 is out."}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":2}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"pause_turn"},"usage":{"output_tokens":20}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func TestServerToolResponse(t *testing.T) {
+	var deltas []llm.StreamDelta
+	raw, err := parseSSEStream(strings.NewReader(serverToolStream), func(d llm.StreamDelta) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("parseSSEStream() error = %v", err)
+	}
+	resp := toLLMResponse(raw)
+
+	if resp.StopReason != llm.StopReasonPauseTurn || resp.ToMessage().EndOfTurn {
+		t.Errorf("expected a paused turn that doesn't end the turn, got %v", resp.StopReason)
+	}
+	if len(resp.Content) != 3 {
+		t.Fatalf("expected 3 content blocks, got %+v", resp.Content)
+	}
+	use, result, text := resp.Content[0], resp.Content[1], resp.Content[2]
+	if use.Type != llm.ContentTypeServerToolUse || use.ToolName != "web_search" || string(use.ToolInput) != `{"query":"go 1.25"}` {
+		t.Errorf("unexpected server tool use: %+v", use)
+	}
+	if len(deltas) == 0 || deltas[0].Type != llm.ContentTypeServerToolUse || deltas[0].ToolName != "web_search" {
+		t.Errorf("expected a server tool use delta first, got %+v", deltas)
+	}
+	if result.Type != llm.ContentTypeServerToolResult || result.ToolUseID != "srvtoolu_1" || !strings.Contains(string(result.ServerToolResult), `"encrypted_content":"abc"`) {
+		t.Errorf("unexpected server tool result: %+v", result)
+	}
+	var citations []map[string]string
+	if err := json.Unmarshal(text.Citations, &citations); err != nil || len(citations) != 1 || citations[0]["encrypted_index"] != "xyz" {
+		t.Errorf("unexpected citations %s: %v", text.Citations, err)
+	}
+
+	// The blocks survive being stored, and go back to the API as they came.
+	stored, err := json.Marshal(resp.ToMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg llm.Message
+	if err := json.Unmarshal(stored, &msg); err != nil {
+		t.Fatal(err)
+	}
+	sent, err := json.Marshal(fromLLMMessage(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`{"id":"srvtoolu_1","type":"server_tool_use","name":"web_search","input":{"query":"go 1.25"}}`,
+		`{"type":"web_search_tool_result","tool_use_id":"srvtoolu_1","content":[{"type":"web_search_result",`,
+		`"citations":[{"type":"web_search_result_location",`,
+	} {
+		if !strings.Contains(string(sent), want) {
+			t.Errorf("sent message %s\nmissing %s", sent, want)
+		}
+	}
+	if text := fromLLMContent(llm.Content{Type: llm.ContentTypeText, Text: "hi"}); text.Citations != nil {
+		t.Errorf("expected no citations on plain text, got %s", text.Citations)
+	}
+}
+
+func TestDoSendsBetaHeader(t *testing.T) {
+	var beta string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		beta = r.Header.Get("Anthropic-Beta")
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(mockSSEResponse("msg_1", Claude45Sonnet, "hi", 1, 1)))
+	}))
+	defer server.Close()
+
+	s := &Service{APIKey: "test-key", URL: server.URL, ContextWindow: 1000000}
+	req := &llm.Request{
+		Messages: []llm.Message{{Role: llm.MessageRoleUser, Content: []llm.Content{llm.StringContent("hi")}}},
+		Tools:    s.ServerTools([]string{"web_fetch"}),
+	}
+	if _, err := s.Do(context.Background(), req); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if beta != "context-1m-2025-08-07,web-fetch-2025-09-10" {
+		t.Errorf("Anthropic-Beta = %q", beta)
+	}
+}
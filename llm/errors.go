@@ -0,0 +1,152 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorKind classifies why an LLM request failed.
+type ErrorKind string
+
+const (
+	ErrorKindUnknown        ErrorKind = ""
+	ErrorKindRateLimit      ErrorKind = "rate_limit"       // HTTP 429
+	ErrorKindOverloaded     ErrorKind = "overloaded"       // HTTP 529 or 503: the provider is at capacity
+	ErrorKindContextTooLong ErrorKind = "context_too_long" // the request exceeds the model's context window
+	ErrorKindAuth           ErrorKind = "auth"             // HTTP 401 or 403
+	ErrorKindTransient      ErrorKind = "transient"        // other server errors and network failures
+)
+
+// Retryable reports whether a request that failed with this kind of error
+// may succeed if it is sent again unchanged.
+func (k ErrorKind) Retryable() bool {
+	switch k {
+	case ErrorKindRateLimit, ErrorKindOverloaded, ErrorKindTransient:
+		return true
+	}
+	return false
+}
+
+// Error is a classified error from an LLM provider.
+type Error struct {
+	Kind       ErrorKind
+	StatusCode int           // HTTP status code, or 0 if the request got no response
+	RetryAfter time.Duration // how long the provider asked us to wait, or 0
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKindOf returns the kind of the first *Error in err's chain,
+// or ErrorKindUnknown if there is none.
+func ErrorKindOf(err error) ErrorKind {
+	var llmErr *Error
+	if errors.As(err, &llmErr) {
+		return llmErr.Kind
+	}
+	return ErrorKindUnknown
+}
+
+// RetryAfterOf returns the RetryAfter of the first *Error in err's chain, or 0.
+func RetryAfterOf(err error) time.Duration {
+	var llmErr *Error
+	if errors.As(err, &llmErr) {
+		return llmErr.RetryAfter
+	}
+	return 0
+}
+
+// maxServiceRetryAfter is the longest Retry-After services wait out on their own.
+const maxServiceRetryAfter = time.Minute
+
+type withoutRetriesKey struct{}
+
+// WithoutRetries returns a context under which services return retryable
+// errors from the API right away instead of retrying them. It is for callers
+// that retry on their own, such as the agent loop, which also falls back to
+// other models.
+func WithoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutRetriesKey{}, true)
+}
+
+// ShouldRetry reports whether a service should retry a request that failed
+// with err itself: the error is retryable, any Retry-After is short, and ctx
+// isn't from WithoutRetries.
+func ShouldRetry(ctx context.Context, err error) bool {
+	if ctx.Value(withoutRetriesKey{}) != nil || ctx.Err() != nil {
+		return false
+	}
+	return ErrorKindOf(err).Retryable() && RetryAfterOf(err) <= maxServiceRetryAfter
+}
+
+// ErrorKindForStatus classifies an HTTP error status code.
+// It can't detect ErrorKindContextTooLong, which providers report as a 400
+// with a provider-specific message.
+func ErrorKindForStatus(statusCode int) ErrorKind {
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return ErrorKindRateLimit
+	case statusCode == 529 || statusCode == http.StatusServiceUnavailable:
+		return ErrorKindOverloaded
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrorKindAuth
+	case statusCode == http.StatusRequestTimeout || statusCode >= 500:
+		return ErrorKindTransient
+	}
+	return ErrorKindUnknown
+}
+
+// NewHTTPError returns an *Error for a failed HTTP response. If
+// contextTooLong is true, the kind is ErrorKindContextTooLong regardless of
+// the status code.
+func NewHTTPError(statusCode int, header http.Header, contextTooLong bool, err error) *Error {
+	kind := ErrorKindForStatus(statusCode)
+	if contextTooLong {
+		kind = ErrorKindContextTooLong
+	}
+	return &Error{
+		Kind:       kind,
+		StatusCode: statusCode,
+		RetryAfter: ParseRetryAfter(header),
+		Err:        err,
+	}
+}
+
+// ParseRetryAfter returns the wait requested by a response's retry-after-ms
+// or Retry-After header (in seconds or as an HTTP date), or 0 if neither is set.
+func ParseRetryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+	if ms := header.Get("retry-after-ms"); ms != "" {
+		if n, err := strconv.ParseFloat(ms, 64); err == nil && n > 0 {
+			return time.Duration(n * float64(time.Millisecond))
+		}
+	}
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		if n <= 0 {
+			return 0
+		}
+		return time.Duration(n * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
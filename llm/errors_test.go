@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestErrorKindForStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   ErrorKind
+	}{
+		{429, ErrorKindRateLimit},
+		{529, ErrorKindOverloaded},
+		{503, ErrorKindOverloaded},
+		{401, ErrorKindAuth},
+		{403, ErrorKindAuth},
+		{500, ErrorKindTransient},
+		{502, ErrorKindTransient},
+		{408, ErrorKindTransient},
+		{400, ErrorKindUnknown},
+		{404, ErrorKindUnknown},
+	}
+	for _, tt := range tests {
+		if got := ErrorKindForStatus(tt.status); got != tt.want {
+			t.Errorf("ErrorKindForStatus(%d) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"none", http.Header{}, 0},
+		{"seconds", http.Header{"Retry-After": {"7"}}, 7 * time.Second},
+		{"fractional seconds", http.Header{"Retry-After": {"1.5"}}, 1500 * time.Millisecond},
+		{"milliseconds preferred", http.Header{"Retry-After": {"7"}, "Retry-After-Ms": {"250"}}, 250 * time.Millisecond},
+		{"negative", http.Header{"Retry-After": {"-3"}}, 0},
+		{"garbage", http.Header{"Retry-After": {"soon"}}, 0},
+		{"past date", http.Header{"Retry-After": {"Wed, 21 Oct 2015 07:28:00 GMT"}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseRetryAfter(tt.header); got != tt.want {
+				t.Errorf("ParseRetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := ParseRetryAfter(http.Header{"Retry-After": {future}}); got < 50*time.Second || got > time.Minute {
+		t.Errorf("ParseRetryAfter(date a minute away) = %v", got)
+	}
+}
+
+func TestErrorChain(t *testing.T) {
+	err := fmt.Errorf("LLM request failed: %w", NewHTTPError(429, http.Header{"Retry-After": {"2"}}, false, fmt.Errorf("status 429")))
+	if kind := ErrorKindOf(err); kind != ErrorKindRateLimit {
+		t.Errorf("ErrorKindOf() = %q, want rate_limit", kind)
+	}
+	if !ErrorKindOf(err).Retryable() {
+		t.Error("rate limit errors should be retryable")
+	}
+	if d := RetryAfterOf(err); d != 2*time.Second {
+		t.Errorf("RetryAfterOf() = %v, want 2s", d)
+	}
+	if kind := ErrorKindOf(NewHTTPError(400, nil, true, fmt.Errorf("too long"))); kind != ErrorKindContextTooLong || kind.Retryable() {
+		t.Errorf("expected non-retryable context_too_long, got %q", kind)
+	}
+	if kind := ErrorKindOf(fmt.Errorf("plain")); kind != ErrorKindUnknown {
+		t.Errorf("ErrorKindOf(plain error) = %q, want unknown", kind)
+	}
+}
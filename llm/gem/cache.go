@@ -0,0 +1,172 @@
+package gem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/llm/gem/gemini"
+)
+
+// Gemini bills content read from an explicit cache at a fraction of the input
+// price, so the stable prefix of a conversation (the system prompt, tools, and
+// every message but the last) is sent from a cache once it is large enough.
+//
+// A cache can't be extended, and creating one bills its whole prefix, so
+// a new cache is created only when the uncached part of the prefix is at least
+// as large as the cache it would replace. That bounds creation costs at about
+// twice the final prefix for a conversation however long it grows.
+const (
+	cacheMinTokens = 4096             // the least most models allow in a cache
+	cacheTTL       = 15 * time.Minute // long enough to span a pause between turns
+	cacheMargin    = time.Minute      // don't use caches that expire sooner
+)
+
+// contextCache tracks the caches a Service has created, by the prefix they hold.
+type contextCache struct {
+	mu       sync.Mutex
+	entries  map[string]cacheEntry // keyed by prefixKeys
+	offUntil time.Time             // set when creating a cache fails
+}
+
+type cacheEntry struct {
+	name    string // "cachedContents/{id}"
+	tokens  uint64
+	expires time.Time
+}
+
+// prefixKeys returns a key for each prefix of req's contents: keys[k] covers
+// the system instruction, tools, and contents[:k].
+func prefixKeys(req *gemini.Request) []string {
+	h := sha256.New()
+	write := func(v any) {
+		b, _ := json.Marshal(v)
+		h.Write(b)
+		h.Write([]byte{0})
+	}
+	sum := func(h hash.Hash) string { return hex.EncodeToString(h.Sum(nil)) }
+
+	write(req.SystemInstruction)
+	write(req.Tools)
+	keys := []string{sum(h)}
+	for _, c := range req.Contents {
+		write(c)
+		keys = append(keys, sum(h))
+	}
+	return keys
+}
+
+// useCache sends the stable prefix of req from a cache, creating one if it's
+// worthwhile, and rewrites req to refer to it. It returns the number of tokens
+// written to a new cache, or 0 if none was created.
+// Caching is best-effort: when it fails, req is sent whole.
+func (s *Service) useCache(ctx context.Context, model gemini.Model, req *gemini.Request) uint64 {
+	stable := len(req.Contents) - 1
+	if stable < 1 {
+		return 0
+	}
+	keys := prefixKeys(req)
+	now := time.Now()
+
+	c := &s.cache
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+	var entry cacheEntry
+	cached := 0
+	for k := stable; k > 0; k-- {
+		e, ok := c.entries[keys[k]]
+		if ok && e.expires.After(now.Add(cacheMargin)) {
+			entry, cached = e, k
+			break
+		}
+	}
+	for key, e := range c.entries {
+		if !e.expires.After(now) {
+			delete(c.entries, key)
+		}
+	}
+	off := now.Before(c.offUntil)
+	c.mu.Unlock()
+
+	uncached := estimateTokens(req.Contents[cached:stable])
+	if cached == 0 {
+		if req.SystemInstruction != nil {
+			uncached += estimateTokens([]gemini.Content{*req.SystemInstruction})
+		}
+		tools, _ := json.Marshal(req.Tools)
+		uncached += uint64(len(tools)) / 4
+	}
+
+	var created uint64
+	if !off && uncached >= max(cacheMinTokens, entry.tokens) {
+		cc, err := model.CreateCachedContent(ctx, &gemini.CachedContent{
+			SystemInstruction: req.SystemInstruction,
+			Tools:             req.Tools,
+			Contents:          req.Contents[:stable],
+			TTL:               fmt.Sprintf("%.0fs", cacheTTL.Seconds()),
+		})
+		c.mu.Lock()
+		if err != nil {
+			slog.WarnContext(ctx, "gemini_cache_create_failed", "error", err, "model", model.Model)
+			c.offUntil = now.Add(cacheTTL)
+		} else {
+			entry = cacheEntry{name: cc.Name, tokens: entry.tokens + uncached, expires: now.Add(cacheTTL)}
+			if !cc.ExpireTime.IsZero() {
+				entry.expires = cc.ExpireTime
+			}
+			if cc.UsageMetadata != nil {
+				entry.tokens = cc.UsageMetadata.TotalTokenCount
+			}
+			c.entries[keys[stable]] = entry
+			cached = stable
+			created = entry.tokens
+		}
+		c.mu.Unlock()
+	}
+
+	if cached == 0 {
+		return 0
+	}
+	// The cache holds the system instruction and tools; requests using it may not repeat them.
+	req.CachedContent = entry.name
+	req.SystemInstruction = nil
+	req.Tools = nil
+	req.Contents = req.Contents[cached:]
+	return created
+}
+
+// cacheMissing reports whether a request that used a cache failed because the
+// API no longer has the cache, for instance because it expired early. Other
+// errors say nothing about the cache, so it is kept.
+func cacheMissing(apiErr *gemini.APIError) bool {
+	switch apiErr.StatusCode {
+	case http.StatusForbidden, http.StatusNotFound, http.StatusBadRequest:
+	default:
+		return false
+	}
+	// "CachedContent not found (or permission denied)", "Not found: cachedContents/...",
+	// "Cache content ... is expired."
+	body := strings.ToLower(apiErr.Body)
+	return strings.Contains(body, "cachedcontent") || strings.Contains(body, "cache content")
+}
+
+// forget drops the cache named name, so later requests don't use it.
+func (c *contextCache) forget(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if e.name == name {
+			delete(c.entries, key)
+		}
+	}
+}
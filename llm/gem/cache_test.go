@@ -0,0 +1,162 @@
+package gem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/llm/gem/gemini"
+)
+
+// cacheServer is a fake Gemini API that creates caches and records the requests it gets.
+type cacheServer struct {
+	t          *testing.T
+	creates    []gemini.CachedContent
+	generates  []gemini.Request
+	rejectName string // generate requests using this cache fail
+	limited    bool   // generate requests using a cache are rate limited
+}
+
+func (s *cacheServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Goog-Api-Key") != "test-key" || r.URL.Query().Has("key") {
+		s.t.Errorf("API key not sent in header only: %s %v", r.URL, r.Header)
+	}
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/cachedContents"):
+		var cc gemini.CachedContent
+		json.Unmarshal(body, &cc)
+		s.creates = append(s.creates, cc)
+		fmt.Fprintf(w, `{"name":"cachedContents/c%d","expireTime":"2999-01-01T00:00:00Z",`+
+			`"usageMetadata":{"totalTokenCount":6000}}`, len(s.creates))
+	case strings.HasSuffix(r.URL.Path, ":generateContent"):
+		var req gemini.Request
+		json.Unmarshal(body, &req)
+		s.generates = append(s.generates, req)
+		if req.CachedContent != "" && req.CachedContent == s.rejectName {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"error":{"code":403,"message":"CachedContent not found","status":"PERMISSION_DENIED"}}`)
+			return
+		}
+		if req.CachedContent != "" && s.limited {
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"error":{"code":429,"message":"Resource has been exhausted","status":"RESOURCE_EXHAUSTED"}}`)
+			return
+		}
+		cached := 0
+		if req.CachedContent != "" {
+			cached = 6000
+		}
+		fmt.Fprintf(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"ok"}]}}],`+
+			`"usageMetadata":{"promptTokenCount":%d,"cachedContentTokenCount":%d,"candidatesTokenCount":5}}`,
+			cached+100, cached)
+	default:
+		s.t.Errorf("unexpected request to %s", r.URL)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func text(role llm.MessageRole, s string) llm.Message {
+	return llm.Message{Role: role, Content: []llm.Content{{Type: llm.ContentTypeText, Text: s}}}
+}
+
+func TestContextCache(t *testing.T) {
+	fake := &cacheServer{t: t}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	svc := &Service{URL: server.URL, APIKey: "test-key", Model: "gemini-2.5-flash"}
+
+	ir := &llm.Request{
+		System: []llm.SystemContent{{Text: strings.Repeat("You are helpful. ", 1500)}},
+		Messages: []llm.Message{
+			text(llm.MessageRoleUser, "list the files"),
+			text(llm.MessageRoleAssistant, "there are none"),
+			text(llm.MessageRoleUser, "make one"),
+		},
+	}
+	res, err := svc.Do(context.Background(), ir)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if len(fake.creates) != 1 || fake.creates[0].Model != "models/gemini-2.5-flash" ||
+		fake.creates[0].SystemInstruction == nil || len(fake.creates[0].Contents) != 2 || fake.creates[0].TTL != "900s" {
+		t.Fatalf("expected a cache of the system prompt and first two messages, got %+v", fake.creates)
+	}
+	first := fake.generates[0]
+	if first.CachedContent != "cachedContents/c1" || first.SystemInstruction != nil || len(first.Contents) != 1 {
+		t.Errorf("expected the first request to send only its last message, got %+v", first)
+	}
+	want := llm.Usage{InputTokens: 100, CacheCreationInputTokens: 6000, OutputTokens: 5}
+	if res.Usage.InputTokens != want.InputTokens || res.Usage.CacheCreationInputTokens != want.CacheCreationInputTokens ||
+		res.Usage.CacheReadInputTokens != 0 || res.Usage.OutputTokens != want.OutputTokens {
+		t.Errorf("first usage = %+v, want %+v", res.Usage, want)
+	}
+
+	// The next turn reuses the cache, since too little was added to be worth caching again.
+	ir.Messages = append(ir.Messages, text(llm.MessageRoleAssistant, "done"), text(llm.MessageRoleUser, "thanks"))
+	res, err = svc.Do(context.Background(), ir)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if len(fake.creates) != 1 {
+		t.Errorf("expected the cache to be reused, got %d creates", len(fake.creates))
+	}
+	second := fake.generates[1]
+	if second.CachedContent != "cachedContents/c1" || len(second.Contents) != 3 {
+		t.Errorf("expected the second request to send the three messages after the cache, got %+v", second)
+	}
+	if res.Usage.CacheReadInputTokens != 6000 || res.Usage.CacheCreationInputTokens != 0 || res.Usage.InputTokens != 100 {
+		t.Errorf("second usage = %+v", res.Usage)
+	}
+
+	// Other errors leave the cache alone.
+	fake.limited = true
+	sent := len(fake.generates)
+	_, err = svc.Do(llm.WithoutRetries(context.Background()), ir)
+	if kind := llm.ErrorKindOf(err); kind != llm.ErrorKindRateLimit {
+		t.Fatalf("ErrorKindOf() = %q, want %q (err: %v)", kind, llm.ErrorKindRateLimit, err)
+	}
+	if len(fake.generates) != sent+1 || len(svc.cache.entries) == 0 {
+		t.Errorf("expected one request and the cache kept, got %d requests, entries %+v", len(fake.generates)-sent, svc.cache.entries)
+	}
+	fake.limited = false
+
+	// A cache the API no longer has is dropped, and the request is resent whole.
+	fake.rejectName = "cachedContents/c1"
+	if _, err := svc.Do(context.Background(), ir); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	last := fake.generates[len(fake.generates)-1]
+	if last.CachedContent != "" || last.SystemInstruction == nil || len(last.Contents) != 5 {
+		t.Errorf("expected the retry to send the whole conversation, got %+v", last)
+	}
+	if len(svc.cache.entries) != 0 {
+		t.Errorf("expected the rejected cache to be forgotten, got %+v", svc.cache.entries)
+	}
+}
+
+func TestContextCacheSkipsSmallPrefixes(t *testing.T) {
+	fake := &cacheServer{t: t}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	svc := &Service{URL: server.URL, APIKey: "test-key"}
+
+	ir := &llm.Request{Messages: []llm.Message{
+		text(llm.MessageRoleUser, "hi"),
+		text(llm.MessageRoleAssistant, "hello"),
+		text(llm.MessageRoleUser, "bye"),
+	}}
+	if _, err := svc.Do(context.Background(), ir); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if len(fake.creates) != 0 || fake.generates[0].CachedContent != "" || len(fake.generates[0].Contents) != 3 {
+		t.Errorf("expected a short conversation to be sent uncached, got creates %+v, request %+v", fake.creates, fake.generates[0])
+	}
+}
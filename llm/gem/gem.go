@@ -4,6 +4,7 @@ import (
 	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand"
@@ -23,13 +24,20 @@ const (
 // Service provides Gemini completions.
 // Fields should not be altered concurrently with calling any method on Service.
 type Service struct {
-	HTTPC  *http.Client // defaults to http.DefaultClient if nil
-	URL    string       // Gemini API URL, uses the gemini package default if empty
-	APIKey string       // must be non-empty
-	Model  string       // defaults to DefaultModel if empty
+	HTTPC         *http.Client      // defaults to http.DefaultClient if nil
+	URL           string            // Gemini API URL, uses the gemini package default if empty
+	APIKey        string            // must be non-empty
+	Model         string            // defaults to DefaultModel if empty
+	Pricing       llm.Pricing       // used to compute cost when the gateway doesn't report it
+	ThinkingLevel llm.ThinkingLevel // thinking level (ThinkingLevelOff thinks as little as the model allows)
+
+	cache contextCache // caches of conversation prefixes; see cache.go
 }
 
-var _ llm.Service = (*Service)(nil)
+var (
+	_ llm.Service          = (*Service)(nil)
+	_ llm.StreamingService = (*Service)(nil)
+)
 
 // These maps convert between Sketch's llm package and Gemini API formats
 var fromLLMRole = map[llm.MessageRole]string{
@@ -45,6 +53,9 @@ func convertToolSchemas(tools []*llm.Tool) ([]gemini.FunctionDeclaration, error)
 
 	var decls []gemini.FunctionDeclaration
 	for _, tool := range tools {
+		if tool.Server {
+			continue // run by another provider
+		}
 		// Parse the schema from raw JSON
 		var schemaJSON map[string]any
 		if err := json.Unmarshal(tool.InputSchema, &schemaJSON); err != nil {
@@ -187,10 +198,32 @@ func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error)
 		// Map each content item to Gemini's format
 		for _, c := range msg.Content {
 			switch c.Type {
-			case llm.ContentTypeText, llm.ContentTypeThinking, llm.ContentTypeRedactedThinking:
+			case llm.ContentTypeThinking:
+				// Thought summaries go back as they came, with their signatures.
+				if c.Thinking != "" || c.Signature != "" {
+					content.Parts = append(content.Parts, gemini.Part{
+						Text:             c.Thinking,
+						Thought:          true,
+						ThoughtSignature: c.Signature,
+					})
+				}
+			case llm.ContentTypeText, llm.ContentTypeRedactedThinking:
+				if c.IsImage() || (c.IsDocument() && c.MediaType == "application/pdf" && c.Data != "") {
+					content.Parts = append(content.Parts, gemini.Part{
+						InlineData: &gemini.Blob{MimeType: c.MediaType, Data: c.Data},
+					})
+					continue
+				}
+				if c.IsDocument() {
+					content.Parts = append(content.Parts, gemini.Part{
+						Text: c.DocumentText(),
+					})
+					continue
+				}
 				// Simple text content
 				content.Parts = append(content.Parts, gemini.Part{
-					Text: c.Text,
+					Text:             c.Text,
+					ThoughtSignature: c.Signature,
 				})
 			case llm.ContentTypeToolUse:
 				// Tool use becomes a function call
@@ -297,9 +330,47 @@ func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error)
 		}
 	}
 
+	if tc := s.thinkingConfig(req.ThinkingLevelOr(s.ThinkingLevel)); tc != nil {
+		gemReq.GenerationConfig = &gemini.GenerationConfig{ThinkingConfig: tc}
+	}
+
 	return gemReq, nil
 }
 
+// thinkingConfig maps a thinking level to the model's thinking configuration.
+// It returns nil for models that don't think.
+func (s *Service) thinkingConfig(thinkingLevel llm.ThinkingLevel) *gemini.ThinkingConfig {
+	model := cmp.Or(s.Model, DefaultModel)
+	include := thinkingLevel != llm.ThinkingLevelOff
+	switch {
+	case strings.HasPrefix(model, "gemini-3"):
+		// Gemini 3 can't turn thinking off. Pro only has the low and high levels.
+		level := "low"
+		switch thinkingLevel {
+		case llm.ThinkingLevelOff, llm.ThinkingLevelMinimal:
+			if strings.Contains(model, "flash") {
+				level = "minimal"
+			}
+		case llm.ThinkingLevelMedium:
+			level = "high"
+			if strings.Contains(model, "flash") {
+				level = "medium"
+			}
+		case llm.ThinkingLevelHigh:
+			level = "high"
+		}
+		return &gemini.ThinkingConfig{IncludeThoughts: include, ThinkingLevel: level}
+	case strings.HasPrefix(model, "gemini-2.5"):
+		budget := thinkingLevel.ThinkingBudgetTokens()
+		if budget == 0 && strings.Contains(model, "pro") {
+			budget = 128 // the least 2.5 Pro allows
+		}
+		return &gemini.ThinkingConfig{IncludeThoughts: include, ThinkingBudget: &budget}
+	default:
+		return nil
+	}
+}
+
 // convertGeminiResponsesToContent converts a Gemini response to llm.Content
 func convertGeminiResponseToContent(res *gemini.Response) []llm.Content {
 	if res == nil || len(res.Candidates) == 0 || len(res.Candidates[0].Content.Parts) == 0 {
@@ -320,7 +391,13 @@ func convertGeminiResponseToContent(res *gemini.Response) []llm.Content {
 			"has_function_call", part.FunctionCall != nil,
 			"has_function_response", part.FunctionResponse != nil)
 
-		if part.Text != "" {
+		if part.Text != "" && part.Thought {
+			contents = append(contents, llm.Content{
+				Type:      llm.ContentTypeThinking,
+				Thinking:  part.Text,
+				Signature: part.ThoughtSignature,
+			})
+		} else if part.Text != "" {
 			// Simple text response
 			contents = append(contents, llm.Content{
 				Type:      llm.ContentTypeText,
@@ -393,37 +470,48 @@ func ensureToolIDs(contents []llm.Content) {
 	}
 }
 
-func calculateUsage(req *gemini.Request, res *gemini.Response) llm.Usage {
-	// Very rough estimation of token counts
-	var inputTokens uint64
-	var outputTokens uint64
-
-	// Count system tokens
-	if req.SystemInstruction != nil {
-		for _, part := range req.SystemInstruction.Parts {
-			if part.Text != "" {
-				// Very rough estimation: 1 token per 4 characters
-				inputTokens += uint64(len(part.Text)) / 4
-			}
-		}
-	}
-
-	// Count input tokens
-	for _, content := range req.Contents {
+// estimateTokens very roughly estimates the tokens in contents: 1 token per 4 characters.
+func estimateTokens(contents []gemini.Content) uint64 {
+	var tokens uint64
+	for _, content := range contents {
 		for _, part := range content.Parts {
 			if part.Text != "" {
-				inputTokens += uint64(len(part.Text)) / 4
+				tokens += uint64(len(part.Text)) / 4
 			} else if part.FunctionCall != nil {
 				// Estimate function call tokens
 				argBytes, _ := json.Marshal(part.FunctionCall.Args)
-				inputTokens += uint64(len(part.FunctionCall.Name)+len(argBytes)) / 4
+				tokens += uint64(len(part.FunctionCall.Name)+len(argBytes)) / 4
 			} else if part.FunctionResponse != nil {
 				// Estimate function response tokens
 				resBytes, _ := json.Marshal(part.FunctionResponse.Response)
-				inputTokens += uint64(len(part.FunctionResponse.Name)+len(resBytes)) / 4
+				tokens += uint64(len(part.FunctionResponse.Name)+len(resBytes)) / 4
 			}
 		}
 	}
+	return tokens
+}
+
+// calculateUsage returns the usage the response reports, or an estimate if it reports none.
+func calculateUsage(req *gemini.Request, res *gemini.Response) llm.Usage {
+	if res != nil && res.UsageMetadata != nil {
+		u := res.UsageMetadata
+		cached := min(u.CachedContentTokenCount, u.PromptTokenCount)
+		return llm.Usage{
+			InputTokens:          u.PromptTokenCount - cached,
+			CacheReadInputTokens: cached,
+			OutputTokens:         u.CandidatesTokenCount + u.ThoughtsTokenCount,
+			ThinkingTokens:       u.ThoughtsTokenCount,
+		}
+	}
+
+	// Very rough estimation of token counts
+	var inputTokens uint64
+	var outputTokens uint64
+
+	if req.SystemInstruction != nil {
+		inputTokens += estimateTokens([]gemini.Content{*req.SystemInstruction})
+	}
+	inputTokens += estimateTokens(req.Contents)
 
 	// Count output tokens
 	if res != nil && len(res.Candidates) > 0 {
@@ -475,8 +563,56 @@ func (s *Service) MaxImageDimension() int {
 	return 0 // No known limit
 }
 
+// MaxRequestBytes returns Gemini's 20 MB limit on requests with inline data.
+func (s *Service) MaxRequestBytes() int {
+	return 20 * 1024 * 1024
+}
+
+// streamDeltas returns a chunk callback that converts streamed Gemini parts
+// into deltas, numbering blocks the same way the merged response does.
+func streamDeltas(onDelta func(llm.StreamDelta)) func(*gemini.Response) {
+	blocks := 0
+	inText := false
+	inThought := false
+	return func(chunk *gemini.Response) {
+		if len(chunk.Candidates) == 0 {
+			return
+		}
+		for _, p := range chunk.Candidates[0].Content.Parts {
+			switch {
+			case p.FunctionCall != nil:
+				args, _ := json.Marshal(p.FunctionCall.Args)
+				onDelta(llm.StreamDelta{Index: blocks, Type: llm.ContentTypeToolUse, ToolName: p.FunctionCall.Name, ToolInput: string(args)})
+				blocks++
+				inText = false
+			case p.Text != "":
+				if !inText || inThought != p.Thought {
+					blocks++
+					inText = true
+					inThought = p.Thought
+				}
+				typ := llm.ContentTypeText
+				if p.Thought {
+					typ = llm.ContentTypeThinking
+				}
+				onDelta(llm.StreamDelta{Index: blocks - 1, Type: typ, Text: p.Text})
+			}
+		}
+	}
+}
+
 // Do sends a request to Gemini.
 func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
+	return s.do(ctx, ir, nil)
+}
+
+// DoStream sends a request to Gemini using the streaming endpoint,
+// calling onDelta with partial output as it arrives.
+func (s *Service) DoStream(ctx context.Context, ir *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
+	return s.do(ctx, ir, onDelta)
+}
+
+func (s *Service) do(ctx context.Context, ir *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
 	// Log the incoming request for debugging
 	slog.DebugContext(ctx, "gemini_request",
 		"message_count", len(ir.Messages),
@@ -538,16 +674,26 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 		HTTPC:    cmp.Or(s.HTTPC, http.DefaultClient),
 	}
 
+	// Send the stable prefix of the conversation from a cache when there is one.
+	uncachedReq := *gemReq
+	cacheCreated := s.useCache(ctx, model, gemReq)
+
 	// Send the request to Gemini with retry logic
 	startTime := time.Now()
 	endTime := startTime // Initialize endTime
 	var gemRes *gemini.Response
 
-	// Retry mechanism for handling server errors and rate limiting
+	// Retry network and stream errors, and retryable errors the API responds
+	// with unless the caller retries on its own (see llm.WithoutRetries).
 	backoff := []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second, 10 * time.Second}
 	for attempts := 0; attempts <= len(backoff); attempts++ {
+		var retryAfter time.Duration
 		gemApiErr := error(nil)
-		gemRes, gemApiErr = model.GenerateContent(ctx, gemReq)
+		if onDelta != nil {
+			gemRes, gemApiErr = model.StreamGenerateContent(ctx, gemReq, streamDeltas(onDelta))
+		} else {
+			gemRes, gemApiErr = model.GenerateContent(ctx, gemReq)
+		}
 		endTime = time.Now()
 
 		if gemApiErr == nil {
@@ -558,24 +704,42 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 			}
 			break
 		}
-
-		if attempts == len(backoff) {
-			// We've exhausted all retry attempts
-			return nil, fmt.Errorf("gemini: API error after %d attempts (last at %s): %w", attempts, time.Now().Format(time.DateTime), gemApiErr)
+		if onDelta != nil {
+			onDelta(llm.StreamDelta{Reset: true})
 		}
 
-		// Check if the error is retryable (e.g., server error or rate limiting)
-		if strings.Contains(gemApiErr.Error(), "429") || strings.Contains(gemApiErr.Error(), "5") {
-			// Rate limited or server error - wait and retry
-			random := time.Duration(rand.Int63n(int64(time.Second)))
-			sleep := backoff[attempts] + random
-			slog.WarnContext(ctx, "gemini_request_retry", "error", gemApiErr.Error(), "attempt", attempts+1, "sleep", sleep)
-			time.Sleep(sleep)
+		var apiErr *gemini.APIError
+		if errors.As(gemApiErr, &apiErr) && gemReq.CachedContent != "" && cacheMissing(apiErr) {
+			// The cache was deleted or expired early. Resend without it. The
+			// resend isn't a retry, so it doesn't use up an attempt; it can
+			// only happen once, since the uncached request has no cache.
+			slog.WarnContext(ctx, "gemini_cached_request_failed", "error", gemApiErr.Error(), "cache", gemReq.CachedContent)
+			s.cache.forget(gemReq.CachedContent)
+			gemReq, cacheCreated = &uncachedReq, 0
+			attempts--
 			continue
 		}
+		if errors.As(gemApiErr, &apiErr) {
+			slog.WarnContext(ctx, "gemini_request_failed", "error", gemApiErr.Error(), "status_code", apiErr.StatusCode)
+			classified := classifyAPIError(apiErr)
+			if !llm.ShouldRetry(ctx, classified) || attempts == len(backoff) {
+				return nil, classified
+			}
+			retryAfter = classified.RetryAfter
+		} else if ctx.Err() != nil {
+			return nil, fmt.Errorf("gemini: %w", gemApiErr)
+		} else if attempts == len(backoff) {
+			// We've exhausted all retry attempts
+			return nil, &llm.Error{
+				Kind: llm.ErrorKindTransient,
+				Err:  fmt.Errorf("gemini: API error after %d attempts (last at %s): %w", attempts, time.Now().Format(time.DateTime), gemApiErr),
+			}
+		}
 
-		// Non-retryable error
-		return nil, fmt.Errorf("gemini: API error: %w", gemApiErr)
+		random := time.Duration(rand.Int63n(int64(time.Second)))
+		sleep := max(backoff[attempts]+random, retryAfter)
+		slog.WarnContext(ctx, "gemini_request_retry", "error", gemApiErr.Error(), "attempt", attempts+1, "sleep", sleep)
+		time.Sleep(sleep)
 	}
 
 	content := convertGeminiResponseToContent(gemRes)
@@ -583,7 +747,14 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 	ensureToolIDs(content)
 
 	usage := calculateUsage(gemReq, gemRes)
-	usage.CostUSD = llm.CostUSDFromResponse(gemRes.Header())
+	// Tokens cached for this request count as written rather than read,
+	// so the total stays the size of the prompt.
+	if cacheCreated > 0 {
+		written := min(cacheCreated, usage.CacheReadInputTokens)
+		usage.CacheReadInputTokens -= written
+		usage.CacheCreationInputTokens = written
+	}
+	usage.CostUSD = s.Pricing.CostUSD(gemRes.Header(), usage)
 
 	stopReason := llm.StopReasonEndTurn
 	for _, part := range content {
@@ -606,3 +777,11 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 		EndTime:    &endTime,
 	}, nil
 }
+
+// classifyAPIError converts an error response from the Gemini API into an *llm.Error.
+func classifyAPIError(apiErr *gemini.APIError) *llm.Error {
+	// Gemini reports oversized requests as a 400 INVALID_ARGUMENT, e.g.
+	// "The input token count (1200000) exceeds the maximum number of tokens allowed (1048576)."
+	contextTooLong := apiErr.StatusCode == http.StatusBadRequest && strings.Contains(apiErr.Body, "exceeds the maximum number of tokens")
+	return llm.NewHTTPError(apiErr.StatusCode, apiErr.Header, contextTooLong, fmt.Errorf("gemini: API error: %w", apiErr))
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"shelley.exe.dev/llm"
@@ -781,3 +782,181 @@ func TestCalculateUsageWithComplexFunctionCall(t *testing.T) {
 		t.Errorf("Expected output tokens with complex function call to be greater than 0, got %d", usage.OutputTokens)
 	}
 }
+
+func TestServiceDoStream(t *testing.T) {
+	body := "data: " + `{"candidates":[{"content":{"role":"model","parts":[{"text":"Hel"}]}}]}` + "\n\n" +
+		"data: " + `{"candidates":[{"content":{"role":"model","parts":[{"text":"lo"}]}}]}` + "\n\n" +
+		"data: " + `{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"bash","args":{"command":"ls"}}}]}}]}` + "\n\n"
+	svc := &Service{
+		APIKey: "test-key",
+		HTTPC: &http.Client{Transport: &mockRoundTripper{response: &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       io.NopCloser(bytes.NewBufferString(body)),
+		}}},
+	}
+
+	var deltas []llm.StreamDelta
+	res, err := svc.DoStream(context.Background(), &llm.Request{
+		Messages: []llm.Message{{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "hi"}}}},
+	}, func(d llm.StreamDelta) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("DoStream: %v", err)
+	}
+
+	want := []llm.StreamDelta{
+		{Index: 0, Type: llm.ContentTypeText, Text: "Hel"},
+		{Index: 0, Type: llm.ContentTypeText, Text: "lo"},
+		{Index: 1, Type: llm.ContentTypeToolUse, ToolName: "bash", ToolInput: `{"command":"ls"}`},
+	}
+	if len(deltas) != len(want) {
+		t.Fatalf("got %d deltas, want %d: %+v", len(deltas), len(want), deltas)
+	}
+	for i := range want {
+		if deltas[i] != want[i] {
+			t.Errorf("delta %d = %+v, want %+v", i, deltas[i], want[i])
+		}
+	}
+
+	if len(res.Content) != 2 {
+		t.Fatalf("got %d content blocks, want 2", len(res.Content))
+	}
+	if res.Content[0].Text != "Hello" {
+		t.Errorf("text = %q, want %q", res.Content[0].Text, "Hello")
+	}
+	if res.Content[1].ToolName != "bash" || res.Content[1].ID == "" {
+		t.Errorf("tool use = %+v, want bash with an ID", res.Content[1])
+	}
+	if res.StopReason != llm.StopReasonToolUse {
+		t.Errorf("stop reason = %v, want tool use", res.StopReason)
+	}
+}
+
+func TestDoClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		wantKind   llm.ErrorKind
+	}{
+		{"rate limit", 429, `{"error":{"code":429,"message":"Resource has been exhausted","status":"RESOURCE_EXHAUSTED"}}`, llm.ErrorKindRateLimit},
+		{"overloaded", 503, `{"error":{"code":503,"message":"The model is overloaded.","status":"UNAVAILABLE"}}`, llm.ErrorKindOverloaded},
+		{"context too long", 400, `{"error":{"code":400,"message":"The input token count (1200000) exceeds the maximum number of tokens allowed (1048576).","status":"INVALID_ARGUMENT"}}`, llm.ErrorKindContextTooLong},
+		{"bad request", 400, `{"error":{"code":400,"message":"Invalid JSON payload","status":"INVALID_ARGUMENT"}}`, llm.ErrorKindUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.statusCode)
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			service := &Service{URL: server.URL, APIKey: "test-api-key", Model: DefaultModel}
+			ir := &llm.Request{
+				Messages: []llm.Message{{
+					Role:    llm.MessageRoleUser,
+					Content: []llm.Content{{Type: llm.ContentTypeText, Text: "Hello"}},
+				}},
+			}
+			_, err := service.Do(llm.WithoutRetries(context.Background()), ir)
+			if err == nil {
+				t.Fatal("Do() error = nil, want error")
+			}
+			if kind := llm.ErrorKindOf(err); kind != tt.wantKind {
+				t.Errorf("ErrorKindOf() = %q, want %q (err: %v)", kind, tt.wantKind, err)
+			}
+			if calls != 1 {
+				t.Errorf("expected 1 request, got %d", calls)
+			}
+		})
+	}
+}
+
+func TestThinkingConfig(t *testing.T) {
+	budget := func(n int) *int { return &n }
+	tests := []struct {
+		model string
+		level llm.ThinkingLevel
+		want  *gemini.ThinkingConfig
+	}{
+		{"gemini-3-pro-preview", llm.ThinkingLevelMedium, &gemini.ThinkingConfig{IncludeThoughts: true, ThinkingLevel: "high"}},
+		{"gemini-3-pro-preview", llm.ThinkingLevelMinimal, &gemini.ThinkingConfig{IncludeThoughts: true, ThinkingLevel: "low"}},
+		{"gemini-3-pro-preview", llm.ThinkingLevelOff, &gemini.ThinkingConfig{ThinkingLevel: "low"}},
+		{"gemini-3-flash-preview", llm.ThinkingLevelMedium, &gemini.ThinkingConfig{IncludeThoughts: true, ThinkingLevel: "medium"}},
+		{"gemini-3-flash-preview", llm.ThinkingLevelOff, &gemini.ThinkingConfig{ThinkingLevel: "minimal"}},
+		{"gemini-2.5-flash", llm.ThinkingLevelHigh, &gemini.ThinkingConfig{IncludeThoughts: true, ThinkingBudget: budget(16384)}},
+		{"gemini-2.5-flash", llm.ThinkingLevelOff, &gemini.ThinkingConfig{ThinkingBudget: budget(0)}},
+		{"gemini-2.5-pro", llm.ThinkingLevelOff, &gemini.ThinkingConfig{ThinkingBudget: budget(128)}},
+		{"gemini-2.0-flash", llm.ThinkingLevelHigh, nil},
+	}
+	for _, tt := range tests {
+		got := (&Service{Model: tt.model}).thinkingConfig(tt.level)
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(tt.want)
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("%s at %v: thinkingConfig() = %s, want %s", tt.model, tt.level, gotJSON, wantJSON)
+		}
+	}
+}
+
+func TestThoughtParts(t *testing.T) {
+	res := &gemini.Response{Candidates: []gemini.Candidate{{Content: gemini.Content{Parts: []gemini.Part{
+		{Text: "Considering the files.", Thought: true, ThoughtSignature: "sig1"},
+		{Text: "Done."},
+	}}}}}
+	content := convertGeminiResponseToContent(res)
+	if len(content) != 2 || content[0].Type != llm.ContentTypeThinking || content[0].Thinking != "Considering the files." ||
+		content[0].Signature != "sig1" || content[1].Type != llm.ContentTypeText {
+		t.Fatalf("unexpected content: %+v", content)
+	}
+
+	// Thoughts go back to the model as they came.
+	svc := &Service{Model: "gemini-3-pro-preview", ThinkingLevel: llm.ThinkingLevelHigh}
+	req, err := svc.buildGeminiRequest(&llm.Request{Messages: []llm.Message{
+		{Role: llm.MessageRoleAssistant, Content: content},
+		{Role: llm.MessageRoleUser, Content: []llm.Content{llm.StringContent("thanks")}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := req.Contents[0].Parts[0]
+	if !part.Thought || part.Text != "Considering the files." || part.ThoughtSignature != "sig1" {
+		t.Errorf("thought part = %+v", part)
+	}
+	if req.GenerationConfig == nil || req.GenerationConfig.ThinkingConfig.ThinkingLevel != "high" {
+		t.Errorf("expected a thinking config, got %+v", req.GenerationConfig)
+	}
+
+	// Streamed thoughts and text are separate blocks.
+	var deltas []llm.StreamDelta
+	stream := streamDeltas(func(d llm.StreamDelta) { deltas = append(deltas, d) })
+	stream(&gemini.Response{Candidates: []gemini.Candidate{{Content: gemini.Content{Parts: []gemini.Part{{Text: "Hmm", Thought: true}}}}}})
+	stream(&gemini.Response{Candidates: []gemini.Candidate{{Content: gemini.Content{Parts: []gemini.Part{{Text: "Hi"}}}}}})
+	want := []llm.StreamDelta{
+		{Index: 0, Type: llm.ContentTypeThinking, Text: "Hmm"},
+		{Index: 1, Type: llm.ContentTypeText, Text: "Hi"},
+	}
+	if len(deltas) != len(want) || deltas[0] != want[0] || deltas[1] != want[1] {
+		t.Errorf("deltas = %+v, want %+v", deltas, want)
+	}
+}
+
+func TestCalculateUsageFromMetadata(t *testing.T) {
+	res := &gemini.Response{UsageMetadata: &gemini.UsageMetadata{
+		PromptTokenCount:        5000,
+		CachedContentTokenCount: 4000,
+		CandidatesTokenCount:    200,
+		ThoughtsTokenCount:      300,
+	}}
+	got := calculateUsage(&gemini.Request{}, res)
+	if got.InputTokens != 1000 || got.CacheReadInputTokens != 4000 || got.OutputTokens != 500 || got.ThinkingTokens != 300 {
+		t.Errorf("calculateUsage() = %+v", got)
+	}
+	if got.TotalInputTokens() != 5000 {
+		t.Errorf("TotalInputTokens() = %d, want 5000", got.TotalInputTokens())
+	}
+}
@@ -1,12 +1,15 @@
 package gemini
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 )
 
 // https://ai.google.dev/api/generate-content#request-body
@@ -24,8 +27,18 @@ type Request struct {
 
 // https://ai.google.dev/api/generate-content#response-body
 type Response struct {
-	Candidates []Candidate `json:"candidates"`
-	headers    http.Header // captured HTTP response headers
+	Candidates    []Candidate    `json:"candidates"`
+	UsageMetadata *UsageMetadata `json:"usageMetadata,omitempty"`
+	headers       http.Header    // captured HTTP response headers
+}
+
+// https://ai.google.dev/api/generate-content#UsageMetadata
+type UsageMetadata struct {
+	PromptTokenCount        uint64 `json:"promptTokenCount"`        // includes cached tokens
+	CachedContentTokenCount uint64 `json:"cachedContentTokenCount"` // tokens read from a cache
+	CandidatesTokenCount    uint64 `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      uint64 `json:"thoughtsTokenCount"`
+	TotalTokenCount         uint64 `json:"totalTokenCount"`
 }
 
 // Header returns the HTTP response headers.
@@ -46,6 +59,7 @@ type Content struct {
 // This is a union data structure, only one-of the fields can be set.
 type Part struct {
 	Text                string               `json:"text,omitempty"`
+	Thought             bool                 `json:"thought,omitempty"` // Text is a summary of the model's thinking
 	FunctionCall        *FunctionCall        `json:"functionCall,omitempty"`
 	FunctionResponse    *FunctionResponse    `json:"functionResponse,omitempty"`
 	ExecutableCode      *ExecutableCode      `json:"executableCode,omitempty"`
@@ -53,10 +67,16 @@ type Part struct {
 	// ThoughtSignature is required for Gemini 3 models when using function calling.
 	// It must be passed back exactly as received when sending the conversation history.
 	ThoughtSignature string `json:"thoughtSignature,omitempty"`
-	// TODO inlineData
+	InlineData       *Blob  `json:"inlineData,omitempty"`
 	// TODO fileData
 }
 
+// Blob is inline media, such as an image or a PDF.
+type Blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // base64-encoded
+}
+
 type FunctionCall struct {
 	Name string         `json:"name"`
 	Args map[string]any `json:"args"`
@@ -95,8 +115,35 @@ const (
 
 // https://ai.google.dev/api/generate-content#v1beta.GenerationConfig
 type GenerationConfig struct {
-	ResponseMimeType string  `json:"responseMimeType,omitempty"` // text/plain, application/json, or text/x.enum
-	ResponseSchema   *Schema `json:"responseSchema,omitempty"`   // for JSON
+	ResponseMimeType string          `json:"responseMimeType,omitempty"` // text/plain, application/json, or text/x.enum
+	ResponseSchema   *Schema         `json:"responseSchema,omitempty"`   // for JSON
+	ThinkingConfig   *ThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+// https://ai.google.dev/gemini-api/docs/thinking
+// Gemini 3 models take a ThinkingLevel; earlier models take a ThinkingBudget.
+type ThinkingConfig struct {
+	IncludeThoughts bool   `json:"includeThoughts,omitempty"`
+	ThinkingBudget  *int   `json:"thinkingBudget,omitempty"` // tokens; 0 disables thinking, -1 lets the model decide
+	ThinkingLevel   string `json:"thinkingLevel,omitempty"`  // "minimal", "low", "medium", or "high"
+}
+
+// CachedContent is content stored for reuse by later requests, which refer
+// to it by Name in Request.CachedContent.
+// https://ai.google.dev/api/caching#CachedContent
+type CachedContent struct {
+	Name              string         `json:"name,omitempty"` // set by the API: "cachedContents/{id}"
+	Model             string         `json:"model"`
+	SystemInstruction *Content       `json:"systemInstruction,omitempty"`
+	Tools             []Tool         `json:"tools,omitempty"`
+	Contents          []Content      `json:"contents,omitempty"`
+	TTL               string         `json:"ttl,omitempty"` // e.g. "600s"
+	ExpireTime        time.Time      `json:"expireTime,omitzero"`
+	UsageMetadata     *CacheMetadata `json:"usageMetadata,omitempty"`
+}
+
+type CacheMetadata struct {
+	TotalTokenCount uint64 `json:"totalTokenCount"`
 }
 
 // https://ai.google.dev/api/caching#Tool
@@ -148,16 +195,39 @@ type Model struct {
 	Endpoint string       // if empty, DefaultEndpoint is used
 }
 
-func (m Model) GenerateContent(ctx context.Context, req *Request) (*Response, error) {
-	reqBytes, err := json.Marshal(req)
+// APIError is returned when the Gemini API responds with a non-200 status.
+type APIError struct {
+	Op         string // "GenerateContent", "StreamGenerateContent", or "CreateCachedContent"
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: HTTP status: %d, %s", e.Op, e.StatusCode, e.Body)
+}
+
+// newRequest returns a POST request of v as JSON to path under the endpoint.
+// The API key goes in a header, so it doesn't end up in logged URLs.
+func (m Model) newRequest(ctx context.Context, path string, v any) (*http.Request, error) {
+	reqBytes, err := json.Marshal(v)
 	if err != nil {
 		return nil, fmt.Errorf("marshaling request: %w", err)
 	}
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/%s:generateContent?key=%s", m.endpoint(), m.Model, m.APIKey), bytes.NewReader(reqBytes))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, m.endpoint()+"/"+path, bytes.NewReader(reqBytes))
 	if err != nil {
 		return nil, fmt.Errorf("creating HTTP request: %w", err)
 	}
 	httpReq.Header.Add("Content-Type", "application/json")
+	httpReq.Header.Add("X-Goog-Api-Key", m.APIKey)
+	return httpReq, nil
+}
+
+func (m Model) GenerateContent(ctx context.Context, req *Request) (*Response, error) {
+	httpReq, err := m.newRequest(ctx, m.Model+":generateContent", req)
+	if err != nil {
+		return nil, err
+	}
 	httpResp, err := m.httpc().Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("GenerateContent: do: %w", err)
@@ -168,7 +238,7 @@ func (m Model) GenerateContent(ctx context.Context, req *Request) (*Response, er
 		return nil, fmt.Errorf("GenerateContent: reading response body: %w", err)
 	}
 	if httpResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GenerateContent: HTTP status: %d, %s", httpResp.StatusCode, string(body))
+		return nil, &APIError{Op: "GenerateContent", StatusCode: httpResp.StatusCode, Header: httpResp.Header, Body: string(body)}
 	}
 	var res Response
 	if err := json.Unmarshal(body, &res); err != nil {
@@ -178,6 +248,101 @@ func (m Model) GenerateContent(ctx context.Context, req *Request) (*Response, er
 	return &res, nil
 }
 
+// StreamGenerateContent is like GenerateContent, but uses the streaming endpoint.
+// onChunk is called with each partial response as it arrives.
+// The returned Response has the chunks merged into a single candidate,
+// with adjacent text parts concatenated.
+func (m Model) StreamGenerateContent(ctx context.Context, req *Request, onChunk func(*Response)) (*Response, error) {
+	httpReq, err := m.newRequest(ctx, m.Model+":streamGenerateContent?alt=sse", req)
+	if err != nil {
+		return nil, err
+	}
+	httpResp, err := m.httpc().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("StreamGenerateContent: do: %w", err)
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(httpResp.Body)
+		return nil, &APIError{Op: "StreamGenerateContent", StatusCode: httpResp.StatusCode, Header: httpResp.Header, Body: string(body)}
+	}
+
+	res := &Response{Candidates: []Candidate{{Content: Content{Role: "model"}}}}
+	scanner := bufio.NewScanner(httpResp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var chunk Response
+		if err := json.Unmarshal([]byte(line[len("data: "):]), &chunk); err != nil {
+			return nil, fmt.Errorf("StreamGenerateContent: unmarshaling chunk: %w, %s", err, line)
+		}
+		if onChunk != nil {
+			onChunk(&chunk)
+		}
+		if len(chunk.Candidates) > 0 {
+			res.Candidates[0].Content.Parts = mergeParts(res.Candidates[0].Content.Parts, chunk.Candidates[0].Content.Parts)
+		}
+		// Each chunk's usage covers the response so far.
+		if chunk.UsageMetadata != nil {
+			res.UsageMetadata = chunk.UsageMetadata
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("StreamGenerateContent: reading stream: %w", err)
+	}
+	res.headers = httpResp.Header
+	return res, nil
+}
+
+// mergeParts appends streamed parts to parts, concatenating adjacent text parts.
+func mergeParts(parts, next []Part) []Part {
+	for _, p := range next {
+		if n := len(parts); n > 0 && p.Text != "" && isTextPart(parts[n-1]) && isTextPart(p) && parts[n-1].Thought == p.Thought {
+			parts[n-1].Text += p.Text
+			if p.ThoughtSignature != "" {
+				parts[n-1].ThoughtSignature = p.ThoughtSignature
+			}
+			continue
+		}
+		parts = append(parts, p)
+	}
+	return parts
+}
+
+func isTextPart(p Part) bool {
+	return p.FunctionCall == nil && p.FunctionResponse == nil && p.ExecutableCode == nil && p.CodeExecutionResult == nil
+}
+
+// CreateCachedContent stores cc for reuse by later requests to the model,
+// and returns it with its Name and ExpireTime set.
+func (m Model) CreateCachedContent(ctx context.Context, cc *CachedContent) (*CachedContent, error) {
+	cc.Model = m.Model
+	httpReq, err := m.newRequest(ctx, "cachedContents", cc)
+	if err != nil {
+		return nil, err
+	}
+	httpResp, err := m.httpc().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("CreateCachedContent: do: %w", err)
+	}
+	defer httpResp.Body.Close()
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("CreateCachedContent: reading response body: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, &APIError{Op: "CreateCachedContent", StatusCode: httpResp.StatusCode, Header: httpResp.Header, Body: string(body)}
+	}
+	var res CachedContent
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("CreateCachedContent: unmarshaling response: %w, %s", err, string(body))
+	}
+	return &res, nil
+}
+
 func (m Model) endpoint() string {
 	if m.Endpoint != "" {
 		return m.Endpoint
@@ -25,6 +25,54 @@ type Service interface {
 	MaxImageDimension() int
 }
 
+// StreamingService is an optional interface for services that can report
+// partial output while a response is being generated.
+type StreamingService interface {
+	Service
+	// DoStream is like Do, but calls onDelta with partial content as it arrives.
+	// onDelta is called from the goroutine that called DoStream.
+	// The returned Response is the same as Do would return.
+	// If the request is retried internally, deltas from the failed attempt
+	// are followed by a StreamDelta with Reset set.
+	DoStream(ctx context.Context, req *Request, onDelta func(StreamDelta)) (*Response, error)
+}
+
+// DoStream sends req to svc, streaming partial output to onDelta if svc supports it.
+// Services that do not implement StreamingService fall back to Do, and onDelta is never called.
+func DoStream(ctx context.Context, svc Service, req *Request, onDelta func(StreamDelta)) (*Response, error) {
+	if ss, ok := svc.(StreamingService); ok && onDelta != nil {
+		return ss.DoStream(ctx, req, onDelta)
+	}
+	return svc.Do(ctx, req)
+}
+
+// StreamDelta is an incremental piece of a response that is still being generated.
+// Deltas are ephemeral: the complete Response is authoritative.
+type StreamDelta struct {
+	// Index is the position of the content block this delta belongs to.
+	Index int
+	// Type is the type of the content block: text, thinking, or tool_use.
+	Type ContentType
+	// Text is appended to the block's text (ContentTypeText) or thinking (ContentTypeThinking).
+	Text string
+	// ToolUseID and ToolName are set on the first delta of a tool_use block.
+	ToolUseID string
+	ToolName  string
+	// ToolInput is a fragment of the tool_use block's JSON input.
+	ToolInput string
+	// Reset indicates that all previously streamed deltas should be discarded,
+	// for example because the request is being retried.
+	Reset bool
+}
+
+// ServerToolService is an optional interface for services whose provider can
+// run tools itself, such as web search.
+type ServerToolService interface {
+	// ServerTools returns the server tools among names that the service supports.
+	// Unknown names are ignored.
+	ServerTools(names []string) []*Tool
+}
+
 type SimplifiedPatcher interface {
 	// UseSimplifiedPatch reports whether the service should use the simplified patch input schema.
 	UseSimplifiedPatch() bool
@@ -37,6 +85,22 @@ func UseSimplifiedPatch(svc Service) bool {
 	return false
 }
 
+// RequestSizeLimiter is an optional interface for services whose API limits
+// the size of a request body.
+type RequestSizeLimiter interface {
+	// MaxRequestBytes returns the size of the largest request body the API accepts.
+	MaxRequestBytes() int
+}
+
+// MaxRequestBytes returns the size of the largest request body svc accepts,
+// or 0 if it doesn't say.
+func MaxRequestBytes(svc Service) int {
+	if rl, ok := svc.(RequestSizeLimiter); ok {
+		return rl.MaxRequestBytes()
+	}
+	return 0
+}
+
 // MustSchema validates that schema is a valid JSON schema and returns it as a json.RawMessage.
 // It panics if the schema is invalid.
 // The schema must have at least type="object" and a properties key.
@@ -64,9 +128,11 @@ func EmptySchema() json.RawMessage {
 type ErrorType string
 
 const (
-	ErrorTypeNone       ErrorType = ""            // Not an error
-	ErrorTypeTruncation ErrorType = "truncation"  // Response truncated due to max tokens
-	ErrorTypeLLMRequest ErrorType = "llm_request" // LLM request failed
+	ErrorTypeNone          ErrorType = ""               // Not an error
+	ErrorTypeTruncation    ErrorType = "truncation"     // Response truncated due to max tokens
+	ErrorTypeLLMRequest    ErrorType = "llm_request"    // LLM request failed
+	ErrorTypeBudget        ErrorType = "budget"         // Spending budget exceeded; the conversation is paused
+	ErrorTypeModelFallback ErrorType = "model_fallback" // Requests kept failing, so the loop switched to a fallback model
 )
 
 type Request struct {
@@ -74,6 +140,16 @@ type Request struct {
 	ToolChoice *ToolChoice
 	Tools      []*Tool
 	System     []SystemContent
+	// ThinkingLevel, if set, overrides the service's thinking level for this request.
+	ThinkingLevel *ThinkingLevel
+}
+
+// ThinkingLevelOr returns the request's thinking level, or level if it doesn't set one.
+func (r *Request) ThinkingLevelOr(level ThinkingLevel) ThinkingLevel {
+	if r.ThinkingLevel != nil {
+		return *r.ThinkingLevel
+	}
+	return level
 }
 
 // Message represents a message in the conversation.
@@ -88,7 +164,7 @@ type Message struct {
 	ExcludedFromContext bool `json:"ExcludedFromContext,omitempty"`
 
 	// ErrorType indicates this is a system-generated error message (not LLM content).
-	// Empty string means not an error. Values: "truncation", "llm_request".
+	// Empty string means not an error. Values: "truncation", "llm_request", "budget", "model_fallback".
 	ErrorType ErrorType `json:"ErrorType,omitempty"`
 }
 
@@ -121,6 +197,17 @@ type Tool struct {
 	EndsTurn bool
 	// Cache indicates whether to use prompt caching for this tool
 	Cache bool
+	// Sequential indicates that this tool mutates state shared with other tool calls,
+	// so it must not run concurrently with other calls from the same response.
+	Sequential bool
+	// SequentialCall, if set, decides Sequential for each call from its input,
+	// for tools where only some calls mutate shared state.
+	SequentialCall func(input json.RawMessage) bool `json:"-"`
+	// Server indicates a tool run by the provider, such as web search.
+	// Server tools have no Run function; see ServerToolService.
+	Server bool
+	// MaxUses limits how many times a server tool may be used in one response.
+	MaxUses int
 
 	// The Run function is automatically called when the tool is used.
 	// Run functions may be called concurrently with each other and themselves.
@@ -131,6 +218,15 @@ type Tool struct {
 	Run func(ctx context.Context, input json.RawMessage) ToolOut `json:"-"`
 }
 
+// RunsSequentially reports whether a call to t with input must not run
+// concurrently with other calls; see Sequential.
+func (t *Tool) RunsSequentially(input json.RawMessage) bool {
+	if t.SequentialCall != nil {
+		return t.SequentialCall(input)
+	}
+	return t.Sequential
+}
+
 // ToolOut represents the output of a tool run.
 type ToolOut struct {
 	// LLMContent is the output of the tool to be sent back to the LLM.
@@ -151,8 +247,11 @@ type Content struct {
 	Type ContentType
 	Text string
 
-	// Media type for image content
+	// Media type for image and document content. Images and documents are
+	// text content with a MediaType; see IsImage and IsDocument.
 	MediaType string
+	// Filename is the name of an attached document.
+	Filename string `json:",omitempty"`
 
 	// for thinking
 	Thinking  string
@@ -168,6 +267,13 @@ type Content struct {
 	ToolError  bool
 	ToolResult []Content
 
+	// for server_tool_result: the provider's result block, sent back verbatim
+	ServerToolResult json.RawMessage `json:",omitempty"`
+
+	// Citations is a JSON array of the sources a text block cites.
+	// Its format is provider specific.
+	Citations json.RawMessage `json:",omitempty"`
+
 	// timing information for tool_result; added externally; not sent to the LLM
 	ToolUseStartTime *time.Time
 	ToolUseEndTime   *time.Time
@@ -178,6 +284,24 @@ type Content struct {
 	Cache bool
 }
 
+// IsImage reports whether c is an image, whose base64 data is in Data.
+func (c Content) IsImage() bool {
+	return c.Type == ContentTypeText && strings.HasPrefix(c.MediaType, "image/")
+}
+
+// IsDocument reports whether c is an attached document, such as a PDF or a
+// text file. A PDF's base64 data is in Data. Text holds the document's text,
+// for models that can't read the document itself.
+func (c Content) IsDocument() bool {
+	return c.Type == ContentTypeText && c.MediaType != "" && !c.IsImage()
+}
+
+// DocumentText returns a document's text, labeled with its name, for
+// models that can't read the document itself.
+func (c Content) DocumentText() string {
+	return fmt.Sprintf("<document name=%q>\n%s\n</document>", c.Filename, c.Text)
+}
+
 func StringContent(s string) Content {
 	return Content{Type: ContentTypeText, Text: s}
 }
@@ -238,6 +362,12 @@ const (
 	StopReasonEndTurn
 	StopReasonToolUse
 	StopReasonRefusal
+	StopReasonPauseTurn // a long server tool turn was paused; send the conversation back to continue it
+
+	// Server tools run on the provider's side within a single response.
+	// These are appended here because content types are stored by value.
+	ContentTypeServerToolUse ContentType = iota
+	ContentTypeServerToolResult
 )
 
 // ThinkingLevel controls how much thinking/reasoning the model does.
@@ -266,6 +396,41 @@ func (t ThinkingLevel) ThinkingBudgetTokens() int {
 	}
 }
 
+var thinkingLevelNames = [...]string{"off", "minimal", "low", "medium", "high"}
+
+// Name returns the level's name, as used in the API: "off", "minimal", "low", "medium" or "high".
+func (t ThinkingLevel) Name() string {
+	if t < 0 || int(t) >= len(thinkingLevelNames) {
+		return t.String()
+	}
+	return thinkingLevelNames[t]
+}
+
+// ParseThinkingLevel returns the thinking level with the given name.
+func ParseThinkingLevel(name string) (ThinkingLevel, error) {
+	for i, n := range thinkingLevelNames {
+		if n == name {
+			return ThinkingLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown thinking level %q (want one of %s)", name, strings.Join(thinkingLevelNames[:], ", "))
+}
+
+// MarshalText encodes the level as its name.
+func (t ThinkingLevel) MarshalText() ([]byte, error) {
+	return []byte(t.Name()), nil
+}
+
+// UnmarshalText decodes a level from its name.
+func (t *ThinkingLevel) UnmarshalText(text []byte) error {
+	level, err := ParseThinkingLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*t = level
+	return nil
+}
+
 // ThinkingEffort returns the reasoning effort string for OpenAI's reasoning API.
 func (t ThinkingLevel) ThinkingEffort() string {
 	switch t {
@@ -299,7 +464,7 @@ func (m *Response) ToMessage() Message {
 	return Message{
 		Role:      m.Role,
 		Content:   m.Content,
-		EndOfTurn: m.StopReason != StopReasonToolUse, // End of turn unless there are tools to call
+		EndOfTurn: m.StopReason != StopReasonToolUse && m.StopReason != StopReasonPauseTurn, // End of turn unless there are tools to call or a paused turn to continue
 	}
 }
 
@@ -316,6 +481,36 @@ func CostUSDFromResponse(headers http.Header) float64 {
 	return cost
 }
 
+// Pricing is a model's price in USD per million tokens.
+// The zero value means the price is unknown.
+type Pricing struct {
+	InputPerMTok      float64
+	OutputPerMTok     float64
+	CacheReadPerMTok  float64
+	CacheWritePerMTok float64
+}
+
+func (p Pricing) IsZero() bool {
+	return p == Pricing{}
+}
+
+// Cost returns the cost in USD of the given token usage.
+func (p Pricing) Cost(u Usage) float64 {
+	return (float64(u.InputTokens)*p.InputPerMTok +
+		float64(u.OutputTokens)*p.OutputPerMTok +
+		float64(u.CacheReadInputTokens)*p.CacheReadPerMTok +
+		float64(u.CacheCreationInputTokens)*p.CacheWritePerMTok) / 1e6
+}
+
+// CostUSD returns the cost reported by the exe.dev gateway in headers, falling
+// back to computing it from u when the gateway header is absent.
+func (p Pricing) CostUSD(headers http.Header, u Usage) float64 {
+	if headers.Get("Exedev-Gateway-Cost") != "" {
+		return CostUSDFromResponse(headers)
+	}
+	return p.Cost(u)
+}
+
 // Usage represents the billing and rate-limit usage.
 // Most LLM structs do not have JSON tags, to avoid accidental direct use in specific providers.
 // However, the front-end uses this struct, and it relies on its JSON serialization.
@@ -325,6 +520,7 @@ type Usage struct {
 	CacheCreationInputTokens uint64     `json:"cache_creation_input_tokens"`
 	CacheReadInputTokens     uint64     `json:"cache_read_input_tokens"`
 	OutputTokens             uint64     `json:"output_tokens"`
+	ThinkingTokens           uint64     `json:"thinking_tokens,omitempty"` // the part of OutputTokens spent thinking, for providers that report it
 	CostUSD                  float64    `json:"cost_usd"`
 	Model                    string     `json:"model,omitempty"`
 	StartTime                *time.Time `json:"start_time,omitempty"`
@@ -336,6 +532,7 @@ func (u *Usage) Add(other Usage) {
 	u.CacheCreationInputTokens += other.CacheCreationInputTokens
 	u.CacheReadInputTokens += other.CacheReadInputTokens
 	u.OutputTokens += other.OutputTokens
+	u.ThinkingTokens += other.ThinkingTokens
 	u.CostUSD += other.CostUSD
 }
 
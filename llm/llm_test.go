@@ -547,3 +547,31 @@ func TestDumpToFile(t *testing.T) {
 	// This might fail due to permissions, but it shouldn't panic
 	_ = DumpToFile("test", "http://example.com", content)
 }
+
+func TestThinkingLevelText(t *testing.T) {
+	for level := ThinkingLevelOff; level <= ThinkingLevelHigh; level++ {
+		data, err := json.Marshal(level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got ThinkingLevel
+		if err := json.Unmarshal(data, &got); err != nil || got != level {
+			t.Errorf("%v round trips as %s to %v (%v)", level, data, got, err)
+		}
+	}
+	if data, _ := json.Marshal(ThinkingLevelMedium); string(data) != `"medium"` {
+		t.Errorf("ThinkingLevelMedium encodes as %s, want \"medium\"", data)
+	}
+	var level ThinkingLevel
+	if err := json.Unmarshal([]byte(`"max"`), &level); err == nil {
+		t.Error("expected an error for an unknown thinking level")
+	}
+
+	high := ThinkingLevelHigh
+	if got := (&Request{}).ThinkingLevelOr(ThinkingLevelLow); got != ThinkingLevelLow {
+		t.Errorf("ThinkingLevelOr without a level = %v, want the default", got)
+	}
+	if got := (&Request{ThinkingLevel: &high}).ThinkingLevelOr(ThinkingLevelLow); got != ThinkingLevelHigh {
+		t.Errorf("ThinkingLevelOr with a level = %v, want %v", got, ThinkingLevelHigh)
+	}
+}
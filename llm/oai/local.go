@@ -0,0 +1,194 @@
+package oai
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// LocalModel is a model served by a self-hosted OpenAI-compatible server,
+// such as Ollama, llama.cpp or vLLM.
+type LocalModel struct {
+	Name string
+	// ContextWindow is the model's context length in tokens, or 0 if the server doesn't report it.
+	ContextWindow int
+	// NativeTools reports whether the server accepts tool definitions for the model.
+	// If it doesn't, tools have to be described in the prompt; see Service.TextToolCalls.
+	NativeTools bool
+}
+
+// localModelEntry is an entry of the /models list. Only vLLM reports
+// max_model_len, and llama.cpp puts the training context in meta.
+type localModelEntry struct {
+	ID          string `json:"id"`
+	MaxModelLen int    `json:"max_model_len"`
+	Meta        struct {
+		NCtxTrain int `json:"n_ctx_train"`
+	} `json:"meta"`
+}
+
+// DiscoverLocalModels lists the models served at baseURL, the root of the
+// server's OpenAI-compatible API (e.g. "http://localhost:11434/v1"), and
+// probes each for its context window and tool-calling support.
+func DiscoverLocalModels(ctx context.Context, httpc *http.Client, baseURL, apiKey string) ([]LocalModel, error) {
+	entries, err := listLocalModels(ctx, httpc, baseURL, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	models := make([]LocalModel, 0, len(entries))
+	for _, entry := range entries {
+		models = append(models, probeLocalModel(ctx, httpc, baseURL, apiKey, entry))
+	}
+	return models, nil
+}
+
+// ProbeLocalModel returns the context window and tool-calling support of the
+// named model served at baseURL.
+func ProbeLocalModel(ctx context.Context, httpc *http.Client, baseURL, apiKey, name string) (LocalModel, error) {
+	entries, err := listLocalModels(ctx, httpc, baseURL, apiKey)
+	if err != nil {
+		return LocalModel{}, err
+	}
+	entry := localModelEntry{ID: name}
+	if i := slices.IndexFunc(entries, func(e localModelEntry) bool { return e.ID == name }); i >= 0 {
+		entry = entries[i]
+	}
+	return probeLocalModel(ctx, httpc, baseURL, apiKey, entry), nil
+}
+
+func listLocalModels(ctx context.Context, httpc *http.Client, baseURL, apiKey string) ([]localModelEntry, error) {
+	var list struct {
+		Data []localModelEntry `json:"data"`
+	}
+	if err := localRequest(ctx, httpc, "GET", strings.TrimSuffix(baseURL, "/")+"/models", apiKey, nil, &list); err != nil {
+		return nil, fmt.Errorf("listing models at %s: %w", baseURL, err)
+	}
+	return list.Data, nil
+}
+
+// probeLocalModel fills in what the server-specific endpoints say about a
+// model. Ollama has /api/show and llama.cpp has /props; when neither says
+// whether tools work (as with vLLM, which needs --enable-auto-tool-choice),
+// a one-token request with a tool decides.
+func probeLocalModel(ctx context.Context, httpc *http.Client, baseURL, apiKey string, entry localModelEntry) LocalModel {
+	model := LocalModel{Name: entry.ID, ContextWindow: entry.MaxModelLen}
+	root := strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/v1")
+
+	var tools *bool
+	var show struct {
+		Capabilities []string       `json:"capabilities"`
+		ModelInfo    map[string]any `json:"model_info"`
+		Parameters   string         `json:"parameters"`
+	}
+	if err := localRequest(ctx, httpc, "POST", root+"/api/show", apiKey, map[string]string{"model": entry.ID}, &show); err == nil {
+		model.ContextWindow = cmp.Or(ollamaContextWindow(show.Parameters, show.ModelInfo), model.ContextWindow)
+		if show.Capabilities != nil {
+			native := slices.Contains(show.Capabilities, "tools")
+			tools = &native
+		}
+	}
+
+	var props struct {
+		DefaultGenerationSettings struct {
+			NCtx int `json:"n_ctx"`
+		} `json:"default_generation_settings"`
+		ChatTemplateCaps map[string]bool `json:"chat_template_caps"`
+	}
+	if tools == nil {
+		if err := localRequest(ctx, httpc, "GET", root+"/props", apiKey, nil, &props); err == nil {
+			model.ContextWindow = cmp.Or(props.DefaultGenerationSettings.NCtx, model.ContextWindow)
+			if native, ok := props.ChatTemplateCaps["supports_tool_calls"]; ok {
+				tools = &native
+			}
+		}
+	}
+	model.ContextWindow = cmp.Or(model.ContextWindow, entry.Meta.NCtxTrain)
+
+	if tools != nil {
+		model.NativeTools = *tools
+	} else {
+		model.NativeTools = probeNativeTools(ctx, httpc, baseURL, apiKey, entry.ID)
+	}
+	return model
+}
+
+// ollamaContextWindow returns the context window from Ollama's /api/show
+// response. A num_ctx parameter in the Modelfile limits what the server
+// loads, so it takes precedence over the model's trained context length.
+func ollamaContextWindow(parameters string, info map[string]any) int {
+	for line := range strings.Lines(parameters) {
+		if name, value, ok := strings.Cut(strings.TrimSpace(line), " "); ok && name == "num_ctx" {
+			if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				return n
+			}
+		}
+	}
+	for key, value := range info {
+		if n, ok := value.(float64); ok && strings.HasSuffix(key, ".context_length") {
+			return int(n)
+		}
+	}
+	return 0
+}
+
+// probeNativeTools reports whether the server accepts a chat completion with
+// a tool definition for the model.
+func probeNativeTools(ctx context.Context, httpc *http.Client, baseURL, apiKey, name string) bool {
+	req := map[string]any{
+		"model":      name,
+		"messages":   []map[string]string{{"role": "user", "content": "Say hi."}},
+		"max_tokens": 1,
+		"tools": []map[string]any{{
+			"type": "function",
+			"function": map[string]any{
+				"name":        "noop",
+				"description": "Does nothing.",
+				"parameters":  map[string]any{"type": "object", "properties": map[string]any{}},
+			},
+		}},
+	}
+	return localRequest(ctx, httpc, "POST", strings.TrimSuffix(baseURL, "/")+"/chat/completions", apiKey, req, nil) == nil
+}
+
+// localRequest sends a JSON request to a local model server and decodes the
+// response into out, if it isn't nil.
+func localRequest(ctx context.Context, httpc *http.Client, method, url, apiKey string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := cmp.Or(httpc, http.DefaultClient).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: status %d: %s", method, url, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,98 @@
+package oai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscoverLocalModels(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  func(w http.ResponseWriter, r *http.Request)
+		expected []LocalModel
+	}{
+		{
+			name: "ollama",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/models":
+					w.Write([]byte(`{"data": [{"id": "qwen3:8b"}, {"id": "gemma2:9b"}]}`))
+				case "/api/show":
+					var req struct{ Model string }
+					json.NewDecoder(r.Body).Decode(&req)
+					if req.Model == "qwen3:8b" {
+						w.Write([]byte(`{"capabilities": ["completion", "tools"], "model_info": {"qwen3.context_length": 40960}}`))
+					} else {
+						w.Write([]byte(`{"capabilities": ["completion"], "model_info": {"gemma2.context_length": 8192}, "parameters": "stop \"<end_of_turn>\"\nnum_ctx 4096"}`))
+					}
+				default:
+					t.Errorf("unexpected request to %s", r.URL.Path)
+					http.NotFound(w, r)
+				}
+			},
+			expected: []LocalModel{
+				{Name: "qwen3:8b", ContextWindow: 40960, NativeTools: true},
+				{Name: "gemma2:9b", ContextWindow: 4096, NativeTools: false},
+			},
+		},
+		{
+			name: "llama.cpp",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/models":
+					w.Write([]byte(`{"data": [{"id": "model.gguf", "meta": {"n_ctx_train": 131072}}]}`))
+				case "/props":
+					w.Write([]byte(`{"default_generation_settings": {"n_ctx": 16384}, "chat_template_caps": {"supports_tool_calls": true}}`))
+				default:
+					http.NotFound(w, r)
+				}
+			},
+			expected: []LocalModel{{Name: "model.gguf", ContextWindow: 16384, NativeTools: true}},
+		},
+		{
+			name: "vllm without tool parser",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/models":
+					w.Write([]byte(`{"data": [{"id": "meta-llama/Llama-3.1-8B-Instruct", "max_model_len": 32768}]}`))
+				case "/v1/chat/completions":
+					http.Error(w, `{"message": "\"auto\" tool choice requires --enable-auto-tool-choice"}`, http.StatusBadRequest)
+				default:
+					http.NotFound(w, r)
+				}
+			},
+			expected: []LocalModel{{Name: "meta-llama/Llama-3.1-8B-Instruct", ContextWindow: 32768, NativeTools: false}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(tt.handler))
+			defer server.Close()
+
+			models, err := DiscoverLocalModels(context.Background(), nil, server.URL+"/v1", "")
+			if err != nil {
+				t.Fatalf("DiscoverLocalModels() error = %v", err)
+			}
+			if len(models) != len(tt.expected) {
+				t.Fatalf("got %+v, expected %+v", models, tt.expected)
+			}
+			for i := range models {
+				if models[i] != tt.expected[i] {
+					t.Errorf("model %d = %+v, expected %+v", i, models[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDiscoverLocalModelsUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	if _, err := DiscoverLocalModels(context.Background(), nil, server.URL+"/v1", ""); err == nil {
+		t.Error("expected an error for an unreachable server")
+	}
+}
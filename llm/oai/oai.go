@@ -6,9 +6,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"math/rand/v2"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -312,9 +314,19 @@ type Service struct {
 	ModelURL  string       // optional, overrides Model.URL
 	MaxTokens int          // defaults to DefaultMaxTokens if zero
 	Org       string       // optional - organization ID
+	Pricing   llm.Pricing  // used to compute cost when the gateway doesn't report it
+
+	// ContextWindow overrides the context window inferred from the model name, if nonzero.
+	ContextWindow int
+	// TextToolCalls describes tools in the system prompt and parses calls out of
+	// the model's text, for servers that don't accept tool definitions.
+	TextToolCalls bool
 }
 
-var _ llm.Service = (*Service)(nil)
+var (
+	_ llm.Service          = (*Service)(nil)
+	_ llm.StreamingService = (*Service)(nil)
+)
 
 // ModelsRegistry is a registry of all known models with their user-friendly names.
 var ModelsRegistry = []Model{
@@ -410,6 +422,13 @@ var (
 func fromLLMContent(c llm.Content) (string, []openai.ToolCall) {
 	switch c.Type {
 	case llm.ContentTypeText:
+		if c.IsImage() {
+			// Images are sent as message parts; see fromLLMMessage.
+			return "", nil
+		}
+		if c.IsDocument() {
+			return c.DocumentText(), nil
+		}
 		return c.Text, nil
 	case llm.ContentTypeToolUse:
 		// For OpenAI, tool use is sent as a null content with tool_calls in the message
@@ -501,12 +520,26 @@ func fromLLMMessage(msg llm.Message) []openai.ChatCompletionMessage {
 		// For assistant messages that contain tool calls
 		var toolCalls []openai.ToolCall
 		var textContent string
+		// Messages with images are sent as parts, one per content.
+		var parts []openai.ChatMessagePart
+		hasImage := slices.ContainsFunc(regularContent, llm.Content.IsImage)
 
 		for _, c := range regularContent {
+			if hasImage && c.IsImage() {
+				parts = append(parts, openai.ChatMessagePart{
+					Type:     openai.ChatMessagePartTypeImageURL,
+					ImageURL: &openai.ChatMessageImageURL{URL: "data:" + c.MediaType + ";base64," + c.Data},
+				})
+				continue
+			}
 			content, tools := fromLLMContent(c)
 			if len(tools) > 0 {
 				toolCalls = append(toolCalls, tools...)
 			} else if content != "" {
+				if hasImage {
+					parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: content})
+					continue
+				}
 				if textContent != "" {
 					textContent += "\n"
 				}
@@ -514,7 +547,11 @@ func fromLLMMessage(msg llm.Message) []openai.ChatCompletionMessage {
 			}
 		}
 
-		m.Content = textContent
+		if hasImage {
+			m.MultiContent = parts
+		} else {
+			m.Content = textContent
+		}
 		m.ToolCalls = toolCalls
 
 		messages = append(messages, m)
@@ -666,7 +703,10 @@ func (s *Service) toLLMUsage(au openai.Usage, headers http.Header) llm.Usage {
 		CacheReadInputTokens: cached,
 		OutputTokens:         out,
 	}
-	u.CostUSD = llm.CostUSDFromResponse(headers)
+	if au.CompletionTokensDetails != nil {
+		u.ThinkingTokens = uint64(au.CompletionTokensDetails.ReasoningTokens)
+	}
+	u.CostUSD = s.Pricing.CostUSD(headers, u)
 	return u
 }
 
@@ -722,6 +762,9 @@ func toStopReason(reason string) llm.StopReason {
 // TokenContextWindow returns the maximum token context window size for this service
 func (s *Service) TokenContextWindow() int {
 	// TODO: move TokenContextWindow information to Model struct
+	if s.ContextWindow > 0 {
+		return s.ContextWindow
+	}
 
 	model := cmp.Or(s.Model, DefaultModel)
 
@@ -756,6 +799,15 @@ func (s *Service) MaxImageDimension() int {
 
 // Do sends a request to OpenAI using the go-openai package.
 func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
+	return s.do(ctx, ir, nil)
+}
+
+// DoStream is like Do, but uses a streaming chat completion and reports partial output to onDelta.
+func (s *Service) DoStream(ctx context.Context, ir *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
+	return s.do(ctx, ir, onDelta)
+}
+
+func (s *Service) do(ctx context.Context, ir *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
 	// Configure the OpenAI client
 	httpc := cmp.Or(s.HTTPC, http.DefaultClient)
 	model := cmp.Or(s.Model, DefaultModel)
@@ -773,6 +825,11 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 
 	client := openai.NewClientWithConfig(config)
 
+	textTools := s.TextToolCalls && len(ir.Tools) > 0
+	if textTools {
+		ir = toTextToolRequest(ir)
+	}
+
 	// Start with system messages if provided
 	var allMessages []openai.ChatCompletionMessage
 	if len(ir.System) > 0 {
@@ -789,6 +846,9 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 	// Convert tools
 	var tools []openai.Tool
 	for _, t := range ir.Tools {
+		if t.Server {
+			continue // run by another provider
+		}
 		tools = append(tools, fromLLMTool(t))
 	}
 
@@ -800,6 +860,13 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 		ToolChoice:          fromLLMToolChoice(ir.ToolChoice), // TODO: make fromLLMToolChoice return an error when a perfect translation is not possible
 		MaxCompletionTokens: cmp.Or(s.MaxTokens, DefaultMaxTokens),
 	}
+	if model.IsReasoningModel && ir.ThinkingLevel != nil {
+		// Reasoning models can't stop reasoning, and most take only low, medium or high.
+		req.ReasoningEffort = "low"
+		if *ir.ThinkingLevel > llm.ThinkingLevelLow {
+			req.ReasoningEffort = ir.ThinkingLevel.ThinkingEffort()
+		}
+	}
 	// Construct the full URL for logging and debugging
 	fullURL := baseURL + "/chat/completions"
 
@@ -807,22 +874,36 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 	backoff := []time.Duration{1 * time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 15 * time.Second}
 
 	// retry loop
-	var errs error // accumulated errors across all attempts
+	var errs error               // accumulated errors across all attempts
+	var retryAfter time.Duration // requested by the last error response
 	for attempts := 0; ; attempts++ {
 		if attempts > 10 {
-			return nil, fmt.Errorf("openai request failed after %d attempts (url=%s, model=%s): %w", attempts, fullURL, model.ModelName, errs)
+			return nil, &llm.Error{Kind: llm.ErrorKindTransient, Err: fmt.Errorf("openai request failed after %d attempts (url=%s, model=%s): %w", attempts, fullURL, model.ModelName, errs)}
 		}
 		if attempts > 0 {
-			sleep := backoff[min(attempts, len(backoff)-1)] + time.Duration(rand.Int64N(int64(time.Second)))
+			sleep := max(backoff[min(attempts, len(backoff)-1)]+time.Duration(rand.Int64N(int64(time.Second))), retryAfter)
 			slog.WarnContext(ctx, "openai request sleep before retry", "sleep", sleep, "attempts", attempts)
 			time.Sleep(sleep)
 		}
 
-		resp, err := client.CreateChatCompletion(ctx, req)
+		var resp openai.ChatCompletionResponse
+		var err error
+		if onDelta != nil {
+			resp, err = createChatCompletionStream(ctx, client, req, onDelta)
+		} else {
+			resp, err = client.CreateChatCompletion(ctx, req)
+		}
 
 		// Handle successful response
 		if err == nil {
-			return s.toLLMResponse(&resp), nil
+			response := s.toLLMResponse(&resp)
+			if textTools {
+				parseTextToolCalls(response)
+			}
+			return response, nil
+		}
+		if onDelta != nil {
+			onDelta(llm.StreamDelta{Reset: true})
 		}
 
 		// Handle errors
@@ -833,38 +914,130 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 			continue
 		}
 
+		var reqErr *openai.RequestError
+		if errors.As(err, &reqErr) && reqErr.HTTPStatusCode != 0 {
+			// An error status without a structured error body
+			slog.WarnContext(ctx, "openai_request_failed", "error", err.Error(), "status_code", reqErr.HTTPStatusCode, "url", fullURL, "model", model.ModelName)
+			err = fmt.Errorf("attempt %d at %s: url=%s model=%s: %w", attempts+1, time.Now().Format(time.DateTime), fullURL, model.ModelName, err)
+			httpErr := llm.NewHTTPError(reqErr.HTTPStatusCode, nil, false, errors.Join(errs, err))
+			if !llm.ShouldRetry(ctx, httpErr) {
+				return nil, httpErr
+			}
+			errs = errors.Join(errs, err)
+			retryAfter = 0
+			continue
+		}
+
 		var apiErr *openai.APIError
 		if ok := errors.As(err, &apiErr); !ok {
 			// Not an OpenAI API error, return immediately with accumulated errors
 			return nil, errors.Join(errs, fmt.Errorf("attempt %d at %s: url=%s model=%s: %w", attempts+1, time.Now().Format(time.DateTime), fullURL, model.ModelName, err))
 		}
 
-		now := time.Now().Format(time.DateTime)
-		switch {
-		case apiErr.HTTPStatusCode >= 500:
-			// Server error, try again with backoff
-			slog.WarnContext(ctx, "openai_request_failed", "error", apiErr.Error(), "status_code", apiErr.HTTPStatusCode, "url", fullURL, "model", model.ModelName)
-			errs = errors.Join(errs, fmt.Errorf("attempt %d at %s: status %d (url=%s, model=%s): %s", attempts+1, now, apiErr.HTTPStatusCode, fullURL, model.ModelName, apiErr.Error()))
-			continue
-
-		case apiErr.HTTPStatusCode == 429:
-			// Rate limited, accumulate error and retry
-			slog.WarnContext(ctx, "openai_request_rate_limited", "error", apiErr.Error(), "url", fullURL, "model", model.ModelName)
-			errs = errors.Join(errs, fmt.Errorf("attempt %d at %s: status %d (rate limited, url=%s, model=%s): %s", attempts+1, now, apiErr.HTTPStatusCode, fullURL, model.ModelName, apiErr.Error()))
-			continue
+		// Errors the API responds with are classified. Retryable ones are
+		// retried unless the caller retries on its own; see llm.WithoutRetries.
+		slog.WarnContext(ctx, "openai_request_failed", "error", apiErr.Error(), "status_code", apiErr.HTTPStatusCode, "url", fullURL, "model", model.ModelName)
+		err = fmt.Errorf("attempt %d at %s: status %d (url=%s, model=%s): %s", attempts+1, time.Now().Format(time.DateTime), apiErr.HTTPStatusCode, fullURL, model.ModelName, apiErr.Error())
+		httpErr := llm.NewHTTPError(apiErr.HTTPStatusCode, nil, isContextLengthExceeded(apiErr.Code, apiErr.Message), errors.Join(errs, err))
+		if !llm.ShouldRetry(ctx, httpErr) {
+			return nil, httpErr
+		}
+		errs = errors.Join(errs, err)
+		retryAfter = 0
+	}
+}
 
-		case apiErr.HTTPStatusCode >= 400 && apiErr.HTTPStatusCode < 500:
-			// Client error, probably unrecoverable
-			slog.WarnContext(ctx, "openai_request_failed", "error", apiErr.Error(), "status_code", apiErr.HTTPStatusCode, "url", fullURL, "model", model.ModelName)
-			return nil, errors.Join(errs, fmt.Errorf("attempt %d at %s: status %d (url=%s, model=%s): %s", attempts+1, now, apiErr.HTTPStatusCode, fullURL, model.ModelName, apiErr.Error()))
+// isContextLengthExceeded reports whether an OpenAI-style error says the
+// request exceeds the model's context window.
+func isContextLengthExceeded(code any, message string) bool {
+	return code == "context_length_exceeded" || strings.Contains(message, "maximum context length")
+}
 
-		default:
-			// Other error, accumulate and retry
-			slog.WarnContext(ctx, "openai_request_failed", "error", apiErr.Error(), "status_code", apiErr.HTTPStatusCode, "url", fullURL, "model", model.ModelName)
-			errs = errors.Join(errs, fmt.Errorf("attempt %d at %s: status %d (url=%s, model=%s): %s", attempts+1, now, apiErr.HTTPStatusCode, fullURL, model.ModelName, apiErr.Error()))
+// createChatCompletionStream sends req as a streaming chat completion,
+// reporting deltas to onDelta, and assembles the chunks into a single response.
+// Reasoning content is streamed as thinking, but (as with CreateChatCompletion) is not part of the response.
+func createChatCompletionStream(ctx context.Context, client *openai.Client, req openai.ChatCompletionRequest, onDelta func(llm.StreamDelta)) (openai.ChatCompletionResponse, error) {
+	req.Stream = true
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, err := client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	defer stream.Close()
+
+	// Stream delta indexes: reasoning, then text, then tool calls.
+	const (
+		reasoningIndex = 0
+		textIndex      = 1
+		toolCallIndex  = 2
+	)
+
+	var (
+		resp      openai.ChatCompletionResponse
+		content   strings.Builder
+		toolCalls []openai.ToolCall
+		finish    openai.FinishReason
+	)
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return openai.ChatCompletionResponse{}, err
+		}
+		resp.ID = cmp.Or(resp.ID, chunk.ID)
+		resp.Model = cmp.Or(resp.Model, chunk.Model)
+		resp.Created = cmp.Or(resp.Created, chunk.Created)
+		if chunk.Usage != nil {
+			resp.Usage = *chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
 			continue
 		}
+		choice := chunk.Choices[0]
+		if choice.FinishReason != "" {
+			finish = choice.FinishReason
+		}
+		delta := choice.Delta
+		if delta.ReasoningContent != "" {
+			onDelta(llm.StreamDelta{Index: reasoningIndex, Type: llm.ContentTypeThinking, Text: delta.ReasoningContent})
+		}
+		if delta.Content != "" {
+			content.WriteString(delta.Content)
+			onDelta(llm.StreamDelta{Index: textIndex, Type: llm.ContentTypeText, Text: delta.Content})
+		}
+		for _, tc := range delta.ToolCalls {
+			i := len(toolCalls)
+			if tc.Index != nil {
+				i = *tc.Index
+			}
+			for len(toolCalls) <= i {
+				toolCalls = append(toolCalls, openai.ToolCall{Type: openai.ToolTypeFunction})
+			}
+			call := &toolCalls[i]
+			if tc.ID != "" || tc.Function.Name != "" {
+				call.ID += tc.ID
+				call.Function.Name += tc.Function.Name
+				onDelta(llm.StreamDelta{Index: toolCallIndex + i, Type: llm.ContentTypeToolUse, ToolUseID: call.ID, ToolName: call.Function.Name})
+			}
+			if tc.Function.Arguments != "" {
+				call.Function.Arguments += tc.Function.Arguments
+				onDelta(llm.StreamDelta{Index: toolCallIndex + i, Type: llm.ContentTypeToolUse, ToolInput: tc.Function.Arguments})
+			}
+		}
 	}
+
+	resp.Choices = []openai.ChatCompletionChoice{{
+		Message: openai.ChatCompletionMessage{
+			Role:      openai.ChatMessageRoleAssistant,
+			Content:   content.String(),
+			ToolCalls: toolCalls,
+		},
+		FinishReason: finish,
+	}}
+	resp.SetHeader(stream.Header())
+	return resp, nil
 }
 
 func (s *Service) UseSimplifiedPatch() bool {
@@ -1,6 +1,7 @@
 package oai
 
 import (
+	"bufio"
 	"bytes"
 	"cmp"
 	"context"
@@ -29,9 +30,13 @@ type ResponsesService struct {
 	Org           string            // optional - organization ID
 	DumpLLM       bool              // whether to dump request/response text to files for debugging; defaults to false
 	ThinkingLevel llm.ThinkingLevel // thinking level (ThinkingLevelOff disables reasoning)
+	Pricing       llm.Pricing       // used to compute cost when the gateway doesn't report it
 }
 
-var _ llm.Service = (*ResponsesService)(nil)
+var (
+	_ llm.Service          = (*ResponsesService)(nil)
+	_ llm.StreamingService = (*ResponsesService)(nil)
+)
 
 // Responses API request/response types
 
@@ -42,6 +47,7 @@ type responsesRequest struct {
 	ToolChoice      any                  `json:"tool_choice,omitempty"`
 	MaxOutputTokens int                  `json:"max_output_tokens,omitempty"`
 	Reasoning       *responsesReasoning  `json:"reasoning,omitempty"`
+	Stream          bool                 `json:"stream,omitempty"`
 }
 
 type responsesReasoning struct {
@@ -59,8 +65,11 @@ type responsesInputItem struct {
 }
 
 type responsesContent struct {
-	Type string `json:"type"` // "input_text", "output_text"
-	Text string `json:"text"`
+	Type     string `json:"type"` // "input_text", "output_text", "input_image", "input_file"
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"` // for input_image
+	Filename string `json:"filename,omitempty"`  // for input_file
+	FileData string `json:"file_data,omitempty"` // for input_file
 }
 
 type responsesTool struct {
@@ -167,7 +176,23 @@ func fromLLMMessageResponses(msg llm.Message) []responsesInputItem {
 		for _, c := range regularContent {
 			switch c.Type {
 			case llm.ContentTypeText:
-				if c.Text != "" {
+				if c.IsImage() {
+					messageContent = append(messageContent, responsesContent{
+						Type:     "input_image",
+						ImageURL: "data:" + c.MediaType + ";base64," + c.Data,
+					})
+				} else if c.IsDocument() && c.MediaType == "application/pdf" && c.Data != "" {
+					messageContent = append(messageContent, responsesContent{
+						Type:     "input_file",
+						Filename: cmp.Or(c.Filename, "document.pdf"),
+						FileData: "data:application/pdf;base64," + c.Data,
+					})
+				} else if c.IsDocument() {
+					messageContent = append(messageContent, responsesContent{
+						Type: "input_text",
+						Text: c.DocumentText(),
+					})
+				} else if c.Text != "" {
 					contentType := "input_text"
 					if msg.Role == llm.MessageRoleAssistant {
 						contentType = "output_text"
@@ -336,7 +361,10 @@ func (s *ResponsesService) toLLMUsageFromResponses(usage responsesUsage, headers
 		CacheReadInputTokens: cached,
 		OutputTokens:         out,
 	}
-	u.CostUSD = llm.CostUSDFromResponse(headers)
+	if usage.OutputTokensDetails != nil {
+		u.ThinkingTokens = uint64(usage.OutputTokensDetails.ReasoningTokens)
+	}
+	u.CostUSD = s.Pricing.CostUSD(headers, u)
 	return u
 }
 
@@ -367,8 +395,22 @@ func (s *ResponsesService) MaxImageDimension() int {
 	return 0 // No known limit
 }
 
+// MaxRequestBytes returns OpenAI's 50 MB limit on the inputs of a request.
+func (s *ResponsesService) MaxRequestBytes() int {
+	return 50 * 1024 * 1024
+}
+
 // Do sends a request to OpenAI using the Responses API.
 func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
+	return s.do(ctx, ir, nil)
+}
+
+// DoStream is like Do, but requests a streamed response and reports partial output to onDelta.
+func (s *ResponsesService) DoStream(ctx context.Context, ir *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
+	return s.do(ctx, ir, onDelta)
+}
+
+func (s *ResponsesService) do(ctx context.Context, ir *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
 	httpc := cmp.Or(s.HTTPC, http.DefaultClient)
 	model := cmp.Or(s.Model, DefaultModel)
 
@@ -388,6 +430,9 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 	// Convert tools
 	var tools []responsesTool
 	for _, t := range ir.Tools {
+		if t.Server {
+			continue // run by another provider
+		}
 		tools = append(tools, fromLLMToolResponses(t))
 	}
 
@@ -397,11 +442,12 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 		Input:           allInput,
 		Tools:           tools,
 		MaxOutputTokens: cmp.Or(s.MaxTokens, DefaultMaxTokens),
+		Stream:          onDelta != nil,
 	}
 
 	// Add reasoning if thinking is enabled
-	if s.ThinkingLevel != llm.ThinkingLevelOff {
-		effort := s.ThinkingLevel.ThinkingEffort()
+	if level := ir.ThinkingLevelOr(s.ThinkingLevel); level != llm.ThinkingLevelOff {
+		effort := level.ThinkingEffort()
 		if effort != "" {
 			req.Reasoning = &responsesReasoning{Effort: effort}
 		}
@@ -435,13 +481,14 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 	backoff := []time.Duration{1 * time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 15 * time.Second}
 
 	// retry loop
-	var errs error // accumulated errors across all attempts
+	var errs error               // accumulated errors across all attempts
+	var retryAfter time.Duration // requested by the last error response
 	for attempts := 0; ; attempts++ {
 		if attempts > 10 {
-			return nil, fmt.Errorf("responses request failed after %d attempts (url=%s, model=%s): %w", attempts, fullURL, model.ModelName, errs)
+			return nil, &llm.Error{Kind: llm.ErrorKindTransient, Err: fmt.Errorf("responses request failed after %d attempts (url=%s, model=%s): %w", attempts, fullURL, model.ModelName, errs)}
 		}
 		if attempts > 0 {
-			sleep := backoff[min(attempts, len(backoff)-1)] + time.Duration(rand.Int64N(int64(time.Second)))
+			sleep := max(backoff[min(attempts, len(backoff)-1)]+time.Duration(rand.Int64N(int64(time.Second))), retryAfter)
 			slog.WarnContext(ctx, "responses request sleep before retry", "sleep", sleep, "attempts", attempts)
 			time.Sleep(sleep)
 		}
@@ -466,43 +513,43 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 		}
 		defer httpResp.Body.Close()
 
+		// Streamed responses are assembled as the events arrive
+		if req.Stream && httpResp.StatusCode == http.StatusOK {
+			resp, err := parseResponsesStream(httpResp.Body, onDelta)
+			if err != nil {
+				errs = errors.Join(errs, fmt.Errorf("attempt %d at %s: %w", attempts+1, time.Now().Format(time.DateTime), err))
+				onDelta(llm.StreamDelta{Reset: true})
+				continue
+			}
+			return s.finishResponse(ctx, resp, httpResp.Header)
+		}
+
 		// Read response body
 		body, err := io.ReadAll(httpResp.Body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read response body: %w", err)
 		}
 
-		// Handle non-200 responses
+		// Handle non-200 responses. Errors the API responds with are
+		// classified. Retryable ones are retried (honoring Retry-After) unless
+		// the caller retries on its own; see llm.WithoutRetries.
 		if httpResp.StatusCode != http.StatusOK {
 			var apiErr responsesError
+			message := string(body)
 			if jsonErr := json.Unmarshal(body, &struct {
 				Error *responsesError `json:"error"`
 			}{Error: &apiErr}); jsonErr == nil && apiErr.Message != "" {
-				// We have a structured error
-				now := time.Now().Format(time.DateTime)
-				switch {
-				case httpResp.StatusCode >= 500:
-					// Server error, retry
-					slog.WarnContext(ctx, "responses_request_failed", "error", apiErr.Message, "status_code", httpResp.StatusCode, "url", fullURL, "model", model.ModelName)
-					errs = errors.Join(errs, fmt.Errorf("attempt %d at %s: status %d (url=%s, model=%s): %s", attempts+1, now, httpResp.StatusCode, fullURL, model.ModelName, apiErr.Message))
-					continue
-
-				case httpResp.StatusCode == 429:
-					// Rate limited, retry
-					slog.WarnContext(ctx, "responses_request_rate_limited", "error", apiErr.Message, "url", fullURL, "model", model.ModelName)
-					errs = errors.Join(errs, fmt.Errorf("attempt %d at %s: status %d (rate limited, url=%s, model=%s): %s", attempts+1, now, httpResp.StatusCode, fullURL, model.ModelName, apiErr.Message))
-					continue
-
-				case httpResp.StatusCode >= 400 && httpResp.StatusCode < 500:
-					// Client error, probably unrecoverable
-					slog.WarnContext(ctx, "responses_request_failed", "error", apiErr.Message, "status_code", httpResp.StatusCode, "url", fullURL, "model", model.ModelName)
-					return nil, errors.Join(errs, fmt.Errorf("attempt %d at %s: status %d (url=%s, model=%s): %s", attempts+1, now, httpResp.StatusCode, fullURL, model.ModelName, apiErr.Message))
-				}
+				message = apiErr.Message
 			}
-
-			// No structured error, use the raw body
-			slog.WarnContext(ctx, "responses_request_failed", "status_code", httpResp.StatusCode, "url", fullURL, "model", model.ModelName, "body", string(body))
-			return nil, fmt.Errorf("status %d (url=%s, model=%s): %s", httpResp.StatusCode, fullURL, model.ModelName, string(body))
+			slog.WarnContext(ctx, "responses_request_failed", "error", message, "status_code", httpResp.StatusCode, "url", fullURL, "model", model.ModelName)
+			err := fmt.Errorf("attempt %d at %s: status %d (url=%s, model=%s): %s", attempts+1, time.Now().Format(time.DateTime), httpResp.StatusCode, fullURL, model.ModelName, message)
+			httpErr := llm.NewHTTPError(httpResp.StatusCode, httpResp.Header, isContextLengthExceeded(apiErr.Code, apiErr.Message), errors.Join(errs, err))
+			if !llm.ShouldRetry(ctx, httpErr) {
+				return nil, httpErr
+			}
+			errs = errors.Join(errs, err)
+			retryAfter = httpErr.RetryAfter
+			continue
 		}
 
 		// Parse successful response
@@ -511,22 +558,88 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 		}
 
-		// Check for errors in the response
-		if resp.Error != nil {
-			return nil, fmt.Errorf("response contains error: %s", resp.Error.Message)
+		return s.finishResponse(ctx, &resp, httpResp.Header)
+	}
+}
+
+// finishResponse checks a parsed Responses API response for errors, dumps it if enabled,
+// and converts it to an llm.Response.
+func (s *ResponsesService) finishResponse(ctx context.Context, resp *responsesResponse, headers http.Header) (*llm.Response, error) {
+	// Check for errors in the response
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response contains error: %s", resp.Error.Message)
+	}
+
+	// Dump response if enabled
+	if s.DumpLLM {
+		if respJSON, err := json.MarshalIndent(resp, "", "  "); err == nil {
+			if err := llm.DumpToFile("response", "", respJSON); err != nil {
+				slog.WarnContext(ctx, "failed to dump responses response to file", "error", err)
+			}
 		}
+	}
 
-		// Dump response if enabled
-		if s.DumpLLM {
-			if respJSON, err := json.MarshalIndent(resp, "", "  "); err == nil {
-				if err := llm.DumpToFile("response", "", respJSON); err != nil {
-					slog.WarnContext(ctx, "failed to dump responses response to file", "error", err)
-				}
+	return s.toLLMResponseFromResponses(resp, headers), nil
+}
+
+// responsesStreamEvent is a single SSE event from the streaming Responses API.
+// See https://platform.openai.com/docs/api-reference/responses-streaming
+type responsesStreamEvent struct {
+	Type        string               `json:"type"`
+	OutputIndex int                  `json:"output_index"`
+	Delta       string               `json:"delta,omitempty"`
+	Item        *responsesOutputItem `json:"item,omitempty"`
+	Response    *responsesResponse   `json:"response,omitempty"`
+	Message     string               `json:"message,omitempty"` // for error events
+}
+
+// parseResponsesStream reads a Responses API SSE stream, reporting deltas to onDelta,
+// and returns the final response carried by the response.completed event.
+func parseResponsesStream(r io.Reader, onDelta func(llm.StreamDelta)) (*responsesResponse, error) {
+	scanner := bufio.NewScanner(r)
+	// SSE lines can be large (the final event carries the whole response)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		data := line[len("data: "):]
+		if data == "[DONE]" {
+			break
+		}
+
+		var event responsesStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, fmt.Errorf("parsing SSE event: %w", err)
+		}
+
+		switch event.Type {
+		case "response.output_item.added":
+			if event.Item != nil && event.Item.Type == "function_call" {
+				onDelta(llm.StreamDelta{Index: event.OutputIndex, Type: llm.ContentTypeToolUse, ToolUseID: event.Item.CallID, ToolName: event.Item.Name})
 			}
+		case "response.output_text.delta":
+			onDelta(llm.StreamDelta{Index: event.OutputIndex, Type: llm.ContentTypeText, Text: event.Delta})
+		case "response.reasoning_summary_text.delta":
+			onDelta(llm.StreamDelta{Index: event.OutputIndex, Type: llm.ContentTypeThinking, Text: event.Delta})
+		case "response.function_call_arguments.delta":
+			onDelta(llm.StreamDelta{Index: event.OutputIndex, Type: llm.ContentTypeToolUse, ToolInput: event.Delta})
+		case "response.completed", "response.incomplete", "response.failed":
+			if event.Response == nil {
+				return nil, fmt.Errorf("%s event has no response", event.Type)
+			}
+			return event.Response, nil
+		case "error":
+			return nil, fmt.Errorf("stream error event: %s", event.Message)
 		}
+	}
 
-		return s.toLLMResponseFromResponses(&resp, httpResp.Header), nil
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading SSE stream: %w", err)
 	}
+	return nil, fmt.Errorf("incomplete stream: no response.completed event received")
 }
 
 func (s *ResponsesService) UseSimplifiedPatch() bool {
@@ -3,10 +3,13 @@ package oai
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
 	"testing"
+	"time"
 
 	"shelley.exe.dev/llm"
 )
@@ -603,3 +606,152 @@ func TestResponsesServiceDoWithCaching(t *testing.T) {
 		t.Errorf("resp.Usage.ContextWindowUsed() = %d, expected 150", resp.Usage.ContextWindowUsed())
 	}
 }
+
+func TestResponsesServiceDoStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body responsesRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		if !body.Stream {
+			t.Error("expected stream=true in request")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"response.created","response":{"id":"resp_1","status":"in_progress"}}`,
+			`{"type":"response.output_text.delta","output_index":0,"content_index":0,"delta":"Hi "}`,
+			`{"type":"response.output_text.delta","output_index":0,"content_index":0,"delta":"there"}`,
+			`{"type":"response.output_item.added","output_index":1,"item":{"type":"function_call","call_id":"call_1","name":"bash"}}`,
+			`{"type":"response.function_call_arguments.delta","output_index":1,"delta":"{}"}`,
+			`{"type":"response.completed","response":{"id":"resp_1","model":"test-model","status":"completed","output":[` +
+				`{"type":"message","role":"assistant","content":[{"type":"output_text","text":"Hi there"}]},` +
+				`{"type":"function_call","call_id":"call_1","name":"bash","arguments":"{}"}` +
+				`],"usage":{"input_tokens":10,"output_tokens":4}}}`,
+		}
+		for _, e := range events {
+			fmt.Fprintf(w, "data: %s\n\n", e)
+		}
+	}))
+	defer server.Close()
+
+	svc := &ResponsesService{
+		APIKey:   "test-api-key",
+		Model:    Model{ModelName: "test-model"},
+		ModelURL: server.URL,
+	}
+	req := &llm.Request{Messages: []llm.Message{llm.UserStringMessage("Hello!")}}
+
+	var deltas []llm.StreamDelta
+	resp, err := svc.DoStream(context.Background(), req, func(d llm.StreamDelta) {
+		deltas = append(deltas, d)
+	})
+	if err != nil {
+		t.Fatalf("DoStream() error = %v", err)
+	}
+	want := []llm.StreamDelta{
+		{Index: 0, Type: llm.ContentTypeText, Text: "Hi "},
+		{Index: 0, Type: llm.ContentTypeText, Text: "there"},
+		{Index: 1, Type: llm.ContentTypeToolUse, ToolUseID: "call_1", ToolName: "bash"},
+		{Index: 1, Type: llm.ContentTypeToolUse, ToolInput: "{}"},
+	}
+	if len(deltas) != len(want) {
+		t.Fatalf("got %d deltas, want %d: %+v", len(deltas), len(want), deltas)
+	}
+	for i := range want {
+		if deltas[i] != want[i] {
+			t.Errorf("delta[%d] = %+v, want %+v", i, deltas[i], want[i])
+		}
+	}
+	if len(resp.Content) != 2 || resp.Content[0].Text != "Hi there" {
+		t.Errorf("resp.Content = %+v", resp.Content)
+	}
+	if resp.StopReason != llm.StopReasonToolUse {
+		t.Errorf("resp.StopReason = %v, want %v", resp.StopReason, llm.StopReasonToolUse)
+	}
+}
+
+func TestResponsesServiceDoClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		wantKind   llm.ErrorKind
+	}{
+		{"rate limit", 429, `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`, llm.ErrorKindRateLimit},
+		{"context length", 400, `{"error":{"message":"Your input exceeds the context window of this model.","type":"invalid_request_error","code":"context_length_exceeded"}}`, llm.ErrorKindContextTooLong},
+		{"server error", 500, `{"error":{"message":"internal error","type":"server_error"}}`, llm.ErrorKindTransient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Retry-After", "3")
+				w.WriteHeader(tt.statusCode)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			svc := &ResponsesService{APIKey: "test-api-key", Model: GPT41, ModelURL: server.URL}
+			req := &llm.Request{
+				Messages: []llm.Message{{
+					Role:    llm.MessageRoleUser,
+					Content: []llm.Content{{Type: llm.ContentTypeText, Text: "Hello!"}},
+				}},
+			}
+			_, err := svc.Do(llm.WithoutRetries(context.Background()), req)
+			if err == nil {
+				t.Fatal("Do() error = nil, want error")
+			}
+			if kind := llm.ErrorKindOf(err); kind != tt.wantKind {
+				t.Errorf("ErrorKindOf() = %q, want %q (err: %v)", kind, tt.wantKind, err)
+			}
+			if d := llm.RetryAfterOf(err); d != 3*time.Second {
+				t.Errorf("RetryAfterOf() = %v, want 3s", d)
+			}
+			if calls != 1 {
+				t.Errorf("expected 1 request, got %d", calls)
+			}
+		})
+	}
+}
+
+func TestResponsesServiceThinkingLevel(t *testing.T) {
+	var efforts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req responsesRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		effort := ""
+		if req.Reasoning != nil {
+			effort = req.Reasoning.Effort
+		}
+		efforts = append(efforts, effort)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(responsesResponse{
+			ID:     "responses-think",
+			Output: []responsesOutputItem{{Type: "message", Role: "assistant", Content: []responsesContent{{Type: "text", Text: "ok"}}}},
+			Usage: responsesUsage{
+				InputTokens:         10,
+				OutputTokens:        50,
+				OutputTokensDetails: &responsesOutputTokensDetails{ReasoningTokens: 40},
+			},
+		})
+	}))
+	defer server.Close()
+
+	svc := &ResponsesService{APIKey: "test-api-key", Model: GPT52Codex, ModelURL: server.URL, ThinkingLevel: llm.ThinkingLevelMedium}
+	msgs := []llm.Message{{Role: llm.MessageRoleUser, Content: []llm.Content{llm.StringContent("Hello!")}}}
+	high, off := llm.ThinkingLevelHigh, llm.ThinkingLevelOff
+	for _, level := range []*llm.ThinkingLevel{nil, &high, &off} {
+		resp, err := svc.Do(context.Background(), &llm.Request{Messages: msgs, ThinkingLevel: level})
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if resp.Usage.ThinkingTokens != 40 || resp.Usage.OutputTokens != 50 {
+			t.Errorf("usage = %+v, want 40 of 50 output tokens spent thinking", resp.Usage)
+		}
+	}
+	if want := []string{"medium", "high", ""}; !slices.Equal(efforts, want) {
+		t.Errorf("reasoning efforts = %q, want %q", efforts, want)
+	}
+}
@@ -3,8 +3,11 @@ package oai
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -365,6 +368,40 @@ func TestFromLLMContent(t *testing.T) {
 	}
 }
 
+func TestFromLLMMessageAttachments(t *testing.T) {
+	msg := llm.Message{
+		Role: llm.MessageRoleUser,
+		Content: []llm.Content{
+			{Type: llm.ContentTypeText, Text: "What's this?"},
+			{Type: llm.ContentTypeText, MediaType: "image/png", Data: "iVBORw0KGgo="},
+			{Type: llm.ContentTypeText, MediaType: "text/plain", Text: "notes", Filename: "notes.txt"},
+		},
+	}
+	messages := fromLLMMessage(msg)
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	parts := messages[0].MultiContent
+	if messages[0].Content != "" || len(parts) != 3 {
+		t.Fatalf("expected 3 parts and no content, got %+v", messages[0])
+	}
+	if parts[0].Type != openai.ChatMessagePartTypeText || parts[0].Text != "What's this?" {
+		t.Errorf("unexpected text part: %+v", parts[0])
+	}
+	if parts[1].Type != openai.ChatMessagePartTypeImageURL || parts[1].ImageURL.URL != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Errorf("unexpected image part: %+v", parts[1])
+	}
+	if want := "<document name=\"notes.txt\">\nnotes\n</document>"; parts[2].Text != want {
+		t.Errorf("document part text = %q, expected %q", parts[2].Text, want)
+	}
+
+	// Without images, documents are inlined as text.
+	messages = fromLLMMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{msg.Content[0], msg.Content[2]}})
+	if len(messages[0].MultiContent) != 0 || !strings.Contains(messages[0].Content, "<document name=") {
+		t.Errorf("expected the document inlined, got %+v", messages[0])
+	}
+}
+
 func TestToRawLLMContent(t *testing.T) {
 	content := toRawLLMContent("test text")
 	if content.Type != llm.ContentTypeText {
@@ -845,6 +882,29 @@ func TestToLLMUsage(t *testing.T) {
 	}
 }
 
+func TestToLLMUsagePricing(t *testing.T) {
+	service := &Service{Pricing: llm.Pricing{InputPerMTok: 2, OutputPerMTok: 8, CacheReadPerMTok: 0.5}}
+	openaiUsage := openai.Usage{
+		PromptTokens:     3_000_000,
+		CompletionTokens: 1_000_000,
+		PromptTokensDetails: &openai.PromptTokensDetails{
+			CachedTokens: 2_000_000,
+		},
+	}
+
+	// Without the gateway header, cost comes from the pricing table:
+	// 1M uncached input * $2 + 2M cached * $0.5 + 1M output * $8 = $11.
+	usage := service.toLLMUsage(openaiUsage, nil)
+	if math.Abs(usage.CostUSD-11) > 1e-9 {
+		t.Errorf("toLLMUsage().CostUSD = %v, expected 11", usage.CostUSD)
+	}
+
+	usage = service.toLLMUsage(openaiUsage, http.Header{"Exedev-Gateway-Cost": []string{"0.5"}})
+	if usage.CostUSD != 0.5 {
+		t.Errorf("toLLMUsage().CostUSD with gateway header = %v, expected 0.5", usage.CostUSD)
+	}
+}
+
 func TestToLLMResponse(t *testing.T) {
 	// Create a service instance
 	service := &Service{}
@@ -1324,3 +1384,70 @@ func TestServiceDo(t *testing.T) {
 		t.Errorf("resp.Usage.OutputTokens = %d, expected 20", resp.Usage.OutputTokens)
 	}
 }
+
+func TestServiceDoStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] != true {
+			t.Errorf("expected stream=true in request, got %v", body["stream"])
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []string{
+			`{"id":"chatcmpl-1","model":"gpt-4.1","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+			`{"id":"chatcmpl-1","model":"gpt-4.1","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+			`{"id":"chatcmpl-1","model":"gpt-4.1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"bash","arguments":""}}]}}]}`,
+			`{"id":"chatcmpl-1","model":"gpt-4.1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"command\":"}}]}}]}`,
+			`{"id":"chatcmpl-1","model":"gpt-4.1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"ls\"}"}}]},"finish_reason":"tool_calls"}]}`,
+			`{"id":"chatcmpl-1","model":"gpt-4.1","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+		}
+		for _, c := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", c)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	svc := &Service{
+		APIKey:   "test-api-key",
+		Model:    GPT41,
+		ModelURL: server.URL + "/v1",
+	}
+	req := &llm.Request{Messages: []llm.Message{llm.UserStringMessage("Hello!")}}
+
+	var text, input strings.Builder
+	var toolName string
+	resp, err := svc.DoStream(context.Background(), req, func(d llm.StreamDelta) {
+		switch d.Type {
+		case llm.ContentTypeText:
+			text.WriteString(d.Text)
+		case llm.ContentTypeToolUse:
+			toolName += d.ToolName
+			input.WriteString(d.ToolInput)
+		}
+	})
+	if err != nil {
+		t.Fatalf("DoStream() error = %v", err)
+	}
+	if text.String() != "Hello" {
+		t.Errorf("streamed text = %q, want %q", text.String(), "Hello")
+	}
+	if toolName != "bash" || input.String() != `{"command":"ls"}` {
+		t.Errorf("streamed tool = %q %q, want bash {\"command\":\"ls\"}", toolName, input.String())
+	}
+	if resp.StopReason != llm.StopReasonToolUse {
+		t.Errorf("resp.StopReason = %v, want %v", resp.StopReason, llm.StopReasonToolUse)
+	}
+	if len(resp.Content) != 2 {
+		t.Fatalf("resp.Content length = %d, want 2", len(resp.Content))
+	}
+	if resp.Content[0].Text != "Hello" {
+		t.Errorf("resp.Content[0].Text = %q, want %q", resp.Content[0].Text, "Hello")
+	}
+	if resp.Content[1].ID != "call_1" || string(resp.Content[1].ToolInput) != `{"command":"ls"}` {
+		t.Errorf("resp.Content[1] = %+v", resp.Content[1])
+	}
+	if resp.Usage.InputTokens != 10 || resp.Usage.OutputTokens != 5 {
+		t.Errorf("resp.Usage = %+v, want 10 in, 5 out", resp.Usage)
+	}
+}
@@ -0,0 +1,155 @@
+package oai
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"shelley.exe.dev/llm"
+)
+
+// Text tool calls are for models whose server doesn't accept tool
+// definitions. The tools are described in the system prompt, the model writes
+// each call as a JSON object between <tool_call> tags (the format many open
+// models are trained on), and results are sent back in <tool_result> tags.
+
+const (
+	toolCallOpen  = "<tool_call>"
+	toolCallClose = "</tool_call>"
+)
+
+// textToolCall is the JSON a model writes between <tool_call> tags.
+type textToolCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// toTextToolRequest rewrites ir to describe its tools in the system prompt
+// and to carry earlier tool calls and results as text.
+func toTextToolRequest(ir *llm.Request) *llm.Request {
+	out := *ir
+	out.Tools = nil
+	out.ToolChoice = nil
+	out.System = append(append([]llm.SystemContent(nil), ir.System...), llm.SystemContent{Text: textToolPrompt(ir.Tools, ir.ToolChoice)})
+
+	toolNames := map[string]string{} // tool use ID to tool name
+	out.Messages = make([]llm.Message, len(ir.Messages))
+	for i, msg := range ir.Messages {
+		converted := msg
+		converted.Content = nil
+		for _, c := range msg.Content {
+			switch c.Type {
+			case llm.ContentTypeToolUse:
+				toolNames[c.ID] = c.ToolName
+				call, _ := json.Marshal(textToolCall{Name: c.ToolName, Arguments: c.ToolInput})
+				converted.Content = append(converted.Content, llm.Content{Type: llm.ContentTypeText, Text: toolCallOpen + "\n" + string(call) + "\n" + toolCallClose})
+			case llm.ContentTypeToolResult:
+				converted.Content = append(converted.Content, llm.Content{Type: llm.ContentTypeText, Text: textToolResult(toolNames[c.ToolUseID], c)})
+			case llm.ContentTypeThinking, llm.ContentTypeRedactedThinking:
+				// Not sent back, as with native tool calls.
+			default:
+				converted.Content = append(converted.Content, c)
+			}
+		}
+		out.Messages[i] = converted
+	}
+	return &out
+}
+
+// textToolPrompt describes tools and how to call them.
+func textToolPrompt(tools []*llm.Tool, choice *llm.ToolChoice) string {
+	var b strings.Builder
+	b.WriteString("# Tools\n\n")
+	b.WriteString("You can call the tools below. To call a tool, write a JSON object with the tool's name and arguments between <tool_call> tags, like this:\n\n")
+	b.WriteString(toolCallOpen + "\n{\"name\": \"tool_name\", \"arguments\": {\"argument\": \"value\"}}\n" + toolCallClose + "\n\n")
+	b.WriteString("You may make several tool calls in one reply. After your tool calls, stop writing: the results will be sent to you in <tool_result> tags.\n")
+	if choice != nil {
+		switch choice.Type {
+		case llm.ToolChoiceTypeAny:
+			b.WriteString("You must call a tool in your reply.\n")
+		case llm.ToolChoiceTypeTool:
+			fmt.Fprintf(&b, "You must call the %s tool in your reply.\n", choice.Name)
+		}
+	}
+	b.WriteString("\nAvailable tools:\n")
+	for _, t := range tools {
+		if t.Server {
+			continue // run by another provider
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n\nArguments JSON schema: %s\n", t.Name, strings.TrimSpace(t.Description), t.InputSchema)
+	}
+	return b.String()
+}
+
+// textToolResult formats a tool result as text.
+func textToolResult(name string, c llm.Content) string {
+	var texts []string
+	for _, result := range c.ToolResult {
+		if strings.TrimSpace(result.Text) != "" {
+			texts = append(texts, result.Text)
+		}
+	}
+	attrs := fmt.Sprintf(" name=%q", name)
+	if c.ToolError {
+		attrs += ` error="true"`
+	}
+	return "<tool_result" + attrs + ">\n" + strings.Join(texts, "\n") + "\n</tool_result>"
+}
+
+// parseTextToolCalls turns the <tool_call> blocks in resp's text into tool
+// uses. Text before the first call is kept; anything after the calls is
+// dropped, since models sometimes go on to imagine the results. Blocks that
+// aren't valid calls are left as text.
+func parseTextToolCalls(resp *llm.Response) {
+	var content []llm.Content
+	for _, c := range resp.Content {
+		if c.Type != llm.ContentTypeText {
+			content = append(content, c)
+			continue
+		}
+		text := c.Text
+		var calls []llm.Content
+		for {
+			start := strings.Index(text, toolCallOpen)
+			if start < 0 {
+				break
+			}
+			body, rest, closed := strings.Cut(text[start+len(toolCallOpen):], toolCallClose)
+			var call textToolCall
+			if json.Unmarshal([]byte(strings.TrimSpace(body)), &call) != nil || call.Name == "" {
+				break
+			}
+			if len(calls) == 0 {
+				if before := strings.TrimSpace(text[:start]); before != "" {
+					content = append(content, llm.Content{Type: llm.ContentTypeText, Text: before})
+				}
+			}
+			calls = append(calls, llm.Content{
+				ID:        fmt.Sprintf("call_%016x", rand.Uint64()),
+				Type:      llm.ContentTypeToolUse,
+				ToolName:  call.Name,
+				ToolInput: toolInput(call.Arguments),
+			})
+			if !closed {
+				break
+			}
+			text = rest
+		}
+		if len(calls) == 0 {
+			content = append(content, c)
+			continue
+		}
+		content = append(content, calls...)
+		resp.StopReason = llm.StopReasonToolUse
+	}
+	resp.Content = content
+}
+
+// toolInput returns args as a tool input, treating missing arguments as empty.
+func toolInput(args json.RawMessage) json.RawMessage {
+	if len(args) == 0 || string(args) == "null" {
+		return json.RawMessage("{}")
+	}
+	return args
+}
@@ -0,0 +1,95 @@
+package oai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"shelley.exe.dev/llm"
+)
+
+func TestParseTextToolCalls(t *testing.T) {
+	resp := &llm.Response{
+		StopReason: llm.StopReasonStopSequence,
+		Content: []llm.Content{{
+			Type: llm.ContentTypeText,
+			Text: "Let me look.\n<tool_call>\n{\"name\": \"bash\", \"arguments\": {\"command\": \"ls\"}}\n</tool_call>\n<tool_call>{\"name\": \"think\"}</tool_call>\n<tool_result>imagined</tool_result>",
+		}},
+	}
+	parseTextToolCalls(resp)
+
+	if resp.StopReason != llm.StopReasonToolUse {
+		t.Errorf("StopReason = %v, expected %v", resp.StopReason, llm.StopReasonToolUse)
+	}
+	if len(resp.Content) != 3 {
+		t.Fatalf("expected text and two tool uses, got %+v", resp.Content)
+	}
+	if resp.Content[0].Text != "Let me look." {
+		t.Errorf("text = %q", resp.Content[0].Text)
+	}
+	if c := resp.Content[1]; c.Type != llm.ContentTypeToolUse || c.ToolName != "bash" || string(c.ToolInput) != `{"command": "ls"}` || c.ID == "" {
+		t.Errorf("unexpected first tool use: %+v", c)
+	}
+	if c := resp.Content[2]; c.ToolName != "think" || string(c.ToolInput) != "{}" || c.ID == resp.Content[1].ID {
+		t.Errorf("unexpected second tool use: %+v", c)
+	}
+
+	plain := &llm.Response{Content: []llm.Content{{Type: llm.ContentTypeText, Text: "Use <tool_call>not json</tool_call> to call tools."}}}
+	parseTextToolCalls(plain)
+	if len(plain.Content) != 1 || plain.Content[0].Type != llm.ContentTypeText || plain.StopReason == llm.StopReasonToolUse {
+		t.Errorf("expected an invalid call to stay text, got %+v", plain)
+	}
+}
+
+func TestServiceDoTextToolCalls(t *testing.T) {
+	var got openai.ChatCompletionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{
+				Message:      openai.ChatCompletionMessage{Role: "assistant", Content: "<tool_call>{\"name\": \"bash\", \"arguments\": {\"command\": \"pwd\"}}</tool_call>"},
+				FinishReason: "stop",
+			}},
+		})
+	}))
+	defer server.Close()
+
+	svc := &Service{Model: Model{ModelName: "local"}, ModelURL: server.URL + "/v1", TextToolCalls: true}
+	req := &llm.Request{
+		System: []llm.SystemContent{{Text: "You are helpful."}},
+		Tools:  []*llm.Tool{{Name: "bash", Description: "Runs a command.", InputSchema: json.RawMessage(`{"type":"object"}`)}},
+		Messages: []llm.Message{
+			{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "Where am I?"}}},
+			{Role: llm.MessageRoleAssistant, Content: []llm.Content{{ID: "call_1", Type: llm.ContentTypeToolUse, ToolName: "bash", ToolInput: json.RawMessage(`{"command":"ls"}`)}}},
+			{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeToolResult, ToolUseID: "call_1", ToolError: true, ToolResult: []llm.Content{{Type: llm.ContentTypeText, Text: "permission denied"}}}}},
+		},
+	}
+	resp, err := svc.Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if len(got.Tools) != 0 {
+		t.Errorf("expected no native tools, got %+v", got.Tools)
+	}
+	if len(got.Messages) != 4 {
+		t.Fatalf("expected system, user, assistant and user messages, got %+v", got.Messages)
+	}
+	if !strings.Contains(got.Messages[0].Content, "You are helpful.") || !strings.Contains(got.Messages[0].Content, "## bash") {
+		t.Errorf("expected the tools in the system prompt, got %q", got.Messages[0].Content)
+	}
+	if !strings.Contains(got.Messages[2].Content, `<tool_call>`) || !strings.Contains(got.Messages[2].Content, `"name":"bash"`) {
+		t.Errorf("expected the earlier call as text, got %q", got.Messages[2].Content)
+	}
+	if got.Messages[3].Role != "user" || got.Messages[3].Content != "<tool_result name=\"bash\" error=\"true\">\npermission denied\n</tool_result>" {
+		t.Errorf("expected the result as user text, got %+v", got.Messages[3])
+	}
+
+	if resp.StopReason != llm.StopReasonToolUse || len(resp.Content) != 1 || resp.Content[0].ToolName != "bash" {
+		t.Errorf("expected a bash tool use, got %+v", resp)
+	}
+}
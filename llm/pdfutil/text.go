@@ -0,0 +1,336 @@
+// Package pdfutil provides PDF utilities.
+package pdfutil
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// IsPDF checks if data is a PDF based on file magic.
+func IsPDF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("%PDF-"))
+}
+
+// maxStreamSize bounds the decompressed size of a single stream.
+const maxStreamSize = 32 << 20
+
+var streamRE = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+
+// ExtractText returns the text shown by the content streams of a PDF.
+//
+// It is a best-effort extraction for providers that can't read PDFs: it
+// handles uncompressed and Flate-compressed streams, and decodes strings as
+// UTF-16BE (with a byte order mark) or Latin-1. Text in fonts with custom
+// encodings comes out garbled or not at all.
+func ExtractText(data []byte) (string, error) {
+	if !IsPDF(data) {
+		return "", errors.New("not a PDF")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return "", errors.New("encrypted PDFs are not supported")
+	}
+
+	var out strings.Builder
+	for _, m := range streamRE.FindAllSubmatchIndex(data, -1) {
+		dict := data[m[2]:m[3]]
+		start := m[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		stream := data[start : start+end]
+
+		switch {
+		case bytes.Contains(dict, []byte("/FlateDecode")):
+			r, err := zlib.NewReader(bytes.NewReader(stream))
+			if err != nil {
+				continue
+			}
+			// Truncated streams are common; keep what decompressed.
+			stream, _ = io.ReadAll(io.LimitReader(r, maxStreamSize))
+		case bytes.Contains(dict, []byte("/Filter")):
+			// Images and other encodings carry no text we can read.
+			continue
+		}
+		if !bytes.Contains(stream, []byte("BT")) {
+			continue
+		}
+		out.WriteString(contentText(stream))
+	}
+	return tidy(out.String()), nil
+}
+
+// contentText returns the text shown by a content stream.
+func contentText(stream []byte) string {
+	var out strings.Builder
+	var operands []token
+	inText := false
+	for t := range tokens(stream) {
+		if t.kind != opToken {
+			operands = append(operands, t)
+			continue
+		}
+		switch t.text {
+		case "BT":
+			inText = true
+		case "ET":
+			inText = false
+			out.WriteByte('\n')
+		}
+		if inText {
+			showText(&out, t.text, operands)
+		}
+		operands = operands[:0]
+	}
+	return out.String()
+}
+
+// showText writes the text shown, or the line breaks made, by the text
+// operator op.
+func showText(out *strings.Builder, op string, operands []token) {
+	switch op {
+	case "Tj":
+		writeStrings(out, operands)
+	case "'", `"`:
+		out.WriteByte('\n')
+		writeStrings(out, operands)
+	case "TJ":
+		for _, t := range operands {
+			switch t.kind {
+			case stringToken:
+				out.WriteString(t.text)
+			case numberToken:
+				// Large negative offsets separate words.
+				if n, err := strconv.ParseFloat(t.text, 64); err == nil && n < -200 {
+					out.WriteByte(' ')
+				}
+			}
+		}
+	case "Td", "TD":
+		if len(operands) == 2 && operands[1].text != "0" {
+			out.WriteByte('\n')
+		} else {
+			out.WriteByte(' ')
+		}
+	case "T*", "Tm":
+		out.WriteByte('\n')
+	}
+}
+
+func writeStrings(out *strings.Builder, operands []token) {
+	for _, t := range operands {
+		if t.kind == stringToken {
+			out.WriteString(t.text)
+		}
+	}
+}
+
+// tidy trims trailing space from lines and collapses runs of blank lines.
+func tidy(s string) string {
+	lines := strings.Split(s, "\n")
+	var b strings.Builder
+	blank := 0
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			blank++
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(strings.Repeat("\n", min(blank, 1)+1))
+		}
+		blank = 0
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+type tokenKind int
+
+const (
+	opToken tokenKind = iota
+	numberToken
+	stringToken
+	otherToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokens yields the tokens of a content stream. Strings are decoded.
+// Array brackets, names, and dictionaries are skipped or reported as
+// otherToken, since text extraction doesn't need them.
+func tokens(s []byte) func(yield func(token) bool) {
+	return func(yield func(token) bool) {
+		i := 0
+		for i < len(s) {
+			c := s[i]
+			switch {
+			case isSpace(c):
+				i++
+			case c == '%':
+				for i < len(s) && s[i] != '\n' && s[i] != '\r' {
+					i++
+				}
+			case c == '(':
+				var str []byte
+				str, i = literalString(s, i+1)
+				if !yield(token{stringToken, decodeString(str)}) {
+					return
+				}
+			case c == '<' && i+1 < len(s) && s[i+1] == '<', c == '>' && i+1 < len(s) && s[i+1] == '>':
+				i += 2
+			case c == '<':
+				var str []byte
+				str, i = hexString(s, i+1)
+				if !yield(token{stringToken, decodeString(str)}) {
+					return
+				}
+			case c == '[' || c == ']' || c == '{' || c == '}' || c == '>' || c == ')':
+				i++
+			case c == '/':
+				j := i + 1
+				for j < len(s) && !isSpace(s[j]) && !isDelim(s[j]) {
+					j++
+				}
+				if !yield(token{otherToken, string(s[i:j])}) {
+					return
+				}
+				i = j
+			default:
+				j := i
+				for j < len(s) && !isSpace(s[j]) && !isDelim(s[j]) {
+					j++
+				}
+				if j == i {
+					j++
+				}
+				word := string(s[i:j])
+				kind := opToken
+				if _, err := strconv.ParseFloat(word, 64); err == nil {
+					kind = numberToken
+				}
+				if !yield(token{kind, word}) {
+					return
+				}
+				i = j
+			}
+		}
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func isDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// literalString parses a literal string whose opening parenthesis ends
+// before s[i]. It returns the string and the index after it.
+func literalString(s []byte, i int) ([]byte, int) {
+	var out []byte
+	depth := 1
+	for i < len(s) {
+		c := s[i]
+		i++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out, i
+			}
+		case '\\':
+			if i >= len(s) {
+				return out, i
+			}
+			e := s[i]
+			i++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				// A backslash at the end of a line continues the string.
+				if i < len(s) && s[i] == '\n' {
+					i++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for k := 0; k < 2 && i < len(s) && s[i] >= '0' && s[i] <= '7'; k++ {
+						n = n*8 + int(s[i]-'0')
+						i++
+					}
+					out = append(out, byte(n))
+				} else {
+					out = append(out, e)
+				}
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, i
+}
+
+// hexString parses a hex string whose opening angle bracket ends before
+// s[i]. It returns the string and the index after it.
+func hexString(s []byte, i int) ([]byte, int) {
+	var digits []byte
+	for i < len(s) && s[i] != '>' {
+		if c := s[i]; strings.IndexByte("0123456789abcdefABCDEF", c) >= 0 {
+			digits = append(digits, c)
+		}
+		i++
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for k := range out {
+		n, _ := strconv.ParseUint(string(digits[2*k:2*k+2]), 16, 8)
+		out[k] = byte(n)
+	}
+	return out, i + 1
+}
+
+// decodeString decodes the bytes of a PDF string as UTF-16BE if they start
+// with a byte order mark, and as Latin-1 otherwise.
+func decodeString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		b = b[2:]
+		u := make([]uint16, len(b)/2)
+		for k := range u {
+			u[k] = uint16(b[2*k])<<8 | uint16(b[2*k+1])
+		}
+		return string(utf16.Decode(u))
+	}
+	r := make([]rune, 0, len(b))
+	for _, c := range b {
+		if c < 0x20 && c != '\n' && c != '\t' {
+			continue
+		}
+		r = append(r, rune(c))
+	}
+	return string(r)
+}
@@ -0,0 +1,97 @@
+package pdfutil
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+)
+
+// buildPDF returns a minimal PDF with a page per content stream.
+func buildPDF(streams ...[]byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	for i, s := range streams {
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", i+3, len(s), s)
+	}
+	b.WriteString("%%EOF\n")
+	return b.Bytes()
+}
+
+func buildFlatePDF(content []byte) []byte {
+	var z bytes.Buffer
+	w := zlib.NewWriter(&z)
+	w.Write(content)
+	w.Close()
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	fmt.Fprintf(&b, "3 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", z.Len())
+	b.Write(z.Bytes())
+	b.WriteString("\nendstream\nendobj\n%%EOF\n")
+	return b.Bytes()
+}
+
+func TestExtractText(t *testing.T) {
+	tests := []struct {
+		name string
+		pdf  []byte
+		want string
+	}{
+		{
+			name: "simple",
+			pdf:  buildPDF([]byte("BT /F1 12 Tf 72 720 Td (Hello, world!) Tj ET")),
+			want: "Hello, world!",
+		},
+		{
+			name: "lines",
+			pdf:  buildPDF([]byte("BT /F1 12 Tf 72 720 Td (First line) Tj 0 -14 Td (Second line) Tj T* (Third) Tj ET")),
+			want: "First line\nSecond line\nThird",
+		},
+		{
+			name: "escapes and hex",
+			pdf:  buildPDF([]byte(`BT (a \(nested\) \\ \101 ) Tj <48692E> Tj <FEFF00E9> Tj ET`)),
+			want: `a (nested) \ A Hi.é`,
+		},
+		{
+			name: "TJ spacing",
+			pdf:  buildPDF([]byte("BT [(Hel) 20 (lo) -500 (there)] TJ ET")),
+			want: "Hello there",
+		},
+		{
+			name: "pages",
+			pdf:  buildPDF([]byte("BT (Page one) Tj ET"), []byte("BT (Page two) Tj ET")),
+			want: "Page one\nPage two",
+		},
+		{
+			name: "flate",
+			pdf:  buildFlatePDF([]byte("BT /F1 12 Tf (Compressed text) Tj ET")),
+			want: "Compressed text",
+		},
+		{
+			name: "no text",
+			pdf:  buildPDF([]byte("0 0 m 100 100 l S")),
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractText(tt.pdf)
+			if err != nil {
+				t.Fatalf("ExtractText: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ExtractText = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractTextErrors(t *testing.T) {
+	if _, err := ExtractText([]byte("not a pdf")); err == nil {
+		t.Error("expected an error for non-PDF data")
+	}
+	encrypted := append(buildPDF([]byte("BT (x) Tj ET")), "trailer << /Encrypt 5 0 R >>"...)
+	if _, err := ExtractText(encrypted); err == nil {
+		t.Error("expected an error for an encrypted PDF")
+	}
+}
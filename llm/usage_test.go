@@ -1,6 +1,10 @@
 package llm
 
-import "testing"
+import (
+	"math"
+	"net/http"
+	"testing"
+)
 
 func TestUsageTotalInputTokens(t *testing.T) {
 	tests := []struct {
@@ -98,3 +102,39 @@ func TestUsageContextWindowUsed(t *testing.T) {
 		})
 	}
 }
+
+func TestPricingCost(t *testing.T) {
+	p := Pricing{InputPerMTok: 3, OutputPerMTok: 15, CacheReadPerMTok: 0.30, CacheWritePerMTok: 3.75}
+	u := Usage{
+		InputTokens:              1_000_000,
+		CacheCreationInputTokens: 2_000_000,
+		CacheReadInputTokens:     10_000_000,
+		OutputTokens:             100_000,
+	}
+	want := 3 + 7.5 + 3 + 1.5
+	if got := p.Cost(u); math.Abs(got-want) > 1e-9 {
+		t.Errorf("Cost() = %v, want %v", got, want)
+	}
+	if got := (Pricing{}).Cost(u); got != 0 {
+		t.Errorf("zero Pricing Cost() = %v, want 0", got)
+	}
+}
+
+func TestPricingCostUSD(t *testing.T) {
+	p := Pricing{InputPerMTok: 1, OutputPerMTok: 5}
+	u := Usage{InputTokens: 1_000_000, OutputTokens: 1_000_000}
+
+	if got := p.CostUSD(nil, u); math.Abs(got-6) > 1e-9 {
+		t.Errorf("CostUSD() without gateway header = %v, want 6", got)
+	}
+
+	// The gateway's reported cost takes precedence, even when it is zero.
+	headers := http.Header{"Exedev-Gateway-Cost": []string{"0.25"}}
+	if got := p.CostUSD(headers, u); got != 0.25 {
+		t.Errorf("CostUSD() with gateway header = %v, want 0.25", got)
+	}
+	headers = http.Header{"Exedev-Gateway-Cost": []string{"0"}}
+	if got := p.CostUSD(headers, u); got != 0 {
+		t.Errorf("CostUSD() with zero gateway header = %v, want 0", got)
+	}
+}
@@ -1,7 +1,9 @@
 package loop
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -21,9 +23,50 @@ type MessageRecordFunc func(ctx context.Context, message llm.Message, usage llm.
 // This is used to record user-visible notifications about git changes.
 type GitStateChangeFunc func(ctx context.Context, state *gitstate.GitState)
 
+// BudgetCheckFunc is called before each LLM request. A non-nil error means a
+// spending budget has been reached and the request must not be sent.
+type BudgetCheckFunc func(ctx context.Context) error
+
+// CompactFunc is called before each LLM request that continues a turn, with
+// the context window the previous response used. When the context is nearly
+// full, it compacts the conversation and returns the shorter history to
+// continue the turn with; otherwise it returns nil.
+type CompactFunc func(ctx context.Context, contextUsed uint64) ([]llm.Message, error)
+
+// ErrBudgetExceeded is returned when the loop pauses because CheckBudget failed.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Fallback is a model the loop switches to when LLM requests keep failing.
+type Fallback struct {
+	ModelID string
+	LLM     llm.Service
+}
+
+// Retry policy for failed LLM requests. Variables so that tests can shorten them.
+var (
+	maxLLMAttempts = 4                // attempts per model before falling back
+	retryBaseDelay = time.Second      // delay before the first retry; doubled for each further retry
+	retryMaxDelay  = 30 * time.Second // cap on the backoff, and on Retry-After we're willing to wait for
+)
+
+// maxConcurrentTools bounds how many tool calls from a single response run at once.
+const maxConcurrentTools = 8
+
+// maxPauseContinuations bounds how many times in a row a paused turn is continued.
+const maxPauseContinuations = 5
+
+// StreamDeltaFunc is called with partial LLM output while a response is being generated.
+// Deltas are best-effort; the complete response is still recorded via MessageRecordFunc.
+type StreamDeltaFunc func(ctx context.Context, delta llm.StreamDelta)
+
+// ToolProgressFunc is called with the output of a tool call that is still running.
+// Like deltas, progress is best-effort and never recorded; the tool result is.
+type ToolProgressFunc func(ctx context.Context, toolUseID, output string)
+
 // Config contains all configuration needed to create a Loop.
 type Config struct {
 	LLM              llm.Service
+	ModelID          string // ID of the LLM model, used in fallback messages
 	History          []llm.Message
 	Tools            []*llm.Tool
 	RecordMessage    MessageRecordFunc
@@ -31,28 +74,54 @@ type Config struct {
 	System           []llm.SystemContent
 	WorkingDir       string // working directory for tools
 	OnGitStateChange GitStateChangeFunc
+	// OnStreamDelta, if set, receives partial LLM output as it streams in.
+	OnStreamDelta StreamDeltaFunc
+	// OnToolProgress, if set, receives the output of tool calls while they run.
+	OnToolProgress ToolProgressFunc
+	// Fallbacks are tried in order when requests to LLM keep failing. A fallback
+	// is used until the end of the turn; the next user message starts on LLM again.
+	Fallbacks []Fallback
+	// CheckBudget, if set, is called before each LLM request. When it fails,
+	// the loop records a budget error message and pauses until Resume.
+	CheckBudget BudgetCheckFunc
 	// GetWorkingDir returns the current working directory for tools.
 	// If set, this is called at end of turn to check for git state changes.
 	// If nil, Config.WorkingDir is used as a static value.
 	GetWorkingDir func() string
+	// ThinkingLevel, if set, overrides the thinking level of LLM (and fallbacks).
+	ThinkingLevel *llm.ThinkingLevel
+	// Compact, if set, may compact the history in the middle of a turn, so that
+	// a turn with many tool calls doesn't run out of context window.
+	Compact CompactFunc
 }
 
 // Loop manages a conversation turn with an LLM including tool execution and message recording.
 // Notably, when the turn ends, the "Loop" is over. TODO: maybe rename to Turn?
 type Loop struct {
-	llm              llm.Service
+	llm              llm.Service // current service; a fallback after a switch
+	modelID          string
+	primary          Fallback // Config.LLM and Config.ModelID
+	fallbacks        []Fallback
+	nextFallback     int // index into fallbacks of the next model to switch to
 	tools            []*llm.Tool
 	recordMessage    MessageRecordFunc
 	history          []llm.Message
 	messageQueue     []llm.Message
+	resumeRequested  bool
 	totalUsage       llm.Usage
 	mu               sync.Mutex
 	logger           *slog.Logger
 	system           []llm.SystemContent
 	workingDir       string
 	onGitStateChange GitStateChangeFunc
+	onStreamDelta    StreamDeltaFunc
+	onToolProgress   ToolProgressFunc
+	checkBudget      BudgetCheckFunc
 	getWorkingDir    func() string
 	lastGitState     *gitstate.GitState
+	thinkingLevel    *llm.ThinkingLevel
+	compact          CompactFunc
+	contextUsed      uint64 // by the last response
 }
 
 // NewLoop creates a new Loop instance with the provided configuration
@@ -71,6 +140,9 @@ func NewLoop(config Config) *Loop {
 
 	return &Loop{
 		llm:              config.LLM,
+		modelID:          config.ModelID,
+		primary:          Fallback{ModelID: config.ModelID, LLM: config.LLM},
+		fallbacks:        config.Fallbacks,
 		history:          config.History,
 		tools:            config.Tools,
 		recordMessage:    config.RecordMessage,
@@ -79,7 +151,12 @@ func NewLoop(config Config) *Loop {
 		system:           config.System,
 		workingDir:       config.WorkingDir,
 		onGitStateChange: config.OnGitStateChange,
+		onStreamDelta:    config.OnStreamDelta,
+		onToolProgress:   config.OnToolProgress,
+		checkBudget:      config.CheckBudget,
 		getWorkingDir:    config.GetWorkingDir,
+		thinkingLevel:    config.ThinkingLevel,
+		compact:          config.Compact,
 		lastGitState:     initialGitState,
 	}
 }
@@ -92,6 +169,22 @@ func (l *Loop) QueueUserMessage(message llm.Message) {
 	l.logger.Debug("queued user message", "content_count", len(message.Content))
 }
 
+// Resume asks the loop to send the current history to the LLM again without a
+// new user message. It is used to continue a turn that paused on a budget.
+func (l *Loop) Resume() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.resumeRequested = true
+}
+
+// SetThinkingLevel changes the thinking level of the LLM requests that follow.
+// A nil level uses each service's own.
+func (l *Loop) SetThinkingLevel(level *llm.ThinkingLevel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.thinkingLevel = level
+}
+
 // GetUsage returns the total usage accumulated by this loop
 func (l *Loop) GetUsage() llm.Usage {
 	l.mu.Lock()
@@ -136,12 +229,16 @@ func (l *Loop) Go(ctx context.Context) error {
 
 		// Process any queued messages
 		l.mu.Lock()
-		hasQueuedMessages := len(l.messageQueue) > 0
+		hasQueuedMessages := len(l.messageQueue) > 0 || l.resumeRequested
+		l.resumeRequested = false
 		if hasQueuedMessages {
 			// Add queued messages to history (they are already recorded to DB by ConversationManager)
 			for _, msg := range l.messageQueue {
 				l.history = append(l.history, msg)
 			}
+			if len(l.messageQueue) > 0 {
+				l.resetFallback()
+			}
 			l.messageQueue = l.messageQueue[:0] // Clear queue
 		}
 		l.mu.Unlock()
@@ -149,7 +246,10 @@ func (l *Loop) Go(ctx context.Context) error {
 		if hasQueuedMessages {
 			// Send request to LLM
 			l.logger.Debug("processing queued messages", "count", 1)
-			if err := l.processLLMRequest(ctx); err != nil {
+			if err := l.processLLMRequest(ctx); errors.Is(err, ErrBudgetExceeded) {
+				l.logger.Info("conversation paused", "error", err)
+				continue
+			} else if err != nil {
 				l.logger.Error("failed to process LLM request", "error", err)
 				time.Sleep(time.Second) // Wait before retrying
 				continue
@@ -182,6 +282,7 @@ func (l *Loop) ProcessOneTurn(ctx context.Context) error {
 			l.history = append(l.history, msg)
 		}
 		l.messageQueue = nil
+		l.resetFallback()
 	}
 	l.mu.Unlock()
 
@@ -189,13 +290,64 @@ func (l *Loop) ProcessOneTurn(ctx context.Context) error {
 	return l.processLLMRequest(ctx)
 }
 
-// processLLMRequest sends a request to the LLM and handles the response
+// processLLMRequest sends a request to the LLM and handles the response,
+// continuing the turn as long as the model pauses it.
 func (l *Loop) processLLMRequest(ctx context.Context) error {
+	for continuations := 0; ; continuations++ {
+		paused, err := l.sendLLMRequest(ctx)
+		if err != nil || !paused {
+			return err
+		}
+		if continuations == maxPauseContinuations {
+			err := fmt.Errorf("the turn was paused %d times in a row", continuations+1)
+			// EndOfTurn must be true so the agent working state is properly updated
+			errorMessage := llm.Message{
+				Role:      llm.MessageRoleAssistant,
+				Content:   []llm.Content{{Type: llm.ContentTypeText, Text: fmt.Sprintf("LLM request failed: %v", err)}},
+				EndOfTurn: true,
+				ErrorType: llm.ErrorTypeLLMRequest,
+			}
+			if recordErr := l.recordMessage(ctx, errorMessage, llm.Usage{}); recordErr != nil {
+				l.logger.Error("failed to record error message", "error", recordErr)
+			}
+			return fmt.Errorf("LLM request failed: %w", err)
+		}
+		// A long-running server tool turn was paused; sending the conversation
+		// back as it is lets the model continue it.
+		l.logger.Debug("continuing paused turn")
+	}
+}
+
+// sendLLMRequest sends a request to the LLM and handles the response. It
+// reports whether the model paused the turn, which the caller continues.
+func (l *Loop) sendLLMRequest(ctx context.Context) (paused bool, err error) {
+	if l.checkBudget != nil {
+		if err := l.checkBudget(ctx); err != nil {
+			// EndOfTurn must be true so the agent working state is properly updated
+			pauseMessage := llm.Message{
+				Role: llm.MessageRoleAssistant,
+				Content: []llm.Content{
+					{
+						Type: llm.ContentTypeText,
+						Text: fmt.Sprintf("Paused: %v. Raise the budget to continue.", err),
+					},
+				},
+				EndOfTurn: true,
+				ErrorType: llm.ErrorTypeBudget,
+			}
+			if recordErr := l.recordMessage(ctx, pauseMessage, llm.Usage{}); recordErr != nil {
+				l.logger.Error("failed to record budget message", "error", recordErr)
+			}
+			return false, fmt.Errorf("%w: %w", ErrBudgetExceeded, err)
+		}
+	}
+
 	l.mu.Lock()
 	messages := append([]llm.Message(nil), l.history...)
 	tools := l.tools
 	system := l.system
 	llmService := l.llm
+	thinkingLevel := l.thinkingLevel
 	l.mu.Unlock()
 
 	// Enable prompt caching: set cache flag on last tool and last user message content
@@ -224,9 +376,10 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	}
 
 	req := &llm.Request{
-		Messages: messages,
-		Tools:    tools,
-		System:   system,
+		Messages:      messages,
+		Tools:         tools,
+		System:        system,
+		ThinkingLevel: thinkingLevel,
 	}
 
 	// Insert missing tool results if the previous message had tool_use blocks
@@ -244,23 +397,20 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	llmCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
-	// Retry LLM requests that fail with retryable errors (EOF, connection reset)
-	const maxRetries = 2
-	var resp *llm.Response
-	var err error
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		resp, err = llmService.Do(llmCtx, req)
-		if err == nil {
-			break
-		}
-		if !isRetryableError(err) || attempt == maxRetries {
+	var onDelta func(llm.StreamDelta)
+	if l.onStreamDelta != nil {
+		onDelta = func(d llm.StreamDelta) { l.onStreamDelta(ctx, d) }
+	}
+
+	// Retry failed requests, then fall back to the next model in the chain
+	// if the failure persists.
+	resp, err := l.doWithRetries(llmCtx, llmService, req, onDelta)
+	for err != nil && shouldFallback(err) && llmCtx.Err() == nil {
+		next, ok := l.switchToFallback(ctx, err)
+		if !ok {
 			break
 		}
-		l.logger.Warn("LLM request failed with retryable error, retrying",
-			"error", err,
-			"attempt", attempt,
-			"max_retries", maxRetries)
-		time.Sleep(time.Second * time.Duration(attempt)) // Simple backoff
+		resp, err = l.doWithRetries(llmCtx, next, req, onDelta)
 	}
 	if err != nil {
 		// Record the error as a message so it can be displayed in the UI
@@ -279,7 +429,7 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 		if recordErr := l.recordMessage(ctx, errorMessage, llm.Usage{}); recordErr != nil {
 			l.logger.Error("failed to record error message", "error", recordErr)
 		}
-		return fmt.Errorf("LLM request failed: %w", err)
+		return false, fmt.Errorf("LLM request failed: %w", err)
 	}
 
 	l.logger.Debug("received LLM response", "content_count", len(resp.Content), "stop_reason", resp.StopReason.String(), "usage", resp.Usage.String())
@@ -287,13 +437,14 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	// Update total usage
 	l.mu.Lock()
 	l.totalUsage.Add(resp.Usage)
+	l.contextUsed = resp.Usage.ContextWindowUsed()
 	l.mu.Unlock()
 
 	// Handle max tokens truncation BEFORE adding to history - truncated responses
 	// should not be added to history normally (they get special handling)
 	if resp.StopReason == llm.StopReasonMaxTokens {
 		l.logger.Warn("LLM response truncated due to max tokens")
-		return l.handleMaxTokensTruncation(ctx, resp)
+		return false, l.handleMaxTokensTruncation(ctx, resp)
 	}
 
 	// Convert response to message and add to history
@@ -314,13 +465,17 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	// Handle tool calls if any
 	if resp.StopReason == llm.StopReasonToolUse {
 		l.logger.Debug("handling tool calls", "content_count", len(resp.Content))
-		return l.handleToolCalls(ctx, resp.Content)
+		return false, l.handleToolCalls(ctx, resp.Content)
+	}
+
+	if resp.StopReason == llm.StopReasonPauseTurn {
+		return true, nil
 	}
 
 	// End of turn - check for git state changes
 	l.checkGitStateChange(ctx)
 
-	return nil
+	return false, nil
 }
 
 // checkGitStateChange checks if the git state has changed and calls the callback if so.
@@ -410,67 +565,35 @@ func (l *Loop) handleMaxTokensTruncation(ctx context.Context, resp *llm.Response
 
 // handleToolCalls processes tool calls from the LLM response
 func (l *Loop) handleToolCalls(ctx context.Context, content []llm.Content) error {
-	var toolResults []llm.Content
-
+	var toolUses []llm.Content
 	for _, c := range content {
-		if c.Type != llm.ContentTypeToolUse {
-			continue
-		}
-
-		l.logger.Debug("executing tool", "name", c.ToolName, "id", c.ID)
-
-		// Find the tool
-		var tool *llm.Tool
-		for _, t := range l.tools {
-			if t.Name == c.ToolName {
-				tool = t
-				break
-			}
+		if c.Type == llm.ContentTypeToolUse {
+			toolUses = append(toolUses, c)
 		}
+	}
 
-		if tool == nil {
-			l.logger.Error("tool not found", "name", c.ToolName)
-			toolResults = append(toolResults, llm.Content{
-				Type:      llm.ContentTypeToolResult,
-				ToolUseID: c.ID,
-				ToolError: true,
-				ToolResult: []llm.Content{
-					{Type: llm.ContentTypeText, Text: fmt.Sprintf("Tool '%s' not found", c.ToolName)},
-				},
-			})
+	// Run tool calls concurrently, bounded by maxConcurrentTools.
+	// Each result goes in the slot matching its tool_use, so order is stable.
+	// Sequential tools wait for all earlier calls and finish before later ones start.
+	toolResults := make([]llm.Content, len(toolUses))
+	sem := make(chan struct{}, maxConcurrentTools)
+	var wg sync.WaitGroup
+	for i, c := range toolUses {
+		tool := l.findTool(c.ToolName)
+		if tool != nil && tool.RunsSequentially(c.ToolInput) {
+			wg.Wait()
+			toolResults[i] = l.runTool(ctx, tool, c)
 			continue
 		}
-
-		// Execute the tool with working directory set in context
-		toolCtx := ctx
-		if l.workingDir != "" {
-			toolCtx = claudetool.WithWorkingDir(ctx, l.workingDir)
-		}
-		startTime := time.Now()
-		result := tool.Run(toolCtx, c.ToolInput)
-		endTime := time.Now()
-
-		var toolResultContent []llm.Content
-		if result.Error != nil {
-			l.logger.Error("tool execution failed", "name", c.ToolName, "error", result.Error)
-			toolResultContent = []llm.Content{
-				{Type: llm.ContentTypeText, Text: result.Error.Error()},
-			}
-		} else {
-			toolResultContent = result.LLMContent
-			l.logger.Debug("tool executed successfully", "name", c.ToolName, "duration", endTime.Sub(startTime))
-		}
-
-		toolResults = append(toolResults, llm.Content{
-			Type:             llm.ContentTypeToolResult,
-			ToolUseID:        c.ID,
-			ToolError:        result.Error != nil,
-			ToolResult:       toolResultContent,
-			ToolUseStartTime: &startTime,
-			ToolUseEndTime:   &endTime,
-			Display:          result.Display,
-		})
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			toolResults[i] = l.runTool(ctx, tool, c)
+		}()
 	}
+	wg.Wait()
 
 	if len(toolResults) > 0 {
 		// Add tool results to history as a user message
@@ -497,6 +620,8 @@ func (l *Loop) handleToolCalls(ctx context.Context, content []llm.Content) error
 			l.logger.Error("failed to record tool result message", "error", err)
 		}
 
+		l.maybeCompact(ctx)
+
 		// Process another LLM request with the tool results
 		return l.processLLMRequest(ctx)
 	}
@@ -504,6 +629,93 @@ func (l *Loop) handleToolCalls(ctx context.Context, content []llm.Content) error
 	return nil
 }
 
+// maybeCompact gives Config.Compact the chance to replace the history before
+// the turn continues. If compacting fails, the turn continues as it was.
+func (l *Loop) maybeCompact(ctx context.Context) {
+	l.mu.Lock()
+	used := l.contextUsed
+	l.mu.Unlock()
+	if l.compact == nil || used == 0 {
+		return
+	}
+	history, err := l.compact(ctx, used)
+	if err != nil {
+		l.logger.Error("failed to compact history", "error", err)
+		return
+	}
+	if history == nil {
+		return
+	}
+	l.mu.Lock()
+	l.history = history
+	l.contextUsed = 0
+	l.mu.Unlock()
+	l.logger.Info("compacted history during turn", "context_used", used, "message_count", len(history))
+}
+
+// findTool returns the tool with the given name, or nil if there is none.
+func (l *Loop) findTool(name string) *llm.Tool {
+	for _, t := range l.tools {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// runTool executes a single tool_use and returns its tool_result.
+// A nil tool produces a "not found" error result.
+func (l *Loop) runTool(ctx context.Context, tool *llm.Tool, c llm.Content) llm.Content {
+	l.logger.Debug("executing tool", "name", c.ToolName, "id", c.ID)
+
+	if tool == nil {
+		l.logger.Error("tool not found", "name", c.ToolName)
+		return llm.Content{
+			Type:      llm.ContentTypeToolResult,
+			ToolUseID: c.ID,
+			ToolError: true,
+			ToolResult: []llm.Content{
+				{Type: llm.ContentTypeText, Text: fmt.Sprintf("Tool '%s' not found", c.ToolName)},
+			},
+		}
+	}
+
+	// Execute the tool with working directory set in context
+	toolCtx := ctx
+	if l.workingDir != "" {
+		toolCtx = claudetool.WithWorkingDir(ctx, l.workingDir)
+	}
+	if l.onToolProgress != nil {
+		toolCtx = claudetool.WithProgress(toolCtx, func(output string) {
+			l.onToolProgress(ctx, c.ID, output)
+		})
+	}
+	startTime := time.Now()
+	result := tool.Run(toolCtx, c.ToolInput)
+	endTime := time.Now()
+
+	var toolResultContent []llm.Content
+	if result.Error != nil {
+		l.logger.Error("tool execution failed", "name", c.ToolName, "error", result.Error)
+		toolResultContent = []llm.Content{
+			{Type: llm.ContentTypeText, Text: result.Error.Error()},
+		}
+	} else {
+		toolResultContent = result.LLMContent
+		l.logger.Debug("tool executed successfully", "name", c.ToolName, "duration", endTime.Sub(startTime))
+	}
+
+	return llm.Content{
+		Type:             llm.ContentTypeToolResult,
+		ToolUseID:        c.ID,
+		ToolError:        result.Error != nil,
+		ToolResult:       toolResultContent,
+		ToolUseStartTime: &startTime,
+		ToolUseEndTime:   &endTime,
+		Display:          result.Display,
+	}
+}
+
 // insertMissingToolResults fixes tool_result issues in the conversation history:
 //  1. Adds error results for tool_uses that were requested but not included in the next message.
 //     This can happen when a request is cancelled or fails after the LLM responds with tool_use
@@ -667,6 +879,111 @@ func (l *Loop) insertMissingToolResults(req *llm.Request) {
 	}
 }
 
+// doWithRetries sends req to svc, retrying retryable failures with exponential
+// backoff. A Retry-After from the provider is honored, unless it is longer
+// than retryMaxDelay, in which case the error is returned so the caller can
+// fall back to another model instead of waiting.
+func (l *Loop) doWithRetries(ctx context.Context, svc llm.Service, req *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := llm.DoStream(llm.WithoutRetries(ctx), svc, req, onDelta)
+		if err == nil {
+			return resp, nil
+		}
+		if onDelta != nil {
+			// Discard any partial output from the failed attempt.
+			onDelta(llm.StreamDelta{Reset: true})
+		}
+		if !isRetryable(err) || attempt == maxLLMAttempts {
+			return nil, err
+		}
+		delay := retryDelay(attempt, llm.RetryAfterOf(err))
+		if delay > retryMaxDelay {
+			return nil, err
+		}
+		l.logger.Warn("LLM request failed with retryable error, retrying",
+			"error", err,
+			"kind", llm.ErrorKindOf(err),
+			"attempt", attempt,
+			"max_attempts", maxLLMAttempts,
+			"delay", delay)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryDelay returns how long to wait before retrying after the given attempt
+// (counting from 1): exponential backoff from retryBaseDelay, capped at
+// retryMaxDelay, or retryAfter if the provider asked for longer.
+func retryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 16 {
+		delay = min(retryBaseDelay<<(attempt-1), retryMaxDelay)
+	}
+	return max(delay, retryAfter)
+}
+
+// switchToFallback makes the next fallback model current, recording a message
+// saying why. It returns false if there are no fallbacks left.
+func (l *Loop) switchToFallback(ctx context.Context, cause error) (llm.Service, bool) {
+	l.mu.Lock()
+	if l.nextFallback >= len(l.fallbacks) {
+		l.mu.Unlock()
+		return nil, false
+	}
+	from := l.modelID
+	next := l.fallbacks[l.nextFallback]
+	l.nextFallback++
+	l.llm = next.LLM
+	l.modelID = next.ModelID
+	l.mu.Unlock()
+
+	l.logger.Warn("switching to fallback model", "from", from, "to", next.ModelID, "error", cause)
+	reason := string(llm.ErrorKindOf(cause))
+	if reason == "" {
+		reason = "request failed"
+	}
+	message := llm.Message{
+		Role: llm.MessageRoleAssistant,
+		Content: []llm.Content{
+			{
+				Type: llm.ContentTypeText,
+				Text: fmt.Sprintf("Switched from %s to %s for the rest of this turn (%s): %v", cmp.Or(from, "the conversation model"), next.ModelID, reason, cause),
+			},
+		},
+		ErrorType: llm.ErrorTypeModelFallback,
+	}
+	if err := l.recordMessage(ctx, message, llm.Usage{}); err != nil {
+		l.logger.Error("failed to record fallback message", "error", err)
+	}
+	return next.LLM, true
+}
+
+// resetFallback switches back to the primary model for a new turn.
+// l.mu must be held.
+func (l *Loop) resetFallback() {
+	l.llm = l.primary.LLM
+	l.modelID = l.primary.ModelID
+	l.nextFallback = 0
+}
+
+// isRetryable reports whether a failed request may succeed if sent again.
+func isRetryable(err error) bool {
+	if kind := llm.ErrorKindOf(err); kind != llm.ErrorKindUnknown {
+		return kind.Retryable()
+	}
+	return isRetryableError(err)
+}
+
+// shouldFallback reports whether a request that failed with err, after
+// retries, may succeed with a different model. Requests that are too long or
+// malformed will fail the same way elsewhere.
+func shouldFallback(err error) bool {
+	return isRetryable(err) || llm.ErrorKindOf(err) == llm.ErrorKindAuth
+}
+
 // isRetryableError checks if an error is transient and should be retried.
 // This includes EOF errors (connection closed unexpectedly) and similar network issues.
 func isRetryableError(err error) bool {
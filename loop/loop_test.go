@@ -3,6 +3,7 @@ package loop
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -277,6 +278,57 @@ func TestLoopWithPredictableService(t *testing.T) {
 	}
 }
 
+func TestLoopBudgetPause(t *testing.T) {
+	var recordedMessages []llm.Message
+	recordFunc := func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+		recordedMessages = append(recordedMessages, message)
+		return nil
+	}
+
+	overBudget := true
+	service := NewPredictableService()
+	loop := NewLoop(Config{
+		LLM:           service,
+		History:       []llm.Message{},
+		Tools:         []*llm.Tool{},
+		RecordMessage: recordFunc,
+		CheckBudget: func(ctx context.Context) error {
+			if overBudget {
+				return fmt.Errorf("conversation budget of 1 turns reached")
+			}
+			return nil
+		},
+	})
+
+	loop.QueueUserMessage(llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: "echo: paused"}},
+	})
+	err := loop.ProcessOneTurn(context.Background())
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+	if len(service.GetRecentRequests()) != 0 {
+		t.Error("expected no LLM request while over budget")
+	}
+	if len(recordedMessages) != 1 || recordedMessages[0].ErrorType != llm.ErrorTypeBudget || !recordedMessages[0].EndOfTurn {
+		t.Fatalf("expected one budget error message, got %+v", recordedMessages)
+	}
+
+	// Once the budget allows it, Resume sends the paused history.
+	overBudget = false
+	loop.Resume()
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := loop.Go(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context deadline exceeded, got %v", err)
+	}
+	last := recordedMessages[len(recordedMessages)-1]
+	if last.Role != llm.MessageRoleAssistant || last.ErrorType != llm.ErrorTypeNone || last.Content[0].Text != "paused" {
+		t.Errorf("expected resumed response to echo the paused message, got %+v", last)
+	}
+}
+
 func TestLoopWithTools(t *testing.T) {
 	var toolCalls []string
 
@@ -1222,6 +1274,51 @@ func TestPredictableServiceThinking(t *testing.T) {
 	}
 }
 
+func TestLoopStreamDeltas(t *testing.T) {
+	var recordedMessages []llm.Message
+	var deltas []llm.StreamDelta
+	loop := NewLoop(Config{
+		LLM:     NewPredictableService(),
+		History: []llm.Message{},
+		Tools:   []*llm.Tool{},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			recordedMessages = append(recordedMessages, message)
+			return nil
+		},
+		OnStreamDelta: func(ctx context.Context, delta llm.StreamDelta) {
+			deltas = append(deltas, delta)
+		},
+	})
+
+	loop.QueueUserMessage(llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: "echo: streaming works fine"}},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := loop.ProcessOneTurn(ctx); err != nil {
+		t.Fatalf("ProcessOneTurn: %v", err)
+	}
+
+	if len(deltas) < 2 {
+		t.Fatalf("expected multiple deltas, got %d", len(deltas))
+	}
+	var text strings.Builder
+	for _, d := range deltas {
+		if d.Index != 0 || d.Type != llm.ContentTypeText {
+			t.Errorf("unexpected delta %+v", d)
+		}
+		text.WriteString(d.Text)
+	}
+	if len(recordedMessages) != 1 {
+		t.Fatalf("expected 1 recorded message, got %d", len(recordedMessages))
+	}
+	if got, want := text.String(), recordedMessages[0].Content[0].Text; got != want {
+		t.Errorf("streamed text %q does not match recorded text %q", got, want)
+	}
+}
+
 func TestPredictableServicePatchTool(t *testing.T) {
 	service := NewPredictableService()
 
@@ -1607,7 +1704,15 @@ func (r *retryableLLMService) getCallCount() int {
 	return r.callCount
 }
 
+// shortenRetryDelays makes LLM request retries fast for the duration of the test.
+func shortenRetryDelays(t *testing.T) {
+	base, maxDelay := retryBaseDelay, retryMaxDelay
+	retryBaseDelay, retryMaxDelay = time.Millisecond, 100*time.Millisecond
+	t.Cleanup(func() { retryBaseDelay, retryMaxDelay = base, maxDelay })
+}
+
 func TestLLMRequestRetryOnEOF(t *testing.T) {
+	shortenRetryDelays(t)
 	// Test that LLM requests are retried on EOF errors
 	retryService := &retryableLLMService{failuresRemaining: 1}
 
@@ -1655,8 +1760,9 @@ func TestLLMRequestRetryOnEOF(t *testing.T) {
 }
 
 func TestLLMRequestRetryExhausted(t *testing.T) {
+	shortenRetryDelays(t)
 	// Test that after max retries, error is returned
-	retryService := &retryableLLMService{failuresRemaining: 10} // More than maxRetries
+	retryService := &retryableLLMService{failuresRemaining: 10} // More than maxLLMAttempts
 
 	var recordedMessages []llm.Message
 	recordFunc := func(ctx context.Context, message llm.Message, usage llm.Usage) error {
@@ -1685,9 +1791,8 @@ func TestLLMRequestRetryExhausted(t *testing.T) {
 		t.Fatal("expected error after exhausting retries")
 	}
 
-	// Should have been called maxRetries times (2)
-	if retryService.getCallCount() != 2 {
-		t.Errorf("expected 2 LLM calls (maxRetries), got %d", retryService.getCallCount())
+	if retryService.getCallCount() != maxLLMAttempts {
+		t.Errorf("expected %d LLM calls (maxLLMAttempts), got %d", maxLLMAttempts, retryService.getCallCount())
 	}
 
 	// Check error message was recorded
@@ -1700,6 +1805,158 @@ func TestLLMRequestRetryExhausted(t *testing.T) {
 	}
 }
 
+// scriptedLLMService returns the scripted errors in order, then succeeds with text.
+type scriptedLLMService struct {
+	errs      []error
+	text      string
+	callCount int
+	mu        sync.Mutex
+}
+
+func (s *scriptedLLMService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callCount++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return nil, err
+	}
+	return &llm.Response{
+		Content:    []llm.Content{{Type: llm.ContentTypeText, Text: s.text}},
+		StopReason: llm.StopReasonEndTurn,
+	}, nil
+}
+
+func (s *scriptedLLMService) TokenContextWindow() int {
+	return 200000
+}
+
+func (s *scriptedLLMService) MaxImageDimension() int {
+	return 2000
+}
+
+func (s *scriptedLLMService) getCallCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.callCount
+}
+
+func repeatErr(err error, n int) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+func TestLLMRequestRetryHonorsRetryAfter(t *testing.T) {
+	shortenRetryDelays(t)
+	rateLimited := &llm.Error{Kind: llm.ErrorKindRateLimit, StatusCode: 429, RetryAfter: 50 * time.Millisecond, Err: fmt.Errorf("status 429")}
+	service := &scriptedLLMService{errs: []error{rateLimited}, text: "ok"}
+
+	loop := NewLoop(Config{
+		LLM:           service,
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error { return nil },
+	})
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "hi"}}})
+
+	start := time.Now()
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected to wait for Retry-After, retried after %v", elapsed)
+	}
+	if service.getCallCount() != 2 {
+		t.Errorf("expected 2 LLM calls, got %d", service.getCallCount())
+	}
+}
+
+func TestLLMRequestFallback(t *testing.T) {
+	shortenRetryDelays(t)
+	overloaded := &llm.Error{Kind: llm.ErrorKindOverloaded, StatusCode: 529, Err: fmt.Errorf("status 529 overloaded")}
+	primary := &scriptedLLMService{errs: repeatErr(overloaded, maxLLMAttempts), text: "from primary"}
+	fallback := &scriptedLLMService{text: "from fallback"}
+
+	var recorded []llm.Message
+	loop := NewLoop(Config{
+		LLM:       primary,
+		ModelID:   "primary-model",
+		Fallbacks: []Fallback{{ModelID: "fallback-model", LLM: fallback}},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			recorded = append(recorded, message)
+			return nil
+		},
+	})
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "hi"}}})
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatalf("expected fallback to succeed, got %v", err)
+	}
+	if primary.getCallCount() != maxLLMAttempts || fallback.getCallCount() != 1 {
+		t.Errorf("expected %d primary calls and 1 fallback call, got %d and %d", maxLLMAttempts, primary.getCallCount(), fallback.getCallCount())
+	}
+	if len(recorded) != 2 {
+		t.Fatalf("expected a fallback message and a response, got %d messages", len(recorded))
+	}
+	if recorded[0].ErrorType != llm.ErrorTypeModelFallback || !strings.Contains(recorded[0].Content[0].Text, "primary-model to fallback-model") {
+		t.Errorf("unexpected fallback message: %+v", recorded[0])
+	}
+	if recorded[1].Content[0].Text != "from fallback" {
+		t.Errorf("expected response from fallback, got %q", recorded[1].Content[0].Text)
+	}
+
+	// The next turn starts on the primary model again.
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "again"}}})
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatalf("second turn failed: %v", err)
+	}
+	if last := recorded[len(recorded)-1]; last.Content[0].Text != "from primary" {
+		t.Errorf("expected the next turn to use the primary model, got %q", last.Content[0].Text)
+	}
+}
+
+func TestLLMRequestNoFallbackOnContextTooLong(t *testing.T) {
+	shortenRetryDelays(t)
+	tooLong := &llm.Error{Kind: llm.ErrorKindContextTooLong, StatusCode: 400, Err: fmt.Errorf("prompt is too long")}
+	primary := &scriptedLLMService{errs: []error{tooLong}}
+	fallback := &scriptedLLMService{}
+
+	loop := NewLoop(Config{
+		LLM:           primary,
+		Fallbacks:     []Fallback{{ModelID: "fallback-model", LLM: fallback}},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error { return nil },
+	})
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "hi"}}})
+	if err := loop.ProcessOneTurn(context.Background()); err == nil {
+		t.Fatal("expected context too long error")
+	}
+	if primary.getCallCount() != 1 || fallback.getCallCount() != 0 {
+		t.Errorf("expected no retries or fallback, got %d primary and %d fallback calls", primary.getCallCount(), fallback.getCallCount())
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempt    int
+		retryAfter time.Duration
+		want       time.Duration
+	}{
+		{1, 0, time.Second},
+		{2, 0, 2 * time.Second},
+		{3, 0, 4 * time.Second},
+		{10, 0, 30 * time.Second},
+		{100, 0, 30 * time.Second},
+		{1, 5 * time.Second, 5 * time.Second},
+		{3, time.Second, 4 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempt, tt.retryAfter); got != tt.want {
+			t.Errorf("retryDelay(%d, %v) = %v, want %v", tt.attempt, tt.retryAfter, got, tt.want)
+		}
+	}
+}
+
 func TestIsRetryableError(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -1952,6 +2209,165 @@ func TestHandleToolCallsWithErrorTool(t *testing.T) {
 	}
 }
 
+func TestHandleToolCallsConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	var recordedMessages []llm.Message
+	recordFunc := func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		recordedMessages = append(recordedMessages, message)
+		return nil
+	}
+
+	// Each call blocks until all three are running, so this only completes if they run concurrently.
+	var started sync.WaitGroup
+	started.Add(3)
+	slowTool := &llm.Tool{
+		Name:        "slow_tool",
+		Description: "A tool that waits for its siblings",
+		InputSchema: llm.MustSchema(`{"type": "object", "properties": {"n": {"type": "string"}}}`),
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			started.Done()
+			started.Wait()
+			return llm.ToolOut{LLMContent: llm.TextContent(string(input))}
+		},
+	}
+
+	loop := NewLoop(Config{
+		LLM:           NewPredictableService(),
+		History:       []llm.Message{},
+		Tools:         []*llm.Tool{slowTool},
+		RecordMessage: recordFunc,
+	})
+
+	var content []llm.Content
+	for i := range 3 {
+		content = append(content, llm.Content{
+			ID:        fmt.Sprintf("slow_%d", i),
+			Type:      llm.ContentTypeToolUse,
+			ToolName:  "slow_tool",
+			ToolInput: json.RawMessage(fmt.Sprintf(`{"n":"%d"}`, i)),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := loop.handleToolCalls(ctx, content); err != nil {
+		t.Fatalf("handleToolCalls failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(recordedMessages) < 1 {
+		t.Fatal("expected a recorded tool result message")
+	}
+	results := recordedMessages[0].Content
+	if len(results) != 3 {
+		t.Fatalf("expected 3 tool results, got %d", len(results))
+	}
+	for i, r := range results {
+		if want := fmt.Sprintf("slow_%d", i); r.ToolUseID != want {
+			t.Errorf("result %d: expected tool use ID %q, got %q", i, want, r.ToolUseID)
+		}
+		if r.ToolUseStartTime == nil || r.ToolUseEndTime == nil {
+			t.Errorf("result %d: expected start and end times", i)
+		}
+	}
+}
+
+func TestHandleToolCallsSequentialTool(t *testing.T) {
+	var mu sync.Mutex
+	var running, maxRunning int
+	track := func() func() {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		return func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}
+	}
+	var order []string
+	run := func(name string) func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+		return func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			defer track()()
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return llm.ToolOut{LLMContent: llm.TextContent(name)}
+		}
+	}
+	tools := []*llm.Tool{
+		{Name: "a", InputSchema: llm.EmptySchema(), Run: run("a")},
+		{Name: "seq", InputSchema: llm.EmptySchema(), Sequential: true, Run: run("seq")},
+	}
+
+	loop := NewLoop(Config{
+		LLM:           NewPredictableService(),
+		History:       []llm.Message{},
+		Tools:         tools,
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error { return nil },
+	})
+
+	content := []llm.Content{
+		{ID: "1", Type: llm.ContentTypeToolUse, ToolName: "seq", ToolInput: json.RawMessage(`{}`)},
+		{ID: "2", Type: llm.ContentTypeToolUse, ToolName: "seq", ToolInput: json.RawMessage(`{}`)},
+		{ID: "3", Type: llm.ContentTypeToolUse, ToolName: "a", ToolInput: json.RawMessage(`{}`)},
+		{ID: "4", Type: llm.ContentTypeToolUse, ToolName: "seq", ToolInput: json.RawMessage(`{}`)},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := loop.handleToolCalls(ctx, content); err != nil {
+		t.Fatalf("handleToolCalls failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxRunning != 1 {
+		t.Errorf("expected sequential tools to never overlap, got %d concurrent calls", maxRunning)
+	}
+	if got := strings.Join(order, ","); got != "seq,seq,a,seq" {
+		t.Errorf("unexpected execution order %q", got)
+	}
+}
+
+func TestHandleToolCallsBashInOrder(t *testing.T) {
+	dir := t.TempDir()
+	bash := &claudetool.BashTool{WorkingDir: claudetool.NewMutableWorkingDir(dir)}
+	var results []llm.Content
+	loop := NewLoop(Config{
+		LLM:     NewPredictableService(),
+		History: []llm.Message{},
+		Tools:   []*llm.Tool{bash.Tool()},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			if results == nil {
+				results = message.Content
+			}
+			return nil
+		},
+	})
+
+	// The second command reads what the first one writes once it's done.
+	content := []llm.Content{
+		{ID: "1", Type: llm.ContentTypeToolUse, ToolName: "bash", ToolInput: json.RawMessage(`{"command":"sleep 0.5; echo first > f"}`)},
+		{ID: "2", Type: llm.ContentTypeToolUse, ToolName: "bash", ToolInput: json.RawMessage(`{"command":"cat f"}`)},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := loop.handleToolCalls(ctx, content); err != nil {
+		t.Fatalf("handleToolCalls failed: %v", err)
+	}
+	if len(results) != 2 || len(results[1].ToolResult) == 0 || !strings.Contains(results[1].ToolResult[0].Text, "first") {
+		t.Errorf("expected the second command to see the first one's output, got %+v", results)
+	}
+}
+
 func TestMaxTokensTruncation(t *testing.T) {
 	var mu sync.Mutex
 	var recordedMessages []llm.Message
@@ -2136,3 +2552,147 @@ func TestMaxTokensTruncation(t *testing.T) {
 //		t.Error("expected to find tool2 result in message 3")
 //	}
 //}
+
+func TestHandleToolCallsReportsProgress(t *testing.T) {
+	type report struct{ id, output string }
+	var mu sync.Mutex
+	var reports []report
+	tool := &llm.Tool{
+		Name:        "progress_tool",
+		InputSchema: llm.MustSchema(`{"type":"object","properties":{}}`),
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			claudetool.ReportProgress(ctx, "halfway")
+			return llm.ToolOut{LLMContent: llm.TextContent("done")}
+		},
+	}
+
+	var recorded []llm.Message
+	loop := NewLoop(Config{
+		LLM:   NewPredictableService(),
+		Tools: []*llm.Tool{tool},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			recorded = append(recorded, message)
+			return nil
+		},
+		OnToolProgress: func(ctx context.Context, toolUseID, output string) {
+			mu.Lock()
+			reports = append(reports, report{toolUseID, output})
+			mu.Unlock()
+		},
+	})
+
+	content := []llm.Content{{
+		ID:        "tool_1",
+		Type:      llm.ContentTypeToolUse,
+		ToolName:  "progress_tool",
+		ToolInput: json.RawMessage(`{}`),
+	}}
+	if err := loop.handleToolCalls(context.Background(), content); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) != 1 || reports[0] != (report{"tool_1", "halfway"}) {
+		t.Fatalf("unexpected progress reports: %+v", reports)
+	}
+	// Progress is not recorded; the tool result is unchanged.
+	if len(recorded) == 0 || recorded[0].Content[0].ToolResult[0].Text != "done" {
+		t.Fatalf("unexpected recorded messages: %+v", recorded)
+	}
+}
+
+// pausingLLMService pauses the turn pauses times (once if zero), then finishes it.
+type pausingLLMService struct {
+	scriptedLLMService
+	pauses   int
+	requests []*llm.Request
+}
+
+func (s *pausingLLMService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	s.requests = append(s.requests, req)
+	if len(s.requests) <= max(s.pauses, 1) {
+		return &llm.Response{
+			Role:       llm.MessageRoleAssistant,
+			Content:    []llm.Content{{ID: "srvtoolu_1", Type: llm.ContentTypeServerToolUse, ToolName: "web_search", ToolInput: json.RawMessage(`{"query":"q"}`)}},
+			StopReason: llm.StopReasonPauseTurn,
+		}, nil
+	}
+	return s.scriptedLLMService.Do(ctx, req)
+}
+
+func TestLoopContinuesPausedTurn(t *testing.T) {
+	service := &pausingLLMService{scriptedLLMService: scriptedLLMService{text: "done"}}
+	var recorded []llm.Message
+	loop := NewLoop(Config{
+		LLM: service,
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			recorded = append(recorded, message)
+			return nil
+		},
+	})
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "search"}}})
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatalf("ProcessOneTurn() error = %v", err)
+	}
+
+	if len(service.requests) != 2 {
+		t.Fatalf("expected the paused turn to be continued, got %d requests", len(service.requests))
+	}
+	msgs := service.requests[1].Messages
+	if last := msgs[len(msgs)-1]; last.Role != llm.MessageRoleAssistant || last.Content[0].Type != llm.ContentTypeServerToolUse {
+		t.Errorf("expected the paused response to be sent back, got %+v", last)
+	}
+	if len(recorded) != 2 || recorded[0].EndOfTurn || !recorded[1].EndOfTurn {
+		t.Errorf("expected a paused message and a final one, got %+v", recorded)
+	}
+}
+
+func TestLoopLimitsPausedTurnContinuations(t *testing.T) {
+	service := &pausingLLMService{scriptedLLMService: scriptedLLMService{text: "done"}, pauses: 100}
+	var recorded []llm.Message
+	loop := NewLoop(Config{
+		LLM: service,
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			recorded = append(recorded, message)
+			return nil
+		},
+	})
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "search"}}})
+	if err := loop.ProcessOneTurn(context.Background()); err == nil {
+		t.Fatal("expected an error once the turn kept pausing")
+	}
+
+	if len(service.requests) != maxPauseContinuations+1 {
+		t.Errorf("expected %d requests, got %d", maxPauseContinuations+1, len(service.requests))
+	}
+	last := recorded[len(recorded)-1]
+	if !last.EndOfTurn || last.ErrorType != llm.ErrorTypeLLMRequest {
+		t.Errorf("expected the turn to end with an error message, got %+v", last)
+	}
+}
+
+func TestLoopThinkingLevel(t *testing.T) {
+	service := NewPredictableService()
+	high := llm.ThinkingLevelHigh
+	loop := NewLoop(Config{
+		LLM:           service,
+		ThinkingLevel: &high,
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error { return nil },
+	})
+
+	loop.QueueUserMessage(llm.UserStringMessage("echo: one"))
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatalf("ProcessOneTurn() error = %v", err)
+	}
+	if req := service.GetLastRequest(); req.ThinkingLevel == nil || *req.ThinkingLevel != high {
+		t.Errorf("expected the request to think at %v, got %v", high, req.ThinkingLevel)
+	}
+
+	loop.SetThinkingLevel(nil)
+	loop.QueueUserMessage(llm.UserStringMessage("echo: two"))
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatalf("ProcessOneTurn() error = %v", err)
+	}
+	if req := service.GetLastRequest(); req.ThinkingLevel != nil {
+		t.Errorf("expected the service's own thinking level, got %v", *req.ThinkingLevel)
+	}
+}
@@ -25,6 +25,9 @@ import (
 //   - "change_dir: <path>" - triggers change_dir tool
 //   - "delay: <seconds>" - delays response by specified seconds
 //   - See Do() method for complete list of supported patterns
+//
+// DoStream returns the same responses as Do, emitting text and thinking word by word
+// and tool input in small chunks before returning.
 type PredictableService struct {
 	// TokenContextWindow size
 	tokenContextWindow int
@@ -59,6 +62,39 @@ func (s *PredictableService) MaxImageDimension() int {
 	return 2000
 }
 
+var _ llm.StreamingService = (*PredictableService)(nil)
+
+// DoStream is like Do, but also emits the response as a sequence of deterministic deltas.
+func (s *PredictableService) DoStream(ctx context.Context, req *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
+	resp, err := s.Do(ctx, req)
+	if err != nil || onDelta == nil {
+		return resp, err
+	}
+	for i, c := range resp.Content {
+		switch c.Type {
+		case llm.ContentTypeText, llm.ContentTypeThinking:
+			text := c.Text
+			if c.Type == llm.ContentTypeThinking {
+				text = c.Thinking
+			}
+			for _, word := range strings.SplitAfter(text, " ") {
+				if word != "" {
+					onDelta(llm.StreamDelta{Index: i, Type: c.Type, Text: word})
+				}
+			}
+		case llm.ContentTypeToolUse:
+			onDelta(llm.StreamDelta{Index: i, Type: c.Type, ToolUseID: c.ID, ToolName: c.ToolName})
+			const chunkSize = 16
+			for input := string(c.ToolInput); input != ""; {
+				n := min(chunkSize, len(input))
+				onDelta(llm.StreamDelta{Index: i, Type: c.Type, ToolInput: input[:n]})
+				input = input[n:]
+			}
+		}
+	}
+	return resp, nil
+}
+
 // Do processes a request and returns a predictable response based on the input text
 func (s *PredictableService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
 	// Store request for testing inspection
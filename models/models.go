@@ -1,10 +1,12 @@
 package models
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
 	"shelley.exe.dev/db"
@@ -26,6 +28,7 @@ const (
 	ProviderFireworks Provider = "fireworks"
 	ProviderGemini    Provider = "gemini"
 	ProviderBuiltIn   Provider = "builtin"
+	ProviderLocal     Provider = "local" // Self-hosted OpenAI-compatible server (Ollama, llama.cpp, vLLM)
 )
 
 // ModelSource describes where a model's configuration comes from
@@ -57,6 +60,13 @@ type Model struct {
 	// GatewayEnabled indicates whether this model is available when using a gateway
 	GatewayEnabled bool
 
+	// Pricing is used to compute request cost when the gateway doesn't report it
+	Pricing llm.Pricing
+
+	// Fallbacks are model IDs to switch to, in order, when requests to this
+	// model keep failing. Unavailable models are skipped.
+	Fallbacks []string
+
 	// Factory creates an llm.Service instance for this model
 	Factory func(config *Config, httpc *http.Client) (llm.Service, error)
 }
@@ -119,6 +129,15 @@ type Config struct {
 
 	// Database for recording LLM requests (optional)
 	DB *db.DB
+
+	// Fallbacks overrides the fallback chains of models, keyed by model ID (optional).
+	// An empty chain disables fallback for that model.
+	Fallbacks map[string][]string
+
+	// LocalEndpoints are base URLs of self-hosted OpenAI-compatible servers
+	// (e.g. "http://localhost:11434/v1") whose models are discovered and offered
+	// as "local/<name>" (optional).
+	LocalEndpoints []string
 }
 
 // getAnthropicURL returns the Anthropic API URL, with gateway suffix if gateway is set
@@ -153,6 +172,14 @@ func (c *Config) getFireworksURL() string {
 	return "" // use default from oai package
 }
 
+// Prices in USD per million tokens, shared by models in the same family.
+var (
+	pricingClaudeOpus   = llm.Pricing{InputPerMTok: 5, OutputPerMTok: 25, CacheReadPerMTok: 0.50, CacheWritePerMTok: 6.25}
+	pricingClaudeSonnet = llm.Pricing{InputPerMTok: 3, OutputPerMTok: 15, CacheReadPerMTok: 0.30, CacheWritePerMTok: 3.75}
+	pricingClaudeHaiku  = llm.Pricing{InputPerMTok: 1, OutputPerMTok: 5, CacheReadPerMTok: 0.10, CacheWritePerMTok: 1.25}
+	pricingGPTCodex     = llm.Pricing{InputPerMTok: 1.75, OutputPerMTok: 14, CacheReadPerMTok: 0.175}
+)
+
 // All returns all available models in Shelley
 func All() []Model {
 	return []Model{
@@ -162,6 +189,7 @@ func All() []Model {
 			Description:     "Claude Opus 4.6 (default)",
 			RequiredEnvVars: []string{"ANTHROPIC_API_KEY"},
 			GatewayEnabled:  true,
+			Pricing:         pricingClaudeOpus,
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.AnthropicAPIKey == "" {
 					return nil, fmt.Errorf("claude-opus-4.6 requires ANTHROPIC_API_KEY")
@@ -179,6 +207,7 @@ func All() []Model {
 			Description:     "Claude Opus 4.5",
 			RequiredEnvVars: []string{"ANTHROPIC_API_KEY"},
 			GatewayEnabled:  true,
+			Pricing:         pricingClaudeOpus,
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.AnthropicAPIKey == "" {
 					return nil, fmt.Errorf("claude-opus-4.5 requires ANTHROPIC_API_KEY")
@@ -196,6 +225,8 @@ func All() []Model {
 			Description:     "Claude Sonnet 4.6",
 			RequiredEnvVars: []string{"ANTHROPIC_API_KEY"},
 			GatewayEnabled:  true,
+			Pricing:         pricingClaudeSonnet,
+			Fallbacks:       []string{"gpt-5.3-codex"},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.AnthropicAPIKey == "" {
 					return nil, fmt.Errorf("claude-sonnet-4.6 requires ANTHROPIC_API_KEY")
@@ -213,6 +244,7 @@ func All() []Model {
 			Description:     "Claude Sonnet 4.5",
 			RequiredEnvVars: []string{"ANTHROPIC_API_KEY"},
 			GatewayEnabled:  true,
+			Pricing:         pricingClaudeSonnet,
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.AnthropicAPIKey == "" {
 					return nil, fmt.Errorf("claude-sonnet-4.5 requires ANTHROPIC_API_KEY")
@@ -231,6 +263,7 @@ func All() []Model {
 			Tags:            "slug-backup",
 			RequiredEnvVars: []string{"ANTHROPIC_API_KEY"},
 			GatewayEnabled:  true,
+			Pricing:         pricingClaudeHaiku,
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.AnthropicAPIKey == "" {
 					return nil, fmt.Errorf("claude-haiku-4.5 requires ANTHROPIC_API_KEY")
@@ -248,6 +281,7 @@ func All() []Model {
 			Description:     "GLM-4.7 on Fireworks",
 			RequiredEnvVars: []string{"FIREWORKS_API_KEY"},
 			GatewayEnabled:  true,
+			Pricing:         llm.Pricing{InputPerMTok: 0.60, OutputPerMTok: 2.20, CacheReadPerMTok: 0.30},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.FireworksAPIKey == "" {
 					return nil, fmt.Errorf("glm-4.7-fireworks requires FIREWORKS_API_KEY")
@@ -265,6 +299,7 @@ func All() []Model {
 			Description:     "GPT-5.3 Codex",
 			RequiredEnvVars: []string{"OPENAI_API_KEY"},
 			GatewayEnabled:  true,
+			Pricing:         pricingGPTCodex,
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.OpenAIAPIKey == "" {
 					return nil, fmt.Errorf("gpt-5.3-codex requires OPENAI_API_KEY")
@@ -282,6 +317,7 @@ func All() []Model {
 			Description:     "GPT-5.2 Codex",
 			RequiredEnvVars: []string{"OPENAI_API_KEY"},
 			GatewayEnabled:  true,
+			Pricing:         pricingGPTCodex,
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.OpenAIAPIKey == "" {
 					return nil, fmt.Errorf("gpt-5.2-codex requires OPENAI_API_KEY")
@@ -300,6 +336,7 @@ func All() []Model {
 			Tags:            "slug",
 			RequiredEnvVars: []string{"FIREWORKS_API_KEY"},
 			GatewayEnabled:  true,
+			Pricing:         llm.Pricing{InputPerMTok: 0.07, OutputPerMTok: 0.30},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.FireworksAPIKey == "" {
 					return nil, fmt.Errorf("gpt-oss-20b-fireworks requires FIREWORKS_API_KEY")
@@ -316,6 +353,7 @@ func All() []Model {
 			Provider:        ProviderFireworks,
 			Description:     "GLM-4P6 on Fireworks",
 			RequiredEnvVars: []string{"FIREWORKS_API_KEY"},
+			Pricing:         llm.Pricing{InputPerMTok: 0.55, OutputPerMTok: 2.19},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.FireworksAPIKey == "" {
 					return nil, fmt.Errorf("glm-4p6-fireworks requires FIREWORKS_API_KEY")
@@ -332,11 +370,12 @@ func All() []Model {
 			Provider:        ProviderGemini,
 			Description:     "Gemini 3 Pro",
 			RequiredEnvVars: []string{"GEMINI_API_KEY"},
+			Pricing:         llm.Pricing{InputPerMTok: 2.00, OutputPerMTok: 12.00, CacheReadPerMTok: 0.20, CacheWritePerMTok: 2.00},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.GeminiAPIKey == "" {
 					return nil, fmt.Errorf("gemini-3-pro requires GEMINI_API_KEY")
 				}
-				svc := &gem.Service{APIKey: config.GeminiAPIKey, Model: "gemini-3-pro-preview", HTTPC: httpc}
+				svc := &gem.Service{APIKey: config.GeminiAPIKey, Model: "gemini-3-pro-preview", HTTPC: httpc, ThinkingLevel: llm.ThinkingLevelMedium}
 				if url := config.getGeminiURL(); url != "" {
 					svc.URL = url
 				}
@@ -348,11 +387,12 @@ func All() []Model {
 			Provider:        ProviderGemini,
 			Description:     "Gemini 3 Flash",
 			RequiredEnvVars: []string{"GEMINI_API_KEY"},
+			Pricing:         llm.Pricing{InputPerMTok: 0.50, OutputPerMTok: 3.00, CacheReadPerMTok: 0.05, CacheWritePerMTok: 0.50},
 			Factory: func(config *Config, httpc *http.Client) (llm.Service, error) {
 				if config.GeminiAPIKey == "" {
 					return nil, fmt.Errorf("gemini-3-flash requires GEMINI_API_KEY")
 				}
-				svc := &gem.Service{APIKey: config.GeminiAPIKey, Model: "gemini-3-flash-preview", HTTPC: httpc}
+				svc := &gem.Service{APIKey: config.GeminiAPIKey, Model: "gemini-3-flash-preview", HTTPC: httpc, ThinkingLevel: llm.ThinkingLevelMedium}
 				if url := config.getGeminiURL(); url != "" {
 					svc.URL = url
 				}
@@ -400,12 +440,15 @@ func Default() Model {
 
 // Manager manages LLM services for all configured models
 type Manager struct {
-	services   map[string]serviceEntry
-	modelOrder []string // ordered list of model IDs (built-in first, then custom)
-	logger     *slog.Logger
-	db         *db.DB       // for custom models and LLM request recording
-	httpc      *http.Client // HTTP client with recording middleware
-	cfg        *Config      // retained for refreshing custom models
+	mu          sync.RWMutex // guards services, modelOrder and the discovery state
+	services    map[string]serviceEntry
+	modelOrder  []string  // ordered list of model IDs (built-in first, then custom, then local)
+	discovered  time.Time // when local models were last discovered
+	discovering bool      // whether local models are being discovered in the background
+	logger      *slog.Logger
+	db          *db.DB       // for custom models and LLM request recording
+	httpc       *http.Client // HTTP client with recording middleware
+	cfg         *Config      // retained for refreshing custom models
 }
 
 type serviceEntry struct {
@@ -415,6 +458,7 @@ type serviceEntry struct {
 	source      string // Human-readable source (e.g., "exe.dev gateway", "$ANTHROPIC_API_KEY")
 	displayName string // For custom models, the user-provided display name
 	tags        string // For custom models, user-provided tags
+	fallbacks   []string
 }
 
 // ConfigInfo is an optional interface that services can implement to provide configuration details for logging
@@ -500,6 +544,19 @@ func (l *loggingService) MaxImageDimension() int {
 	return l.service.MaxImageDimension()
 }
 
+// ServerTools delegates to the underlying service if it supports it
+func (l *loggingService) ServerTools(names []string) []*llm.Tool {
+	if sts, ok := l.service.(llm.ServerToolService); ok {
+		return sts.ServerTools(names)
+	}
+	return nil
+}
+
+// MaxRequestBytes delegates to the underlying service
+func (l *loggingService) MaxRequestBytes() int {
+	return llm.MaxRequestBytes(l.service)
+}
+
 // UseSimplifiedPatch delegates to the underlying service if it supports it
 func (l *loggingService) UseSimplifiedPatch() bool {
 	if sp, ok := l.service.(llm.SimplifiedPatcher); ok {
@@ -594,6 +651,7 @@ func NewManager(cfg *Config) (*Manager, error) {
 			// Model not available (e.g., missing API key) - skip it
 			continue
 		}
+		setPricing(svc, model.Pricing)
 
 		manager.services[model.ID] = serviceEntry{
 			service:     svc,
@@ -602,6 +660,7 @@ func NewManager(cfg *Config) (*Manager, error) {
 			source:      model.Source(cfg),
 			displayName: model.ID, // built-in models use ID as display name
 			tags:        model.Tags,
+			fallbacks:   model.Fallbacks,
 		}
 		manager.modelOrder = append(manager.modelOrder, model.ID)
 	}
@@ -611,11 +670,16 @@ func NewManager(cfg *Config) (*Manager, error) {
 		cfg.Logger.Warn("Failed to load custom models", "error", err)
 	}
 
+	local := manager.discoverLocalModels()
+	manager.mu.Lock()
+	manager.setLocalModelsLocked(local)
+	manager.mu.Unlock()
+
 	return manager, nil
 }
 
 // loadCustomModels loads custom models from the database into the manager.
-// It adds them after built-in models in the order.
+// It adds them after the other models in the order. Callers hold m.mu.
 func (m *Manager) loadCustomModels() error {
 	if m.db == nil {
 		return nil
@@ -651,13 +715,121 @@ func (m *Manager) loadCustomModels() error {
 	return nil
 }
 
-// RefreshCustomModels reloads custom models from the database.
+// localRediscoverInterval is how long listing models waits after discovering
+// local models before discovering them again.
+const localRediscoverInterval = 30 * time.Second
+
+// isDiscovered reports whether entry is a model found at a local endpoint,
+// as opposed to a custom model that happens to be local.
+func (e serviceEntry) isDiscovered() bool {
+	return e.provider == ProviderLocal && e.source != string(SourceCustom)
+}
+
+// discoverLocalModels asks each configured local endpoint which models it
+// serves, and returns them by endpoint. Endpoints that can't be reached are
+// left out.
+func (m *Manager) discoverLocalModels() map[string][]serviceEntry {
+	if m.cfg == nil {
+		return nil
+	}
+	found := make(map[string][]serviceEntry)
+	for _, endpoint := range m.cfg.LocalEndpoints {
+		ctx, cancel := context.WithTimeout(context.Background(), localProbeTimeout)
+		localModels, err := oai.DiscoverLocalModels(ctx, nil, endpoint, "")
+		cancel()
+		if err != nil {
+			if m.logger != nil {
+				m.logger.Warn("Failed to discover local models", "endpoint", endpoint, "error", err)
+			}
+			continue
+		}
+		entries := []serviceEntry{}
+		for _, lm := range localModels {
+			entries = append(entries, serviceEntry{
+				service:     newLocalService(endpoint, "", lm, 0, m.httpc),
+				provider:    ProviderLocal,
+				modelID:     "local/" + lm.Name,
+				source:      endpoint,
+				displayName: lm.Name,
+			})
+		}
+		found[endpoint] = entries
+	}
+	return found
+}
+
+// setLocalModelsLocked replaces the models of the endpoints in found, and
+// puts all local models after the other models. The models of endpoints
+// that couldn't be reached are kept until they can. Callers hold m.mu.
+func (m *Manager) setLocalModelsLocked(found map[string][]serviceEntry) {
+	var local []serviceEntry
+	previous := make(map[string]bool)
+	order := make([]string, 0, len(m.modelOrder))
+	for _, id := range m.modelOrder {
+		entry := m.services[id]
+		if !entry.isDiscovered() {
+			order = append(order, id)
+			continue
+		}
+		delete(m.services, id)
+		previous[id] = true
+		if _, ok := found[entry.source]; !ok {
+			local = append(local, entry)
+		}
+	}
+	if m.cfg != nil {
+		for _, endpoint := range m.cfg.LocalEndpoints {
+			local = append(local, found[endpoint]...)
+		}
+	}
+	for _, entry := range local {
+		if _, exists := m.services[entry.modelID]; exists {
+			continue
+		}
+		m.services[entry.modelID] = entry
+		order = append(order, entry.modelID)
+		if !previous[entry.modelID] && m.logger != nil {
+			m.logger.Info("Discovered local model", "model", entry.modelID, "endpoint", entry.source)
+		}
+	}
+	m.modelOrder = order
+	m.discovered = time.Now()
+}
+
+// rediscoverLocalModels discovers local models again in the background, if
+// it has been a while, so that models loaded into or removed from a local
+// server after startup show up in the next listing.
+func (m *Manager) rediscoverLocalModels() {
+	if m.cfg == nil || len(m.cfg.LocalEndpoints) == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.discovering || time.Since(m.discovered) < localRediscoverInterval {
+		return
+	}
+	m.discovering = true
+	go func() {
+		found := m.discoverLocalModels()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.setLocalModelsLocked(found)
+		m.discovering = false
+	}()
+}
+
+// RefreshCustomModels reloads custom models from the database and
+// rediscovers the models at local endpoints.
 // Call this after adding or removing custom models via the UI.
 func (m *Manager) RefreshCustomModels() error {
-	if m.db == nil {
+	if m.db == nil && (m.cfg == nil || len(m.cfg.LocalEndpoints) == 0) {
 		return nil
 	}
 
+	found := m.discoverLocalModels()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	// Remove existing custom models from services and modelOrder
 	newOrder := make([]string, 0, len(m.modelOrder))
 	for _, id := range m.modelOrder {
@@ -671,12 +843,16 @@ func (m *Manager) RefreshCustomModels() error {
 	m.modelOrder = newOrder
 
 	// Reload custom models
-	return m.loadCustomModels()
+	err := m.loadCustomModels()
+	m.setLocalModelsLocked(found)
+	return err
 }
 
 // GetService returns the LLM service for the given model ID, wrapped with logging
 func (m *Manager) GetService(modelID string) (llm.Service, error) {
+	m.mu.RLock()
 	entry, ok := m.services[modelID]
+	m.mu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("unsupported model: %s", modelID)
 	}
@@ -695,8 +871,13 @@ func (m *Manager) GetService(modelID string) (llm.Service, error) {
 }
 
 // GetAvailableModels returns a list of available model IDs.
-// Returns union of built-in models (in order) followed by custom models.
+// Returns union of built-in models (in order) followed by custom models,
+// then local ones. Listing models rediscovers local ones in the background.
 func (m *Manager) GetAvailableModels() []string {
+	m.rediscoverLocalModels()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	// Return a copy to prevent external modification
 	result := make([]string, len(m.modelOrder))
 	copy(result, m.modelOrder)
@@ -705,6 +886,8 @@ func (m *Manager) GetAvailableModels() []string {
 
 // HasModel reports whether the manager has a service for the given model ID
 func (m *Manager) HasModel(modelID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	_, ok := m.services[modelID]
 	return ok
 }
@@ -713,19 +896,81 @@ func (m *Manager) HasModel(modelID string) bool {
 type ModelInfo struct {
 	DisplayName string
 	Tags        string
-	Source      string // Human-readable source (e.g., "exe.dev gateway", "$ANTHROPIC_API_KEY", "custom")
+	Source      string   // Human-readable source (e.g., "exe.dev gateway", "$ANTHROPIC_API_KEY", "custom")
+	Fallbacks   []string // Model IDs to switch to when requests keep failing
 }
 
 // GetModelInfo returns the display name, tags, and source for a model
 func (m *Manager) GetModelInfo(modelID string) *ModelInfo {
+	m.mu.RLock()
 	entry, ok := m.services[modelID]
+	m.mu.RUnlock()
 	if !ok {
 		return nil
 	}
+	fallbacks := entry.fallbacks
+	if m.cfg != nil {
+		if override, ok := m.cfg.Fallbacks[modelID]; ok {
+			fallbacks = override
+		}
+	}
 	return &ModelInfo{
 		DisplayName: entry.displayName,
 		Tags:        entry.tags,
 		Source:      entry.source,
+		Fallbacks:   fallbacks,
+	}
+}
+
+// setPricing sets the pricing used by svc to compute request cost, for the
+// service types that support it.
+func setPricing(svc llm.Service, pricing llm.Pricing) {
+	switch svc := svc.(type) {
+	case *ant.Service:
+		svc.Pricing = pricing
+	case *oai.Service:
+		svc.Pricing = pricing
+	case *oai.ResponsesService:
+		svc.Pricing = pricing
+	case *gem.Service:
+		svc.Pricing = pricing
+	}
+}
+
+// customModelPricing returns the pricing configured for a custom model.
+func customModelPricing(model *generated.Model) llm.Pricing {
+	return llm.Pricing{
+		InputPerMTok:      model.InputPricePerMtok,
+		OutputPerMTok:     model.OutputPricePerMtok,
+		CacheReadPerMTok:  model.CacheReadPricePerMtok,
+		CacheWritePerMTok: model.CacheWritePricePerMtok,
+	}
+}
+
+// localProbeTimeout bounds asking a local server about its models.
+const localProbeTimeout = 10 * time.Second
+
+// newLocalService creates a service for a model on a local server. A nonzero
+// contextWindow overrides the probed one. Output is limited to a quarter of
+// the context window, as some servers (vLLM) reject requests whose prompt and
+// max_tokens together exceed it.
+func newLocalService(endpoint, apiKey string, lm oai.LocalModel, contextWindow int, httpc *http.Client) *oai.Service {
+	contextWindow = cmp.Or(contextWindow, lm.ContextWindow)
+	maxTokens := 0
+	if contextWindow > 0 {
+		maxTokens = min(contextWindow/4, oai.DefaultMaxTokens)
+	}
+	return &oai.Service{
+		APIKey:   apiKey,
+		ModelURL: endpoint,
+		Model: oai.Model{
+			ModelName: lm.Name,
+			URL:       endpoint,
+		},
+		MaxTokens:     maxTokens,
+		ContextWindow: contextWindow,
+		TextToolCalls: !lm.NativeTools,
+		HTTPC:         httpc,
 	}
 }
 
@@ -733,12 +978,15 @@ func (m *Manager) GetModelInfo(modelID string) *ModelInfo {
 func (m *Manager) createServiceFromModel(model *generated.Model) llm.Service {
 	switch model.ProviderType {
 	case "anthropic":
+		// max_tokens is the model's context window; over 200k enables the long context beta.
 		return &ant.Service{
 			APIKey:        model.ApiKey,
 			URL:           model.Endpoint,
 			Model:         model.ModelName,
 			HTTPC:         m.httpc,
 			ThinkingLevel: llm.ThinkingLevelMedium,
+			Pricing:       customModelPricing(model),
+			ContextWindow: int(model.MaxTokens),
 		}
 	case "openai":
 		return &oai.Service{
@@ -750,6 +998,7 @@ func (m *Manager) createServiceFromModel(model *generated.Model) llm.Service {
 			},
 			MaxTokens: int(model.MaxTokens),
 			HTTPC:     m.httpc,
+			Pricing:   customModelPricing(model),
 		}
 	case "openai-responses":
 		return &oai.ResponsesService{
@@ -762,14 +1011,31 @@ func (m *Manager) createServiceFromModel(model *generated.Model) llm.Service {
 			MaxTokens:     int(model.MaxTokens),
 			HTTPC:         m.httpc,
 			ThinkingLevel: llm.ThinkingLevelMedium,
+			Pricing:       customModelPricing(model),
 		}
 	case "gemini":
 		return &gem.Service{
-			APIKey: model.ApiKey,
-			URL:    model.Endpoint,
-			Model:  model.ModelName,
-			HTTPC:  m.httpc,
+			APIKey:        model.ApiKey,
+			URL:           model.Endpoint,
+			Model:         model.ModelName,
+			HTTPC:         m.httpc,
+			Pricing:       customModelPricing(model),
+			ThinkingLevel: llm.ThinkingLevelMedium,
+		}
+	case "local":
+		ctx, cancel := context.WithTimeout(context.Background(), localProbeTimeout)
+		defer cancel()
+		lm, err := oai.ProbeLocalModel(ctx, nil, model.Endpoint, model.ApiKey, model.ModelName)
+		if err != nil {
+			// Assume native tool calls until the server can be reached.
+			if m.logger != nil {
+				m.logger.Warn("Failed to probe local model", "model_id", model.ModelID, "endpoint", model.Endpoint, "error", err)
+			}
+			lm = oai.LocalModel{Name: model.ModelName, NativeTools: true}
 		}
+		svc := newLocalService(model.Endpoint, model.ApiKey, lm, int(model.MaxTokens), m.httpc)
+		svc.Pricing = customModelPricing(model)
+		return svc
 	default:
 		if m.logger != nil {
 			m.logger.Error("Unknown provider type for model", "model_id", model.ModelID, "provider_type", model.ProviderType)
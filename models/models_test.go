@@ -2,11 +2,20 @@ package models
 
 import (
 	"context"
+	"encoding/json"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"shelley.exe.dev/db/generated"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/llm/ant"
+	"shelley.exe.dev/llm/oai"
 )
 
 func TestAll(t *testing.T) {
@@ -512,3 +521,161 @@ func TestGetAvailableModelsUnion(t *testing.T) {
 		}
 	}
 }
+
+func TestBuiltInPricing(t *testing.T) {
+	for _, m := range All() {
+		if m.ID == "predictable" {
+			continue
+		}
+		if m.Pricing.InputPerMTok <= 0 || m.Pricing.OutputPerMTok <= 0 {
+			t.Errorf("model %s has no pricing: %+v", m.ID, m.Pricing)
+		}
+	}
+
+	// The manager hands each model's pricing to its service.
+	manager, err := NewManager(&Config{AnthropicAPIKey: "test-key"})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	svc, ok := manager.services["claude-sonnet-4.5"].service.(*ant.Service)
+	if !ok {
+		t.Fatalf("expected *ant.Service for claude-sonnet-4.5")
+	}
+	if svc.Pricing != ByID("claude-sonnet-4.5").Pricing {
+		t.Errorf("service pricing = %+v, want %+v", svc.Pricing, ByID("claude-sonnet-4.5").Pricing)
+	}
+}
+
+func TestModelFallbacks(t *testing.T) {
+	manager, err := NewManager(&Config{AnthropicAPIKey: "test-key", OpenAIAPIKey: "test-key"})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	info := manager.GetModelInfo("claude-sonnet-4.6")
+	if info == nil {
+		t.Fatal("GetModelInfo('claude-sonnet-4.6') returned nil")
+	}
+	if !slices.Equal(info.Fallbacks, []string{"gpt-5.3-codex"}) {
+		t.Errorf("built-in fallbacks = %v, want [gpt-5.3-codex]", info.Fallbacks)
+	}
+
+	// Config overrides the built-in chain; an empty chain disables fallback.
+	manager, err = NewManager(&Config{
+		AnthropicAPIKey: "test-key",
+		OpenAIAPIKey:    "test-key",
+		Fallbacks: map[string][]string{
+			"claude-sonnet-4.6": {},
+			"gpt-5.3-codex":     {"claude-sonnet-4.6"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if fallbacks := manager.GetModelInfo("claude-sonnet-4.6").Fallbacks; len(fallbacks) != 0 {
+		t.Errorf("overridden fallbacks = %v, want none", fallbacks)
+	}
+	if fallbacks := manager.GetModelInfo("gpt-5.3-codex").Fallbacks; !slices.Equal(fallbacks, []string{"claude-sonnet-4.6"}) {
+		t.Errorf("overridden fallbacks = %v, want [claude-sonnet-4.6]", fallbacks)
+	}
+}
+
+func TestLocalModels(t *testing.T) {
+	var mu sync.Mutex
+	served := `{"data": [{"id": "qwen3:8b"}, {"id": "gemma2:9b"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			mu.Lock()
+			defer mu.Unlock()
+			if served == "" {
+				http.Error(w, "down", http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(served))
+		case "/api/show":
+			var req struct{ Model string }
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Model == "qwen3:8b" {
+				w.Write([]byte(`{"capabilities": ["tools"], "model_info": {"qwen3.context_length": 40960}}`))
+			} else {
+				w.Write([]byte(`{"capabilities": ["completion"], "model_info": {"gemma2.context_length": 8192}}`))
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	endpoint := server.URL + "/v1"
+	manager, err := NewManager(&Config{LocalEndpoints: []string{endpoint}})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if !manager.HasModel("local/qwen3:8b") || !manager.HasModel("local/gemma2:9b") {
+		t.Fatalf("expected the local models to be discovered, got %v", manager.GetAvailableModels())
+	}
+	if info := manager.GetModelInfo("local/qwen3:8b"); info.Source != endpoint || info.DisplayName != "qwen3:8b" {
+		t.Errorf("unexpected model info: %+v", info)
+	}
+
+	qwen := manager.services["local/qwen3:8b"].service.(*oai.Service)
+	if qwen.TokenContextWindow() != 40960 || qwen.MaxTokens != 10240 || qwen.TextToolCalls {
+		t.Errorf("unexpected qwen3 service: %+v", qwen)
+	}
+	gemma := manager.services["local/gemma2:9b"].service.(*oai.Service)
+	if gemma.TokenContextWindow() != 8192 || !gemma.TextToolCalls {
+		t.Errorf("expected gemma2 to use text tool calls: %+v", gemma)
+	}
+
+	// Refreshing rediscovers rather than duplicates the models.
+	if err := manager.RefreshCustomModels(); err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for _, id := range manager.GetAvailableModels() {
+		if strings.HasPrefix(id, "local/") {
+			count++
+		}
+	}
+	if count != 2 {
+		t.Errorf("expected 2 local models after refresh, got %v", manager.GetAvailableModels())
+	}
+
+	// Listing models rediscovers them in the background once they are stale,
+	// keeping the models of an endpoint that can't be reached.
+	rediscover := func(models string) {
+		t.Helper()
+		mu.Lock()
+		served = models
+		mu.Unlock()
+		manager.mu.Lock()
+		manager.discovered = time.Time{}
+		manager.mu.Unlock()
+		manager.GetAvailableModels()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			manager.mu.RLock()
+			done := !manager.discovering
+			manager.mu.RUnlock()
+			if done {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatal("timed out waiting for local models to be rediscovered")
+	}
+	rediscover("")
+	if !manager.HasModel("local/qwen3:8b") {
+		t.Errorf("expected the models of an unreachable endpoint to be kept, got %v", manager.GetAvailableModels())
+	}
+	rediscover(`{"data": [{"id": "qwen3:8b"}, {"id": "llama3:8b"}]}`)
+	if !manager.HasModel("local/llama3:8b") || manager.HasModel("local/gemma2:9b") {
+		t.Errorf("expected the rediscovered models, got %v", manager.GetAvailableModels())
+	}
+
+	// A custom local model with max_tokens set uses it as the context window.
+	svc := manager.createServiceFromModel(&generated.Model{ModelID: "custom-1", ProviderType: "local", Endpoint: endpoint, ModelName: "gemma2:9b", MaxTokens: 4096})
+	if local := svc.(*oai.Service); local.TokenContextWindow() != 4096 || !local.TextToolCalls {
+		t.Errorf("unexpected custom local service: %+v", local)
+	}
+}
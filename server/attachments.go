@@ -0,0 +1,190 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"shelley.exe.dev/claudetool/browse"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/llm/imageutil"
+	"shelley.exe.dev/llm/pdfutil"
+)
+
+// Attachment is a file sent with a chat message: an image, a PDF, or a text
+// file. Its contents are either inline, in Data, or a file saved by
+// /api/upload, in Path.
+type Attachment struct {
+	Filename string `json:"filename,omitempty"`
+	// MediaType is the attachment's type; by default, it is detected from
+	// its contents.
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"` // base64-encoded
+	Path      string `json:"path,omitempty"`
+}
+
+const (
+	// maxAttachmentSize bounds each attachment, like uploads.
+	maxAttachmentSize = 10 * 1024 * 1024
+	// maxAttachmentsSize bounds a message's attachments together.
+	maxAttachmentsSize = 32 * 1024 * 1024
+	maxAttachments     = 20
+	// maxChatRequestSize bounds chat request bodies, which hold the message
+	// and its attachments base64-encoded.
+	maxChatRequestSize = maxAttachmentsSize/3*4 + 8*1024*1024
+)
+
+// attachmentContents converts a message's attachments to content for
+// llmService: images become image blocks, downscaled to the model's limit,
+// and PDFs and text files become document blocks. Attachments that together
+// are too large, for the server or for llmService's requests, are rejected.
+// On failure, it returns an HTTP status code and message, like validateSandboxMode.
+func attachmentContents(attachments []Attachment, llmService llm.Service) ([]llm.Content, int, string) {
+	if len(attachments) > maxAttachments {
+		return nil, http.StatusBadRequest, fmt.Sprintf("At most %d attachments are allowed", maxAttachments)
+	}
+	var contents []llm.Content
+	var total, encoded int
+	for i, a := range attachments {
+		name := a.Filename
+		if name == "" {
+			name = fmt.Sprintf("attachment %d", i+1)
+		}
+		data, err := attachmentData(a)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Sprintf("%s: %v", name, err)
+		}
+		if total += len(data); total > maxAttachmentsSize {
+			return nil, http.StatusRequestEntityTooLarge, fmt.Sprintf("Attachments exceed %d bytes in total", maxAttachmentsSize)
+		}
+		c, code, msg := attachmentContent(a, data, llmService.MaxImageDimension())
+		if code != 0 {
+			return nil, code, fmt.Sprintf("%s: %s", name, msg)
+		}
+		encoded += len(c.Data) + len(c.Text)
+		contents = append(contents, c)
+	}
+	// The rest of the conversation counts too, but the attachments alone
+	// are enough to know that the provider would refuse the request.
+	if limit := llm.MaxRequestBytes(llmService); limit > 0 && encoded > limit {
+		return nil, http.StatusRequestEntityTooLarge, fmt.Sprintf("Attachments are too large for this model: %d bytes encoded, but its requests are limited to %d", encoded, limit)
+	}
+	return contents, 0, ""
+}
+
+// attachmentData returns the contents of an attachment.
+func attachmentData(a Attachment) ([]byte, error) {
+	switch {
+	case a.Data != "" && a.Path != "":
+		return nil, fmt.Errorf("data and path are mutually exclusive")
+	case a.Data != "":
+		if base64.StdEncoding.DecodedLen(len(a.Data)) > maxAttachmentSize+2 {
+			return nil, fmt.Errorf("attachment exceeds %d bytes", maxAttachmentSize)
+		}
+		data, err := base64.StdEncoding.DecodeString(a.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 data: %w", err)
+		}
+		return data, nil
+	case a.Path != "":
+		// Only files saved by /api/upload can be attached by path.
+		path := filepath.Clean(a.Path)
+		if filepath.Dir(path) != filepath.Clean(browse.ScreenshotDir) {
+			return nil, fmt.Errorf("path must be a file returned by /api/upload")
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("attachment not found")
+		}
+		if info.Size() > maxAttachmentSize {
+			return nil, fmt.Errorf("attachment exceeds %d bytes", maxAttachmentSize)
+		}
+		return os.ReadFile(path)
+	default:
+		return nil, fmt.Errorf("data or path is required")
+	}
+}
+
+// attachmentContent converts one attachment's data to content.
+func attachmentContent(a Attachment, data []byte, maxImageDimension int) (llm.Content, int, string) {
+	filename := a.Filename
+	if filename == "" && a.Path != "" {
+		filename = filepath.Base(a.Path)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(a.MediaType)
+	if imageutil.IsHEIC(data) {
+		// Go can't decode HEIC, and most providers don't accept it.
+		png, err := imageutil.ConvertHEICToPNG(data)
+		if err != nil {
+			return llm.Content{}, http.StatusUnprocessableEntity, fmt.Sprintf("failed to convert HEIC image: %v", err)
+		}
+		data, mediaType = png, "image/png"
+	}
+	if mediaType == "" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		// Decoding the image also gives its real format, which providers
+		// check against the media type.
+		dim := maxImageDimension
+		if dim <= 0 {
+			dim = math.MaxInt
+		}
+		resized, format, _, err := imageutil.ResizeImage(data, dim)
+		if err != nil {
+			return llm.Content{}, http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported image: %v", err)
+		}
+		return llm.Content{
+			Type:      llm.ContentTypeText,
+			MediaType: "image/" + format,
+			Data:      base64.StdEncoding.EncodeToString(resized),
+		}, 0, ""
+	case mediaType == "application/pdf" || pdfutil.IsPDF(data):
+		if !pdfutil.IsPDF(data) {
+			return llm.Content{}, http.StatusBadRequest, "not a PDF"
+		}
+		// The extracted text is for providers that can't read PDFs.
+		text, err := pdfutil.ExtractText(data)
+		if err != nil || text == "" {
+			text = "(No text could be extracted from this PDF.)"
+		}
+		return llm.Content{
+			Type:      llm.ContentTypeText,
+			MediaType: "application/pdf",
+			Data:      base64.StdEncoding.EncodeToString(data),
+			Text:      text,
+			Filename:  filename,
+		}, 0, ""
+	case strings.HasPrefix(mediaType, "text/") || isTextMediaType(mediaType):
+		if !utf8.Valid(data) {
+			return llm.Content{}, http.StatusUnsupportedMediaType, "text attachments must be UTF-8"
+		}
+		return llm.Content{
+			Type:      llm.ContentTypeText,
+			MediaType: "text/plain",
+			Text:      string(data),
+			Filename:  filename,
+		}, 0, ""
+	default:
+		return llm.Content{}, http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported attachment type %s", mediaType)
+	}
+}
+
+// isTextMediaType reports whether a non-text/* media type holds text,
+// as source files often do.
+func isTextMediaType(mediaType string) bool {
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-yaml", "application/yaml", "application/toml", "application/x-sh":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+func TestChatAttachments(t *testing.T) {
+	h := NewTestHarness(t)
+
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 3000, 100))); err != nil {
+		t.Fatal(err)
+	}
+	pdf := "%PDF-1.4\n1 0 obj\n<< /Length 30 >>\nstream\nBT (Quarterly report) Tj ET\nendstream\nendobj\n%%EOF\n"
+
+	body, _ := json.Marshal(ChatRequest{
+		Message: "echo: what are these?",
+		Model:   "predictable",
+		Attachments: []Attachment{
+			{Filename: "wide.png", Data: base64.StdEncoding.EncodeToString(img.Bytes())},
+			{Filename: "notes.txt", MediaType: "text/plain", Data: base64.StdEncoding.EncodeToString([]byte("some notes"))},
+			{Filename: "report.pdf", Data: base64.StdEncoding.EncodeToString([]byte(pdf))},
+		},
+	})
+	req := httptest.NewRequest("POST", "/api/conversations/new", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	h.server.handleNewConversation(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	h.convID = resp.ConversationID
+	h.WaitResponse()
+
+	// The attachments persist with the user message.
+	var messages []generated.Message
+	h.db.Queries(context.Background(), func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(context.Background(), h.convID)
+		return err
+	})
+	var userMsg llm.Message
+	for _, m := range messages {
+		if m.Type == string(db.MessageTypeUser) && m.LlmData != nil {
+			json.Unmarshal([]byte(*m.LlmData), &userMsg)
+			break
+		}
+	}
+	if len(userMsg.Content) != 4 {
+		t.Fatalf("expected text and 3 attachments, got %+v", userMsg.Content)
+	}
+	if userMsg.Content[0].Text != "echo: what are these?" {
+		t.Errorf("expected the text first, got %+v", userMsg.Content[0])
+	}
+
+	image := userMsg.Content[1]
+	if !image.IsImage() || image.MediaType != "image/png" {
+		t.Fatalf("expected a PNG image, got %+v", image)
+	}
+	data, _ := base64.StdEncoding.DecodeString(image.Data)
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode image: %v", err)
+	}
+	if cfg.Width != h.llm.MaxImageDimension() {
+		t.Errorf("expected the image downscaled to %d wide, got %d", h.llm.MaxImageDimension(), cfg.Width)
+	}
+
+	text := userMsg.Content[2]
+	if !text.IsDocument() || text.Text != "some notes" || text.Filename != "notes.txt" {
+		t.Errorf("unexpected text document: %+v", text)
+	}
+	doc := userMsg.Content[3]
+	if !doc.IsDocument() || doc.MediaType != "application/pdf" || doc.Data == "" || doc.Text != "Quarterly report" {
+		t.Errorf("unexpected PDF document: %+v", doc)
+	}
+
+	// The model saw them too.
+	last := h.llm.GetLastRequest()
+	var sent llm.Message
+	for _, m := range last.Messages {
+		if m.Role == llm.MessageRoleUser {
+			sent = m
+			break
+		}
+	}
+	if len(sent.Content) != 4 || !sent.Content[1].IsImage() {
+		t.Errorf("expected attachments in the request, got %+v", sent.Content)
+	}
+}
+
+func TestChatAttachmentErrors(t *testing.T) {
+	h := NewTestHarness(t)
+	h.NewConversation("echo: hi", "")
+	h.WaitResponse()
+
+	tests := []struct {
+		name       string
+		attachment Attachment
+		want       int
+	}{
+		{"unsupported", Attachment{Filename: "a.bin", Data: base64.StdEncoding.EncodeToString([]byte{0x00, 0x01, 0xff, 0xfe})}, http.StatusUnsupportedMediaType},
+		{"bad image", Attachment{Filename: "a.png", MediaType: "image/png", Data: base64.StdEncoding.EncodeToString([]byte("not a png"))}, http.StatusUnsupportedMediaType},
+		{"bad base64", Attachment{Data: "!!!"}, http.StatusBadRequest},
+		{"outside uploads", Attachment{Path: "/etc/passwd"}, http.StatusBadRequest},
+		{"empty", Attachment{Filename: "a.txt"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(ChatRequest{Message: "echo: x", Model: "predictable", Attachments: []Attachment{tt.attachment}})
+			req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/chat", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			h.server.handleChatConversation(w, req, h.convID)
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// limitedService is a service whose requests are limited to maxBytes.
+type limitedService struct {
+	*loop.PredictableService
+	maxBytes int
+}
+
+func (s limitedService) MaxRequestBytes() int { return s.maxBytes }
+
+func TestAttachmentSizeLimits(t *testing.T) {
+	text := func(size int) Attachment {
+		return Attachment{Filename: "a.txt", Data: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("a"), size))}
+	}
+
+	// Each attachment is within its own limit, but not all of them together.
+	var many []Attachment
+	for range maxAttachmentsSize/maxAttachmentSize + 1 {
+		many = append(many, text(maxAttachmentSize))
+	}
+	if _, code, msg := attachmentContents(many, loop.NewPredictableService()); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for too many bytes in total, got %d: %s", code, msg)
+	}
+
+	svc := limitedService{loop.NewPredictableService(), 1000}
+	if _, code, msg := attachmentContents([]Attachment{text(500)}, svc); code != 0 {
+		t.Errorf("expected a small attachment to fit the model's requests, got %d: %s", code, msg)
+	}
+	if _, code, msg := attachmentContents([]Attachment{text(600), text(600)}, svc); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for attachments too large for the model, got %d: %s", code, msg)
+	}
+}
+
+func TestChatRequestSizeLimit(t *testing.T) {
+	h := NewTestHarness(t)
+	h.NewConversation("echo: hi", "")
+	h.WaitResponse()
+
+	body := append([]byte(`{"message":"`), bytes.Repeat([]byte("a"), maxChatRequestSize)...)
+	body = append(body, `"}`...)
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/chat", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	h.server.handleChatConversation(w, req, h.convID)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d: %s", w.Code, w.Body.String())
+	}
+}
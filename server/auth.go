@@ -0,0 +1,663 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"shelley.exe.dev/db/generated"
+)
+
+// Built-in authentication, enabled with "serve --auth". The password is set
+// on first run, using a setup code printed to the server log. Browsers get a
+// signed session cookie; scripts and the client CLI use API tokens, sent as
+// "Authorization: Bearer <token>". Both are rows in auth_sessions, so they
+// can be listed and revoked.
+
+const (
+	sessionCookieName = "shelley_session"
+	sessionLifetime   = 30 * 24 * time.Hour
+	apiTokenPrefix    = "shelley_"
+	minPasswordLength = 8
+
+	// passwordIterations is the PBKDF2-SHA256 work factor, as recommended
+	// by OWASP.
+	passwordIterations = 600_000
+
+	// Failed logins are limited to maxLoginFailures per loginFailureWindow,
+	// across all clients.
+	maxLoginFailures   = 10
+	loginFailureWindow = time.Minute
+
+	// touchInterval limits how often a session's last use is recorded.
+	touchInterval = time.Minute
+)
+
+// authState is the server's built-in authentication state. It is nil when
+// authentication is disabled.
+type authState struct {
+	mu        sync.Mutex
+	setupCode string      // required to set the first password
+	secret    []byte      // signs session cookies; nil until a password is set
+	failures  []time.Time // recent failed logins
+}
+
+// authInfo describes how a request was authenticated.
+type authInfo struct {
+	SessionID string
+	Kind      string // "session" or "token"
+}
+
+type authContextKey struct{}
+
+func authFromContext(ctx context.Context) *authInfo {
+	info, _ := ctx.Value(authContextKey{}).(*authInfo)
+	return info
+}
+
+// EnableAuth turns on built-in authentication for the TCP listener. If no
+// password has been set yet, it logs a setup code needed to choose one.
+func (s *Server) EnableAuth(ctx context.Context) error {
+	a := &authState{}
+	config, err := s.db.GetAuthConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load auth config: %w", err)
+	}
+	if config != nil {
+		if a.secret, err = hex.DecodeString(config.SessionSecret); err != nil {
+			return fmt.Errorf("invalid session secret: %w", err)
+		}
+	} else {
+		a.setupCode = rand.Text()
+		s.logger.Warn("Authentication is enabled but no password is set. Open Shelley and enter this setup code to choose one.", "setup_code", a.setupCode)
+	}
+	if err := s.db.DeleteExpiredAuthSessions(ctx, time.Now().UTC()); err != nil {
+		s.logger.Warn("Failed to delete expired sessions", "error", err)
+	}
+	s.auth = a
+	return nil
+}
+
+// needsSetup reports whether a password has yet to be set.
+func (a *authState) needsSetup() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.secret == nil
+}
+
+// hashPassword returns an encoded PBKDF2 hash of a password.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+// checkPassword reports whether a password matches an encoded hash.
+func checkPassword(encoded, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := enc.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// hashToken returns the stored form of an API token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// signSession returns the cookie value for a session: its ID and an HMAC of
+// the ID, so forged cookies are rejected without a database lookup.
+func (a *authState) signSession(sessionID string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(sessionID))
+	return sessionID + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifySession returns the session ID in a cookie value with a valid
+// signature.
+func (a *authState) verifySession(value string) (string, bool) {
+	sessionID, sig, ok := strings.Cut(value, ".")
+	if !ok || sessionID == "" {
+		return "", false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return "", false
+	}
+	a.mu.Lock()
+	secret := a.secret
+	a.mu.Unlock()
+	if secret == nil {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(sessionID))
+	return sessionID, hmac.Equal(got, mac.Sum(nil))
+}
+
+// allowLogin reports whether another login attempt may be made now.
+func (a *authState) allowLogin(now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.failures = slices.DeleteFunc(a.failures, func(t time.Time) bool {
+		return now.Sub(t) > loginFailureWindow
+	})
+	return len(a.failures) < maxLoginFailures
+}
+
+func (a *authState) recordLoginFailure(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.failures = append(a.failures, now)
+}
+
+// authenticate returns how a request is authenticated, or nil if it isn't.
+func (s *Server) authenticate(r *http.Request) *authInfo {
+	ctx := r.Context()
+	var session *generated.AuthSession
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = strings.TrimSpace(token)
+		if !strings.HasPrefix(token, apiTokenPrefix) {
+			return nil
+		}
+		var err error
+		if session, err = s.db.GetAuthSessionByTokenHash(ctx, hashToken(token)); err != nil || session.Kind != "token" {
+			return nil
+		}
+	} else if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		sessionID, ok := s.auth.verifySession(cookie.Value)
+		if !ok {
+			return nil
+		}
+		if session, err = s.db.GetAuthSession(ctx, sessionID); err != nil || session.Kind != "session" {
+			return nil
+		}
+	} else {
+		return nil
+	}
+
+	now := time.Now()
+	if session.ExpiresAt != nil && session.ExpiresAt.Before(now) {
+		return nil
+	}
+	if session.LastUsedAt == nil || now.Sub(*session.LastUsedAt) > touchInterval {
+		if err := s.db.TouchAuthSession(ctx, session.SessionID); err != nil {
+			s.logger.Warn("Failed to record session use", "error", err)
+		}
+	}
+	return &authInfo{SessionID: session.SessionID, Kind: session.Kind}
+}
+
+// authPublicPaths are reachable without signing in.
+var authPublicPaths = []string{
+	"/api/auth/status",
+	"/api/auth/login",
+	"/api/auth/setup",
+	"/version",
+}
+
+// authProtectedPaths are the non-API endpoints that need signing in. The UI's
+// static assets don't; index.html leaves out its init data until signed in.
+var authProtectedPaths = []string{
+	"/settings",
+	"/upgrade",
+	"/exit",
+	"/version-check",
+	"/version-changelog",
+}
+
+func authRequired(path string) bool {
+	if slices.Contains(authPublicPaths, path) {
+		return false
+	}
+	return strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/debug/") || slices.Contains(authProtectedPaths, path)
+}
+
+// AuthMiddleware requires a session cookie or API token on everything but
+// the UI's static assets and the sign-in endpoints. Requests authenticated
+// by cookie that change state, or open a websocket, must also come from the
+// same origin, so other sites can't act with the browser's session.
+func (s *Server) AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := s.authenticate(r)
+		if info != nil {
+			r = r.WithContext(context.WithValue(r.Context(), authContextKey{}, info))
+		}
+		if !authRequired(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if info == nil {
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		if info.Kind == "session" && needsSameOrigin(r) && !sameOrigin(r) {
+			http.Error(w, "cross-origin request rejected", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// needsSameOrigin reports whether a request changes state or opens a
+// websocket.
+func needsSameOrigin(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+	}
+	return true
+}
+
+// sameOrigin reports whether a browser says a request came from this
+// origin. Unlike http.CrossOriginProtection, it rejects requests that carry
+// neither Sec-Fetch-Site nor Origin.
+func sameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin"
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// startSession creates a browser session and sets its cookie.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request) error {
+	expires := time.Now().Add(sessionLifetime).UTC()
+	session, err := s.db.CreateAuthSession(r.Context(), generated.CreateAuthSessionParams{
+		SessionID: "sess-" + uuid.New().String(),
+		Kind:      "session",
+		Name:      truncateString(r.UserAgent(), 200),
+		ExpiresAt: &expires,
+	})
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    s.auth.signSession(session.SessionID),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+type AuthStatusAPI struct {
+	Enabled       bool `json:"enabled"`
+	SetupRequired bool `json:"setup_required"`
+	Authenticated bool `json:"authenticated"`
+}
+
+type AuthSessionAPI struct {
+	SessionID  string     `json:"session_id"`
+	Kind       string     `json:"kind"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	Current    bool       `json:"current"`
+}
+
+func toAuthSessionAPI(session generated.AuthSession, current *authInfo) AuthSessionAPI {
+	return AuthSessionAPI{
+		SessionID:  session.SessionID,
+		Kind:       session.Kind,
+		Name:       session.Name,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.ExpiresAt,
+		Current:    current != nil && current.SessionID == session.SessionID,
+	}
+}
+
+// handleAuthStatus handles GET /api/auth/status.
+func (s *Server) handleAuthStatus(w http.ResponseWriter, r *http.Request) {
+	status := AuthStatusAPI{Authenticated: true}
+	if s.auth != nil {
+		status = AuthStatusAPI{
+			Enabled:       true,
+			SetupRequired: s.auth.needsSetup(),
+			Authenticated: authFromContext(r.Context()) != nil,
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// requireAuthEnabled writes an error if built-in authentication is off.
+func (s *Server) requireAuthEnabled(w http.ResponseWriter) bool {
+	if s.auth == nil {
+		http.Error(w, "authentication is not enabled", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// handleAuthSetup handles POST /api/auth/setup, which sets the first
+// password given the setup code from the server log.
+func (s *Server) handleAuthSetup(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAuthEnabled(w) {
+		return
+	}
+	var req struct {
+		SetupCode string `json:"setup_code"`
+		Password  string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Password) < minPasswordLength {
+		http.Error(w, fmt.Sprintf("Password must be at least %d characters", minPasswordLength), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	if !s.auth.allowLogin(now) {
+		http.Error(w, "Too many attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	a := s.auth
+	a.mu.Lock()
+	if a.secret != nil {
+		a.mu.Unlock()
+		http.Error(w, "A password is already set", http.StatusConflict)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(req.SetupCode)), []byte(a.setupCode)) != 1 {
+		a.mu.Unlock()
+		a.recordLoginFailure(now)
+		http.Error(w, "Invalid setup code", http.StatusForbidden)
+		return
+	}
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		a.mu.Unlock()
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	if err := s.db.SetAuthConfig(r.Context(), hash, hex.EncodeToString(secret)); err != nil {
+		a.mu.Unlock()
+		http.Error(w, fmt.Sprintf("Failed to save password: %v", err), http.StatusInternalServerError)
+		return
+	}
+	a.secret = secret
+	a.setupCode = ""
+	a.mu.Unlock()
+
+	if err := s.startSession(w, r); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create session: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleAuthLogin handles POST /api/auth/login.
+func (s *Server) handleAuthLogin(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAuthEnabled(w) {
+		return
+	}
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	if !s.auth.allowLogin(now) {
+		http.Error(w, "Too many failed attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+	config, err := s.db.GetAuthConfig(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load auth config: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if config == nil {
+		http.Error(w, "No password is set", http.StatusConflict)
+		return
+	}
+	if !checkPassword(config.PasswordHash, req.Password) {
+		s.auth.recordLoginFailure(now)
+		s.logger.Warn("Failed login attempt", "remote_addr", r.RemoteAddr)
+		http.Error(w, "Incorrect password", http.StatusUnauthorized)
+		return
+	}
+	if err := s.startSession(w, r); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create session: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleAuthLogout handles POST /api/auth/logout, revoking the current
+// session.
+func (s *Server) handleAuthLogout(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAuthEnabled(w) {
+		return
+	}
+	if info := authFromContext(r.Context()); info != nil && info.Kind == "session" {
+		if err := s.db.DeleteAuthSession(r.Context(), info.SessionID); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to end session: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleAuthPassword handles POST /api/auth/password. Changing the password
+// signs out every other browser session; API tokens keep working.
+func (s *Server) handleAuthPassword(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAuthEnabled(w) {
+		return
+	}
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.NewPassword) < minPasswordLength {
+		http.Error(w, fmt.Sprintf("Password must be at least %d characters", minPasswordLength), http.StatusBadRequest)
+		return
+	}
+	config, err := s.db.GetAuthConfig(r.Context())
+	if err != nil || config == nil {
+		http.Error(w, "No password is set", http.StatusConflict)
+		return
+	}
+	if !checkPassword(config.PasswordHash, req.CurrentPassword) {
+		s.auth.recordLoginFailure(time.Now())
+		http.Error(w, "Incorrect password", http.StatusUnauthorized)
+		return
+	}
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	if err := s.db.SetAuthConfig(r.Context(), hash, config.SessionSecret); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save password: %v", err), http.StatusInternalServerError)
+		return
+	}
+	var keep string
+	if info := authFromContext(r.Context()); info != nil {
+		keep = info.SessionID
+	}
+	if err := s.db.DeleteOtherAuthSessions(r.Context(), keep); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to end other sessions: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleAuthSessions handles GET /api/auth/sessions, listing browser
+// sessions and API tokens.
+func (s *Server) handleAuthSessions(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAuthEnabled(w) {
+		return
+	}
+	sessions, err := s.db.ListAuthSessions(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list sessions: %v", err), http.StatusInternalServerError)
+		return
+	}
+	current := authFromContext(r.Context())
+	now := time.Now()
+	apiSessions := []AuthSessionAPI{}
+	for _, session := range sessions {
+		if session.ExpiresAt != nil && session.ExpiresAt.Before(now) {
+			continue
+		}
+		apiSessions = append(apiSessions, toAuthSessionAPI(session, current))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(apiSessions)
+}
+
+// handleAuthSession handles DELETE /api/auth/sessions/<id>, revoking a
+// session or API token.
+func (s *Server) handleAuthSession(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAuthEnabled(w) {
+		return
+	}
+	sessionID := r.PathValue("id")
+	if _, err := s.db.GetAuthSession(r.Context(), sessionID); err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err := s.db.DeleteAuthSession(r.Context(), sessionID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to revoke session: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCreateAuthToken handles POST /api/auth/tokens. The token is only
+// ever returned in this response.
+func (s *Server) handleCreateAuthToken(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAuthEnabled(w) {
+		return
+	}
+	var req struct {
+		Name          string `json:"name"`
+		ExpiresInDays int    `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresInDays < 0 {
+		http.Error(w, "expires_in_days must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	token := apiTokenPrefix + rand.Text()
+	hash := hashToken(token)
+	params := generated.CreateAuthSessionParams{
+		SessionID: "tok-" + uuid.New().String(),
+		Kind:      "token",
+		Name:      truncateString(req.Name, 200),
+		TokenHash: &hash,
+	}
+	if req.ExpiresInDays > 0 {
+		expires := time.Now().AddDate(0, 0, req.ExpiresInDays).UTC()
+		params.ExpiresAt = &expires
+	}
+	session, err := s.db.CreateAuthSession(r.Context(), params)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create token: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		Token   string         `json:"token"`
+		Session AuthSessionAPI `json:"session"`
+	}{token, toAuthSessionAPI(*session, nil)})
+}
+
+// indexAuth returns the sign-in state injected into index.html, and
+// whether the page may include the rest of its init data.
+func (s *Server) indexAuth(r *http.Request) (map[string]any, bool) {
+	if s.auth == nil {
+		return nil, true
+	}
+	if s.authenticate(r) != nil {
+		return map[string]any{"enabled": true}, true
+	}
+	return map[string]any{"enabled": true, "required": true, "setup_required": s.auth.needsSetup()}, false
+}
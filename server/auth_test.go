@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checkPassword(hash, "correct horse") {
+		t.Error("expected the password to match")
+	}
+	if checkPassword(hash, "wrong horse") {
+		t.Error("expected a wrong password not to match")
+	}
+	if checkPassword("not a hash", "correct horse") {
+		t.Error("expected a malformed hash not to match")
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	h := NewTestHarness(t)
+	s := h.server
+	if err := s.EnableAuth(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+	handler := s.AuthMiddleware(mux)
+
+	type request struct {
+		method, path, body string
+		headers            map[string]string
+	}
+	do := func(req request) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(req.method, req.path, strings.NewReader(req.body))
+		for k, v := range req.headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do(request{method: "GET", path: "/api/conversations"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 before signing in, got %d", w.Code)
+	}
+	if w := do(request{method: "POST", path: "/exit"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for /exit before signing in, got %d", w.Code)
+	}
+	w := do(request{method: "GET", path: "/api/auth/status"})
+	var status AuthStatusAPI
+	json.Unmarshal(w.Body.Bytes(), &status)
+	if !status.Enabled || !status.SetupRequired || status.Authenticated {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	// Setting the first password needs the setup code from the log.
+	w = do(request{method: "POST", path: "/api/auth/setup", body: `{"setup_code": "nope", "password": "hunter2hunter2"}`})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a wrong setup code, got %d", w.Code)
+	}
+	w = do(request{method: "POST", path: "/api/auth/setup", body: `{"setup_code": "` + s.auth.setupCode + `", "password": "hunter2hunter2"}`})
+	if w.Code != http.StatusOK {
+		t.Fatalf("setup failed: %d: %s", w.Code, w.Body.String())
+	}
+	cookie := w.Result().Cookies()[0].Name + "=" + w.Result().Cookies()[0].Value
+	w = do(request{method: "POST", path: "/api/auth/setup", body: `{"setup_code": "", "password": "hunter2hunter2"}`})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected setup to be refused once a password is set, got %d", w.Code)
+	}
+
+	if w := do(request{method: "GET", path: "/api/conversations", headers: map[string]string{"Cookie": cookie}}); w.Code != http.StatusOK {
+		t.Fatalf("expected the session cookie to work, got %d", w.Code)
+	}
+	tampered := cookie[:len(cookie)-2] + "xx"
+	if w := do(request{method: "GET", path: "/api/conversations", headers: map[string]string{"Cookie": tampered}}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a tampered cookie to be rejected, got %d", w.Code)
+	}
+
+	// Requests with the cookie that change state must be same-origin.
+	tokenReq := request{method: "POST", path: "/api/auth/tokens", body: `{"name": "scripts"}`, headers: map[string]string{"Cookie": cookie}}
+	if w := do(tokenReq); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without an origin, got %d", w.Code)
+	}
+	tokenReq.headers["Origin"] = "https://evil.example"
+	if w := do(tokenReq); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another origin, got %d", w.Code)
+	}
+	ws := request{method: "GET", path: "/api/exec-ws", headers: map[string]string{"Cookie": cookie, "Upgrade": "websocket", "Origin": "https://evil.example"}}
+	if w := do(ws); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a cross-origin websocket, got %d", w.Code)
+	}
+	tokenReq.headers["Origin"] = "http://example.com"
+	w = do(tokenReq)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("failed to create token: %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Token   string         `json:"token"`
+		Session AuthSessionAPI `json:"session"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	// API tokens don't need an origin.
+	bearer := map[string]string{"Authorization": "Bearer " + created.Token}
+	if w := do(request{method: "POST", path: "/api/conversations/new", body: `{"message": "echo: hi", "model": "predictable"}`, headers: bearer}); w.Code != http.StatusCreated {
+		t.Fatalf("expected the token to work, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = do(request{method: "GET", path: "/api/auth/sessions", headers: bearer})
+	var sessions []AuthSessionAPI
+	json.Unmarshal(w.Body.Bytes(), &sessions)
+	if len(sessions) != 2 {
+		t.Fatalf("expected a session and a token, got %+v", sessions)
+	}
+	for _, session := range sessions {
+		if session.Current != (session.SessionID == created.Session.SessionID) {
+			t.Errorf("unexpected current flag: %+v", session)
+		}
+	}
+
+	if w := do(request{method: "DELETE", path: "/api/auth/sessions/" + created.Session.SessionID, headers: bearer}); w.Code != http.StatusNoContent {
+		t.Fatalf("failed to revoke token: %d", w.Code)
+	}
+	if w := do(request{method: "GET", path: "/api/conversations", headers: bearer}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a revoked token to be rejected, got %d", w.Code)
+	}
+
+	if w := do(request{method: "POST", path: "/api/auth/login", body: `{"password": "wrong"}`}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong password, got %d", w.Code)
+	}
+	if w := do(request{method: "POST", path: "/api/auth/login", body: `{"password": "hunter2hunter2"}`}); w.Code != http.StatusOK || len(w.Result().Cookies()) != 1 {
+		t.Fatalf("login failed: %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestIndexWithoutSignIn(t *testing.T) {
+	h := NewTestHarness(t)
+	if err := h.server.EnableAuth(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	fsys := http.FS(fstest.MapFS{"index.html": {Data: []byte("<html><head></head></html>")}})
+
+	w := httptest.NewRecorder()
+	h.server.serveIndexWithInit(w, httptest.NewRequest("GET", "/", nil), fsys)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the UI to load, got %d", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"setup_required":true`) || strings.Contains(body, "default_cwd") {
+		t.Errorf("expected only sign-in data before signing in: %s", body)
+	}
+}
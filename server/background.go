@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"shelley.exe.dev/claudetool"
+)
+
+// backgroundProcesses returns the processes started in a conversation, or nil
+// if the conversation has no active manager.
+func (s *Server) backgroundProcesses(conversationID string) *claudetool.BackgroundProcesses {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	manager, ok := s.activeConversations[conversationID]
+	if !ok {
+		return nil
+	}
+	return manager.background
+}
+
+// stopBackgroundProcesses stops the processes started in a conversation.
+func (s *Server) stopBackgroundProcesses(conversationID string) {
+	if procs := s.backgroundProcesses(conversationID); procs != nil {
+		procs.StopAll()
+	}
+}
+
+// stopAllBackgroundProcesses stops the processes started in every conversation,
+// so that none outlive the server.
+func (s *Server) stopAllBackgroundProcesses() {
+	s.mu.Lock()
+	var all []*claudetool.BackgroundProcesses
+	for _, manager := range s.activeConversations {
+		all = append(all, manager.background)
+	}
+	s.mu.Unlock()
+	for _, procs := range all {
+		procs.StopAll()
+	}
+}
+
+// handleListProcesses handles GET /conversation/<id>/processes
+func (s *Server) handleListProcesses(w http.ResponseWriter, r *http.Request, conversationID string) {
+	infos := []claudetool.BackgroundProcessInfo{}
+	if procs := s.backgroundProcesses(conversationID); procs != nil {
+		infos = procs.List()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+// getBackgroundProcess looks up a process, writing a 404 if there is none.
+func (s *Server) getBackgroundProcess(w http.ResponseWriter, conversationID, processID string) *claudetool.BackgroundProcess {
+	procs := s.backgroundProcesses(conversationID)
+	if procs == nil {
+		http.Error(w, "Process not found", http.StatusNotFound)
+		return nil
+	}
+	p, err := procs.Get(processID)
+	if err != nil {
+		http.Error(w, "Process not found", http.StatusNotFound)
+		return nil
+	}
+	return p
+}
+
+// handleProcessOutput handles GET /conversation/<id>/processes/<process>/output,
+// returning the end of the process's output as plain text.
+func (s *Server) handleProcessOutput(w http.ResponseWriter, r *http.Request, conversationID, processID string) {
+	lines := 200
+	if v := r.URL.Query().Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid lines", http.StatusBadRequest)
+			return
+		}
+		lines = n
+	}
+	p := s.getBackgroundProcess(w, conversationID, processID)
+	if p == nil {
+		return
+	}
+	out, err := p.Tail(lines)
+	if err != nil {
+		s.logger.Error("Failed to read process output", "conversationID", conversationID, "process", processID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(out))
+}
+
+// handleStopProcess handles POST /conversation/<id>/processes/<process>/stop
+func (s *Server) handleStopProcess(w http.ResponseWriter, r *http.Request, conversationID, processID string) {
+	p := s.getBackgroundProcess(w, conversationID, processID)
+	if p == nil {
+		return
+	}
+	p.Stop()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p.Info())
+}
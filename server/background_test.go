@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+)
+
+func TestBackgroundProcessEndpoints(t *testing.T) {
+	h := NewTestHarness(t)
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/"+h.convID+path, nil)
+		w := httptest.NewRecorder()
+		h.server.conversationMux().ServeHTTP(w, req)
+		return w
+	}
+
+	procs := h.server.backgroundProcesses(h.convID)
+	if procs == nil {
+		t.Fatal("expected the active conversation to have background processes")
+	}
+	p, err := procs.Start("echo started; sleep 300", t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer procs.StopAll()
+
+	w := serve("GET", "/processes")
+	var infos []claudetool.BackgroundProcessInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &infos); err != nil {
+		t.Fatalf("failed to parse processes: %v", err)
+	}
+	if len(infos) != 1 || infos[0].ID != p.ID || !infos[0].Running {
+		t.Fatalf("unexpected processes: %+v", infos)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w = serve("GET", "/processes/"+p.ID+"/output?lines=10")
+		if strings.Contains(w.Body.String(), "started") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the process output, got %d %q", w.Code, w.Body.String())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if w := serve("GET", "/processes/nope/output"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown process, got %d", w.Code)
+	}
+
+	// Idle conversations are kept while their processes run.
+	h.server.mu.Lock()
+	manager := h.server.activeConversations[h.convID]
+	h.server.mu.Unlock()
+	manager.mu.Lock()
+	manager.lastActivity = time.Now().Add(-time.Hour)
+	manager.mu.Unlock()
+	h.server.Cleanup()
+	if h.server.backgroundProcesses(h.convID) == nil {
+		t.Fatal("expected Cleanup to keep a conversation with running processes")
+	}
+
+	w = serve("POST", "/processes/"+p.ID+"/stop")
+	var info claudetool.BackgroundProcessInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("failed to parse stopped process: %v", err)
+	}
+	if info.Running || p.Running() {
+		t.Fatalf("expected the process to be stopped, got %+v", info)
+	}
+
+	// Archiving a conversation stops its processes and its subagents'.
+	p, err = procs.Start("sleep 300", t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subagent, err := h.db.CreateSubagentConversation(t.Context(), "helper", h.convID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subManager, err := h.server.getOrCreateSubagentConversationManager(t.Context(), subagent.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subProc, err := subManager.background.Start("sleep 300", t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subManager.background.StopAll()
+	if w := serve("POST", "/archive"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 archiving, got %d", w.Code)
+	}
+	deadline = time.Now().Add(10 * time.Second)
+	for p.Running() || subProc.Running() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected archiving to stop the processes")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,434 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/server/notifications"
+)
+
+// Budget limits spend. Zero fields are unlimited.
+type Budget struct {
+	MaxDollars float64 `json:"max_dollars,omitempty"`
+	MaxTokens  int64   `json:"max_tokens,omitempty"`
+	MaxTurns   int64   `json:"max_turns,omitempty"`
+}
+
+// IsZero reports whether the budget sets no limits.
+func (b Budget) IsZero() bool {
+	return b.MaxDollars == 0 && b.MaxTokens == 0 && b.MaxTurns == 0
+}
+
+// BudgetConfig holds the default budgets, from the "budgets" key in shelley.json.
+type BudgetConfig struct {
+	// Conversation applies to each conversation unless overridden per conversation.
+	Conversation Budget `json:"conversation"`
+	// Daily applies to all conversations combined, per UTC day.
+	Daily Budget `json:"daily"`
+}
+
+// Spend is how much of a budget has been used.
+type Spend struct {
+	CostUSD float64 `json:"cost_usd"`
+	Tokens  int64   `json:"tokens"`
+	Turns   int64   `json:"turns"`
+}
+
+// Settings keys that override the shelley.json budgets. Values are JSON-encoded Budgets.
+const (
+	settingBudgetConversation = "budget_conversation"
+	settingBudgetDaily        = "budget_daily"
+)
+
+// SetBudgets sets the default budgets.
+func (s *Server) SetBudgets(cfg BudgetConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.budgets = cfg
+}
+
+// defaultBudget returns the budget stored under the settings key, falling back to fallback.
+func (s *Server) defaultBudget(ctx context.Context, key string, fallback Budget) Budget {
+	value, err := s.db.GetSetting(ctx, key)
+	if err != nil || value == "" {
+		return fallback
+	}
+	var b Budget
+	if err := json.Unmarshal([]byte(value), &b); err != nil {
+		s.logger.Warn("Invalid budget setting, using default", "key", key, "value", value)
+		return fallback
+	}
+	return b
+}
+
+// conversationBudget returns the budget for a conversation: its own override
+// if one was set, otherwise the default conversation budget.
+func (s *Server) conversationBudget(ctx context.Context, conversationID string) (Budget, error) {
+	override, err := s.db.GetConversationBudget(ctx, conversationID)
+	if err != nil {
+		return Budget{}, err
+	}
+	if override != nil {
+		return Budget{MaxDollars: override.MaxDollars, MaxTokens: override.MaxTokens, MaxTurns: override.MaxTurns}, nil
+	}
+	s.mu.Lock()
+	fallback := s.budgets.Conversation
+	s.mu.Unlock()
+	return s.defaultBudget(ctx, settingBudgetConversation, fallback), nil
+}
+
+// dailyBudget returns the budget for all conversations combined in one UTC day.
+func (s *Server) dailyBudget(ctx context.Context) Budget {
+	s.mu.Lock()
+	fallback := s.budgets.Daily
+	s.mu.Unlock()
+	return s.defaultBudget(ctx, settingBudgetDaily, fallback)
+}
+
+// dailySpendSince returns the start of the current UTC day in the database's timestamp format.
+func dailySpendSince() string {
+	return time.Now().UTC().Format(time.DateOnly)
+}
+
+// budgetThreshold is the fraction of a budget at which a budget_threshold
+// notification is sent.
+const budgetThreshold = 0.8
+
+// reachedLimit returns a description of the first limit in b that spend has
+// reached the given fraction of, or "" if none has.
+func reachedLimit(b Budget, spend Spend, fraction float64) (limit string, used string, max string, percent int) {
+	switch {
+	case b.MaxDollars > 0 && spend.CostUSD >= b.MaxDollars*fraction:
+		return "dollars", fmt.Sprintf("$%.2f", spend.CostUSD), fmt.Sprintf("$%.2f", b.MaxDollars), int(spend.CostUSD * 100 / b.MaxDollars)
+	case b.MaxTokens > 0 && float64(spend.Tokens) >= float64(b.MaxTokens)*fraction:
+		return "tokens", fmt.Sprintf("%d tokens", spend.Tokens), fmt.Sprintf("%d tokens", b.MaxTokens), int(spend.Tokens * 100 / b.MaxTokens)
+	case b.MaxTurns > 0 && float64(spend.Turns) >= float64(b.MaxTurns)*fraction:
+		return "turns", fmt.Sprintf("%d turns", spend.Turns), fmt.Sprintf("%d turns", b.MaxTurns), int(spend.Turns * 100 / b.MaxTurns)
+	}
+	return "", "", "", 0
+}
+
+// budgetExceeded describes the budget limit a conversation has reached.
+type budgetExceeded struct {
+	scope   string // "conversation" or "daily"
+	owner   string // for the conversation scope, the conversation whose budget it is
+	limit   string // "dollars", "tokens" or "turns"
+	used    string
+	max     string
+	percent int
+}
+
+func (e *budgetExceeded) Error() string {
+	return fmt.Sprintf("%s budget of %s reached (%s used)", e.scope, e.max, e.used)
+}
+
+// budgetOwner returns the conversation whose budget a conversation spends:
+// its own for a top-level conversation, and the top-level one that started it
+// for a subagent.
+func (s *Server) budgetOwner(ctx context.Context, conversationID string) (string, error) {
+	for {
+		conv, err := s.db.GetConversationByID(ctx, conversationID)
+		if err != nil {
+			return "", err
+		}
+		if conv.ParentConversationID == nil {
+			return conversationID, nil
+		}
+		conversationID = *conv.ParentConversationID
+	}
+}
+
+// findExceededBudget returns the conversation or daily budget limit that has
+// been reached, or nil if the conversation may continue.
+func (s *Server) findExceededBudget(ctx context.Context, conversationID string) (*budgetExceeded, error) {
+	return s.findReachedBudget(ctx, conversationID, 1)
+}
+
+// findReachedBudget returns the conversation or daily budget limit that
+// spend has reached the given fraction of, or nil if there is none. A
+// subagent is checked against its top-level conversation's budget, which
+// includes the spend of all of its subagents.
+func (s *Server) findReachedBudget(ctx context.Context, conversationID string, fraction float64) (*budgetExceeded, error) {
+	conversationID, err := s.budgetOwner(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	convBudget, err := s.conversationBudget(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation budget: %w", err)
+	}
+	if !convBudget.IsZero() {
+		row, err := s.db.GetConversationSpend(ctx, conversationID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get conversation spend: %w", err)
+		}
+		spend := Spend{CostUSD: row.CostUsd, Tokens: row.Tokens, Turns: row.Turns}
+		if limit, used, max, percent := reachedLimit(convBudget, spend, fraction); limit != "" {
+			return &budgetExceeded{scope: "conversation", owner: conversationID, limit: limit, used: used, max: max, percent: percent}, nil
+		}
+	}
+
+	dailyBudget := s.dailyBudget(ctx)
+	if !dailyBudget.IsZero() {
+		row, err := s.db.GetSpendSince(ctx, dailySpendSince())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get daily spend: %w", err)
+		}
+		spend := Spend{CostUSD: row.CostUsd, Tokens: row.Tokens, Turns: row.Turns}
+		if limit, used, max, percent := reachedLimit(dailyBudget, spend, fraction); limit != "" {
+			return &budgetExceeded{scope: "daily", limit: limit, used: used, max: max, percent: percent}, nil
+		}
+	}
+	return nil, nil
+}
+
+// checkBudget is the loop.BudgetCheckFunc for a conversation. When a budget
+// has been reached it emits a budget_exceeded notification and returns an
+// error, which pauses the conversation. When a budget is nearly reached, it
+// emits a budget_threshold notification, once per budget.
+func (s *Server) checkBudget(ctx context.Context, conversationID string) error {
+	exceeded, err := s.findExceededBudget(ctx, conversationID)
+	if err != nil {
+		// Don't stop conversations because the budget couldn't be read.
+		s.logger.Error("Failed to check budget", "conversationID", conversationID, "error", err)
+		return nil
+	}
+	if exceeded == nil {
+		s.checkBudgetThreshold(ctx, conversationID)
+		return nil
+	}
+
+	payload := notifications.BudgetExceededPayload{
+		Scope: exceeded.scope,
+		Limit: exceeded.limit,
+		Used:  exceeded.used,
+		Max:   exceeded.max,
+	}
+	if conv, err := s.db.GetConversationByID(ctx, conversationID); err == nil && conv.Slug != nil {
+		payload.ConversationTitle = *conv.Slug
+	}
+	s.emitBudgetEvent(notifications.Event{
+		Type:           notifications.EventBudgetExceeded,
+		ConversationID: conversationID,
+		Timestamp:      time.Now(),
+		Payload:        payload,
+	})
+
+	return exceeded
+}
+
+// checkBudgetThreshold emits a budget_threshold notification when a budget
+// is nearly reached.
+func (s *Server) checkBudgetThreshold(ctx context.Context, conversationID string) {
+	reached, err := s.findReachedBudget(ctx, conversationID, budgetThreshold)
+	if err != nil || reached == nil {
+		return
+	}
+
+	// Notify once per budget: per conversation, or per day for the daily budget.
+	key := reached.scope + ":" + reached.owner + ":" + reached.limit + ":" + reached.max
+	if reached.scope == "daily" {
+		key = reached.scope + ":" + dailySpendSince() + ":" + reached.limit + ":" + reached.max
+	}
+	s.mu.Lock()
+	if s.budgetThresholdsNotified == nil {
+		s.budgetThresholdsNotified = make(map[string]bool)
+	}
+	notified := s.budgetThresholdsNotified[key]
+	s.budgetThresholdsNotified[key] = true
+	s.mu.Unlock()
+	if notified {
+		return
+	}
+
+	payload := notifications.BudgetThresholdPayload{
+		Scope:   reached.scope,
+		Limit:   reached.limit,
+		Used:    reached.used,
+		Max:     reached.max,
+		Percent: reached.percent,
+	}
+	payload.ConversationTitle = s.conversationTitle(ctx, conversationID)
+	s.emitBudgetEvent(notifications.Event{
+		Type:           notifications.EventBudgetThreshold,
+		ConversationID: conversationID,
+		Timestamp:      time.Now(),
+		Payload:        payload,
+	})
+}
+
+// emitBudgetEvent dispatches a budget notification and shows it in every open UI.
+func (s *Server) emitBudgetEvent(event notifications.Event) {
+	s.notifDispatcher.Dispatch(event)
+
+	s.mu.Lock()
+	for _, manager := range s.activeConversations {
+		manager.subpub.Broadcast(StreamResponse{NotificationEvent: &event})
+	}
+	s.mu.Unlock()
+}
+
+// BudgetResponse is the response for /api/conversation/{id}/budget
+type BudgetResponse struct {
+	Conversation      Budget `json:"conversation"`
+	ConversationSpend Spend  `json:"conversation_spend"`
+	// Overridden is true when the conversation has its own budget rather than the default.
+	Overridden bool   `json:"overridden"`
+	Daily      Budget `json:"daily"`
+	DailySpend Spend  `json:"daily_spend"`
+	// Exceeded describes the budget that is currently reached, if any.
+	Exceeded string `json:"exceeded,omitempty"`
+}
+
+// handleGetConversationBudget returns a conversation's budget, the daily budget, and spend against both.
+// For a subagent, the conversation budget is its top-level conversation's.
+func (s *Server) handleGetConversationBudget(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	owner, err := s.budgetOwner(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	resp := BudgetResponse{Daily: s.dailyBudget(ctx)}
+	resp.Conversation, err = s.conversationBudget(ctx, owner)
+	if err == nil {
+		var override *generated.ConversationBudget
+		override, err = s.db.GetConversationBudget(ctx, owner)
+		resp.Overridden = override != nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to get conversation budget", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	convSpend, err := s.db.GetConversationSpend(ctx, owner)
+	if err != nil {
+		s.logger.Error("Failed to get conversation spend", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp.ConversationSpend = Spend{CostUSD: convSpend.CostUsd, Tokens: convSpend.Tokens, Turns: convSpend.Turns}
+
+	dailySpend, err := s.db.GetSpendSince(ctx, dailySpendSince())
+	if err != nil {
+		s.logger.Error("Failed to get daily spend", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp.DailySpend = Spend{CostUSD: dailySpend.CostUsd, Tokens: dailySpend.Tokens, Turns: dailySpend.Turns}
+
+	if exceeded, err := s.findExceededBudget(ctx, conversationID); err == nil && exceeded != nil {
+		resp.Exceeded = exceeded.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleSetConversationBudget sets a conversation's own budget, overriding the default.
+// For a subagent, it sets the budget of its top-level conversation.
+func (s *Server) handleSetConversationBudget(w http.ResponseWriter, r *http.Request, conversationID string) {
+	var req Budget
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.MaxDollars < 0 || req.MaxTokens < 0 || req.MaxTurns < 0 {
+		http.Error(w, "Budget limits must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	owner, err := s.budgetOwner(r.Context(), conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	err = s.db.SetConversationBudget(r.Context(), generated.SetConversationBudgetParams{
+		ConversationID: owner,
+		MaxDollars:     req.MaxDollars,
+		MaxTokens:      req.MaxTokens,
+		MaxTurns:       req.MaxTurns,
+	})
+	if err != nil {
+		s.logger.Error("Failed to set conversation budget", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// handleResumeConversation continues a conversation that paused on a budget.
+func (s *Server) handleResumeConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	latest, err := s.db.GetLatestMessage(ctx, conversationID)
+	if err != nil || !isBudgetPause(latest) {
+		http.Error(w, "Conversation is not paused on a budget", http.StatusConflict)
+		return
+	}
+
+	exceeded, err := s.findExceededBudget(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to check budget", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if exceeded != nil {
+		http.Error(w, fmt.Sprintf("Still over budget: %v", exceeded), http.StatusConflict)
+		return
+	}
+
+	modelID := s.defaultModel
+	if conv, err := s.db.GetConversationByID(ctx, conversationID); err == nil && conv.Model != nil {
+		modelID = *conv.Model
+	}
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		s.logger.Error("Unsupported model requested", "model", modelID, "error", err)
+		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
+		return
+	}
+
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID, r.Header.Get("X-ExeDev-Email"))
+	if err != nil {
+		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := manager.Resume(ctx, llmService, modelID); err != nil {
+		if errors.Is(err, errConversationModelMismatch) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.logger.Error("Failed to resume conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "resumed"})
+}
+
+// isBudgetPause reports whether msg is the error message recorded when a
+// conversation paused on a budget.
+func isBudgetPause(msg *generated.Message) bool {
+	if msg == nil || msg.LlmData == nil {
+		return false
+	}
+	var llmMsg llm.Message
+	if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil {
+		return false
+	}
+	return llmMsg.ErrorType == llm.ErrorTypeBudget
+}
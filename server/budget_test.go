@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+// waitBudgetPause waits until the conversation's latest message is a budget pause.
+func waitBudgetPause(t *testing.T, h *TestHarness) {
+	t.Helper()
+	deadline := time.Now().Add(h.timeout)
+	for time.Now().Before(deadline) {
+		msg, err := h.db.GetLatestMessage(context.Background(), h.convID)
+		if err == nil && isBudgetPause(msg) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for the conversation to pause on its budget")
+}
+
+func TestConversationBudgetPausesAndResumes(t *testing.T) {
+	h := NewTestHarness(t)
+	mux := h.server.conversationMux()
+
+	// The first turn is within the default budget of one turn.
+	h.server.SetBudgets(BudgetConfig{Conversation: Budget{MaxTurns: 1}})
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+
+	// The second turn is not sent to the LLM.
+	requests := len(h.llm.GetRecentRequests())
+	h.Chat("echo: second")
+	waitBudgetPause(t, h)
+	if n := len(h.llm.GetRecentRequests()); n != requests {
+		t.Errorf("expected no LLM request while over budget, got %d new", n-requests)
+	}
+	msg, err := h.db.GetLatestMessage(context.Background(), h.convID)
+	if err != nil {
+		t.Fatalf("failed to get latest message: %v", err)
+	}
+	if msg.Type != string(db.MessageTypeError) {
+		t.Errorf("expected budget pause to be an error message, got %s", msg.Type)
+	}
+
+	// Resuming is refused until the budget is raised.
+	req := httptest.NewRequest("POST", "/"+h.convID+"/resume", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 while over budget, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/"+h.convID+"/budget", strings.NewReader(`{"max_turns": 5}`))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("failed to set budget: %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/"+h.convID+"/budget", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("failed to get budget: %d: %s", w.Code, w.Body.String())
+	}
+	var budget BudgetResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &budget); err != nil {
+		t.Fatalf("failed to parse budget: %v", err)
+	}
+	if !budget.Overridden || budget.Conversation.MaxTurns != 5 || budget.ConversationSpend.Turns != 1 || budget.Exceeded != "" {
+		t.Errorf("unexpected budget response: %+v", budget)
+	}
+
+	// Resuming sends the paused turn without a new user message.
+	req = httptest.NewRequest("POST", "/"+h.convID+"/resume", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 on resume, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := h.WaitResponse(); got != "second" {
+		t.Errorf("expected resumed turn to answer the paused message, got %q", got)
+	}
+	userMsgs, err := h.db.ListMessagesByType(context.Background(), h.convID, db.MessageTypeUser)
+	if err != nil {
+		t.Fatalf("failed to list user messages: %v", err)
+	}
+	if len(userMsgs) != 2 {
+		t.Errorf("expected 2 user messages after resume, got %d", len(userMsgs))
+	}
+}
+
+func TestDailyBudgetFromSettings(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+
+	// The settings override applies to every conversation, not just this one.
+	if err := h.db.SetSetting(ctx, settingBudgetDaily, `{"max_dollars": 0.0001}`); err != nil {
+		t.Fatalf("failed to set daily budget: %v", err)
+	}
+	h.NewConversation("echo: other", "")
+	waitBudgetPause(t, h)
+
+	msg, err := h.db.GetLatestMessage(ctx, h.convID)
+	if err != nil {
+		t.Fatalf("failed to get latest message: %v", err)
+	}
+	var llmMsg llm.Message
+	if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+	if text := llmMsg.Content[0].Text; !strings.Contains(text, "daily budget") {
+		t.Errorf("expected pause message to name the daily budget, got %q", text)
+	}
+}
+
+func TestSubagentSpendsParentBudget(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+	h.server.SetBudgets(BudgetConfig{Conversation: Budget{MaxDollars: 1}})
+
+	parent, err := h.db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subagent, err := h.db.CreateSubagentConversation(ctx, "helper", parent.ConversationID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, err := h.db.CreateSubagentConversation(ctx, "nested", subagent.ConversationID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each subagent spends within the budget, but together they reach it.
+	for _, id := range []string{subagent.ConversationID, nested.ConversationID} {
+		if _, err := h.db.CreateMessage(ctx, db.CreateMessageParams{
+			ConversationID: id,
+			Type:           db.MessageTypeAgent,
+			LLMData:        llm.Message{Role: llm.MessageRoleAssistant},
+			UsageData:      llm.Usage{OutputTokens: 1, CostUSD: 0.5},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range []string{parent.ConversationID, subagent.ConversationID, nested.ConversationID} {
+		exceeded, err := h.server.findExceededBudget(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exceeded == nil || exceeded.scope != "conversation" || exceeded.owner != parent.ConversationID {
+			t.Errorf("%s: expected the parent's budget to be exceeded, got %+v", id, exceeded)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/"+nested.ConversationID+"/budget", nil)
+	w := httptest.NewRecorder()
+	h.server.conversationMux().ServeHTTP(w, req)
+	var budget BudgetResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &budget); err != nil {
+		t.Fatalf("failed to parse budget: %v", err)
+	}
+	if budget.ConversationSpend.CostUSD != 1 || budget.Conversation.MaxDollars != 1 || budget.Exceeded == "" {
+		t.Errorf("expected the subagent to report its parent's budget, got %+v", budget)
+	}
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/gitstate"
+)
+
+// settingCheckpointWorktree, when "true", makes each checkpoint also snapshot
+// the whole git worktree of the conversation's working directory, so that
+// changes made by bash commands can be rewound too. Without it, checkpoints
+// only cover files changed by the patch tool.
+const settingCheckpointWorktree = "checkpoint_worktree"
+
+// CheckpointInfo describes a checkpoint in GET /api/conversation/<id>/checkpoints.
+type CheckpointInfo struct {
+	CheckpointID int64 `json:"checkpoint_id"`
+	// SequenceID is the sequence ID of the first message after the checkpoint,
+	// usually the user message that started the turn.
+	SequenceID int64     `json:"sequence_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Files are the files the patch tool changed after the checkpoint, before the next one.
+	Files []string `json:"files"`
+	// Worktree is set if the checkpoint includes a snapshot of this git worktree.
+	Worktree string `json:"worktree,omitempty"`
+}
+
+// checkpointRef is the hidden git ref that keeps a worktree snapshot alive.
+func checkpointRef(conversationID string, sequenceID int64) string {
+	return fmt.Sprintf("refs/shelley/checkpoints/%s/%d", conversationID, sequenceID)
+}
+
+// createCheckpoint marks the start of a user turn, so that files changed
+// from now on can be restored to their current state. Failures are logged:
+// a missing checkpoint shouldn't keep the user's message from being sent.
+func (cm *ConversationManager) createCheckpoint(ctx context.Context) {
+	cm.mu.Lock()
+	subagent := cm.parentConversationID != ""
+	cm.mu.Unlock()
+	if subagent {
+		// A subagent's edits are saved to the checkpoint of the turn that started it.
+		return
+	}
+	var sequenceID int64
+	err := cm.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		sequenceID, err = q.GetNextSequenceID(ctx, cm.conversationID)
+		return err
+	})
+	if err != nil {
+		cm.logger.Error("Failed to get sequence ID for checkpoint", "error", err)
+		return
+	}
+
+	params := generated.CreateCheckpointParams{
+		ConversationID: cm.conversationID,
+		SequenceID:     sequenceID,
+	}
+	if enabled, _ := cm.db.GetSetting(ctx, settingCheckpointWorktree); enabled == "true" {
+		cm.mu.Lock()
+		cwd := cm.cwd
+		cm.mu.Unlock()
+		worktree, commit, err := gitstate.Snapshot(cwd, checkpointRef(cm.conversationID, sequenceID))
+		if err != nil {
+			cm.logger.Debug("No worktree snapshot for checkpoint", "cwd", cwd, "error", err)
+		} else {
+			params.GitWorktree = &worktree
+			params.GitCommit = &commit
+		}
+	}
+	if _, err := cm.db.CreateCheckpoint(ctx, params); err != nil {
+		cm.logger.Error("Failed to create checkpoint", "error", err)
+	}
+}
+
+// checkpointOwner returns the conversation whose checkpoints save the files
+// cm's tools change: cm's own, or for a subagent, the top-level conversation
+// whose turn started it, so that rewinding that turn undoes the subagent's edits.
+func (cm *ConversationManager) checkpointOwner(ctx context.Context) (string, error) {
+	cm.mu.Lock()
+	owner := cm.parentConversationID
+	cm.mu.Unlock()
+	if owner == "" {
+		return cm.conversationID, nil
+	}
+	for {
+		conv, err := cm.db.GetConversationByID(ctx, owner)
+		if err != nil {
+			return "", err
+		}
+		if conv.ParentConversationID == nil {
+			return owner, nil
+		}
+		owner = *conv.ParentConversationID
+	}
+}
+
+// captureFile saves a file's contents and mode to the latest checkpoint
+// before the patch tool changes it. Only the first change per checkpoint is
+// kept, since that is the state the checkpoint restores.
+func (cm *ConversationManager) captureFile(path string, orig []byte, existed bool) {
+	ctx := context.Background()
+	owner, err := cm.checkpointOwner(ctx)
+	if err != nil {
+		cm.logger.Error("Failed to get conversation for checkpoint", "error", err)
+		return
+	}
+	checkpoint, err := cm.db.GetLatestCheckpoint(ctx, owner)
+	if err != nil {
+		cm.logger.Error("Failed to get checkpoint", "error", err)
+		return
+	}
+	if checkpoint == nil {
+		return
+	}
+	var mode *int64
+	if info, err := os.Stat(path); err == nil {
+		m := int64(info.Mode().Perm())
+		mode = &m
+	}
+	err = cm.db.AddCheckpointFile(ctx, generated.AddCheckpointFileParams{
+		CheckpointID: checkpoint.CheckpointID,
+		Path:         path,
+		Existed:      existed,
+		Content:      orig,
+		Mode:         mode,
+	})
+	if err != nil {
+		cm.logger.Error("Failed to save file to checkpoint", "path", path, "error", err)
+	}
+}
+
+// restoreCheckpoint puts files back the way they were when a checkpoint was
+// taken and returns the paths it restored. The worktree snapshot, if any,
+// is restored first; then every file the patch tool changed since the
+// checkpoint and outside that worktree gets its earliest saved contents and
+// mode back.
+func restoreCheckpoint(ctx context.Context, database *db.DB, checkpoint *generated.Checkpoint) ([]string, error) {
+	var restored []string
+	var worktree string
+	if checkpoint.GitWorktree != nil && checkpoint.GitCommit != nil {
+		worktree = *checkpoint.GitWorktree
+		paths, err := gitstate.RestoreSnapshot(worktree, *checkpoint.GitCommit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore worktree snapshot: %w", err)
+		}
+		for _, path := range paths {
+			restored = append(restored, filepath.Join(worktree, path))
+		}
+	}
+
+	files, err := database.ListCheckpointFilesFrom(ctx, checkpoint.ConversationID, checkpoint.CheckpointID)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, file := range files {
+		if seen[file.Path] || (worktree != "" && isWithin(worktree, file.Path)) {
+			continue
+		}
+		seen[file.Path] = true
+		if !file.Existed {
+			if err := os.Remove(file.Path); err != nil && !os.IsNotExist(err) {
+				return restored, fmt.Errorf("failed to remove %s: %w", file.Path, err)
+			}
+		} else {
+			if err := os.MkdirAll(filepath.Dir(file.Path), 0o700); err != nil {
+				return restored, err
+			}
+			mode := os.FileMode(0o600)
+			if file.Mode != nil {
+				mode = os.FileMode(*file.Mode)
+			}
+			if err := os.WriteFile(file.Path, file.Content, mode); err != nil {
+				return restored, fmt.Errorf("failed to restore %s: %w", file.Path, err)
+			}
+			// WriteFile sets the mode only of files it creates.
+			if file.Mode != nil {
+				if err := os.Chmod(file.Path, mode); err != nil {
+					return restored, fmt.Errorf("failed to restore the mode of %s: %w", file.Path, err)
+				}
+			}
+		}
+		restored = append(restored, file.Path)
+	}
+	slices.Sort(restored)
+	return slices.Compact(restored), nil
+}
+
+// isWithin reports whether path is dir or inside it.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// deleteCheckpointRefs removes the git refs holding a conversation's worktree snapshots.
+func (s *Server) deleteCheckpointRefs(ctx context.Context, conversationID string) {
+	checkpoints, err := s.db.ListCheckpoints(ctx, conversationID)
+	if err != nil {
+		s.logger.Warn("Failed to list checkpoints", "conversationID", conversationID, "error", err)
+		return
+	}
+	for _, checkpoint := range checkpoints {
+		if checkpoint.GitWorktree == nil {
+			continue
+		}
+		if err := gitstate.DeleteSnapshot(*checkpoint.GitWorktree, checkpointRef(conversationID, checkpoint.SequenceID)); err != nil {
+			s.logger.Debug("Failed to delete checkpoint ref", "conversationID", conversationID, "error", err)
+		}
+	}
+}
+
+// handleListCheckpoints handles GET /conversation/<id>/checkpoints
+func (s *Server) handleListCheckpoints(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	checkpoints, err := s.db.ListCheckpoints(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list checkpoints", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	paths, err := s.db.ListCheckpointFilePaths(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list checkpoint files", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	files := make(map[int64][]string)
+	for _, p := range paths {
+		files[p.CheckpointID] = append(files[p.CheckpointID], p.Path)
+	}
+
+	infos := make([]CheckpointInfo, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		info := CheckpointInfo{
+			CheckpointID: checkpoint.CheckpointID,
+			SequenceID:   checkpoint.SequenceID,
+			CreatedAt:    checkpoint.CreatedAt,
+			Files:        files[checkpoint.CheckpointID],
+		}
+		if info.Files == nil {
+			info.Files = []string{}
+		}
+		if checkpoint.GitWorktree != nil {
+			info.Worktree = *checkpoint.GitWorktree
+		}
+		infos = append(infos, info)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+// handleRestoreCheckpoint handles POST /conversation/<id>/checkpoints/<checkpoint>/restore.
+// It rewinds files only; the conversation's messages are left alone.
+func (s *Server) handleRestoreCheckpoint(w http.ResponseWriter, r *http.Request, conversationID, checkpointParam string) {
+	ctx := r.Context()
+	checkpointID, err := strconv.ParseInt(checkpointParam, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid checkpoint ID", http.StatusBadRequest)
+		return
+	}
+	checkpoint, err := s.db.GetCheckpoint(ctx, checkpointID)
+	if err != nil || checkpoint.ConversationID != conversationID {
+		http.Error(w, "Checkpoint not found", http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	manager, ok := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if ok && manager.IsAgentWorking() {
+		http.Error(w, "Agent is working; cancel it before restoring files", http.StatusConflict)
+		return
+	}
+
+	restored, err := restoreCheckpoint(ctx, s.db, checkpoint)
+	if err != nil {
+		s.logger.Error("Failed to restore checkpoint", "conversationID", conversationID, "checkpointID", checkpointID, "error", err)
+		http.Error(w, fmt.Sprintf("Failed to restore checkpoint: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if restored == nil {
+		restored = []string{}
+	}
+	s.logger.Info("Restored checkpoint", "conversationID", conversationID, "checkpointID", checkpointID, "files", len(restored))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "restored",
+		"restored": restored,
+	})
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+)
+
+func listCheckpoints(t *testing.T, h *TestHarness) []CheckpointInfo {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/"+h.convID+"/checkpoints", nil)
+	w := httptest.NewRecorder()
+	h.server.conversationMux().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 listing checkpoints, got %d: %s", w.Code, w.Body.String())
+	}
+	var checkpoints []CheckpointInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &checkpoints); err != nil {
+		t.Fatalf("failed to parse checkpoints: %v", err)
+	}
+	return checkpoints
+}
+
+func restoreCheckpointRequest(t *testing.T, h *TestHarness, checkpointID int64) []string {
+	t.Helper()
+	req := httptest.NewRequest("POST", fmt.Sprintf("/%s/checkpoints/%d/restore", h.convID, checkpointID), nil)
+	w := httptest.NewRecorder()
+	h.server.conversationMux().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 restoring checkpoint, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Restored []string `json:"restored"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse restore response: %v", err)
+	}
+	return resp.Restored
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestCheckpointRestore(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("an example\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	h.NewConversation("echo: hi", dir)
+	h.WaitResponse()
+	h.Chat("patch: " + file)
+	h.WaitResponse()
+	h.Chat("patch: " + file)
+	h.WaitResponse()
+	// Restoring is refused while the agent works, which it may briefly still
+	// be doing after recording its response.
+	waitFor(t, 5*time.Second, func() bool { return !h.server.IsAgentWorking(h.convID) })
+	if got := readFile(t, file); got != "an updated updated example\n" {
+		t.Fatalf("unexpected contents after patches: %q", got)
+	}
+
+	checkpoints := listCheckpoints(t, h)
+	if len(checkpoints) != 3 {
+		t.Fatalf("expected a checkpoint per user turn, got %+v", checkpoints)
+	}
+	if len(checkpoints[0].Files) != 0 || !slices.Equal(checkpoints[1].Files, []string{file}) || !slices.Equal(checkpoints[2].Files, []string{file}) {
+		t.Errorf("unexpected checkpoint files: %+v", checkpoints)
+	}
+	userMsgs, err := h.db.ListMessagesByType(ctx, h.convID, db.MessageTypeUser)
+	if err != nil {
+		t.Fatalf("failed to list user messages: %v", err)
+	}
+	if checkpoints[1].SequenceID != userMsgs[1].SequenceID {
+		t.Errorf("checkpoint sequence %d, want that of the turn's user message %d", checkpoints[1].SequenceID, userMsgs[1].SequenceID)
+	}
+
+	t.Run("restore endpoint", func(t *testing.T) {
+		if restored := restoreCheckpointRequest(t, h, checkpoints[2].CheckpointID); !slices.Equal(restored, []string{file}) {
+			t.Errorf("restored %v", restored)
+		}
+		if got := readFile(t, file); got != "an updated example\n" {
+			t.Errorf("after restoring the last checkpoint got %q", got)
+		}
+		if err := os.Chmod(file, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		restoreCheckpointRequest(t, h, checkpoints[1].CheckpointID)
+		if got := readFile(t, file); got != "an example\n" {
+			t.Errorf("after restoring the second checkpoint got %q", got)
+		}
+		if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0o755 {
+			t.Errorf("expected the file's mode to be restored, got %v, %v", info.Mode(), err)
+		}
+		if restored := restoreCheckpointRequest(t, h, checkpoints[0].CheckpointID); !slices.Equal(restored, []string{file}) {
+			t.Errorf("restored %v", restored)
+		}
+	})
+
+	t.Run("other conversation's checkpoint", func(t *testing.T) {
+		req := httptest.NewRequest("POST", fmt.Sprintf("/%s/checkpoints/%d/restore", "nope", checkpoints[0].CheckpointID), nil)
+		w := httptest.NewRecorder()
+		h.server.conversationMux().ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("fork with restore_files", func(t *testing.T) {
+		if err := os.WriteFile(file, []byte("changed by hand\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		w := fork(t, h, `{"message_id": "`+userMsgs[1].MessageID+`", "message": "echo: edited", "restore_files": true}`)
+		forkedConversationID(t, w)
+		var resp struct {
+			Restored []string `json:"restored"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to parse fork response: %v", err)
+		}
+		if !slices.Equal(resp.Restored, []string{file}) {
+			t.Errorf("restored %v", resp.Restored)
+		}
+		if got := readFile(t, file); got != "an example\n" {
+			t.Errorf("fork did not rewind files, got %q", got)
+		}
+	})
+}
+
+func TestCheckpointSubagentEdits(t *testing.T) {
+	h := NewTestHarness(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("an example\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h.NewConversation("echo: hi", dir)
+	h.WaitResponse()
+	waitFor(t, 5*time.Second, func() bool { return !h.server.IsAgentWorking(h.convID) })
+
+	subagent, err := h.db.CreateSubagentConversation(t.Context(), "helper", h.convID, &dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager, err := h.server.getOrCreateSubagentConversationManager(t.Context(), subagent.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.createCheckpoint(t.Context())
+	manager.captureFile(file, []byte("an example\n"), true)
+	if err := os.WriteFile(file, []byte("edited by a subagent\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The subagent's edit is saved to its parent's turn, so rewinding that turn undoes it.
+	checkpoints := listCheckpoints(t, h)
+	if len(checkpoints) != 1 || !slices.Equal(checkpoints[0].Files, []string{file}) {
+		t.Fatalf("expected the parent's checkpoint to have the subagent's edit, got %+v", checkpoints)
+	}
+	restoreCheckpointRequest(t, h, checkpoints[0].CheckpointID)
+	if got := readFile(t, file); got != "an example\n" {
+		t.Errorf("after restoring got %q", got)
+	}
+	if own, err := h.db.ListCheckpoints(t.Context(), subagent.ConversationID); err != nil || len(own) != 0 {
+		t.Errorf("expected the subagent to have no checkpoints of its own, got %+v, %v", own, err)
+	}
+}
+
+func TestCheckpointWorktreeSnapshot(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	h := NewTestHarness(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	if err := h.db.SetSetting(ctx, settingCheckpointWorktree, "true"); err != nil {
+		t.Fatal(err)
+	}
+
+	h.NewConversation("echo: hi", dir)
+	h.WaitResponse()
+	waitFor(t, 5*time.Second, func() bool { return !h.server.IsAgentWorking(h.convID) })
+	checkpoints := listCheckpoints(t, h)
+	if len(checkpoints) != 1 || checkpoints[0].Worktree == "" {
+		t.Fatalf("expected a checkpoint with a worktree snapshot, got %+v", checkpoints)
+	}
+
+	// Files changed outside the patch tool, as by bash, are restored too.
+	created := filepath.Join(dir, "created.txt")
+	if err := os.WriteFile(created, []byte("new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	restored := restoreCheckpointRequest(t, h, checkpoints[0].CheckpointID)
+	if len(restored) != 1 || filepath.Base(restored[0]) != "created.txt" {
+		t.Errorf("restored %v", restored)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("expected created.txt to be removed, got %v", err)
+	}
+}
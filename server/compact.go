@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// defaultAutoCompactThreshold is the fraction of the model's context window
+// at which a conversation is compacted, unless overridden by the
+// auto_compact_threshold setting.
+const defaultAutoCompactThreshold = 0.8
+
+// autoCompactThreshold returns the configured compaction threshold as a
+// fraction of the context window. A value of 0 disables auto compaction.
+func (s *Server) autoCompactThreshold(ctx context.Context) float64 {
+	value, err := s.db.GetSetting(ctx, "auto_compact_threshold")
+	if err != nil || value == "" {
+		return defaultAutoCompactThreshold
+	}
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil || threshold < 0 || threshold >= 1 {
+		s.logger.Warn("Invalid auto_compact_threshold setting, using default", "value", value)
+		return defaultAutoCompactThreshold
+	}
+	return threshold
+}
+
+// compactionDue reports whether a conversation using svc that has used this
+// much of its context window should be compacted.
+func (s *Server) compactionDue(ctx context.Context, svc llm.Service, used uint64) bool {
+	if svc == nil || svc.TokenContextWindow() <= 0 {
+		return false
+	}
+	threshold := s.autoCompactThreshold(ctx)
+	if threshold == 0 {
+		return false
+	}
+	return used >= uint64(threshold*float64(svc.TokenContextWindow()))
+}
+
+// maybeCompactConversation compacts a conversation once its context usage, as
+// reported by endMsg, crosses the auto compaction threshold. It is called when
+// the agent ends its turn.
+//
+// Compaction distills the current context into a summary user message, marks
+// all earlier user/agent/tool messages as excluded from context, and resets the
+// conversation loop so the next turn starts from the summary. Nothing is deleted.
+func (s *Server) maybeCompactConversation(ctx context.Context, conversationID string, endMsg *generated.Message) {
+	s.mu.Lock()
+	manager, ok := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	svc := manager.Service()
+	used := calculateContextWindowSizeFromMsg(endMsg)
+	if !s.compactionDue(ctx, svc, used) {
+		return
+	}
+
+	manager.compactMu.Lock()
+	defer manager.compactMu.Unlock()
+
+	// A new turn may have started before we got the lock; compact at its end instead.
+	if manager.IsAgentWorking() {
+		return
+	}
+
+	logger := s.logger.With("conversationID", conversationID)
+	logger.Info("Compacting conversation", "context_used", used, "context_window", svc.TokenContextWindow())
+	err := s.compactConversation(ctx, conversationID, svc, false)
+	s.notifyCompaction(conversationID, err)
+	if err != nil {
+		logger.Error("Failed to compact conversation", "error", err)
+		return
+	}
+	manager.resetLoop()
+}
+
+// compactDuringTurn is the loop's CompactFunc. A turn with many tool calls can
+// fill the context window before it ends, so the loop checks before each
+// request that continues the turn; the turn then goes on from the summary.
+func (s *Server) compactDuringTurn(ctx context.Context, manager *ConversationManager, used uint64) ([]llm.Message, error) {
+	svc := manager.Service()
+	if !s.compactionDue(ctx, svc, used) {
+		return nil, nil
+	}
+
+	manager.compactMu.Lock()
+	defer manager.compactMu.Unlock()
+
+	s.logger.Info("Compacting conversation during turn", "conversationID", manager.conversationID, "context_used", used, "context_window", svc.TokenContextWindow())
+	err := s.compactConversation(ctx, manager.conversationID, svc, true)
+	s.notifyCompaction(manager.conversationID, err)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.db.ListMessagesForContext(ctx, manager.conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	history, _ := manager.partitionMessages(messages)
+	return history, nil
+}
+
+// compactConversation replaces the conversation's current context with a distilled summary.
+// If duringTurn, the summary asks the agent to carry on with the turn.
+func (s *Server) compactConversation(ctx context.Context, conversationID string, svc llm.Service, duringTurn bool) error {
+	messages, err := s.db.ListMessagesForContext(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation: %w", err)
+	}
+	slugText := "unknown"
+	if conversation.Slug != nil {
+		slugText = *conversation.Slug
+	}
+
+	// The status message marks where compaction happened; everything before it is compacted.
+	statusMsg, err := s.db.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID:      conversationID,
+		Type:                db.MessageTypeSystem,
+		UserData:            map[string]string{"compaction_status": "in_progress"},
+		ExcludedFromContext: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create status message: %w", err)
+	}
+	go s.notifySubscribersNewMessage(ctx, conversationID, statusMsg)
+
+	summary, err := distillTranscript(ctx, svc, buildDistillTranscript(slugText, messages))
+	if err != nil {
+		s.updateCompactionStatus(ctx, conversationID, statusMsg.MessageID, "error")
+		return fmt.Errorf("distillation failed: %w", err)
+	}
+	if duringTurn {
+		summary += "\n\nThe conversation was compacted while you were working on the latest request. Continue where you left off."
+	}
+
+	summaryMessage := llm.Message{
+		Role: llm.MessageRoleUser,
+		Content: []llm.Content{
+			{Type: llm.ContentTypeText, Text: summary},
+		},
+	}
+	if err := s.recordMessage(ctx, conversationID, summaryMessage, llm.Usage{}, map[string]string{"compacted": "true"}); err != nil {
+		s.updateCompactionStatus(ctx, conversationID, statusMsg.MessageID, "error")
+		return fmt.Errorf("failed to record summary message: %w", err)
+	}
+
+	if err := s.db.ExcludeMessagesFromContextBefore(ctx, conversationID, statusMsg.SequenceID); err != nil {
+		s.updateCompactionStatus(ctx, conversationID, statusMsg.MessageID, "error")
+		return fmt.Errorf("failed to exclude compacted messages: %w", err)
+	}
+
+	s.updateCompactionStatus(ctx, conversationID, statusMsg.MessageID, "complete")
+	return nil
+}
+
+// updateCompactionStatus updates the compaction status message and broadcasts the change.
+func (s *Server) updateCompactionStatus(ctx context.Context, conversationID, messageID, status string) {
+	data, err := json.Marshal(map[string]string{"compaction_status": status})
+	if err != nil {
+		s.logger.Error("Failed to marshal compaction status", "error", err)
+		return
+	}
+	dataStr := string(data)
+	if err := s.db.UpdateMessageUserData(ctx, messageID, &dataStr); err != nil {
+		s.logger.Error("Failed to update compaction status", "messageID", messageID, "error", err)
+		return
+	}
+	// Broadcast rather than Publish: the message's sequence_id hasn't changed.
+	updatedMsg, err := s.db.GetMessageByID(ctx, messageID)
+	if err == nil {
+		go s.broadcastMessageUpdate(ctx, conversationID, updatedMsg)
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// waitCompactionStatus waits until the conversation has a compaction status
+// message with the given status, and returns the number of such messages.
+func waitCompactionStatus(t *testing.T, h *TestHarness, status string, count int) {
+	t.Helper()
+	deadline := time.Now().Add(h.timeout)
+	for time.Now().Before(deadline) {
+		msgs, err := h.db.ListMessagesByType(context.Background(), h.convID, db.MessageTypeSystem)
+		if err != nil {
+			t.Fatalf("failed to list system messages: %v", err)
+		}
+		n := 0
+		for _, msg := range msgs {
+			if msg.UserData == nil {
+				continue
+			}
+			var ud map[string]string
+			if err := json.Unmarshal([]byte(*msg.UserData), &ud); err == nil && ud["compaction_status"] == status {
+				n++
+			}
+		}
+		if n >= count {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d compaction messages with status %q", count, status)
+}
+
+func TestAutoCompaction(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+
+	// Any real usage crosses this threshold, so every turn ends with a compaction.
+	if err := h.db.SetSetting(ctx, "auto_compact_threshold", "0.000001"); err != nil {
+		t.Fatalf("failed to set threshold: %v", err)
+	}
+
+	h.NewConversation("echo: first message", "")
+	h.WaitResponse()
+	waitCompactionStatus(t, h, "complete", 1)
+
+	// The original messages are kept but excluded; the context is the system prompt plus the summary.
+	all, err := h.db.ListMessages(ctx, h.convID)
+	if err != nil {
+		t.Fatalf("failed to list messages: %v", err)
+	}
+	contextMsgs, err := h.db.ListMessagesForContext(ctx, h.convID)
+	if err != nil {
+		t.Fatalf("failed to list context messages: %v", err)
+	}
+	var excluded int
+	for _, msg := range all {
+		if msg.ExcludedFromContext && (msg.Type == string(db.MessageTypeUser) || msg.Type == string(db.MessageTypeAgent)) {
+			excluded++
+		}
+	}
+	if excluded < 2 {
+		t.Errorf("expected the first exchange to be excluded from context, got %d excluded messages", excluded)
+	}
+	var summary *generated.Message
+	for i, msg := range contextMsgs {
+		switch msg.Type {
+		case string(db.MessageTypeSystem):
+		case string(db.MessageTypeUser):
+			summary = &contextMsgs[i]
+		default:
+			t.Errorf("unexpected %s message left in context", msg.Type)
+		}
+	}
+	if summary == nil || summary.UserData == nil {
+		t.Fatal("expected a summary user message in context")
+	}
+	var ud map[string]string
+	if err := json.Unmarshal([]byte(*summary.UserData), &ud); err != nil || ud["compacted"] != "true" {
+		t.Errorf("expected summary to be marked compacted, got user_data %v", ud)
+	}
+
+	// The next turn starts from the summary rather than the original history.
+	h.Chat("echo: second message")
+	h.WaitResponse()
+	var req *llm.Request
+	for _, r := range h.llm.GetRecentRequests() {
+		msgs := r.Messages
+		if len(msgs) > 0 && len(msgs[len(msgs)-1].Content) > 0 && msgs[len(msgs)-1].Content[0].Text == "echo: second message" {
+			req = r
+		}
+	}
+	if req == nil {
+		t.Fatal("did not find the LLM request for the second message")
+	}
+	for _, msg := range req.Messages {
+		for _, c := range msg.Content {
+			if c.Text == "echo: first message" {
+				t.Error("compacted message was sent to the LLM")
+			}
+		}
+	}
+}
+
+func TestAutoCompactionDisabled(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+
+	if err := h.db.SetSetting(ctx, "auto_compact_threshold", "0"); err != nil {
+		t.Fatalf("failed to set threshold: %v", err)
+	}
+
+	h.NewConversation("echo: hello", "")
+	h.WaitResponse()
+	// Give a (wrongly) triggered compaction time to start.
+	time.Sleep(200 * time.Millisecond)
+
+	msgs, err := h.db.ListMessagesForContext(ctx, h.convID)
+	if err != nil {
+		t.Fatalf("failed to list context messages: %v", err)
+	}
+	for _, msg := range msgs {
+		if msg.Type == string(db.MessageTypeUser) {
+			return
+		}
+	}
+	t.Error("expected the user message to remain in context when compaction is disabled")
+}
+
+func TestCompactionDuringTurn(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+
+	if err := h.db.SetSetting(ctx, "auto_compact_threshold", "0.000001"); err != nil {
+		t.Fatalf("failed to set threshold: %v", err)
+	}
+
+	// The tool call's response already crosses the threshold, so the request
+	// with its result is sent from a summary instead.
+	h.NewConversation("bash: echo compacted", "")
+	h.WaitResponse()
+
+	var req *llm.Request
+	for _, r := range h.llm.GetRecentRequests() {
+		msgs := r.Messages
+		if len(msgs) > 0 && len(msgs[0].Content) > 0 && strings.HasSuffix(msgs[0].Content[0].Text, "Continue where you left off.") {
+			req = r
+			break
+		}
+	}
+	if req == nil {
+		t.Fatal("did not find an LLM request continuing the turn from a summary")
+	}
+	for _, msg := range req.Messages {
+		for _, c := range msg.Content {
+			if c.Type == llm.ContentTypeToolUse || c.Text == "bash: echo compacted" {
+				t.Errorf("compacted content was sent to the LLM: %+v", c)
+			}
+		}
+	}
+}
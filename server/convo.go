@@ -9,6 +9,9 @@ import (
 	"time"
 
 	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/claudetool/mcp"
+	"shelley.exe.dev/claudetool/permission"
+	"shelley.exe.dev/claudetool/sandbox"
 	"shelley.exe.dev/db"
 	"shelley.exe.dev/db/generated"
 	"shelley.exe.dev/gitstate"
@@ -30,25 +33,64 @@ type ConversationManager struct {
 	mu             sync.Mutex
 	lastActivity   time.Time
 	modelID        string
+	service        llm.Service // LLM service used by the current loop
 	recordMessage  loop.MessageRecordFunc
 	logger         *slog.Logger
 	toolSetConfig  claudetool.ToolSetConfig
 	toolSet        *claudetool.ToolSet // created per-conversation when loop starts
 
 	subpub *subpub.SubPub[StreamResponse]
+	stream streamBuffer
 
 	hydrated              bool
 	hasConversationEvents bool
 	cwd                   string // working directory for tools
 	userEmail             string // exe.dev auth email, from X-ExeDev-Email header
+	// thinkingLevel overrides the model's thinking level, if set.
+	thinkingLevel *llm.ThinkingLevel
 
 	// agentWorking tracks whether the agent is currently working.
 	// This is explicitly managed and broadcast to subscribers when it changes.
 	agentWorking bool
 
+	// compactMu is held while the conversation history is being compacted,
+	// so that new user messages wait for the compacted history.
+	compactMu sync.Mutex
+
 	// onStateChange is called when the conversation state changes.
 	// This allows the server to broadcast state changes to all subscribers.
 	onStateChange func(state ConversationState)
+
+	// checkBudget is consulted by the loop before each LLM request.
+	checkBudget loop.BudgetCheckFunc
+	// compact is consulted by the loop before each LLM request that continues a turn.
+	compact loop.CompactFunc
+
+	// fallbacks returns the models the loop may switch to when requests to modelID keep failing.
+	fallbacks func(modelID string) []loop.Fallback
+
+	// permissionPolicy returns the policy for tool calls made in workingDir.
+	// If nil, all tool calls are allowed.
+	permissionPolicy func(ctx context.Context, workingDir string) (permission.Policy, error)
+	// sandboxExecutor returns the executor for the conversation's commands.
+	// If nil, commands run directly on the host.
+	sandboxExecutor func(ctx context.Context) sandbox.Executor
+	// mcpServers returns the MCP servers the conversation's tools connect to
+	// when its loop starts. If nil, there are none.
+	mcpServers func(ctx context.Context) []mcp.ServerConfig
+	// onApprovalRequest, if set, is called when a tool call starts waiting
+	// for the user's approval.
+	onApprovalRequest func(ApprovalRequest)
+	// approvals are the tool calls waiting for the user to allow or deny them.
+	approvals []*pendingApproval
+	// rememberedDecisions maps permission.Call keys to whether the user
+	// allowed them, for decisions the user asked to remember.
+	rememberedDecisions  map[string]bool
+	parentConversationID string
+
+	// background holds processes started by the background tool. It outlives
+	// loops, so that restarting one (e.g. to compact history) keeps them running.
+	background *claudetool.BackgroundProcesses
 }
 
 // NewConversationManager constructs a manager with dependencies but defers hydration until needed.
@@ -68,6 +110,7 @@ func NewConversationManager(conversationID string, database *db.DB, baseLogger *
 		toolSetConfig:  toolSetConfig,
 		subpub:         subpub.New[StreamResponse](),
 		onStateChange:  onStateChange,
+		background:     &claudetool.BackgroundProcesses{ConversationID: conversationID},
 	}
 }
 
@@ -80,20 +123,46 @@ func (cm *ConversationManager) SetAgentWorking(working bool) {
 	}
 	cm.agentWorking = working
 	onStateChange := cm.onStateChange
-	convID := cm.conversationID
-	modelID := cm.modelID
+	state := cm.stateLocked()
 	cm.mu.Unlock()
 
 	cm.logger.Debug("agent working state changed", "working", working)
 	if onStateChange != nil {
-		onStateChange(ConversationState{
-			ConversationID: convID,
-			Working:        working,
-			Model:          modelID,
-		})
+		onStateChange(state)
 	}
 }
 
+// State returns the conversation's current state.
+func (cm *ConversationManager) State() ConversationState {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.stateLocked()
+}
+
+func (cm *ConversationManager) stateLocked() ConversationState {
+	return ConversationState{
+		ConversationID:   cm.conversationID,
+		Working:          cm.agentWorking,
+		Model:            cm.modelID,
+		PendingApprovals: cm.pendingApprovalsLocked(),
+	}
+}
+
+// publishState sends the current state to subscribers, for changes other than to agentWorking.
+func (cm *ConversationManager) publishState() {
+	cm.mu.Lock()
+	onStateChange := cm.onStateChange
+	state := cm.stateLocked()
+	cm.mu.Unlock()
+	// The server treats a state that isn't working as the agent finishing,
+	// so only this conversation's subscribers hear about idle states.
+	if onStateChange != nil && state.Working {
+		onStateChange(state)
+		return
+	}
+	cm.subpub.Broadcast(StreamResponse{ConversationState: &state})
+}
+
 // IsAgentWorking returns the current agent working state.
 func (cm *ConversationManager) IsAgentWorking() bool {
 	cm.mu.Lock()
@@ -133,6 +202,9 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 		cwd = *conversation.Cwd
 	}
 	cm.cwd = cwd
+	if conversation.ParentConversationID != nil {
+		cm.parentConversationID = *conversation.ParentConversationID
+	}
 
 	// Load model from conversation if available
 	var modelID string
@@ -140,6 +212,16 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 		modelID = *conversation.Model
 	}
 
+	var thinkingLevel *llm.ThinkingLevel
+	if conversation.ThinkingLevel != nil {
+		level, err := llm.ParseThinkingLevel(*conversation.ThinkingLevel)
+		if err != nil {
+			cm.logger.Warn("Ignoring conversation thinking level", "error", err)
+		} else {
+			thinkingLevel = &level
+		}
+	}
+
 	// Generate system prompt if missing:
 	// - For user-initiated conversations: full system prompt
 	// - For subagent conversations (has parent): minimal subagent prompt
@@ -172,6 +254,7 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 	cm.lastActivity = time.Now()
 	cm.hydrated = true
 	cm.modelID = modelID
+	cm.thinkingLevel = thinkingLevel
 	cm.mu.Unlock()
 
 	if modelID != "" {
@@ -184,11 +267,14 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 // AcceptUserMessage enqueues a user message, ensuring the loop is ready first.
 // The message is recorded to the database immediately so it appears in the UI,
 // even if the loop is busy processing a previous request.
-func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service llm.Service, modelID string, message llm.Message) (bool, error) {
+func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service llm.Service, modelID string, message llm.Message, thinking *thinkingLevelUpdate) (bool, error) {
 	if service == nil {
 		return false, fmt.Errorf("llm service is required")
 	}
 
+	cm.compactMu.Lock()
+	defer cm.compactMu.Unlock()
+
 	if err := cm.Hydrate(ctx); err != nil {
 		return false, err
 	}
@@ -197,6 +283,13 @@ func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service ll
 		return false, err
 	}
 
+	// The message is accepted, so its thinking level sticks from it on.
+	if thinking != nil {
+		if err := cm.SetThinkingLevel(ctx, thinking.Level); err != nil {
+			return false, err
+		}
+	}
+
 	cm.mu.Lock()
 	isFirst := !cm.hasConversationEvents
 	cm.hasConversationEvents = true
@@ -209,6 +302,9 @@ func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service ll
 		return false, fmt.Errorf("conversation loop not initialized")
 	}
 
+	// Mark where this turn starts, so that files the agent changes during it can be rewound.
+	cm.createCheckpoint(ctx)
+
 	// Record the user message to the database immediately so it appears in the UI,
 	// even if the loop is busy processing a previous request
 	if recordMessage != nil {
@@ -226,6 +322,60 @@ func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service ll
 	return isFirst, nil
 }
 
+// Resume continues a turn that paused on a spending budget, sending the
+// current history to the LLM again without a new user message.
+func (cm *ConversationManager) Resume(ctx context.Context, service llm.Service, modelID string) error {
+	if service == nil {
+		return fmt.Errorf("llm service is required")
+	}
+
+	cm.compactMu.Lock()
+	defer cm.compactMu.Unlock()
+
+	if err := cm.Hydrate(ctx); err != nil {
+		return err
+	}
+
+	if err := cm.ensureLoop(service, modelID); err != nil {
+		return err
+	}
+
+	cm.mu.Lock()
+	loopInstance := cm.loop
+	cm.lastActivity = time.Now()
+	cm.mu.Unlock()
+
+	if loopInstance == nil {
+		return fmt.Errorf("conversation loop not initialized")
+	}
+
+	loopInstance.Resume()
+	cm.SetAgentWorking(true)
+	return nil
+}
+
+// SetThinkingLevel persists the conversation's thinking level and applies it to
+// the LLM requests that follow. A nil level uses the model's default.
+func (cm *ConversationManager) SetThinkingLevel(ctx context.Context, level *llm.ThinkingLevel) error {
+	name := ""
+	if level != nil {
+		name = level.Name()
+	}
+	if err := cm.db.SetConversationThinkingLevel(ctx, cm.conversationID, name); err != nil {
+		return fmt.Errorf("failed to set thinking level: %w", err)
+	}
+
+	cm.mu.Lock()
+	cm.thinkingLevel = level
+	loopInstance := cm.loop
+	cm.mu.Unlock()
+
+	if loopInstance != nil {
+		loopInstance.SetThinkingLevel(level)
+	}
+	return nil
+}
+
 // Touch updates last activity timestamp.
 func (cm *ConversationManager) Touch() {
 	cm.mu.Lock()
@@ -409,6 +559,10 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	toolSetConfig := cm.toolSetConfig
 	conversationID := cm.conversationID
 	db := cm.db
+	checkBudget := cm.checkBudget
+	compact := cm.compact
+	fallbacksFor := cm.fallbacks
+	thinkingLevel := cm.thinkingLevel
 	cm.mu.Unlock()
 
 	// Load conversation history fresh from the database. This is the canonical
@@ -432,6 +586,18 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	toolSetConfig.ModelID = modelID
 	toolSetConfig.ConversationID = conversationID
 	toolSetConfig.ParentConversationID = conversationID // For subagent tool
+	toolSetConfig.BeforeFileWrite = cm.captureFile
+	toolSetConfig.CheckToolCall = cm.checkToolCall
+	toolSetConfig.Background = cm.background
+	if sandboxExecutor := cm.sandboxExecutor; sandboxExecutor != nil {
+		// Look the executor up for each command, so a change of mode applies right away.
+		toolSetConfig.Sandbox = sandbox.Dynamic(func() sandbox.Executor {
+			return sandboxExecutor(context.Background())
+		})
+	}
+	if cm.mcpServers != nil {
+		toolSetConfig.MCPServers = cm.mcpServers(context.Background())
+	}
 	toolSetConfig.OnWorkingDirChange = func(newDir string) {
 		// Persist working directory change to database
 		if err := db.UpdateConversationCwd(context.Background(), conversationID, newDir); err != nil {
@@ -465,18 +631,46 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	processCtx, cancel := context.WithTimeout(baseCtx, 12*time.Hour)
 	toolSet := claudetool.NewToolSet(processCtx, toolSetConfig)
 
+	var fallbacks []loop.Fallback
+	if fallbacksFor != nil {
+		fallbacks = fallbacksFor(modelID)
+	}
+
+	// A recorded response ends the one being streamed.
+	recordResponse := func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+		if message.Role == llm.MessageRoleAssistant {
+			cm.stream.finish()
+		}
+		return recordMessage(ctx, message, usage)
+	}
+
 	loopInstance := loop.NewLoop(loop.Config{
 		LLM:           service,
+		ModelID:       modelID,
+		Fallbacks:     fallbacks,
 		History:       history,
 		Tools:         toolSet.Tools(),
-		RecordMessage: recordMessage,
+		RecordMessage: recordResponse,
 		Logger:        logger,
 		System:        system,
 		WorkingDir:    cwd,
 		GetWorkingDir: toolSet.WorkingDir().Get,
+		CheckBudget:   checkBudget,
+		ThinkingLevel: thinkingLevel,
+		Compact:       compact,
 		OnGitStateChange: func(ctx context.Context, state *gitstate.GitState) {
 			cm.recordGitStateChange(ctx, state)
 		},
+		OnStreamDelta: func(ctx context.Context, delta llm.StreamDelta) {
+			// TryBroadcast drops deltas for slow subscribers rather than
+			// disconnecting them; subscribe fills the gaps from cm.stream.
+			d := toAPIStreamDelta(delta)
+			cm.stream.add(d)
+			cm.subpub.TryBroadcast(StreamResponse{StreamDelta: d})
+		},
+		OnToolProgress: func(ctx context.Context, toolUseID, output string) {
+			cm.subpub.TryBroadcast(StreamResponse{ToolProgress: &ToolProgress{ToolUseID: toolUseID, Output: output}})
+		},
 	})
 
 	cm.mu.Lock()
@@ -496,6 +690,7 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	cm.loopCancel = cancel
 	cm.loopCtx = processCtx
 	cm.modelID = modelID
+	cm.service = service
 	cm.toolSet = toolSet
 	cm.mu.Unlock()
 
@@ -527,6 +722,7 @@ func (cm *ConversationManager) stopLoop() {
 	cm.loopCtx = nil
 	cm.loop = nil
 	cm.modelID = ""
+	cm.service = nil
 	cm.toolSet = nil
 	cm.mu.Unlock()
 
@@ -538,6 +734,22 @@ func (cm *ConversationManager) stopLoop() {
 	}
 }
 
+// resetLoop stops the current loop so that the next user message starts a new
+// one with history reloaded from the database.
+func (cm *ConversationManager) resetLoop() {
+	cm.stopLoop()
+	cm.mu.Lock()
+	cm.hydrated = false
+	cm.mu.Unlock()
+}
+
+// Service returns the LLM service used by the current loop, or nil if there is no loop.
+func (cm *ConversationManager) Service() llm.Service {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.service
+}
+
 // CancelConversation cancels the current conversation loop and records a cancelled tool result if a tool was in progress
 func (cm *ConversationManager) CancelConversation(ctx context.Context) error {
 	cm.mu.Lock()
@@ -669,6 +881,7 @@ func (cm *ConversationManager) CancelConversation(ctx context.Context) error {
 	cm.loopCtx = nil
 	cm.loop = nil
 	cm.modelID = ""
+	cm.service = nil
 	// Reset hydrated so that the next AcceptUserMessage will reload history from the database
 	cm.hydrated = false
 	cm.mu.Unlock()
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// CostsResponse is the response for /api/costs
+type CostsResponse struct {
+	Since         string                              `json:"since"` // YYYY-MM-DD, UTC
+	TotalCostUSD  float64                             `json:"total_cost_usd"`
+	Conversations []generated.GetConversationCostsRow `json:"conversations"`
+	Days          []generated.GetDailyCostsRow        `json:"days"`
+}
+
+// handleCosts returns per-conversation and per-day cost rollups.
+// Query parameters:
+//   - days: how many days back to include, counting today (default 30)
+//   - limit: maximum number of conversations to return (default 100)
+func (s *Server) handleCosts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+
+	days := 30
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		d, err := strconv.Atoi(daysStr)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid days parameter", http.StatusBadRequest)
+			return
+		}
+		days = d
+	}
+	limit := int64(100)
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || l <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	// Timestamps in the database are UTC (CURRENT_TIMESTAMP).
+	since := time.Now().UTC().AddDate(0, 0, -(days - 1)).Format(time.DateOnly)
+
+	conversations, err := s.db.GetConversationCosts(ctx, since, limit)
+	if err != nil {
+		s.logger.Error("Failed to get conversation costs", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	daily, err := s.db.GetDailyCosts(ctx, since)
+	if err != nil {
+		s.logger.Error("Failed to get daily costs", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := CostsResponse{
+		Since:         since,
+		Conversations: conversations,
+		Days:          daily,
+	}
+	for _, d := range daily {
+		resp.TotalCostUSD += d.CostUsd
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getCosts(t *testing.T, s *Server, query string) CostsResponse {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/api/costs"+query, nil)
+	w := httptest.NewRecorder()
+	s.handleCosts(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp CostsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	return resp
+}
+
+func TestCostsEndpoint(t *testing.T) {
+	h := NewTestHarness(t)
+
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+	first := h.convID
+	h.NewConversation("echo: second", "")
+	h.WaitResponse()
+	h.Chat("echo: again")
+	h.WaitResponse()
+	second := h.convID
+
+	resp := getCosts(t, h.server, "")
+	if resp.Since != time.Now().UTC().AddDate(0, 0, -29).Format(time.DateOnly) {
+		t.Errorf("unexpected since %q for the default 30 days", resp.Since)
+	}
+
+	costs := make(map[string]float64)
+	var sum float64
+	for _, c := range resp.Conversations {
+		costs[c.ConversationID] = c.CostUsd
+		sum += c.CostUsd
+	}
+	// The predictable model charges $0.001 per response.
+	if costs[first] < 0.001 {
+		t.Errorf("expected a cost for the first conversation, got %v", costs[first])
+	}
+	if costs[second] <= costs[first] {
+		t.Errorf("expected the two-turn conversation to cost more: %v <= %v", costs[second], costs[first])
+	}
+	if resp.Conversations[0].ConversationID != second {
+		t.Errorf("expected the most expensive conversation first")
+	}
+
+	if len(resp.Days) != 1 || resp.Days[0].Day != time.Now().UTC().Format(time.DateOnly) {
+		t.Fatalf("expected a single rollup for today, got %+v", resp.Days)
+	}
+	if math.Abs(resp.Days[0].CostUsd-sum) > 1e-9 || math.Abs(resp.TotalCostUSD-sum) > 1e-9 {
+		t.Errorf("daily total %v and total %v should match conversation sum %v", resp.Days[0].CostUsd, resp.TotalCostUSD, sum)
+	}
+	if resp.Days[0].OutputTokens == 0 || resp.Days[0].InputTokens == 0 {
+		t.Errorf("expected token totals, got %+v", resp.Days[0])
+	}
+
+	limited := getCosts(t, h.server, "?limit=1")
+	if len(limited.Conversations) != 1 {
+		t.Errorf("expected limit=1 to return one conversation, got %d", len(limited.Conversations))
+	}
+
+	req := httptest.NewRequest("GET", "/api/costs?days=0", nil)
+	w := httptest.NewRecorder()
+	h.server.handleCosts(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for days=0, got %d", w.Code)
+	}
+}
+
+func TestCustomModelPricing(t *testing.T) {
+	h := NewTestHarness(t)
+
+	body := `{"display_name": "Priced", "provider_type": "anthropic", "endpoint": "https://example.com", "api_key": "k", "model_name": "m",
+		"input_price_per_mtok": 3, "output_price_per_mtok": 15, "cache_read_price_per_mtok": 0.3, "cache_write_price_per_mtok": 3.75}`
+	req := httptest.NewRequest("POST", "/api/custom-models", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.server.handleCustomModels(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created ModelAPI
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if created.InputPricePerMTok != 3 || created.OutputPricePerMTok != 15 || created.CacheReadPricePerMTok != 0.3 || created.CacheWritePricePerMTok != 3.75 {
+		t.Errorf("prices not stored: %+v", created)
+	}
+
+	req = httptest.NewRequest("POST", "/api/custom-models/"+created.ModelID+"/duplicate", nil)
+	w = httptest.NewRecorder()
+	h.server.handleCustomModel(w, req)
+	var dup ModelAPI
+	if err := json.Unmarshal(w.Body.Bytes(), &dup); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if dup.OutputPricePerMTok != 15 {
+		t.Errorf("duplicate did not copy prices: %+v", dup)
+	}
+
+	body = `{"display_name": "Bad", "provider_type": "anthropic", "endpoint": "https://example.com", "api_key": "k", "model_name": "m", "input_price_per_mtok": -1}`
+	req = httptest.NewRequest("POST", "/api/custom-models", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	h.server.handleCustomModels(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a negative price, got %d", w.Code)
+	}
+}
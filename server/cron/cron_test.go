@@ -0,0 +1,71 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	// Friday, 2026-01-02 10:30 UTC.
+	from := time.Date(2026, 1, 2, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"* * * * *", "2026-01-02 10:31"},
+		{"*/15 * * * *", "2026-01-02 10:45"},
+		{"0 9 * * mon-fri", "2026-01-05 09:00"},
+		{"0 9 * * 1-5", "2026-01-05 09:00"},
+		{"30 10 * * *", "2026-01-03 10:30"},
+		{"0 0 1 * *", "2026-02-01 00:00"},
+		{"0 12 13 * 5", "2026-01-02 12:00"}, // day of month or day of week
+		{"0 0 * * 7", "2026-01-04 00:00"},
+		{"5/20 * * * *", "2026-01-02 10:45"},
+		{"0 0 29 feb *", "2028-02-29 00:00"},
+		{"@hourly", "2026-01-02 11:00"},
+		{"@weekly", "2026-01-04 00:00"},
+		{"0 0 1,15 mar,jun *", "2026-03-01 00:00"},
+		{"0 0 30 2 *", "0001-01-01 00:00"},
+	}
+	for _, tt := range tests {
+		s, err := Parse("CRON_TZ=UTC " + tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(from).UTC().Format("2006-01-02 15:04"); got != tt.want {
+			t.Errorf("Next(%q) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestNextTimeZone(t *testing.T) {
+	s, err := Parse("CRON_TZ=America/New_York 0 9 * * *")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	got := s.Next(time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 7, 1, 13, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next = %s, want %s", got.UTC(), want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"CRON_TZ=Nowhere/Land * * * * *",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q): expected an error", expr)
+		}
+	}
+}
@@ -26,6 +26,12 @@ type ModelAPI struct {
 	ModelName    string `json:"model_name"`
 	MaxTokens    int64  `json:"max_tokens"`
 	Tags         string `json:"tags"` // Comma-separated tags (e.g., "slug" for slug generation)
+
+	// Prices in USD per million tokens, used to compute cost; 0 means unknown
+	InputPricePerMTok      float64 `json:"input_price_per_mtok"`
+	OutputPricePerMTok     float64 `json:"output_price_per_mtok"`
+	CacheReadPricePerMTok  float64 `json:"cache_read_price_per_mtok"`
+	CacheWritePricePerMTok float64 `json:"cache_write_price_per_mtok"`
 }
 
 // CreateModelRequest is the request body for creating a model
@@ -37,6 +43,12 @@ type CreateModelRequest struct {
 	ModelName    string `json:"model_name"`
 	MaxTokens    int64  `json:"max_tokens"`
 	Tags         string `json:"tags"` // Comma-separated tags
+
+	// Prices in USD per million tokens, used to compute cost; 0 means unknown
+	InputPricePerMTok      float64 `json:"input_price_per_mtok"`
+	OutputPricePerMTok     float64 `json:"output_price_per_mtok"`
+	CacheReadPricePerMTok  float64 `json:"cache_read_price_per_mtok"`
+	CacheWritePricePerMTok float64 `json:"cache_write_price_per_mtok"`
 }
 
 // UpdateModelRequest is the request body for updating a model
@@ -48,6 +60,12 @@ type UpdateModelRequest struct {
 	ModelName    string `json:"model_name"`
 	MaxTokens    int64  `json:"max_tokens"`
 	Tags         string `json:"tags"` // Comma-separated tags
+
+	// Prices in USD per million tokens, used to compute cost; 0 means unknown
+	InputPricePerMTok      float64 `json:"input_price_per_mtok"`
+	OutputPricePerMTok     float64 `json:"output_price_per_mtok"`
+	CacheReadPricePerMTok  float64 `json:"cache_read_price_per_mtok"`
+	CacheWritePricePerMTok float64 `json:"cache_write_price_per_mtok"`
 }
 
 // TestModelRequest is the request body for testing a model
@@ -69,7 +87,24 @@ func toModelAPI(m generated.Model) ModelAPI {
 		ModelName:    m.ModelName,
 		MaxTokens:    m.MaxTokens,
 		Tags:         m.Tags,
+
+		InputPricePerMTok:      m.InputPricePerMtok,
+		OutputPricePerMTok:     m.OutputPricePerMtok,
+		CacheReadPricePerMTok:  m.CacheReadPricePerMtok,
+		CacheWritePricePerMTok: m.CacheWritePricePerMtok,
+	}
+}
+
+// defaultMaxTokens returns the max_tokens to store for a model. For local
+// models, 0 means use the context window the server reports.
+func defaultMaxTokens(providerType string, maxTokens int64) int64 {
+	if maxTokens > 0 {
+		return maxTokens
+	}
+	if providerType == "local" {
+		return 0
 	}
+	return 200000
 }
 
 func (s *Server) handleCustomModels(w http.ResponseWriter, r *http.Request) {
@@ -106,25 +141,27 @@ func (s *Server) handleCreateModel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate required fields
-	if req.DisplayName == "" || req.ProviderType == "" || req.Endpoint == "" || req.APIKey == "" || req.ModelName == "" {
+	// Validate required fields. Local servers usually don't need an API key.
+	if req.DisplayName == "" || req.ProviderType == "" || req.Endpoint == "" || (req.APIKey == "" && req.ProviderType != "local") || req.ModelName == "" {
 		http.Error(w, "display_name, provider_type, endpoint, api_key, and model_name are required", http.StatusBadRequest)
 		return
 	}
 
 	// Validate provider type
-	if req.ProviderType != "anthropic" && req.ProviderType != "openai" && req.ProviderType != "openai-responses" && req.ProviderType != "gemini" {
-		http.Error(w, "provider_type must be 'anthropic', 'openai', 'openai-responses', or 'gemini'", http.StatusBadRequest)
+	if req.ProviderType != "anthropic" && req.ProviderType != "openai" && req.ProviderType != "openai-responses" && req.ProviderType != "gemini" && req.ProviderType != "local" {
+		http.Error(w, "provider_type must be 'anthropic', 'openai', 'openai-responses', 'gemini', or 'local'", http.StatusBadRequest)
+		return
+	}
+
+	if req.InputPricePerMTok < 0 || req.OutputPricePerMTok < 0 || req.CacheReadPricePerMTok < 0 || req.CacheWritePricePerMTok < 0 {
+		http.Error(w, "prices must not be negative", http.StatusBadRequest)
 		return
 	}
 
 	// Generate model ID
 	modelID := "custom-" + uuid.New().String()[:8]
 
-	// Default max tokens
-	if req.MaxTokens <= 0 {
-		req.MaxTokens = 200000
-	}
+	req.MaxTokens = defaultMaxTokens(req.ProviderType, req.MaxTokens)
 
 	model, err := s.db.CreateModel(r.Context(), generated.CreateModelParams{
 		ModelID:      modelID,
@@ -135,6 +172,11 @@ func (s *Server) handleCreateModel(w http.ResponseWriter, r *http.Request) {
 		ModelName:    req.ModelName,
 		MaxTokens:    req.MaxTokens,
 		Tags:         req.Tags,
+
+		InputPricePerMtok:      req.InputPricePerMTok,
+		OutputPricePerMtok:     req.OutputPricePerMTok,
+		CacheReadPricePerMtok:  req.CacheReadPricePerMTok,
+		CacheWritePricePerMtok: req.CacheWritePricePerMTok,
 	})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create model: %v", err), http.StatusInternalServerError)
@@ -213,16 +255,18 @@ func (s *Server) handleUpdateModel(w http.ResponseWriter, r *http.Request, model
 		return
 	}
 
+	if req.InputPricePerMTok < 0 || req.OutputPricePerMTok < 0 || req.CacheReadPricePerMTok < 0 || req.CacheWritePricePerMTok < 0 {
+		http.Error(w, "prices must not be negative", http.StatusBadRequest)
+		return
+	}
+
 	// Use existing API key if not provided
 	apiKey := req.APIKey
 	if apiKey == "" {
 		apiKey = existing.ApiKey
 	}
 
-	// Default max tokens
-	if req.MaxTokens <= 0 {
-		req.MaxTokens = 200000
-	}
+	req.MaxTokens = defaultMaxTokens(req.ProviderType, req.MaxTokens)
 
 	model, err := s.db.UpdateModel(r.Context(), generated.UpdateModelParams{
 		DisplayName:  req.DisplayName,
@@ -233,6 +277,11 @@ func (s *Server) handleUpdateModel(w http.ResponseWriter, r *http.Request, model
 		MaxTokens:    req.MaxTokens,
 		Tags:         req.Tags,
 		ModelID:      modelID,
+
+		InputPricePerMtok:      req.InputPricePerMTok,
+		OutputPricePerMtok:     req.OutputPricePerMTok,
+		CacheReadPricePerMtok:  req.CacheReadPricePerMTok,
+		CacheWritePricePerMtok: req.CacheWritePricePerMTok,
 	})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to update model: %v", err), http.StatusInternalServerError)
@@ -301,6 +350,11 @@ func (s *Server) handleDuplicateModel(w http.ResponseWriter, r *http.Request, mo
 		ModelName:    source.ModelName,
 		MaxTokens:    source.MaxTokens,
 		Tags:         "", // Don't copy tags
+
+		InputPricePerMtok:      source.InputPricePerMtok,
+		OutputPricePerMtok:     source.OutputPricePerMtok,
+		CacheReadPricePerMtok:  source.CacheReadPricePerMtok,
+		CacheWritePricePerMtok: source.CacheWritePricePerMtok,
 	})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to duplicate model: %v", err), http.StatusInternalServerError)
@@ -339,13 +393,22 @@ func (s *Server) handleTestModel(w http.ResponseWriter, r *http.Request) {
 		req.APIKey = model.ApiKey
 	}
 
-	if req.ProviderType == "" || req.Endpoint == "" || req.APIKey == "" || req.ModelName == "" {
+	if req.ProviderType == "" || req.Endpoint == "" || (req.APIKey == "" && req.ProviderType != "local") || req.ModelName == "" {
 		http.Error(w, "provider_type, endpoint, api_key, and model_name are required", http.StatusBadRequest)
 		return
 	}
 
+	// Send a simple test request. Local servers may need to load the model first.
+	timeout := 10 * time.Second
+	if req.ProviderType == "local" {
+		timeout = time.Minute
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
 	// Create the appropriate service based on provider type
 	var service llm.Service
+	var details string
 	switch req.ProviderType {
 	case "anthropic":
 		service = &ant.Service{
@@ -377,15 +440,35 @@ func (s *Server) handleTestModel(w http.ResponseWriter, r *http.Request) {
 				URL:       req.Endpoint,
 			},
 		}
+	case "local":
+		lm, err := oai.ProbeLocalModel(ctx, nil, req.Endpoint, req.APIKey, req.ModelName)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": fmt.Sprintf("Test failed: %v", err),
+			})
+			return
+		}
+		service = &oai.Service{
+			APIKey:   req.APIKey,
+			ModelURL: req.Endpoint,
+			Model: oai.Model{
+				ModelName: req.ModelName,
+				URL:       req.Endpoint,
+			},
+			MaxTokens: 256,
+		}
+		toolCalls := "native"
+		if !lm.NativeTools {
+			toolCalls = "text"
+		}
+		details = fmt.Sprintf(" (context window: %s, tool calls: %s)", contextWindowText(lm.ContextWindow), toolCalls)
 	default:
 		http.Error(w, "Invalid provider_type", http.StatusBadRequest)
 		return
 	}
 
-	// Send a simple test request
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-	defer cancel()
-
 	request := &llm.Request{
 		Messages: []llm.Message{
 			{
@@ -420,6 +503,14 @@ func (s *Server) handleTestModel(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"message": fmt.Sprintf("Test successful! Response: %s", response.Content[0].Text),
+		"message": fmt.Sprintf("Test successful! Response: %s%s", response.Content[0].Text, details),
 	})
 }
+
+// contextWindowText describes a probed context window.
+func contextWindowText(tokens int) string {
+	if tokens == 0 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d tokens", tokens)
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateLocalModel(t *testing.T) {
+	h := NewTestHarness(t)
+
+	// Local models need no API key, and max_tokens 0 means use the probed context window.
+	body := `{"display_name": "Qwen", "provider_type": "local", "endpoint": "http://gpu-box:11434/v1", "model_name": "qwen3:8b"}`
+	req := httptest.NewRequest("POST", "/api/custom-models", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.server.handleCustomModels(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created ModelAPI
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if created.ProviderType != "local" || created.MaxTokens != 0 {
+		t.Errorf("unexpected model: %+v", created)
+	}
+
+	body = `{"display_name": "Claude", "provider_type": "anthropic", "endpoint": "https://example.com", "model_name": "m"}`
+	req = httptest.NewRequest("POST", "/api/custom-models", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	h.server.handleCustomModels(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without an API key, got %d", w.Code)
+	}
+}
@@ -184,45 +184,13 @@ func (s *Server) runDistillation(ctx context.Context, conversationID, sourceSlug
 		return
 	}
 
-	// Make the LLM call
-	distillCtx, cancel := context.WithTimeout(ctx, 120*time.Second)
-	defer cancel()
-
-	// TODO: consider disabling thinking for distillation requests to reduce
-	// cost and latency — it's a simple summarization task.
-	resp, err := svc.Do(distillCtx, &llm.Request{
-		System: []llm.SystemContent{
-			{Text: distillSystemPrompt, Type: "text"},
-		},
-		Messages: []llm.Message{
-			{
-				Role: llm.MessageRoleUser,
-				Content: []llm.Content{
-					{Type: llm.ContentTypeText, Text: transcript},
-				},
-			},
-		},
-	})
+	distilledText, err := distillTranscript(ctx, svc, transcript)
 	if err != nil {
 		logger.Error("LLM distillation failed", "error", err)
 		s.insertDistillError(ctx, conversationID, fmt.Sprintf("Distillation failed: %v", err))
 		return
 	}
 
-	// Extract text from response
-	var distilledText string
-	for _, content := range resp.Content {
-		if content.Type == llm.ContentTypeText {
-			distilledText += content.Text
-		}
-	}
-
-	if distilledText == "" {
-		logger.Error("LLM returned empty distillation")
-		s.insertDistillError(ctx, conversationID, "Distillation returned empty result")
-		return
-	}
-
 	logger.Info("Distillation complete", "output_length", len(distilledText))
 
 	// Update the status message to "complete"
@@ -251,6 +219,44 @@ func (s *Server) runDistillation(ctx context.Context, conversationID, sourceSlug
 	}
 }
 
+// distillTranscript asks svc to distill a conversation transcript using the
+// distillation prompt and returns the resulting text.
+func distillTranscript(ctx context.Context, svc llm.Service, transcript string) (string, error) {
+	distillCtx, cancel := context.WithTimeout(ctx, 120*time.Second)
+	defer cancel()
+
+	// TODO: consider disabling thinking for distillation requests to reduce
+	// cost and latency — it's a simple summarization task.
+	resp, err := svc.Do(distillCtx, &llm.Request{
+		System: []llm.SystemContent{
+			{Text: distillSystemPrompt, Type: "text"},
+		},
+		Messages: []llm.Message{
+			{
+				Role: llm.MessageRoleUser,
+				Content: []llm.Content{
+					{Type: llm.ContentTypeText, Text: transcript},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Extract text from response
+	var distilledText string
+	for _, content := range resp.Content {
+		if content.Type == llm.ContentTypeText {
+			distilledText += content.Text
+		}
+	}
+	if distilledText == "" {
+		return "", fmt.Errorf("distillation returned empty result")
+	}
+	return distilledText, nil
+}
+
 // insertDistillError updates status to error and inserts an error message.
 func (s *Server) insertDistillError(ctx context.Context, conversationID, errMsg string) {
 	s.updateDistillStatus(ctx, conversationID, "error")
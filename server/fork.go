@@ -0,0 +1,171 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/slug"
+)
+
+// ForkRequest is the body of POST /api/conversation/<id>/fork.
+type ForkRequest struct {
+	// MessageID is the message to branch off at.
+	MessageID string `json:"message_id"`
+	// Message, if set, replaces the user message MessageID refers to, as in
+	// edit-and-resend. Otherwise the fork keeps history through MessageID.
+	Message string `json:"message,omitempty"`
+	// Model defaults to the source conversation's model.
+	Model string `json:"model,omitempty"`
+	// RestoreFiles rewinds files to how they were at the fork point, using
+	// the source conversation's checkpoints.
+	RestoreFiles bool `json:"restore_files,omitempty"`
+}
+
+// handleForkConversation handles POST /conversation/<id>/fork. It copies the
+// conversation's history up to a message into a new conversation and, if that
+// history ends with a user message, starts the agent on it. With
+// restore_files, files are first rewound using the source's checkpoints.
+func (s *Server) handleForkConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	var req ForkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.MessageID == "" {
+		http.Error(w, "message_id is required", http.StatusBadRequest)
+		return
+	}
+
+	source, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	at, err := s.db.GetMessageByID(ctx, req.MessageID)
+	if err != nil || at.ConversationID != conversationID {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if req.Message != "" && at.Type != string(db.MessageTypeUser) {
+		http.Error(w, "Only user messages can be edited", http.StatusBadRequest)
+		return
+	}
+
+	modelID := req.Model
+	if modelID == "" && source.Model != nil {
+		modelID = *source.Model
+	}
+	if modelID == "" {
+		modelID = s.defaultModel
+	}
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		s.logger.Error("Unsupported model requested", "model", modelID, "error", err)
+		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
+		return
+	}
+
+	var restored []string
+	if req.RestoreFiles {
+		s.mu.Lock()
+		manager, ok := s.activeConversations[conversationID]
+		s.mu.Unlock()
+		if ok && manager.IsAgentWorking() {
+			http.Error(w, "Agent is working; cancel it before restoring files", http.StatusConflict)
+			return
+		}
+		// A user message's checkpoint is taken as it is sent; for other
+		// messages, the state at the start of the next turn is the closest we have.
+		after := at.SequenceID
+		if at.Type == string(db.MessageTypeUser) {
+			after--
+		}
+		checkpoint, err := s.db.GetFirstCheckpointAfter(ctx, conversationID, after)
+		if err != nil {
+			s.logger.Error("Failed to get checkpoint", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if checkpoint != nil {
+			restored, err = restoreCheckpoint(ctx, s.db, checkpoint)
+			if err != nil {
+				s.logger.Error("Failed to restore checkpoint", "conversationID", conversationID, "checkpointID", checkpoint.CheckpointID, "error", err)
+				http.Error(w, fmt.Sprintf("Failed to restore checkpoint: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+		if restored == nil {
+			restored = []string{}
+		}
+	}
+
+	fork, err := s.db.ForkConversation(ctx, db.ForkConversationParams{
+		SourceConversationID: conversationID,
+		MessageID:            req.MessageID,
+		IncludeMessage:       req.Message == "",
+		Cwd:                  source.Cwd,
+		Model:                &modelID,
+	})
+	if err != nil {
+		s.logger.Error("Failed to fork conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if source.Slug != nil {
+		if forkSlug, err := slug.SetUniqueSlug(ctx, s.db, s.logger, fork.ConversationID, *source.Slug+"-fork"); err != nil {
+			s.logger.Warn("Failed to set slug for fork", "conversationID", fork.ConversationID, "error", err)
+		} else {
+			fork.Slug = &forkSlug
+		}
+	}
+	s.logger.Info("Forked conversation", "source", conversationID, "fork", fork.ConversationID, "messageID", req.MessageID)
+
+	go s.publishConversationListUpdate(ConversationListUpdate{
+		Type:         "update",
+		Conversation: fork,
+	})
+
+	manager, err := s.getOrCreateConversationManager(ctx, fork.ConversationID, r.Header.Get("X-ExeDev-Email"))
+	if err != nil {
+		s.logger.Error("Failed to get conversation manager", "conversationID", fork.ConversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	switch {
+	case req.Message != "":
+		userMessage := llm.Message{
+			Role:    llm.MessageRoleUser,
+			Content: []llm.Content{{Type: llm.ContentTypeText, Text: req.Message}},
+		}
+		_, err = manager.AcceptUserMessage(ctx, llmService, modelID, userMessage, nil)
+	case at.Type == string(db.MessageTypeUser):
+		err = manager.Resume(ctx, llmService, modelID)
+	}
+	if err != nil {
+		if errors.Is(err, errConversationModelMismatch) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.logger.Error("Failed to start forked conversation", "conversationID", fork.ConversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	resp := map[string]interface{}{
+		"status":          "forked",
+		"conversation_id": fork.ConversationID,
+		"conversation":    fork,
+	}
+	if req.RestoreFiles {
+		resp["restored"] = restored
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+)
+
+// fork posts a fork request for the harness's conversation and returns the response.
+func fork(t *testing.T, h *TestHarness, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/"+h.convID+"/fork", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.server.conversationMux().ServeHTTP(w, req)
+	return w
+}
+
+func forkedConversationID(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 from fork, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse fork response: %v", err)
+	}
+	return resp.ConversationID
+}
+
+func TestForkConversation(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+	h.Chat("echo: second")
+	h.WaitResponse()
+	sourceID := h.convID
+	if _, err := h.db.UpdateConversationSlug(ctx, sourceID, "fork-source"); err != nil {
+		t.Fatalf("failed to set slug: %v", err)
+	}
+	sourceMessages, err := h.db.ListMessages(ctx, sourceID)
+	if err != nil {
+		t.Fatalf("failed to list messages: %v", err)
+	}
+	userMsgs, err := h.db.ListMessagesByType(ctx, sourceID, db.MessageTypeUser)
+	if err != nil {
+		t.Fatalf("failed to list user messages: %v", err)
+	}
+	agentMsgs, err := h.db.ListMessagesByType(ctx, sourceID, db.MessageTypeAgent)
+	if err != nil {
+		t.Fatalf("failed to list agent messages: %v", err)
+	}
+	second := userMsgs[1].MessageID
+
+	t.Run("edit and resend", func(t *testing.T) {
+		h.convID = sourceID
+		forkID := forkedConversationID(t, fork(t, h, `{"message_id": "`+second+`", "message": "echo: edited"}`))
+		h.convID, h.responsesCount = forkID, 1
+		if got := h.WaitResponse(); got != "edited" {
+			t.Errorf("expected forked turn to answer the edited message, got %q", got)
+		}
+
+		conv, err := h.db.GetConversationByID(ctx, forkID)
+		if err != nil {
+			t.Fatalf("failed to get fork: %v", err)
+		}
+		if conv.ForkedFromConversationID == nil || *conv.ForkedFromConversationID != sourceID {
+			t.Errorf("fork is not linked to its source: %+v", conv)
+		}
+		forkUserMsgs, err := h.db.ListMessagesByType(ctx, forkID, db.MessageTypeUser)
+		if err != nil {
+			t.Fatalf("failed to list user messages: %v", err)
+		}
+		if len(forkUserMsgs) != 2 || !strings.Contains(*forkUserMsgs[1].LlmData, "echo: edited") {
+			t.Errorf("expected the second user message to be replaced, got %d user messages", len(forkUserMsgs))
+		}
+
+		// The source conversation is untouched.
+		after, err := h.db.ListMessages(ctx, sourceID)
+		if err != nil {
+			t.Fatalf("failed to list messages: %v", err)
+		}
+		if len(after) != len(sourceMessages) {
+			t.Errorf("source conversation changed from %d to %d messages", len(sourceMessages), len(after))
+		}
+	})
+
+	t.Run("retry from a user message", func(t *testing.T) {
+		h.convID = sourceID
+		forkID := forkedConversationID(t, fork(t, h, `{"message_id": "`+second+`"}`))
+		h.convID, h.responsesCount = forkID, 1
+		if got := h.WaitResponse(); got != "second" {
+			t.Errorf("expected forked turn to answer the copied message, got %q", got)
+		}
+	})
+
+	t.Run("branch after an agent message", func(t *testing.T) {
+		h.convID = sourceID
+		requests := len(h.llm.GetRecentRequests())
+		forkID := forkedConversationID(t, fork(t, h, `{"message_id": "`+agentMsgs[0].MessageID+`"}`))
+		latest, err := h.db.GetLatestMessage(ctx, forkID)
+		if err != nil {
+			t.Fatalf("failed to get latest message: %v", err)
+		}
+		if latest.SequenceID != agentMsgs[0].SequenceID {
+			t.Errorf("expected fork to end at the agent message, got sequence %d", latest.SequenceID)
+		}
+		if n := len(h.llm.GetRecentRequests()); n != requests {
+			t.Errorf("expected no LLM request for a fork ending in an agent message, got %d", n-requests)
+		}
+		conv, err := h.db.GetConversationByID(ctx, forkID)
+		if err != nil {
+			t.Fatalf("failed to get fork: %v", err)
+		}
+		if conv.Slug == nil || !strings.HasPrefix(*conv.Slug, "fork-source-fork") {
+			t.Errorf("expected fork slug derived from the source, got %v", conv.Slug)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		h.convID = sourceID
+		if w := fork(t, h, `{"message_id": "`+agentMsgs[0].MessageID+`", "message": "edited"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 editing an agent message, got %d", w.Code)
+		}
+		if w := fork(t, h, `{"message_id": "no-such-message"}`); w.Code != http.StatusNotFound {
+			t.Errorf("expected 404 for an unknown message, got %d", w.Code)
+		}
+		if w := fork(t, h, `{}`); w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 without message_id, got %d", w.Code)
+		}
+	})
+}
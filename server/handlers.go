@@ -1,6 +1,7 @@
 package server
 
 import (
+	"cmp"
 	"compress/gzip"
 	"context"
 	"crypto/rand"
@@ -20,6 +21,7 @@ import (
 	"time"
 
 	"shelley.exe.dev/claudetool/browse"
+	"shelley.exe.dev/claudetool/sandbox"
 	"shelley.exe.dev/db/generated"
 	"shelley.exe.dev/gitstate"
 	"shelley.exe.dev/llm"
@@ -357,6 +359,13 @@ func (s *Server) serveIndexWithInit(w http.ResponseWriter, r *http.Request, fs h
 		return
 	}
 
+	// Until the client signs in, only tell the UI how to sign in.
+	authData, signedIn := s.indexAuth(r)
+	if !signedIn {
+		s.writeIndex(w, indexHTML, map[string]interface{}{"auth": authData})
+		return
+	}
+
 	// Build initialization data
 	modelList := s.getModelList()
 
@@ -386,15 +395,7 @@ func (s *Server) serveIndexWithInit(w http.ResponseWriter, r *http.Request, fs h
 		}
 	}
 
-	// Get hostname (add .exe.xyz suffix if no dots, matching system_prompt.go)
-	hostname := "localhost"
-	if h, err := os.Hostname(); err == nil {
-		if !strings.Contains(h, ".") {
-			hostname = h + ".exe.xyz"
-		} else {
-			hostname = h
-		}
-	}
+	hostname := indexHostname()
 
 	// Get default working directory
 	defaultCwd, err := os.Getwd()
@@ -419,15 +420,39 @@ func (s *Server) serveIndexWithInit(w http.ResponseWriter, r *http.Request, fs h
 		initData["links"] = s.links
 	}
 
+	if authData != nil {
+		initData["auth"] = authData
+	}
+
 	// Inject notification channel type metadata for the settings modal
 	initData["notification_channel_types"] = s.getNotificationChannelTypes()
 
+	s.writeIndex(w, indexHTML, initData)
+}
+
+// indexHostname returns the hostname shown in the UI, adding the .exe.xyz
+// suffix if it has no dots, matching system_prompt.go.
+func indexHostname() string {
+	h, err := os.Hostname()
+	if err != nil {
+		return "localhost"
+	}
+	if !strings.Contains(h, ".") {
+		return h + ".exe.xyz"
+	}
+	return h
+}
+
+// writeIndex writes index.html with the favicon and init data injected.
+func (s *Server) writeIndex(w http.ResponseWriter, indexHTML []byte, initData map[string]interface{}) {
 	initJSON, err := json.Marshal(initData)
 	if err != nil {
 		http.Error(w, "Failed to marshal init data", http.StatusInternalServerError)
 		return
 	}
 
+	hostname := indexHostname()
+
 	// Generate favicon as data URI
 	faviconSVG := generateFaviconSVG(hostname)
 	faviconDataURI := "data:image/svg+xml," + url.PathEscape(faviconSVG)
@@ -469,12 +494,16 @@ func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
 
 	// Get conversations from database
 	var conversations []generated.Conversation
+	var matches []generated.SearchConversationsWithMessagesRow
 	var err error
 
 	if query != "" {
 		if searchContent {
 			// Search in both slug and message content
-			conversations, err = s.db.SearchConversationsWithMessages(ctx, query, int64(limit), int64(offset))
+			matches, err = s.db.SearchConversationsWithMessages(ctx, query, int64(limit), int64(offset))
+			for _, match := range matches {
+				conversations = append(conversations, match.Conversation)
+			}
 		} else {
 			// Search only in slug
 			conversations, err = s.db.SearchConversations(ctx, query, int64(limit), int64(offset))
@@ -501,6 +530,12 @@ func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
 			Conversation: conv,
 			Working:      workingStates[conv.ConversationID],
 		}
+		if matches != nil {
+			cws.MatchSequenceID = matches[i].SequenceID
+			if matches[i].Snippet != nil {
+				cws.MatchSnippet = *matches[i].Snippet
+			}
+		}
 		if conv.Cwd != nil {
 			gs, ok := gitStates[*conv.Cwd]
 			if !ok {
@@ -556,6 +591,51 @@ func (s *Server) conversationMux() *http.ServeMux {
 	mux.HandleFunc("GET /{id}/subagents", func(w http.ResponseWriter, r *http.Request) {
 		s.handleGetSubagents(w, r, r.PathValue("id"))
 	})
+	mux.HandleFunc("GET /{id}/budget", func(w http.ResponseWriter, r *http.Request) {
+		s.handleGetConversationBudget(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/budget", func(w http.ResponseWriter, r *http.Request) {
+		s.handleSetConversationBudget(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/resume", func(w http.ResponseWriter, r *http.Request) {
+		s.handleResumeConversation(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/fork", func(w http.ResponseWriter, r *http.Request) {
+		s.handleForkConversation(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/checkpoints", func(w http.ResponseWriter, r *http.Request) {
+		s.handleListCheckpoints(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/checkpoints/{checkpoint}/restore", func(w http.ResponseWriter, r *http.Request) {
+		s.handleRestoreCheckpoint(w, r, r.PathValue("id"), r.PathValue("checkpoint"))
+	})
+	mux.HandleFunc("GET /{id}/permissions", func(w http.ResponseWriter, r *http.Request) {
+		s.handleGetPermissions(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/permissions", func(w http.ResponseWriter, r *http.Request) {
+		s.handleSetPermissions(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/approvals/{approval}", func(w http.ResponseWriter, r *http.Request) {
+		s.handleResolveApproval(w, r, r.PathValue("id"), r.PathValue("approval"))
+	})
+	mux.HandleFunc("GET /{id}/mcp", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationMCP(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/sandbox", func(w http.ResponseWriter, r *http.Request) {
+		s.handleGetSandbox(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/sandbox", func(w http.ResponseWriter, r *http.Request) {
+		s.handleSetSandbox(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/processes", func(w http.ResponseWriter, r *http.Request) {
+		s.handleListProcesses(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/processes/{process}/output", func(w http.ResponseWriter, r *http.Request) {
+		s.handleProcessOutput(w, r, r.PathValue("id"), r.PathValue("process"))
+	})
+	mux.HandleFunc("POST /{id}/processes/{process}/stop", func(w http.ResponseWriter, r *http.Request) {
+		s.handleStopProcess(w, r, r.PathValue("id"), r.PathValue("process"))
+	})
 	return mux
 }
 
@@ -605,6 +685,37 @@ type ChatRequest struct {
 	Message string `json:"message"`
 	Model   string `json:"model,omitempty"`
 	Cwd     string `json:"cwd,omitempty"`
+	// Sandbox is the sandbox mode for a new conversation's commands; by
+	// default, the server's.
+	Sandbox sandbox.Mode `json:"sandbox,omitempty"`
+	// Attachments are images and files sent with the message.
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// ThinkingLevel, if set, becomes the conversation's thinking level
+	// ("off", "minimal", "low", "medium" or "high") from this message on.
+	// "" or "default" goes back to the model's default.
+	ThinkingLevel *string `json:"thinking_level,omitempty"`
+}
+
+// thinkingLevelUpdate changes a conversation's thinking level. A nil Level
+// goes back to the model's default.
+type thinkingLevelUpdate struct {
+	Level *llm.ThinkingLevel
+}
+
+// thinkingLevel parses the request's thinking level. It returns nil if the
+// request leaves the conversation's level alone.
+func (req *ChatRequest) thinkingLevel() (*thinkingLevelUpdate, error) {
+	if req.ThinkingLevel == nil {
+		return nil, nil
+	}
+	if name := *req.ThinkingLevel; name == "" || name == "default" {
+		return &thinkingLevelUpdate{}, nil
+	}
+	level, err := llm.ParseThinkingLevel(*req.ThinkingLevel)
+	if err != nil {
+		return nil, err
+	}
+	return &thinkingLevelUpdate{Level: &level}, nil
 }
 
 // handleChatConversation handles POST /conversation/<id>/chat
@@ -618,15 +729,26 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 
 	// Parse request
 	var req ChatRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxChatRequestSize)
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Request exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	if req.Message == "" {
+	if req.Message == "" && len(req.Attachments) == 0 {
 		http.Error(w, "Message is required", http.StatusBadRequest)
 		return
 	}
+	thinking, err := req.thinkingLevel()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Get LLM service for the requested model
 	modelID := req.Model
@@ -641,45 +763,73 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	userEmail := r.Header.Get("X-ExeDev-Email")
+	attachments, code, msg := attachmentContents(req.Attachments, llmService)
+	if code != 0 {
+		http.Error(w, msg, code)
+		return
+	}
 
-	// Get or create conversation manager
-	manager, err := s.getOrCreateConversationManager(ctx, conversationID, userEmail)
+	err = s.acceptUserMessage(ctx, conversationID, r.Header.Get("X-ExeDev-Email"), llmService, modelID, req.Message, attachments, thinking)
 	if errors.Is(err, errConversationModelMismatch) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	if err != nil {
-		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+}
+
+// acceptUserMessage gives a user message to a conversation's agent, starting
+// the conversation's loop if needed, and names the conversation after its
+// first message. Attachments follow the text in the message, and a non-nil
+// thinking changes the conversation's thinking level from it on. It returns
+// errConversationModelMismatch if the conversation already uses another model.
+func (s *Server) acceptUserMessage(ctx context.Context, conversationID, userEmail string, llmService llm.Service, modelID, text string, attachments []llm.Content, thinking *thinkingLevelUpdate) error {
+	// Get or create conversation manager
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID, userEmail)
+	if errors.Is(err, errConversationModelMismatch) {
+		return err
+	}
+	if err != nil {
+		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
+		return err
+	}
+
 	// Create user message
-	userMessage := llm.Message{
-		Role: llm.MessageRoleUser,
-		Content: []llm.Content{
-			{Type: llm.ContentTypeText, Text: req.Message},
-		},
+	userMessage := llm.Message{Role: llm.MessageRoleUser}
+	if text != "" {
+		userMessage.Content = append(userMessage.Content, llm.Content{Type: llm.ContentTypeText, Text: text})
 	}
+	userMessage.Content = append(userMessage.Content, attachments...)
 
-	firstMessage, err := manager.AcceptUserMessage(ctx, llmService, modelID, userMessage)
+	firstMessage, err := manager.AcceptUserMessage(ctx, llmService, modelID, userMessage, thinking)
 	if errors.Is(err, errConversationModelMismatch) {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		return err
 	}
 	if err != nil {
 		s.logger.Error("Failed to accept user message", "conversationID", conversationID, "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		return err
 	}
 
 	if firstMessage {
+		slugText := text
+		if slugText == "" {
+			// Name the conversation after what was attached.
+			var names []string
+			for _, c := range attachments {
+				names = append(names, cmp.Or(c.Filename, c.MediaType))
+			}
+			slugText = "Attached: " + strings.Join(names, ", ")
+		}
 		ctxNoCancel := context.WithoutCancel(ctx)
 		go func() {
 			slugCtx, cancel := context.WithTimeout(ctxNoCancel, 15*time.Second)
 			defer cancel()
-			_, err := slug.GenerateSlug(slugCtx, s.llmManager, s.db, s.logger, conversationID, req.Message, modelID)
+			_, err := slug.GenerateSlug(slugCtx, s.llmManager, s.db, s.logger, conversationID, slugText, modelID)
 			if err != nil {
 				s.logger.Warn("Failed to generate slug for conversation", "conversationID", conversationID, "error", err)
 			} else {
@@ -687,9 +837,43 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 			}
 		}()
 	}
+	return nil
+}
 
-	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+// createConversation creates a conversation for a new chat, and tells the
+// conversation list subscribers about it.
+func (s *Server) createConversation(ctx context.Context, req ChatRequest, modelID string, thinking *thinkingLevelUpdate) (*generated.Conversation, error) {
+	// Create new conversation with optional cwd
+	var cwdPtr *string
+	if req.Cwd != "" {
+		cwdPtr = &req.Cwd
+	}
+	conversation, err := s.db.CreateConversation(ctx, nil, true, cwdPtr, &modelID)
+	if err != nil {
+		s.logger.Error("Failed to create conversation", "error", err)
+		return nil, err
+	}
+	if req.Sandbox != "" {
+		if err := s.db.SetConversationSandbox(ctx, conversation.ConversationID, string(req.Sandbox)); err != nil {
+			s.logger.Error("Failed to set conversation sandbox", "conversationID", conversation.ConversationID, "error", err)
+			return nil, err
+		}
+	}
+	if thinking != nil && thinking.Level != nil {
+		name := thinking.Level.Name()
+		if err := s.db.SetConversationThinkingLevel(ctx, conversation.ConversationID, name); err != nil {
+			s.logger.Error("Failed to set conversation thinking level", "conversationID", conversation.ConversationID, "error", err)
+			return nil, err
+		}
+		conversation.ThinkingLevel = &name
+	}
+
+	// Notify conversation list subscribers about the new conversation
+	go s.publishConversationListUpdate(ConversationListUpdate{
+		Type:         "update",
+		Conversation: conversation,
+	})
+	return conversation, nil
 }
 
 // handleNewConversation handles POST /api/conversations/new - creates conversation implicitly on first message
@@ -703,15 +887,26 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request
 	var req ChatRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxChatRequestSize)
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Request exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	if req.Message == "" {
+	if req.Message == "" && len(req.Attachments) == 0 {
 		http.Error(w, "Message is required", http.StatusBadRequest)
 		return
 	}
+	thinking, err := req.thinkingLevel()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Get LLM service for the requested model
 	modelID := req.Model
@@ -727,72 +922,36 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create new conversation with optional cwd
-	var cwdPtr *string
-	if req.Cwd != "" {
-		cwdPtr = &req.Cwd
-	}
-	conversation, err := s.db.CreateConversation(ctx, nil, true, cwdPtr, &modelID)
-	if err != nil {
-		s.logger.Error("Failed to create conversation", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	if req.Sandbox != "" {
+		if code, msg := s.validateSandboxMode(ctx, req.Sandbox); code != 0 {
+			http.Error(w, msg, code)
+			return
+		}
 	}
-	conversationID := conversation.ConversationID
-
-	// Notify conversation list subscribers about the new conversation
-	go s.publishConversationListUpdate(ConversationListUpdate{
-		Type:         "update",
-		Conversation: conversation,
-	})
 
-	userEmail := r.Header.Get("X-ExeDev-Email")
-
-	// Get or create conversation manager
-	manager, err := s.getOrCreateConversationManager(ctx, conversationID, userEmail)
-	if errors.Is(err, errConversationModelMismatch) {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	attachments, code, msg := attachmentContents(req.Attachments, llmService)
+	if code != 0 {
+		http.Error(w, msg, code)
 		return
 	}
+
+	conversation, err := s.createConversation(ctx, req, modelID, thinking)
 	if err != nil {
-		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	conversationID := conversation.ConversationID
 
-	// Create user message
-	userMessage := llm.Message{
-		Role: llm.MessageRoleUser,
-		Content: []llm.Content{
-			{Type: llm.ContentTypeText, Text: req.Message},
-		},
-	}
-
-	firstMessage, err := manager.AcceptUserMessage(ctx, llmService, modelID, userMessage)
+	err = s.acceptUserMessage(ctx, conversationID, r.Header.Get("X-ExeDev-Email"), llmService, modelID, req.Message, attachments, nil)
 	if errors.Is(err, errConversationModelMismatch) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	if err != nil {
-		s.logger.Error("Failed to accept user message", "conversationID", conversationID, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	if firstMessage {
-		ctxNoCancel := context.WithoutCancel(ctx)
-		go func() {
-			slugCtx, cancel := context.WithTimeout(ctxNoCancel, 15*time.Second)
-			defer cancel()
-			_, err := slug.GenerateSlug(slugCtx, s.llmManager, s.db, s.logger, conversationID, req.Message, modelID)
-			if err != nil {
-				s.logger.Warn("Failed to generate slug for conversation", "conversationID", conversationID, "error", err)
-			} else {
-				go s.notifySubscribers(ctxNoCancel, conversationID)
-			}
-		}()
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -918,6 +1077,7 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 	}
 
 	// Send initial response (all messages for fresh connections, missed messages for resumes)
+	state := manager.State()
 	if len(messages) > 0 {
 		apiMessages := toAPIMessages(messages)
 		// Only send context_window_size for fresh connections where we have all messages.
@@ -928,13 +1088,9 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 			ctxSize = calculateContextWindowSize(apiMessages)
 		}
 		streamData := StreamResponse{
-			Messages:     apiMessages,
-			Conversation: conversation,
-			ConversationState: &ConversationState{
-				ConversationID: conversationID,
-				Working:        manager.IsAgentWorking(),
-				Model:          manager.GetModel(),
-			},
+			Messages:          apiMessages,
+			Conversation:      conversation,
+			ConversationState: &state,
 			ContextWindowSize: ctxSize,
 		}
 		data, _ := json.Marshal(streamData)
@@ -943,13 +1099,9 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 	} else {
 		// Either resuming or no messages yet - send current state as heartbeat
 		streamData := StreamResponse{
-			Conversation: conversation,
-			ConversationState: &ConversationState{
-				ConversationID: conversationID,
-				Working:        manager.IsAgentWorking(),
-				Model:          manager.GetModel(),
-			},
-			Heartbeat: true,
+			Conversation:      conversation,
+			ConversationState: &state,
+			Heartbeat:         true,
 		}
 		data, _ := json.Marshal(streamData)
 		fmt.Fprintf(w, "data: %s\n\n", data)
@@ -957,7 +1109,7 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 	}
 
 	// Subscribe to new messages after the last one we sent
-	next := manager.subpub.Subscribe(ctx, lastSeqID)
+	next := manager.subscribe(ctx, lastSeqID)
 
 	// Start heartbeat goroutine - sends state every 30 seconds if no other messages
 	heartbeatDone := make(chan struct{})
@@ -983,13 +1135,9 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 				}
 
 				heartbeat := StreamResponse{
-					Conversation: conv,
-					ConversationState: &ConversationState{
-						ConversationID: conversationID,
-						Working:        manager.IsAgentWorking(),
-						Model:          manager.GetModel(),
-					},
-					Heartbeat: true,
+					Conversation:      conv,
+					ConversationState: &state,
+					Heartbeat:         true,
 				}
 				manager.subpub.Broadcast(heartbeat)
 			}
@@ -1126,6 +1274,7 @@ func (s *Server) handleArchiveConversation(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	s.stopConversations(s.withSubagents(ctx, conversationID))
 
 	// Notify conversation list subscribers
 	go s.publishConversationListUpdate(ConversationListUpdate{
@@ -1170,11 +1319,14 @@ func (s *Server) handleDeleteConversation(w http.ResponseWriter, r *http.Request
 	}
 
 	ctx := r.Context()
+	stopped := s.withSubagents(ctx, conversationID)
+	s.deleteCheckpointRefs(ctx, conversationID)
 	if err := s.db.DeleteConversation(ctx, conversationID); err != nil {
 		s.logger.Error("Failed to delete conversation", "conversationID", conversationID, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	s.stopConversations(stopped)
 
 	// Notify conversation list subscribers about the deletion
 	go s.publishConversationListUpdate(ConversationListUpdate{
@@ -1379,7 +1531,11 @@ func (s *Server) handleSetSetting(w http.ResponseWriter, r *http.Request) {
 
 	// Only allow known setting keys
 	allowedKeys := map[string]bool{
-		"auto_upgrade": true,
+		"auto_upgrade":            true,
+		"auto_compact_threshold":  true,
+		settingBudgetConversation: true,
+		settingBudgetDaily:        true,
+		settingCheckpointWorktree: true,
 	}
 	if !allowedKeys[req.Key] {
 		http.Error(w, fmt.Sprintf("Invalid setting key: %s", req.Key), http.StatusBadRequest)
@@ -3,6 +3,9 @@ package server
 import (
 	"log/slog"
 
+	"shelley.exe.dev/claudetool/mcp"
+	"shelley.exe.dev/claudetool/permission"
+	"shelley.exe.dev/claudetool/sandbox"
 	"shelley.exe.dev/db"
 )
 
@@ -37,6 +40,29 @@ type LLMConfig struct {
 	// Each entry is a map with at least a "type" key, plus channel-specific fields.
 	NotificationChannels []map[string]any
 
+	// Budgets are the default spending budgets from shelley.json (optional).
+	Budgets BudgetConfig
+
+	// Permissions is the server-wide tool permission policy from shelley.json (optional).
+	Permissions permission.Policy
+
+	// Sandbox configures how tools run commands, from shelley.json (optional).
+	Sandbox sandbox.Config
+
+	// MCPServers are the MCP servers from shelley.json (optional).
+	MCPServers []mcp.ServerConfig
+
+	// ModelFallbacks overrides model fallback chains, keyed by model ID (optional).
+	ModelFallbacks map[string][]string
+
+	// LocalModels are base URLs of self-hosted OpenAI-compatible servers whose
+	// models are discovered and offered automatically (optional).
+	LocalModels []string
+
+	// ServerTools names the provider-run tools to offer models that support
+	// them, such as "web_search" (optional).
+	ServerTools []string
+
 	// DB is the database for recording LLM requests (optional)
 	DB *db.DB
 
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"shelley.exe.dev/claudetool/mcp"
+	"shelley.exe.dev/db/generated"
+)
+
+// SetMCPServers sets the MCP servers from shelley.json. Servers added through
+// the API replace those of the same name.
+func (s *Server) SetMCPServers(servers []mcp.ServerConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mcpServers = servers
+}
+
+// MCPServerAPI is an MCP server as listed by /api/mcp-servers.
+type MCPServerAPI struct {
+	Name string `json:"name"`
+	// Source is "config" for servers from shelley.json and "database" for
+	// those added through the API.
+	Source  string `json:"source"`
+	Enabled bool   `json:"enabled"`
+	// Config has the values of environment variables and headers redacted.
+	Config mcp.ServerConfig `json:"config"`
+}
+
+// SaveMCPServerRequest is the request body for POST /api/mcp-servers.
+type SaveMCPServerRequest struct {
+	Enabled bool             `json:"enabled"`
+	Config  mcp.ServerConfig `json:"config"`
+}
+
+// listMCPServers returns every configured server, enabled or not, by name.
+func (s *Server) listMCPServers(ctx context.Context) ([]MCPServerAPI, error) {
+	rows, err := s.db.GetMCPServers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var servers []MCPServerAPI
+	for _, row := range rows {
+		var cfg mcp.ServerConfig
+		if err := json.Unmarshal([]byte(row.Config), &cfg); err != nil {
+			return nil, fmt.Errorf("MCP server %q: %w", row.Name, err)
+		}
+		cfg.Name = row.Name
+		servers = append(servers, MCPServerAPI{Name: row.Name, Source: "database", Enabled: row.Enabled != 0, Config: cfg})
+	}
+	s.mu.Lock()
+	for _, cfg := range s.mcpServers {
+		if !slices.ContainsFunc(servers, func(srv MCPServerAPI) bool { return srv.Name == cfg.Name }) {
+			servers = append(servers, MCPServerAPI{Name: cfg.Name, Source: "config", Enabled: true, Config: cfg})
+		}
+	}
+	s.mu.Unlock()
+	slices.SortFunc(servers, func(a, b MCPServerAPI) int { return strings.Compare(a.Name, b.Name) })
+	return servers, nil
+}
+
+// mcpServerConfigs returns the servers conversations connect to when their
+// loops start. If the database can't be read, it falls back to shelley.json.
+func (s *Server) mcpServerConfigs(ctx context.Context) []mcp.ServerConfig {
+	servers, err := s.listMCPServers(ctx)
+	if err != nil {
+		s.logger.Error("Failed to get MCP servers", "error", err)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return slices.Clone(s.mcpServers)
+	}
+	var configs []mcp.ServerConfig
+	for _, srv := range servers {
+		if srv.Enabled {
+			configs = append(configs, srv.Config)
+		}
+	}
+	return configs
+}
+
+func (s *Server) handleMCPServers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		servers, err := s.listMCPServers(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get MCP servers: %v", err), http.StatusInternalServerError)
+			return
+		}
+		for i := range servers {
+			servers[i].Config = servers[i].Config.Redacted()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(append([]MCPServerAPI{}, servers...))
+	case http.MethodPost:
+		s.handleSaveMCPServer(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSaveMCPServer adds or replaces a server. Conversations connect to it
+// when their loops next start.
+func (s *Server) handleSaveMCPServer(w http.ResponseWriter, r *http.Request) {
+	var req SaveMCPServerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := req.Config.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := req.Config.Name
+	req.Config.Name = "" // the name column holds it
+	configJSON, err := json.Marshal(req.Config)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid config: %v", err), http.StatusBadRequest)
+		return
+	}
+	var enabled int64
+	if req.Enabled {
+		enabled = 1
+	}
+	if _, err := s.db.UpsertMCPServer(r.Context(), generated.UpsertMCPServerParams{
+		Name:    name,
+		Enabled: enabled,
+		Config:  string(configJSON),
+	}); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save MCP server: %v", err), http.StatusInternalServerError)
+		return
+	}
+	req.Config.Name = name
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MCPServerAPI{Name: name, Source: "database", Enabled: req.Enabled, Config: req.Config.Redacted()})
+}
+
+// handleMCPServer handles DELETE /api/mcp-servers/<name>. Deleting a server
+// from the database uncovers one of the same name from shelley.json, if any.
+func (s *Server) handleMCPServer(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/mcp-servers/")
+	if name == "" || strings.Contains(name, "/") {
+		http.Error(w, "Invalid server name", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.db.DeleteMCPServer(r.Context(), name); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete MCP server: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleConversationMCP handles GET /conversation/<id>/mcp, reporting on the
+// servers the conversation's tools are connected to. A conversation without
+// a running loop has none.
+func (s *Server) handleConversationMCP(w http.ResponseWriter, r *http.Request, conversationID string) {
+	statuses := []mcp.Status{}
+	s.mu.Lock()
+	manager := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if manager != nil {
+		manager.mu.Lock()
+		toolSet := manager.toolSet
+		manager.mu.Unlock()
+		if toolSet != nil && toolSet.MCP() != nil {
+			statuses = toolSet.MCP().Status(r.Context())
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
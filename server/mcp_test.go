@@ -0,0 +1,173 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/claudetool/mcp"
+)
+
+func TestMCPServersAPI(t *testing.T) {
+	h := NewTestHarness(t)
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	h.server.SetMCPServers([]mcp.ServerConfig{
+		{Name: "docs", URL: "http://localhost:1/mcp", Headers: map[string]string{"Authorization": "Bearer secret"}},
+		{Name: "files", Command: "mcp-files"},
+	})
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	list := func() []MCPServerAPI {
+		t.Helper()
+		w := serve("GET", "/api/mcp-servers", "")
+		var servers []MCPServerAPI
+		if err := json.Unmarshal(w.Body.Bytes(), &servers); err != nil {
+			t.Fatalf("failed to parse MCP servers: %v: %s", err, w.Body.String())
+		}
+		return servers
+	}
+
+	servers := list()
+	if len(servers) != 2 || servers[0].Name != "docs" || servers[0].Source != "config" {
+		t.Fatalf("unexpected servers: %+v", servers)
+	}
+	if got := servers[0].Config.Headers["Authorization"]; got != "***" {
+		t.Errorf("expected headers to be redacted, got %q", got)
+	}
+
+	if w := serve("POST", "/api/mcp-servers", `{"enabled":true,"config":{"name":"bad"}}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a server with neither command nor url, got %d", w.Code)
+	}
+	// A server from the database replaces the one of the same name from shelley.json.
+	if w := serve("POST", "/api/mcp-servers", `{"enabled":false,"config":{"name":"files","command":"other-files"}}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 saving a server, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve("POST", "/api/mcp-servers", `{"enabled":true,"config":{"name":"git","command":"mcp-git","args":["--repo","."]}}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 saving a server, got %d", w.Code)
+	}
+	servers = list()
+	if len(servers) != 3 || servers[1].Source != "database" || servers[1].Enabled || servers[1].Config.Command != "other-files" {
+		t.Fatalf("unexpected servers: %+v", servers)
+	}
+	var names []string
+	for _, cfg := range h.server.mcpServerConfigs(t.Context()) {
+		names = append(names, cfg.Name)
+	}
+	if strings.Join(names, ",") != "docs,git" {
+		t.Errorf("expected the enabled servers, got %q", names)
+	}
+
+	if w := serve("DELETE", "/api/mcp-servers/files", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 deleting a server, got %d", w.Code)
+	}
+	if servers = list(); servers[1].Name != "files" || servers[1].Source != "config" {
+		t.Fatalf("expected deleting to uncover the server from shelley.json, got %+v", servers)
+	}
+}
+
+func TestConversationMCPStatus(t *testing.T) {
+	h := NewTestHarness(t)
+	h.server.SetMCPServers([]mcp.ServerConfig{{Name: "broken", Command: "/nonexistent/mcp-server"}})
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+
+	w := httptest.NewRecorder()
+	h.server.conversationMux().ServeHTTP(w, httptest.NewRequest("GET", "/"+h.convID+"/mcp", nil))
+	var statuses []mcp.Status
+	if err := json.Unmarshal(w.Body.Bytes(), &statuses); err != nil {
+		t.Fatalf("failed to parse statuses: %v: %s", err, w.Body.String())
+	}
+	if len(statuses) != 1 || statuses[0].Name != "broken" || statuses[0].Connected || statuses[0].Error == "" {
+		t.Fatalf("expected the server that failed to start, got %+v", statuses)
+	}
+
+	// Archiving the conversation disconnects its servers.
+	w = httptest.NewRecorder()
+	h.server.conversationMux().ServeHTTP(w, httptest.NewRequest("POST", "/"+h.convID+"/archive", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 archiving, got %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	h.server.conversationMux().ServeHTTP(w, httptest.NewRequest("GET", "/"+h.convID+"/mcp", nil))
+	if strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("expected no servers once archived, got %s", w.Body.String())
+	}
+}
+
+func TestMCPEndpoint(t *testing.T) {
+	h := NewTestHarness(t)
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := t.Context()
+	c, err := mcp.Connect(ctx, mcp.ServerConfig{Name: "shelley", URL: srv.URL + "/api/mcp"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	tools, err := c.ListTools(ctx)
+	if err != nil || len(tools) != 7 {
+		t.Fatalf("ListTools = %d tools, %v", len(tools), err)
+	}
+
+	call := func(name, args string, v any) {
+		t.Helper()
+		res, err := c.CallTool(ctx, name, json.RawMessage(args))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if res.IsError {
+			t.Fatalf("%s failed: %s", name, res.Content[0].Text)
+		}
+		if err := json.Unmarshal([]byte(res.Content[0].Text), v); err != nil {
+			t.Fatalf("%s: %v: %s", name, err, res.Content[0].Text)
+		}
+	}
+
+	var turn MCPTurn
+	call("new_conversation", `{"message":"echo: hello","model":"predictable","cwd":"`+t.TempDir()+`","wait":true}`, &turn)
+	if turn.ConversationID == "" || turn.Working || turn.Response != "hello" {
+		t.Fatalf("unexpected turn %+v", turn)
+	}
+	call("send_message", `{"conversation_id":"`+turn.ConversationID+`","message":"echo: again","wait":true}`, &turn)
+	if turn.Working || turn.Response != "again" {
+		t.Fatalf("unexpected turn %+v", turn)
+	}
+
+	var messages []MCPMessage
+	call("read_messages", `{"conversation_id":"`+turn.ConversationID+`"}`, &messages)
+	if len(messages) < 4 || messages[len(messages)-1].Text != "again" || !messages[len(messages)-1].EndOfTurn {
+		t.Fatalf("unexpected messages %+v", messages)
+	}
+	var since []MCPMessage
+	call("read_messages", `{"conversation_id":"`+turn.ConversationID+`","since_sequence_id":`+fmt.Sprint(messages[len(messages)-2].SequenceID)+`}`, &since)
+	if len(since) != 1 {
+		t.Errorf("expected the last message, got %+v", since)
+	}
+
+	var conversations []MCPConversation
+	call("list_conversations", `{}`, &conversations)
+	if len(conversations) != 1 || conversations[0].ConversationID != turn.ConversationID || conversations[0].Model != "predictable" {
+		t.Errorf("unexpected conversations %+v", conversations)
+	}
+	var subagents []MCPConversation
+	call("list_subagents", `{"conversation_id":"`+turn.ConversationID+`"}`, &subagents)
+	if len(subagents) != 0 {
+		t.Errorf("expected no subagents, got %+v", subagents)
+	}
+
+	res, err := c.CallTool(ctx, "send_message", json.RawMessage(`{"conversation_id":"nope","message":"hi"}`))
+	if err != nil || !res.IsError {
+		t.Errorf("expected a failed tool call for an unknown conversation, got %+v, %v", res, err)
+	}
+}
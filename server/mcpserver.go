@@ -0,0 +1,402 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/claudetool/mcp"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/models"
+	"shelley.exe.dev/version"
+)
+
+// Bounds on how long MCP tools wait for the agent to finish its turn.
+const (
+	mcpDefaultWait = 2 * time.Minute
+	mcpMaxWait     = time.Hour
+)
+
+// handleMCP handles POST /api/mcp, which serves Shelley's conversations as
+// tools to MCP clients such as other agents. "shelley mcp" bridges it to
+// stdio.
+func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
+	s.mcpHandler(r.Header.Get("X-ExeDev-Email")).ServeHTTP(w, r)
+}
+
+func (s *Server) mcpHandler(userEmail string) *mcp.Handler {
+	conversationID := mcpProperty{"conversation_id", "string", "The conversation's ID."}
+	message := mcpProperty{"message", "string", "The message to send to the agent."}
+	wait := mcpProperty{"wait", "boolean", "Wait for the agent to finish its turn and return its reply."}
+	timeout := mcpProperty{"timeout_seconds", "integer", "How long to wait, in seconds. Defaults to 120."}
+	return &mcp.Handler{
+		Name:    "shelley",
+		Version: version.Version,
+		Instructions: "Shelley is a coding agent. Start a conversation with a task, then follow it with " +
+			"wait_for_turn or read_messages. The agent works in the conversation's directory.",
+		Tools: []mcp.ServerTool{
+			{
+				Tool: mcpTool("new_conversation", "Start a conversation with the agent.", false,
+					[]mcpProperty{message, {"model", "string", "The model to use. Defaults to the server's."},
+						{"cwd", "string", "The directory the agent works in."}, wait, timeout}, "message"),
+				Handle: func(ctx context.Context, args json.RawMessage) (*mcp.CallToolResult, error) {
+					return s.mcpNewConversation(ctx, userEmail, args)
+				},
+			},
+			{
+				Tool: mcpTool("send_message", "Send a message to the agent in an existing conversation.", false,
+					[]mcpProperty{conversationID, message, wait, timeout}, "conversation_id", "message"),
+				Handle: func(ctx context.Context, args json.RawMessage) (*mcp.CallToolResult, error) {
+					return s.mcpSendMessage(ctx, userEmail, args)
+				},
+			},
+			{
+				Tool: mcpTool("wait_for_turn", "Wait for the agent to finish its turn, returning its reply.", true,
+					[]mcpProperty{conversationID, timeout}, "conversation_id"),
+				Handle: s.mcpWaitForTurn,
+			},
+			{
+				Tool: mcpTool("cancel_conversation", "Stop the agent's current turn.", false,
+					[]mcpProperty{conversationID}, "conversation_id"),
+				Handle: s.mcpCancelConversation,
+			},
+			{
+				Tool: mcpTool("list_conversations", "List conversations, most recent first.", true,
+					[]mcpProperty{{"query", "string", "Only list conversations whose names contain this."},
+						{"archived", "boolean", "List archived conversations instead."},
+						{"limit", "integer", "The most conversations to list. Defaults to 50."}}),
+				Handle: s.mcpListConversations,
+			},
+			{
+				Tool: mcpTool("read_messages", "Read a conversation's messages.", true,
+					[]mcpProperty{conversationID, {"since_sequence_id", "integer", "Only read messages after this one."}},
+					"conversation_id"),
+				Handle: s.mcpReadMessages,
+			},
+			{
+				Tool: mcpTool("list_subagents", "List the subagent conversations a conversation started.", true,
+					[]mcpProperty{conversationID}, "conversation_id"),
+				Handle: s.mcpListSubagents,
+			},
+		},
+	}
+}
+
+// mcpProperty describes a tool argument.
+type mcpProperty struct {
+	name, typ, description string
+}
+
+func mcpTool(name, description string, readOnly bool, props []mcpProperty, required ...string) mcp.Tool {
+	properties := make(map[string]any, len(props))
+	for _, p := range props {
+		properties[p.name] = map[string]string{"type": p.typ, "description": p.description}
+	}
+	schema, _ := json.Marshal(map[string]any{"type": "object", "properties": properties, "required": append([]string{}, required...)})
+	tool := mcp.Tool{Name: name, Description: description, InputSchema: schema}
+	tool.Annotations.ReadOnlyHint = readOnly
+	return tool
+}
+
+// mcpJSON returns a tool result holding v as JSON.
+func mcpJSON(v any) (*mcp.CallToolResult, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.TextResult(string(data)), nil
+}
+
+// MCPTurn is the result of the MCP tools that talk to the agent.
+type MCPTurn struct {
+	ConversationID string `json:"conversation_id"`
+	// Working is true if the agent is still busy: the caller didn't wait,
+	// or the wait timed out.
+	Working bool `json:"working"`
+	// Response is the agent's last reply, once it finishes its turn.
+	Response string `json:"response,omitempty"`
+}
+
+type mcpChatArgs struct {
+	ConversationID string `json:"conversation_id"`
+	Message        string `json:"message"`
+	Model          string `json:"model"`
+	Cwd            string `json:"cwd"`
+	Wait           bool   `json:"wait"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+func (s *Server) mcpNewConversation(ctx context.Context, userEmail string, args json.RawMessage) (*mcp.CallToolResult, error) {
+	var req mcpChatArgs
+	if err := json.Unmarshal(args, &req); err != nil {
+		return nil, err
+	}
+	if req.Message == "" {
+		return nil, errors.New("message is required")
+	}
+	modelID := s.mcpModel(req.Model, nil)
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported model: %s", modelID)
+	}
+	conversation, err := s.createConversation(ctx, ChatRequest{Message: req.Message, Cwd: req.Cwd}, modelID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conversation: %w", err)
+	}
+	req.ConversationID = conversation.ConversationID
+	if err := s.acceptUserMessage(ctx, req.ConversationID, userEmail, llmService, modelID, req.Message, nil, nil); err != nil {
+		return nil, err
+	}
+	return s.mcpFinishTurn(ctx, req)
+}
+
+func (s *Server) mcpSendMessage(ctx context.Context, userEmail string, args json.RawMessage) (*mcp.CallToolResult, error) {
+	var req mcpChatArgs
+	if err := json.Unmarshal(args, &req); err != nil {
+		return nil, err
+	}
+	if req.Message == "" {
+		return nil, errors.New("message is required")
+	}
+	conversation, err := s.db.GetConversationByID(ctx, req.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("conversation %q not found", req.ConversationID)
+	}
+	modelID := s.mcpModel("", conversation.Model)
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported model: %s", modelID)
+	}
+	if err := s.acceptUserMessage(ctx, req.ConversationID, userEmail, llmService, modelID, req.Message, nil, nil); err != nil {
+		return nil, err
+	}
+	return s.mcpFinishTurn(ctx, req)
+}
+
+// mcpModel picks the model for a message: the requested one, the
+// conversation's, or the server's default.
+func (s *Server) mcpModel(requested string, conversationModel *string) string {
+	switch {
+	case requested != "":
+		return requested
+	case conversationModel != nil && *conversationModel != "":
+		return *conversationModel
+	case s.defaultModel != "":
+		return s.defaultModel
+	}
+	return models.Default().ID
+}
+
+// mcpFinishTurn reports on a message just sent, waiting for the agent's
+// reply if asked to.
+func (s *Server) mcpFinishTurn(ctx context.Context, req mcpChatArgs) (*mcp.CallToolResult, error) {
+	if !req.Wait {
+		return mcpJSON(MCPTurn{ConversationID: req.ConversationID, Working: true})
+	}
+	turn, err := s.mcpWait(ctx, req.ConversationID, req.TimeoutSeconds)
+	if err != nil {
+		return nil, err
+	}
+	return mcpJSON(turn)
+}
+
+func (s *Server) mcpWait(ctx context.Context, conversationID string, timeoutSeconds int) (MCPTurn, error) {
+	timeout := mcpDefaultWait
+	if timeoutSeconds > 0 {
+		timeout = time.Duration(timeoutSeconds) * time.Second
+	}
+	if timeout > mcpMaxWait {
+		timeout = mcpMaxWait
+	}
+	turn := MCPTurn{ConversationID: conversationID}
+	done, err := s.waitForAgent(ctx, conversationID, timeout)
+	if err != nil {
+		return turn, err
+	}
+	if !done {
+		turn.Working = true
+		return turn, nil
+	}
+	turn.Response, err = (&SubagentRunner{server: s}).getLastAssistantResponse(ctx, conversationID)
+	return turn, err
+}
+
+func (s *Server) mcpWaitForTurn(ctx context.Context, args json.RawMessage) (*mcp.CallToolResult, error) {
+	var req mcpChatArgs
+	if err := json.Unmarshal(args, &req); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.GetConversationByID(ctx, req.ConversationID); err != nil {
+		return nil, fmt.Errorf("conversation %q not found", req.ConversationID)
+	}
+	turn, err := s.mcpWait(ctx, req.ConversationID, req.TimeoutSeconds)
+	if err != nil {
+		return nil, err
+	}
+	return mcpJSON(turn)
+}
+
+func (s *Server) mcpCancelConversation(ctx context.Context, args json.RawMessage) (*mcp.CallToolResult, error) {
+	var req mcpChatArgs
+	if err := json.Unmarshal(args, &req); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	manager, exists := s.activeConversations[req.ConversationID]
+	s.mu.Unlock()
+	if !exists {
+		return mcp.TextResult("The agent isn't working in that conversation."), nil
+	}
+	if err := manager.CancelConversation(ctx); err != nil {
+		s.logger.Error("Failed to cancel conversation", "conversationID", req.ConversationID, "error", err)
+		return nil, fmt.Errorf("failed to cancel conversation: %w", err)
+	}
+	s.logger.Info("Conversation cancelled", "conversationID", req.ConversationID)
+	return mcp.TextResult("Cancelled."), nil
+}
+
+// MCPConversation is a conversation as listed by the MCP tools.
+type MCPConversation struct {
+	ConversationID string    `json:"conversation_id"`
+	Slug           string    `json:"slug,omitempty"`
+	Cwd            string    `json:"cwd,omitempty"`
+	Model          string    `json:"model,omitempty"`
+	Working        bool      `json:"working"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (s *Server) mcpConversations(conversations []generated.Conversation) []MCPConversation {
+	working := s.getWorkingConversations()
+	result := make([]MCPConversation, len(conversations))
+	for i, c := range conversations {
+		result[i] = MCPConversation{
+			ConversationID: c.ConversationID,
+			Working:        working[c.ConversationID],
+			UpdatedAt:      c.UpdatedAt,
+		}
+		if c.Slug != nil {
+			result[i].Slug = *c.Slug
+		}
+		if c.Cwd != nil {
+			result[i].Cwd = *c.Cwd
+		}
+		if c.Model != nil {
+			result[i].Model = *c.Model
+		}
+	}
+	return result
+}
+
+func (s *Server) mcpListConversations(ctx context.Context, args json.RawMessage) (*mcp.CallToolResult, error) {
+	var req struct {
+		Query    string `json:"query"`
+		Archived bool   `json:"archived"`
+		Limit    int64  `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &req); err != nil {
+		return nil, err
+	}
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+	var conversations []generated.Conversation
+	var err error
+	switch {
+	case req.Archived && req.Query != "":
+		conversations, err = s.db.SearchArchivedConversations(ctx, req.Query, req.Limit, 0)
+	case req.Archived:
+		conversations, err = s.db.ListArchivedConversations(ctx, req.Limit, 0)
+	case req.Query != "":
+		conversations, err = s.db.SearchConversations(ctx, req.Query, req.Limit, 0)
+	default:
+		conversations, err = s.db.ListConversations(ctx, req.Limit, 0)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversations: %w", err)
+	}
+	return mcpJSON(s.mcpConversations(conversations))
+}
+
+func (s *Server) mcpListSubagents(ctx context.Context, args json.RawMessage) (*mcp.CallToolResult, error) {
+	var req mcpChatArgs
+	if err := json.Unmarshal(args, &req); err != nil {
+		return nil, err
+	}
+	subagents, err := s.db.GetSubagents(ctx, req.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subagents: %w", err)
+	}
+	return mcpJSON(s.mcpConversations(subagents))
+}
+
+// MCPMessage is a conversation message as read by the MCP tools, reduced to
+// its text, like the messages "shelley client read" prints.
+type MCPMessage struct {
+	SequenceID int64  `json:"sequence_id"`
+	Type       string `json:"type"`
+	Text       string `json:"text,omitempty"`
+	ToolName   string `json:"tool_name,omitempty"`
+	EndOfTurn  bool   `json:"end_of_turn"`
+}
+
+func (s *Server) mcpReadMessages(ctx context.Context, args json.RawMessage) (*mcp.CallToolResult, error) {
+	var req struct {
+		ConversationID  string `json:"conversation_id"`
+		SinceSequenceID int64  `json:"since_sequence_id"`
+	}
+	if err := json.Unmarshal(args, &req); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.GetConversationByID(ctx, req.ConversationID); err != nil {
+		return nil, fmt.Errorf("conversation %q not found", req.ConversationID)
+	}
+	messages, err := s.db.ListMessages(ctx, req.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	result := []MCPMessage{}
+	for _, msg := range messages {
+		if msg.SequenceID > req.SinceSequenceID {
+			result = append(result, simplifyMCPMessage(msg))
+		}
+	}
+	return mcpJSON(result)
+}
+
+func simplifyMCPMessage(msg generated.Message) MCPMessage {
+	out := MCPMessage{SequenceID: msg.SequenceID, Type: msg.Type}
+	if msg.LlmData == nil {
+		return out
+	}
+	var llmMsg llm.Message
+	if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil {
+		return out
+	}
+	out.EndOfTurn = llmMsg.EndOfTurn
+	var texts []string
+	for _, c := range llmMsg.Content {
+		switch c.Type {
+		case llm.ContentTypeText:
+			if c.Text != "" {
+				texts = append(texts, c.Text)
+			}
+		case llm.ContentTypeToolUse:
+			if out.ToolName == "" {
+				out.ToolName = c.ToolName
+			}
+		case llm.ContentTypeToolResult:
+			for _, r := range c.ToolResult {
+				if r.Text != "" {
+					texts = append(texts, r.Text)
+				}
+			}
+		}
+	}
+	out.Text = strings.Join(texts, "\n")
+	return out
+}
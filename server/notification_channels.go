@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"shelley.exe.dev/db"
 	"shelley.exe.dev/db/generated"
 	"shelley.exe.dev/server/notifications"
 )
@@ -66,6 +69,31 @@ var channelTypeInfo = map[string]ChannelTypeInfo{
 			{Name: "to", Label: "Recipient Email", Type: "string", Required: true, Placeholder: "you@example.com"},
 		},
 	},
+	"smtp": {
+		Type:  "smtp",
+		Label: "Email (SMTP)",
+		ConfigFields: []ConfigField{
+			{Name: "host", Label: "SMTP Server", Type: "string", Required: true, Placeholder: "smtp.example.com"},
+			{Name: "security", Label: "Security", Type: "string", Required: true, Default: "starttls", Options: []string{"starttls", "tls", "none"}, Description: "starttls usually uses port 587, tls port 465. Use none only for local relays."},
+			{Name: "port", Label: "Port", Type: "string", Placeholder: "587", Description: "Optional. Defaults to the usual port for the security mode."},
+			{Name: "username", Label: "Username", Type: "string", Description: "Optional. Leave empty if the server doesn't require authentication."},
+			{Name: "password", Label: "Password", Type: "password"},
+			{Name: "from", Label: "From", Type: "string", Required: true, Placeholder: "Shelley <shelley@example.com>"},
+			{Name: "to", Label: "To", Type: "string", Required: true, Placeholder: "you@example.com", Description: "Comma-separated for several recipients."},
+		},
+	},
+	"webhook": {
+		Type:  "webhook",
+		Label: "Webhook",
+		ConfigFields: []ConfigField{
+			{Name: "url", Label: "URL", Type: "string", Required: true, Placeholder: "https://hooks.slack.com/services/..."},
+			{Name: "method", Label: "Method", Type: "string", Required: true, Default: "POST", Options: []string{"POST", "PUT", "PATCH", "GET"}},
+			{Name: "headers", Label: "Headers", Type: "text", Placeholder: "Authorization: Bearer ...", Description: "Optional. One \"Name: Value\" per line."},
+			{Name: "secret", Label: "Signing Secret", Type: "password", Description: "Optional. Signs the body with HMAC-SHA256 in the X-Shelley-Signature header, as sha256=<hex>."},
+			{Name: "body", Label: "Body Template", Type: "text", Placeholder: `{"text": {{json .Title}}}`, Description: "Optional Go text/template, given .Type, .ConversationID, .Timestamp, .Payload, .Title and .Message; json encodes a value as JSON. By default, the event is sent as JSON."},
+			{Name: "content_type", Label: "Content Type", Type: "string", Default: "application/json"},
+		},
+	},
 	"ntfy": {
 		Type:  "ntfy",
 		Label: "ntfy",
@@ -166,6 +194,10 @@ func (s *Server) handleCreateNotificationChannel(w http.ResponseWriter, r *http.
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if _, err := notifications.ParseFilter(validationConfig); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	channelID := "notif-" + uuid.New().String()[:8]
 	var enabled int64
@@ -199,6 +231,16 @@ func (s *Server) handleNotificationChannel(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if strings.HasSuffix(path, "/deliveries") {
+		channelID := strings.TrimSuffix(path, "/deliveries")
+		if r.Method == http.MethodGet {
+			s.handleNotificationDeliveries(w, r, channelID)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
 	if strings.HasSuffix(path, "/test") {
 		channelID := strings.TrimSuffix(path, "/test")
 		if r.Method == http.MethodPost {
@@ -269,6 +311,10 @@ func (s *Server) handleUpdateNotificationChannel(w http.ResponseWriter, r *http.
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if _, err := notifications.ParseFilter(validationConfig); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	var enabled int64
 	if req.Enabled {
@@ -358,6 +404,57 @@ func (s *Server) handleTestNotificationChannel(w http.ResponseWriter, r *http.Re
 	})
 }
 
+// NotificationDeliveryAPI is one entry in a channel's delivery log.
+type NotificationDeliveryAPI struct {
+	DeliveryID     int64     `json:"delivery_id"`
+	EventType      string    `json:"event_type"`
+	ConversationID *string   `json:"conversation_id,omitempty"`
+	Status         string    `json:"status"`
+	Attempts       int64     `json:"attempts"`
+	LastError      *string   `json:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// handleNotificationDeliveries returns a channel's most recent deliveries.
+func (s *Server) handleNotificationDeliveries(w http.ResponseWriter, r *http.Request, channelID string) {
+	limit := int64(50)
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.ParseInt(l, 10, 64)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n > 500 {
+			n = 500
+		}
+		limit = n
+	}
+
+	deliveries, err := s.db.ListNotificationDeliveries(r.Context(), channelID, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get notification deliveries: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]NotificationDeliveryAPI, len(deliveries))
+	for i, d := range deliveries {
+		result[i] = NotificationDeliveryAPI{
+			DeliveryID:     d.DeliveryID,
+			EventType:      d.EventType,
+			ConversationID: d.ConversationID,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			LastError:      d.LastError,
+			CreatedAt:      d.CreatedAt,
+			UpdatedAt:      d.UpdatedAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
 func (s *Server) handleNotificationChannelTypes(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -373,16 +470,30 @@ func (s *Server) getNotificationChannelTypes() []ChannelTypeInfo {
 	types := notifications.RegisteredTypes()
 	result := make([]ChannelTypeInfo, 0, len(types))
 	for _, t := range types {
-		if info, ok := channelTypeInfo[t]; ok {
-			result = append(result, info)
-		} else {
-			result = append(result, ChannelTypeInfo{Type: t, Label: t})
+		info, ok := channelTypeInfo[t]
+		if !ok {
+			info = ChannelTypeInfo{Type: t, Label: t}
 		}
+		info.ConfigFields = append(slices.Clip(info.ConfigFields), filterConfigFields()...)
+		result = append(result, info)
 	}
 	return result
 }
 
-// ReloadNotificationChannels reads enabled channels from DB and replaces the dispatcher's channel set.
+// filterConfigFields are the config fields every channel type has, read by
+// notifications.ParseFilter.
+func filterConfigFields() []ConfigField {
+	events := make([]string, len(notifications.EventTypes))
+	for i, e := range notifications.EventTypes {
+		events[i] = string(e)
+	}
+	return []ConfigField{
+		{Name: "events", Label: "Events", Type: "string", Placeholder: "agent_done, tool_approval", Description: "Optional. Comma-separated events to send; all events if empty. One of: " + strings.Join(events, ", ") + "."},
+		{Name: "conversations", Label: "Conversations", Type: "string", Description: "Optional. Comma-separated conversation IDs to send events for; all conversations if empty."},
+	}
+}
+
+// ReloadNotificationChannels reads enabled channels from DB and replaces the dispatcher's route set.
 func (s *Server) ReloadNotificationChannels() {
 	channels, err := s.db.GetEnabledNotificationChannels(context.Background())
 	if err != nil {
@@ -390,7 +501,7 @@ func (s *Server) ReloadNotificationChannels() {
 		return
 	}
 
-	var active []notifications.Channel
+	var active []notifications.Route
 	for _, dbCh := range channels {
 		config := map[string]any{"type": dbCh.ChannelType}
 		var extra map[string]any
@@ -404,10 +515,18 @@ func (s *Server) ReloadNotificationChannels() {
 			s.logger.Warn("Failed to create notification channel", "id", dbCh.ChannelID, "error", err)
 			continue
 		}
-		active = append(active, ch)
+		filter, err := notifications.ParseFilter(config)
+		if err != nil {
+			s.logger.Warn("Invalid notification channel filter", "id", dbCh.ChannelID, "error", err)
+			continue
+		}
+		active = append(active, notifications.Route{ID: dbCh.ChannelID, Channel: ch, Filter: filter})
 	}
 
-	s.notifDispatcher.ReplaceChannels(active)
+	s.notifDispatcher.ReplaceRoutes(active)
+	if err := s.db.DeleteOldNotificationDeliveries(context.Background()); err != nil {
+		s.logger.Warn("Failed to prune notification deliveries", "error", err)
+	}
 	s.logger.Info("Reloaded notification channels", "count", len(active))
 }
 
@@ -461,3 +580,37 @@ func (s *Server) SeedNotificationChannelsFromConfig(configs []map[string]any) {
 		s.logger.Info("Seeded notification channel from config", "type", typeName, "id", channelID)
 	}
 }
+
+// notificationDeliveryLog records notification deliveries in the database.
+type notificationDeliveryLog struct {
+	db *db.DB
+}
+
+func (l notificationDeliveryLog) CreateDelivery(ctx context.Context, channelID string, event notifications.Event) (int64, error) {
+	var conversationID *string
+	if event.ConversationID != "" {
+		conversationID = &event.ConversationID
+	}
+	d, err := l.db.CreateNotificationDelivery(ctx, generated.CreateNotificationDeliveryParams{
+		ChannelID:      channelID,
+		EventType:      string(event.Type),
+		ConversationID: conversationID,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return d.DeliveryID, nil
+}
+
+func (l notificationDeliveryLog) UpdateDelivery(ctx context.Context, deliveryID int64, status string, attempts int, lastError string) error {
+	var errPtr *string
+	if lastError != "" {
+		errPtr = &lastError
+	}
+	return l.db.UpdateNotificationDelivery(ctx, generated.UpdateNotificationDeliveryParams{
+		Status:     status,
+		Attempts:   int64(attempts),
+		LastError:  errPtr,
+		DeliveryID: deliveryID,
+	})
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"shelley.exe.dev/server/notifications"
+)
+
+// recordingChannel records the events sent to it.
+type recordingChannel struct {
+	mu     sync.Mutex
+	events []notifications.Event
+}
+
+func (c *recordingChannel) Name() string { return "recording" }
+
+func (c *recordingChannel) Send(ctx context.Context, event notifications.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, event)
+	return nil
+}
+
+var testRecorder = &recordingChannel{}
+
+func init() {
+	notifications.Register("recording", func(config map[string]any, logger *slog.Logger) (notifications.Channel, error) {
+		return testRecorder, nil
+	})
+}
+
+func TestNotificationChannelFilterAndDeliveries(t *testing.T) {
+	h := NewTestHarness(t)
+
+	create := func(config string) *httptest.ResponseRecorder {
+		body := `{"channel_type": "recording", "display_name": "Recorder", "enabled": true, "config": ` + config + `}`
+		req := httptest.NewRequest("POST", "/api/notification-channels", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.server.handleNotificationChannels(w, req)
+		return w
+	}
+	if w := create(`{"events": "agent_done, nope"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown event, got %d: %s", w.Code, w.Body.String())
+	}
+	w := create(`{"events": "agent_done"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("failed to create channel: %d: %s", w.Code, w.Body.String())
+	}
+	var ch NotificationChannelAPI
+	json.Unmarshal(w.Body.Bytes(), &ch)
+
+	h.NewConversation("echo: hi", "")
+	h.WaitResponse()
+	h.server.notifDispatcher.Dispatch(notifications.Event{Type: notifications.EventUpgradeAvailable})
+	h.server.notifDispatcher.Wait()
+
+	testRecorder.mu.Lock()
+	events := testRecorder.events
+	testRecorder.mu.Unlock()
+	if len(events) == 0 {
+		t.Fatal("expected an agent_done event")
+	}
+	for _, e := range events {
+		if e.Type != notifications.EventAgentDone {
+			t.Errorf("filtered channel got %s", e.Type)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/notification-channels/"+ch.ChannelID+"/deliveries", nil)
+	w = httptest.NewRecorder()
+	h.server.handleNotificationChannel(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("failed to list deliveries: %d: %s", w.Code, w.Body.String())
+	}
+	var deliveries []NotificationDeliveryAPI
+	if err := json.Unmarshal(w.Body.Bytes(), &deliveries); err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != len(events) {
+		t.Fatalf("expected %d deliveries, got %+v", len(events), deliveries)
+	}
+	d := deliveries[0]
+	if d.EventType != "agent_done" || d.Status != notifications.DeliveryDelivered || d.Attempts != 1 || d.ConversationID == nil || *d.ConversationID != h.convID {
+		t.Errorf("unexpected delivery: %+v", d)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/server/notifications"
+)
+
+// commandDoneThreshold is how long a background command must run for its
+// exit to send a command_done notification.
+const commandDoneThreshold = time.Minute
+
+// conversationTitle returns a conversation's slug, or "" if it has none.
+func (s *Server) conversationTitle(ctx context.Context, conversationID string) string {
+	conv, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil || conv.Slug == nil {
+		return ""
+	}
+	return *conv.Slug
+}
+
+// notifyToolApproval sends a tool_approval notification for a tool call
+// waiting for the user.
+func (s *Server) notifyToolApproval(conversationID string, request ApprovalRequest) {
+	s.notifDispatcher.Dispatch(notifications.Event{
+		Type:           notifications.EventToolApproval,
+		ConversationID: conversationID,
+		Timestamp:      time.Now(),
+		Payload: notifications.ToolApprovalPayload{
+			ToolName:          request.Tool,
+			Summary:           request.Summary,
+			Reason:            request.Reason,
+			ConversationTitle: s.conversationTitle(context.Background(), conversationID),
+		},
+	})
+}
+
+// notifyCommandDone sends a command_done notification when a background
+// command that ran for a while exits.
+func (s *Server) notifyCommandDone(conversationID string, info claudetool.BackgroundProcessInfo) {
+	if info.ExitedAt == nil || info.ExitCode == nil {
+		return
+	}
+	duration := info.ExitedAt.Sub(info.StartedAt)
+	if duration < commandDoneThreshold {
+		return
+	}
+	s.notifDispatcher.Dispatch(notifications.Event{
+		Type:           notifications.EventCommandDone,
+		ConversationID: conversationID,
+		Timestamp:      time.Now(),
+		Payload: notifications.CommandDonePayload{
+			Command:           info.Command,
+			ExitCode:          *info.ExitCode,
+			Duration:          duration.Round(time.Second),
+			ConversationTitle: s.conversationTitle(context.Background(), conversationID),
+		},
+	})
+}
+
+// notifyCompaction sends a compaction notification after a conversation's
+// history was compacted, or failed to be.
+func (s *Server) notifyCompaction(conversationID string, err error) {
+	payload := notifications.CompactionPayload{
+		ConversationTitle: s.conversationTitle(context.Background(), conversationID),
+	}
+	if err != nil {
+		payload.Error = err.Error()
+	}
+	s.notifDispatcher.Dispatch(notifications.Event{
+		Type:           notifications.EventCompaction,
+		ConversationID: conversationID,
+		Timestamp:      time.Now(),
+		Payload:        payload,
+	})
+}
+
+// notifyUpgradeAvailable sends an upgrade_available notification, once per release.
+func (s *Server) notifyUpgradeAvailable(info *VersionInfo) {
+	if info.LatestTag == s.upgradeNotifiedTag {
+		return
+	}
+	s.upgradeNotifiedTag = info.LatestTag
+	s.notifDispatcher.Dispatch(notifications.Event{
+		Type:      notifications.EventUpgradeAvailable,
+		Timestamp: time.Now(),
+		Payload: notifications.UpgradeAvailablePayload{
+			CurrentVersion: info.CurrentTag,
+			LatestVersion:  info.LatestTag,
+		},
+	})
+}
@@ -1,6 +1,10 @@
 package notifications
 
-import "context"
+import (
+	"context"
+	"errors"
+	"net/http"
+)
 
 // Channel is a backend notification delivery mechanism.
 // Discord webhooks, email, etc. implement this interface.
@@ -11,3 +15,28 @@ type Channel interface {
 	// Send delivers a notification event through this channel.
 	Send(ctx context.Context, event Event) error
 }
+
+// StatusError is the error a channel returns when its backend responds
+// with an HTTP error status.
+type StatusError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *StatusError) Error() string { return e.Err.Error() }
+func (e *StatusError) Unwrap() error { return e.Err }
+
+// permanent reports whether a delivery that failed with err can't succeed
+// on retry: the backend rejected it with a 4xx status other than
+// 408 Request Timeout or 429 Too Many Requests.
+func permanent(err error) bool {
+	var se *StatusError
+	if !errors.As(err, &se) {
+		return false
+	}
+	switch se.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return se.StatusCode >= 400 && se.StatusCode < 500
+}
@@ -0,0 +1,223 @@
+package channels
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/server/notifications"
+)
+
+var testEvent = notifications.Event{
+	Type:           notifications.EventAgentDone,
+	ConversationID: "conv-1",
+	Timestamp:      time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	Payload: notifications.AgentDonePayload{
+		Model:             "claude",
+		ConversationTitle: `fix "the" bug`,
+		FinalResponse:     "Done.",
+	},
+}
+
+func TestWebhook(t *testing.T) {
+	type request struct {
+		method string
+		header http.Header
+		body   string
+	}
+	requests := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.Method, r.Header, string(body)}
+	}))
+	defer srv.Close()
+
+	send := func(config map[string]any) request {
+		t.Helper()
+		config["type"] = "webhook"
+		config["url"] = srv.URL
+		ch, err := notifications.CreateFromConfig(config, slog.Default())
+		if err != nil {
+			t.Fatalf("CreateFromConfig: %v", err)
+		}
+		if err := ch.Send(context.Background(), testEvent); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		return <-requests
+	}
+
+	t.Run("default body", func(t *testing.T) {
+		req := send(map[string]any{})
+		var got map[string]any
+		if err := json.Unmarshal([]byte(req.body), &got); err != nil {
+			t.Fatalf("body is not JSON: %q", req.body)
+		}
+		if req.method != http.MethodPost || got["type"] != "agent_done" || got["conversation_id"] != "conv-1" || got["title"] != `Agent finished: fix "the" bug` {
+			t.Errorf("unexpected request: %s %s", req.method, req.body)
+		}
+		if req.header.Get(webhookSignatureHeader) != "" {
+			t.Error("unexpected signature without a secret")
+		}
+	})
+
+	t.Run("template, headers and signature", func(t *testing.T) {
+		req := send(map[string]any{
+			"method":  "put",
+			"headers": "Authorization: Bearer abc\nX-Extra: 1",
+			"secret":  "s3cret",
+			"body":    `{"text": {{json .Title}}, "model": "{{.Payload.Model}}"}`,
+		})
+		if want := `{"text": "Agent finished: fix \"the\" bug", "model": "claude"}`; req.body != want {
+			t.Errorf("body = %s, want %s", req.body, want)
+		}
+		if req.method != http.MethodPut || req.header.Get("Authorization") != "Bearer abc" || req.header.Get("X-Extra") != "1" {
+			t.Errorf("unexpected method or headers: %s %v", req.method, req.header)
+		}
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write([]byte(req.body))
+		if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.header.Get(webhookSignatureHeader) != want {
+			t.Errorf("signature = %q, want %q", req.header.Get(webhookSignatureHeader), want)
+		}
+	})
+
+	t.Run("header object", func(t *testing.T) {
+		req := send(map[string]any{"headers": map[string]any{"X-Token": "t"}})
+		if req.header.Get("X-Token") != "t" {
+			t.Errorf("missing header: %v", req.header)
+		}
+	})
+}
+
+func TestWebhookConfigErrors(t *testing.T) {
+	for name, config := range map[string]map[string]any{
+		"missing url":  {},
+		"bad url":      {"url": "ftp://example.com"},
+		"bad method":   {"url": "https://example.com", "method": "DELETE"},
+		"bad header":   {"url": "https://example.com", "headers": "no colon"},
+		"bad template": {"url": "https://example.com", "body": "{{.Title"},
+	} {
+		config["type"] = "webhook"
+		if _, err := notifications.CreateFromConfig(config, slog.Default()); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// A minimal SMTP server that records the envelope and message.
+	type delivery struct {
+		from, data string
+		rcpt       []string
+	}
+	deliveries := make(chan delivery, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		var d delivery
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				d.from = strings.TrimPrefix(cmd, "MAIL FROM:")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				d.rcpt = append(d.rcpt, strings.TrimPrefix(cmd, "RCPT TO:"))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				d.data = data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 bye")
+				deliveries <- d
+				return
+			default:
+				reply("502 unsupported")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	ch, err := notifications.CreateFromConfig(map[string]any{
+		"type":     "smtp",
+		"host":     host,
+		"port":     port,
+		"security": "none",
+		"from":     "Shelley <shelley@example.com>",
+		"to":       "a@example.com, b@example.com",
+	}, slog.Default())
+	if err != nil {
+		t.Fatalf("CreateFromConfig: %v", err)
+	}
+	if err := ch.Send(context.Background(), testEvent); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	d := <-deliveries
+	if d.from != "<shelley@example.com>" || len(d.rcpt) != 2 || d.rcpt[1] != "<b@example.com>" {
+		t.Errorf("unexpected envelope: %+v", d)
+	}
+	for _, want := range []string{
+		"From: \"Shelley\" <shelley@example.com>\r\n",
+		"To: <a@example.com>, <b@example.com>\r\n",
+		"Subject: Agent finished: fix \"the\" bug\r\n",
+		"Model: claude",
+		"Done.",
+	} {
+		if !strings.Contains(d.data, want) {
+			t.Errorf("message missing %q:\n%s", want, d.data)
+		}
+	}
+}
+
+func TestSMTPConfigErrors(t *testing.T) {
+	for name, config := range map[string]map[string]any{
+		"missing host": {"from": "a@example.com", "to": "b@example.com"},
+		"bad security": {"host": "h", "security": "ssl", "from": "a@example.com", "to": "b@example.com"},
+		"bad port":     {"host": "h", "port": "smtp", "from": "a@example.com", "to": "b@example.com"},
+		"bad from":     {"host": "h", "from": "nope", "to": "b@example.com"},
+		"missing to":   {"host": "h", "from": "a@example.com"},
+	} {
+		config["type"] = "smtp"
+		if _, err := notifications.CreateFromConfig(config, slog.Default()); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -64,7 +64,7 @@ func (d *discord) Send(ctx context.Context, event notifications.Event) error {
 	io.Copy(io.Discard, resp.Body)
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("discord webhook returned %d", resp.StatusCode)
+		return &notifications.StatusError{StatusCode: resp.StatusCode, Err: fmt.Errorf("discord webhook returned %d", resp.StatusCode)}
 	}
 	return nil
 }
@@ -118,7 +118,30 @@ func formatDiscordMessage(event notifications.Event) *discordMessage {
 		}
 		return &discordMessage{Embeds: []discordEmbed{embed}}
 
+	case notifications.EventBudgetExceeded:
+		embed := discordEmbed{
+			Title:     "Budget reached",
+			Color:     0xf59e0b, // amber
+			Timestamp: event.Timestamp.Format(time.RFC3339),
+		}
+		if p, ok := event.Payload.(notifications.BudgetExceededPayload); ok {
+			if p.ConversationTitle != "" {
+				embed.Title = fmt.Sprintf("Budget reached: %s", p.ConversationTitle)
+			}
+			embed.Description = p.Description()
+		}
+		return &discordMessage{Embeds: []discordEmbed{embed}}
+
 	default:
-		return nil
+		title, body := formatText(event)
+		if title == "" {
+			return nil
+		}
+		return &discordMessage{Embeds: []discordEmbed{{
+			Title:       title,
+			Description: body,
+			Color:       0x3b82f6, // blue
+			Timestamp:   event.Timestamp.Format(time.RFC3339),
+		}}}
 	}
 }
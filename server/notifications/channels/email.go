@@ -42,7 +42,7 @@ func newEmail(to string) *email {
 func (e *email) Name() string { return "email" }
 
 func (e *email) Send(ctx context.Context, event notifications.Event) error {
-	subject, body := formatEmailMessage(event)
+	subject, body := formatText(event)
 	if subject == "" {
 		return nil
 	}
@@ -74,7 +74,7 @@ func (e *email) Send(ctx context.Context, event notifications.Event) error {
 	}
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("email gateway returned %d: %s", resp.StatusCode, respBody)
+		return &notifications.StatusError{StatusCode: resp.StatusCode, Err: fmt.Errorf("email gateway returned %d: %s", resp.StatusCode, respBody)}
 	}
 
 	var result struct {
@@ -86,34 +86,3 @@ func (e *email) Send(ctx context.Context, event notifications.Event) error {
 
 	return nil
 }
-
-func formatEmailMessage(event notifications.Event) (subject, body string) {
-	switch event.Type {
-	case notifications.EventAgentDone:
-		subject = "Agent finished"
-		if p, ok := event.Payload.(notifications.AgentDonePayload); ok {
-			if p.ConversationTitle != "" {
-				subject = fmt.Sprintf("Agent finished: %s", p.ConversationTitle)
-			}
-			if p.Model != "" {
-				body = fmt.Sprintf("Model: %s\nTime: %s", p.Model, event.Timestamp.Format(time.RFC822))
-			} else {
-				body = fmt.Sprintf("Time: %s", event.Timestamp.Format(time.RFC822))
-			}
-			if p.FinalResponse != "" {
-				body += "\n\n" + p.FinalResponse
-			}
-		}
-		return subject, body
-
-	case notifications.EventAgentError:
-		subject = "Agent error"
-		if p, ok := event.Payload.(notifications.AgentErrorPayload); ok && p.ErrorMessage != "" {
-			body = p.ErrorMessage
-		}
-		return subject, body
-
-	default:
-		return "", ""
-	}
-}
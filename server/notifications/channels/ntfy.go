@@ -122,10 +122,11 @@ func (n *ntfy) Send(ctx context.Context, event notifications.Event) error {
 
 	if resp.StatusCode >= 400 {
 		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		err := fmt.Errorf("ntfy server returned %s", resp.Status)
 		if len(b) > 0 {
-			return fmt.Errorf("ntfy server returned %s: %s", resp.Status, bytes.TrimSpace(b))
+			err = fmt.Errorf("ntfy server returned %s: %s", resp.Status, bytes.TrimSpace(b))
 		}
-		return fmt.Errorf("ntfy server returned %s", resp.Status)
+		return &notifications.StatusError{StatusCode: resp.StatusCode, Err: err}
 	}
 
 	_, _ = io.Copy(io.Discard, resp.Body)
@@ -174,7 +175,38 @@ func (n *ntfy) formatMessage(event notifications.Event) *ntfyMessage {
 		}
 		return msg
 
+	case notifications.EventBudgetExceeded:
+		msg := &ntfyMessage{
+			Topic:    n.topic,
+			Title:    "Budget reached",
+			Priority: n.errorPriority,
+			Tags:     []string{"money_with_wings"},
+		}
+		if p, ok := event.Payload.(notifications.BudgetExceededPayload); ok {
+			if p.ConversationTitle != "" {
+				msg.Title = fmt.Sprintf("Budget reached: %s", p.ConversationTitle)
+			}
+			msg.Message = p.Description()
+		}
+		return msg
+
 	default:
-		return nil
+		title, body := formatText(event)
+		if title == "" {
+			return nil
+		}
+		msg := &ntfyMessage{
+			Topic:    n.topic,
+			Title:    title,
+			Message:  body,
+			Priority: n.donePriority,
+			Tags:     []string{"bell"},
+		}
+		// Approvals block the agent until the user answers.
+		if event.Type == notifications.EventToolApproval {
+			msg.Priority = n.errorPriority
+			msg.Tags = []string{"raised_hand"}
+		}
+		return msg
 	}
 }
@@ -0,0 +1,201 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/server/notifications"
+)
+
+// SMTP security modes.
+const (
+	smtpSTARTTLS = "starttls" // plain connection upgraded with STARTTLS, usually port 587
+	smtpTLS      = "tls"      // implicit TLS, usually port 465
+	smtpNone     = "none"     // no encryption, for local relays
+)
+
+func init() {
+	notifications.Register("smtp", func(config map[string]any, logger *slog.Logger) (notifications.Channel, error) {
+		host, _ := config["host"].(string)
+		if host == "" {
+			return nil, fmt.Errorf("smtp channel requires \"host\"")
+		}
+
+		security, _ := config["security"].(string)
+		if security == "" {
+			security = smtpSTARTTLS
+		}
+		port := 587
+		switch security {
+		case smtpSTARTTLS:
+		case smtpTLS:
+			port = 465
+		case smtpNone:
+			port = 25
+		default:
+			return nil, fmt.Errorf("smtp channel: invalid security %q", security)
+		}
+		// Ports come from JSON as numbers, and from the UI as strings.
+		switch p := config["port"].(type) {
+		case float64:
+			port = int(p)
+		case string:
+			if p != "" {
+				n, err := strconv.Atoi(p)
+				if err != nil {
+					return nil, fmt.Errorf("smtp channel: invalid port %q", p)
+				}
+				port = n
+			}
+		}
+
+		fromStr, _ := config["from"].(string)
+		if fromStr == "" {
+			return nil, fmt.Errorf("smtp channel requires \"from\"")
+		}
+		from, err := mail.ParseAddress(fromStr)
+		if err != nil {
+			return nil, fmt.Errorf("smtp channel: invalid from address: %w", err)
+		}
+
+		toStr, _ := config["to"].(string)
+		if toStr == "" {
+			return nil, fmt.Errorf("smtp channel requires \"to\"")
+		}
+		to, err := mail.ParseAddressList(toStr)
+		if err != nil {
+			return nil, fmt.Errorf("smtp channel: invalid to address: %w", err)
+		}
+
+		username, _ := config["username"].(string)
+		password, _ := config["password"].(string)
+
+		return &smtpEmail{
+			addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+			host:     host,
+			security: security,
+			username: username,
+			password: password,
+			from:     from,
+			to:       to,
+		}, nil
+	})
+}
+
+type smtpEmail struct {
+	addr     string
+	host     string
+	security string
+	username string
+	password string
+	from     *mail.Address
+	to       []*mail.Address
+}
+
+func (e *smtpEmail) Name() string { return "smtp" }
+
+func (e *smtpEmail) Send(ctx context.Context, event notifications.Event) error {
+	subject, body := formatText(event)
+	if subject == "" {
+		return nil
+	}
+	msg, err := e.message(subject, body, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tlsConfig := &tls.Config{ServerName: e.host}
+
+	var conn net.Conn
+	if e.security == smtpTLS {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", e.addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", e.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connect to smtp server: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		return fmt.Errorf("smtp greeting: %w", err)
+	}
+	defer c.Close()
+
+	if e.security == smtpSTARTTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server does not support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if e.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.username, e.password, e.host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(e.from.Address); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	for _, to := range e.to {
+		if err := c.Rcpt(to.Address); err != nil {
+			return fmt.Errorf("smtp rcpt to %s: %w", to.Address, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("smtp write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp send message: %w", err)
+	}
+	return c.Quit()
+}
+
+// message formats a plain-text email.
+func (e *smtpEmail) message(subject, body string, date time.Time) ([]byte, error) {
+	to := make([]string, len(e.to))
+	for i, a := range e.to {
+		to[i] = a.String()
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return nil, fmt.Errorf("encode email body: %w", err)
+	}
+	if err := qp.Close(); err != nil {
+		return nil, fmt.Errorf("encode email body: %w", err)
+	}
+	return b.Bytes(), nil
+}
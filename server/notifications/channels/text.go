@@ -0,0 +1,122 @@
+package channels
+
+import (
+	"fmt"
+	"time"
+
+	"shelley.exe.dev/server/notifications"
+)
+
+// formatText returns a plain-text subject and body for an event, for
+// channels that send plain text. The subject is empty for events that
+// have no plain-text form.
+func formatText(event notifications.Event) (subject, body string) {
+	switch event.Type {
+	case notifications.EventAgentDone:
+		subject = "Agent finished"
+		if p, ok := event.Payload.(notifications.AgentDonePayload); ok {
+			if p.ConversationTitle != "" {
+				subject = fmt.Sprintf("Agent finished: %s", p.ConversationTitle)
+			}
+			if p.Model != "" {
+				body = fmt.Sprintf("Model: %s\nTime: %s", p.Model, event.Timestamp.Format(time.RFC822))
+			} else {
+				body = fmt.Sprintf("Time: %s", event.Timestamp.Format(time.RFC822))
+			}
+			if p.FinalResponse != "" {
+				body += "\n\n" + p.FinalResponse
+			}
+		}
+		return subject, body
+
+	case notifications.EventAgentError:
+		subject = "Agent error"
+		if p, ok := event.Payload.(notifications.AgentErrorPayload); ok && p.ErrorMessage != "" {
+			body = p.ErrorMessage
+		}
+		return subject, body
+
+	case notifications.EventBudgetExceeded:
+		subject = "Budget reached"
+		if p, ok := event.Payload.(notifications.BudgetExceededPayload); ok {
+			if p.ConversationTitle != "" {
+				subject = fmt.Sprintf("Budget reached: %s", p.ConversationTitle)
+			}
+			body = fmt.Sprintf("%s\nTime: %s", p.Description(), event.Timestamp.Format(time.RFC822))
+		}
+		return subject, body
+
+	case notifications.EventBudgetThreshold:
+		subject = "Budget nearly reached"
+		if p, ok := event.Payload.(notifications.BudgetThresholdPayload); ok {
+			if p.ConversationTitle != "" {
+				subject = fmt.Sprintf("Budget nearly reached: %s", p.ConversationTitle)
+			}
+			body = fmt.Sprintf("%s\nTime: %s", p.Description(), event.Timestamp.Format(time.RFC822))
+		}
+		return subject, body
+
+	case notifications.EventToolApproval:
+		subject = "Approval needed"
+		if p, ok := event.Payload.(notifications.ToolApprovalPayload); ok {
+			if p.ConversationTitle != "" {
+				subject = fmt.Sprintf("Approval needed: %s", p.ConversationTitle)
+			}
+			body = fmt.Sprintf("Tool: %s", p.ToolName)
+			if p.Summary != "" {
+				body += "\n" + p.Summary
+			}
+			if p.Reason != "" {
+				body += "\nReason: " + p.Reason
+			}
+		}
+		return subject, body
+
+	case notifications.EventSubagentDone:
+		subject = "Subagent finished"
+		if p, ok := event.Payload.(notifications.SubagentDonePayload); ok {
+			if p.SubagentTitle != "" {
+				subject = fmt.Sprintf("Subagent finished: %s", p.SubagentTitle)
+			}
+			body = fmt.Sprintf("Time: %s", event.Timestamp.Format(time.RFC822))
+			if p.FinalResponse != "" {
+				body += "\n\n" + p.FinalResponse
+			}
+		}
+		return subject, body
+
+	case notifications.EventCommandDone:
+		subject = "Command finished"
+		if p, ok := event.Payload.(notifications.CommandDonePayload); ok {
+			if p.ConversationTitle != "" {
+				subject = fmt.Sprintf("Command finished: %s", p.ConversationTitle)
+			}
+			body = fmt.Sprintf("%s\nExited with code %d after %s.", p.Command, p.ExitCode, p.Duration)
+		}
+		return subject, body
+
+	case notifications.EventCompaction:
+		subject = "Conversation compacted"
+		if p, ok := event.Payload.(notifications.CompactionPayload); ok {
+			if p.Error != "" {
+				subject = "Compaction failed"
+				body = p.Error
+			}
+			if p.ConversationTitle != "" {
+				subject = fmt.Sprintf("%s: %s", subject, p.ConversationTitle)
+			}
+		}
+		return subject, body
+
+	case notifications.EventUpgradeAvailable:
+		subject = "Shelley upgrade available"
+		if p, ok := event.Payload.(notifications.UpgradeAvailablePayload); ok {
+			subject = fmt.Sprintf("Shelley %s is available", p.LatestVersion)
+			body = fmt.Sprintf("You are running %s.", p.CurrentVersion)
+		}
+		return subject, body
+
+	default:
+		return "", ""
+	}
+}
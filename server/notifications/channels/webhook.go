@@ -0,0 +1,194 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"shelley.exe.dev/server/notifications"
+)
+
+// webhookSignatureHeader carries the HMAC-SHA256 of the request body, as
+// "sha256=<hex>", when the channel has a secret.
+const webhookSignatureHeader = "X-Shelley-Signature"
+
+func init() {
+	notifications.Register("webhook", func(config map[string]any, logger *slog.Logger) (notifications.Channel, error) {
+		rawURL, _ := config["url"].(string)
+		if rawURL == "" {
+			return nil, fmt.Errorf("webhook channel requires \"url\"")
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhook channel: invalid url %q", rawURL)
+		}
+
+		method, _ := config["method"].(string)
+		method = strings.ToUpper(method)
+		switch method {
+		case "":
+			method = http.MethodPost
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodGet:
+		default:
+			return nil, fmt.Errorf("webhook channel: invalid method %q", method)
+		}
+
+		headers, err := parseWebhookHeaders(config["headers"])
+		if err != nil {
+			return nil, fmt.Errorf("webhook channel: %w", err)
+		}
+
+		var body *template.Template
+		if text, _ := config["body"].(string); text != "" {
+			body, err = template.New("body").Funcs(webhookFuncs).Option("missingkey=zero").Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("webhook channel: invalid body template: %w", err)
+			}
+		}
+
+		contentType, _ := config["content_type"].(string)
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		secret, _ := config["secret"].(string)
+
+		return &webhook{
+			url:         rawURL,
+			method:      method,
+			headers:     headers,
+			contentType: contentType,
+			body:        body,
+			secret:      secret,
+			client: &http.Client{
+				Timeout: 10 * time.Second,
+			},
+		}, nil
+	})
+}
+
+// parseWebhookHeaders accepts headers as a JSON object, from shelley.json,
+// or as "Name: Value" lines, from the UI.
+func parseWebhookHeaders(v any) (http.Header, error) {
+	headers := http.Header{}
+	switch v := v.(type) {
+	case nil:
+	case map[string]any:
+		for name, value := range v {
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("header %q must be a string", name)
+			}
+			headers.Set(name, s)
+		}
+	case string:
+		for line := range strings.Lines(v) {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(line, ":")
+			if !ok || strings.TrimSpace(name) == "" {
+				return nil, fmt.Errorf("invalid header %q: expected \"Name: Value\"", line)
+			}
+			headers.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	default:
+		return nil, fmt.Errorf("\"headers\" must be an object or \"Name: Value\" lines")
+	}
+	return headers, nil
+}
+
+var webhookFuncs = template.FuncMap{
+	// json encodes a value as JSON, so templates can embed strings in JSON
+	// bodies safely: {"text": {{json .Message}}}.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+type webhook struct {
+	url         string
+	method      string
+	headers     http.Header
+	contentType string
+	body        *template.Template // nil sends webhookData as JSON
+	secret      string
+	client      *http.Client
+}
+
+// webhookData is what body templates see: the event's fields, plus a
+// plain-text summary of it.
+type webhookData struct {
+	notifications.Event
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
+func (wh *webhook) Name() string { return "webhook" }
+
+func (wh *webhook) Send(ctx context.Context, event notifications.Event) error {
+	title, message := formatText(event)
+	if title == "" {
+		title = string(event.Type)
+	}
+	data := webhookData{Event: event, Title: title, Message: message}
+
+	var body bytes.Buffer
+	if wh.body != nil {
+		if err := wh.body.Execute(&body, data); err != nil {
+			return fmt.Errorf("render webhook body: %w", err)
+		}
+	} else if err := json.NewEncoder(&body).Encode(data); err != nil {
+		return fmt.Errorf("marshal webhook payload: %w", err)
+	}
+
+	var reqBody io.Reader
+	if wh.method != http.MethodGet {
+		reqBody = bytes.NewReader(body.Bytes())
+	}
+	req, err := http.NewRequestWithContext(ctx, wh.method, wh.url, reqBody)
+	if err != nil {
+		return fmt.Errorf("create webhook request: %w", err)
+	}
+	for name, values := range wh.headers {
+		req.Header[name] = values
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", wh.contentType)
+		if wh.secret != "" {
+			mac := hmac.New(sha256.New, []byte(wh.secret))
+			mac.Write(body.Bytes())
+			req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+	}
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		err := fmt.Errorf("webhook returned %s", resp.Status)
+		if len(b) > 0 {
+			err = fmt.Errorf("webhook returned %s: %s", resp.Status, bytes.TrimSpace(b))
+		}
+		return &notifications.StatusError{StatusCode: resp.StatusCode, Err: err}
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -3,58 +3,234 @@ package notifications
 import (
 	"context"
 	"log/slog"
+	"math/rand/v2"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Delivery statuses recorded in the DeliveryLog.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+const (
+	// maxConcurrentSends bounds the number of Send calls in flight.
+	maxConcurrentSends = 4
+	// maxPendingDeliveries bounds the deliveries waiting to be sent or
+	// retried; beyond it, new deliveries are dropped.
+	maxPendingDeliveries = 1000
+	// sendTimeout bounds a single delivery attempt.
+	sendTimeout = 30 * time.Second
+)
+
+// Route is a channel and the events it receives.
+type Route struct {
+	// ID identifies the channel in the delivery log.
+	ID      string
+	Channel Channel
+	Filter  Filter
+}
+
+// DeliveryLog records the outcome of deliveries.
+type DeliveryLog interface {
+	CreateDelivery(ctx context.Context, routeID string, event Event) (int64, error)
+	UpdateDelivery(ctx context.Context, deliveryID int64, status string, attempts int, lastError string) error
+}
+
 // Dispatcher routes notification events to registered backend channels.
+// Deliveries are asynchronous, so a slow channel never blocks the caller,
+// and failed deliveries are retried with exponential backoff unless the
+// backend rejected them (see StatusError). Retries are kept in memory only:
+// deliveries still pending on Close are recorded as failed.
 type Dispatcher struct {
-	mu       sync.RWMutex
-	channels []Channel
-	logger   *slog.Logger
+	mu     sync.RWMutex
+	routes []Route
+	log    DeliveryLog
+	logger *slog.Logger
+
+	// MaxAttempts is the number of times a delivery is tried.
+	MaxAttempts int
+	// Backoff is the delay before the first retry; it doubles after each
+	// failed attempt, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	sem     chan struct{}
+	pending atomic.Int64
 }
 
 // NewDispatcher creates a new notification dispatcher.
 func NewDispatcher(logger *slog.Logger) *Dispatcher {
-	return &Dispatcher{logger: logger}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		logger:      logger,
+		MaxAttempts: 5,
+		Backoff:     5 * time.Second,
+		MaxBackoff:  5 * time.Minute,
+		ctx:         ctx,
+		cancel:      cancel,
+		sem:         make(chan struct{}, maxConcurrentSends),
+	}
 }
 
-// Register adds a backend channel to the dispatcher.
+// SetDeliveryLog sets where deliveries are recorded.
+func (d *Dispatcher) SetDeliveryLog(log DeliveryLog) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = log
+}
+
+// Register adds a backend channel that receives every event.
 func (d *Dispatcher) Register(ch Channel) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.channels = append(d.channels, ch)
+	d.routes = append(d.routes, Route{ID: ch.Name(), Channel: ch})
 }
 
-// ReplaceChannels atomically replaces the entire channel set.
-func (d *Dispatcher) ReplaceChannels(channels []Channel) {
+// ReplaceRoutes atomically replaces the entire route set. Deliveries
+// already in progress continue.
+func (d *Dispatcher) ReplaceRoutes(routes []Route) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.channels = channels
+	d.routes = routes
 }
 
-// Channels returns a snapshot of current registered channels.
-func (d *Dispatcher) Channels() []Channel {
+// Routes returns a snapshot of the current routes.
+func (d *Dispatcher) Routes() []Route {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
-	result := make([]Channel, len(d.channels))
-	copy(result, d.channels)
+	result := make([]Route, len(d.routes))
+	copy(result, d.routes)
 	return result
 }
 
-// Dispatch sends an event to all registered backend channels.
-// It does not block on individual channel failures.
-func (d *Dispatcher) Dispatch(ctx context.Context, event Event) {
+// Wants reports whether any route receives events of type t that aren't
+// about a conversation, so callers can skip producing events nobody gets.
+func (d *Dispatcher) Wants(t EventType) bool {
 	d.mu.RLock()
-	channels := d.channels
+	defer d.mu.RUnlock()
+	return slices.ContainsFunc(d.routes, func(route Route) bool {
+		return route.Filter.Match(Event{Type: t})
+	})
+}
+
+// Dispatch queues an event for delivery to every route whose filter
+// matches it, and to the routes with the given target IDs regardless of
+// their filters. It does not wait for deliveries.
+func (d *Dispatcher) Dispatch(event Event, targets ...string) {
+	d.mu.RLock()
+	routes := d.routes
+	log := d.log
 	d.mu.RUnlock()
 
-	for _, ch := range channels {
-		if err := ch.Send(ctx, event); err != nil {
+	for _, route := range routes {
+		if !route.Filter.Match(event) && !slices.Contains(targets, route.ID) {
+			continue
+		}
+		var deliveryID int64
+		if log != nil {
+			id, err := log.CreateDelivery(context.Background(), route.ID, event)
+			if err != nil {
+				d.logger.Warn("failed to record notification delivery", "channel", route.ID, "error", err)
+			}
+			deliveryID = id
+		}
+		if d.pending.Add(1) > maxPendingDeliveries {
+			d.pending.Add(-1)
+			d.logger.Warn("notification queue full, dropping event",
+				"channel", route.ID,
+				"event", string(event.Type),
+			)
+			d.record(log, deliveryID, DeliveryFailed, 0, "dropped: notification queue full")
+			continue
+		}
+		d.wg.Add(1)
+		go d.deliver(route, event, log, deliveryID)
+	}
+}
+
+// deliver sends an event to one route, retrying failures.
+func (d *Dispatcher) deliver(route Route, event Event, log DeliveryLog, deliveryID int64) {
+	defer d.wg.Done()
+	defer d.pending.Add(-1)
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case d.sem <- struct{}{}:
+		case <-d.ctx.Done():
+			d.record(log, deliveryID, DeliveryFailed, attempt-1, "abandoned: server shutting down")
+			return
+		}
+		ctx, cancel := context.WithTimeout(d.ctx, sendTimeout)
+		err := route.Channel.Send(ctx, event)
+		cancel()
+		<-d.sem
+
+		if err == nil {
+			d.record(log, deliveryID, DeliveryDelivered, attempt, "")
+			return
+		}
+		if attempt >= d.MaxAttempts || permanent(err) {
 			d.logger.Warn("notification channel failed",
-				"channel", ch.Name(),
+				"channel", route.ID,
 				"event", string(event.Type),
+				"attempts", attempt,
 				"error", err,
 			)
+			d.record(log, deliveryID, DeliveryFailed, attempt, err.Error())
+			return
 		}
+		d.record(log, deliveryID, DeliveryPending, attempt, err.Error())
+
+		t := time.NewTimer(d.backoff(attempt))
+		select {
+		case <-t.C:
+		case <-d.ctx.Done():
+			t.Stop()
+			d.record(log, deliveryID, DeliveryFailed, attempt, "abandoned: server shutting down")
+			return
+		}
+	}
+}
+
+// backoff returns the delay after the given failed attempt: Backoff doubled
+// for each earlier attempt, capped at MaxBackoff, with up to half of it
+// randomized so failing channels don't retry in lockstep.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.Backoff
+	for i := 1; i < attempt && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.MaxBackoff)
+	if half := delay / 2; half > 0 {
+		delay = half + rand.N(half)
+	}
+	return delay
+}
+
+func (d *Dispatcher) record(log DeliveryLog, deliveryID int64, status string, attempts int, lastError string) {
+	if log == nil || deliveryID == 0 {
+		return
 	}
+	if err := log.UpdateDelivery(context.Background(), deliveryID, status, attempts, lastError); err != nil {
+		d.logger.Warn("failed to record notification delivery", "delivery_id", deliveryID, "error", err)
+	}
+}
+
+// Wait blocks until every queued delivery has finished, including retries.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// Close abandons pending deliveries and waits for sends in progress.
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.wg.Wait()
 }
@@ -0,0 +1,240 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeChannel returns err, or "unavailable", for its first failures sends,
+// then records events.
+type fakeChannel struct {
+	mu       sync.Mutex
+	failures int
+	err      error
+	attempts int
+	events   []Event
+}
+
+func (c *fakeChannel) Name() string { return "fake" }
+
+func (c *fakeChannel) Send(ctx context.Context, event Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempts++
+	if c.attempts <= c.failures {
+		if c.err != nil {
+			return c.err
+		}
+		return errors.New("unavailable")
+	}
+	c.events = append(c.events, event)
+	return nil
+}
+
+type fakeDelivery struct {
+	routeID   string
+	status    string
+	attempts  int
+	lastError string
+}
+
+type fakeLog struct {
+	mu         sync.Mutex
+	deliveries []*fakeDelivery
+}
+
+func (l *fakeLog) CreateDelivery(ctx context.Context, routeID string, event Event) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.deliveries = append(l.deliveries, &fakeDelivery{routeID: routeID, status: DeliveryPending})
+	return int64(len(l.deliveries)), nil
+}
+
+func (l *fakeLog) UpdateDelivery(ctx context.Context, deliveryID int64, status string, attempts int, lastError string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d := l.deliveries[deliveryID-1]
+	d.status, d.attempts, d.lastError = status, attempts, lastError
+	return nil
+}
+
+func newTestDispatcher(t *testing.T) (*Dispatcher, *fakeLog) {
+	d := NewDispatcher(slog.Default())
+	d.Backoff = time.Millisecond
+	d.MaxBackoff = 4 * time.Millisecond
+	d.MaxAttempts = 3
+	log := &fakeLog{}
+	d.SetDeliveryLog(log)
+	t.Cleanup(d.Close)
+	return d, log
+}
+
+func TestDispatchFilters(t *testing.T) {
+	d, _ := newTestDispatcher(t)
+	all, done, conv := &fakeChannel{}, &fakeChannel{}, &fakeChannel{}
+	d.ReplaceRoutes([]Route{
+		{ID: "all", Channel: all},
+		{ID: "done", Channel: done, Filter: Filter{Events: []EventType{EventAgentDone}}},
+		{ID: "conv", Channel: conv, Filter: Filter{Conversations: []string{"c1"}}},
+	})
+
+	d.Dispatch(Event{Type: EventAgentDone, ConversationID: "c1"})
+	d.Dispatch(Event{Type: EventToolApproval, ConversationID: "c2"})
+	d.Dispatch(Event{Type: EventUpgradeAvailable})
+	d.Dispatch(Event{Type: EventCompaction, ConversationID: "c1"}, "done")
+	d.Wait()
+
+	if len(all.events) != 4 {
+		t.Errorf("unfiltered channel got %d events, want 4", len(all.events))
+	}
+	// Targeted events bypass the filter.
+	if len(done.events) != 2 {
+		t.Errorf("event filter: got %+v", done.events)
+	}
+	// Events without a conversation pass the conversation filter.
+	// Deliveries are concurrent, so events may arrive in any order.
+	if len(conv.events) != 3 || slices.ContainsFunc(conv.events, func(e Event) bool { return e.Type == EventToolApproval }) {
+		t.Errorf("conversation filter: got %+v", conv.events)
+	}
+}
+
+func TestWants(t *testing.T) {
+	d, _ := newTestDispatcher(t)
+	if d.Wants(EventUpgradeAvailable) {
+		t.Error("expected no route to want events before any are registered")
+	}
+	d.ReplaceRoutes([]Route{
+		{ID: "done", Channel: &fakeChannel{}, Filter: Filter{Events: []EventType{EventAgentDone}}},
+		{ID: "conv", Channel: &fakeChannel{}, Filter: Filter{Events: []EventType{EventToolApproval}, Conversations: []string{"c1"}}},
+	})
+	if !d.Wants(EventAgentDone) || !d.Wants(EventToolApproval) {
+		t.Error("expected the filtered events to be wanted")
+	}
+	if d.Wants(EventUpgradeAvailable) {
+		t.Error("expected upgrade_available not to be wanted")
+	}
+}
+
+func TestDispatchRetries(t *testing.T) {
+	d, log := newTestDispatcher(t)
+	flaky, broken := &fakeChannel{failures: 2}, &fakeChannel{failures: 10}
+	d.ReplaceRoutes([]Route{{ID: "flaky", Channel: flaky}, {ID: "broken", Channel: broken}})
+
+	d.Dispatch(Event{Type: EventAgentDone})
+	d.Wait()
+
+	if len(flaky.events) != 1 {
+		t.Errorf("expected the flaky channel to deliver after retrying, got %d events", len(flaky.events))
+	}
+	if broken.attempts != 3 {
+		t.Errorf("expected 3 attempts for the broken channel, got %d", broken.attempts)
+	}
+
+	got := map[string]fakeDelivery{}
+	for _, d := range log.deliveries {
+		got[d.routeID] = *d
+	}
+	if d := got["flaky"]; d.status != DeliveryDelivered || d.attempts != 3 {
+		t.Errorf("unexpected flaky delivery: %+v", d)
+	}
+	if d := got["broken"]; d.status != DeliveryFailed || d.attempts != 3 || d.lastError != "unavailable" {
+		t.Errorf("unexpected broken delivery: %+v", d)
+	}
+}
+
+func TestDispatchPermanentFailures(t *testing.T) {
+	d, log := newTestDispatcher(t)
+	rejected := &fakeChannel{failures: 10, err: &StatusError{StatusCode: 400, Err: errors.New("bad request")}}
+	limited := &fakeChannel{failures: 10, err: &StatusError{StatusCode: 429, Err: errors.New("too many requests")}}
+	d.ReplaceRoutes([]Route{{ID: "rejected", Channel: rejected}, {ID: "limited", Channel: limited}})
+
+	d.Dispatch(Event{Type: EventAgentDone})
+	d.Wait()
+
+	if rejected.attempts != 1 {
+		t.Errorf("expected a rejected delivery not to be retried, got %d attempts", rejected.attempts)
+	}
+	if limited.attempts != 3 {
+		t.Errorf("expected a rate limited delivery to be retried, got %d attempts", limited.attempts)
+	}
+	for _, d := range log.deliveries {
+		if d.status != DeliveryFailed {
+			t.Errorf("unexpected delivery: %+v", *d)
+		}
+	}
+}
+
+func TestCloseFailsPendingDeliveries(t *testing.T) {
+	d, log := newTestDispatcher(t)
+	d.Backoff, d.MaxBackoff = time.Hour, time.Hour
+	broken := &fakeChannel{failures: 10}
+	d.Register(broken)
+
+	d.Dispatch(Event{Type: EventAgentDone})
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		log.mu.Lock()
+		attempted := log.deliveries[0].attempts > 0
+		log.mu.Unlock()
+		if attempted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the first attempt")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	d.Close()
+
+	if got := *log.deliveries[0]; got.status != DeliveryFailed || got.attempts != 1 || !strings.Contains(got.lastError, "abandoned") {
+		t.Errorf("expected the pending delivery to be recorded as failed, got %+v", got)
+	}
+}
+
+func TestDispatchDoesNotBlock(t *testing.T) {
+	d, _ := newTestDispatcher(t)
+	release := make(chan struct{})
+	d.Register(blockingChannel(release))
+
+	start := time.Now()
+	d.Dispatch(Event{Type: EventAgentDone})
+	if time.Since(start) > time.Second {
+		t.Error("Dispatch waited for the channel")
+	}
+	close(release)
+	d.Wait()
+}
+
+type blockingChannel chan struct{}
+
+func (c blockingChannel) Name() string { return "blocking" }
+
+func (c blockingChannel) Send(ctx context.Context, event Event) error {
+	<-c
+	return nil
+}
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter(map[string]any{"events": "agent_done, tool_approval", "conversations": []any{"c1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Events) != 2 || f.Events[1] != EventToolApproval || len(f.Conversations) != 1 {
+		t.Errorf("unexpected filter: %+v", f)
+	}
+
+	if f, err := ParseFilter(map[string]any{}); err != nil || len(f.Events) != 0 {
+		t.Errorf("expected an empty filter, got %+v, %v", f, err)
+	}
+	if _, err := ParseFilter(map[string]any{"events": "agent_done, nope"}); err == nil {
+		t.Error("expected an error for an unknown event")
+	}
+	if _, err := ParseFilter(map[string]any{"conversations": 3.0}); err == nil {
+		t.Error("expected an error for a bad conversation list")
+	}
+}
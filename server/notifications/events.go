@@ -1,15 +1,38 @@
 package notifications
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // EventType identifies the kind of notification event.
 type EventType string
 
 const (
-	EventAgentDone  EventType = "agent_done"
-	EventAgentError EventType = "agent_error"
+	EventAgentDone        EventType = "agent_done"
+	EventAgentError       EventType = "agent_error"
+	EventBudgetExceeded   EventType = "budget_exceeded"
+	EventBudgetThreshold  EventType = "budget_threshold"
+	EventToolApproval     EventType = "tool_approval"
+	EventSubagentDone     EventType = "subagent_done"
+	EventCommandDone      EventType = "command_done"
+	EventCompaction       EventType = "compaction"
+	EventUpgradeAvailable EventType = "upgrade_available"
 )
 
+// EventTypes lists every event type, for validating filters.
+var EventTypes = []EventType{
+	EventAgentDone,
+	EventAgentError,
+	EventBudgetExceeded,
+	EventBudgetThreshold,
+	EventToolApproval,
+	EventSubagentDone,
+	EventCommandDone,
+	EventCompaction,
+	EventUpgradeAvailable,
+}
+
 // Event is a notification event generated by the system.
 type Event struct {
 	Type           EventType `json:"type"`
@@ -29,3 +52,72 @@ type AgentDonePayload struct {
 type AgentErrorPayload struct {
 	ErrorMessage string `json:"error_message"`
 }
+
+// BudgetExceededPayload is the payload for EventBudgetExceeded.
+type BudgetExceededPayload struct {
+	Scope             string `json:"scope"` // "conversation" or "daily"
+	Limit             string `json:"limit"` // "dollars", "tokens" or "turns"
+	Used              string `json:"used"`
+	Max               string `json:"max"`
+	ConversationTitle string `json:"conversation_title,omitempty"`
+}
+
+// Description summarizes which budget was reached, for notification bodies.
+func (p BudgetExceededPayload) Description() string {
+	return fmt.Sprintf("The %s budget of %s was reached (%s used). The conversation is paused until the budget is raised.", p.Scope, p.Max, p.Used)
+}
+
+// BudgetThresholdPayload is the payload for EventBudgetThreshold, sent when
+// a budget is close to being reached.
+type BudgetThresholdPayload struct {
+	Scope             string `json:"scope"` // "conversation" or "daily"
+	Limit             string `json:"limit"` // "dollars", "tokens" or "turns"
+	Used              string `json:"used"`
+	Max               string `json:"max"`
+	Percent           int    `json:"percent"`
+	ConversationTitle string `json:"conversation_title,omitempty"`
+}
+
+// Description summarizes how much of the budget is used, for notification bodies.
+func (p BudgetThresholdPayload) Description() string {
+	return fmt.Sprintf("%d%% of the %s budget of %s is used (%s).", p.Percent, p.Scope, p.Max, p.Used)
+}
+
+// ToolApprovalPayload is the payload for EventToolApproval, sent when a tool
+// call is waiting for the user to approve it.
+type ToolApprovalPayload struct {
+	ToolName          string `json:"tool_name"`
+	Summary           string `json:"summary,omitempty"`
+	Reason            string `json:"reason,omitempty"`
+	ConversationTitle string `json:"conversation_title,omitempty"`
+}
+
+// SubagentDonePayload is the payload for EventSubagentDone. The event's
+// ConversationID is the parent conversation.
+type SubagentDonePayload struct {
+	SubagentID    string `json:"subagent_id"`
+	SubagentTitle string `json:"subagent_title,omitempty"`
+	Model         string `json:"model,omitempty"`
+	FinalResponse string `json:"final_response,omitempty"`
+}
+
+// CommandDonePayload is the payload for EventCommandDone, sent when a
+// long-running background command exits.
+type CommandDonePayload struct {
+	Command           string        `json:"command"`
+	ExitCode          int           `json:"exit_code"`
+	Duration          time.Duration `json:"duration"`
+	ConversationTitle string        `json:"conversation_title,omitempty"`
+}
+
+// CompactionPayload is the payload for EventCompaction.
+type CompactionPayload struct {
+	ConversationTitle string `json:"conversation_title,omitempty"`
+	Error             string `json:"error,omitempty"` // set if compaction failed
+}
+
+// UpgradeAvailablePayload is the payload for EventUpgradeAvailable.
+type UpgradeAvailablePayload struct {
+	CurrentVersion string `json:"current_version"`
+	LatestVersion  string `json:"latest_version"`
+}
@@ -0,0 +1,78 @@
+package notifications
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Filter selects which events a channel receives. An empty list matches
+// everything.
+type Filter struct {
+	Events        []EventType
+	Conversations []string
+}
+
+// Match reports whether the filter accepts event. Events that aren't about a
+// conversation, like upgrades, pass the conversation filter.
+func (f Filter) Match(event Event) bool {
+	if len(f.Events) > 0 && !slices.Contains(f.Events, event.Type) {
+		return false
+	}
+	if len(f.Conversations) > 0 && event.ConversationID != "" && !slices.Contains(f.Conversations, event.ConversationID) {
+		return false
+	}
+	return true
+}
+
+// ParseFilter reads a channel's filter from its config's "events" and
+// "conversations" keys. Each is a list, from shelley.json, or a
+// comma-separated string, from the UI.
+func ParseFilter(config map[string]any) (Filter, error) {
+	events, err := stringList(config["events"])
+	if err != nil {
+		return Filter{}, fmt.Errorf("\"events\": %w", err)
+	}
+	conversations, err := stringList(config["conversations"])
+	if err != nil {
+		return Filter{}, fmt.Errorf("\"conversations\": %w", err)
+	}
+
+	var f Filter
+	for _, e := range events {
+		if !slices.Contains(EventTypes, EventType(e)) {
+			return Filter{}, fmt.Errorf("unknown notification event %q", e)
+		}
+		f.Events = append(f.Events, EventType(e))
+	}
+	f.Conversations = conversations
+	return f, nil
+}
+
+func stringList(v any) ([]string, error) {
+	var list []string
+	switch v := v.(type) {
+	case nil:
+	case string:
+		for s := range strings.SplitSeq(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+	case []any:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings")
+			}
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+	case []string:
+		list = v
+	default:
+		return nil, fmt.Errorf("expected a list or a comma-separated string")
+	}
+	return list, nil
+}
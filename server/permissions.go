@@ -0,0 +1,335 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"shelley.exe.dev/claudetool/permission"
+)
+
+// ApprovalRequest is a tool call that is waiting for the user to allow or deny it.
+type ApprovalRequest struct {
+	ID             string `json:"id"`
+	ConversationID string `json:"conversation_id"`
+	// ParentConversationID is set when the call was made by a subagent.
+	ParentConversationID string `json:"parent_conversation_id,omitempty"`
+	Tool                 string `json:"tool"`
+	Input                string `json:"input"`
+	// Summary is the command, path or URL the call would use.
+	Summary   string    `json:"summary"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// pendingApproval is an ApprovalRequest and the tool call blocked on it.
+type pendingApproval struct {
+	ApprovalRequest
+	call     permission.Call
+	decision chan bool // receives true to allow the call, false to deny it
+}
+
+// errApprovalNotFound is returned when resolving an approval that is not pending.
+var errApprovalNotFound = errors.New("approval not found")
+
+// SetPermissions sets the server-wide permission policy, which applies to
+// every conversation along with the conversation's and the project's rules.
+func (s *Server) SetPermissions(policy permission.Policy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.permissions = policy
+}
+
+// conversationPolicy returns the policy a conversation set for itself, if any.
+func (s *Server) conversationPolicy(ctx context.Context, conversationID string) (permission.Policy, error) {
+	row, err := s.db.GetConversationPermissions(ctx, conversationID)
+	if err != nil || row == nil {
+		return permission.Policy{}, err
+	}
+	var p permission.Policy
+	if err := json.Unmarshal([]byte(row.Policy), &p); err != nil {
+		return permission.Policy{}, fmt.Errorf("invalid conversation policy: %w", err)
+	}
+	return p, nil
+}
+
+// permissionPolicy returns the policy for a conversation's tool calls made
+// in workingDir: the server's rules, restricted further by the project's and
+// by those of the conversation and, for a subagent, the conversations that
+// started it. Only the server's policy can allow calls.
+func (s *Server) permissionPolicy(ctx context.Context, conversationID, workingDir string) (permission.Policy, error) {
+	s.mu.Lock()
+	policies := []permission.Policy{s.permissions}
+	s.mu.Unlock()
+	project, _, err := permission.LoadProject(workingDir)
+	if err != nil {
+		return permission.Policy{}, err
+	}
+	policies = append(policies, project.Restrictions())
+	for id := conversationID; id != ""; {
+		conv, err := s.conversationPolicy(ctx, id)
+		if err != nil {
+			return permission.Policy{}, err
+		}
+		policies = append(policies, conv.Restrictions())
+		c, err := s.db.GetConversationByID(ctx, id)
+		if err != nil {
+			return permission.Policy{}, err
+		}
+		id = ""
+		if c.ParentConversationID != nil {
+			id = *c.ParentConversationID
+		}
+	}
+	return permission.Merge(policies...), nil
+}
+
+// checkToolCall is the claudetool.ToolSetConfig.CheckToolCall for a
+// conversation. It returns an error if the call is denied, and blocks
+// while a call the policy asks about waits for the user.
+func (cm *ConversationManager) checkToolCall(ctx context.Context, tool string, input json.RawMessage, workingDir string) error {
+	call := permission.Describe(tool, input, workingDir)
+
+	cm.mu.Lock()
+	allowed, remembered := cm.rememberedDecisions[call.Key()]
+	policyFor := cm.permissionPolicy
+	cm.mu.Unlock()
+
+	if remembered {
+		if !allowed {
+			return fmt.Errorf("the user denied this %s call earlier in this session", tool)
+		}
+		return nil
+	}
+	if policyFor == nil {
+		return nil
+	}
+
+	policy, err := policyFor(ctx, workingDir)
+	if err != nil {
+		// Don't run tools whose policy can't be read; it may deny them.
+		return fmt.Errorf("failed to load permission policy: %w", err)
+	}
+	decision := policy.Evaluate(call)
+	switch decision.Action {
+	case permission.Deny:
+		return fmt.Errorf("permission denied: %s", decision.Reason())
+	case permission.Ask:
+		return cm.awaitApproval(ctx, call, input, decision)
+	}
+	return nil
+}
+
+// awaitApproval publishes an approval request for call and waits for the user to resolve it.
+func (cm *ConversationManager) awaitApproval(ctx context.Context, call permission.Call, input json.RawMessage, decision permission.Decision) error {
+	pending := &pendingApproval{
+		ApprovalRequest: ApprovalRequest{
+			ID:             rand.Text(),
+			ConversationID: cm.conversationID,
+			Tool:           call.Tool,
+			Input:          string(input),
+			Summary:        call.Summary(),
+			Reason:         decision.Reason(),
+			CreatedAt:      time.Now(),
+		},
+		call:     call,
+		decision: make(chan bool, 1),
+	}
+
+	cm.mu.Lock()
+	pending.ParentConversationID = cm.parentConversationID
+	cm.approvals = append(cm.approvals, pending)
+	cm.lastActivity = time.Now()
+	cm.mu.Unlock()
+
+	cm.logger.Info("Tool call awaiting approval", "approvalID", pending.ID, "tool", call.Tool, "summary", pending.Summary)
+	request := pending.ApprovalRequest
+	cm.subpub.Broadcast(StreamResponse{ApprovalRequest: &request})
+	cm.publishState()
+	if cm.onApprovalRequest != nil {
+		cm.onApprovalRequest(request)
+	}
+
+	var allowed bool
+	var err error
+	select {
+	case allowed = <-pending.decision:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	cm.mu.Lock()
+	cm.approvals = slices.DeleteFunc(cm.approvals, func(p *pendingApproval) bool { return p == pending })
+	cm.lastActivity = time.Now()
+	cm.mu.Unlock()
+	cm.publishState()
+
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return errors.New("the user denied this tool call")
+	}
+	return nil
+}
+
+// ResolveApproval allows or denies a pending tool call. If remember is set,
+// the decision also applies to identical calls for the rest of the session.
+func (cm *ConversationManager) ResolveApproval(id string, allow, remember bool) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	idx := slices.IndexFunc(cm.approvals, func(p *pendingApproval) bool { return p.ID == id })
+	if idx < 0 {
+		return errApprovalNotFound
+	}
+	resolved := cm.approvals[idx]
+	if remember {
+		if cm.rememberedDecisions == nil {
+			cm.rememberedDecisions = make(map[string]bool)
+		}
+		cm.rememberedDecisions[resolved.call.Key()] = allow
+	}
+	for _, p := range cm.approvals {
+		if p == resolved || (remember && p.call.Key() == resolved.call.Key()) {
+			select {
+			case p.decision <- allow:
+			default: // already resolved
+			}
+		}
+	}
+	return nil
+}
+
+// PendingApprovals returns the tool calls waiting for the user, oldest first.
+func (cm *ConversationManager) PendingApprovals() []ApprovalRequest {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.pendingApprovalsLocked()
+}
+
+func (cm *ConversationManager) pendingApprovalsLocked() []ApprovalRequest {
+	var requests []ApprovalRequest
+	for _, p := range cm.approvals {
+		requests = append(requests, p.ApprovalRequest)
+	}
+	return requests
+}
+
+// PermissionsResponse is the response for /api/conversation/{id}/permissions
+type PermissionsResponse struct {
+	// Conversation is the conversation's own policy, which is edited with POST.
+	// It can only restrict the server's policy, so its allow rules are ignored.
+	Conversation permission.Policy `json:"conversation"`
+	// Project is the policy from the project's .shelley/permissions.json, if any.
+	Project     permission.Policy `json:"project"`
+	ProjectFile string            `json:"project_file,omitempty"`
+	// Server is the server-wide policy from shelley.json.
+	Server permission.Policy `json:"server"`
+}
+
+// handleGetPermissions returns the policies that apply to a conversation's tool calls.
+func (s *Server) handleGetPermissions(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	conv, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	var resp PermissionsResponse
+	resp.Conversation, err = s.conversationPolicy(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation permissions", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if conv.Cwd != nil {
+		resp.Project, resp.ProjectFile, err = permission.LoadProject(*conv.Cwd)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	s.mu.Lock()
+	resp.Server = s.permissions
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleSetPermissions sets a conversation's own permission policy.
+func (s *Server) handleSetPermissions(w http.ResponseWriter, r *http.Request, conversationID string) {
+	var req permission.Policy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := s.db.GetConversationByID(r.Context(), conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.db.SetConversationPermissions(r.Context(), conversationID, string(data)); err != nil {
+		s.logger.Error("Failed to set conversation permissions", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// ResolveApprovalRequest is the request body for /api/conversation/{id}/approvals/{approval}
+type ResolveApprovalRequest struct {
+	Decision permission.Action `json:"decision"` // "allow" or "deny"
+	// Remember applies the decision to identical calls for the rest of the session.
+	Remember bool `json:"remember,omitempty"`
+}
+
+// handleResolveApproval allows or denies a tool call that is waiting for approval.
+func (s *Server) handleResolveApproval(w http.ResponseWriter, r *http.Request, conversationID, approvalID string) {
+	var req ResolveApprovalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Decision != permission.Allow && req.Decision != permission.Deny {
+		http.Error(w, `Decision must be "allow" or "deny"`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	manager, ok := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "Approval not found", http.StatusNotFound)
+		return
+	}
+	if err := manager.ResolveApproval(approvalID, req.Decision == permission.Allow, req.Remember); err != nil {
+		if errors.Is(err, errApprovalNotFound) {
+			http.Error(w, "Approval not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "resolved"})
+}
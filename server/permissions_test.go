@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/claudetool/permission"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// waitApproval waits until the conversation has a tool call waiting for approval.
+func waitApproval(t *testing.T, h *TestHarness) ApprovalRequest {
+	t.Helper()
+	deadline := time.Now().Add(h.timeout)
+	for time.Now().Before(deadline) {
+		h.server.mu.Lock()
+		manager := h.server.activeConversations[h.convID]
+		h.server.mu.Unlock()
+		if manager != nil {
+			if pending := manager.PendingApprovals(); len(pending) > 0 {
+				return pending[0]
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for an approval request")
+	return ApprovalRequest{}
+}
+
+func resolveApproval(t *testing.T, h *TestHarness, approvalID, body string) int {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/"+h.convID+"/approvals/"+approvalID, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.server.conversationMux().ServeHTTP(w, req)
+	return w.Code
+}
+
+// lastToolResult returns the text of the conversation's most recent tool result.
+func lastToolResult(t *testing.T, h *TestHarness) string {
+	t.Helper()
+	var messages []generated.Message
+	err := h.db.Queries(context.Background(), func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(context.Background(), h.convID)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last string
+	for _, msg := range messages {
+		if msg.LlmData == nil {
+			continue
+		}
+		var llmMsg llm.Message
+		if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil {
+			continue
+		}
+		for _, c := range llmMsg.Content {
+			if c.Type == llm.ContentTypeToolResult && len(c.ToolResult) > 0 {
+				last = c.ToolResult[0].Text
+			}
+		}
+	}
+	return last
+}
+
+func TestToolApproval(t *testing.T) {
+	h := NewTestHarness(t)
+	h.server.SetPermissions(permission.Policy{Rules: []permission.Rule{
+		{Action: permission.Ask, Command: "echo"},
+		{Action: permission.Deny, Command: "rm", Reason: "no deleting"},
+	}})
+
+	h.NewConversation("bash: echo approved", t.TempDir())
+	approval := waitApproval(t, h)
+	if approval.Tool != "bash" || approval.Summary != "echo approved" || approval.ConversationID != h.convID {
+		t.Fatalf("unexpected approval request: %+v", approval)
+	}
+
+	h.server.mu.Lock()
+	manager := h.server.activeConversations[h.convID]
+	h.server.mu.Unlock()
+	if state := manager.State(); !state.Working || len(state.PendingApprovals) != 1 {
+		t.Fatalf("expected the state to include the pending approval, got %+v", state)
+	}
+
+	if code := resolveApproval(t, h, "nonexistent", `{"decision":"allow"}`); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown approval, got %d", code)
+	}
+	if code := resolveApproval(t, h, approval.ID, `{"decision":"maybe"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid decision, got %d", code)
+	}
+	if code := resolveApproval(t, h, approval.ID, `{"decision":"allow","remember":true}`); code != http.StatusOK {
+		t.Fatalf("expected 200 resolving the approval, got %d", code)
+	}
+	h.WaitResponse()
+	if got := lastToolResult(t, h); !strings.Contains(got, "approved") {
+		t.Fatalf("expected the approved command to run, got %q", got)
+	}
+	if pending := manager.PendingApprovals(); len(pending) != 0 {
+		t.Fatalf("expected no pending approvals, got %+v", pending)
+	}
+
+	// The remembered decision applies to the same call without asking again.
+	h.Chat("bash: echo approved")
+	h.WaitResponse()
+	if got := lastToolResult(t, h); !strings.Contains(got, "approved") {
+		t.Fatalf("expected the remembered command to run, got %q", got)
+	}
+
+	// A different call is asked about again, and can be denied.
+	h.Chat("bash: echo other")
+	approval = waitApproval(t, h)
+	if code := resolveApproval(t, h, approval.ID, `{"decision":"deny"}`); code != http.StatusOK {
+		t.Fatalf("expected 200 denying the approval, got %d", code)
+	}
+	h.WaitResponse()
+	if got := lastToolResult(t, h); !strings.Contains(got, "denied") {
+		t.Fatalf("expected the denied command not to run, got %q", got)
+	}
+
+	h.Chat("bash: rm -rf nothing")
+	h.WaitResponse()
+	if got := lastToolResult(t, h); !strings.Contains(got, "no deleting") {
+		t.Fatalf("expected the rule's reason, got %q", got)
+	}
+}
+
+func TestConversationPermissions(t *testing.T) {
+	h := NewTestHarness(t)
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+
+	post := func(body string) int {
+		req := httptest.NewRequest("POST", "/"+h.convID+"/permissions", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.server.conversationMux().ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := post(`{"rules":[{"action":"sometimes"}]}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid policy, got %d", code)
+	}
+	if code := post(`{"rules":[{"action":"deny","command":"echo"}]}`); code != http.StatusOK {
+		t.Fatalf("expected 200 setting the policy, got %d", code)
+	}
+
+	req := httptest.NewRequest("GET", "/"+h.convID+"/permissions", nil)
+	w := httptest.NewRecorder()
+	h.server.conversationMux().ServeHTTP(w, req)
+	var resp PermissionsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse permissions: %v", err)
+	}
+	if len(resp.Conversation.Rules) != 1 || resp.Conversation.Rules[0].Command != "echo" {
+		t.Fatalf("unexpected conversation policy: %+v", resp.Conversation)
+	}
+
+	h.Chat("bash: echo hi")
+	h.WaitResponse()
+	if got := lastToolResult(t, h); !strings.Contains(got, "permission denied") {
+		t.Fatalf("expected the conversation's rule to deny the call, got %q", got)
+	}
+}
+
+func TestConversationPermissionsOnlyRestrict(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+	h.server.SetPermissions(permission.Policy{Default: permission.Ask})
+
+	parent, err := h.db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subagent, err := h.db.CreateSubagentConversation(ctx, "helper", parent.ConversationID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.db.SetConversationPermissions(ctx, parent.ConversationID, `{"default":"allow","rules":[{"action":"allow","tool":"bash"},{"action":"deny","command":"rm"}]}`); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for _, id := range []string{parent.ConversationID, subagent.ConversationID} {
+		policy, err := h.server.permissionPolicy(ctx, id, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ls := permission.Describe("bash", json.RawMessage(`{"command":"ls"}`), dir)
+		if got := policy.Evaluate(ls); got.Action != permission.Ask {
+			t.Errorf("%s: ls got %s, want the server's ask despite the conversation's allow", id, got.Action)
+		}
+		rm := permission.Describe("bash", json.RawMessage(`{"command":"rm -rf x"}`), dir)
+		if got := policy.Evaluate(rm); got.Action != permission.Deny {
+			t.Errorf("%s: rm got %s, want the parent conversation's deny", id, got.Action)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"shelley.exe.dev/claudetool/sandbox"
+)
+
+// SetSandbox sets the server's sandbox configuration. Conversations may
+// choose a different mode; the rest of the configuration applies to all.
+func (s *Server) SetSandbox(cfg sandbox.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sandbox = cfg
+}
+
+// checkSandboxAvailable reports whether namespace mode works on this system.
+// The check runs a command, so its result is kept for the server's lifetime.
+func (s *Server) checkSandboxAvailable(ctx context.Context) error {
+	s.sandboxCheck.Do(func() {
+		s.sandboxErr = sandbox.Available(context.WithoutCancel(ctx))
+	})
+	return s.sandboxErr
+}
+
+// conversationSandboxMode returns the mode a conversation chose for itself, or
+// else the one its parent chose, or "" if neither chose one.
+func (s *Server) conversationSandboxMode(ctx context.Context, conversationID string) (sandbox.Mode, error) {
+	row, err := s.db.GetConversationSandbox(ctx, conversationID)
+	if err != nil {
+		return "", err
+	}
+	if row != nil {
+		return sandbox.Mode(row.Mode), nil
+	}
+	conv, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil || conv.ParentConversationID == nil {
+		return "", err
+	}
+	row, err = s.db.GetConversationSandbox(ctx, *conv.ParentConversationID)
+	if err != nil || row == nil {
+		return "", err
+	}
+	return sandbox.Mode(row.Mode), nil
+}
+
+// sandboxConfig returns the sandbox configuration for a conversation's commands.
+func (s *Server) sandboxConfig(ctx context.Context, conversationID string) (sandbox.Config, error) {
+	s.mu.Lock()
+	cfg := s.sandbox
+	if s.socketPath != "" {
+		// The socket takes API requests without authentication.
+		cfg.Hide = []string{s.socketPath}
+	}
+	s.mu.Unlock()
+	mode, err := s.conversationSandboxMode(ctx, conversationID)
+	if err != nil {
+		return cfg, err
+	}
+	if mode != "" {
+		cfg.Mode = mode
+	}
+	return cfg, nil
+}
+
+// sandboxExecutor returns the executor for a conversation's commands.
+func (s *Server) sandboxExecutor(ctx context.Context, conversationID string) sandbox.Executor {
+	cfg, err := s.sandboxConfig(ctx, conversationID)
+	if err != nil {
+		// Fail the command rather than guess: the conversation may have chosen
+		// a stricter mode than the server's.
+		s.logger.Error("Failed to get conversation sandbox", "conversationID", conversationID, "error", err)
+		return sandbox.Failed(fmt.Errorf("failed to get the conversation's sandbox: %w", err))
+	}
+	return sandbox.New(cfg)
+}
+
+// SandboxResponse is the response for /api/conversation/{id}/sandbox
+type SandboxResponse struct {
+	// Mode is the mode the conversation's commands run in.
+	Mode sandbox.Mode `json:"mode"`
+	// ConversationMode is the mode the conversation chose, if any.
+	ConversationMode sandbox.Mode `json:"conversation_mode,omitempty"`
+	// Server is the server's sandbox configuration from shelley.json.
+	Server sandbox.Config `json:"server"`
+	// Unavailable explains why namespace mode does not work on this system, if it doesn't.
+	Unavailable string `json:"unavailable,omitempty"`
+}
+
+// SetSandboxRequest is the request body for POST /api/conversation/{id}/sandbox
+type SetSandboxRequest struct {
+	Mode sandbox.Mode `json:"mode"`
+}
+
+// validateSandboxMode checks that mode is one a conversation may choose.
+func (s *Server) validateSandboxMode(ctx context.Context, mode sandbox.Mode) (int, string) {
+	switch mode {
+	case sandbox.ModeHost:
+	case sandbox.ModeNamespace:
+		if err := s.checkSandboxAvailable(ctx); err != nil {
+			return http.StatusUnprocessableEntity, err.Error()
+		}
+	default:
+		return http.StatusBadRequest, `Mode must be "host" or "namespace"`
+	}
+	return 0, ""
+}
+
+// handleGetSandbox returns how a conversation's commands run.
+func (s *Server) handleGetSandbox(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	cfg, err := s.sandboxConfig(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation sandbox", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp := SandboxResponse{Mode: cfg.Mode}
+	if resp.Mode == "" {
+		resp.Mode = sandbox.ModeHost
+	}
+	resp.ConversationMode, _ = s.conversationSandboxMode(ctx, conversationID)
+	s.mu.Lock()
+	resp.Server = s.sandbox
+	s.mu.Unlock()
+	if err := s.checkSandboxAvailable(ctx); err != nil {
+		resp.Unavailable = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleSetSandbox sets the mode a conversation's commands run in. It takes
+// effect from the next command.
+func (s *Server) handleSetSandbox(w http.ResponseWriter, r *http.Request, conversationID string) {
+	var req SetSandboxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	if code, msg := s.validateSandboxMode(ctx, req.Mode); code != 0 {
+		http.Error(w, msg, code)
+		return
+	}
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err := s.db.SetConversationSandbox(ctx, conversationID, string(req.Mode)); err != nil {
+		s.logger.Error("Failed to set conversation sandbox", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.handleGetSandbox(w, r, conversationID)
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/claudetool/sandbox"
+)
+
+func TestSandboxEndpoints(t *testing.T) {
+	h := NewTestHarness(t)
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+
+	serve := func(method, body string) (*httptest.ResponseRecorder, SandboxResponse) {
+		req := httptest.NewRequest(method, "/"+h.convID+"/sandbox", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.server.conversationMux().ServeHTTP(w, req)
+		var resp SandboxResponse
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to parse sandbox: %v", err)
+			}
+		}
+		return w, resp
+	}
+
+	if w, resp := serve("GET", ""); w.Code != http.StatusOK || resp.Mode != sandbox.ModeHost || resp.ConversationMode != "" {
+		t.Fatalf("expected host mode by default, got %d %+v", w.Code, resp)
+	}
+	if w, _ := serve("POST", `{"mode":"jail"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown mode, got %d", w.Code)
+	}
+
+	// The server's mode applies until the conversation chooses one.
+	h.server.SetSandbox(sandbox.Config{Mode: sandbox.ModeNamespace, ShareNetwork: true})
+	if _, resp := serve("GET", ""); resp.Mode != sandbox.ModeNamespace || !resp.Server.ShareNetwork {
+		t.Fatalf("expected the server's namespace mode, got %+v", resp)
+	}
+	if _, ok := h.server.sandboxExecutor(context.Background(), h.convID).(*sandbox.Namespace); !ok {
+		t.Fatal("expected a namespace executor")
+	}
+	if w, resp := serve("POST", `{"mode":"host"}`); w.Code != http.StatusOK || resp.Mode != sandbox.ModeHost || resp.ConversationMode != sandbox.ModeHost {
+		t.Fatalf("expected the conversation's host mode, got %d %+v", w.Code, resp)
+	}
+	if _, ok := h.server.sandboxExecutor(context.Background(), h.convID).(sandbox.Host); !ok {
+		t.Fatal("expected a host executor")
+	}
+
+	// Subagents follow their parent's choice.
+	ctx := context.Background()
+	child, err := h.db.CreateSubagentConversation(ctx, "child", h.convID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h.server.sandboxExecutor(ctx, child.ConversationID).(sandbox.Host); !ok {
+		t.Fatal("expected the subagent to inherit host mode")
+	}
+
+	w := httptest.NewRecorder()
+	h.server.conversationMux().ServeHTTP(w, httptest.NewRequest("GET", "/nope/sandbox", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown conversation, got %d", w.Code)
+	}
+}
+
+func TestSandboxDeniesPatchOutsideWorktree(t *testing.T) {
+	h := NewTestHarness(t)
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+
+	// Store the mode directly: checking writes doesn't need namespace support.
+	if err := h.db.SetConversationSandbox(context.Background(), h.convID, string(sandbox.ModeNamespace)); err != nil {
+		t.Fatal(err)
+	}
+	executor := h.server.sandboxExecutor(context.Background(), h.convID)
+	if err := executor.CheckWrite("/etc/shelley-sandbox-test", t.TempDir()); err == nil {
+		t.Fatal("expected a write outside the worktree to be denied")
+	}
+}
+
+func TestSandboxExecutorFailsClosed(t *testing.T) {
+	h := NewTestHarness(t)
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+
+	// A cancelled context makes looking up the conversation's mode fail.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	executor := h.server.sandboxExecutor(ctx, h.convID)
+	if err := executor.CheckWrite(filepath.Join(t.TempDir(), "file.txt"), t.TempDir()); err == nil {
+		t.Error("expected writes to be denied")
+	}
+	if err := executor.Command(context.Background(), "true", t.TempDir(), nil).Run(); err == nil {
+		t.Error("expected commands to fail")
+	}
+}
@@ -0,0 +1,392 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/server/cron"
+)
+
+// schedulerInterval is how often the scheduler looks for due tasks.
+const schedulerInterval = 30 * time.Second
+
+type ScheduledTaskAPI struct {
+	TaskID                string     `json:"task_id"`
+	Name                  string     `json:"name"`
+	Cron                  string     `json:"cron"`
+	Prompt                string     `json:"prompt"`
+	Model                 *string    `json:"model"`
+	Cwd                   *string    `json:"cwd"`
+	NotificationChannelID *string    `json:"notification_channel_id"`
+	Enabled               bool       `json:"enabled"`
+	LastRunAt             *time.Time `json:"last_run_at"`
+	NextRunAt             *time.Time `json:"next_run_at"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+// ScheduledTaskRequest creates or updates a scheduled task. Enabled defaults
+// to true for new tasks and is left unchanged on update when omitted.
+type ScheduledTaskRequest struct {
+	Name                  string  `json:"name"`
+	Cron                  string  `json:"cron"`
+	Prompt                string  `json:"prompt"`
+	Model                 *string `json:"model"`
+	Cwd                   *string `json:"cwd"`
+	NotificationChannelID *string `json:"notification_channel_id"`
+	Enabled               *bool   `json:"enabled"`
+}
+
+func toScheduledTaskAPI(t generated.ScheduledTask) ScheduledTaskAPI {
+	return ScheduledTaskAPI{
+		TaskID:                t.TaskID,
+		Name:                  t.Name,
+		Cron:                  t.Cron,
+		Prompt:                t.Prompt,
+		Model:                 t.Model,
+		Cwd:                   t.Cwd,
+		NotificationChannelID: t.NotificationChannelID,
+		Enabled:               t.Enabled != 0,
+		LastRunAt:             t.LastRunAt,
+		NextRunAt:             t.NextRunAt,
+		CreatedAt:             t.CreatedAt,
+		UpdatedAt:             t.UpdatedAt,
+	}
+}
+
+// emptyToNil returns nil for a missing or blank string.
+func emptyToNil(s *string) *string {
+	if s == nil || strings.TrimSpace(*s) == "" {
+		return nil
+	}
+	v := strings.TrimSpace(*s)
+	return &v
+}
+
+// validateScheduledTask checks and normalizes a request, returning the time
+// the task next fires, or nil if it never does. On failure, it returns an
+// HTTP status and message.
+func (s *Server) validateScheduledTask(ctx context.Context, req *ScheduledTaskRequest) (*time.Time, int, string) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Cron = strings.TrimSpace(req.Cron)
+	req.Model = emptyToNil(req.Model)
+	req.Cwd = emptyToNil(req.Cwd)
+	req.NotificationChannelID = emptyToNil(req.NotificationChannelID)
+	if req.Name == "" || req.Cron == "" || strings.TrimSpace(req.Prompt) == "" {
+		return nil, http.StatusBadRequest, "name, cron and prompt are required"
+	}
+	schedule, err := cron.Parse(req.Cron)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Sprintf("Invalid cron expression: %v", err)
+	}
+	if req.Model != nil {
+		if _, err := s.llmManager.GetService(*req.Model); err != nil {
+			return nil, http.StatusBadRequest, fmt.Sprintf("Unsupported model: %s", *req.Model)
+		}
+	}
+	if req.NotificationChannelID != nil {
+		if _, err := s.db.GetNotificationChannel(ctx, *req.NotificationChannelID); err != nil {
+			return nil, http.StatusBadRequest, fmt.Sprintf("Unknown notification channel: %s", *req.NotificationChannelID)
+		}
+	}
+	return nextRunAt(schedule, time.Now()), 0, ""
+}
+
+// nextRunAt returns when a schedule next fires after t, in UTC, or nil if it
+// never does.
+func nextRunAt(schedule *cron.Schedule, t time.Time) *time.Time {
+	next := schedule.Next(t)
+	if next.IsZero() {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
+
+func (s *Server) handleScheduledTasks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		tasks, err := s.db.ListScheduledTasks(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get scheduled tasks: %v", err), http.StatusInternalServerError)
+			return
+		}
+		apiTasks := make([]ScheduledTaskAPI, len(tasks))
+		for i, t := range tasks {
+			apiTasks[i] = toScheduledTaskAPI(t)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(apiTasks)
+	case http.MethodPost:
+		s.handleCreateScheduledTask(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleCreateScheduledTask(w http.ResponseWriter, r *http.Request) {
+	var req ScheduledTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	next, code, msg := s.validateScheduledTask(r.Context(), &req)
+	if code != 0 {
+		http.Error(w, msg, code)
+		return
+	}
+
+	enabled := int64(1)
+	if req.Enabled != nil && !*req.Enabled {
+		enabled = 0
+	}
+	task, err := s.db.CreateScheduledTask(r.Context(), generated.CreateScheduledTaskParams{
+		TaskID:                "task-" + uuid.New().String()[:8],
+		Name:                  req.Name,
+		Cron:                  req.Cron,
+		Prompt:                req.Prompt,
+		Model:                 req.Model,
+		Cwd:                   req.Cwd,
+		NotificationChannelID: req.NotificationChannelID,
+		Enabled:               enabled,
+		NextRunAt:             next,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create scheduled task: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(toScheduledTaskAPI(*task))
+}
+
+func (s *Server) handleScheduledTask(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/scheduled-tasks/")
+	taskID, action, _ := strings.Cut(path, "/")
+	if taskID == "" {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	switch action {
+	case "":
+	case "run":
+		if r.Method == http.MethodPost {
+			s.handleRunScheduledTask(w, r, taskID)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	case "runs":
+		if r.Method == http.MethodGet {
+			s.handleScheduledTaskRuns(w, r, taskID)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		task, err := s.db.GetScheduledTask(r.Context(), taskID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Task not found: %v", err), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toScheduledTaskAPI(*task))
+	case http.MethodPut:
+		s.handleUpdateScheduledTask(w, r, taskID)
+	case http.MethodDelete:
+		if err := s.db.DeleteScheduledTask(r.Context(), taskID); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to delete scheduled task: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleUpdateScheduledTask(w http.ResponseWriter, r *http.Request, taskID string) {
+	existing, err := s.db.GetScheduledTask(r.Context(), taskID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Task not found: %v", err), http.StatusNotFound)
+		return
+	}
+
+	var req ScheduledTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	next, code, msg := s.validateScheduledTask(r.Context(), &req)
+	if code != 0 {
+		http.Error(w, msg, code)
+		return
+	}
+
+	enabled := existing.Enabled
+	if req.Enabled != nil {
+		enabled = 0
+		if *req.Enabled {
+			enabled = 1
+		}
+	}
+	task, err := s.db.UpdateScheduledTask(r.Context(), generated.UpdateScheduledTaskParams{
+		Name:                  req.Name,
+		Cron:                  req.Cron,
+		Prompt:                req.Prompt,
+		Model:                 req.Model,
+		Cwd:                   req.Cwd,
+		NotificationChannelID: req.NotificationChannelID,
+		Enabled:               enabled,
+		NextRunAt:             next,
+		TaskID:                taskID,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update scheduled task: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toScheduledTaskAPI(*task))
+}
+
+// handleRunScheduledTask handles POST /api/scheduled-tasks/<id>/run, which
+// runs a task now without changing its schedule.
+func (s *Server) handleRunScheduledTask(w http.ResponseWriter, r *http.Request, taskID string) {
+	task, err := s.db.GetScheduledTask(r.Context(), taskID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Task not found: %v", err), http.StatusNotFound)
+		return
+	}
+	conversationID, err := s.runScheduledTask(context.WithoutCancel(r.Context()), *task)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to run scheduled task: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":          "accepted",
+		"conversation_id": conversationID,
+	})
+}
+
+// handleScheduledTaskRuns handles GET /api/scheduled-tasks/<id>/runs, listing
+// the conversations the task started, newest first.
+func (s *Server) handleScheduledTaskRuns(w http.ResponseWriter, r *http.Request, taskID string) {
+	conversations, err := s.db.ListScheduledTaskConversations(r.Context(), taskID, 50)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get runs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversations)
+}
+
+// schedulerRoutine runs scheduled tasks as they come due. Schedules live in
+// the database, so a task due while the server was down, restarting or
+// upgrading runs once when it comes back.
+func (s *Server) schedulerRoutine() {
+	s.runDueScheduledTasks(time.Now())
+
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.runDueScheduledTasks(now)
+		case <-s.shutdownCh:
+			return
+		}
+	}
+}
+
+// runDueScheduledTasks starts a run of every enabled task due at now.
+func (s *Server) runDueScheduledTasks(now time.Time) {
+	ctx := context.Background()
+	tasks, err := s.db.ListDueScheduledTasks(ctx, now.UTC())
+	if err != nil {
+		s.logger.Error("Failed to list due scheduled tasks", "error", err)
+		return
+	}
+	for _, task := range tasks {
+		// Advance the schedule before running, so a crash mid-run doesn't
+		// run the task again, and missed runs collapse into this one.
+		var next *time.Time
+		if schedule, err := cron.Parse(task.Cron); err != nil {
+			s.logger.Error("Invalid scheduled task cron expression", "task", task.TaskID, "cron", task.Cron, "error", err)
+		} else {
+			next = nextRunAt(schedule, now)
+		}
+		if err := s.db.SetScheduledTaskRun(ctx, task.TaskID, now.UTC(), next); err != nil {
+			s.logger.Error("Failed to update scheduled task", "task", task.TaskID, "error", err)
+			continue
+		}
+		if _, err := s.runScheduledTask(ctx, task); err != nil {
+			s.logger.Error("Failed to run scheduled task", "task", task.TaskID, "error", err)
+		}
+	}
+}
+
+// runScheduledTask starts a new conversation for a task, sending its prompt
+// as the first message, and returns the conversation's ID.
+func (s *Server) runScheduledTask(ctx context.Context, task generated.ScheduledTask) (string, error) {
+	modelID := s.mcpModel("", task.Model)
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		return "", fmt.Errorf("unsupported model %s: %w", modelID, err)
+	}
+
+	req := ChatRequest{Message: task.Prompt}
+	if task.Cwd != nil {
+		req.Cwd = *task.Cwd
+	}
+	conversation, err := s.createConversation(ctx, req, modelID, nil)
+	if err != nil {
+		return "", err
+	}
+	conversationID := conversation.ConversationID
+	if err := s.db.SetConversationScheduledTask(ctx, conversationID, task.TaskID); err != nil {
+		return "", err
+	}
+	if conversation, err := s.db.GetConversationByID(ctx, conversationID); err == nil {
+		go s.publishConversationListUpdate(ConversationListUpdate{
+			Type:         "update",
+			Conversation: conversation,
+		})
+	}
+
+	s.logger.Info("Running scheduled task", "task", task.TaskID, "name", task.Name, "conversationID", conversationID)
+	if err := s.acceptUserMessage(ctx, conversationID, "", llmService, modelID, task.Prompt, nil, nil); err != nil {
+		return "", err
+	}
+	return conversationID, nil
+}
+
+// scheduledTaskChannel returns the notification channel a conversation's
+// scheduled task reports to, if any.
+func (s *Server) scheduledTaskChannel(ctx context.Context, conv *generated.Conversation) string {
+	if conv.ScheduledTaskID == nil {
+		return ""
+	}
+	task, err := s.db.GetScheduledTask(ctx, *conv.ScheduledTaskID)
+	if err != nil || task.NotificationChannelID == nil {
+		return ""
+	}
+	return *task.NotificationChannelID
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+func TestScheduledTasks(t *testing.T) {
+	h := NewTestHarness(t)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/scheduled-tasks", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.server.handleScheduledTasks(w, req)
+		return w
+	}
+	for _, body := range []string{
+		`{"name": "Nightly", "cron": "0 3 * * *"}`,
+		`{"name": "Nightly", "cron": "0 3 * *", "prompt": "echo: hi"}`,
+		`{"name": "Nightly", "cron": "0 3 * * *", "prompt": "echo: hi", "notification_channel_id": "nope"}`,
+	} {
+		if w := create(body); w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+
+	w := create(`{"name": "Nightly", "cron": "0 3 * * *", "prompt": "echo: scheduled", "model": "predictable"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("failed to create task: %d: %s", w.Code, w.Body.String())
+	}
+	var task ScheduledTaskAPI
+	json.Unmarshal(w.Body.Bytes(), &task)
+	if !task.Enabled || task.NextRunAt == nil || !task.NextRunAt.After(time.Now()) {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+
+	// Nothing is due yet.
+	h.server.runDueScheduledTasks(time.Now())
+	if convs, _ := h.db.ListScheduledTaskConversations(t.Context(), task.TaskID, 10); len(convs) != 0 {
+		t.Fatalf("expected no runs, got %d", len(convs))
+	}
+
+	now := task.NextRunAt.Add(time.Minute)
+	h.server.runDueScheduledTasks(now)
+	updated, err := h.db.GetScheduledTask(t.Context(), task.TaskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.LastRunAt == nil || updated.LastRunAt.Sub(now).Abs() > time.Second {
+		t.Errorf("expected last_run_at %s, got %v", now, updated.LastRunAt)
+	}
+	if updated.NextRunAt == nil || !updated.NextRunAt.After(now) {
+		t.Errorf("expected next_run_at after %s, got %v", now, updated.NextRunAt)
+	}
+	// The run is recorded, so running again at the same time does nothing.
+	h.server.runDueScheduledTasks(now)
+
+	req := httptest.NewRequest("GET", "/api/scheduled-tasks/"+task.TaskID+"/runs", nil)
+	w = httptest.NewRecorder()
+	h.server.handleScheduledTask(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("failed to list runs: %d: %s", w.Code, w.Body.String())
+	}
+	var runs []generated.Conversation
+	if err := json.Unmarshal(w.Body.Bytes(), &runs); err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].ScheduledTaskID == nil || *runs[0].ScheduledTaskID != task.TaskID {
+		t.Fatalf("expected one run linked to the task, got %+v", runs)
+	}
+	h.convID = runs[0].ConversationID
+	if resp := h.WaitResponse(); resp != "scheduled" {
+		t.Errorf("unexpected response: %q", resp)
+	}
+
+	// Disabling the task keeps its schedule but stops runs.
+	req = httptest.NewRequest("PUT", "/api/scheduled-tasks/"+task.TaskID, strings.NewReader(`{"name": "Nightly", "cron": "@hourly", "prompt": "echo: scheduled", "enabled": false}`))
+	w = httptest.NewRecorder()
+	h.server.handleScheduledTask(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("failed to update task: %d: %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &task)
+	if task.Enabled || task.Cron != "@hourly" || task.Model != nil {
+		t.Errorf("unexpected updated task: %+v", task)
+	}
+	h.server.runDueScheduledTasks(time.Now().Add(48 * time.Hour))
+	if convs, _ := h.db.ListScheduledTaskConversations(t.Context(), task.TaskID, 10); len(convs) != 1 {
+		t.Errorf("expected a disabled task not to run, got %d runs", len(convs))
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/scheduled-tasks/"+task.TaskID, nil)
+	w = httptest.NewRecorder()
+	h.server.handleScheduledTask(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("failed to delete task: %d: %s", w.Code, w.Body.String())
+	}
+	req = httptest.NewRequest("GET", "/api/scheduled-tasks/"+task.TaskID, nil)
+	w = httptest.NewRecorder()
+	h.server.handleScheduledTask(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", w.Code)
+	}
+}
@@ -21,9 +21,13 @@ import (
 	"tailscale.com/util/singleflight"
 
 	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/claudetool/mcp"
+	"shelley.exe.dev/claudetool/permission"
+	"shelley.exe.dev/claudetool/sandbox"
 	"shelley.exe.dev/db"
 	"shelley.exe.dev/db/generated"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
 	"shelley.exe.dev/models"
 	"shelley.exe.dev/server/notifications"
 	"shelley.exe.dev/ui"
@@ -50,6 +54,8 @@ type ConversationState struct {
 	ConversationID string `json:"conversation_id"`
 	Working        bool   `json:"working"`
 	Model          string `json:"model,omitempty"`
+	// PendingApprovals are the tool calls waiting for the user to allow or deny them.
+	PendingApprovals []ApprovalRequest `json:"pending_approvals,omitempty"`
 }
 
 // ConversationWithState combines a conversation with its working state.
@@ -60,6 +66,11 @@ type ConversationWithState struct {
 	GitWorktreeRoot string `json:"git_worktree_root,omitempty"`
 	GitCommit       string `json:"git_commit,omitempty"`
 	GitSubject      string `json:"git_subject,omitempty"`
+	// MatchSequenceID and MatchSnippet describe the best matching message
+	// when listing conversations by content search. Matches in the snippet
+	// are wrapped in db.SearchSnippetStart and db.SearchSnippetEnd.
+	MatchSequenceID *int64 `json:"match_sequence_id,omitempty"`
+	MatchSnippet    string `json:"match_snippet,omitempty"`
 }
 
 // StreamResponse represents the response format for conversation streaming
@@ -74,6 +85,72 @@ type StreamResponse struct {
 	Heartbeat bool `json:"heartbeat,omitempty"`
 	// NotificationEvent is set when a notification-worthy event occurs (e.g. agent finished).
 	NotificationEvent *notifications.Event `json:"notification_event,omitempty"`
+	// StreamDelta is set when the agent is streaming partial output for a response
+	// that has not been recorded yet. Deltas may be dropped for slow subscribers;
+	// the gap is then filled by a StreamResync.
+	StreamDelta *StreamDelta `json:"stream_delta,omitempty"`
+	// StreamResync is set when a subscriber missed stream deltas.
+	StreamResync *StreamResync `json:"stream_resync,omitempty"`
+	// ToolProgress is set when a running tool call has new output. Like deltas,
+	// it is best-effort and superseded by the recorded tool result.
+	ToolProgress *ToolProgress `json:"tool_progress,omitempty"`
+	// ApprovalRequest is set when a tool call starts waiting for the user to allow or deny it.
+	ApprovalRequest *ApprovalRequest `json:"approval_request,omitempty"`
+}
+
+// StreamDelta is a piece of partial LLM output sent to clients while a response streams in.
+// Clients accumulate deltas per Index and discard them once the full message arrives.
+type StreamDelta struct {
+	// Response numbers the responses streamed in the conversation, and Seq
+	// numbers the deltas of each response from 1, so that clients can tell
+	// when they missed one.
+	Response  int64  `json:"response"`
+	Seq       int    `json:"seq"`
+	Index     int    `json:"index"`
+	Type      string `json:"type"` // "text", "thinking", or "tool_use"
+	Text      string `json:"text,omitempty"`
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	ToolName  string `json:"tool_name,omitempty"`
+	ToolInput string `json:"tool_input,omitempty"`
+	// Reset indicates that all partial output received so far should be discarded.
+	Reset bool `json:"reset,omitempty"`
+}
+
+// StreamResync is the partial output of a response as of its Seq'th delta. It
+// replaces what a client has accumulated for the response.
+type StreamResync struct {
+	Response int64         `json:"response"`
+	Seq      int           `json:"seq"`
+	Blocks   []StreamDelta `json:"blocks"`
+}
+
+// ToolProgress is the output so far of a tool call that is still running.
+// Each frame replaces the previous one for the same tool call.
+type ToolProgress struct {
+	ToolUseID string `json:"tool_use_id"`
+	// Output is the end of what the tool has produced, not only what is new.
+	Output string `json:"output"`
+}
+
+// toAPIStreamDelta converts an llm.StreamDelta to its client representation.
+func toAPIStreamDelta(d llm.StreamDelta) *StreamDelta {
+	out := &StreamDelta{
+		Index:     d.Index,
+		Text:      d.Text,
+		ToolUseID: d.ToolUseID,
+		ToolName:  d.ToolName,
+		ToolInput: d.ToolInput,
+		Reset:     d.Reset,
+	}
+	switch d.Type {
+	case llm.ContentTypeText:
+		out.Type = "text"
+	case llm.ContentTypeThinking:
+		out.Type = "thinking"
+	case llm.ContentTypeToolUse, llm.ContentTypeServerToolUse:
+		out.Type = "tool_use"
+	}
+	return out
 }
 
 // LLMProvider is an interface for getting LLM services
@@ -96,6 +173,8 @@ func NewLLMServiceManager(cfg *LLMConfig) LLMProvider {
 		Gateway:         cfg.Gateway,
 		Logger:          cfg.Logger,
 		DB:              cfg.DB,
+		Fallbacks:       cfg.ModelFallbacks,
+		LocalEndpoints:  cfg.LocalModels,
 	}
 
 	manager, err := models.NewManager(modelConfig)
@@ -236,7 +315,25 @@ type Server struct {
 	conversationGroup   singleflight.Group[string, *ConversationManager]
 	versionChecker      *VersionChecker
 	notifDispatcher     *notifications.Dispatcher
+	budgets             BudgetConfig
+	permissions         permission.Policy
+	sandbox             sandbox.Config
+	sandboxCheck        sync.Once // guards sandboxErr
+	sandboxErr          error     // why namespace mode is unavailable, if it is
+	socketPath          string    // the Unix socket the server listens on, if any
+	mcpServers          []mcp.ServerConfig
+	mcpPool             *mcp.Pool     // connections to MCP servers, shared by conversations
 	shutdownCh          chan struct{} // Signals background routines to stop
+
+	// budgetThresholdsNotified records the budgets a budget_threshold
+	// notification was sent for, so each is sent once.
+	budgetThresholdsNotified map[string]bool
+	// upgradeNotifiedTag is the latest release an upgrade_available
+	// notification was sent for. Only autoUpgradeRoutine uses it.
+	upgradeNotifiedTag string
+	// auth is the built-in authentication state, nil unless EnableAuth
+	// was called.
+	auth *authState
 }
 
 // NewServer creates a new server instance
@@ -254,6 +351,7 @@ func NewServer(database *db.DB, llmManager LLMProvider, toolSetConfig claudetool
 		links:               links,
 		versionChecker:      NewVersionChecker(),
 		notifDispatcher:     notifications.NewDispatcher(logger),
+		mcpPool:             &mcp.Pool{Idle: 10 * time.Minute},
 		shutdownCh:          make(chan struct{}),
 	}
 
@@ -261,6 +359,12 @@ func NewServer(database *db.DB, llmManager LLMProvider, toolSetConfig claudetool
 	s.toolSetConfig.SubagentRunner = NewSubagentRunner(s)
 	s.toolSetConfig.SubagentDB = &db.SubagentDBAdapter{DB: database}
 	s.toolSetConfig.MaxSubagentDepth = 1 // Only top-level conversations can spawn subagents
+	s.toolSetConfig.MCPPool = s.mcpPool
+
+	s.notifDispatcher.SetDeliveryLog(notificationDeliveryLog{db: database})
+	if err := database.FailPendingNotificationDeliveries(context.Background()); err != nil {
+		logger.Warn("Failed to fail abandoned notification deliveries", "error", err)
+	}
 
 	return s
 }
@@ -292,6 +396,16 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	mux.Handle("/api/write-file", http.HandlerFunc(s.handleWriteFile))                  // Small response
 	mux.HandleFunc("/api/exec-ws", s.handleExecWS)                                      // Websocket for shell commands
 
+	// Built-in authentication API
+	mux.HandleFunc("GET /api/auth/status", s.handleAuthStatus)
+	mux.HandleFunc("POST /api/auth/setup", s.handleAuthSetup)
+	mux.HandleFunc("POST /api/auth/login", s.handleAuthLogin)
+	mux.HandleFunc("POST /api/auth/logout", s.handleAuthLogout)
+	mux.HandleFunc("POST /api/auth/password", s.handleAuthPassword)
+	mux.HandleFunc("GET /api/auth/sessions", s.handleAuthSessions)
+	mux.HandleFunc("DELETE /api/auth/sessions/{id}", s.handleAuthSession)
+	mux.HandleFunc("POST /api/auth/tokens", s.handleCreateAuthToken)
+
 	// Custom models API
 	mux.Handle("/api/custom-models", http.HandlerFunc(s.handleCustomModels))
 	mux.Handle("/api/custom-models/", http.HandlerFunc(s.handleCustomModel))
@@ -300,11 +414,23 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	// Notification channels API
 	mux.Handle("/api/notification-channels", http.HandlerFunc(s.handleNotificationChannels))
 	mux.Handle("/api/notification-channels/", http.HandlerFunc(s.handleNotificationChannel))
+	mux.Handle("/api/scheduled-tasks", http.HandlerFunc(s.handleScheduledTasks))
+	mux.Handle("/api/scheduled-tasks/", http.HandlerFunc(s.handleScheduledTask))
 	mux.Handle("/api/notification-channel-types", http.HandlerFunc(s.handleNotificationChannelTypes))
 
+	// MCP servers API
+	mux.Handle("/api/mcp-servers", http.HandlerFunc(s.handleMCPServers))
+	mux.Handle("/api/mcp-servers/", http.HandlerFunc(s.handleMCPServer))
+
+	// Shelley's own MCP server, for other agents
+	mux.Handle("/api/mcp", http.HandlerFunc(s.handleMCP))
+
 	// Models API (dynamic list refresh)
 	mux.Handle("/api/models", http.HandlerFunc(s.handleModels))
 
+	// Cost rollups
+	mux.Handle("/api/costs", gzipHandler(http.HandlerFunc(s.handleCosts)))
+
 	// Version endpoints
 	mux.Handle("GET /version", http.HandlerFunc(s.handleVersion))
 	mux.Handle("GET /version-check", http.HandlerFunc(s.handleVersionCheck))
@@ -690,6 +816,26 @@ func (s *Server) getOrCreateConversationManager(ctx context.Context, conversatio
 
 		manager := NewConversationManager(conversationID, s.db, s.logger, s.toolSetConfig, recordMessage, onStateChange)
 		manager.userEmail = userEmail
+		manager.checkBudget = func(ctx context.Context) error {
+			return s.checkBudget(ctx, conversationID)
+		}
+		manager.compact = func(ctx context.Context, used uint64) ([]llm.Message, error) {
+			return s.compactDuringTurn(ctx, manager, used)
+		}
+		manager.fallbacks = s.modelFallbacks
+		manager.permissionPolicy = func(ctx context.Context, workingDir string) (permission.Policy, error) {
+			return s.permissionPolicy(ctx, conversationID, workingDir)
+		}
+		manager.sandboxExecutor = func(ctx context.Context) sandbox.Executor {
+			return s.sandboxExecutor(ctx, conversationID)
+		}
+		manager.mcpServers = s.mcpServerConfigs
+		manager.onApprovalRequest = func(request ApprovalRequest) {
+			s.notifyToolApproval(conversationID, request)
+		}
+		manager.background.OnExit = func(info claudetool.BackgroundProcessInfo) {
+			s.notifyCommandDone(conversationID, info)
+		}
 		if err := manager.Hydrate(ctx); err != nil {
 			return nil, err
 		}
@@ -703,6 +849,27 @@ func (s *Server) getOrCreateConversationManager(ctx context.Context, conversatio
 	return manager, nil
 }
 
+// modelFallbacks returns the available models in modelID's fallback chain.
+func (s *Server) modelFallbacks(modelID string) []loop.Fallback {
+	info := s.llmManager.GetModelInfo(modelID)
+	if info == nil {
+		return nil
+	}
+	var fallbacks []loop.Fallback
+	for _, id := range info.Fallbacks {
+		if id == modelID {
+			continue
+		}
+		svc, err := s.llmManager.GetService(id)
+		if err != nil {
+			// Not configured (e.g. no API key for its provider)
+			continue
+		}
+		fallbacks = append(fallbacks, loop.Fallback{ModelID: id, LLM: svc})
+	}
+	return fallbacks
+}
+
 // getOrCreateSubagentConversationManager is like getOrCreateConversationManager but
 // uses a toolSetConfig with SubagentDepth incremented by 1, preventing subagents
 // from spawning their own subagents (when MaxSubagentDepth is 1).
@@ -728,6 +895,26 @@ func (s *Server) getOrCreateSubagentConversationManager(ctx context.Context, con
 		subagentConfig.SubagentDepth = s.toolSetConfig.SubagentDepth + 1
 
 		manager := NewConversationManager(conversationID, s.db, s.logger, subagentConfig, recordMessage, onStateChange)
+		manager.checkBudget = func(ctx context.Context) error {
+			return s.checkBudget(ctx, conversationID)
+		}
+		manager.compact = func(ctx context.Context, used uint64) ([]llm.Message, error) {
+			return s.compactDuringTurn(ctx, manager, used)
+		}
+		manager.fallbacks = s.modelFallbacks
+		manager.permissionPolicy = func(ctx context.Context, workingDir string) (permission.Policy, error) {
+			return s.permissionPolicy(ctx, conversationID, workingDir)
+		}
+		manager.sandboxExecutor = func(ctx context.Context) sandbox.Executor {
+			return s.sandboxExecutor(ctx, conversationID)
+		}
+		manager.mcpServers = s.mcpServerConfigs
+		manager.onApprovalRequest = func(request ApprovalRequest) {
+			s.notifyToolApproval(conversationID, request)
+		}
+		manager.background.OnExit = func(info claudetool.BackgroundProcessInfo) {
+			s.notifyCommandDone(conversationID, info)
+		}
 		if err := manager.Hydrate(ctx); err != nil {
 			return nil, err
 		}
@@ -936,6 +1123,7 @@ func (s *Server) notifySubscribersNewMessage(ctx context.Context, conversationID
 	// Update agent working state based on message type
 	if isAgentEndOfTurn(newMsg) {
 		manager.SetAgentWorking(false)
+		go s.maybeCompactConversation(ctx, conversationID, newMsg)
 	}
 
 	// Publish only the new message
@@ -1017,37 +1205,60 @@ func (s *Server) publishConversationListUpdate(update ConversationListUpdate) {
 // publishConversationState broadcasts a conversation state update to ALL active
 // conversation streams. This allows clients to see the working state of other conversations.
 func (s *Server) publishConversationState(state ConversationState) {
-	// When the agent finishes working, emit a notification event.
+	// When the agent finishes working, emit a notification event. Subagents
+	// report to their parent conversation instead.
 	var notifEvent *notifications.Event
 	if !state.Working {
-		payload := notifications.AgentDonePayload{
-			Model: state.Model,
-		}
-		if conv, err := s.db.GetConversationByID(context.Background(), state.ConversationID); err == nil && conv.Slug != nil {
-			payload.ConversationTitle = *conv.Slug
+		var title, parentID, taskChannel string
+		if conv, err := s.db.GetConversationByID(context.Background(), state.ConversationID); err == nil {
+			if conv.Slug != nil {
+				title = *conv.Slug
+			}
+			if conv.ParentConversationID != nil {
+				parentID = *conv.ParentConversationID
+			}
+			taskChannel = s.scheduledTaskChannel(context.Background(), conv)
 		}
+		var finalResponse string
 		if msg, err := s.db.GetLatestMessage(context.Background(), state.ConversationID); err == nil && msg.Type == string(db.MessageTypeAgent) && msg.LlmData != nil {
 			var llmMsg llm.Message
 			if json.Unmarshal([]byte(*msg.LlmData), &llmMsg) == nil {
-				var text string
 				for _, c := range llmMsg.Content {
 					if c.Type == llm.ContentTypeText && c.Text != "" {
-						text = c.Text
+						finalResponse = c.Text
 					}
 				}
-				if len(text) > 255 {
-					text = text[:255] + "..."
+				if len(finalResponse) > 255 {
+					finalResponse = finalResponse[:255] + "..."
 				}
-				payload.FinalResponse = text
 			}
 		}
 		event := notifications.Event{
 			Type:           notifications.EventAgentDone,
 			ConversationID: state.ConversationID,
 			Timestamp:      time.Now(),
-			Payload:        payload,
+			Payload: notifications.AgentDonePayload{
+				Model:             state.Model,
+				ConversationTitle: title,
+				FinalResponse:     finalResponse,
+			},
+		}
+		if parentID != "" {
+			event.Type = notifications.EventSubagentDone
+			event.ConversationID = parentID
+			event.Payload = notifications.SubagentDonePayload{
+				SubagentID:    state.ConversationID,
+				SubagentTitle: title,
+				Model:         state.Model,
+				FinalResponse: finalResponse,
+			}
+		}
+		if taskChannel != "" {
+			// Scheduled runs always report to their task's channel.
+			s.notifDispatcher.Dispatch(event, taskChannel)
+		} else {
+			s.notifDispatcher.Dispatch(event)
 		}
-		s.notifDispatcher.Dispatch(context.Background(), event)
 		notifEvent = &event
 	}
 
@@ -1100,8 +1311,11 @@ func (s *Server) Cleanup() {
 		// Remove managers that have been inactive for more than 30 minutes
 		manager.mu.Lock()
 		lastActivity := manager.lastActivity
+		waiting := len(manager.approvals) > 0
 		manager.mu.Unlock()
-		if now.Sub(lastActivity) > 30*time.Minute {
+		// Keep conversations whose tool calls are waiting for the user, and
+		// those with background processes the agent may come back to.
+		if now.Sub(lastActivity) > 30*time.Minute && !waiting && !manager.background.HasRunning() {
 			manager.stopLoop()
 			delete(s.activeConversations, id)
 			s.logger.Debug("Cleaned up inactive conversation", "conversationID", id)
@@ -1109,6 +1323,48 @@ func (s *Server) Cleanup() {
 	}
 }
 
+// withSubagents returns conversationID followed by the IDs of the subagents it
+// started, and of the subagents they started.
+func (s *Server) withSubagents(ctx context.Context, conversationID string) []string {
+	ids := []string{conversationID}
+	for i := 0; i < len(ids); i++ {
+		subagents, err := s.db.GetSubagents(ctx, ids[i])
+		if err != nil {
+			s.logger.Warn("Failed to get subagents", "conversationID", ids[i], "error", err)
+			continue
+		}
+		for _, subagent := range subagents {
+			ids = append(ids, subagent.ConversationID)
+		}
+	}
+	return ids
+}
+
+// stopConversations stops the loops and background processes of conversations
+// that were archived or deleted. Processes can take seconds to stop, so they
+// are stopped in the background.
+func (s *Server) stopConversations(conversationIDs []string) {
+	for _, id := range conversationIDs {
+		s.stopConversationLoop(id)
+	}
+	go func() {
+		for _, id := range conversationIDs {
+			s.stopBackgroundProcesses(id)
+		}
+	}()
+}
+
+// stopConversationLoop stops the loop of a conversation that was archived or
+// deleted, releasing what its tools hold, such as connections to MCP servers.
+func (s *Server) stopConversationLoop(conversationID string) {
+	s.mu.Lock()
+	manager := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if manager != nil {
+		manager.stopLoop()
+	}
+}
+
 // Start starts the HTTP server and handles the complete lifecycle
 func (s *Server) Start(port string) error {
 	listener, err := net.Listen("tcp", ":"+port)
@@ -1126,9 +1382,9 @@ func (s *Server) StartWithListener(listener net.Listener) error {
 }
 
 // StartWithListeners starts the HTTP server on the given TCP listener and optionally
-// also on a Unix socket. The TCP listener gets full middleware (CSRF, requireHeader, logger).
-// The Unix socket listener gets only the logger middleware (no CSRF, no requireHeader)
-// since it is local and trusted.
+// also on a Unix socket. The TCP listener gets full middleware (CSRF, requireHeader, auth, logger).
+// The Unix socket listener gets only the logger middleware (no CSRF, no requireHeader, no auth)
+// since it is local and trusted; sandboxed commands can't reach it.
 func (s *Server) StartWithListeners(tcpListener net.Listener, socketPath string) error {
 	// Set up shared mux with routes
 	mux := http.NewServeMux()
@@ -1138,6 +1394,9 @@ func (s *Server) StartWithListeners(tcpListener net.Listener, socketPath string)
 	tcpHandler := LoggerMiddleware(s.logger)(mux)
 	cop := http.NewCrossOriginProtection()
 	tcpHandler = cop.Handler(tcpHandler)
+	if s.auth != nil {
+		tcpHandler = s.AuthMiddleware(tcpHandler)
+	}
 	if s.requireHeader != "" {
 		tcpHandler = RequireHeaderMiddleware(s.requireHeader)(tcpHandler)
 	}
@@ -1157,6 +1416,7 @@ func (s *Server) StartWithListeners(tcpListener net.Listener, socketPath string)
 
 	// Start auto-upgrade routine
 	go s.autoUpgradeRoutine()
+	go s.schedulerRoutine()
 
 	// Get actual port from listener
 	actualPort := tcpListener.Addr().(*net.TCPAddr).Port
@@ -1175,6 +1435,9 @@ func (s *Server) StartWithListeners(tcpListener net.Listener, socketPath string)
 	var actualSocketPath string
 	if socketPath != "" {
 		actualSocketPath = resolveSocketPath(socketPath, s.logger)
+		s.mu.Lock()
+		s.socketPath = actualSocketPath
+		s.mu.Unlock()
 
 		// Ensure the directory exists
 		if err := os.MkdirAll(filepath.Dir(actualSocketPath), 0o700); err != nil {
@@ -1199,7 +1462,7 @@ func (s *Server) StartWithListeners(tcpListener net.Listener, socketPath string)
 			s.logger.Warn("Failed to chmod socket", "path", actualSocketPath, "error", err)
 		}
 
-		// Unix socket handler: relaxed middleware (only logger, no CSRF or requireHeader)
+		// Unix socket handler: relaxed middleware (only logger, no CSRF, requireHeader or auth)
 		socketHandler := LoggerMiddleware(s.logger)(mux)
 
 		socketServer = &http.Server{
@@ -1245,11 +1508,14 @@ func (s *Server) StartWithListeners(tcpListener net.Listener, socketPath string)
 		os.Remove(actualSocketPath)
 	}
 
+	s.stopAllBackgroundProcesses()
+	s.mcpPool.Close()
+	s.notifDispatcher.Close()
 	s.logger.Info("Server exited")
 	return nil
 }
 
-// autoUpgradeRoutine checks for upgrades every 24 hours if auto-upgrade is enabled
+// autoUpgradeRoutine checks for upgrades every 24 hours
 func (s *Server) autoUpgradeRoutine() {
 	// Wait a bit before starting to let the server fully initialize
 	timer := time.NewTimer(1 * time.Minute)
@@ -1278,17 +1544,18 @@ func (s *Server) autoUpgradeRoutine() {
 	}
 }
 
-// tryAutoUpgrade attempts to upgrade if auto-upgrade is enabled and server is idle
+// tryAutoUpgrade sends an upgrade_available notification for new releases,
+// and attempts to upgrade if auto-upgrade is enabled and server is idle
 func (s *Server) tryAutoUpgrade() {
 	ctx := context.Background()
 
-	// Check if auto-upgrade is enabled
-	autoUpgradeEnabled, err := s.db.GetSetting(ctx, "auto_upgrade")
-	if err != nil || autoUpgradeEnabled != "true" {
+	// Only check for updates if something would come of it.
+	setting, err := s.db.GetSetting(ctx, "auto_upgrade")
+	autoUpgradeEnabled := err == nil && setting == "true"
+	if !autoUpgradeEnabled && !s.notifDispatcher.Wants(notifications.EventUpgradeAvailable) {
 		return
 	}
 
-	// Check for updates first
 	versionInfo, err := s.versionChecker.Check(ctx, true)
 	if err != nil {
 		s.logger.Error("Auto-upgrade version check failed", "error", err)
@@ -1299,6 +1566,10 @@ func (s *Server) tryAutoUpgrade() {
 		s.logger.Debug("Auto-upgrade: no update available")
 		return
 	}
+	s.notifyUpgradeAvailable(versionInfo)
+	if !autoUpgradeEnabled {
+		return
+	}
 
 	s.logger.Info("Auto-upgrade: update available", "current", versionInfo.CurrentTag, "latest", versionInfo.LatestTag)
 
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"sync"
+)
+
+// streamBuffer numbers the deltas of each streamed response and keeps the
+// partial output they add up to, so that subscribers that miss deltas can
+// catch up. Deltas are broadcast with subpub.TryBroadcast, which drops them
+// for subscribers that fall behind.
+type streamBuffer struct {
+	mu   sync.Mutex
+	cur  StreamResync // the response streaming now, or the last one streamed
+	prev StreamResync // the response before cur
+	done bool         // cur has been recorded; the next delta starts a new response
+}
+
+// add numbers d and folds it into the partial output of its response.
+func (b *streamBuffer) add(d *StreamDelta) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done || b.cur.Response == 0 {
+		b.prev = b.cur
+		b.cur = StreamResync{Response: b.cur.Response + 1}
+		b.done = false
+	}
+	b.cur.Seq++
+	d.Response, d.Seq = b.cur.Response, b.cur.Seq
+	if d.Reset {
+		b.cur.Blocks = nil
+		return
+	}
+	// Blocks are copied on write, since snapshots share them.
+	blocks := make([]StreamDelta, max(len(b.cur.Blocks), d.Index+1))
+	copy(blocks, b.cur.Blocks)
+	block := &blocks[d.Index]
+	block.Index = d.Index
+	if block.Type == "" {
+		block.Type = d.Type
+	}
+	if block.ToolUseID == "" {
+		block.ToolUseID = d.ToolUseID
+	}
+	if block.ToolName == "" {
+		block.ToolName = d.ToolName
+	}
+	block.Text += d.Text
+	block.ToolInput += d.ToolInput
+	b.cur.Blocks = blocks
+}
+
+// finish marks the response being streamed as recorded.
+func (b *streamBuffer) finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done = true
+}
+
+// snapshot returns the partial output of the given response, if it is
+// still kept.
+func (b *streamBuffer) snapshot(response int64) (StreamResync, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch response {
+	case b.cur.Response:
+		return b.cur, true
+	case b.prev.Response:
+		return b.prev, true
+	}
+	return StreamResync{}, false
+}
+
+// subscribe is like subpub.Subscribe, but repairs the stream deltas that were
+// dropped for the subscriber: a gap in a response's deltas is replaced by a
+// StreamResync with the response's partial output so far. Before any other
+// update is returned, the subscriber is caught up on the response it was
+// following, so its partial output is complete when the recorded message
+// arrives.
+func (cm *ConversationManager) subscribe(ctx context.Context, idx int64) func() (StreamResponse, bool) {
+	next := cm.subpub.Subscribe(ctx, idx)
+	var response int64 // the response being followed, and its last delta
+	var seq int
+	var held *StreamResponse
+
+	resync := func(s StreamResync) StreamResponse {
+		response, seq = s.Response, s.Seq
+		return StreamResponse{StreamResync: &s}
+	}
+	return func() (StreamResponse, bool) {
+		if held != nil {
+			resp := *held
+			held = nil
+			return resp, true
+		}
+		for {
+			resp, ok := next()
+			if !ok {
+				return resp, false
+			}
+			d := resp.StreamDelta
+			if d == nil {
+				if response != 0 {
+					if s, ok := cm.stream.snapshot(response); ok && s.Seq > seq {
+						held = &resp
+						return resync(s), true
+					}
+				}
+				return resp, true
+			}
+			switch {
+			case d.Response < response || (d.Response == response && d.Seq <= seq):
+				// Already covered by a resync.
+				continue
+			case (d.Response == response && d.Seq == seq+1) || (d.Response > response && d.Seq == 1):
+				response, seq = d.Response, d.Seq
+				return resp, true
+			}
+			if s, ok := cm.stream.snapshot(d.Response); ok {
+				return resync(s), true
+			}
+			// The response is too old to repair; clients see the gap in its
+			// numbering and wait for the recorded message instead.
+			response, seq = d.Response, d.Seq
+			return resp, true
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+// TestStreamDeltasPrecedeAgentMessage verifies that partial LLM output is
+// broadcast to stream subscribers before the full agent message is recorded.
+func TestStreamDeltasPrecedeAgentMessage(t *testing.T) {
+	server, database, _ := newTestServer(t)
+
+	conversation, err := database.CreateConversation(context.Background(), nil, true, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	conversationID := conversation.ConversationID
+
+	manager, err := server.getOrCreateConversationManager(context.Background(), conversationID, "")
+	if err != nil {
+		t.Fatalf("failed to get conversation manager: %v", err)
+	}
+
+	subCtx, subCancel := context.WithCancel(context.Background())
+	defer subCancel()
+	next := manager.subscribe(subCtx, -1)
+
+	updates := make(chan StreamResponse, 100)
+	go func() {
+		for {
+			data, ok := next()
+			if !ok {
+				return
+			}
+			updates <- data
+		}
+	}()
+
+	chatBody, _ := json.Marshal(ChatRequest{
+		Message: "echo: partial output arrives first",
+		Model:   "predictable",
+	})
+	req := httptest.NewRequest("POST", "/api/conversation/"+conversationID+"/chat", strings.NewReader(string(chatBody)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.handleChatConversation(w, req, conversationID)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var streamed strings.Builder
+	deltas := 0
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case update := <-updates:
+			if d := update.StreamDelta; d != nil {
+				if d.Type != "text" || d.Index != 0 {
+					t.Errorf("unexpected delta: %+v", d)
+				}
+				streamed.WriteString(d.Text)
+				deltas++
+				continue
+			}
+			if r := update.StreamResync; r != nil {
+				// Deltas this subscriber missed are filled in from the partial output.
+				streamed.Reset()
+				streamed.WriteString(r.Blocks[0].Text)
+				deltas++
+				continue
+			}
+			for _, msg := range update.Messages {
+				if msg.Type != string(db.MessageTypeAgent) || msg.LlmData == nil {
+					continue
+				}
+				var llmMsg llm.Message
+				if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil {
+					t.Fatalf("failed to unmarshal agent message: %v", err)
+				}
+				if deltas < 2 {
+					t.Fatalf("expected multiple deltas before the agent message, got %d", deltas)
+				}
+				if got, want := streamed.String(), llmMsg.Content[0].Text; got != want {
+					t.Errorf("streamed text %q does not match recorded text %q", got, want)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for agent message; got %d deltas", deltas)
+		}
+	}
+}
+
+// TestToolProgressPrecedesToolResult verifies that the output of a running bash
+// command is broadcast before its tool result is recorded.
+func TestToolProgressPrecedesToolResult(t *testing.T) {
+	server, database, _ := newTestServer(t)
+
+	conversation, err := database.CreateConversation(context.Background(), nil, true, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	conversationID := conversation.ConversationID
+
+	manager, err := server.getOrCreateConversationManager(context.Background(), conversationID, "")
+	if err != nil {
+		t.Fatalf("failed to get conversation manager: %v", err)
+	}
+
+	subCtx, subCancel := context.WithCancel(context.Background())
+	defer subCancel()
+	next := manager.subpub.Subscribe(subCtx, -1)
+
+	updates := make(chan StreamResponse, 100)
+	go func() {
+		for {
+			data, ok := next()
+			if !ok {
+				return
+			}
+			updates <- data
+		}
+	}()
+
+	chatBody, _ := json.Marshal(ChatRequest{
+		Message: "bash: echo building; sleep 1; echo built",
+		Model:   "predictable",
+	})
+	req := httptest.NewRequest("POST", "/api/conversation/"+conversationID+"/chat", strings.NewReader(string(chatBody)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.handleChatConversation(w, req, conversationID)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var progress []ToolProgress
+	timeout := time.After(15 * time.Second)
+	for {
+		select {
+		case update := <-updates:
+			if p := update.ToolProgress; p != nil {
+				progress = append(progress, *p)
+				continue
+			}
+			for _, msg := range update.Messages {
+				if msg.Type != string(db.MessageTypeUser) || msg.LlmData == nil {
+					continue
+				}
+				var llmMsg llm.Message
+				if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil {
+					t.Fatalf("failed to unmarshal user message: %v", err)
+				}
+				if len(llmMsg.Content) == 0 || llmMsg.Content[0].Type != llm.ContentTypeToolResult {
+					continue
+				}
+				result := llmMsg.Content[0]
+				if !strings.Contains(result.ToolResult[0].Text, "building\nbuilt") {
+					t.Fatalf("unexpected tool result: %q", result.ToolResult[0].Text)
+				}
+				var sawPartial bool
+				for _, p := range progress {
+					if p.ToolUseID != result.ToolUseID {
+						t.Fatalf("progress for %q, want %q", p.ToolUseID, result.ToolUseID)
+					}
+					sawPartial = sawPartial || (strings.Contains(p.Output, "building") && !strings.Contains(p.Output, "built"))
+				}
+				if !sawPartial {
+					t.Fatalf("expected progress with partial output before the tool result, got %+v", progress)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for the tool result; got %d progress frames", len(progress))
+		}
+	}
+}
+
+// TestSubscribeRepairsDroppedDeltas verifies that a subscriber that falls
+// behind gets the partial output it missed, before the recorded message.
+func TestSubscribeRepairsDroppedDeltas(t *testing.T) {
+	cm := NewConversationManager("c", nil, nil, claudetool.ToolSetConfig{}, nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	next := cm.subscribe(ctx, -1)
+
+	send := func(text string) {
+		d := &StreamDelta{Type: "text", Text: text}
+		cm.stream.add(d)
+		cm.subpub.TryBroadcast(StreamResponse{StreamDelta: d})
+	}
+	var want strings.Builder
+	for i := range 20 {
+		text := fmt.Sprintf("word%d ", i)
+		want.WriteString(text)
+		send(text)
+	}
+	cm.stream.finish()
+	cm.subpub.Publish(1, StreamResponse{Heartbeat: true})
+
+	var got strings.Builder
+	for {
+		resp, ok := next()
+		if !ok {
+			t.Fatal("subscription ended early")
+		}
+		if d := resp.StreamDelta; d != nil {
+			got.WriteString(d.Text)
+		} else if r := resp.StreamResync; r != nil {
+			got.Reset()
+			got.WriteString(r.Blocks[0].Text)
+		} else {
+			break
+		}
+	}
+	if got.String() != want.String() {
+		t.Errorf("streamed %q, want %q", got.String(), want.String())
+	}
+
+	// The next response is numbered afresh.
+	send("next")
+	if resp, _ := next(); resp.StreamDelta == nil || resp.StreamDelta.Response != 2 || resp.StreamDelta.Seq != 1 {
+		t.Errorf("expected the first delta of response 2, got %+v", resp)
+	}
+}
@@ -79,7 +79,7 @@ func (r *SubagentRunner) RunSubagent(ctx context.Context, conversationID, prompt
 	}
 
 	// Accept the user message (this starts processing)
-	_, err = manager.AcceptUserMessage(ctx, llmService, modelID, userMessage)
+	_, err = manager.AcceptUserMessage(ctx, llmService, modelID, userMessage, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to accept user message: %w", err)
 	}
@@ -93,38 +93,50 @@ func (r *SubagentRunner) RunSubagent(ctx context.Context, conversationID, prompt
 }
 
 func (r *SubagentRunner) waitForResponse(ctx context.Context, conversationID, modelID string, llmService llm.Service, timeout time.Duration) (string, error) {
-	s := r.server
+	done, err := r.server.waitForAgent(ctx, conversationID, timeout)
+	if err != nil {
+		return "", err
+	}
+	if !done {
+		// Timeout reached - generate a progress summary
+		return r.generateProgressSummary(ctx, conversationID, modelID, llmService)
+	}
+	// Agent is done, get the last message
+	return r.getLastAssistantResponse(ctx, conversationID)
+}
 
+// waitForAgent waits up to timeout for the agent to finish working in a
+// conversation, reporting whether it did.
+func (s *Server) waitForAgent(ctx context.Context, conversationID string, timeout time.Duration) (bool, error) {
+	r := &SubagentRunner{server: s}
 	deadline := time.Now().Add(timeout)
 	pollInterval := 500 * time.Millisecond
 
 	for {
 		select {
 		case <-ctx.Done():
-			return "", ctx.Err()
+			return false, ctx.Err()
 		default:
 		}
 
 		if time.Now().After(deadline) {
-			// Timeout reached - generate a progress summary
-			return r.generateProgressSummary(ctx, conversationID, modelID, llmService)
+			return false, nil
 		}
 
 		// Check if agent is still working
 		working, err := r.isAgentWorking(ctx, conversationID)
 		if err != nil {
-			return "", fmt.Errorf("failed to check agent status: %w", err)
+			return false, fmt.Errorf("failed to check agent status: %w", err)
 		}
 
 		if !working {
-			// Agent is done, get the last message
-			return r.getLastAssistantResponse(ctx, conversationID)
+			return true, nil
 		}
 
 		// Wait before polling again
 		select {
 		case <-ctx.Done():
-			return "", ctx.Err()
+			return false, ctx.Err()
 		case <-time.After(pollInterval):
 		}
 
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestConversationThinkingLevel(t *testing.T) {
+	h := NewTestHarness(t)
+
+	w := httptest.NewRecorder()
+	h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new",
+		strings.NewReader(`{"message":"echo: hi","model":"predictable","thinking_level":"max"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown thinking level, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new",
+		strings.NewReader(`{"message":"echo: hi","model":"predictable","thinking_level":"high"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	h.convID = resp.ConversationID
+	h.WaitResponse()
+
+	// requestFor finds the loop's request for msg; titling the conversation
+	// makes requests of its own.
+	requestFor := func(msg string) *llm.Request {
+		reqs := h.llm.GetRecentRequests()
+		for i := len(reqs) - 1; i >= 0; i-- {
+			if m := reqs[i].Messages; len(m) > 0 && slices.ContainsFunc(m[len(m)-1].Content,
+				func(c llm.Content) bool { return c.Text == msg }) {
+				return reqs[i]
+			}
+		}
+		return nil
+	}
+	checkLevel := func(msg string, want llm.ThinkingLevel) {
+		t.Helper()
+		req := requestFor(msg)
+		if req == nil || req.ThinkingLevel == nil || *req.ThinkingLevel != want {
+			t.Errorf("expected the LLM request to think at %v, got %+v", want, req)
+		}
+		conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conv.ThinkingLevel == nil || *conv.ThinkingLevel != want.Name() {
+			t.Errorf("expected the conversation to keep thinking level %q, got %v", want.Name(), conv.ThinkingLevel)
+		}
+	}
+	checkLevel("echo: hi", llm.ThinkingLevelHigh)
+
+	// A chat message changes the level for the running loop, and later messages keep it.
+	w = httptest.NewRecorder()
+	h.server.handleChatConversation(w, httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/chat",
+		strings.NewReader(`{"message":"echo: quick","model":"predictable","thinking_level":"off"}`)), h.convID)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	h.WaitResponse()
+	checkLevel("echo: quick", llm.ThinkingLevelOff)
+
+	h.Chat("echo: again")
+	h.WaitResponse()
+	checkLevel("echo: again", llm.ThinkingLevelOff)
+
+	chat := func(body string) int {
+		w := httptest.NewRecorder()
+		h.server.handleChatConversation(w, httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/chat",
+			strings.NewReader(body)), h.convID)
+		return w.Code
+	}
+
+	// A message the conversation rejects leaves the level alone.
+	if code := chat(`{"message":"echo: other","model":"other","thinking_level":"high"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for another model, got %d", code)
+	}
+	if conv, err := h.db.GetConversationByID(context.Background(), h.convID); err != nil {
+		t.Fatal(err)
+	} else if conv.ThinkingLevel == nil || *conv.ThinkingLevel != "off" {
+		t.Errorf("expected the rejected message not to change the level, got %v", conv.ThinkingLevel)
+	}
+
+	// "default" goes back to the model's default.
+	if code := chat(`{"message":"echo: reset","model":"predictable","thinking_level":"default"}`); code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", code)
+	}
+	h.WaitResponse()
+	if req := requestFor("echo: reset"); req == nil || req.ThinkingLevel != nil {
+		t.Errorf("expected a request without a thinking level, got %+v", req)
+	}
+	if conv, err := h.db.GetConversationByID(context.Background(), h.convID); err != nil {
+		t.Fatal(err)
+	} else if conv.ThinkingLevel != nil {
+		t.Errorf("expected the level to be cleared, got %q", *conv.ThinkingLevel)
+	}
+
+	// A conversation without a level leaves it to the model.
+	h.NewConversation("echo: default", "")
+	h.WaitResponse()
+	if req := requestFor("echo: default"); req == nil || req.ThinkingLevel != nil {
+		t.Errorf("expected a request without a thinking level, got %+v", req)
+	}
+}
@@ -27,11 +27,16 @@ func GenerateSlug(ctx context.Context, llmProvider LLMServiceProvider, database
 	if err != nil {
 		return "", err
 	}
+	return SetUniqueSlug(ctx, database, logger, conversationID, baseSlug)
+}
 
+// SetUniqueSlug sets the conversation's slug to baseSlug, adding a numeric
+// suffix if another conversation already uses it.
+func SetUniqueSlug(ctx context.Context, database *db.DB, logger *slog.Logger, conversationID, baseSlug string) (string, error) {
 	// Try to update with the base slug first, then with numeric suffixes if needed
 	slug := baseSlug
 	for attempt := 0; attempt < 100; attempt++ {
-		_, err = database.UpdateConversationSlug(ctx, conversationID, slug)
+		_, err := database.UpdateConversationSlug(ctx, conversationID, slug)
 		if err == nil {
 			// Success!
 			logger.Info("Generated slug for conversation", "conversationID", conversationID, "slug", slug)
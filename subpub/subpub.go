@@ -133,3 +133,30 @@ func (sp *SubPub[K]) Broadcast(message K) {
 	}
 	sp.subscribers = remaining
 }
+
+// TryBroadcast is like Broadcast, but drops the message for subscribers whose
+// buffer is half full instead of disconnecting them. It is meant for lossy,
+// high-frequency updates (such as streaming deltas) that are superseded by
+// later published messages. The other half of the buffer is left for those
+// messages, so that lossy updates never cause a disconnect.
+func (sp *SubPub[K]) TryBroadcast(message K) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	remaining := sp.subscribers[:0]
+	for _, sub := range sp.subscribers {
+		select {
+		case <-sub.ctx.Done():
+			close(sub.ch)
+			continue
+		default:
+		}
+
+		// Only publishers send, and they hold sp.mu, so the length can't grow under us.
+		if len(sub.ch) < cap(sub.ch)/2 {
+			sub.ch <- message
+		}
+		remaining = append(remaining, sub)
+	}
+	sp.subscribers = remaining
+}
@@ -365,3 +365,38 @@ func TestSubPubSubscriberContextDoneDuringPublish(t *testing.T) {
 		t.Error("Expected closed channel after context cancellation")
 	}
 }
+
+func TestSubPubTryBroadcastDropsWhenFull(t *testing.T) {
+	sp := New[string]()
+	ctx := context.Background()
+
+	next := sp.Subscribe(ctx, 0)
+
+	// Overflow the lossy half of the buffer - the overflow should be dropped, not disconnect
+	for i := 1; i <= 11; i++ {
+		sp.TryBroadcast(fmt.Sprintf("delta%d", i))
+	}
+
+	// Published messages still fit while the deltas are unread
+	sp.Publish(1, "after")
+	sp.Broadcast("broadcast")
+
+	for i := 1; i <= 5; i++ {
+		msg, ok := next()
+		if !ok {
+			t.Fatalf("Expected buffered message %d, got closed channel", i)
+		}
+		if want := fmt.Sprintf("delta%d", i); msg != want {
+			t.Errorf("Expected %q, got %q", want, msg)
+		}
+	}
+	for _, want := range []string{"after", "broadcast"} {
+		msg, ok := next()
+		if !ok {
+			t.Fatal("Expected subscriber to remain connected after TryBroadcast overflow")
+		}
+		if msg != want {
+			t.Errorf("Expected %q, got %q", want, msg)
+		}
+	}
+}
@@ -7,7 +7,13 @@ import ConversationDrawer from "./components/ConversationDrawer";
 import CommandPalette from "./components/CommandPalette";
 import ModelsModal from "./components/ModelsModal";
 import NotificationsModal from "./components/NotificationsModal";
-import { Conversation, ConversationWithState, ConversationListUpdate } from "./types";
+import AccessModal from "./components/AccessModal";
+import {
+  Conversation,
+  ConversationWithState,
+  ConversationListUpdate,
+  ThinkingLevel,
+} from "./types";
 import { api } from "./services/api";
 
 // Worker pool configuration for @pierre/diffs syntax highlighting
@@ -112,8 +118,11 @@ function App() {
   const [diffViewerTrigger, setDiffViewerTrigger] = useState(0);
   const [modelsModalOpen, setModelsModalOpen] = useState(false);
   const [notificationsModalOpen, setNotificationsModalOpen] = useState(false);
+  const [accessModalOpen, setAccessModalOpen] = useState(false);
   const [modelsRefreshTrigger, setModelsRefreshTrigger] = useState(0);
   const [navigateUserMessageTrigger, setNavigateUserMessageTrigger] = useState(0);
+  // Sequence ID of a search match to scroll to in the current conversation
+  const [jumpToSequenceId, setJumpToSequenceId] = useState<number | null>(null);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
   // Global ephemeral terminals - persist across conversation switches
@@ -551,9 +560,19 @@ function App() {
   const mostRecentCwd =
     currentConversation?.cwd || (conversations.length > 0 ? conversations[0].cwd : null);
 
-  const handleFirstMessage = async (message: string, model: string, cwd?: string) => {
+  const handleFirstMessage = async (
+    message: string,
+    model: string,
+    cwd?: string,
+    thinkingLevel?: ThinkingLevel,
+  ) => {
     try {
-      const response = await api.sendMessageWithNewConversation({ message, model, cwd });
+      const response = await api.sendMessageWithNewConversation({
+        message,
+        model,
+        cwd,
+        thinking_level: thinkingLevel,
+      });
       const newConversationId = response.conversation_id;
 
       // Fetch the new conversation details
@@ -587,6 +606,23 @@ function App() {
     }
   };
 
+  const handleForkConversation = async (
+    sourceConversationId: string,
+    messageId: string,
+    message?: string,
+    restoreFiles?: boolean,
+  ) => {
+    const response = await api.forkConversation(
+      sourceConversationId,
+      messageId,
+      message,
+      restoreFiles,
+    );
+    const updatedConvs = await api.getConversations();
+    setConversations(updatedConvs);
+    setCurrentConversationId(response.conversation_id);
+  };
+
   return (
     <WorkerPoolContextProvider
       poolOptions={diffsPoolOptions}
@@ -628,6 +664,7 @@ function App() {
             onConversationStateUpdate={handleConversationStateUpdate}
             onFirstMessage={handleFirstMessage}
             onDistillConversation={handleDistillConversation}
+            onForkConversation={handleForkConversation}
             mostRecentCwd={mostRecentCwd}
             isDrawerCollapsed={drawerCollapsed}
             onToggleDrawerCollapse={toggleDrawerCollapsed}
@@ -639,6 +676,8 @@ function App() {
             setEphemeralTerminals={setEphemeralTerminals}
             navigateUserMessageTrigger={navigateUserMessageTrigger}
             onConversationUnarchived={handleConversationUnarchived}
+            jumpToSequenceId={jumpToSequenceId}
+            onJumpDone={() => setJumpToSequenceId(null)}
           />
         </div>
 
@@ -658,6 +697,7 @@ function App() {
           }}
           onSelectConversation={(conversation) => {
             selectConversation(conversation);
+            setJumpToSequenceId(conversation.match_sequence_id ?? null);
             setCommandPaletteOpen(false);
           }}
           onArchiveConversation={async (conversationId: string) => {
@@ -680,6 +720,10 @@ function App() {
             setNotificationsModalOpen(true);
             setCommandPaletteOpen(false);
           }}
+          onOpenAccessModal={() => {
+            setAccessModalOpen(true);
+            setCommandPaletteOpen(false);
+          }}
           onNextConversation={navigateToNextConversation}
           onPreviousConversation={navigateToPreviousConversation}
           onNextUserMessage={navigateToNextUserMessage}
@@ -698,6 +742,8 @@ function App() {
           onClose={() => setNotificationsModalOpen(false)}
         />
 
+        <AccessModal isOpen={accessModalOpen} onClose={() => setAccessModalOpen(false)} />
+
         {/* Backdrop for mobile drawer */}
         {drawerOpen && (
           <div className="backdrop hide-on-desktop" onClick={() => setDrawerOpen(false)} />
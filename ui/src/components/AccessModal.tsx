@@ -0,0 +1,186 @@
+import React, { useState, useEffect, useCallback } from "react";
+import Modal from "./Modal";
+import { authApi, AuthSessionAPI } from "../services/api";
+
+interface AccessModalProps {
+  isOpen: boolean;
+  onClose: () => void;
+}
+
+// AccessModal lists the browser sessions and API tokens of a server run with
+// --auth, and creates and revokes tokens.
+function AccessModal({ isOpen, onClose }: AccessModalProps) {
+  const [sessions, setSessions] = useState<AuthSessionAPI[]>([]);
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+  const [tokenName, setTokenName] = useState("");
+  const [newToken, setNewToken] = useState<string | null>(null);
+
+  const loadSessions = useCallback(async () => {
+    setLoading(true);
+    try {
+      setSessions(await authApi.getSessions());
+      setError(null);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to load sessions");
+    } finally {
+      setLoading(false);
+    }
+  }, []);
+
+  useEffect(() => {
+    if (isOpen) {
+      setNewToken(null);
+      loadSessions();
+    }
+  }, [isOpen, loadSessions]);
+
+  const handleCreateToken = async () => {
+    try {
+      const created = await authApi.createToken(tokenName.trim(), 0);
+      setNewToken(created.token);
+      setTokenName("");
+      await loadSessions();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to create token");
+    }
+  };
+
+  const handleRevoke = async (session: AuthSessionAPI) => {
+    const what = session.kind === "token" ? `token "${session.name}"` : "this session";
+    if (!confirm(`Revoke ${what}?`)) return;
+    try {
+      await authApi.revokeSession(session.session_id);
+      if (session.current) {
+        window.location.reload();
+        return;
+      }
+      await loadSessions();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to revoke session");
+    }
+  };
+
+  const handleSignOut = async () => {
+    try {
+      await authApi.logout();
+      window.location.reload();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to sign out");
+    }
+  };
+
+  const formatTime = (time: string | null) => (time ? new Date(time).toLocaleString() : "never");
+
+  const renderSession = (session: AuthSessionAPI) => (
+    <div
+      key={session.session_id}
+      className="model-card"
+      style={{
+        display: "flex",
+        alignItems: "center",
+        justifyContent: "space-between",
+        padding: "0.75rem 1rem",
+        marginBottom: "0.5rem",
+      }}
+    >
+      <div style={{ minWidth: 0 }}>
+        <div
+          style={{
+            fontWeight: 500,
+            overflow: "hidden",
+            textOverflow: "ellipsis",
+            whiteSpace: "nowrap",
+          }}
+        >
+          {session.name || "Unknown browser"}
+          {session.current && " (current)"}
+        </div>
+        <div style={{ fontSize: "0.75rem", color: "var(--text-secondary)" }}>
+          Created {formatTime(session.created_at)}, last used {formatTime(session.last_used_at)}
+        </div>
+      </div>
+      <button className="btn btn-secondary btn-sm" onClick={() => handleRevoke(session)}>
+        Revoke
+      </button>
+    </div>
+  );
+
+  const sectionLabel = (label: string) => (
+    <div
+      className="overflow-menu-label"
+      style={{
+        marginBottom: "0.5rem",
+        fontSize: "0.75rem",
+        textTransform: "uppercase",
+        letterSpacing: "0.05em",
+        color: "var(--text-secondary)",
+      }}
+    >
+      {label}
+    </div>
+  );
+
+  const browserSessions = sessions.filter((s) => s.kind === "session");
+  const tokens = sessions.filter((s) => s.kind === "token");
+
+  return (
+    <Modal
+      isOpen={isOpen}
+      onClose={onClose}
+      title="Access"
+      className="modal-wide"
+      titleRight={
+        <button className="btn btn-secondary btn-sm" onClick={handleSignOut}>
+          Sign Out
+        </button>
+      }
+    >
+      {error && (
+        <div className="test-result error" style={{ marginBottom: "1rem" }}>
+          {error}
+        </div>
+      )}
+
+      {loading && sessions.length === 0 && (
+        <div style={{ padding: "1rem", color: "var(--text-secondary)" }}>Loading...</div>
+      )}
+
+      <div style={{ marginBottom: "1rem" }}>
+        {sectionLabel("Browser Sessions")}
+        {browserSessions.map(renderSession)}
+      </div>
+
+      <div>
+        {sectionLabel("API Tokens")}
+        {tokens.map(renderSession)}
+        {newToken && (
+          <div className="test-result success" style={{ marginBottom: "0.5rem" }}>
+            Copy this token now; it won't be shown again. Use it with{" "}
+            <code>shelley client -token</code> or as a Bearer token.
+            <pre style={{ whiteSpace: "pre-wrap", wordBreak: "break-all", margin: "0.5rem 0 0" }}>
+              {newToken}
+            </pre>
+          </div>
+        )}
+        <div style={{ display: "flex", gap: "0.5rem" }}>
+          <input
+            className="form-input"
+            value={tokenName}
+            onChange={(e) => setTokenName(e.target.value)}
+            placeholder="Token name, e.g. laptop CLI"
+          />
+          <button
+            className="btn btn-primary"
+            onClick={handleCreateToken}
+            disabled={tokenName.trim() === ""}
+          >
+            Create Token
+          </button>
+        </div>
+      </div>
+    </Modal>
+  );
+}
+
+export default AccessModal;
@@ -0,0 +1,90 @@
+import React, { useState } from "react";
+import { api } from "../services/api";
+import { ApprovalRequest } from "../types";
+
+interface ApprovalPanelProps {
+  approvals: ApprovalRequest[];
+}
+
+// Shown above the input while tool calls wait for the user to allow or deny
+// them under the conversation's permission policy.
+function ApprovalPanel({ approvals }: ApprovalPanelProps) {
+  const [busy, setBusy] = useState<string | null>(null);
+  const [error, setError] = useState<string | null>(null);
+
+  if (approvals.length === 0) {
+    return null;
+  }
+
+  const resolve = async (
+    approval: ApprovalRequest,
+    decision: "allow" | "deny",
+    remember: boolean,
+  ) => {
+    setBusy(approval.id);
+    setError(null);
+    try {
+      await api.resolveApproval(approval.conversation_id, approval.id, decision, remember);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : String(err));
+    } finally {
+      setBusy(null);
+    }
+  };
+
+  return (
+    <div className="approval-panel" data-testid="approval-panel">
+      {approvals.map((approval) => (
+        <div key={approval.id} className="approval-request">
+          <div className="approval-request-header">
+            <span className="approval-request-tool">{approval.tool}</span>
+            {approval.parent_conversation_id && (
+              <span className="text-xs text-secondary">subagent</span>
+            )}
+            <span className="text-xs text-secondary">{approval.reason}</span>
+          </div>
+          <pre className="approval-request-summary">{approval.summary}</pre>
+          <div className="approval-request-actions">
+            <button
+              className="btn-primary btn-sm"
+              onClick={() => resolve(approval, "allow", false)}
+              disabled={busy === approval.id}
+            >
+              Allow
+            </button>
+            <button
+              className="btn-secondary btn-sm"
+              onClick={() => resolve(approval, "allow", true)}
+              disabled={busy === approval.id}
+              title="Allow this call without asking for the rest of the session"
+            >
+              Always allow
+            </button>
+            <button
+              className="btn-secondary btn-sm"
+              onClick={() => resolve(approval, "deny", false)}
+              disabled={busy === approval.id}
+            >
+              Deny
+            </button>
+            <button
+              className="btn-secondary btn-sm"
+              onClick={() => resolve(approval, "deny", true)}
+              disabled={busy === approval.id}
+              title="Deny this call without asking for the rest of the session"
+            >
+              Always deny
+            </button>
+          </div>
+        </div>
+      ))}
+      {error && (
+        <div className="text-xs" style={{ color: "var(--error-text)" }}>
+          {error}
+        </div>
+      )}
+    </div>
+  );
+}
+
+export default ApprovalPanel;
@@ -0,0 +1,95 @@
+import React, { useEffect, useState } from "react";
+import { api, BackgroundProcess } from "../services/api";
+
+interface BackgroundProcessesPanelProps {
+  conversationId: string;
+  // Changes whenever the conversation may have started or stopped a process.
+  refreshKey: unknown;
+}
+
+// Shown above the input while the agent has background processes, such as dev
+// servers or watchers. Lets the user look at their output and stop them.
+function BackgroundProcessesPanel({ conversationId, refreshKey }: BackgroundProcessesPanelProps) {
+  const [processes, setProcesses] = useState<BackgroundProcess[]>([]);
+  const [expanded, setExpanded] = useState<string | null>(null);
+  const [output, setOutput] = useState("");
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    api
+      .listProcesses(conversationId)
+      .then(setProcesses)
+      .catch(() => setProcesses([]));
+  }, [conversationId, refreshKey]);
+
+  useEffect(() => {
+    if (!expanded) {
+      return;
+    }
+    let cancelled = false;
+    const load = () => {
+      api
+        .getProcessOutput(conversationId, expanded)
+        .then((text) => !cancelled && setOutput(text))
+        .catch((err) => !cancelled && setError(err instanceof Error ? err.message : String(err)));
+    };
+    load();
+    const interval = setInterval(load, 2000);
+    return () => {
+      cancelled = true;
+      clearInterval(interval);
+    };
+  }, [conversationId, expanded]);
+
+  const running = processes.filter((p) => p.running);
+  if (running.length === 0 && !expanded) {
+    return null;
+  }
+
+  const handleStop = async (id: string) => {
+    setError(null);
+    try {
+      const stopped = await api.stopProcess(conversationId, id);
+      setProcesses((prev) => prev.map((p) => (p.id === id ? stopped : p)));
+    } catch (err) {
+      setError(err instanceof Error ? err.message : String(err));
+    }
+  };
+
+  const toggle = (id: string) => {
+    setOutput("");
+    setExpanded((prev) => (prev === id ? null : id));
+  };
+
+  return (
+    <div className="background-processes" data-testid="background-processes">
+      {processes
+        .filter((p) => p.running || p.id === expanded)
+        .map((p) => (
+          <div key={p.id} className="background-process">
+            <div className="background-process-header">
+              <button className="background-process-command" onClick={() => toggle(p.id)}>
+                {expanded === p.id ? "▾" : "▸"} {p.command}
+              </button>
+              <span className="text-xs text-secondary">
+                {p.running ? `pid ${p.pid}` : `exited with status ${p.exit_code}`}
+              </span>
+              {p.running && (
+                <button className="btn-secondary btn-sm" onClick={() => handleStop(p.id)}>
+                  Stop
+                </button>
+              )}
+            </div>
+            {expanded === p.id && <pre className="background-process-output">{output}</pre>}
+          </div>
+        ))}
+      {error && (
+        <div className="text-xs" style={{ color: "var(--error-text)" }}>
+          {error}
+        </div>
+      )}
+    </div>
+  );
+}
+
+export default BackgroundProcessesPanel;
@@ -1,4 +1,4 @@
-import React, { useState } from "react";
+import React, { useEffect, useRef, useState } from "react";
 import { LLMContent } from "../types";
 
 // Display data from the bash tool backend
@@ -10,6 +10,8 @@ interface BashToolProps {
   // For tool_use (pending state)
   toolInput?: unknown;
   isRunning?: boolean;
+  // Latest output while the command runs
+  progress?: string;
 
   // For tool_result (completed state)
   toolResult?: LLMContent[];
@@ -18,9 +20,25 @@ interface BashToolProps {
   display?: unknown;
 }
 
+// LiveOutput shows the end of a running command's output, scrolled to the bottom.
+function LiveOutput({ output }: { output: string }) {
+  const ref = useRef<HTMLPreElement>(null);
+  useEffect(() => {
+    if (ref.current) {
+      ref.current.scrollTop = ref.current.scrollHeight;
+    }
+  }, [output]);
+  return (
+    <pre ref={ref} className="bash-tool-code bash-tool-live" data-testid="bash-live-output">
+      {output}
+    </pre>
+  );
+}
+
 function BashTool({
   toolInput,
   isRunning,
+  progress,
   toolResult,
   hasError,
   executionTime,
@@ -109,6 +127,8 @@ function BashTool({
         </button>
       </div>
 
+      {isRunning && progress && <LiveOutput output={progress} />}
+
       {isExpanded && (
         <div className="bash-tool-details">
           {displayData?.workingDir && (
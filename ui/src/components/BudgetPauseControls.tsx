@@ -0,0 +1,108 @@
+import React, { useEffect, useState } from "react";
+import { api, BudgetResponse } from "../services/api";
+
+interface BudgetPauseControlsProps {
+  conversationId: string;
+}
+
+// Shown under the error message recorded when a conversation pauses on a
+// spending budget. Lets the user raise the conversation's limits and resume.
+function BudgetPauseControls({ conversationId }: BudgetPauseControlsProps) {
+  const [budget, setBudget] = useState<BudgetResponse | null>(null);
+  const [maxDollars, setMaxDollars] = useState("");
+  const [maxTokens, setMaxTokens] = useState("");
+  const [maxTurns, setMaxTurns] = useState("");
+  const [busy, setBusy] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    api
+      .getBudget(conversationId)
+      .then((b) => {
+        setBudget(b);
+        setMaxDollars(b.conversation.max_dollars ? String(b.conversation.max_dollars) : "");
+        setMaxTokens(b.conversation.max_tokens ? String(b.conversation.max_tokens) : "");
+        setMaxTurns(b.conversation.max_turns ? String(b.conversation.max_turns) : "");
+      })
+      .catch((err) => setError(err instanceof Error ? err.message : String(err)));
+  }, [conversationId]);
+
+  const handleResume = async () => {
+    setBusy(true);
+    setError(null);
+    try {
+      await api.setBudget(conversationId, {
+        max_dollars: maxDollars ? parseFloat(maxDollars) : 0,
+        max_tokens: maxTokens ? parseInt(maxTokens, 10) : 0,
+        max_turns: maxTurns ? parseInt(maxTurns, 10) : 0,
+      });
+      await api.resumeConversation(conversationId);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : String(err));
+    } finally {
+      setBusy(false);
+    }
+  };
+
+  const inputStyle = { width: "7rem" };
+
+  return (
+    <div className="budget-pause-controls" data-testid="budget-pause-controls">
+      {budget && (
+        <div className="text-xs" style={{ marginBottom: "0.5rem" }}>
+          Spent ${budget.conversation_spend.cost_usd.toFixed(2)},{" "}
+          {budget.conversation_spend.tokens} tokens, {budget.conversation_spend.turns} turns in this
+          conversation; ${budget.daily_spend.cost_usd.toFixed(2)} today.
+        </div>
+      )}
+      <div style={{ display: "flex", gap: "0.5rem", flexWrap: "wrap", alignItems: "center" }}>
+        <label className="text-xs">
+          Max $
+          <input
+            type="number"
+            min="0"
+            step="0.01"
+            value={maxDollars}
+            onChange={(e) => setMaxDollars(e.target.value)}
+            style={inputStyle}
+          />
+        </label>
+        <label className="text-xs">
+          Max tokens
+          <input
+            type="number"
+            min="0"
+            value={maxTokens}
+            onChange={(e) => setMaxTokens(e.target.value)}
+            style={inputStyle}
+          />
+        </label>
+        <label className="text-xs">
+          Max turns
+          <input
+            type="number"
+            min="0"
+            value={maxTurns}
+            onChange={(e) => setMaxTurns(e.target.value)}
+            style={inputStyle}
+          />
+        </label>
+        <button className="btn-primary btn-sm" onClick={handleResume} disabled={busy}>
+          {busy ? "Resuming..." : "Raise limit and resume"}
+        </button>
+      </div>
+      {budget && budget.exceeded?.startsWith("daily") && (
+        <div className="text-xs" style={{ marginTop: "0.5rem" }}>
+          The daily budget resets at midnight UTC.
+        </div>
+      )}
+      {error && (
+        <div className="text-xs" style={{ color: "var(--error-text)", marginTop: "0.5rem" }}>
+          {error}
+        </div>
+      )}
+    </div>
+  );
+}
+
+export default BudgetPauseControls;
@@ -6,6 +6,9 @@ import {
   LLMContent,
   ConversationListUpdate,
   isDistillStatusMessage,
+  isCompactionStatusMessage,
+  ApprovalRequest,
+  ThinkingLevel,
 } from "../types";
 import { api } from "../services/api";
 import { ThemeMode, getStoredTheme, setStoredTheme, applyTheme } from "../services/theme";
@@ -38,8 +41,20 @@ import OutputIframeTool from "./OutputIframeTool";
 import DirectoryPickerModal from "./DirectoryPickerModal";
 import { useVersionChecker } from "./VersionChecker";
 import TerminalPanel, { EphemeralTerminal } from "./TerminalPanel";
+import ApprovalPanel from "./ApprovalPanel";
+import BackgroundProcessesPanel from "./BackgroundProcessesPanel";
+import SandboxToggle from "./SandboxToggle";
+import MCPStatus from "./MCPStatus";
 import ModelPicker from "./ModelPicker";
+import ThinkingLevelPicker from "./ThinkingLevelPicker";
 import SystemPromptView from "./SystemPromptView";
+import StreamingMessage, {
+  StreamingBlock,
+  StreamPosition,
+  applyStreamDelta,
+  blocksFromResync,
+  followStream,
+} from "./StreamingMessage";
 
 interface ContextUsageBarProps {
   contextWindowSize: number;
@@ -224,6 +239,8 @@ interface CoalescedToolCallProps {
   toolEndTime?: string | null;
   hasResult?: boolean;
   display?: unknown;
+  // progress is the latest output of the tool call while it runs.
+  progress?: string;
   onCommentTextChange?: (text: string) => void;
 }
 
@@ -260,6 +277,7 @@ function CoalescedToolCall({
   toolEndTime,
   hasResult,
   display,
+  progress,
   onCommentTextChange,
 }: CoalescedToolCallProps) {
   // Calculate execution time if available
@@ -285,6 +303,7 @@ function CoalescedToolCall({
       hasError: toolError,
       executionTime,
       display,
+      ...(!hasResult && progress !== undefined ? { progress } : {}),
       ...(toolName === "patch" && onCommentTextChange ? { onCommentTextChange } : {}),
     };
     return <ToolComponent {...props} />;
@@ -460,12 +479,23 @@ interface ChatInterfaceProps {
   onConversationUpdate?: (conversation: Conversation) => void;
   onConversationListUpdate?: (update: ConversationListUpdate) => void;
   onConversationStateUpdate?: (state: ConversationStateUpdate) => void;
-  onFirstMessage?: (message: string, model: string, cwd?: string) => Promise<void>;
+  onFirstMessage?: (
+    message: string,
+    model: string,
+    cwd?: string,
+    thinkingLevel?: ThinkingLevel,
+  ) => Promise<void>;
   onDistillConversation?: (
     sourceConversationId: string,
     model: string,
     cwd?: string,
   ) => Promise<void>;
+  onForkConversation?: (
+    sourceConversationId: string,
+    messageId: string,
+    message?: string,
+    restoreFiles?: boolean,
+  ) => Promise<void>;
   mostRecentCwd?: string | null;
   isDrawerCollapsed?: boolean;
   onToggleDrawerCollapse?: () => void;
@@ -477,6 +507,10 @@ interface ChatInterfaceProps {
   setEphemeralTerminals: React.Dispatch<React.SetStateAction<EphemeralTerminal[]>>;
   navigateUserMessageTrigger?: number; // positive = next, negative = previous
   onConversationUnarchived?: (conversation: Conversation) => void;
+  // jumpToSequenceId scrolls to and highlights the message with this sequence ID
+  // (or the closest one before it) once it is loaded; onJumpDone is then called.
+  jumpToSequenceId?: number | null;
+  onJumpDone?: () => void;
 }
 
 function ChatInterface({
@@ -490,6 +524,7 @@ function ChatInterface({
   onConversationStateUpdate,
   onFirstMessage,
   onDistillConversation,
+  onForkConversation,
   mostRecentCwd,
   isDrawerCollapsed,
   onToggleDrawerCollapse,
@@ -501,8 +536,14 @@ function ChatInterface({
   setEphemeralTerminals,
   navigateUserMessageTrigger,
   onConversationUnarchived,
+  jumpToSequenceId,
+  onJumpDone,
 }: ChatInterfaceProps) {
   const [messages, setMessages] = useState<Message[]>([]);
+  // Partial output of the agent response currently being generated, if any
+  const [streamingBlocks, setStreamingBlocks] = useState<StreamingBlock[]>([]);
+  // Latest output of tool calls that are still running, by tool use ID
+  const [toolProgress, setToolProgress] = useState<Record<string, string>>({});
   const [loading, setLoading] = useState(true);
   const [sending, setSending] = useState(false);
   const [error, setError] = useState<string | null>(null);
@@ -555,6 +596,12 @@ function ChatInterface({
     }
   }, [currentConversation?.conversation_id]);
 
+  // The thinking level sent with the next message; null uses the model's default.
+  const [thinkingLevel, setThinkingLevel] = useState<ThinkingLevel | null>(null);
+  useEffect(() => {
+    setThinkingLevel((currentConversation?.thinking_level as ThinkingLevel | null) ?? null);
+  }, [currentConversation?.conversation_id]);
+
   // Reset cwdInitialized when switching to a new conversation so we re-read from localStorage
   useEffect(() => {
     if (conversationId === null) {
@@ -624,6 +671,8 @@ function ChatInterface({
   const [diffViewerCwd, setDiffViewerCwd] = useState<string | undefined>(undefined);
   const [diffCommentText, setDiffCommentText] = useState("");
   const [agentWorking, setAgentWorking] = useState(false);
+  // Tool calls waiting for approval, by conversation, from conversation_state updates.
+  const [pendingApprovals, setPendingApprovals] = useState<Record<string, ApprovalRequest[]>>({});
   const [cancelling, setCancelling] = useState(false);
   const [contextWindowSize, setContextWindowSize] = useState(0);
   const terminalURL = window.__SHELLEY_INIT__?.terminal_url || null;
@@ -643,6 +692,7 @@ function ChatInterface({
   const periodicRetryRef = useRef<number | null>(null);
   const heartbeatTimeoutRef = useRef<number | null>(null);
   const lastSequenceIdRef = useRef<number>(-1);
+  const streamPositionRef = useRef<StreamPosition | null>(null);
   const hasConnectedRef = useRef(false);
   const userScrolledRef = useRef(false);
   const highlightTimeoutRef = useRef<number | null>(null);
@@ -723,6 +773,8 @@ function ChatInterface({
 
   // Load messages and set up streaming
   useEffect(() => {
+    setStreamingBlocks([]);
+    setToolProgress({});
     if (conversationId) {
       setAgentWorking(false);
       loadMessages();
@@ -877,6 +929,30 @@ function ChatInterface({
     }
   }, [messages, loading]);
 
+  // Jump to a message, e.g. a search match, once the conversation has loaded
+  useEffect(() => {
+    if (loading || jumpToSequenceId == null || !messagesContainerRef.current) return;
+    // Wait until this conversation's messages, not the previous one's, are shown
+    if (messages.length === 0 || messages[0].conversation_id !== conversationId) return;
+    const targetEl = Array.from(
+      messagesContainerRef.current.querySelectorAll<HTMLElement>("[data-sequence-id]"),
+    )
+      .filter((el) => Number(el.dataset.sequenceId) <= jumpToSequenceId)
+      .pop();
+    onJumpDone?.();
+    if (!targetEl) return;
+    userScrolledRef.current = true;
+    targetEl.scrollIntoView({ behavior: "smooth", block: "center" });
+    targetEl.classList.remove("message-highlight");
+    void targetEl.offsetWidth; // Force reflow to restart animation
+    targetEl.classList.add("message-highlight");
+    targetEl.addEventListener(
+      "animationend",
+      () => targetEl.classList.remove("message-highlight"),
+      { once: true },
+    );
+  }, [jumpToSequenceId, loading, messages, conversationId]);
+
   // Close overflow menu when clicking outside
   useEffect(() => {
     const handleClickOutside = (event: MouseEvent) => {
@@ -975,6 +1051,7 @@ function ChatInterface({
       lastSeqId >= 0 ? lastSeqId : undefined,
     );
     eventSourceRef.current = eventSource;
+    streamPositionRef.current = null;
 
     eventSource.onmessage = (event) => {
       // Reset heartbeat timeout on every message
@@ -986,6 +1063,29 @@ function ChatInterface({
 
       try {
         const streamResponse: StreamResponse = JSON.parse(event.data);
+
+        // Streaming deltas carry nothing else; handle them and stop here.
+        if (streamResponse.stream_delta) {
+          const delta = streamResponse.stream_delta;
+          const [position, apply] = followStream(streamPositionRef.current, delta);
+          streamPositionRef.current = position;
+          if (apply) {
+            setStreamingBlocks((prev) => applyStreamDelta(prev, delta));
+          }
+          return;
+        }
+        if (streamResponse.stream_resync) {
+          const resync = streamResponse.stream_resync;
+          streamPositionRef.current = { response: resync.response, seq: resync.seq, gap: false };
+          setStreamingBlocks(blocksFromResync(resync));
+          return;
+        }
+        if (streamResponse.tool_progress) {
+          const { tool_use_id, output } = streamResponse.tool_progress;
+          setToolProgress((prev) => ({ ...prev, [tool_use_id]: output }));
+          return;
+        }
+
         const incomingMessages = Array.isArray(streamResponse.messages)
           ? streamResponse.messages
           : [];
@@ -1001,6 +1101,10 @@ function ChatInterface({
         // Merge new messages without losing existing ones.
         // If no new messages (e.g., only conversation/slug update or heartbeat), keep existing list.
         if (incomingMessages.length > 0) {
+          // The recorded agent message supersedes any partial output
+          if (incomingMessages.some((m) => m.type === "agent")) {
+            setStreamingBlocks([]);
+          }
           setMessages((prev) => {
             const byId = new Map<string, Message>();
             for (const m of prev) byId.set(m.message_id, m);
@@ -1027,6 +1131,14 @@ function ChatInterface({
 
         // Handle conversation state updates (explicit from server)
         if (streamResponse.conversation_state) {
+          const state = streamResponse.conversation_state;
+          setPendingApprovals((prev) => {
+            const approvals = state.pending_approvals || [];
+            if (approvals.length === 0 && !prev[state.conversation_id]) {
+              return prev;
+            }
+            return { ...prev, [state.conversation_id]: approvals };
+          });
           // Update the conversations list with new working state
           if (onConversationStateUpdate) {
             onConversationStateUpdate(streamResponse.conversation_state);
@@ -1034,6 +1146,10 @@ function ChatInterface({
           // Update local state if this is for our conversation
           if (streamResponse.conversation_state.conversation_id === conversationId) {
             setAgentWorking(streamResponse.conversation_state.working);
+            if (!streamResponse.conversation_state.working) {
+              setStreamingBlocks([]);
+              setToolProgress({});
+            }
             // Update selected model from conversation (ensures consistency across sessions)
             if (streamResponse.conversation_state.model) {
               setSelectedModel(streamResponse.conversation_state.model);
@@ -1249,11 +1365,19 @@ function ChatInterface({
             throw new Error(`Invalid working directory: ${validation.error}`);
           }
         }
-        await onFirstMessage(message.trim(), selectedModel, selectedCwd || undefined);
+        await onFirstMessage(
+          message.trim(),
+          selectedModel,
+          selectedCwd || undefined,
+          thinkingLevel ?? undefined,
+        );
       } else if (conversationId) {
         await api.sendMessage(conversationId, {
           message: message.trim(),
           model: selectedModel,
+          // Going back to the default has to clear the conversation's level.
+          thinking_level:
+            thinkingLevel ?? (currentConversation?.thinking_level ? "default" : undefined),
         });
       }
     } catch (err) {
@@ -1427,9 +1551,9 @@ function ChatInterface({
 
     // Second pass: process messages and extract tool uses
     messages.forEach((message) => {
-      // Allow system messages with distill_status through, skip others
+      // Allow system messages with distill or compaction status through, skip others
       if (message.type === "system") {
-        if (!isDistillStatusMessage(message)) {
+        if (!isDistillStatusMessage(message) && !isCompactionStatusMessage(message)) {
           return;
         }
         coalescedItems.push({ type: "message", message });
@@ -1485,12 +1609,14 @@ function ChatInterface({
               }
             });
 
-            // If we have text content, add it as a message (but only if it's not empty)
+            // If we have text content, add it as a message (but only if it's not empty).
+            // Server tool uses (17) and their results are rendered within the message.
             const textString = textContents
               .map((c) => c.Text || "")
               .join("")
               .trim();
-            if (textString) {
+            const hasServerTools = llmData.Content.some((c: LLMContent) => c.Type === 17);
+            if (textString || hasServerTools) {
               coalescedItems.push({ type: "message", message });
             }
 
@@ -1593,6 +1719,18 @@ function ChatInterface({
               setShowDiffViewer(true);
             }}
             onCommentTextChange={setDiffCommentText}
+            isLatest={item.message === messages[messages.length - 1]}
+            onFork={
+              conversationId && onForkConversation
+                ? (message, restoreFiles) =>
+                    onForkConversation(
+                      conversationId,
+                      item.message!.message_id,
+                      message,
+                      restoreFiles,
+                    )
+                : undefined
+            }
           />
         );
       } else if (item.type === "tool") {
@@ -1607,6 +1745,7 @@ function ChatInterface({
             toolEndTime={item.toolEndTime}
             hasResult={item.hasResult}
             display={item.display}
+            progress={item.toolUseId ? toolProgress[item.toolUseId] : undefined}
             onCommentTextChange={setDiffCommentText}
           />
         );
@@ -1614,8 +1753,10 @@ function ChatInterface({
       return null;
     });
 
-    // Find system prompt message to render at the top (exclude distill status messages)
-    const systemMessage = messages.find((m) => m.type === "system" && !isDistillStatusMessage(m));
+    // Find system prompt message to render at the top (exclude status messages)
+    const systemMessage = messages.find(
+      (m) => m.type === "system" && !isDistillStatusMessage(m) && !isCompactionStatusMessage(m),
+    );
 
     return [
       systemMessage && <SystemPromptView key="system-prompt" message={systemMessage} />,
@@ -1997,7 +2138,10 @@ function ChatInterface({
               <div className="spinner"></div>
             </div>
           ) : (
-            <div className="messages-list">{renderMessages()}</div>
+            <div className="messages-list">
+              {renderMessages()}
+              <StreamingMessage blocks={streamingBlocks} />
+            </div>
           )}
         </div>
 
@@ -2040,6 +2184,21 @@ function ChatInterface({
         }}
       />
 
+      {/* Tool calls waiting for approval, including those of this conversation's subagents */}
+      <ApprovalPanel
+        approvals={Object.values(pendingApprovals)
+          .flat()
+          .filter(
+            (a) =>
+              a.conversation_id === conversationId || a.parent_conversation_id === conversationId,
+          )}
+      />
+
+      {/* Processes started with the background tool */}
+      {conversationId && (
+        <BackgroundProcessesPanel conversationId={conversationId} refreshKey={messages.length} />
+      )}
+
       {/* Unified Status Bar */}
       <div className="status-bar">
         <div className="status-bar-content">
@@ -2130,6 +2289,18 @@ function ChatInterface({
                 />
               </div>
 
+              <div
+                className="status-field status-field-thinking"
+                title="How much the model thinks before answering"
+              >
+                <span className="status-field-label">Thinking:</span>
+                <ThinkingLevelPicker
+                  value={thinkingLevel}
+                  onChange={setThinkingLevel}
+                  disabled={sending}
+                />
+              </div>
+
               {/* CWD indicator - far right */}
               <div
                 className={`status-field status-field-cwd${cwdError ? " status-field-error" : ""}`}
@@ -2149,6 +2320,13 @@ function ChatInterface({
             // Active conversation - show Ready + context bar
             <div className="status-bar-active">
               <span className="status-message status-ready">Ready on {hostname}</span>
+              <SandboxToggle conversationId={conversationId} />
+              <ThinkingLevelPicker
+                value={thinkingLevel}
+                onChange={setThinkingLevel}
+                disabled={sending}
+              />
+              <MCPStatus conversationId={conversationId} refreshKey={messages.length} />
               <ContextUsageBar
                 contextWindowSize={contextWindowSize}
                 maxContextTokens={
@@ -0,0 +1,44 @@
+import React from "react";
+import { Citation } from "../types";
+
+interface CitationsProps {
+  citations: Citation[];
+}
+
+// Citations lists the sources a text block cites: web pages found by a server
+// tool, or passages of attached documents. Repeated sources are shown once.
+function Citations({ citations }: CitationsProps) {
+  const sources = new Map<string, Citation[]>();
+  for (const c of citations) {
+    const key = c.url || c.document_title || `Document ${(c.document_index ?? 0) + 1}`;
+    sources.set(key, [...(sources.get(key) || []), c]);
+  }
+
+  return (
+    <div className="text-sm text-secondary" style={{ marginTop: "0.5rem" }}>
+      <div>Sources:</div>
+      <ol style={{ margin: 0, paddingLeft: "1.5rem" }}>
+        {[...sources].map(([key, cited]) => (
+          <li key={key}>
+            {cited[0].url ? (
+              <a href={cited[0].url} target="_blank" rel="noopener noreferrer">
+                {cited[0].title || cited[0].url}
+              </a>
+            ) : (
+              key
+            )}
+            {cited
+              .filter((c) => c.cited_text)
+              .map((c, i) => (
+                <blockquote key={i} style={{ margin: "0.25rem 0 0.25rem 0.5rem" }}>
+                  {c.cited_text}
+                </blockquote>
+              ))}
+          </li>
+        ))}
+      </ol>
+    </div>
+  );
+}
+
+export default Citations;
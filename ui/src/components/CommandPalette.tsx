@@ -8,6 +8,7 @@ interface CommandItem {
   type: "action" | "conversation";
   title: string;
   subtitle?: string;
+  snippet?: string; // search match, highlighted between \u0002 and \u0003
   shortcut?: string;
   icon?: React.ReactNode;
   action: () => void;
@@ -26,6 +27,7 @@ interface CommandPaletteProps {
   onOpenDiffViewer: () => void;
   onOpenModelsModal: () => void;
   onOpenNotificationsModal: () => void;
+  onOpenAccessModal: () => void;
   onNextConversation: () => void;
   onPreviousConversation: () => void;
   onNextUserMessage: () => void;
@@ -33,6 +35,20 @@ interface CommandPaletteProps {
   hasCwd: boolean;
 }
 
+// Render a search snippet, highlighting the matches the server marked
+function renderSnippet(snippet: string): React.ReactNode {
+  return snippet.split("\u0002").map((part, i) => {
+    const end = part.indexOf("\u0003");
+    if (i === 0 || end < 0) return <React.Fragment key={i}>{part}</React.Fragment>;
+    return (
+      <React.Fragment key={i}>
+        <mark>{part.slice(0, end)}</mark>
+        {part.slice(end + 1)}
+      </React.Fragment>
+    );
+  });
+}
+
 // Simple fuzzy match for actions - returns score (higher is better), -1 if no match
 function fuzzyMatch(query: string, text: string): number {
   const lowerQuery = query.toLowerCase();
@@ -80,6 +96,7 @@ function CommandPalette({
   onOpenDiffViewer,
   onOpenModelsModal,
   onOpenNotificationsModal,
+  onOpenAccessModal,
   onNextConversation,
   onPreviousConversation,
   onNextUserMessage,
@@ -335,6 +352,30 @@ function CommandPalette({
       keywords: ["notification", "notify", "alert", "discord", "webhook", "browser", "favicon"],
     });
 
+    if (window.__SHELLEY_INIT__?.auth?.enabled) {
+      items.push({
+        id: "access-settings",
+        type: "action",
+        title: "Sessions & API Tokens",
+        subtitle: "Manage sign-ins and tokens, or sign out",
+        icon: (
+          <svg fill="none" stroke="currentColor" viewBox="0 0 24 24" width="16" height="16">
+            <path
+              strokeLinecap="round"
+              strokeLinejoin="round"
+              strokeWidth={2}
+              d="M12 15v2m-6 4h12a2 2 0 002-2v-6a2 2 0 00-2-2H6a2 2 0 00-2 2v6a2 2 0 002 2zm10-10V7a4 4 0 00-8 0v4h8z"
+            />
+          </svg>
+        ),
+        action: () => {
+          onOpenAccessModal();
+          onClose();
+        },
+        keywords: ["auth", "session", "token", "api", "login", "logout", "sign out", "password"],
+      });
+    }
+
     const mdLabels: Record<
       string,
       { title: string; subtitle: string; next: "off" | "agent" | "all" }
@@ -470,6 +511,7 @@ function CommandPalette({
     onOpenDiffViewer,
     onOpenModelsModal,
     onOpenNotificationsModal,
+    onOpenAccessModal,
     onArchiveConversation,
     onNewConversationWithCwd,
     onClose,
@@ -487,6 +529,7 @@ function CommandPalette({
       type: "conversation",
       title: conv.slug || conv.conversation_id,
       subtitle: conv.cwd || undefined,
+      snippet: conv.match_snippet || undefined,
       icon: (
         <svg fill="none" stroke="currentColor" viewBox="0 0 24 24" width="16" height="16">
           <path
@@ -637,6 +680,11 @@ function CommandPalette({
                   {item.subtitle && (
                     <div className="command-palette-item-subtitle">{item.subtitle}</div>
                   )}
+                  {item.snippet && (
+                    <div className="command-palette-item-snippet">
+                      {renderSnippet(item.snippet)}
+                    </div>
+                  )}
                 </div>
                 {item.shortcut && (
                   <div className="command-palette-item-shortcut">
@@ -403,6 +403,25 @@ function ConversationDrawer({
     return sorted;
   }, [conversations, groupBy, showArchived]);
 
+  // Shows which conversation a fork branched off from, linking to it if it's listed.
+  const renderForkLineage = (sourceId: string) => {
+    const source = conversations.find((c) => c.conversation_id === sourceId);
+    const label = source ? getConversationPreview(source) : "another conversation";
+    return (
+      <span
+        className="conversation-fork"
+        title={`Forked from ${label}`}
+        onClick={(e) => {
+          if (!source || showArchived) return;
+          e.stopPropagation();
+          onSelectConversation(source);
+        }}
+      >
+        ⑂ {label}
+      </span>
+    );
+  };
+
   const renderConversationItem = (conversation: Conversation | ConversationWithState) => {
     const convState = conversation as ConversationWithState;
     const isActive = conversation.conversation_id === currentConversationId;
@@ -471,6 +490,13 @@ function ConversationDrawer({
                   {formatCwdForDisplay(conversation.cwd)}
                 </span>
               )}
+              {conversation.forked_from_conversation_id &&
+                renderForkLineage(conversation.forked_from_conversation_id)}
+              {conversation.scheduled_task_id && (
+                <span className="conversation-scheduled" title="Started by a scheduled task">
+                  scheduled
+                </span>
+              )}
               {!showArchived && (
                 <div
                   className="conversation-actions"
@@ -0,0 +1,111 @@
+import React, { useState } from "react";
+import { authApi } from "../services/api";
+
+interface LoginPageProps {
+  setupRequired: boolean;
+}
+
+// LoginPage is shown instead of the app when the server runs with --auth and
+// the browser hasn't signed in. On first run, it sets the password, which
+// needs the setup code from the server log.
+function LoginPage({ setupRequired }: LoginPageProps) {
+  const [setupCode, setSetupCode] = useState("");
+  const [password, setPassword] = useState("");
+  const [confirm, setConfirm] = useState("");
+  const [error, setError] = useState<string | null>(null);
+  const [submitting, setSubmitting] = useState(false);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError(null);
+    if (setupRequired && password !== confirm) {
+      setError("Passwords don't match");
+      return;
+    }
+    setSubmitting(true);
+    try {
+      if (setupRequired) {
+        await authApi.setup(setupCode, password);
+      } else {
+        await authApi.login(password);
+      }
+      // Reload so the server sends the app's init data.
+      window.location.reload();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to sign in");
+      setSubmitting(false);
+    }
+  };
+
+  return (
+    <div className="login-page">
+      <form className="modal login-card" onSubmit={handleSubmit}>
+        <div className="modal-header">
+          <h2 className="modal-title">{setupRequired ? "Choose a Password" : "Sign In"}</h2>
+        </div>
+        <div className="modal-body">
+          {setupRequired && (
+            <div className="form-group">
+              <label htmlFor="setup-code">Setup Code</label>
+              <input
+                id="setup-code"
+                className="form-input"
+                value={setupCode}
+                onChange={(e) => setSetupCode(e.target.value)}
+                autoComplete="off"
+                autoFocus
+              />
+              <span
+                style={{
+                  fontSize: "0.75rem",
+                  color: "var(--text-secondary)",
+                  marginTop: "0.25rem",
+                  display: "block",
+                }}
+              >
+                Printed in the server log when Shelley started.
+              </span>
+            </div>
+          )}
+          <div className="form-group">
+            <label htmlFor="password">Password</label>
+            <input
+              id="password"
+              type="password"
+              className="form-input"
+              value={password}
+              onChange={(e) => setPassword(e.target.value)}
+              autoComplete={setupRequired ? "new-password" : "current-password"}
+              autoFocus={!setupRequired}
+            />
+          </div>
+          {setupRequired && (
+            <div className="form-group">
+              <label htmlFor="confirm-password">Confirm Password</label>
+              <input
+                id="confirm-password"
+                type="password"
+                className="form-input"
+                value={confirm}
+                onChange={(e) => setConfirm(e.target.value)}
+                autoComplete="new-password"
+              />
+            </div>
+          )}
+          {error && <div className="test-result error">{error}</div>}
+          <div className="form-actions">
+            <button
+              type="submit"
+              className="btn btn-primary"
+              disabled={submitting || password === "" || (setupRequired && setupCode === "")}
+            >
+              {submitting ? "Signing in..." : setupRequired ? "Set Password" : "Sign In"}
+            </button>
+          </div>
+        </div>
+      </form>
+    </div>
+  );
+}
+
+export default LoginPage;
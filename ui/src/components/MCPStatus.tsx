@@ -0,0 +1,45 @@
+import React, { useEffect, useState } from "react";
+import { api, MCPServerStatus } from "../services/api";
+
+interface MCPStatusProps {
+  conversationId: string;
+  // Changes whenever the conversation's loop may have started.
+  refreshKey: unknown;
+}
+
+// Shows how many of the conversation's MCP servers are healthy, with details on hover.
+function MCPStatus({ conversationId, refreshKey }: MCPStatusProps) {
+  const [statuses, setStatuses] = useState<MCPServerStatus[]>([]);
+
+  useEffect(() => {
+    api
+      .getConversationMCP(conversationId)
+      .then(setStatuses)
+      .catch(() => setStatuses([]));
+  }, [conversationId, refreshKey]);
+
+  if (statuses.length === 0) {
+    return null;
+  }
+
+  const healthy = statuses.filter((s) => s.healthy).length;
+  const details = statuses
+    .map((s) =>
+      s.healthy
+        ? `${s.name}: ${s.tools.length} tool${s.tools.length === 1 ? "" : "s"}`
+        : `${s.name}: ${s.error || "unavailable"}`,
+    )
+    .join("\n");
+
+  return (
+    <span
+      className={`status-chip${healthy < statuses.length ? " status-chip-error" : ""}`}
+      title={details}
+      data-testid="mcp-status"
+    >
+      MCP {healthy}/{statuses.length}
+    </span>
+  );
+}
+
+export default MCPStatus;
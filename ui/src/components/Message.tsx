@@ -8,6 +8,7 @@ import {
   LLMContent,
   Usage,
   isDistillStatusMessage,
+  isCompactionStatusMessage,
 } from "../types";
 import BashTool from "./BashTool";
 import PatchTool from "./PatchTool";
@@ -24,9 +25,12 @@ import ChangeDirTool from "./ChangeDirTool";
 import SubagentTool from "./SubagentTool";
 import LLMOneShotTool from "./LLMOneShotTool";
 import OutputIframeTool from "./OutputIframeTool";
+import ServerTool from "./ServerTool";
+import Citations from "./Citations";
 import ThinkingContent from "./ThinkingContent";
 import UsageDetailModal from "./UsageDetailModal";
 import MessageActionBar from "./MessageActionBar";
+import BudgetPauseControls from "./BudgetPauseControls";
 import { type MarkdownMode } from "../services/settings";
 
 /** Should we render markdown for this content block? */
@@ -53,6 +57,11 @@ interface MessageProps {
   message: MessageType;
   onOpenDiffViewer?: (commit: string, cwd?: string) => void;
   onCommentTextChange?: (text: string) => void;
+  // isLatest is true for the conversation's most recent message.
+  isLatest?: boolean;
+  // onFork forks the conversation at this message, replacing it with
+  // message if given. restoreFiles rewinds files changed since then.
+  onFork?: (message?: string, restoreFiles?: boolean) => Promise<void>;
 }
 
 // Copy icon for the commit hash copy button
@@ -300,7 +309,64 @@ function DistillStatusMessage({ message }: { message: MessageType }) {
   );
 }
 
-function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProps) {
+// CompactionStatusMessage marks where older messages were compacted into a summary
+function CompactionStatusMessage({ message }: { message: MessageType }) {
+  let status = "in_progress";
+
+  if (message.user_data) {
+    try {
+      const userData =
+        typeof message.user_data === "string" ? JSON.parse(message.user_data) : message.user_data;
+      status = userData.compaction_status || "in_progress";
+    } catch {
+      // ignore parse errors
+    }
+  }
+
+  const isError = status === "error";
+
+  return (
+    <div
+      className="message message-gitinfo"
+      style={{
+        padding: "0.5rem 1rem",
+        fontSize: "0.8rem",
+        color: isError ? "var(--error-text)" : "var(--text-secondary)",
+        textAlign: "center",
+        fontStyle: "italic",
+        borderTop: "1px dashed var(--border)",
+      }}
+    >
+      {status === "in_progress" && (
+        <span data-testid="compaction-in-progress">
+          <span
+            className="spinner spinner-small"
+            style={{
+              display: "inline-block",
+              marginRight: "6px",
+              verticalAlign: "middle",
+            }}
+          />
+          Compacting conversation context…
+        </span>
+      )}
+      {status === "complete" && (
+        <span data-testid="compaction-complete">
+          Context compacted — earlier messages are summarized below
+        </span>
+      )}
+      {isError && <span data-testid="compaction-error">Context compaction failed</span>}
+    </div>
+  );
+}
+
+function Message({
+  message,
+  onOpenDiffViewer,
+  onCommentTextChange,
+  isLatest,
+  onFork,
+}: MessageProps) {
   const { markdownMode } = useMarkdown();
 
   // Render system messages with distill_status as status indicators
@@ -308,6 +374,9 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
     if (isDistillStatusMessage(message)) {
       return <DistillStatusMessage message={message} />;
     }
+    if (isCompactionStatusMessage(message)) {
+      return <CompactionStatusMessage message={message} />;
+    }
     return null;
   }
 
@@ -320,6 +389,11 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
   const [showActionBar, setShowActionBar] = useState(false);
   const [isHovered, setIsHovered] = useState(false);
   const [showUsageModal, setShowUsageModal] = useState(false);
+  // Edit-and-resend state (user messages only)
+  const [editText, setEditText] = useState<string | null>(null);
+  const [forking, setForking] = useState(false);
+  const [forkError, setForkError] = useState<string | null>(null);
+  const [restoreFiles, setRestoreFiles] = useState(false);
   const messageRef = useRef<HTMLDivElement | null>(null);
 
   // Show action bar on hover or when explicitly tapped
@@ -350,7 +424,8 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
   // Based on llm/llm.go constants (iota continues across types in same const block):
   // MessageRoleUser = 0, MessageRoleAssistant = 1,
   // ContentTypeText = 2, ContentTypeThinking = 3, ContentTypeRedactedThinking = 4,
-  // ContentTypeToolUse = 5, ContentTypeToolResult = 6
+  // ContentTypeToolUse = 5, ContentTypeToolResult = 6,
+  // ContentTypeServerToolUse = 17, ContentTypeServerToolResult = 18
   const getContentType = (type: number): string => {
     switch (type) {
       case 0:
@@ -367,6 +442,10 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
         return "tool_use";
       case 6:
         return "tool_result";
+      case 17:
+        return "server_tool_use";
+      case 18:
+        return "server_tool_result";
       default:
         return "unknown";
     }
@@ -457,6 +536,22 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
     setShowActionBar(false);
   };
 
+  // Fork the conversation at this message, optionally replacing its text
+  const handleFork = async (replacement?: string) => {
+    if (!onFork) return;
+    setForking(true);
+    setForkError(null);
+    setShowActionBar(false);
+    try {
+      await onFork(replacement, replacement !== undefined && restoreFiles);
+      setEditText(null);
+    } catch (err) {
+      setForkError(err instanceof Error ? err.message : String(err));
+    } finally {
+      setForking(false);
+    }
+  };
+
   let displayData: ToolDisplay[] | null = null;
   if (message.display_data) {
     try {
@@ -484,7 +579,7 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
   const isTool = message.type === "tool" || hasToolContent(llmMessage);
   const isError = message.type === "error";
 
-  // Check if this is a distilled user message (LLM-generated, treat as agent for markdown)
+  // Check if this is a distilled or compaction summary user message (LLM-generated, treat as agent for markdown)
   const isDistilledUser =
     isUser &&
     (() => {
@@ -492,7 +587,7 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
       try {
         const ud =
           typeof message.user_data === "string" ? JSON.parse(message.user_data) : message.user_data;
-        return ud?.distilled === "true";
+        return ud?.distilled === "true" || ud?.compacted === "true";
       } catch {
         return false;
       }
@@ -502,6 +597,8 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
   const messageText = getMessageText();
   const hasCopyAction = !!messageText;
   const hasUsageAction = message.type === "agent" && !!usage;
+  const hasEditAction = !!onFork && isUser && !isDistilledUser && !!messageText;
+  const hasForkAction = !!onFork && (isUser || message.type === "agent");
 
   // Build a map of tool use IDs to their inputs for linking tool_result back to tool_use
   const toolUseMap: Record<string, { name: string; input: unknown }> = {};
@@ -541,11 +638,45 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
           </div>
         );
       case "text":
-        if (shouldRenderMarkdown(markdownMode, isUser, isDistilledUser)) {
-          return <MarkdownContent text={content.Text || ""} />;
+        // Attachments: images and documents sent with a user message
+        if (content.MediaType?.startsWith("image/") && content.Data) {
+          return (
+            <img
+              src={`data:${content.MediaType};base64,${content.Data}`}
+              alt="Attached image"
+              className="rounded border"
+              style={{ maxWidth: "100%", height: "auto", maxHeight: "300px" }}
+            />
+          );
+        }
+        if (content.MediaType) {
+          return (
+            <div className="text-sm text-secondary" style={{ fontFamily: "monospace" }}>
+              📎 {content.Filename || "attachment"} ({content.MediaType})
+            </div>
+          );
         }
         return (
-          <div className="whitespace-pre-wrap break-words">{linkifyText(content.Text || "")}</div>
+          <>
+            {shouldRenderMarkdown(markdownMode, isUser, isDistilledUser) ? (
+              <MarkdownContent text={content.Text || ""} />
+            ) : (
+              <div className="whitespace-pre-wrap break-words">
+                {linkifyText(content.Text || "")}
+              </div>
+            )}
+            {content.Citations && content.Citations.length > 0 && (
+              <Citations citations={content.Citations} />
+            )}
+          </>
+        );
+      case "server_tool_use":
+        // Server tools run at the provider, so the result is in this same message.
+        return (
+          <ServerTool
+            toolUse={content}
+            result={llmMessage?.Content?.find((c) => c.Type === 18 && c.ToolUseID === content.ID)}
+          />
         );
       case "tool_use":
         // IMPORTANT: When adding a new tool component here, also add it to:
@@ -1046,6 +1177,7 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
           onMouseLeave={handleMouseLeave}
           style={{ position: "relative" }}
           data-testid="message"
+          data-sequence-id={message.sequence_id}
           role="alert"
           aria-label="Error message"
         >
@@ -1057,6 +1189,9 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
           )}
           <div className="message-content" data-testid="message-content">
             <div className="whitespace-pre-wrap break-words">{errorText}</div>
+            {isLatest && llmMessage?.ErrorType === "budget" && (
+              <BudgetPauseControls conversationId={message.conversation_id} />
+            )}
           </div>
         </div>
         {showUsageModal && usage && (
@@ -1082,6 +1217,7 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
           onMouseLeave={handleMouseLeave}
           style={{ position: "relative" }}
           data-testid="message"
+          data-sequence-id={message.sequence_id}
           role="article"
         >
           {actionBarVisible && (hasCopyAction || hasUsageAction) && (
@@ -1114,6 +1250,7 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
 
   // Filter out redacted thinking, empty content, tool_use, and tool_result
   // Keep thinking content (3) for display
+  // Server tool results (18) are shown with their server_tool_use (17)
   const meaningfulContent =
     llmMessage?.Content?.filter((c) => {
       const contentType = c.Type;
@@ -1126,6 +1263,7 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
         contentType !== 4 &&
         contentType !== 5 &&
         contentType !== 6 &&
+        contentType !== 18 &&
         (c.Text?.trim() || contentType !== 2)
       ); // 4 = redacted_thinking, 5 = tool_use, 6 = tool_result, 2 = text
     }) || [];
@@ -1155,19 +1293,67 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
         onMouseLeave={handleMouseLeave}
         style={{ position: "relative" }}
         data-testid="message"
+        data-sequence-id={message.sequence_id}
         role="article"
       >
-        {actionBarVisible && (hasCopyAction || hasUsageAction) && (
-          <MessageActionBar
-            onCopy={hasCopyAction ? handleCopy : undefined}
-            onShowUsage={hasUsageAction ? handleShowUsage : undefined}
-          />
-        )}
+        {actionBarVisible &&
+          editText === null &&
+          (hasCopyAction || hasUsageAction || hasEditAction || hasForkAction) && (
+            <MessageActionBar
+              onCopy={hasCopyAction ? handleCopy : undefined}
+              onShowUsage={hasUsageAction ? handleShowUsage : undefined}
+              onEdit={hasEditAction ? () => setEditText(messageText) : undefined}
+              onFork={hasForkAction && !forking ? () => handleFork() : undefined}
+            />
+          )}
         {/* Message content */}
         <div className="message-content" data-testid="message-content">
-          {contentToRender.map((content, index) => (
-            <div key={index}>{renderContent(content)}</div>
-          ))}
+          {editText !== null ? (
+            <div className="message-edit">
+              <textarea
+                value={editText}
+                onChange={(e) => setEditText(e.target.value)}
+                rows={Math.min(12, Math.max(3, editText.split("\n").length))}
+                autoFocus
+              />
+              <div className="message-edit-buttons">
+                <label
+                  className="message-edit-restore"
+                  title="Also undo file changes the agent made since this message was sent"
+                >
+                  <input
+                    type="checkbox"
+                    checked={restoreFiles}
+                    onChange={(e) => setRestoreFiles(e.target.checked)}
+                    disabled={forking}
+                  />
+                  Rewind files too
+                </label>
+                <button
+                  className="btn-secondary btn-sm"
+                  onClick={() => setEditText(null)}
+                  disabled={forking}
+                >
+                  Cancel
+                </button>
+                <button
+                  className="btn-primary btn-sm"
+                  onClick={() => handleFork(editText)}
+                  disabled={forking || !editText.trim()}
+                  title="Send the edited message in a new conversation forked from here"
+                >
+                  {forking ? "Forking..." : "Send in new fork"}
+                </button>
+              </div>
+            </div>
+          ) : (
+            contentToRender.map((content, index) => <div key={index}>{renderContent(content)}</div>)
+          )}
+          {forkError && (
+            <div className="text-xs" style={{ color: "var(--error-text)", marginTop: "0.5rem" }}>
+              {forkError}
+            </div>
+          )}
         </div>
       </div>
       {showUsageModal && usage && (
@@ -3,9 +3,33 @@ import React, { useState } from "react";
 interface MessageActionBarProps {
   onCopy?: () => void;
   onShowUsage?: () => void;
+  onEdit?: () => void;
+  onFork?: () => void;
 }
 
-function MessageActionBar({ onCopy, onShowUsage }: MessageActionBarProps) {
+const plainButtonStyle: React.CSSProperties = {
+  display: "flex",
+  alignItems: "center",
+  justifyContent: "center",
+  width: "24px",
+  height: "24px",
+  borderRadius: "4px",
+  border: "none",
+  background: "transparent",
+  cursor: "pointer",
+  color: "var(--text-secondary)",
+  transition: "background-color 0.15s",
+};
+
+function highlightButton(e: React.MouseEvent<HTMLButtonElement>) {
+  e.currentTarget.style.backgroundColor = "var(--bg-tertiary)";
+}
+
+function unhighlightButton(e: React.MouseEvent<HTMLButtonElement>) {
+  e.currentTarget.style.backgroundColor = "transparent";
+}
+
+function MessageActionBar({ onCopy, onShowUsage, onEdit, onFork }: MessageActionBarProps) {
   const [copyFeedback, setCopyFeedback] = useState(false);
 
   const handleCopy = (e: React.MouseEvent) => {
@@ -99,6 +123,61 @@ function MessageActionBar({ onCopy, onShowUsage }: MessageActionBarProps) {
           )}
         </button>
       )}
+      {onEdit && (
+        <button
+          onClick={(e) => {
+            e.stopPropagation();
+            onEdit();
+          }}
+          title="Edit and resend in a new conversation"
+          style={plainButtonStyle}
+          onMouseEnter={highlightButton}
+          onMouseLeave={unhighlightButton}
+        >
+          <svg
+            width="16"
+            height="16"
+            viewBox="0 0 24 24"
+            fill="none"
+            stroke="currentColor"
+            strokeWidth="2"
+            strokeLinecap="round"
+            strokeLinejoin="round"
+          >
+            <path d="M12 20h9"></path>
+            <path d="M16.5 3.5a2.121 2.121 0 0 1 3 3L7 19l-4 1 1-4L16.5 3.5z"></path>
+          </svg>
+        </button>
+      )}
+      {onFork && (
+        <button
+          onClick={(e) => {
+            e.stopPropagation();
+            onFork();
+          }}
+          title="Fork conversation from here"
+          style={plainButtonStyle}
+          onMouseEnter={highlightButton}
+          onMouseLeave={unhighlightButton}
+        >
+          <svg
+            width="16"
+            height="16"
+            viewBox="0 0 24 24"
+            fill="none"
+            stroke="currentColor"
+            strokeWidth="2"
+            strokeLinecap="round"
+            strokeLinejoin="round"
+          >
+            <circle cx="6" cy="6" r="2"></circle>
+            <circle cx="6" cy="18" r="2"></circle>
+            <circle cx="18" cy="8" r="2"></circle>
+            <path d="M6 8v8"></path>
+            <path d="M18 10c0 4-6 3-12 6"></path>
+          </svg>
+        </button>
+      )}
       {onShowUsage && (
         <button
           onClick={handleShowUsage}
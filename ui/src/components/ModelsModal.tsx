@@ -13,13 +13,14 @@ interface ModelsModalProps {
   onModelsChanged?: () => void;
 }
 
-type ProviderType = "anthropic" | "openai" | "openai-responses" | "gemini";
+type ProviderType = "anthropic" | "openai" | "openai-responses" | "gemini" | "local";
 
 const DEFAULT_ENDPOINTS: Record<ProviderType, string> = {
   anthropic: "https://api.anthropic.com/v1/messages",
   openai: "https://api.openai.com/v1",
   "openai-responses": "https://api.openai.com/v1",
   gemini: "https://generativelanguage.googleapis.com/v1beta",
+  local: "http://localhost:11434/v1",
 };
 
 const PROVIDER_LABELS: Record<ProviderType, string> = {
@@ -27,6 +28,7 @@ const PROVIDER_LABELS: Record<ProviderType, string> = {
   openai: "OpenAI (Chat API)",
   "openai-responses": "OpenAI (Responses API)",
   gemini: "Google Gemini",
+  local: "Local (Ollama, llama.cpp, vLLM)",
 };
 
 const DEFAULT_MODELS: Record<ProviderType, { name: string; model_name: string }[]> = {
@@ -41,6 +43,7 @@ const DEFAULT_MODELS: Record<ProviderType, { name: string; model_name: string }[
     { name: "Gemini 3 Pro", model_name: "gemini-3-pro-preview" },
     { name: "Gemini 3 Flash", model_name: "gemini-3-flash-preview" },
   ],
+  local: [],
 };
 
 // Built-in model info from init data
@@ -60,6 +63,10 @@ interface FormData {
   model_name: string;
   max_tokens: number;
   tags: string; // Comma-separated tags
+  input_price_per_mtok: number;
+  output_price_per_mtok: number;
+  cache_read_price_per_mtok: number;
+  cache_write_price_per_mtok: number;
 }
 
 const emptyForm: FormData = {
@@ -71,6 +78,10 @@ const emptyForm: FormData = {
   model_name: "",
   max_tokens: 200000,
   tags: "",
+  input_price_per_mtok: 0,
+  output_price_per_mtok: 0,
+  cache_read_price_per_mtok: 0,
+  cache_write_price_per_mtok: 0,
 };
 
 function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
@@ -123,6 +134,8 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
       ...prev,
       provider_type: provider,
       endpoint: prev.endpoint_custom ? prev.endpoint : DEFAULT_ENDPOINTS[provider],
+      // Local servers report their context window.
+      max_tokens: provider === "local" ? 0 : prev.max_tokens || 200000,
     }));
   };
 
@@ -148,7 +161,7 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
       setTestResult({ success: false, message: "Model name is required" });
       return;
     }
-    if (!form.api_key && !editingModelId) {
+    if (!form.api_key && !editingModelId && form.provider_type !== "local") {
       setTestResult({ success: false, message: "API key is required" });
       return;
     }
@@ -177,7 +190,8 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
   };
 
   const handleSave = async () => {
-    if (!form.display_name || !form.api_key || !form.model_name) {
+    const needsAPIKey = form.provider_type !== "local";
+    if (!form.display_name || (needsAPIKey && !form.api_key) || !form.model_name) {
       setError("Display name, API key, and model name are required");
       return;
     }
@@ -192,6 +206,10 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
         model_name: form.model_name,
         max_tokens: form.max_tokens,
         tags: form.tags,
+        input_price_per_mtok: form.input_price_per_mtok,
+        output_price_per_mtok: form.output_price_per_mtok,
+        cache_read_price_per_mtok: form.cache_read_price_per_mtok,
+        cache_write_price_per_mtok: form.cache_write_price_per_mtok,
       };
 
       if (editingModelId) {
@@ -222,6 +240,10 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
       model_name: model.model_name,
       max_tokens: model.max_tokens,
       tags: model.tags,
+      input_price_per_mtok: model.input_price_per_mtok ?? 0,
+      output_price_per_mtok: model.output_price_per_mtok ?? 0,
+      cache_read_price_per_mtok: model.cache_read_price_per_mtok ?? 0,
+      cache_write_price_per_mtok: model.cache_write_price_per_mtok ?? 0,
     });
     setShowForm(true);
     setTestResult(null);
@@ -301,18 +323,16 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
             <div className="form-group">
               <label>Provider / API Format</label>
               <div className="provider-buttons">
-                {(["anthropic", "openai", "openai-responses", "gemini"] as ProviderType[]).map(
-                  (p) => (
-                    <button
-                      key={p}
-                      type="button"
-                      className={`provider-btn ${form.provider_type === p ? "selected" : ""}`}
-                      onClick={() => handleProviderChange(p)}
-                    >
-                      {PROVIDER_LABELS[p]}
-                    </button>
-                  ),
-                )}
+                {(Object.keys(PROVIDER_LABELS) as ProviderType[]).map((p) => (
+                  <button
+                    key={p}
+                    type="button"
+                    className={`provider-btn ${form.provider_type === p ? "selected" : ""}`}
+                    onClick={() => handleProviderChange(p)}
+                  >
+                    {PROVIDER_LABELS[p]}
+                  </button>
+                ))}
               </div>
             </div>
 
@@ -391,7 +411,7 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
                 type="text"
                 value={form.api_key}
                 onChange={(e) => setForm((prev) => ({ ...prev, api_key: e.target.value }))}
-                placeholder="Enter API key"
+                placeholder={form.provider_type === "local" ? "Optional" : "Enter API key"}
                 className="form-input"
                 autoComplete="off"
               />
@@ -402,14 +422,48 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
               <label>Max Context Tokens</label>
               <input
                 type="number"
-                value={form.max_tokens}
+                value={form.max_tokens || ""}
+                placeholder={form.provider_type === "local" ? "Detect from server" : undefined}
                 onChange={(e) =>
-                  setForm((prev) => ({ ...prev, max_tokens: parseInt(e.target.value) || 200000 }))
+                  setForm((prev) => ({
+                    ...prev,
+                    max_tokens:
+                      parseInt(e.target.value) || (prev.provider_type === "local" ? 0 : 200000),
+                  }))
                 }
                 className="form-input"
               />
             </div>
 
+            {/* Pricing */}
+            <div className="form-group">
+              <label>Pricing (USD per million tokens, optional)</label>
+              <div style={{ display: "flex", gap: "0.5rem" }}>
+                {(
+                  [
+                    ["input_price_per_mtok", "Input"],
+                    ["output_price_per_mtok", "Output"],
+                    ["cache_read_price_per_mtok", "Cache read"],
+                    ["cache_write_price_per_mtok", "Cache write"],
+                  ] as const
+                ).map(([key, label]) => (
+                  <input
+                    key={key}
+                    type="number"
+                    min="0"
+                    step="any"
+                    placeholder={label}
+                    title={label}
+                    value={form[key] || ""}
+                    onChange={(e) =>
+                      setForm((prev) => ({ ...prev, [key]: parseFloat(e.target.value) || 0 }))
+                    }
+                    className="form-input"
+                  />
+                ))}
+              </div>
+            </div>
+
             {/* Tags */}
             <div className="form-group">
               <label>
@@ -0,0 +1,54 @@
+import React, { useEffect, useState } from "react";
+import { api, SandboxResponse } from "../services/api";
+
+interface SandboxToggleProps {
+  conversationId: string;
+}
+
+// Shows whether the conversation's commands run sandboxed, and switches modes.
+// The change applies from the next command.
+function SandboxToggle({ conversationId }: SandboxToggleProps) {
+  const [sandbox, setSandbox] = useState<SandboxResponse | null>(null);
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    api
+      .getSandbox(conversationId)
+      .then(setSandbox)
+      .catch(() => setSandbox(null));
+  }, [conversationId]);
+
+  // Without namespace support there is nothing to choose, unless a mode was
+  // chosen where it worked.
+  if (!sandbox || (sandbox.unavailable && sandbox.mode === "host")) {
+    return null;
+  }
+
+  const sandboxed = sandbox.mode === "namespace";
+  const handleToggle = async () => {
+    setError(null);
+    try {
+      setSandbox(await api.setSandbox(conversationId, sandboxed ? "host" : "namespace"));
+    } catch (err) {
+      setError(err instanceof Error ? err.message : String(err));
+    }
+  };
+
+  return (
+    <button
+      className={`status-chip${error ? " status-chip-error" : ""}`}
+      onClick={handleToggle}
+      title={
+        error ||
+        (sandboxed
+          ? "Commands run in a sandbox where only the worktree is writable. MCP servers still run on the host. Click to run commands on the host."
+          : "Commands run on the host as the server's user. Click to sandbox them.")
+      }
+      data-testid="sandbox-toggle"
+    >
+      {sandboxed ? "Sandboxed" : "Host"}
+    </button>
+  );
+}
+
+export default SandboxToggle;
@@ -0,0 +1,138 @@
+import React, { useState } from "react";
+import { LLMContent } from "../types";
+
+// A server tool's result block, as Anthropic returns it. The content is a list
+// of search results, a fetched page, or a command's output, or an error.
+interface ServerToolResultBlock {
+  type?: string;
+  content?: unknown;
+}
+
+interface SearchResult {
+  url: string;
+  title?: string;
+  page_age?: string;
+}
+
+interface ServerToolProps {
+  toolUse: LLMContent; // server_tool_use
+  result?: LLMContent; // server_tool_result, if it has arrived
+}
+
+function field(obj: unknown, name: string): unknown {
+  return typeof obj === "object" && obj !== null && name in obj
+    ? (obj as Record<string, unknown>)[name]
+    : undefined;
+}
+
+function stringField(obj: unknown, name: string): string {
+  const value = field(obj, name);
+  return typeof value === "string" ? value : "";
+}
+
+function ServerTool({ toolUse, result }: ServerToolProps) {
+  const [isExpanded, setIsExpanded] = useState(false);
+
+  const name = toolUse.ToolName || "server tool";
+  const input = toolUse.ToolInput;
+  const summary =
+    stringField(input, "query") ||
+    stringField(input, "url") ||
+    stringField(input, "command") ||
+    stringField(input, "path") ||
+    stringField(input, "code").split("\n")[0] ||
+    name;
+  const emoji = name === "web_search" ? "🔍" : name === "web_fetch" ? "🌐" : "🧪";
+
+  const block = result?.ServerToolResult as ServerToolResultBlock | undefined;
+  const content = block?.content;
+  const errorCode = stringField(content, "type").endsWith("_error")
+    ? stringField(content, "error_code") || "error"
+    : "";
+  const returnCode = field(content, "return_code");
+  const hasError = !!errorCode || (typeof returnCode === "number" && returnCode !== 0);
+  const isComplete = result !== undefined;
+
+  const searchResults: SearchResult[] = Array.isArray(content)
+    ? content.filter((r): r is SearchResult => typeof field(r, "url") === "string")
+    : [];
+  const fetchedURL = stringField(content, "url");
+  const fetchedTitle = stringField(field(content, "content"), "title");
+  const stdout = stringField(content, "stdout");
+  const stderr = stringField(content, "stderr");
+
+  return (
+    <div className="tool" data-testid={isComplete ? "tool-call-completed" : "tool-call-running"}>
+      <div className="tool-header" onClick={() => setIsExpanded(!isExpanded)}>
+        <div className="tool-summary">
+          <span className={`tool-emoji ${isComplete ? "" : "running"}`}>{emoji}</span>
+          <span className="tool-command">{summary}</span>
+          {isComplete && hasError && <span className="tool-error">✗</span>}
+          {isComplete && !hasError && <span className="tool-success">✓</span>}
+        </div>
+        <button
+          className="tool-toggle"
+          aria-label={isExpanded ? "Collapse" : "Expand"}
+          aria-expanded={isExpanded}
+        >
+          <svg
+            width="12"
+            height="12"
+            viewBox="0 0 12 12"
+            fill="none"
+            xmlns="http://www.w3.org/2000/svg"
+            style={{
+              transform: isExpanded ? "rotate(90deg)" : "rotate(0deg)",
+              transition: "transform 0.2s",
+            }}
+          >
+            <path
+              d="M4.5 3L7.5 6L4.5 9"
+              stroke="currentColor"
+              strokeWidth="1.5"
+              strokeLinecap="round"
+              strokeLinejoin="round"
+            />
+          </svg>
+        </button>
+      </div>
+
+      {isExpanded && (
+        <div className="tool-details">
+          <div className="tool-section">
+            <div className="tool-label">Input ({name}, run by the provider):</div>
+            <pre className="tool-code">{JSON.stringify(input, null, 2)}</pre>
+          </div>
+
+          {isComplete && (
+            <div className="tool-section">
+              <div className="tool-label">Result{hasError ? " (Error)" : ""}:</div>
+              {errorCode && <pre className="tool-code error">{errorCode}</pre>}
+              {searchResults.length > 0 && (
+                <ul>
+                  {searchResults.map((r, i) => (
+                    <li key={i}>
+                      <a href={r.url} target="_blank" rel="noopener noreferrer">
+                        {r.title || r.url}
+                      </a>
+                      {r.page_age && <span className="text-secondary"> ({r.page_age})</span>}
+                    </li>
+                  ))}
+                </ul>
+              )}
+              {fetchedURL && (
+                <a href={fetchedURL} target="_blank" rel="noopener noreferrer">
+                  {fetchedTitle || fetchedURL}
+                </a>
+              )}
+              {stdout && <pre className="tool-code">{stdout}</pre>}
+              {stderr && <pre className="tool-code error">{stderr}</pre>}
+            </div>
+          )}
+        </div>
+      )}
+    </div>
+  );
+}
+
+export default ServerTool;
@@ -0,0 +1,102 @@
+import React from "react";
+import { StreamDelta, StreamResync } from "../types";
+import ThinkingContent from "./ThinkingContent";
+
+// StreamingBlock accumulates the deltas for one content block of a response
+// that is still being generated.
+export interface StreamingBlock {
+  type: string;
+  text: string;
+  toolName?: string;
+  toolInput: string;
+}
+
+// applyStreamDelta returns a new block list with the delta folded in.
+export function applyStreamDelta(blocks: StreamingBlock[], delta: StreamDelta): StreamingBlock[] {
+  if (delta.reset) {
+    return [];
+  }
+  const next = blocks.slice();
+  const prev = next[delta.index] ?? { type: delta.type, text: "", toolInput: "" };
+  next[delta.index] = {
+    type: prev.type || delta.type,
+    text: prev.text + (delta.text ?? ""),
+    toolName: prev.toolName ?? delta.tool_name,
+    toolInput: prev.toolInput + (delta.tool_input ?? ""),
+  };
+  return next;
+}
+
+// blocksFromResync returns the blocks a resync replaces the partial output with.
+export function blocksFromResync(resync: StreamResync): StreamingBlock[] {
+  return (resync.blocks ?? []).reduce<StreamingBlock[]>(applyStreamDelta, []);
+}
+
+// StreamPosition is the last delta taken from the stream. gap is set once a
+// delta of the response was missed.
+export interface StreamPosition {
+  response: number;
+  seq: number;
+  gap: boolean;
+}
+
+// followStream returns the position after delta and whether to apply it. A
+// delta is applied only if it starts a response or comes right after the last
+// one; after a missed delta, the partial output stays as it is until a resync
+// or the recorded message replaces it.
+export function followStream(
+  pos: StreamPosition | null,
+  delta: StreamDelta,
+): [StreamPosition | null, boolean] {
+  if (delta.seq === 1 && (!pos || delta.response > pos.response)) {
+    return [{ response: delta.response, seq: 1, gap: false }, true];
+  }
+  if (pos && delta.response < pos.response) {
+    return [pos, false];
+  }
+  if (pos && delta.response === pos.response && !pos.gap && delta.seq === pos.seq + 1) {
+    return [{ ...pos, seq: delta.seq }, true];
+  }
+  return [{ response: delta.response, seq: delta.seq, gap: true }, false];
+}
+
+interface StreamingMessageProps {
+  blocks: StreamingBlock[];
+}
+
+// StreamingMessage renders partial agent output until the complete message arrives.
+function StreamingMessage({ blocks }: StreamingMessageProps) {
+  const visible = blocks.filter((b) => b && (b.text || b.toolName));
+  if (visible.length === 0) {
+    return null;
+  }
+  return (
+    <div className="message message-agent" data-testid="streaming-message">
+      <div className="message-content" data-testid="message-content">
+        {visible.map((block, index) => {
+          if (block.type === "thinking") {
+            return <ThinkingContent key={index} thinking={block.text} />;
+          }
+          if (block.type === "tool_use") {
+            return (
+              <div
+                key={index}
+                className="whitespace-pre-wrap break-words"
+                style={{ fontFamily: "monospace", color: "var(--text-secondary)" }}
+              >
+                🛠️ {block.toolName} {block.toolInput}
+              </div>
+            );
+          }
+          return (
+            <div key={index} className="whitespace-pre-wrap break-words">
+              {block.text}
+            </div>
+          );
+        })}
+      </div>
+    </div>
+  );
+}
+
+export default StreamingMessage;
@@ -0,0 +1,34 @@
+import React from "react";
+import { ThinkingLevel } from "../types";
+
+const levels: ThinkingLevel[] = ["off", "minimal", "low", "medium", "high"];
+
+interface ThinkingLevelPickerProps {
+  value: ThinkingLevel | null; // null uses the model's default
+  onChange: (level: ThinkingLevel | null) => void;
+  disabled?: boolean;
+}
+
+// Chooses how much the model thinks. The level is sent with the next message,
+// and the conversation keeps it after that.
+function ThinkingLevelPicker({ value, onChange, disabled }: ThinkingLevelPickerProps) {
+  return (
+    <select
+      className="status-chip"
+      value={value ?? ""}
+      onChange={(e) => onChange((e.target.value as ThinkingLevel) || null)}
+      disabled={disabled}
+      title="How much the model thinks before answering"
+      data-testid="thinking-level-picker"
+    >
+      <option value="">Default</option>
+      {levels.map((level) => (
+        <option key={level} value={level}>
+          {level.charAt(0).toUpperCase() + level.slice(1)}
+        </option>
+      ))}
+    </select>
+  );
+}
+
+export default ThinkingLevelPicker;
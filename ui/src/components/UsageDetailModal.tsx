@@ -139,6 +139,12 @@ function UsageDetailModal({ usage, durationMs, onClose }: UsageDetailModalProps)
           )}
           <div style={{ color: "#6b7280", fontWeight: "500" }}>Output Tokens:</div>
           <div style={{ color: "#1f2937" }}>{usage.output_tokens.toLocaleString()}</div>
+          {!!usage.thinking_tokens && (
+            <>
+              <div style={{ color: "#6b7280", fontWeight: "500" }}>Thinking:</div>
+              <div style={{ color: "#1f2937" }}>{usage.thinking_tokens.toLocaleString()}</div>
+            </>
+          )}
           {usage.cost_usd > 0 && (
             <>
               <div style={{ color: "#6b7280", fontWeight: "500" }}>Cost:</div>
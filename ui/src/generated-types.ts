@@ -13,6 +13,10 @@ export interface Conversation {
   archived: boolean;
   parent_conversation_id: string | null;
   model: string | null;
+  forked_from_conversation_id: string | null;
+  forked_from_message_id: string | null;
+  scheduled_task_id: string | null;
+  thinking_level: string | null;
 }
 
 export interface Usage {
@@ -20,6 +24,7 @@ export interface Usage {
   cache_creation_input_tokens: number;
   cache_read_input_tokens: number;
   output_tokens: number;
+  thinking_tokens?: number;
   cost_usd: number;
   model?: string;
   start_time?: string | null;
@@ -39,10 +44,22 @@ export interface ApiMessageForTS {
   end_of_turn?: boolean | null;
 }
 
+export interface ApprovalRequestForTS {
+  id: string;
+  conversation_id: string;
+  parent_conversation_id?: string;
+  tool: string;
+  input: string;
+  summary: string;
+  reason: string;
+  created_at: string;
+}
+
 export interface ConversationStateForTS {
   conversation_id: string;
   working: boolean;
   model?: string;
+  pending_approvals?: ApprovalRequestForTS[] | null;
 }
 
 export interface NotificationEventForTS {
@@ -53,12 +70,39 @@ export interface NotificationEventForTS {
   payload?: any;
 }
 
+export interface StreamDeltaForTS {
+  response: number;
+  seq: number;
+  index: number;
+  type: string;
+  text?: string;
+  tool_use_id?: string;
+  tool_name?: string;
+  tool_input?: string;
+  reset?: boolean;
+}
+
+export interface StreamResyncForTS {
+  response: number;
+  seq: number;
+  blocks: StreamDeltaForTS[] | null;
+}
+
+export interface ToolProgressForTS {
+  tool_use_id: string;
+  output: string;
+}
+
 export interface StreamResponseForTS {
   messages: ApiMessageForTS[] | null;
   conversation: Conversation;
   conversation_state?: ConversationStateForTS | null;
   heartbeat?: boolean;
   notification_event?: NotificationEventForTS | null;
+  stream_delta?: StreamDeltaForTS | null;
+  stream_resync?: StreamResyncForTS | null;
+  approval_request?: ApprovalRequestForTS | null;
+  tool_progress?: ToolProgressForTS | null;
 }
 
 export interface ConversationWithStateForTS {
@@ -71,11 +115,17 @@ export interface ConversationWithStateForTS {
   archived: boolean;
   parent_conversation_id: string | null;
   model: string | null;
+  forked_from_conversation_id: string | null;
+  forked_from_message_id: string | null;
+  scheduled_task_id: string | null;
+  thinking_level: string | null;
   working: boolean;
   git_repo_root?: string;
   git_worktree_root?: string;
   git_commit?: string;
   git_subject?: string;
+  match_sequence_id?: number | null;
+  match_snippet?: string;
 }
 
 export type MessageType = "user" | "agent" | "tool" | "error" | "system" | "gitinfo";
@@ -1,6 +1,7 @@
 import React from "react";
 import { createRoot } from "react-dom/client";
 import App from "./App";
+import LoginPage from "./components/LoginPage";
 import { initializeTheme } from "./services/theme";
 import { initializeNotifications } from "./services/notifications";
 import { MarkdownProvider } from "./contexts/MarkdownContext";
@@ -16,8 +17,13 @@ const rootContainer = document.getElementById("root");
 if (!rootContainer) throw new Error("Root container not found");
 
 const root = createRoot(rootContainer);
-root.render(
-  <MarkdownProvider>
-    <App />
-  </MarkdownProvider>,
-);
+const auth = window.__SHELLEY_INIT__?.auth;
+if (auth?.required) {
+  root.render(<LoginPage setupRequired={!!auth.setup_required} />);
+} else {
+  root.render(
+    <MarkdownProvider>
+      <App />
+    </MarkdownProvider>,
+  );
+}
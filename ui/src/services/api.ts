@@ -121,6 +121,210 @@ class ApiService {
     }
   }
 
+  async getBudget(conversationId: string): Promise<BudgetResponse> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/budget`);
+    if (!response.ok) {
+      throw new Error(`Failed to get budget: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async setBudget(conversationId: string, budget: Budget): Promise<Budget> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/budget`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify(budget),
+    });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || response.statusText);
+    }
+    return response.json();
+  }
+
+  async resumeConversation(conversationId: string): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/resume`, {
+      method: "POST",
+      headers: this.postHeaders,
+    });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || response.statusText);
+    }
+  }
+
+  async forkConversation(
+    conversationId: string,
+    messageId: string,
+    message?: string,
+    restoreFiles?: boolean,
+  ): Promise<{ conversation_id: string; conversation: Conversation; restored?: string[] }> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/fork`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ message_id: messageId, message, restore_files: restoreFiles }),
+    });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || response.statusText);
+    }
+    return response.json();
+  }
+
+  async listCheckpoints(conversationId: string): Promise<CheckpointInfo[]> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/checkpoints`);
+    if (!response.ok) {
+      throw new Error(`Failed to list checkpoints: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async restoreCheckpoint(
+    conversationId: string,
+    checkpointId: number,
+  ): Promise<{ restored: string[] }> {
+    const response = await fetch(
+      `${this.baseUrl}/conversation/${conversationId}/checkpoints/${checkpointId}/restore`,
+      { method: "POST", headers: this.postHeaders },
+    );
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || response.statusText);
+    }
+    return response.json();
+  }
+
+  async resolveApproval(
+    conversationId: string,
+    approvalId: string,
+    decision: "allow" | "deny",
+    remember: boolean,
+  ): Promise<void> {
+    const response = await fetch(
+      `${this.baseUrl}/conversation/${conversationId}/approvals/${approvalId}`,
+      {
+        method: "POST",
+        headers: this.postHeaders,
+        body: JSON.stringify({ decision, remember }),
+      },
+    );
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || response.statusText);
+    }
+  }
+
+  async getPermissions(conversationId: string): Promise<PermissionsResponse> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/permissions`);
+    if (!response.ok) {
+      throw new Error(`Failed to get permissions: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async setPermissions(conversationId: string, policy: PermissionPolicy): Promise<PermissionPolicy> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/permissions`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify(policy),
+    });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || response.statusText);
+    }
+    return response.json();
+  }
+
+  async listProcesses(conversationId: string): Promise<BackgroundProcess[]> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/processes`);
+    if (!response.ok) {
+      throw new Error(`Failed to list processes: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getProcessOutput(conversationId: string, processId: string, lines = 200): Promise<string> {
+    const response = await fetch(
+      `${this.baseUrl}/conversation/${conversationId}/processes/${processId}/output?lines=${lines}`,
+    );
+    if (!response.ok) {
+      throw new Error(`Failed to get process output: ${response.statusText}`);
+    }
+    return response.text();
+  }
+
+  async stopProcess(conversationId: string, processId: string): Promise<BackgroundProcess> {
+    const response = await fetch(
+      `${this.baseUrl}/conversation/${conversationId}/processes/${processId}/stop`,
+      { method: "POST", headers: this.postHeaders },
+    );
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || response.statusText);
+    }
+    return response.json();
+  }
+
+  async getSandbox(conversationId: string): Promise<SandboxResponse> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/sandbox`);
+    if (!response.ok) {
+      throw new Error(`Failed to get sandbox: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async setSandbox(conversationId: string, mode: SandboxMode): Promise<SandboxResponse> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/sandbox`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ mode }),
+    });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || response.statusText);
+    }
+    return response.json();
+  }
+
+  async getConversationMCP(conversationId: string): Promise<MCPServerStatus[]> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/mcp`);
+    if (!response.ok) {
+      throw new Error(`Failed to get MCP servers: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async listMCPServers(): Promise<MCPServer[]> {
+    const response = await fetch(`${this.baseUrl}/mcp-servers`);
+    if (!response.ok) {
+      throw new Error(`Failed to list MCP servers: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async saveMCPServer(config: MCPServerConfig, enabled = true): Promise<MCPServer> {
+    const response = await fetch(`${this.baseUrl}/mcp-servers`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ enabled, config }),
+    });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || response.statusText);
+    }
+    return response.json();
+  }
+
+  async deleteMCPServer(name: string): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/mcp-servers/${encodeURIComponent(name)}`, {
+      method: "DELETE",
+      headers: this.postHeaders,
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to delete MCP server: ${response.statusText}`);
+    }
+  }
+
   async validateCwd(path: string): Promise<{ valid: boolean; error?: string }> {
     const response = await fetch(`${this.baseUrl}/validate-cwd?path=${encodeURIComponent(path)}`);
     if (!response.ok) {
@@ -341,31 +545,153 @@ class ApiService {
 
 export const api = new ApiService();
 
+// Spending budgets. Zero or missing limits are unlimited.
+export interface Budget {
+  max_dollars?: number;
+  max_tokens?: number;
+  max_turns?: number;
+}
+
+export interface BudgetSpend {
+  cost_usd: number;
+  tokens: number;
+  turns: number;
+}
+
+export interface BudgetResponse {
+  conversation: Budget;
+  conversation_spend: BudgetSpend;
+  overridden: boolean;
+  daily: Budget;
+  daily_spend: BudgetSpend;
+  exceeded?: string;
+}
+
+// Filesystem checkpoint taken at the start of a user turn.
+export interface CheckpointInfo {
+  checkpoint_id: number;
+  sequence_id: number;
+  created_at: string;
+  files: string[]; // changed by the patch tool during the turn
+  worktree?: string; // set if the whole git worktree was snapshotted
+}
+
+export interface PermissionRule {
+  action: "allow" | "deny" | "ask";
+  tool?: string; // glob of tool names
+  command?: string; // leading words of a bash command
+  path?: string; // glob of file paths
+  host?: string; // URL host, or "*.example.com"
+  reason?: string;
+}
+
+export interface PermissionPolicy {
+  default?: "allow" | "deny" | "ask";
+  rules?: PermissionRule[];
+}
+
+export interface PermissionsResponse {
+  conversation: PermissionPolicy;
+  project: PermissionPolicy;
+  project_file?: string;
+  server: PermissionPolicy;
+}
+
+// Long-running process started with the background tool.
+export interface BackgroundProcess {
+  id: string;
+  command: string;
+  working_dir: string;
+  pid: number;
+  started_at: string;
+  running: boolean;
+  exit_code?: number;
+  exited_at?: string;
+  log_path: string;
+}
+
+// How a conversation's commands run: directly on the host, or in Linux namespaces.
+export type SandboxMode = "host" | "namespace";
+
+export interface SandboxResponse {
+  mode: SandboxMode;
+  conversation_mode?: SandboxMode;
+  server: {
+    mode?: SandboxMode;
+    share_network?: boolean;
+    read_write?: string[];
+    env?: string[];
+  };
+  unavailable?: string;
+}
+
+// MCP (Model Context Protocol) servers, whose tools conversations can use.
+export interface MCPServerConfig {
+  name: string;
+  command?: string;
+  args?: string[];
+  env?: Record<string, string>;
+  url?: string;
+  headers?: Record<string, string>;
+}
+
+export interface MCPServer {
+  name: string;
+  source: "config" | "database";
+  enabled: boolean;
+  config: MCPServerConfig;
+}
+
+export interface MCPServerStatus {
+  name: string;
+  transport: "stdio" | "http";
+  connected: boolean;
+  healthy: boolean;
+  error?: string;
+  server?: {
+    name: string;
+    version: string;
+    tools: boolean;
+    resources: boolean;
+    prompts: boolean;
+  };
+  tools: string[];
+}
+
 // Custom models API
 export interface CustomModel {
   model_id: string;
   display_name: string;
-  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini";
+  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini" | "local";
   endpoint: string;
   api_key: string;
   model_name: string;
   max_tokens: number;
   tags: string; // Comma-separated tags (e.g., "slug" for slug generation)
+  // Prices in USD per million tokens; 0 means unknown
+  input_price_per_mtok: number;
+  output_price_per_mtok: number;
+  cache_read_price_per_mtok: number;
+  cache_write_price_per_mtok: number;
 }
 
 export interface CreateCustomModelRequest {
   display_name: string;
-  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini";
+  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini" | "local";
   endpoint: string;
   api_key: string;
   model_name: string;
   max_tokens: number;
   tags: string; // Comma-separated tags
+  input_price_per_mtok: number;
+  output_price_per_mtok: number;
+  cache_read_price_per_mtok: number;
+  cache_write_price_per_mtok: number;
 }
 
 export interface TestCustomModelRequest {
   model_id?: string; // If provided with empty api_key, use stored key
-  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini";
+  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini" | "local";
   endpoint: string;
   api_key: string;
   model_name: string;
@@ -548,3 +874,77 @@ class NotificationChannelsApi {
 }
 
 export const notificationChannelsApi = new NotificationChannelsApi();
+
+// Built-in authentication API, for servers run with --auth
+export interface AuthSessionAPI {
+  session_id: string;
+  kind: "session" | "token";
+  name: string;
+  created_at: string;
+  last_used_at: string | null;
+  expires_at: string | null;
+  current: boolean;
+}
+
+class AuthApi {
+  private baseUrl = "/api/auth";
+
+  private postHeaders = {
+    "Content-Type": "application/json",
+  };
+
+  private async throwIfNotOk(response: Response, fallback: string): Promise<void> {
+    if (response.ok) return;
+    const body = await response.text().catch(() => "");
+    throw new Error(body.trim() || `${fallback}: ${response.statusText}`);
+  }
+
+  async setup(setupCode: string, password: string): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/setup`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ setup_code: setupCode, password }),
+    });
+    await this.throwIfNotOk(response, "Failed to set password");
+  }
+
+  async login(password: string): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/login`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ password }),
+    });
+    await this.throwIfNotOk(response, "Failed to sign in");
+  }
+
+  async logout(): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/logout`, { method: "POST" });
+    await this.throwIfNotOk(response, "Failed to sign out");
+  }
+
+  async getSessions(): Promise<AuthSessionAPI[]> {
+    const response = await fetch(`${this.baseUrl}/sessions`);
+    await this.throwIfNotOk(response, "Failed to get sessions");
+    return response.json();
+  }
+
+  async revokeSession(sessionId: string): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/sessions/${sessionId}`, { method: "DELETE" });
+    await this.throwIfNotOk(response, "Failed to revoke session");
+  }
+
+  async createToken(
+    name: string,
+    expiresInDays: number,
+  ): Promise<{ token: string; session: AuthSessionAPI }> {
+    const response = await fetch(`${this.baseUrl}/tokens`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ name, expires_in_days: expiresInDays }),
+    });
+    await this.throwIfNotOk(response, "Failed to create token");
+    return response.json();
+  }
+}
+
+export const authApi = new AuthApi();
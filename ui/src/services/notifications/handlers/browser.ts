@@ -18,5 +18,11 @@ export function browserNotificationHandler(event: NotificationEvent): void {
         tag: "shelley-agent-error",
       });
       break;
+    case "budget_exceeded":
+      new Notification("Shelley", {
+        body: "Budget reached; conversation paused",
+        tag: "shelley-budget-exceeded",
+      });
+      break;
   }
 }
@@ -5,6 +5,7 @@ export function faviconNotificationHandler(event: NotificationEvent): void {
   switch (event.type) {
     case "agent_done":
     case "agent_error":
+    case "budget_exceeded":
       setFaviconStatus("ready");
       break;
   }
@@ -5,6 +5,10 @@ import {
   ApiMessageForTS,
   StreamResponseForTS,
   NotificationEventForTS,
+  StreamDeltaForTS,
+  StreamResyncForTS,
+  ApprovalRequestForTS,
+  ToolProgressForTS,
   Usage as GeneratedUsage,
   MessageType as GeneratedMessageType,
 } from "./generated-types";
@@ -13,6 +17,10 @@ import {
 export type Conversation = GeneratedConversation;
 export type ConversationWithState = ConversationWithStateForTS;
 export type Usage = GeneratedUsage;
+export type StreamDelta = StreamDeltaForTS;
+export type StreamResync = StreamResyncForTS;
+export type ApprovalRequest = ApprovalRequestForTS;
+export type ToolProgress = ToolProgressForTS;
 export type MessageType = GeneratedMessageType;
 
 // Extend the generated Message type with parsed data
@@ -25,6 +33,7 @@ export interface LLMMessage {
   Role: number; // 0 = user, 1 = assistant
   Content: LLMContent[];
   ToolUse?: unknown;
+  ErrorType?: "truncation" | "llm_request" | "budget" | "model_fallback";
 }
 
 export interface LLMContent {
@@ -37,6 +46,7 @@ export interface LLMContent {
   ToolError?: boolean;
   // Other fields from Go struct
   MediaType?: string;
+  Filename?: string;
   Thinking?: string;
   Data?: string;
   Signature?: string;
@@ -45,6 +55,21 @@ export interface LLMContent {
   ToolUseEndTime?: string | null;
   Display?: unknown;
   Cache?: boolean;
+  // For server_tool_result: the provider's result block
+  ServerToolResult?: unknown;
+  // Sources cited by a text block
+  Citations?: Citation[];
+}
+
+// A source cited by a text block: a web page (url) or a passage of an
+// attached document (document_title).
+export interface Citation {
+  type: string;
+  cited_text?: string;
+  url?: string;
+  title?: string;
+  document_index?: number;
+  document_title?: string;
 }
 
 // API types
@@ -56,13 +81,38 @@ export interface Model {
   max_context_tokens?: number;
 }
 
+// How much the model thinks before answering.
+export type ThinkingLevel = "off" | "minimal" | "low" | "medium" | "high";
+
 export interface ChatRequest {
   message: string;
   model?: string;
   cwd?: string;
+  sandbox?: "host" | "namespace";
+  attachments?: Attachment[];
+  // Kept by the conversation for later messages; "default" clears it.
+  thinking_level?: ThinkingLevel | "default";
+}
+
+// An image, PDF, or text file sent with a chat message: inline as base64
+// data, or by the path returned by /api/upload.
+export interface Attachment {
+  filename?: string;
+  media_type?: string;
+  data?: string;
+  path?: string;
 }
 // Notification event types
-export type NotificationEventType = "agent_done" | "agent_error";
+export type NotificationEventType =
+  | "agent_done"
+  | "agent_error"
+  | "budget_exceeded"
+  | "budget_threshold"
+  | "tool_approval"
+  | "subagent_done"
+  | "command_done"
+  | "compaction"
+  | "upgrade_available";
 
 export interface NotificationEvent extends Omit<NotificationEventForTS, "type"> {
   type: NotificationEventType;
@@ -94,6 +144,15 @@ export interface InitData {
   terminal_url?: string;
   links?: Link[];
   notification_channel_types?: import("./services/api").ChannelTypeInfo[];
+  auth?: AuthInit;
+}
+
+// AuthInit describes built-in authentication, when the server runs with --auth.
+// Until the user signs in, it is the only init data.
+export interface AuthInit {
+  enabled: boolean;
+  required?: boolean;
+  setup_required?: boolean;
 }
 
 // Extend Window interface to include our init data
@@ -187,3 +246,15 @@ export function isDistillStatusMessage(message: Message): boolean {
     return false;
   }
 }
+
+// Helper to check if a message is an auto compaction status message
+export function isCompactionStatusMessage(message: Message): boolean {
+  if (message.type !== "system" || !message.user_data) return false;
+  try {
+    const userData =
+      typeof message.user_data === "string" ? JSON.parse(message.user_data) : message.user_data;
+    return !!userData.compaction_status;
+  } catch {
+    return false;
+  }
+}